@@ -0,0 +1,123 @@
+package walker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"code.google.com/p/log4go"
+)
+
+// AdaptHandler returns an AckHandler that calls h, acking each response as
+// soon as h returns. If h is a StreamingHandler, HandleStream is called with
+// the (already buffered) response body, and if it is a RetryableHandler,
+// TryHandleResponse is called; either's error is returned instead.
+func AdaptHandler(h Handler) AckHandler {
+	return handlerAdapter{h}
+}
+
+type handlerAdapter struct {
+	Handler
+}
+
+func (a handlerAdapter) HandleResponse(ctx context.Context, res *FetchResults, ack Ack) error {
+	if h, ok := a.Handler.(StreamingHandler); ok {
+		if err := h.HandleStream(ctx, res, res.Response.Body); err != nil {
+			return err
+		}
+	} else if h, ok := a.Handler.(RetryableHandler); ok {
+		if err := h.TryHandleResponse(ctx, res); err != nil {
+			return err
+		}
+	} else {
+		a.Handler.HandleResponse(ctx, res)
+	}
+	ack.Ack()
+	return nil
+}
+
+// errNacked is the error dead-lettered for a response nacked with a nil error
+var errNacked = errors.New("handler nacked the response")
+
+// responseAck is the Ack the fetcher hands to the handler with each response.
+// Once it is acked or nacked it stores the fetch results and marks the
+// response done in pending.
+type responseAck struct {
+	fm      *FetchManager
+	ctx     context.Context
+	res     *FetchResults
+	pending *pendingAcks
+	once    sync.Once
+	done    int32
+}
+
+// Ack implements the Ack interface
+func (a *responseAck) Ack() {
+	a.once.Do(func() { a.finish(nil) })
+}
+
+// Nack implements the Ack interface
+func (a *responseAck) Nack(err error) {
+	if err == nil {
+		err = errNacked
+	}
+	a.once.Do(func() { a.finish(err) })
+}
+
+// finished returns true once the response has been acked or nacked
+func (a *responseAck) finished() bool {
+	return atomic.LoadInt32(&a.done) == 1
+}
+
+func (a *responseAck) finish(err error) {
+	atomic.StoreInt32(&a.done, 1)
+	defer a.pending.done()
+	if err != nil {
+		a.fm.HandlerFailed(a.res, err)
+	}
+	log4go.Fine("Storing fetch results for %v", a.res.URL)
+	a.fm.storeURLFetchResults(a.ctx, a.res)
+}
+
+// pendingAcks counts the responses of a host the handler has yet to ack or
+// nack. Unlike a sync.WaitGroup it can be waited on with a timeout without
+// leaving a goroutine blocked if the handler never acks.
+type pendingAcks struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // closed when n drops back to 0
+}
+
+// add counts another response waiting for its ack
+func (p *pendingAcks) add() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.n == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.n++
+}
+
+// done marks a response acked (or nacked)
+func (p *pendingAcks) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.n--
+	if p.n == 0 {
+		close(p.idle)
+	}
+}
+
+// wait returns a channel that is closed once no responses are waiting for
+// their ack, and how many are waiting now
+func (p *pendingAcks) wait() (<-chan struct{}, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.n == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle, 0
+	}
+	return p.idle, p.n
+}
@@ -0,0 +1,263 @@
+package walker
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"code.google.com/p/log4go"
+)
+
+// Alert describes a crawl anomaly detected by the dispatcher (see the alerts
+// section of walker.yaml)
+type Alert struct {
+	// The condition that fired, ex. "domain_error_rate"
+	Condition string `json:"condition"`
+
+	// The domain (or other subject, like a fetcher token) the alert is
+	// about; empty for crawl-wide conditions
+	Subject string `json:"subject,omitempty"`
+
+	// Human readable description of what happened
+	Message string `json:"message"`
+
+	Time time.Time `json:"time"`
+}
+
+// String formats the alert for logs and plain text notifications
+func (a *Alert) String() string {
+	if a.Subject == "" {
+		return fmt.Sprintf("[%v] %v", a.Condition, a.Message)
+	}
+	return fmt.Sprintf("[%v] %v: %v", a.Condition, a.Subject, a.Message)
+}
+
+// Notifier sends alerts somewhere a person will see them. Implement this to
+// add your own destination to an Alerter.
+type Notifier interface {
+	Notify(a *Alert) error
+}
+
+// notifierClient is used by the HTTP based notifiers
+var notifierClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookNotifier POSTs each alert, JSON encoded, to URL
+type WebhookNotifier struct {
+	URL string
+}
+
+// Notify implements the Notifier interface
+func (n *WebhookNotifier) Notify(a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return postJSON(n.URL, body)
+}
+
+// SlackNotifier posts each alert as a message through a Slack incoming
+// webhook
+type SlackNotifier struct {
+	WebhookURL string
+}
+
+// Notify implements the Notifier interface
+func (n *SlackNotifier) Notify(a *Alert) error {
+	body, err := json.Marshal(map[string]string{"text": "Walker alert " + a.String()})
+	if err != nil {
+		return err
+	}
+	return postJSON(n.WebhookURL, body)
+}
+
+func postJSON(url string, body []byte) error {
+	res, err := notifierClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("%v returned %v", url, res.Status)
+	}
+	return nil
+}
+
+// emailTimeout bounds the whole SMTP conversation of EmailNotifier, like
+// notifierClient's timeout does for the HTTP based notifiers
+var emailTimeout = 10 * time.Second
+
+// EmailNotifier emails each alert to the To addresses through the SMTP
+// server at Server (host:port)
+type EmailNotifier struct {
+	Server string
+	From   string
+	To     []string
+}
+
+// Notify implements the Notifier interface
+func (n *EmailNotifier) Notify(a *Alert) error {
+	msg := fmt.Sprintf("From: %v\r\nTo: %v\r\nSubject: Walker alert: %v\r\n\r\n%v\r\n",
+		n.From, strings.Join(n.To, ", "), a.Condition, a)
+	return n.send([]byte(msg))
+}
+
+// send does what smtp.SendMail does (without auth), but gives up once
+// emailTimeout has passed instead of waiting on the server forever
+func (n *EmailNotifier) send(msg []byte) error {
+	host, _, err := net.SplitHostPort(n.Server)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", n.Server, emailTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// alertQueueSize is the number of alerts an Alerter holds for sending before
+// it starts dropping them
+const alertQueueSize = 100
+
+// Alerter logs alerts and sends them to its Notifiers, suppressing repeats of
+// the same alert within alerts.repeat_interval. Alerts are sent in the
+// background, one at a time, so a slow notifier never holds up the caller of
+// Raise. It is safe for concurrent use.
+//
+// Always create an Alerter using NewAlerter()
+type Alerter struct {
+	// Where alerts are sent. NewAlerter fills this in from the config; add
+	// to it before the Alerter is in use to send alerts elsewhere too.
+	Notifiers []Notifier
+
+	repeatInterval time.Duration
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+
+	queue    chan *Alert
+	sender   sync.Once
+	inFlight sync.WaitGroup
+}
+
+// NewAlerter creates an Alerter with the notifiers configured in the alerts
+// section of walker.yaml
+func NewAlerter() *Alerter {
+	repeat, err := time.ParseDuration(Config.Alerts.RepeatInterval)
+	if err != nil {
+		panic(err) // Should not happen since it is parsed at config load
+	}
+	a := &Alerter{
+		repeatInterval: repeat,
+		lastSent:       map[string]time.Time{},
+		queue:          make(chan *Alert, alertQueueSize),
+	}
+
+	al := &Config.Alerts
+	if al.WebhookURL != "" {
+		a.Notifiers = append(a.Notifiers, &WebhookNotifier{URL: al.WebhookURL})
+	}
+	if al.SlackWebhookURL != "" {
+		a.Notifiers = append(a.Notifiers, &SlackNotifier{WebhookURL: al.SlackWebhookURL})
+	}
+	if len(al.EmailTo) > 0 {
+		a.Notifiers = append(a.Notifiers, &EmailNotifier{
+			Server: al.EmailSMTPServer,
+			From:   al.EmailFrom,
+			To:     al.EmailTo,
+		})
+	}
+	return a
+}
+
+// Raise sends an alert for condition about subject (which may be empty),
+// unless the same alert was already sent within the repeat interval. It
+// returns true if the alert was queued for sending; see Wait.
+func (a *Alerter) Raise(condition, subject, format string, args ...interface{}) bool {
+	alert := &Alert{
+		Condition: condition,
+		Subject:   subject,
+		Message:   fmt.Sprintf(format, args...),
+		Time:      time.Now(),
+	}
+
+	key := condition + " " + subject
+	a.mu.Lock()
+	last, ok := a.lastSent[key]
+	if ok && alert.Time.Sub(last) < a.repeatInterval {
+		a.mu.Unlock()
+		log4go.Debug("Suppressing repeated alert %v", alert)
+		return false
+	}
+	a.lastSent[key] = alert.Time
+	a.mu.Unlock()
+
+	log4go.Warn("Alert %v", alert)
+	a.sender.Do(func() { go a.send() })
+	a.inFlight.Add(1)
+	select {
+	case a.queue <- alert:
+	default:
+		a.inFlight.Done()
+		log4go.Error("Dropping alert %v, %d alerts are already waiting to be sent", alert, alertQueueSize)
+	}
+	return true
+}
+
+// Wait blocks until every alert raised so far has been sent (or failed to
+// send)
+func (a *Alerter) Wait() {
+	a.inFlight.Wait()
+}
+
+// send passes queued alerts to the notifiers, for the life of the process
+func (a *Alerter) send() {
+	for alert := range a.queue {
+		for _, n := range a.Notifiers {
+			if err := n.Notify(alert); err != nil {
+				log4go.Error("Failed to send alert %v with %T: %v", alert, n, err)
+			}
+		}
+		a.inFlight.Done()
+	}
+}
@@ -0,0 +1,172 @@
+package walker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	alerts []*Alert
+}
+
+func (n *recordingNotifier) Notify(a *Alert) error {
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+func TestAlerterRepeatInterval(t *testing.T) {
+	orig := Config.Alerts.RepeatInterval
+	defer func() {
+		Config.Alerts.RepeatInterval = orig
+	}()
+	Config.Alerts.RepeatInterval = "1h"
+
+	rec := &recordingNotifier{}
+	a := NewAlerter()
+	a.Notifiers = append(a.Notifiers, rec)
+
+	if !a.Raise("test_condition", "a.com", "%d failures", 3) {
+		t.Errorf("Expected the first alert to be sent")
+	}
+	if a.Raise("test_condition", "a.com", "%d failures", 4) {
+		t.Errorf("Expected a repeated alert to be suppressed")
+	}
+	if !a.Raise("test_condition", "b.com", "%d failures", 5) {
+		t.Errorf("Expected an alert for another subject to be sent")
+	}
+	a.Wait()
+	if len(rec.alerts) != 2 {
+		t.Fatalf("Expected 2 alerts sent, got %d", len(rec.alerts))
+	}
+	if rec.alerts[0].String() != "[test_condition] a.com: 3 failures" {
+		t.Errorf("Unexpected alert %q", rec.alerts[0])
+	}
+
+	Config.Alerts.RepeatInterval = "0s"
+	a = NewAlerter()
+	a.Notifiers = append(a.Notifiers, rec)
+	a.Raise("test_condition", "", "again")
+	if !a.Raise("test_condition", "", "again") {
+		t.Errorf("Expected repeats to be sent with a 0s repeat_interval")
+	}
+}
+
+func TestAlerterHTTPNotifiers(t *testing.T) {
+	origWebhook := Config.Alerts.WebhookURL
+	origSlack := Config.Alerts.SlackWebhookURL
+	defer func() {
+		Config.Alerts.WebhookURL = origWebhook
+		Config.Alerts.SlackWebhookURL = origSlack
+	}()
+
+	bodies := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected a JSON post to %v, got Content-Type %q", r.URL.Path, ct)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies[r.URL.Path] = string(b)
+	}))
+	defer server.Close()
+
+	Config.Alerts.WebhookURL = server.URL + "/webhook"
+	Config.Alerts.SlackWebhookURL = server.URL + "/slack"
+	a := NewAlerter()
+	if len(a.Notifiers) != 2 {
+		t.Fatalf("Expected 2 configured notifiers, got %d", len(a.Notifiers))
+	}
+	a.Raise("write_failures", "", "10 writes failed")
+	a.Wait()
+
+	var alert Alert
+	if err := json.Unmarshal([]byte(bodies["/webhook"]), &alert); err != nil {
+		t.Fatalf("Failed to decode webhook body %q: %v", bodies["/webhook"], err)
+	}
+	if alert.Condition != "write_failures" || alert.Message != "10 writes failed" {
+		t.Errorf("Unexpected webhook alert: %+v", alert)
+	}
+
+	var slack map[string]string
+	if err := json.Unmarshal([]byte(bodies["/slack"]), &slack); err != nil {
+		t.Fatalf("Failed to decode slack body %q: %v", bodies["/slack"], err)
+	}
+	if !strings.Contains(slack["text"], "[write_failures] 10 writes failed") {
+		t.Errorf("Unexpected slack message %q", slack["text"])
+	}
+}
+
+type blockingNotifier struct {
+	release chan struct{}
+}
+
+func (n *blockingNotifier) Notify(a *Alert) error {
+	<-n.release
+	return nil
+}
+
+func TestAlerterDoesNotBlock(t *testing.T) {
+	block := &blockingNotifier{release: make(chan struct{})}
+	rec := &recordingNotifier{}
+	a := NewAlerter()
+	a.Notifiers = []Notifier{block, rec}
+
+	raised := make(chan bool)
+	go func() {
+		raised <- a.Raise("test_condition", "a.com", "slow notifier")
+	}()
+	select {
+	case <-raised:
+	case <-time.After(time.Second):
+		t.Fatalf("Raise blocked on a slow notifier")
+	}
+
+	close(block.release)
+	a.Wait()
+	if len(rec.alerts) != 1 {
+		t.Errorf("Expected the alert to be sent once the slow notifier finished, got %v", rec.alerts)
+	}
+}
+
+func TestEmailNotifierTimeout(t *testing.T) {
+	origTimeout := emailTimeout
+	defer func() {
+		emailTimeout = origTimeout
+	}()
+	emailTimeout = 100 * time.Millisecond
+
+	// A server that accepts connections but never sends its greeting
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	n := &EmailNotifier{Server: ln.Addr().String(), From: "walker@test.com", To: []string{"ops@test.com"}}
+	done := make(chan error)
+	go func() {
+		done <- n.Notify(&Alert{Condition: "test_condition", Message: "hung server"})
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Expected an error from a server that never answers")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("EmailNotifier did not time out")
+	}
+}
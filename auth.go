@@ -0,0 +1,334 @@
+package walker
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"code.google.com/p/log4go"
+)
+
+// DomainCredential configures how the fetcher authenticates to a domain. See
+// fetcher.domain_credentials in walker.yaml.
+type DomainCredential struct {
+	// One of basic, digest or bearer
+	Type string `yaml:"type"`
+
+	// Username and Password for basic and digest auth, Token for bearer
+	// auth. Password and Token are secrets; see ResolveSecret for the forms
+	// they can take.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+}
+
+// credential is a DomainCredential with its secret resolved, ready to be
+// applied to requests
+type credential struct {
+	typ      string
+	username string
+	secret   string
+}
+
+// resolve returns c with its password or token resolved
+func (c DomainCredential) resolve() (*credential, error) {
+	cred := &credential{typ: strings.ToLower(c.Type), username: c.Username}
+	ref := c.Password
+	if cred.typ == "bearer" {
+		ref = c.Token
+	}
+	var err error
+	cred.secret, err = ResolveSecret(ref)
+	if err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// validate checks that c is complete and that its secret resolves
+func (c DomainCredential) validate() error {
+	switch strings.ToLower(c.Type) {
+	case "basic", "digest":
+		if c.Username == "" {
+			return fmt.Errorf("needs a username")
+		}
+	case "bearer":
+		if c.Token == "" {
+			return fmt.Errorf("needs a token")
+		}
+	default:
+		return fmt.Errorf("type not one of (basic, digest, bearer)")
+	}
+	_, err := c.resolve()
+	return err
+}
+
+// loadCredentials resolves fetcher.domain_credentials, returning them keyed by
+// lowercased domain. Credentials that fail to resolve are logged and left
+// out.
+func loadCredentials() map[string]*credential {
+	creds := map[string]*credential{}
+	for dom, c := range Config.Fetcher.DomainCredentials {
+		cred, err := c.resolve()
+		if err != nil {
+			log4go.Error("Failed to resolve credentials for %v, crawling it without them: %v", dom, err)
+			continue
+		}
+		creds[strings.ToLower(dom)] = cred
+	}
+	return creds
+}
+
+// credentialFor returns the credential configured for host or the closest of
+// its parent domains, or nil if there is none
+func (fm *FetchManager) credentialFor(host string) *credential {
+	host = strings.ToLower(host)
+	for host != "" {
+		if cred, ok := fm.credentials[host]; ok {
+			return cred
+		}
+		i := strings.Index(host, ".")
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return nil
+}
+
+// authorize sets the Authorization header on req, unless this is a digest
+// credential, which can only answer a challenge (see authorizeDigest)
+func (c *credential) authorize(req *http.Request) {
+	switch c.typ {
+	case "basic":
+		req.SetBasicAuth(c.username, c.secret)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+c.secret)
+	}
+}
+
+// ResolveSecret returns the secret that ref refers to. "env:NAME" is the value
+// of environment variable NAME, "file:/path" is the contents of a file with
+// surrounding whitespace trimmed (ex. a mounted secret), and "encrypted:DATA"
+// is DATA decrypted with fetcher.credentials_key (see EncryptSecret). Anything
+// else is the secret itself.
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %v is not set", name)
+		}
+		return val, nil
+
+	case strings.HasPrefix(ref, "file:"):
+		b, err := ioutil.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+
+	case strings.HasPrefix(ref, "encrypted:"):
+		gcm, err := credentialsCipher()
+		if err != nil {
+			return "", err
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ref, "encrypted:"))
+		if err != nil {
+			return "", fmt.Errorf("bad encrypted secret: %v", err)
+		}
+		if len(data) < gcm.NonceSize() {
+			return "", fmt.Errorf("bad encrypted secret: too short")
+		}
+		plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt secret (wrong fetcher.credentials_key?): %v", err)
+		}
+		return string(plain), nil
+	}
+	return ref, nil
+}
+
+// EncryptSecret encrypts secret with fetcher.credentials_key, returning a
+// value that can be used for a password or token in
+// fetcher.domain_credentials
+func EncryptSecret(secret string) (string, error) {
+	gcm, err := credentialsCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return "encrypted:" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// credentialsCipher returns the AES-GCM cipher keyed by
+// fetcher.credentials_key, which is itself resolved with ResolveSecret and
+// must be 64 hex characters (a 256 bit key)
+func credentialsCipher() (cipher.AEAD, error) {
+	if Config.Fetcher.CredentialsKey == "" || strings.HasPrefix(Config.Fetcher.CredentialsKey, "encrypted:") {
+		return nil, fmt.Errorf("fetcher.credentials_key must be set to use encrypted secrets")
+	}
+	keyHex, err := ResolveSecret(Config.Fetcher.CredentialsKey)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher.credentials_key: %v", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(keyHex))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("fetcher.credentials_key must be 64 hex characters")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// authorizeDigest returns a copy of req answering the digest challenge of
+// res (a 401 response to req), or nil if res has no digest challenge
+func (c *credential) authorizeDigest(req *http.Request, res *http.Response) *http.Request {
+	challenge := res.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(strings.ToLower(challenge), "digest ") {
+		return nil
+	}
+	params := parseAuthParams(challenge[len("digest "):])
+
+	var h func() hash.Hash
+	algorithm := params["algorithm"]
+	switch strings.ToUpper(algorithm) {
+	case "", "MD5", "MD5-SESS":
+		h = md5.New
+	case "SHA-256", "SHA-256-SESS":
+		h = sha256.New
+	default:
+		log4go.Debug("Unsupported digest algorithm %q for %v", algorithm, req.URL)
+		return nil
+	}
+	hashHex := func(s string) string {
+		d := h()
+		io.WriteString(d, s)
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	realm, nonce := params["realm"], params["nonce"]
+	cnonceBytes := make([]byte, 8)
+	rand.Read(cnonceBytes)
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+
+	ha1 := hashHex(c.username + ":" + realm + ":" + c.secret)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = hashHex(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	uri := req.URL.RequestURI()
+	ha2 := hashHex(req.Method + ":" + uri)
+
+	qop := ""
+	for _, q := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	var response string
+	if qop == "" {
+		response = hashHex(ha1 + ":" + nonce + ":" + ha2)
+	} else {
+		response = hashHex(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	auth := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		c.username, realm, nonce, uri, response)
+	if algorithm != "" {
+		auth += ", algorithm=" + algorithm
+	}
+	if opaque, ok := params["opaque"]; ok {
+		auth += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	if qop != "" {
+		auth += fmt.Sprintf(", qop=%s, nc=%s, cnonce=\"%s\"", qop, nc, cnonce)
+	}
+
+	retry := req.Clone(req.Context())
+	retry.Header.Set("Authorization", auth)
+	return retry
+}
+
+// parseAuthParams parses the comma separated key=value (or key="value")
+// parameters of a WWW-Authenticate challenge. Keys are lowercased.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				val, s = s, ""
+			} else {
+				val, s = s[:end], s[end:]
+			}
+		}
+		params[key] = strings.TrimSpace(val)
+	}
+	return params
+}
+
+// maskedRequest describes req for logging, with credentials masked
+func maskedRequest(req *http.Request) string {
+	return fmt.Sprintf("%v %v %v", req.Method, req.URL.Redacted(), maskedHeader(req.Header))
+}
+
+// maskedHeader returns a copy of header with Authorization values masked
+func maskedHeader(header http.Header) http.Header {
+	masked := http.Header{}
+	for k, v := range header {
+		if strings.EqualFold(k, "Authorization") {
+			vs := make([]string, len(v))
+			for i := range v {
+				vs[i] = maskAuthorization(v[i])
+			}
+			masked[k] = vs
+		} else {
+			masked[k] = v
+		}
+	}
+	return masked
+}
+
+// maskAuthorization keeps the scheme of an Authorization header value and
+// masks the credentials
+func maskAuthorization(v string) string {
+	if i := strings.Index(v, " "); i > 0 {
+		return v[:i] + " ****"
+	}
+	return "****"
+}
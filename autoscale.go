@@ -0,0 +1,155 @@
+package walker
+
+import (
+	"sync"
+	"time"
+
+	"code.google.com/p/log4go"
+)
+
+// fetcherAutoscaler scales the number of fetchers a FetchManager runs between
+// fetcher.min_simultaneous_fetchers and fetcher.max_simultaneous_fetchers,
+// according to how fast the Datastore calls made by the fetchers are, and
+// how many of them fail.
+type fetcherAutoscaler struct {
+	interval      time.Duration
+	targetLatency time.Duration
+	maxErrorRate  float64
+	min, max      int
+
+	// Datastore calls timed since the last adjustment (see observe)
+	mu      sync.Mutex
+	calls   int64
+	latency time.Duration
+
+	// close quit (see stop) to stop the autoscaler; done is closed once it
+	// has stopped
+	quit     chan struct{}
+	quitOnce sync.Once
+	done     chan struct{}
+}
+
+func newFetcherAutoscaler() *fetcherAutoscaler {
+	interval, err := time.ParseDuration(Config.Fetcher.AutoscaleInterval)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	targetLatency, err := time.ParseDuration(Config.Fetcher.AutoscaleTargetLatency)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	return &fetcherAutoscaler{
+		interval:      interval,
+		targetLatency: targetLatency,
+		maxErrorRate:  Config.Fetcher.AutoscaleMaxErrorRate,
+		min:           Config.Fetcher.MinSimultaneousFetchers,
+		max:           Config.Fetcher.MaxSimultaneousFetchers,
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// observe records a Datastore call that took d. It does nothing on a nil
+// autoscaler, so it can be called whether or not autoscaling is enabled.
+func (a *fetcherAutoscaler) observe(d time.Duration) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.calls++
+	a.latency += d
+	a.mu.Unlock()
+}
+
+// reset returns the number of calls observed since the last reset, and their
+// mean latency
+func (a *fetcherAutoscaler) reset() (calls int64, mean time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	calls = a.calls
+	if calls > 0 {
+		mean = a.latency / time.Duration(calls)
+	}
+	a.calls = 0
+	a.latency = 0
+	return
+}
+
+// target returns how many fetchers should run instead of current, given the
+// number of Datastore calls made since the last adjustment, how many failed,
+// and their mean latency. It moves by a quarter of current (at least one
+// fetcher) at a time.
+func (a *fetcherAutoscaler) target(current int, calls int64, errors int64, mean time.Duration) int {
+	step := current / 4
+	if step < 1 {
+		step = 1
+	}
+	n := current
+	switch {
+	case errors > 0 && (calls == 0 || float64(errors)/float64(calls) > a.maxErrorRate):
+		n = current - step
+	case calls == 0:
+		// Nothing to go by
+	case mean > a.targetLatency:
+		n = current - step
+	case mean < a.targetLatency/2:
+		n = current + step
+	}
+	if n < a.min {
+		n = a.min
+	}
+	if n > a.max {
+		n = a.max
+	}
+	return n
+}
+
+// run adjusts the number of fetchers of fm every interval until the
+// autoscaler is stopped or fm's context is done
+func (a *fetcherAutoscaler) run(fm *FetchManager) {
+	defer close(a.done)
+
+	counter, _ := fm.Datastore.(ErrorCountingDatastore)
+	var lastErrors int64
+	if counter != nil {
+		lastErrors = counter.ErrorCount()
+	}
+	for {
+		select {
+		case <-a.quit:
+			return
+		case <-fm.ctx.Done():
+			return
+		case <-time.After(a.interval):
+		}
+
+		calls, mean := a.reset()
+		var errors int64
+		if counter != nil {
+			count := counter.ErrorCount()
+			errors = count - lastErrors
+			lastErrors = count
+		}
+		current := len(fm.fetchers())
+		n := a.target(current, calls, errors, mean)
+		if n == current {
+			continue
+		}
+		log4go.Info("Scaling fetchers from %d to %d (%d datastore calls averaging %v, %d errors)",
+			current, n, calls, mean, errors)
+		for ; current < n; current++ {
+			fm.startFetcher()
+		}
+		if current > n {
+			fm.stopFetchers(current - n)
+		}
+	}
+}
+
+// stop stops the autoscaler, and waits until it has stopped
+func (a *fetcherAutoscaler) stop() {
+	a.quitOnce.Do(func() {
+		close(a.quit)
+	})
+	<-a.done
+}
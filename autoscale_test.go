@@ -0,0 +1,40 @@
+package walker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoscalerTarget(t *testing.T) {
+	a := &fetcherAutoscaler{
+		targetLatency: 100 * time.Millisecond,
+		maxErrorRate:  0.1,
+		min:           2,
+		max:           20,
+	}
+	tests := []struct {
+		tag     string
+		current int
+		calls   int64
+		errors  int64
+		mean    time.Duration
+		expect  int
+	}{
+		{"no calls", 10, 0, 0, 0, 10},
+		{"fast", 10, 100, 0, 10 * time.Millisecond, 12},
+		{"fast, few fetchers", 3, 100, 0, 10 * time.Millisecond, 4},
+		{"fast, at max", 20, 100, 0, 10 * time.Millisecond, 20},
+		{"near target", 10, 100, 0, 80 * time.Millisecond, 10},
+		{"slow", 10, 100, 0, 200 * time.Millisecond, 8},
+		{"slow, at min", 2, 100, 0, 200 * time.Millisecond, 2},
+		{"some errors", 10, 100, 5, 10 * time.Millisecond, 12},
+		{"many errors", 10, 100, 20, 10 * time.Millisecond, 8},
+		{"errors without calls", 10, 0, 1, 0, 8},
+	}
+	for _, test := range tests {
+		got := a.target(test.current, test.calls, test.errors, test.mean)
+		if got != test.expect {
+			t.Errorf("%s: expected %d fetchers, got %d", test.tag, test.expect, got)
+		}
+	}
+}
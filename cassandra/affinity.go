@@ -0,0 +1,98 @@
+package cassandra
+
+import (
+	"context"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// recentHostsBatch caps how many recently unclaimed domains claimRecentHosts
+// looks up (in a single query) per claim.
+const recentHostsBatch = 100
+
+// claimRecentHosts claims (up to limit of) the domains this crawler unclaimed
+// within cassandra.host_affinity_window that have been dispatched again, so
+// the fetcher can reuse what it cached about them. Must be called with ds.mu
+// held.
+func (ds *Datastore) claimRecentHosts(ctx context.Context, limit int) []string {
+	var candidates []string
+	for dom, unclaimed := range ds.recentHosts {
+		if time.Since(unclaimed) > ds.affinityWindow {
+			delete(ds.recentHosts, dom)
+			continue
+		}
+		if len(candidates) < recentHostsBatch {
+			candidates = append(candidates, dom)
+		}
+	}
+	if len(candidates) == 0 || limit <= 0 {
+		return nil
+	}
+
+	var claimable []string
+	var dom, crawlWindow, crawlTimezone string
+	var dispatched, paused bool
+	var claimTok gocql.UUID
+	itr := ds.read(`SELECT dom, dispatched, paused, claim_tok, crawl_window, crawl_timezone
+					FROM domain_info WHERE dom IN ?`,
+		candidates).WithContext(ctx).Iter()
+	for itr.Scan(&dom, &dispatched, &paused, &claimTok, &crawlWindow, &crawlTimezone) {
+		if dispatched && !paused && claimTok == (gocql.UUID{}) &&
+			!crawlWindowClosed(dom, crawlWindow, crawlTimezone, time.Now()) {
+			claimable = append(claimable, dom)
+		}
+	}
+	if err := itr.Close(); err != nil {
+		log4go.Error("Failed to read domain_info for recent hosts: %v", err)
+		return nil
+	}
+
+	var domains []string
+	for _, dom := range claimable {
+		if len(domains) >= limit {
+			break
+		}
+		casMap := map[string]interface{}{}
+		applied, err := ds.db.Query(claimQuery, ds.crawlerUUID, time.Now(), dom).WithContext(ctx).MapScanCAS(casMap)
+		if err != nil {
+			log4go.Error("Failed to claim segment %v: %v", dom, err)
+			continue
+		}
+		delete(ds.recentHosts, dom)
+		if applied {
+			log4go.Fine("Reclaimed recently crawled segment %v with token %v", dom, ds.crawlerUUID)
+			domains = append(domains, dom)
+		}
+	}
+	return domains
+}
+
+// leftToOtherCrawler returns true if a dispatched domain, last crawled by the
+// crawler with affinityTok and dispatched at lastDispatch, should be left for
+// that crawler to reclaim (see cassandra.host_affinity_grace). active caches
+// which crawlers are in active_fetchers for the current claim cycle.
+func (ds *Datastore) leftToOtherCrawler(ctx context.Context, affinityTok gocql.UUID, lastDispatch time.Time,
+	active map[gocql.UUID]bool) bool {
+
+	if !walker.Config.Cassandra.HostAffinity || affinityTok == (gocql.UUID{}) || affinityTok == ds.crawlerUUID {
+		return false
+	}
+	if time.Since(lastDispatch) >= ds.affinityGrace {
+		return false
+	}
+
+	alive, ok := active[affinityTok]
+	if !ok {
+		var count int
+		err := ds.read(`SELECT COUNT(*) FROM active_fetchers WHERE tok = ?`, affinityTok).WithContext(ctx).Scan(&count)
+		if err != nil {
+			log4go.Error("Failed to check if fetcher %v is active: %v", affinityTok, err)
+		}
+		alive = count > 0
+		active[affinityTok] = alive
+	}
+	return alive
+}
@@ -0,0 +1,69 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestHostAffinity(t *testing.T) {
+	origAffinity := walker.Config.Cassandra.HostAffinity
+	defer func() {
+		walker.Config.Cassandra.HostAffinity = origAffinity
+	}()
+	walker.Config.Cassandra.HostAffinity = true
+
+	ctx := context.Background()
+	db := GetTestDB()
+	ds1 := getDS(t)
+	defer ds1.Close()
+	ds2 := getDS(t)
+	defer ds2.Close()
+	for _, ds := range []*Datastore{ds1, ds2} {
+		if err := ds.KeepAlive(ctx); err != nil {
+			t.Fatalf("KeepAlive failed: %v", err)
+		}
+	}
+
+	dispatch := func(dom string) {
+		err := db.Query(`INSERT INTO domain_info (dom, priority, claim_tok, dispatched, last_dispatch)
+						 VALUES (?, ?, 00000000-0000-0000-0000-000000000000, true, ?)`, dom, MaxPriority, time.Now()).Exec()
+		if err != nil {
+			t.Fatalf("Failed to dispatch %v: %v", dom, err)
+		}
+	}
+
+	dispatch("sticky.com")
+	if host := ds1.ClaimNewHost(ctx); host != "sticky.com" {
+		t.Fatalf("Expected ds1 to claim sticky.com, got %q", host)
+	}
+	ds1.UnclaimHost(ctx, "sticky.com")
+
+	// ds2 should leave sticky.com to ds1 while it is in its grace period
+	dispatch("sticky.com")
+	dispatch("other.com")
+	if host := ds2.ClaimNewHost(ctx); host != "other.com" {
+		t.Errorf("Expected ds2 to claim other.com, got %q", host)
+	}
+	if host := ds2.ClaimNewHost(ctx); host != "" {
+		t.Errorf("Expected ds2 to leave sticky.com to ds1, got %q", host)
+	}
+	if host := ds1.ClaimNewHost(ctx); host != "sticky.com" {
+		t.Errorf("Expected ds1 to reclaim sticky.com, got %q", host)
+	}
+	ds1.UnclaimHost(ctx, "sticky.com")
+
+	// Once ds1 disappears, ds2 should not wait for it
+	dispatch("sticky.com")
+	err := db.Query(`DELETE FROM active_fetchers WHERE tok = ?`, ds1.crawlerUUID).Exec()
+	if err != nil {
+		t.Fatalf("Failed to remove ds1 from active_fetchers: %v", err)
+	}
+	if host := ds2.ClaimNewHost(ctx); host != "sticky.com" {
+		t.Errorf("Expected ds2 to claim sticky.com after ds1 disappeared, got %q", host)
+	}
+}
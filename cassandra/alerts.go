@@ -0,0 +1,93 @@
+package cassandra
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// Names of the alert conditions the dispatcher checks (see the alerts section
+// of walker.yaml)
+const (
+	AlertDomainErrorRate = "domain_error_rate"
+	AlertEmptyDispatch   = "empty_dispatch"
+	AlertFetcherLost     = "fetcher_token_lost"
+	AlertWriteFailures   = "write_failures"
+	AlertRobotsChanged   = "robots_changed"
+)
+
+// checkDomainErrorRate raises an alert if too many of the links the generator
+// just saw fetched within alerts.domain_error_rate_window failed
+func (d *Dispatcher) checkDomainErrorRate(sg *SegmentGenerator) {
+	threshold := walker.Config.Alerts.DomainErrorRate
+	if threshold <= 0 || sg.recentFetches == 0 ||
+		sg.recentFetches < walker.Config.Alerts.DomainErrorRateMinFetches {
+		return
+	}
+	rate := float64(sg.recentErrors) / float64(sg.recentFetches)
+	if rate > threshold {
+		d.Alerter.Raise(AlertDomainErrorRate, sg.domain,
+			"%d of %d links fetched in the last %v failed (%.0f%%)",
+			sg.recentErrors, sg.recentFetches, sg.errorRateWindow, rate*100)
+	}
+}
+
+// checkIterationAlerts runs the crawl-wide checks at the end of a domain
+// iteration that queued the given number of domains for generation
+func (d *Dispatcher) checkIterationAlerts(queued int) {
+	dispatched := atomic.SwapInt64(&d.dispatchedLinks, 0)
+	if queued > 0 {
+		if dispatched == 0 {
+			d.emptyIterations++
+		} else {
+			d.emptyIterations = 0
+		}
+	}
+	cycles := walker.Config.Alerts.EmptyDispatchCycles
+	if cycles > 0 && d.emptyIterations >= cycles {
+		d.Alerter.Raise(AlertEmptyDispatch, "",
+			"no links were dispatched in the last %d dispatch iterations", d.emptyIterations)
+	}
+
+	// Write stats are process-local, so this only sees the failed writes of
+	// this process, not those of fetchers running elsewhere.
+	failures := CurrentWriteStats().Failures
+	newFailures := failures - d.lastWriteFailures
+	d.lastWriteFailures = failures
+	limit := walker.Config.Alerts.WriteFailures
+	if limit > 0 && newFailures >= int64(limit) {
+		d.Alerter.Raise(AlertWriteFailures, "",
+			"%d cassandra writes failed during the last dispatch iteration", newFailures)
+	}
+}
+
+// fetcherLost is called when a fetcher token claiming domains is found to be
+// missing from active_fetchers
+func (d *Dispatcher) fetcherLost(tok gocql.UUID) {
+	log4go.Info("Fetcher %v is no longer active, releasing its domains", tok)
+	if walker.Config.Alerts.FetcherTokenLost {
+		d.Alerter.Raise(AlertFetcherLost, tok.String(),
+			"fetcher disappeared from active_fetchers without releasing its domains")
+	}
+}
+
+// robotsChanged is called when the fingerprint of the robots.txt of dom is
+// found to have changed from oldFp to fp
+func (ds *Datastore) robotsChanged(dom string, oldFp, fp int64) {
+	var change string
+	switch {
+	case oldFp == 0:
+		change = "robots.txt was added"
+	case fp == 0:
+		change = "robots.txt was removed"
+	default:
+		change = fmt.Sprintf("robots.txt changed (fingerprint %016x, was %016x)", uint64(fp), uint64(oldFp))
+	}
+	log4go.Info("%v: %v", dom, change)
+	if walker.Config.Alerts.RobotsChanged {
+		ds.Alerter.Raise(AlertRobotsChanged, dom, "%v", change)
+	}
+}
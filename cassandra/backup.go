@@ -0,0 +1,214 @@
+package cassandra
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+)
+
+// backupTables describes the tables BackupDomains can write and
+// RestoreDomains can read
+var backupTables = map[string]struct {
+	// Primary key columns
+	keys []string
+
+	// Columns left out of the backup
+	skip []string
+}{
+	// Claims refer to crawlers of the cluster the backup was taken from, and
+	// segments are not backed up, so every domain is restored undispatched
+	"domain_info": {keys: []string{"dom"}, skip: []string{"claim_tok", "dispatched"}},
+	"links":       {keys: []string{"dom", "subdom", "path", "proto", "time"}, skip: []string{"body", "body_z"}},
+}
+
+// backupRow is one line of a backup
+type backupRow struct {
+	Table string                     `json:"table"`
+	Row   map[string]json.RawMessage `json:"row"`
+}
+
+// BackupDomains writes the domain_info table to w as JSON lines, one per
+// row, and the links table as well if withLinks is true (without response
+// bodies). Claims and dispatch state are left out. It returns the number of
+// rows written.
+//
+// Null values and zero values are the same to walker, so a row may be
+// restored with zero values where it had nulls.
+func (ds *Datastore) BackupDomains(ctx context.Context, w io.Writer, withLinks bool) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	count := 0
+	tables := []string{"domain_info"}
+	if withLinks {
+		tables = append(tables, "links")
+	}
+	for _, table := range tables {
+		n, err := ds.backupTable(ctx, enc, table)
+		count += n
+		if err != nil {
+			return count, fmt.Errorf("Failed to back up %v: %v", table, err)
+		}
+		log4go.Info("Backed up %d rows of %v", n, table)
+	}
+	return count, bw.Flush()
+}
+
+// backupTable writes every row of table to enc
+func (ds *Datastore) backupTable(ctx context.Context, enc *json.Encoder, table string) (int, error) {
+	columns, err := ds.tableColumns(ctx, table)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for _, c := range columns {
+		if !containsString(backupTables[table].skip, c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+
+	itr := ds.read(fmt.Sprintf("SELECT %s FROM %s", strings.Join(names, ", "), table)).WithContext(ctx).Iter()
+	count := 0
+	row := map[string]interface{}{}
+	for itr.MapScan(row) {
+		line := backupRow{Table: table, Row: map[string]json.RawMessage{}}
+		for col, v := range row {
+			// A zero time is how gocql reads a null timestamp; writing it back
+			// would not give a null
+			if t, ok := v.(time.Time); ok && t.IsZero() {
+				continue
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				itr.Close()
+				return count, fmt.Errorf("Failed to encode %v of %v: %v", col, table, err)
+			}
+			line.Row[col] = b
+		}
+		if err := enc.Encode(&line); err != nil {
+			itr.Close()
+			return count, err
+		}
+		count++
+		row = map[string]interface{}{}
+	}
+	return count, itr.Close()
+}
+
+// RestoreDomains inserts the rows of a backup written by BackupDomains,
+// returning the number of rows inserted. Columns the backup has that the
+// tables here do not are left out. Restored domains are undispatched and
+// unclaimed, so the dispatcher generates new segments for them, and
+// max_priority is raised to the highest priority restored.
+func (ds *Datastore) RestoreDomains(ctx context.Context, r io.Reader) (int, error) {
+	types := map[string]map[string]gocql.TypeInfo{}
+	warned := map[string]bool{}
+	batch := ds.throttle.batcher(ctx, ds.db)
+	dec := json.NewDecoder(bufio.NewReader(r))
+	count := 0
+	maxPrio := -1
+	for {
+		var line backupRow
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return count, fmt.Errorf("Failed to read backup after %d rows: %v", count, err)
+		}
+
+		spec, ok := backupTables[line.Table]
+		if !ok {
+			return count, fmt.Errorf("Unexpected table %q in backup", line.Table)
+		}
+		colTypes := types[line.Table]
+		if colTypes == nil {
+			columns, err := ds.tableColumns(ctx, line.Table)
+			if err != nil {
+				return count, err
+			}
+			colTypes = map[string]gocql.TypeInfo{}
+			for _, c := range columns {
+				colTypes[c.Name] = c.TypeInfo
+			}
+			types[line.Table] = colTypes
+		}
+		for _, k := range spec.keys {
+			if _, ok := line.Row[k]; !ok {
+				return count, fmt.Errorf("Row %d of the backup (%v) is missing key column %v", count+1, line.Table, k)
+			}
+		}
+
+		var names, marks []string
+		var args []interface{}
+		for col, raw := range line.Row {
+			typ, ok := colTypes[col]
+			if !ok {
+				if !warned[line.Table+"."+col] {
+					log4go.Warn("Not restoring column %v of %v, which is not in the schema", col, line.Table)
+					warned[line.Table+"."+col] = true
+				}
+				continue
+			}
+			v := typ.New()
+			if err := json.Unmarshal(raw, v); err != nil {
+				return count, fmt.Errorf("Failed to decode %v of %v in row %d: %v", col, line.Table, count+1, err)
+			}
+			val := reflect.ValueOf(v).Elem().Interface()
+			if prio, ok := val.(int); ok && line.Table == "domain_info" && col == "priority" && prio > maxPrio {
+				maxPrio = prio
+			}
+			names = append(names, col)
+			marks = append(marks, "?")
+			args = append(args, val)
+		}
+		if line.Table == "domain_info" {
+			names = append(names, "dispatched", "claim_tok")
+			marks = append(marks, "?", "?")
+			args = append(args, false, gocql.UUID{})
+		}
+
+		stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			line.Table, strings.Join(names, ", "), strings.Join(marks, ", "))
+		if err := batch.add(stmt, args...); err != nil {
+			return count, fmt.Errorf("Failed to restore row %d (%v): %v", count+1, line.Table, err)
+		}
+		count++
+		if count%100000 == 0 {
+			log4go.Info("Restored %d rows", count)
+		}
+	}
+	if err := batch.flush(); err != nil {
+		return count, err
+	}
+	if maxPrio >= 0 {
+		ds.raiseMaxPriority(maxPrio)
+	}
+	return count, nil
+}
+
+// tableColumns returns the columns of table
+func (ds *Datastore) tableColumns(ctx context.Context, table string) ([]gocql.ColumnInfo, error) {
+	itr := ds.read(fmt.Sprintf("SELECT * FROM %s LIMIT 1", table)).WithContext(ctx).Iter()
+	columns := itr.Columns()
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("Failed to read the columns of %v: %v", table, err)
+	}
+	return columns, nil
+}
+
+// containsString returns true if list contains s
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+func TestBackupRestoreDomains(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+	ctx := context.Background()
+
+	lastDispatch := time.Now().Truncate(time.Millisecond).UTC()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority, excluded, exclude_reason,
+						sample_percent, byte_budget, crawl_window, last_dispatch)
+						VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		"test.com", gocql.TimeUUID(), true, 3, true, "Manual", float32(0.5), int64(1000), "01:00-05:00",
+		lastDispatch).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	fetchTime := time.Now().Truncate(time.Millisecond).UTC()
+	ds.StoreURLFetchResults(ctx, &walker.FetchResults{
+		URL:       walker.MustParse("http://sub.test.com/page1.html?a=b"),
+		FetchTime: fetchTime,
+		Body:      "<html>body</html>",
+		Response: &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": []string{"text/html"}},
+		},
+	})
+
+	var buf bytes.Buffer
+	n, err := ds.BackupDomains(ctx, &buf, true)
+	if err != nil {
+		t.Fatalf("BackupDomains failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 rows backed up, got %d:\n%v", n, buf.String())
+	}
+	if strings.Contains(buf.String(), "<html>body</html>") {
+		t.Errorf("Expected bodies to be left out of the backup:\n%v", buf.String())
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var row backupRow
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("Backup line is not JSON: %v\n%v", err, line)
+		}
+	}
+
+	// Restore into an empty keyspace
+	db = GetTestDB()
+	n, err = ds.RestoreDomains(ctx, &buf)
+	if err != nil {
+		t.Fatalf("RestoreDomains failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 rows restored, got %d", n)
+	}
+
+	dinfo, err := ds.FindDomain("test.com")
+	if err != nil || dinfo == nil {
+		t.Fatalf("Failed to find restored domain: %v, %v", dinfo, err)
+	}
+	if dinfo.Priority != 3 || !dinfo.Excluded || dinfo.ExcludeReason != "Manual" || dinfo.SamplePercent != 0.5 ||
+		dinfo.ByteBudget != 1000 || dinfo.CrawlWindow != "01:00-05:00" {
+		t.Errorf("Unexpected restored domain: %+v", dinfo)
+	}
+	var claimTok gocql.UUID
+	var dispatched bool
+	var restoredDispatch time.Time
+	err = db.Query(`SELECT claim_tok, dispatched, last_dispatch FROM domain_info WHERE dom = ?`, "test.com").
+		Scan(&claimTok, &dispatched, &restoredDispatch)
+	if err != nil {
+		t.Fatalf("Failed to read restored domain: %v", err)
+	}
+	if dispatched || claimTok != (gocql.UUID{}) {
+		t.Errorf("Expected the domain to be restored undispatched, got dispatched %v claim_tok %v", dispatched, claimTok)
+	}
+	if !restoredDispatch.Equal(lastDispatch) {
+		t.Errorf("Expected last_dispatch %v, got %v", lastDispatch, restoredDispatch)
+	}
+
+	linfo, err := ds.FindLink(walker.MustParse("http://sub.test.com/page1.html?a=b"), true)
+	if err != nil || linfo == nil {
+		t.Fatalf("Failed to find restored link: %v, %v", linfo, err)
+	}
+	if !linfo.CrawlTime.Equal(fetchTime) || linfo.Status != 200 || linfo.Body != "" {
+		t.Errorf("Unexpected restored link: %+v", linfo)
+	}
+
+	_, err = ds.RestoreDomains(ctx, strings.NewReader(`{"table":"segments","row":{}}`))
+	if err == nil {
+		t.Errorf("Expected an error restoring an unknown table")
+	}
+	_, err = ds.RestoreDomains(ctx, strings.NewReader(`{"table":"links","row":{"dom":"a.com"}}`))
+	if err == nil {
+		t.Errorf("Expected an error restoring a row without its key")
+	}
+}
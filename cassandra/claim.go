@@ -0,0 +1,206 @@
+package cassandra
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+)
+
+// claimBucket holds the queued domains of one priority, oldest first
+type claimBucket struct {
+	priority int
+	entries  []claimEntry
+}
+
+// claimEntry is a row of claim_queue
+type claimEntry struct {
+	priority int
+	queued   time.Time
+	dom      string
+}
+
+// claimRand picks the buckets to claim from; it is only used with
+// Datastore.mu held
+var claimRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// tryClaimHostsWeighted claims up to limit domains from claim_queue (see
+// cassandra.claim_strategy in walker.yaml), choosing each from a priority
+// bucket picked at random, weighted by priority. It falls back to
+// tryClaimHosts if nothing is queued. Returns retry if the caller should
+// re-call the method.
+func (ds *Datastore) tryClaimHostsWeighted(ctx context.Context, limit int) (domains []string, retry bool) {
+	buckets, err := ds.readClaimQueue(ctx, limit)
+	if err != nil {
+		log4go.Error("Failed to read claim_queue: %v", err)
+		return
+	}
+	if len(buckets) == 0 {
+		// Ex. the dispatcher has not queued any domains since claim_strategy
+		// was switched to weighted
+		return ds.tryClaimHosts(ctx, limit)
+	}
+
+	active := map[gocql.UUID]bool{}
+	start := time.Now()
+	trumpedClaim := 0
+	for len(domains) < limit && len(buckets) > 0 {
+		i := pickBucket(buckets, claimRand)
+		e := buckets[i].entries[0]
+		buckets[i].entries = buckets[i].entries[1:]
+		if len(buckets[i].entries) == 0 {
+			buckets = append(buckets[:i], buckets[i+1:]...)
+		}
+
+		var paused bool
+		var affinityTok gocql.UUID
+		var lastDispatch time.Time
+		var crawlWindow, crawlTimezone string
+		err := ds.read(`SELECT paused, affinity_tok, last_dispatch, crawl_window, crawl_timezone
+						FROM domain_info WHERE dom = ?`,
+			e.dom).WithContext(ctx).Scan(&paused, &affinityTok, &lastDispatch, &crawlWindow, &crawlTimezone)
+		if err == gocql.ErrNotFound {
+			ds.dequeueClaim(ctx, e)
+			continue
+		} else if err != nil {
+			log4go.Error("Failed to read domain_info for %v: %v", e.dom, err)
+			continue
+		} else if paused {
+			// Drop it so it doesn't sit at the head of its bucket; ResumeDomain
+			// queues it again
+			ds.dequeueClaim(ctx, e)
+			continue
+		} else if ds.leftToOtherCrawler(ctx, affinityTok, lastDispatch, active) {
+			// Leave it queued for the crawler it has affinity with
+			continue
+		} else if crawlWindowClosed(e.dom, crawlWindow, crawlTimezone, time.Now()) {
+			// Leave it queued until its crawl window opens again
+			continue
+		}
+
+		casMap := map[string]interface{}{}
+		applied, err := ds.db.Query(claimQuery, ds.crawlerUUID, time.Now(), e.dom).WithContext(ctx).MapScanCAS(casMap)
+		if err != nil {
+			log4go.Error("Failed to claim segment %v: %v", e.dom, err)
+			continue
+		}
+		// Either we claimed it, or it was already claimed (or is no longer
+		// dispatched) and the entry is stale; the dispatcher queues the domain
+		// again when it next dispatches it
+		ds.dequeueClaim(ctx, e)
+		if !applied {
+			trumpedClaim++
+			log4go.Fine("Domain %v was claimed by another crawler before resolution", e.dom)
+			continue
+		}
+		domains = append(domains, e.dom)
+		log4go.Fine("Claimed segment %v (priority %d) with token %v in %v",
+			e.dom, e.priority, ds.crawlerUUID, time.Since(start))
+		start = time.Now()
+	}
+
+	if trumpedClaim >= limit {
+		log4go.Fine("tryClaimHostsWeighted requesting retry with trumpedClaim = %d, and limit = %d", trumpedClaim, limit)
+		retry = true
+	}
+	return
+}
+
+// readClaimQueue returns the oldest (up to limit) queued domains of each
+// priority above 0
+func (ds *Datastore) readClaimQueue(ctx context.Context, limit int) ([]claimBucket, error) {
+	var priorities []int
+	iter := ds.read(`SELECT DISTINCT priority FROM claim_queue`).WithContext(ctx).Iter()
+	var priority int
+	for iter.Scan(&priority) {
+		if priority > 0 {
+			priorities = append(priorities, priority)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	var buckets []claimBucket
+	for _, priority := range priorities {
+		b := claimBucket{priority: priority}
+		iter := ds.read(`SELECT queued, dom FROM claim_queue WHERE priority = ? LIMIT ?`,
+			priority, limit).WithContext(ctx).Iter()
+		e := claimEntry{priority: priority}
+		for iter.Scan(&e.queued, &e.dom) {
+			b.entries = append(b.entries, e)
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		if len(b.entries) > 0 {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets, nil
+}
+
+// dequeueClaim removes e from claim_queue
+func (ds *Datastore) dequeueClaim(ctx context.Context, e claimEntry) {
+	err := ds.db.Query(`DELETE FROM claim_queue WHERE priority = ? AND queued = ? AND dom = ?`,
+		e.priority, e.queued, e.dom).WithContext(ctx).Exec()
+	if err != nil {
+		log4go.Error("Failed to remove %v from claim_queue: %v", e.dom, err)
+	}
+}
+
+// pickBucket returns the index of a bucket chosen at random, each bucket
+// weighted by its priority
+func pickBucket(buckets []claimBucket, rnd *rand.Rand) int {
+	total := 0
+	for _, b := range buckets {
+		total += b.priority
+	}
+	r := rnd.Intn(total)
+	for i, b := range buckets {
+		r -= b.priority
+		if r < 0 {
+			return i
+		}
+	}
+	return len(buckets) - 1
+}
+
+// requeueForClaim adds domain back to claim_queue, where it was queued when
+// last dispatched, if it is still dispatched and unclaimed. Used when a domain
+// is resumed, since tryClaimHostsWeighted drops paused domains from the queue.
+func (ds *Datastore) requeueForClaim(domain string) error {
+	var dispatched bool
+	var claimTok gocql.UUID
+	var priority int
+	var lastDispatch time.Time
+	err := ds.read(`SELECT dispatched, claim_tok, priority, last_dispatch FROM domain_info WHERE dom = ?`,
+		domain).Scan(&dispatched, &claimTok, &priority, &lastDispatch)
+	if err == gocql.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !dispatched || claimTok != (gocql.UUID{}) {
+		return nil
+	}
+	if lastDispatch.IsZero() {
+		lastDispatch = time.Now()
+	}
+	return ds.db.Query(`INSERT INTO claim_queue (priority, queued, dom) VALUES (?, ?, ?)`,
+		priority, lastDispatch, domain).Exec()
+}
+
+// queueForClaim adds the domain to claim_queue under its current priority,
+// for fetchers using the weighted claim strategy
+func (sg *SegmentGenerator) queueForClaim(queued time.Time) error {
+	var priority int
+	err := sg.DB.Query(`SELECT priority FROM domain_info WHERE dom = ?`, sg.domain).Scan(&priority)
+	if err != nil {
+		return err
+	}
+	return sg.DB.Query(`INSERT INTO claim_queue (priority, queued, dom) VALUES (?, ?, ?)`,
+		priority, queued, sg.domain).Exec()
+}
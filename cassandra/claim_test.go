@@ -0,0 +1,221 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestPickBucket(t *testing.T) {
+	buckets := []claimBucket{{priority: 1}, {priority: 9}}
+	rnd := rand.New(rand.NewSource(1))
+	counts := make([]int, len(buckets))
+	for i := 0; i < 10000; i++ {
+		counts[pickBucket(buckets, rnd)]++
+	}
+	ratio := float64(counts[1]) / float64(counts[0])
+	if ratio < 7 || ratio > 11 {
+		t.Errorf("Expected priority 9 to be picked about 9 times as often as priority 1, got %v", counts)
+	}
+}
+
+func TestClaimHostsWeighted(t *testing.T) {
+	origStrategy := walker.Config.Cassandra.ClaimStrategy
+	defer func() {
+		walker.Config.Cassandra.ClaimStrategy = origStrategy
+	}()
+	walker.Config.Cassandra.ClaimStrategy = "weighted"
+
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	insertDomain := `INSERT INTO domain_info (dom, priority, claim_tok, dispatched, paused)
+					 VALUES (?, ?, 00000000-0000-0000-0000-000000000000, true, ?)`
+	queue := `INSERT INTO claim_queue (priority, queued, dom) VALUES (?, ?, ?)`
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		for _, prio := range []int{1, 9} {
+			dom := fmt.Sprintf("p%d-%d.com", prio, i)
+			if err := db.Query(insertDomain, dom, prio, false).Exec(); err != nil {
+				t.Fatalf("Failed to insert domain: %v", err)
+			}
+			if err := db.Query(queue, prio, start.Add(time.Duration(i)*time.Minute), dom).Exec(); err != nil {
+				t.Fatalf("Failed to queue domain: %v", err)
+			}
+		}
+	}
+
+	// Paused domains and entries for domains already claimed are dropped, and
+	// priority 0 domains are never claimed
+	if err := db.Query(insertDomain, "paused.com", 5, true).Exec(); err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	if err := db.Query(insertDomain, "zero.com", 0, false).Exec(); err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	err := db.Query(`INSERT INTO domain_info (dom, priority, claim_tok, dispatched)
+					 VALUES ('claimed.com', 5, ?, true)`, ds.crawlerUUID).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	for _, dom := range []string{"paused.com", "claimed.com"} {
+		if err := db.Query(queue, 5, start, dom).Exec(); err != nil {
+			t.Fatalf("Failed to queue domain: %v", err)
+		}
+	}
+	if err := db.Query(queue, 0, start, "zero.com").Exec(); err != nil {
+		t.Fatalf("Failed to queue domain: %v", err)
+	}
+
+	var claimed []string
+	for {
+		host := ds.ClaimNewHost(context.Background())
+		if host == "" {
+			break
+		}
+		claimed = append(claimed, host)
+	}
+
+	if len(claimed) != 10 {
+		t.Fatalf("Expected the 10 p*.com domains to be claimed, got %v", claimed)
+	}
+	next := map[string]int{"p1": 0, "p9": 0}
+	for _, dom := range claimed {
+		parts := strings.SplitN(strings.TrimSuffix(dom, ".com"), "-", 2)
+		if len(parts) != 2 {
+			t.Fatalf("Unexpected domain claimed: %v", dom)
+		}
+		// Each priority's domains are claimed in the order they were queued
+		if parts[1] != fmt.Sprint(next[parts[0]]) {
+			t.Errorf("Expected %v-%d.com to be claimed next, got %v", parts[0], next[parts[0]], dom)
+		}
+		next[parts[0]]++
+	}
+
+	var queued []string
+	iter := db.Query(`SELECT dom FROM claim_queue`).Iter()
+	var dom string
+	for iter.Scan(&dom) {
+		queued = append(queued, dom)
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatalf("Failed to read claim_queue: %v", err)
+	}
+	if len(queued) != 1 || queued[0] != "zero.com" {
+		t.Errorf("Expected only zero.com left in claim_queue, got %v", queued)
+	}
+
+	// Resuming the paused domain queues it again
+	if err := ds.ResumeDomain("paused.com"); err != nil {
+		t.Fatalf("ResumeDomain failed: %v", err)
+	}
+	if host := ds.ClaimNewHost(context.Background()); host != "paused.com" {
+		t.Errorf("Expected to claim resumed domain paused.com, got %q", host)
+	}
+}
+
+func TestClaimHostsWeightedFallback(t *testing.T) {
+	origStrategy := walker.Config.Cassandra.ClaimStrategy
+	defer func() {
+		walker.Config.Cassandra.ClaimStrategy = origStrategy
+	}()
+	walker.Config.Cassandra.ClaimStrategy = "weighted"
+
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	// Dispatched before the switch to weighted, so never queued
+	err := db.Query(`INSERT INTO domain_info (dom, priority, claim_tok, dispatched)
+					 VALUES ('old.com', ?, 00000000-0000-0000-0000-000000000000, true)`, MaxPriority).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	if host := ds.ClaimNewHost(context.Background()); host != "old.com" {
+		t.Errorf("Expected old.com to be claimed in token order, got %q", host)
+	}
+}
+
+func TestClaimSkipsClosedCrawlWindow(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	// Open for an hour two days ago, so closed now
+	day := time.Now().UTC().AddDate(0, 0, -2).Weekday().String()[:3]
+	err := db.Query(`INSERT INTO domain_info (dom, priority, claim_tok, dispatched, crawl_window)
+					 VALUES ('closed.com', ?, 00000000-0000-0000-0000-000000000000, true, ?)`,
+		MaxPriority, day+" 01:00-02:00").Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	if host := ds.ClaimNewHost(context.Background()); host != "" {
+		t.Errorf("Expected no domain to be claimed outside its crawl window, got %q", host)
+	}
+
+	err = db.Query(`UPDATE domain_info SET crawl_window = '00:00-24:00' WHERE dom = 'closed.com'`).Exec()
+	if err != nil {
+		t.Fatalf("Failed to update crawl_window: %v", err)
+	}
+	if host := ds.ClaimNewHost(context.Background()); host != "closed.com" {
+		t.Errorf("Expected closed.com to be claimed once its crawl window opened, got %q", host)
+	}
+}
+
+func TestClaimHost(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+	ctx := context.Background()
+
+	err := db.Query(`INSERT INTO domain_info (dom, priority, claim_tok, dispatched)
+					 VALUES ('listed.com', ?, 00000000-0000-0000-0000-000000000000, true)`, MaxPriority).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	if !ds.ClaimHost(ctx, "listed.com") {
+		t.Fatalf("Expected to claim listed.com")
+	}
+	if host := ds.ClaimNewHost(ctx); host != "" {
+		t.Errorf("Expected no domain to be claimable while listed.com is claimed, got %q", host)
+	}
+	other := getDS(t)
+	defer other.Close()
+	if other.ClaimHost(ctx, "listed.com") {
+		t.Errorf("Expected listed.com not to be claimable by another crawler")
+	}
+	if !ds.ClaimHost(ctx, "unknown.com") {
+		t.Errorf("Expected a domain missing from domain_info to be claimable")
+	}
+
+	ds.ReleaseHost(ctx, "listed.com")
+	ds.ReleaseHost(ctx, "unknown.com")
+	var count int
+	if err := db.Query(`SELECT COUNT(*) FROM domain_info WHERE dom = 'unknown.com'`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count domain_info: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected ReleaseHost not to add unknown.com to domain_info")
+	}
+	if host := other.ClaimNewHost(ctx); host != "listed.com" {
+		t.Errorf("Expected the released listed.com to be claimed with its segment, got %q", host)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package cassandra
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+)
+
+// claimsAuditBucket is the width of the partitions of claims_audit
+const claimsAuditBucket = 24 * time.Hour
+
+// StrandedClaimStats counts the claims this process's dispatcher found
+// stranded by fetchers that stopped without releasing them
+type StrandedClaimStats struct {
+	// Number of fetcher tokens whose domains were released
+	Fetchers int64
+
+	// Number of domains released
+	Domains int64
+
+	// Number of links in the segments of the released domains, which are
+	// thrown back to be dispatched again
+	Links int64
+}
+
+// String formats StrandedClaimStats for logging
+func (s StrandedClaimStats) String() string {
+	return fmt.Sprintf("%d domains (%d links) released from %d lost fetchers", s.Domains, s.Links, s.Fetchers)
+}
+
+var strandedClaimStats struct {
+	fetchers int64
+	domains  int64
+	links    int64
+}
+
+// CurrentStrandedClaimStats returns the stranded claim counters accumulated
+// since this process started
+func CurrentStrandedClaimStats() StrandedClaimStats {
+	return StrandedClaimStats{
+		Fetchers: atomic.LoadInt64(&strandedClaimStats.fetchers),
+		Domains:  atomic.LoadInt64(&strandedClaimStats.domains),
+		Links:    atomic.LoadInt64(&strandedClaimStats.links),
+	}
+}
+
+// auditStrandedClaim records that domain, claimed by tok at claimTime, was
+// released with the given number of links in its segment
+func (d *Dispatcher) auditStrandedClaim(domain string, tok gocql.UUID, claimTime time.Time, links int) {
+	atomic.AddInt64(&strandedClaimStats.domains, 1)
+	atomic.AddInt64(&strandedClaimStats.links, int64(links))
+
+	now := time.Now()
+	var age time.Duration
+	if !claimTime.IsZero() {
+		age = now.Sub(claimTime)
+	}
+	log4go.Info("Released domain %v from lost fetcher %v (claimed %v ago, %d links in its segment)",
+		domain, tok, age, links)
+	err := d.db.Query(`INSERT INTO claims_audit (day, time, dom, claim_tok, claim_age, links)
+						VALUES (?, ?, ?, ?, ?, ?)`,
+		now.Truncate(claimsAuditBucket), now, domain, tok, int64(age/time.Second), links).Exec()
+	if err != nil {
+		log4go.Error("Failed to insert into claims_audit: %v", err)
+	}
+}
+
+// ListStrandedClaims is documented on the ModelDatastore interface.
+func (ds *Datastore) ListStrandedClaims(since time.Time) ([]*StrandedClaim, error) {
+	var claims []*StrandedClaim
+	now := time.Now()
+	for day := now.Truncate(claimsAuditBucket); !day.Before(since.Truncate(claimsAuditBucket)); day = day.Add(-claimsAuditBucket) {
+		itr := ds.read(`SELECT time, dom, claim_tok, claim_age, links FROM claims_audit
+						WHERE day = ? AND time >= ?`, day, since).Iter()
+		var c StrandedClaim
+		var age int64
+		for itr.Scan(&c.Time, &c.Domain, &c.ClaimToken, &age, &c.Links) {
+			c.ClaimAge = time.Duration(age) * time.Second
+			claim := c
+			claims = append(claims, &claim)
+		}
+		if err := itr.Close(); err != nil {
+			return nil, fmt.Errorf("claims_audit query failed: %v", err)
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,48 @@
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/iParadigms/walker"
+)
+
+// The codecs a compressed body (in the body_z column) can be marked with, in
+// its first byte
+const (
+	bodyCodecSnappy byte = 1
+)
+
+// bodyField returns the links column and value to store body in, compressing
+// it if cassandra.body_compression is set
+func bodyField(body string) dbfield {
+	switch strings.ToLower(walker.Config.Cassandra.BodyCompression) {
+	case "snappy":
+		z := make([]byte, 1, 1+snappy.MaxEncodedLen(len(body)))
+		z[0] = bodyCodecSnappy
+		z = append(z, snappy.Encode(nil, []byte(body))...)
+		return dbfield{"body_z", z}
+	default:
+		return dbfield{"body", body}
+	}
+}
+
+// decodeBody returns the body stored in a row of the links table, from its
+// body and body_z columns; rows stored with and without compression can be
+// mixed
+func decodeBody(body string, z []byte) (string, error) {
+	if len(z) == 0 {
+		return body, nil
+	}
+	switch z[0] {
+	case bodyCodecSnappy:
+		b, err := snappy.Decode(nil, z[1:])
+		if err != nil {
+			return "", fmt.Errorf("Failed to decompress body: %v", err)
+		}
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("Stored body has unknown codec %d", z[0])
+	}
+}
@@ -0,0 +1,87 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestBodyCompressionRoundTrip(t *testing.T) {
+	orig := walker.Config.Cassandra.BodyCompression
+	defer func() { walker.Config.Cassandra.BodyCompression = orig }()
+
+	body := strings.Repeat("<p>compress me</p>", 100)
+	for _, codec := range []string{"none", "snappy"} {
+		walker.Config.Cassandra.BodyCompression = codec
+		f := bodyField(body)
+		var got string
+		var err error
+		switch v := f.value.(type) {
+		case string:
+			if f.name != "body" {
+				t.Errorf("%v: expected an uncompressed body in the body column, got %v", codec, f.name)
+			}
+			got, err = decodeBody(v, nil)
+		case []byte:
+			if len(v) >= len(body) {
+				t.Errorf("%v: expected the body to shrink, got %d bytes", codec, len(v))
+			}
+			got, err = decodeBody("", v)
+		}
+		if err != nil {
+			t.Fatalf("%v: decodeBody failed: %v", codec, err)
+		}
+		if got != body {
+			t.Errorf("%v: body did not survive the round trip", codec)
+		}
+	}
+
+	if _, err := decodeBody("", []byte{99, 1, 2}); err == nil {
+		t.Errorf("Expected a body with an unknown codec to fail to decode")
+	}
+}
+
+func TestMixedCompressedBodies(t *testing.T) {
+	origBody := walker.Config.Cassandra.StoreResponseBody
+	origCompression := walker.Config.Cassandra.BodyCompression
+	defer func() {
+		walker.Config.Cassandra.StoreResponseBody = origBody
+		walker.Config.Cassandra.BodyCompression = origCompression
+	}()
+	walker.Config.Cassandra.StoreResponseBody = true
+
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	bodies := map[string]string{
+		"none":   "<html>stored before compression was turned on</html>",
+		"snappy": "<html>stored compressed</html>",
+	}
+	for codec, body := range bodies {
+		walker.Config.Cassandra.BodyCompression = codec
+		ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:       walker.MustParse("http://test.com/" + codec + ".html"),
+			FetchTime: time.Now(),
+			Response:  &http.Response{StatusCode: 200},
+			Body:      body,
+		})
+	}
+
+	// Whatever the current setting, both bodies read back
+	for codec, body := range bodies {
+		linfo, err := ds.FindLink(walker.MustParse("http://test.com/"+codec+".html"), true)
+		if err != nil {
+			t.Fatalf("FindLink failed: %v", err)
+		}
+		if linfo == nil || linfo.Body != body {
+			t.Errorf("Expected body %q stored with %v to read back, got %+v", body, codec, linfo)
+		}
+	}
+}
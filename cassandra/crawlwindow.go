@@ -0,0 +1,181 @@
+package cassandra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/log4go"
+)
+
+// CrawlWindow is the set of times a domain may be dispatched, parsed from the
+// crawl_window and crawl_timezone columns of domain_info. A nil *CrawlWindow
+// places no restriction on dispatching.
+type CrawlWindow struct {
+	loc    *time.Location
+	ranges []crawlRange
+}
+
+// crawlRange is one entry of a crawl window: the days it starts on, and its
+// start and end in minutes after midnight. If end <= start the range runs
+// past midnight into the following day.
+type crawlRange struct {
+	days       [7]bool
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseCrawlWindow parses a crawl window spec, evaluated in the IANA time
+// zone tz (UTC if tz is empty). The spec is a list of ranges separated by
+// semicolons, each an optional list of days followed by a time range, for
+// example:
+//
+//	01:00-05:00
+//	Mon-Fri 22:00-06:00; Sat,Sun 00:00-24:00
+//
+// A range whose end is before its start runs past midnight. An empty spec
+// returns a nil *CrawlWindow, meaning the domain can be crawled any time.
+func ParseCrawlWindow(spec string, tz string) (*CrawlWindow, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("Bad crawl window time zone %q: %v", tz, err)
+	}
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	w := &CrawlWindow{loc: loc}
+	for _, entry := range strings.Split(spec, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		var r crawlRange
+		switch len(fields) {
+		case 1:
+			for i := range r.days {
+				r.days[i] = true
+			}
+		case 2:
+			if err := parseCrawlDays(fields[0], &r.days); err != nil {
+				return nil, fmt.Errorf("Bad crawl window %q: %v", entry, err)
+			}
+		default:
+			return nil, fmt.Errorf("Bad crawl window %q: expected [days] HH:MM-HH:MM", entry)
+		}
+
+		times := strings.Split(fields[len(fields)-1], "-")
+		if len(times) != 2 {
+			return nil, fmt.Errorf("Bad crawl window %q: expected HH:MM-HH:MM", entry)
+		}
+		if r.start, err = parseCrawlTime(times[0]); err != nil || r.start == 24*60 {
+			return nil, fmt.Errorf("Bad crawl window start %q", times[0])
+		}
+		if r.end, err = parseCrawlTime(times[1]); err != nil {
+			return nil, fmt.Errorf("Bad crawl window end %q", times[1])
+		}
+		if r.start == r.end {
+			return nil, fmt.Errorf("Bad crawl window %q: start and end are the same", entry)
+		}
+		w.ranges = append(w.ranges, r)
+	}
+	if len(w.ranges) == 0 {
+		return nil, fmt.Errorf("Bad crawl window %q: no ranges given", spec)
+	}
+	return w, nil
+}
+
+// parseCrawlDays parses a comma separated list of days and day ranges, like
+// "Mon-Fri" or "Sat,Sun", into days
+func parseCrawlDays(s string, days *[7]bool) error {
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("bad day range %q", part)
+		}
+		first, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return fmt.Errorf("unknown day %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, ok = weekdays[strings.ToLower(bounds[1])]
+			if !ok {
+				return fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseCrawlTime parses HH:MM (00:00 through 24:00) into minutes after
+// midnight
+func parseCrawlTime(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("expected HH:MM, got %q", s)
+	}
+	h, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	if h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("time out of range: %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// crawlWindowClosed returns true if dom has a crawl window (spec, in the time
+// zone tz) and it is not open at t. An invalid window is logged and ignored.
+func crawlWindowClosed(dom, spec, tz string, t time.Time) bool {
+	window, err := ParseCrawlWindow(spec, tz)
+	if err != nil {
+		log4go.Error("Ignoring crawl window of %q: %v", dom, err)
+		return false
+	}
+	return !window.Contains(t)
+}
+
+// Contains returns true if t falls within the window. A nil window contains
+// all times.
+func (w *CrawlWindow) Contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	t = t.In(w.loc)
+	day := t.Weekday()
+	yesterday := (day + 6) % 7
+	minute := t.Hour()*60 + t.Minute()
+	for _, r := range w.ranges {
+		if r.end > r.start {
+			if r.days[day] && minute >= r.start && minute < r.end {
+				return true
+			}
+			continue
+		}
+		if (r.days[day] && minute >= r.start) || (r.days[yesterday] && minute < r.end) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCrawlWindowContains(t *testing.T) {
+	tests := []struct {
+		spec, tz string
+		at       string // RFC3339
+		expected bool
+	}{
+		{"01:00-05:00", "", "2014-05-05T00:59:00Z", false},
+		{"01:00-05:00", "", "2014-05-05T01:00:00Z", true},
+		{"01:00-05:00", "", "2014-05-05T04:59:00Z", true},
+		{"01:00-05:00", "", "2014-05-05T05:00:00Z", false},
+
+		// Evaluated in the domain's time zone: 06:00 UTC is 02:00 EDT
+		{"01:00-05:00", "America/New_York", "2014-05-05T06:00:00Z", true},
+		{"01:00-05:00", "America/New_York", "2014-05-05T02:00:00Z", false},
+
+		// 2014-05-05 is a Monday
+		{"Mon-Fri 01:00-05:00", "", "2014-05-05T02:00:00Z", true},
+		{"Mon-Fri 01:00-05:00", "", "2014-05-04T02:00:00Z", false},
+		{"Mon-Fri 01:00-05:00; Sat,Sun 00:00-24:00", "", "2014-05-04T12:00:00Z", true},
+		{"Fri-Mon 01:00-05:00", "", "2014-05-04T02:00:00Z", true},
+		{"Fri-Mon 01:00-05:00", "", "2014-05-06T02:00:00Z", false},
+
+		// Past midnight: a Friday night window runs into Saturday morning
+		{"Fri 22:00-06:00", "", "2014-05-09T23:00:00Z", true},
+		{"Fri 22:00-06:00", "", "2014-05-10T05:00:00Z", true},
+		{"Fri 22:00-06:00", "", "2014-05-10T23:00:00Z", false},
+		{"Fri 22:00-06:00", "", "2014-05-09T05:00:00Z", false},
+	}
+	for _, tst := range tests {
+		w, err := ParseCrawlWindow(tst.spec, tst.tz)
+		if err != nil {
+			t.Errorf("ParseCrawlWindow(%q, %q) failed: %v", tst.spec, tst.tz, err)
+			continue
+		}
+		at, _ := time.Parse(time.RFC3339, tst.at)
+		if got := w.Contains(at); got != tst.expected {
+			t.Errorf("%q (%q) contains %v = %v, expected %v", tst.spec, tst.tz, tst.at, got, tst.expected)
+		}
+	}
+}
+
+func TestParseCrawlWindowErrors(t *testing.T) {
+	w, err := ParseCrawlWindow("", "")
+	if w != nil || err != nil {
+		t.Errorf("Expected an empty window to be nil, got %v, %v", w, err)
+	}
+	if !w.Contains(time.Now()) {
+		t.Errorf("Expected a nil window to contain all times")
+	}
+
+	bad := []struct{ spec, tz string }{
+		{"01:00-05:00", "Mars/Olympus_Mons"},
+		{"sometimes", ""},
+		{"01:00", ""},
+		{"01:00-01:00", ""},
+		{"24:00-05:00", ""},
+		{"01:00-25:00", ""},
+		{"01:60-05:00", ""},
+		{"1:0-05:00", ""},
+		{"Someday 01:00-05:00", ""},
+		{"Mon-Tue-Wed 01:00-05:00", ""},
+		{"Mon 01:00-05:00 extra", ""},
+		{";", ""},
+	}
+	for _, tst := range bad {
+		if _, err := ParseCrawlWindow(tst.spec, tst.tz); err == nil {
+			t.Errorf("Expected ParseCrawlWindow(%q, %q) to fail", tst.spec, tst.tz)
+		}
+	}
+}
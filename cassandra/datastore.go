@@ -2,9 +2,16 @@ package cassandra
 
 import (
 	"bytes"
+	"context"
+	"encoding/base32"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"io/ioutil"
 	"net/http"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +21,8 @@ import (
 	"github.com/iParadigms/walker"
 
 	lru "github.com/hashicorp/golang-lru"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Datastore is the primary walker Datastore implementation, using Apache
@@ -25,6 +34,11 @@ type Datastore struct {
 	cf *gocql.ClusterConfig
 	db *gocql.Session
 
+	// Per operation class query settings (see read and counterUpdate)
+	readConsistency    gocql.Consistency
+	counterConsistency gocql.Consistency
+	speculative        gocql.SpeculativeExecutionPolicy
+
 	// A group of domains that this datastore has already claimed, ready to
 	// pass to a fetcher
 	domains []string
@@ -37,6 +51,12 @@ type Datastore struct {
 	// This is a unique UUID for the entire crawler.
 	crawlerUUID gocql.UUID
 
+	// Domains this crawler unclaimed, and when, for host affinity (see
+	// claimRecentHosts)
+	recentHosts    map[string]time.Time
+	affinityWindow time.Duration
+	affinityGrace  time.Duration
+
 	// Number of seconds the crawlerUUID lives in active_fetchers before
 	// it's flushed (unless KeepAlive is called in the interim).
 	activeFetchersTTL int
@@ -58,6 +78,42 @@ type Datastore struct {
 	// it equals Config.Cassandra.DefaultDomainPriority. In either case maxPrio is the
 	// best max_priority value available.
 	maxPrio int
+
+	// Guards maxPrio and maxPrioNeedFetch, which UpdateDomain changes outside
+	// of ClaimNewHost
+	maxPrioMu sync.Mutex
+
+	// Rate limits and retries writes to cassandra
+	throttle *writeThrottle
+
+	// Writes that failed while cassandra was unavailable, if
+	// cassandra.spill_file is set. Shared with the other Datastores of the
+	// process, see openSpillJournal.
+	spill *spillJournal
+
+	// A cache of per-domain link sampling settings, see sampled()
+	sampleCache *lru.Cache
+
+	// Whether any domain may have sampling settings of its own (see
+	// domainSamplingKey), and the time after which to re-read it if not
+	domainSampling          bool
+	domainSamplingNeedFetch time.Time
+
+	// Guards domainSampling and domainSamplingNeedFetch
+	sampleMu sync.Mutex
+
+	// A cache of links whose first referrer is known to be stored, see
+	// setFirstReferrer()
+	referrerCache *lru.Cache
+
+	// Segments holds the links of the domains this datastore claims. It is
+	// set from cassandra.segment_store; replace it before the Datastore is
+	// used to read segments from somewhere else.
+	Segments SegmentStore
+
+	// Alerter raises the alerts.robots_changed alert. Add to its Notifiers
+	// before the Datastore is used to send alerts somewhere else too.
+	Alerter *walker.Alerter
 }
 
 var MaxPriorityPeriod time.Duration
@@ -72,8 +128,18 @@ func init() {
 
 // NewDatastore creates a Cassandra session and initializes a Datastore
 func NewDatastore() (*Datastore, error) {
+	return NewDatastoreForKeyspace(walker.Config.Cassandra.Keyspace)
+}
+
+// NewDatastoreForKeyspace creates a Datastore like NewDatastore, but for the
+// crawl stored in the given keyspace instead of the configured one. Crawls in
+// different keyspaces share nothing but the cluster.
+func NewDatastoreForKeyspace(keyspace string) (*Datastore, error) {
+	if !walker.ValidKeyspace(keyspace) {
+		return nil, fmt.Errorf("Invalid keyspace name %q", keyspace)
+	}
 	ds := &Datastore{
-		cf: GetConfig(),
+		cf: GetConfigForKeyspace(keyspace),
 	}
 	var err error
 	ds.db, err = ds.cf.CreateSession()
@@ -84,6 +150,14 @@ func NewDatastore() (*Datastore, error) {
 	if err != nil {
 		return nil, err
 	}
+	ds.sampleCache, err = lru.New(walker.Config.Cassandra.AddedDomainsCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	ds.referrerCache, err = lru.New(walker.Config.Cassandra.ReferrerCacheSize)
+	if err != nil {
+		return nil, err
+	}
 
 	u, err := gocql.RandomUUID()
 	if err != nil {
@@ -100,12 +174,64 @@ func NewDatastore() (*Datastore, error) {
 	ds.restartCursor = true
 	ds.maxPrioNeedFetch = time.Now().AddDate(-1, 0, 0)
 	ds.maxPrio = walker.Config.Cassandra.DefaultDomainPriority
+	ds.throttle = newWriteThrottle()
+	ds.readConsistency = gocql.ParseConsistency(walker.Config.Cassandra.ReadConsistency)
+	ds.counterConsistency = gocql.ParseConsistency(walker.Config.Cassandra.CounterConsistency)
+	ds.speculative = speculativeExecution()
+	ds.Segments, err = newSegmentStore(keyspace, ds.db, ds.throttle, ds.read)
+	if err != nil {
+		return nil, err
+	}
+	ds.recentHosts = map[string]time.Time{}
+	ds.affinityWindow, err = time.ParseDuration(walker.Config.Cassandra.HostAffinityWindow)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	ds.affinityGrace, err = time.ParseDuration(walker.Config.Cassandra.HostAffinityGrace)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	ds.Alerter = walker.NewAlerter()
+
+	if walker.Config.Cassandra.SpillFile != "" {
+		ds.spill, err = openSpillJournal(keyspace)
+		if err != nil {
+			ds.db.Close()
+			return nil, err
+		}
+	}
 
 	return ds, nil
 }
 
+// read creates a query for a read, using cassandra.read_consistency and,
+// since reads are idempotent, speculative execution if it is configured
+func (ds *Datastore) read(stmt string, values ...interface{}) *gocql.Query {
+	q := ds.db.Query(stmt, values...).Consistency(ds.readConsistency)
+	if ds.speculative != nil {
+		q = q.Idempotent(true).SetSpeculativeExecutionPolicy(ds.speculative)
+	}
+	return q
+}
+
+// counterUpdate creates a query for a counter update, using
+// cassandra.counter_consistency. Counter updates are not idempotent, so the
+// query is not retried (see writeThrottle.execCounter).
+func (ds *Datastore) counterUpdate(stmt string, values ...interface{}) *gocql.Query {
+	return ds.db.Query(stmt, values...).Consistency(ds.counterConsistency).RetryPolicy(nil)
+}
+
 // Close will close the Datastore
 func (ds *Datastore) Close() {
+	if ds.spill != nil {
+		ds.spill.release()
+		if stats := CurrentSpillStats(); stats != (SpillStats{}) {
+			log4go.Info("Cassandra spill stats: %v", stats)
+		}
+	}
+	if stats := CurrentWriteStats(); stats.Throttled > 0 || stats.TimeoutRetries > 0 || stats.Failures > 0 {
+		log4go.Info("Cassandra write stats: %v", stats)
+	}
 	ds.db.Close()
 }
 
@@ -118,14 +244,27 @@ func (ds *Datastore) Close() {
 var limitPerClaimCycle = 50
 
 // ClaimNewHost is documented on the walker.Datastore interface.
-func (ds *Datastore) ClaimNewHost() string {
+func (ds *Datastore) ClaimNewHost(ctx context.Context) (domain string) {
+	ctx, span := walker.Tracer().Start(ctx, "cassandra.ClaimNewHost")
+	defer func() {
+		span.SetAttributes(walker.AttrDomain.String(domain))
+		span.End()
+	}()
+
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 
 	if len(ds.domains) == 0 {
+		if walker.Config.Cassandra.HostAffinity {
+			ds.domains = append(ds.domains, ds.claimRecentHosts(ctx, limitPerClaimCycle)...)
+		}
 		retryLimit := 5
-		for i := 0; i < retryLimit; i++ {
-			domainsPerPrio, retry := ds.tryClaimHosts(limitPerClaimCycle - len(ds.domains))
+		for i := 0; i < retryLimit && len(ds.domains) < limitPerClaimCycle; i++ {
+			claim := ds.tryClaimHosts
+			if strings.ToLower(walker.Config.Cassandra.ClaimStrategy) == "weighted" {
+				claim = ds.tryClaimHostsWeighted
+			}
+			domainsPerPrio, retry := claim(ctx, limitPerClaimCycle-len(ds.domains))
 			ds.domains = append(ds.domains, domainsPerPrio...)
 			if !retry {
 				break
@@ -137,7 +276,7 @@ func (ds *Datastore) ClaimNewHost() string {
 		return ""
 	}
 
-	domain := ds.domains[0]
+	domain = ds.domains[0]
 	ds.domains = ds.domains[1:]
 	return domain
 }
@@ -147,14 +286,14 @@ func (ds *Datastore) ClaimNewHost() string {
 // counters which can increment/decrement in a concurrent-consistent manner. Plus, the compare-and-set operation in
 // tryClaimHosts guarantees that only one thread can claim a domain, even if several workers, on several different
 // machines, are simultaneously trying to claim the domain.
-func (ds *Datastore) domainPriorityTry(dom string, domPriority int) bool {
-	err := ds.db.Query("UPDATE domain_counters SET next_crawl = next_crawl+? WHERE dom = ?", domPriority, dom).Exec()
+func (ds *Datastore) domainPriorityTry(ctx context.Context, dom string, domPriority int) bool {
+	err := ds.counterUpdate("UPDATE domain_counters SET next_crawl = next_crawl+? WHERE dom = ?", domPriority, dom).WithContext(ctx).Exec()
 	if err != nil {
 		log4go.Error("domainPriorityQuery failed to increment/establish counter: %v", err)
 		return false
 	}
 
-	itr := ds.db.Query(`SELECT next_crawl FROM domain_counters WHERE dom = ?`, dom).Iter()
+	itr := ds.read(`SELECT next_crawl FROM domain_counters WHERE dom = ?`, dom).WithContext(ctx).Iter()
 	cnt := 0
 	scaned := itr.Scan(&cnt)
 	err = itr.Close()
@@ -171,8 +310,8 @@ func (ds *Datastore) domainPriorityTry(dom string, domPriority int) bool {
 }
 
 // This method sets the domain_counters table correctly after a domain has been claimed.
-func (ds *Datastore) domainPriorityClaim(dom string) bool {
-	err := ds.db.Query("UPDATE domain_counters SET next_crawl = next_crawl-? WHERE dom = ?", ds.MaxPriority(), dom).Exec()
+func (ds *Datastore) domainPriorityClaim(ctx context.Context, dom string) bool {
+	err := ds.counterUpdate("UPDATE domain_counters SET next_crawl = next_crawl-? WHERE dom = ?", ds.MaxPriority(), dom).WithContext(ctx).Exec()
 	if err != nil {
 		log4go.Error("domainPrioritySet failed to clear domain_counters: %v", err)
 		return false
@@ -181,22 +320,34 @@ func (ds *Datastore) domainPriorityClaim(dom string) bool {
 	return true
 }
 
+// claimQuery claims a domain for a crawler. It is a compare-and-set query: it
+// only applies if the domain is dispatched and not already claimed.
+const claimQuery = `UPDATE domain_info 
+						SET 
+							claim_tok = ?, 
+							claim_time = ?
+						WHERE 
+							dom = ?
+						IF 
+							dispatched = true AND
+							claim_tok = 00000000-0000-0000-0000-000000000000`
+
 // tryClaimHosts trys to read a list of hosts from domain_info. Returns retry
 // if the caller should re-call the method.
-func (ds *Datastore) tryClaimHosts(limit int) (domains []string, retry bool) {
+func (ds *Datastore) tryClaimHosts(ctx context.Context, limit int) (domains []string, retry bool) {
 	var domainIter *gocql.Iter
 	if ds.restartCursor {
-		loopQuery := fmt.Sprintf(`SELECT dom, priority 
+		loopQuery := fmt.Sprintf(`SELECT dom, priority, paused, affinity_tok, last_dispatch, crawl_window, crawl_timezone
 									FROM domain_info
 									WHERE 
 										claim_tok = 00000000-0000-0000-0000-000000000000 AND
 								 		dispatched = true
 								 	LIMIT %d 
 								 	ALLOW FILTERING`, limit)
-		domainIter = ds.db.Query(loopQuery).Iter()
+		domainIter = ds.read(loopQuery).WithContext(ctx).Iter()
 		ds.restartCursor = false
 	} else {
-		loopQuery := fmt.Sprintf(`SELECT dom, priority 
+		loopQuery := fmt.Sprintf(`SELECT dom, priority, paused, affinity_tok, last_dispatch, crawl_window, crawl_timezone
 									FROM domain_info
 									WHERE 
 										claim_tok = 00000000-0000-0000-0000-000000000000 AND
@@ -204,19 +355,9 @@ func (ds *Datastore) tryClaimHosts(limit int) (domains []string, retry bool) {
 								 		TOKEN(dom) > TOKEN(?)
 								 	LIMIT %d 
 								 	ALLOW FILTERING`, limit)
-		domainIter = ds.db.Query(loopQuery, ds.claimCursor).Iter()
+		domainIter = ds.read(loopQuery, ds.claimCursor).WithContext(ctx).Iter()
 	}
 
-	casQuery := `UPDATE domain_info 
-						SET 
-							claim_tok = ?, 
-							claim_time = ?
-						WHERE 
-							dom = ?
-						IF 
-							dispatched = true AND
-							claim_tok = 00000000-0000-0000-0000-000000000000`
-
 	// The trumpedClaim counter handles the case when the code attempts to
 	// grab limit domains, but all limit of those domains are claimed by
 	// another datastore before any can be claimed by this datastore.
@@ -224,19 +365,37 @@ func (ds *Datastore) tryClaimHosts(limit int) (domains []string, retry bool) {
 	// more than 5-ish times (hence the retryLimit setting).
 	var domain string
 	var domPriority int
+	var paused bool
+	var affinityTok gocql.UUID
+	var lastDispatch time.Time
+	var crawlWindow, crawlTimezone string
+	active := map[gocql.UUID]bool{}
 	start := time.Now()
 	trumpedClaim := 0
 	scanComplete := false
-	for domainIter.Scan(&domain, &domPriority) {
+	for domainIter.Scan(&domain, &domPriority, &paused, &affinityTok, &lastDispatch, &crawlWindow, &crawlTimezone) {
 		scanComplete = true
-		if !ds.domainPriorityTry(domain, domPriority) {
+		if paused {
+			// Paused domains may still have a segment from before they were
+			// paused; leave it in place for when the domain is resumed
+			continue
+		}
+		if crawlWindowClosed(domain, crawlWindow, crawlTimezone, time.Now()) {
+			// Dispatched while the window was open, but it has closed since;
+			// leave the segment for when it opens again
+			continue
+		}
+		if ds.leftToOtherCrawler(ctx, affinityTok, lastDispatch, active) {
+			continue
+		}
+		if !ds.domainPriorityTry(ctx, domain, domPriority) {
 			continue
 		}
 
 		// The query below is a compare-and-set type query. It will only update the claim_tok, claim_time
 		// if the claim_tok remains 00000000-0000-0000-0000-000000000000 at the time of update.
 		casMap := map[string]interface{}{}
-		applied, err := ds.db.Query(casQuery, ds.crawlerUUID, time.Now(), domain).MapScanCAS(casMap)
+		applied, err := ds.db.Query(claimQuery, ds.crawlerUUID, time.Now(), domain).WithContext(ctx).MapScanCAS(casMap)
 		if err != nil {
 			log4go.Error("Failed to claim segment %v: %v", domain, err)
 		} else if !applied {
@@ -244,7 +403,7 @@ func (ds *Datastore) tryClaimHosts(limit int) (domains []string, retry bool) {
 			log4go.Fine("Domain %v was claimed by another crawler before resolution", domain)
 		} else {
 			domains = append(domains, domain)
-			if ds.domainPriorityClaim(domain) {
+			if ds.domainPriorityClaim(ctx, domain) {
 				log4go.Fine("Claimed segment %v with token %v in %v", domain, ds.crawlerUUID, time.Since(start))
 			}
 			start = time.Now()
@@ -272,9 +431,79 @@ func (ds *Datastore) tryClaimHosts(limit int) (domains []string, retry bool) {
 	return
 }
 
+// StoreDomainAssets is documented on the walker.AssetDatastore interface.
+func (ds *Datastore) StoreDomainAssets(ctx context.Context, host string, assets *walker.DomainAssets) {
+	var faviconURL string
+	if assets.FaviconURL != nil {
+		faviconURL = assets.FaviconURL.String()
+	}
+	err := ds.db.Query(`UPDATE domain_info SET favicon_url = ?, favicon_time = ?, favicon_stat = ?,
+							favicon_mime = ?, favicon_fnv = ? WHERE dom = ?`,
+		faviconURL, assets.FaviconTime, assets.FaviconStatus, assets.FaviconMimeType,
+		assets.FaviconFingerprint, host).WithContext(ctx).Exec()
+	if err != nil {
+		log4go.Error("Failed to store domain assets of %v: %v", host, err)
+	}
+}
+
+// StoreRobotsFingerprint is documented on the walker.RobotsDatastore
+// interface. If the fingerprint differs from the one last stored for host,
+// robots_changed is set and the alerts.robots_changed alert raised.
+func (ds *Datastore) StoreRobotsFingerprint(ctx context.Context, host string, fp int64) {
+	var oldFp int64
+	var since time.Time
+	err := ds.read(`SELECT robots_fnv, robots_time FROM domain_info WHERE dom = ?`, host).
+		WithContext(ctx).Scan(&oldFp, &since)
+	if err != nil {
+		log4go.Error("Failed to read robots.txt fingerprint of %v: %v", host, err)
+		return
+	}
+	if !since.IsZero() && oldFp == fp {
+		return
+	}
+
+	changed := !since.IsZero()
+	err = ds.db.Query(`UPDATE domain_info SET robots_fnv = ?, robots_time = ?, robots_changed = ?
+						WHERE dom = ?`, fp, time.Now(), changed, host).WithContext(ctx).Exec()
+	if err != nil {
+		log4go.Error("Failed to store robots.txt fingerprint of %v: %v", host, err)
+		return
+	}
+	if changed {
+		ds.robotsChanged(host, oldFp, fp)
+	}
+}
+
+// HostSettings is documented on the walker.HostSettingsDatastore interface.
+// The settings are the user_agent and crawl_delay columns of domain_info, as
+// set by SetDomainConfig.
+func (ds *Datastore) HostSettings(ctx context.Context, host string) *walker.HostSettings {
+	var userAgent string
+	var crawlDelay int
+	err := ds.read(`SELECT user_agent, crawl_delay FROM domain_info WHERE dom = ?`, host).
+		WithContext(ctx).Scan(&userAgent, &crawlDelay)
+	if err != nil {
+		if err != gocql.ErrNotFound {
+			log4go.Error("Failed to read host settings of %v: %v", host, err)
+		}
+		return nil
+	}
+	if userAgent == "" && crawlDelay <= 0 {
+		return nil
+	}
+	return &walker.HostSettings{
+		UserAgent:  userAgent,
+		CrawlDelay: time.Duration(crawlDelay) * time.Millisecond,
+	}
+}
+
 // UnclaimHost is documented on the walker.Datastore interface.
-func (ds *Datastore) UnclaimHost(host string) {
-	err := ds.db.Query(`DELETE FROM segments WHERE dom = ?`, host).Exec()
+func (ds *Datastore) UnclaimHost(ctx context.Context, host string) {
+	ctx, span := walker.Tracer().Start(ctx, "cassandra.UnclaimHost",
+		trace.WithAttributes(walker.AttrDomain.String(host)))
+	defer span.End()
+
+	err := ds.Segments.DeleteSegment(ctx, host)
 	if err != nil {
 		log4go.Error("Failed deleting segment links for %v: %v", host, err)
 	}
@@ -283,16 +512,108 @@ func (ds *Datastore) UnclaimHost(host string) {
 					   SET 
 					   		dispatched = false,
 							claim_tok = 00000000-0000-0000-0000-000000000000,
+							affinity_tok = ?,
 							queued_links = 0
-						WHERE dom = ?`, host).Exec()
+						WHERE dom = ?`, ds.crawlerUUID, host).WithContext(ctx).Exec()
 	if err != nil {
 		log4go.Error("Failed deleting %v from domains_to_crawl: %v", host, err)
 	}
+
+	if walker.Config.Cassandra.HostAffinity {
+		ds.mu.Lock()
+		ds.recentHosts[host] = time.Now()
+		ds.mu.Unlock()
+	}
+}
+
+// ClaimHost is documented on the walker.HostClaimingDatastore interface. Like
+// ClaimNewHost it is a compare-and-set query, but it claims the domain whether
+// or not it is dispatched.
+func (ds *Datastore) ClaimHost(ctx context.Context, host string) bool {
+	casMap := map[string]interface{}{}
+	applied, err := ds.db.Query(`UPDATE domain_info SET claim_tok = ?, claim_time = ? WHERE dom = ?
+								 IF claim_tok = 00000000-0000-0000-0000-000000000000`,
+		ds.crawlerUUID, time.Now(), host).WithContext(ctx).MapScanCAS(casMap)
+	if err != nil {
+		log4go.Error("Failed to claim %v: %v", host, err)
+		return false
+	}
+	if _, exists := casMap["claim_tok"]; !applied && !exists {
+		// Not in domain_info, so nobody else can claim it either
+		return true
+	}
+	return applied
+}
+
+// ReleaseHost is documented on the walker.HostClaimingDatastore interface.
+func (ds *Datastore) ReleaseHost(ctx context.Context, host string) {
+	casMap := map[string]interface{}{}
+	applied, err := ds.db.Query(`UPDATE domain_info SET claim_tok = 00000000-0000-0000-0000-000000000000
+								 WHERE dom = ? IF claim_tok = ?`,
+		host, ds.crawlerUUID).WithContext(ctx).MapScanCAS(casMap)
+	if err != nil {
+		log4go.Error("Failed to release %v: %v", host, err)
+		return
+	}
+	if applied && strings.ToLower(walker.Config.Cassandra.ClaimStrategy) == "weighted" {
+		// tryClaimHostsWeighted drops domains it finds claimed from the queue
+		if err := ds.requeueForClaim(host); err != nil {
+			log4go.Error("Failed to queue %v for claiming: %v", host, err)
+		}
+	}
+}
+
+// RequeueHost is documented on the walker.RequeueDatastore interface. links
+// replace host's segment, and host is left dispatched but unclaimed so any
+// fetcher can claim it again. If the segment can't be stored the host is
+// unclaimed instead.
+func (ds *Datastore) RequeueHost(ctx context.Context, host string, links []*walker.URL) {
+	ctx, span := walker.Tracer().Start(ctx, "cassandra.RequeueHost",
+		trace.WithAttributes(walker.AttrDomain.String(host)))
+	defer span.End()
+
+	err := ds.Segments.DeleteSegment(ctx, host)
+	if err == nil {
+		err = ds.Segments.StoreSegment(ctx, host, links)
+	}
+	if err != nil {
+		log4go.Error("Failed to requeue the segment of %v, unclaiming it: %v", host, err)
+		ds.UnclaimHost(ctx, host)
+		return
+	}
+
+	err = ds.db.Query(`UPDATE domain_info
+					   SET
+							claim_tok = 00000000-0000-0000-0000-000000000000,
+							affinity_tok = ?,
+							queued_links = ?
+						WHERE dom = ?`, ds.crawlerUUID, len(links), host).WithContext(ctx).Exec()
+	if err != nil {
+		log4go.Error("Failed to requeue %v: %v", host, err)
+		return
+	}
+	if strings.ToLower(walker.Config.Cassandra.ClaimStrategy) == "weighted" {
+		if err := ds.requeueForClaim(host); err != nil {
+			log4go.Error("Failed to queue %v for claiming: %v", host, err)
+		}
+	}
+
+	if walker.Config.Cassandra.HostAffinity {
+		ds.mu.Lock()
+		ds.recentHosts[host] = time.Now()
+		ds.mu.Unlock()
+	}
 }
 
 // LinksForHost is documented on the walker.Datastore interface.
-func (ds *Datastore) LinksForHost(domain string) <-chan *walker.URL {
-	links, err := ds.getSegmentLinks(domain)
+// TODO: change our LinksForHost implementation to kick off a goroutine to feed
+// 			the channel, instead of keeping all links in memory as we do now.
+func (ds *Datastore) LinksForHost(ctx context.Context, domain string) <-chan *walker.URL {
+	ctx, span := walker.Tracer().Start(ctx, "cassandra.LinksForHost",
+		trace.WithAttributes(walker.AttrDomain.String(domain)))
+	links, err := ds.Segments.SegmentLinks(ctx, domain)
+	span.SetAttributes(attribute.Int("walker.segment_links", len(links)))
+	walker.EndSpan(span, err)
 	if err != nil {
 		log4go.Error("Failed to grab segment for %v: %v", domain, err)
 		c := make(chan *walker.URL)
@@ -309,29 +630,6 @@ func (ds *Datastore) LinksForHost(domain string) <-chan *walker.URL {
 	return linkchan
 }
 
-// getSegmentLinks returns all the URLs in a domain's segment.
-// TODO: change our LinksForHost implementation to kick off a goroutine to feed
-// 			the channel, instead of keeping all links in memory as we do now.
-func (ds *Datastore) getSegmentLinks(domain string) (links []*walker.URL, err error) {
-	q := ds.db.Query(`SELECT dom, subdom, path, proto, time
-						FROM segments WHERE dom = ?`, domain)
-	iter := q.Iter()
-	defer func() { err = iter.Close() }()
-
-	var dbdomain, subdomain, path, protocol string
-	var crawlTime time.Time
-	for iter.Scan(&dbdomain, &subdomain, &path, &protocol, &crawlTime) {
-		u, e := walker.CreateURL(dbdomain, subdomain, path, protocol, crawlTime)
-		if e != nil {
-			log4go.Error("Error adding link (%v) to crawl: %v", u, e)
-		} else {
-			log4go.Debug("Adding link: %v", u)
-			links = append(links, u)
-		}
-	}
-	return
-}
-
 // dbfield is a little struct for updating a dynamic list of columns in the
 // database
 type dbfield struct {
@@ -340,7 +638,11 @@ type dbfield struct {
 }
 
 // StoreURLFetchResults is documented on the walker.Datastore interface.
-func (ds *Datastore) StoreURLFetchResults(fr *walker.FetchResults) {
+func (ds *Datastore) StoreURLFetchResults(ctx context.Context, fr *walker.FetchResults) {
+	ctx, span := walker.Tracer().Start(ctx, "cassandra.StoreURLFetchResults",
+		trace.WithAttributes(walker.AttrURL.String(fr.URL.String())))
+	defer span.End()
+
 	url := fr.URL
 	if len(fr.RedirectedFrom) > 0 {
 		// Remember that the actual response of this FetchResults is from
@@ -348,19 +650,28 @@ func (ds *Datastore) StoreURLFetchResults(fr *walker.FetchResults) {
 		url = fr.RedirectedFrom[len(fr.RedirectedFrom)-1]
 	}
 
-	dom, subdom, err := fr.URL.TLDPlusOneAndSubdomain()
+	// The row takes its dom and subdom from the dispatched link (fr.URL) but
+	// its path and proto from the URL the response came from, so its key is
+	// not simply fr.URL.PrimaryKey()
+	dom, subdom, _, _, err := fr.URL.PrimaryKey()
 	if err != nil {
 		// Consider storing in the link table so we don't keep trying to crawl
 		// this link
 		log4go.Error("StoreURLFetchResults not storing %v: %v", fr.URL, err)
 		return
 	}
+	path, proto := url.RequestURI(), url.Scheme
+
+	var bodyTime time.Time
+	if fr.NotModified {
+		fr, bodyTime = ds.copyForward(fr)
+	}
 
 	inserts := []dbfield{
 		dbfield{"dom", dom},
 		dbfield{"subdom", subdom},
-		dbfield{"path", url.RequestURI()},
-		dbfield{"proto", url.Scheme},
+		dbfield{"path", path},
+		dbfield{"proto", proto},
 		dbfield{"time", fr.FetchTime},
 		dbfield{"fnv", fr.FnvFingerprint},
 		dbfield{"fnv_txt", fr.FnvTextFingerprint},
@@ -374,6 +685,23 @@ func (ds *Datastore) StoreURLFetchResults(fr *walker.FetchResults) {
 		inserts = append(inserts, dbfield{"robot_ex", true})
 	}
 
+	if fr.SkippedByPrecheck {
+		inserts = append(inserts, dbfield{"precheck_skip", true})
+	}
+
+	if fr.RedirectLoop {
+		chain := make([]string, len(fr.RedirectChain))
+		for i, u := range fr.RedirectChain {
+			chain[i] = u.String()
+		}
+		inserts = append(inserts, dbfield{"redir_loop", true})
+		inserts = append(inserts, dbfield{"redir_chain", chain})
+	}
+
+	if fr.Truncated {
+		inserts = append(inserts, dbfield{"trunc", true})
+	}
+
 	if fr.Response != nil {
 		inserts = append(inserts, dbfield{"stat", fr.Response.StatusCode})
 	}
@@ -382,8 +710,36 @@ func (ds *Datastore) StoreURLFetchResults(fr *walker.FetchResults) {
 		inserts = append(inserts, dbfield{"mime", fr.MimeType})
 	}
 
+	if fr.ContentLanguage != "" {
+		inserts = append(inserts, dbfield{"content_lang", fr.ContentLanguage})
+	}
+
+	if fr.CrawlIdentity != "" {
+		inserts = append(inserts, dbfield{"crawl_id", fr.CrawlIdentity})
+	}
+
+	if fr.SHA256Fingerprint != nil {
+		inserts = append(inserts, dbfield{"sha256", fr.SHA256Fingerprint})
+	}
+
+	if fr.SimHashFingerprint != 0 {
+		inserts = append(inserts, dbfield{"simhash", fr.SimHashFingerprint})
+	}
+
+	if fr.Title != "" {
+		inserts = append(inserts, dbfield{"title", fr.Title})
+	}
+
+	if fr.Description != "" {
+		inserts = append(inserts, dbfield{"description", fr.Description})
+	}
+
 	if fr.Body != "" {
-		inserts = append(inserts, dbfield{"body", fr.Body})
+		inserts = append(inserts, bodyField(fr.Body))
+	}
+
+	if !bodyTime.IsZero() {
+		inserts = append(inserts, dbfield{"body_time", bodyTime})
 	}
 
 	if walker.Config.Cassandra.StoreResponseHeaders && fr.Response != nil && fr.Response.Header != nil {
@@ -394,6 +750,41 @@ func (ds *Datastore) StoreURLFetchResults(fr *walker.FetchResults) {
 		inserts = append(inserts, dbfield{"headers", h})
 	}
 
+	if walker.Config.Cassandra.StoreRequestHeaders {
+		if header := fr.RequestHeader(); header != nil {
+			h := map[string]string{}
+			for k, v := range header {
+				h[k] = strings.Join(v, "\000")
+			}
+			inserts = append(inserts, dbfield{"req_headers", h})
+		}
+	}
+
+	if walker.Config.Cassandra.StoreStructuredData && fr.StructuredData != nil {
+		b, err := json.Marshal(fr.StructuredData)
+		if err != nil {
+			log4go.Error("Failed to encode structured data of %v: %v", fr.URL, err)
+		} else {
+			inserts = append(inserts, dbfield{"structured", string(b)})
+		}
+	}
+
+	if walker.Config.Cassandra.StoreFetchTiming && !fr.FetchTime.Equal(walker.NotYetCrawled) {
+		inserts = append(inserts, dbfield{"timing", timingToMap(fr.Timing)})
+	}
+
+	if fr.TransientFailure() && !fr.FetchTime.Equal(walker.NotYetCrawled) {
+		retries := ds.previousRetries(dom, subdom, url) + 1
+		if backoff := walker.RetryBackoff(retries); backoff > 0 {
+			inserts = append(inserts, dbfield{"retries", retries})
+			inserts = append(inserts, dbfield{"next_retry_at", fr.FetchTime.Add(backoff)})
+		}
+	}
+
+	if refetchAfter := fr.RefetchAfter(); !refetchAfter.IsZero() {
+		inserts = append(inserts, dbfield{"refetch_after", refetchAfter})
+	}
+
 	// Put the values together and run the query
 	names := []string{}
 	values := []interface{}{}
@@ -403,121 +794,605 @@ func (ds *Datastore) StoreURLFetchResults(fr *walker.FetchResults) {
 		values = append(values, f.value)
 		placeholders = append(placeholders, "?")
 	}
-	err = ds.db.Query(
+	err = ds.spilling(ds.throttle.batcherOfSize(ctx, ds.db, 1)).add(
 		fmt.Sprintf(`INSERT INTO links (%s) VALUES (%s)`,
 			strings.Join(names, ", "), strings.Join(placeholders, ", ")),
 		values...,
-	).Exec()
+	)
 	if err != nil {
 		log4go.Error("Failed storing fetch results: %v", err)
 		return
 	}
 
+	if walker.Config.Cassandra.StoreTLSInfo && fr.TLS != nil {
+		err = ds.spilling(ds.throttle.batcherOfSize(ctx, ds.db, 1)).add(`INSERT INTO tls_certs (dom, host, time, version, cipher, issuer,
+							subject, not_after, sans) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			dom, url.Hostname(), fr.FetchTime, fr.TLS.Version, fr.TLS.CipherSuite, fr.TLS.Issuer,
+			fr.TLS.Subject, fr.TLS.NotAfter, fr.TLS.SANs)
+		if err != nil {
+			log4go.Error("Failed storing TLS info for %v: %v", url, err)
+		}
+	}
+
+	if walker.Config.Cassandra.IndexFingerprints && !fr.FetchTime.Equal(walker.NotYetCrawled) {
+		ds.indexFingerprints(ctx, url, fr)
+	}
+
+	if !fr.FetchTime.Equal(walker.NotYetCrawled) {
+		failed := fr.FetchError != nil || (fr.Response != nil && fr.Response.StatusCode >= 400)
+		mime := ""
+		if !failed && fr.Response != nil && !fr.NotModified {
+			mime = fr.MimeType
+		}
+		ds.countFetch(ctx, dom, fr.FetchTime, failed, fr.Timing.Bytes, mime)
+	}
+
 	if len(fr.RedirectedFrom) > 0 {
 		// Only trick with this is that fr.URL redirected to RedirectedFrom[0], after that
 		// RedirectedFrom[n] redirected to RedirectedFrom[n+1]
 		rf := fr.RedirectedFrom
 		back := fr.URL
+		batch := ds.spilling(ds.throttle.batcher(ctx, ds.db))
 		for i := 0; i < len(rf); i++ {
 			front := rf[i]
-			dom, subdom, err = back.TLDPlusOneAndSubdomain()
+			dom, subdom, path, proto, err := back.PrimaryKey()
 			if err != nil {
 				log4go.Error("StoreURLFetchResults not storing info for url that redirected (%v): %v", back, err)
 				continue
 			}
-			err := ds.db.Query(`INSERT INTO links (dom, subdom, path, proto, time, redto_url) VALUES (?, ?, ?, ?, ?, ?)`,
-				dom, subdom, back.RequestURI(), back.Scheme, fr.FetchTime,
-				front.String()).Exec()
+			// Record the redirect's status code (ex. 301), unless it was a
+			// meta refresh
+			var status interface{}
+			if i < len(fr.RedirectStatuses) && fr.RedirectStatuses[i] != 0 {
+				status = fr.RedirectStatuses[i]
+			}
+			err = batch.add(`INSERT INTO links (dom, subdom, path, proto, time, redto_url, stat) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				dom, subdom, path, proto, fr.FetchTime, front.String(), status)
 			if err != nil {
 				log4go.Error("Failed to insert redirected link %s -> %s: %v", back.String(), front.String(), err)
 			}
 			back = front
 		}
+		if err := batch.flush(); err != nil {
+			log4go.Error("Failed to insert redirected links for %v: %v", fr.URL, err)
+		}
 	}
 }
 
-// StoreParsedURL is documented on the walker.Datastore interface.
-func (ds *Datastore) StoreParsedURL(u *walker.URL, fr *walker.FetchResults) {
-	if !u.IsAbs() {
-		log4go.Warn("Link should not have made it to StoreParsedURL: %v", u)
+// previousRetries returns the number of consecutive transient failures
+// recorded on the latest row of the given link, or 0 if there are none.
+func (ds *Datastore) previousRetries(dom, subdom string, u *walker.URL) int {
+	var retries int
+	err := ds.read(`SELECT retries FROM links
+						WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?
+						ORDER BY time DESC LIMIT 1`,
+		dom, subdom, u.RequestURI(), u.Scheme).Scan(&retries)
+	if err != nil && err != gocql.ErrNotFound {
+		log4go.Error("Failed to read previous retries for %v: %v", u, err)
+	}
+	return retries
+}
+
+// DeadLetter implements walker.DeadLetterSink, recording the failed fetch
+// results in the handler_dead_letters table
+func (ds *Datastore) DeadLetter(ctx context.Context, fr *walker.FetchResults, err error) {
+	dom, err2 := fr.URL.ToplevelDomainPlusOne()
+	if err2 != nil {
+		log4go.Error("Not storing dead letter for %v: %v", fr.URL, err2)
 		return
 	}
-	dom, subdom, err := u.TLDPlusOneAndSubdomain()
+	var stat interface{}
+	if fr.Response != nil {
+		stat = fr.Response.StatusCode
+	}
+	errStr := ""
 	if err != nil {
-		log4go.Debug("StoreParsedURL not storing %v: %v", fr.URL, err)
-		return
+		errStr = err.Error()
 	}
+	err2 = ds.throttle.exec(ctx, ds.db.Query(`INSERT INTO handler_dead_letters (dom, time, url, stat, err)
+						VALUES (?, ?, ?, ?, ?)`, dom, time.Now(), fr.URL.String(), stat, errStr))
+	if err2 != nil {
+		log4go.Error("Failed to store dead letter for %v: %v", fr.URL, err2)
+	}
+}
 
-	exists := ds.hasDomain(dom)
-
-	if !exists && walker.Config.Cassandra.AddNewDomains {
-		log4go.Debug("Adding new domain to system: %v", dom)
-		ds.addDomain(dom)
-		exists = true
+// copyForward returns a copy of fr, a 304 Not Modified fetch, with the
+// fingerprints, mime type, title and description of the previous crawl of
+// the link filled in, and the time of the crawl whose row holds the body of
+// the link (zero if fr is returned as is, when the previous crawl is not
+// known). Only the metadata of the previous crawl is read, not its body.
+func (ds *Datastore) copyForward(fr *walker.FetchResults) (*walker.FetchResults, time.Time) {
+	// The If-Modified-Since header was only sent for the requested URL
+	if len(fr.RedirectedFrom) > 0 || fr.URL.LastCrawled.Equal(walker.NotYetCrawled) {
+		return fr, time.Time{}
+	}
+	prev, err := ds.crawlMetaAt(fr.URL, fr.URL.LastCrawled)
+	if err != nil {
+		log4go.Debug("Not copying a previous crawl forward for 304 of %v: %v", fr.URL, err)
+		return fr, time.Time{}
 	}
 
-	if exists {
-		log4go.Fine("Inserting parsed URL: %v", u)
-		err = ds.db.Query(`INSERT INTO links (dom, subdom, path, proto, time)
-							VALUES (?, ?, ?, ?, ?)`,
-			dom, subdom, u.RequestURI(), u.Scheme, walker.NotYetCrawled).Exec()
-		if err != nil {
-			log4go.Error("failed inserting parsed url (%v): %v", u, err)
-		}
+	copied := *fr
+	copied.FnvFingerprint = prev.fnv
+	copied.FnvTextFingerprint = prev.fnvText
+	copied.SHA256Fingerprint = prev.sha256
+	copied.SimHashFingerprint = prev.simhash
+	copied.MimeType = prev.mime
+	if copied.ContentLanguage == "" {
+		copied.ContentLanguage = prev.contentLang
 	}
-}
+	copied.Title = prev.title
+	copied.Description = prev.description
 
-// KeepAlive is documented on the walker.Datastore interface.
-func (ds *Datastore) KeepAlive() error {
-	err := ds.db.Query(`INSERT INTO active_fetchers (tok) VALUES (?) USING TTL ?`,
-		ds.crawlerUUID, ds.activeFetchersTTL).Exec()
-	return err
+	// The body is wherever the previous crawl's was: in its own row unless
+	// it was a 304 too
+	bodyTime := prev.bodyTime
+	if bodyTime.IsZero() {
+		bodyTime = fr.URL.LastCrawled
+	}
+	return &copied, bodyTime
 }
 
-// hasDomain expects a TopLevelDomain+1 (no subdomain) and returns true if the
-// domain exists in the domain_info table
-func (ds *Datastore) hasDomain(dom string) bool {
-	exists, ok := ds.domainCache.Get(dom)
-	if ok {
-		return exists.(bool)
+// StoredResponse implements walker.ReplaySource, rebuilding the latest
+// response stored for u in the links table. Only what was stored can be
+// replayed: the body needs cassandra.store_response_body and the headers
+// cassandra.store_response_headers (otherwise only Content-Type is set, from
+// the stored mime type).
+func (ds *Datastore) StoredResponse(u *walker.URL) (*http.Response, error) {
+	dom, subdom, path, proto, err := u.PrimaryKey()
+	if err != nil {
+		return nil, err
 	}
-	var count int
-	err := ds.db.Query(`SELECT COUNT(*) FROM domain_info WHERE dom = ?`, dom).Scan(&count)
+	itr := ds.read(`SELECT stat, headers, body, body_z, mime FROM links
+						WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?
+						ORDER BY time DESC`,
+		dom, subdom, path, proto).Iter()
+	var stat int
+	var headers map[string]string
+	var body, mime string
+	var bodyZ []byte
+	found := false
+	for itr.Scan(&stat, &headers, &body, &bodyZ, &mime) {
+		// Skip rows of failed fetches or not-yet-crawled links, and 304s,
+		// whose content is that of an earlier row
+		if stat > 0 && stat != http.StatusNotModified {
+			found = true
+			break
+		}
+	}
+	if err := itr.Close(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, walker.ErrNotStored
+	}
+	body, err = decodeBody(body, bodyZ)
 	if err != nil {
-		log4go.Error("Failed to check if %v is in domain_info: %v", dom, err)
-		return false // with error, assume we don't have it
+		return nil, fmt.Errorf("Failed to read stored response of %v: %v", u, err)
 	}
-	existsDB := (count == 1)
-	ds.domainCache.Add(dom, existsDB)
-	return existsDB
-}
 
-// addDomain adds the domain to the domain_info table if it does not exist. If
-// it encounters an error it will log it and move on.
-func (ds *Datastore) addDomain(dom string) {
-	err := ds.addDomainWithExcludeReason(dom, "")
-	if err != nil {
-		log4go.Error("Failed to add new dom %v: %v", dom, err)
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", stat, http.StatusText(stat)),
+		StatusCode:    stat,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	for k, v := range headers {
+		res.Header[k] = strings.Split(v, "\000")
+	}
+	if res.Header.Get("Content-Type") == "" && mime != "" {
+		res.Header.Set("Content-Type", mime)
 	}
+	// The body stored is the decoded one
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	return res, nil
 }
 
-// addDomainWithExcludeReason adds a domain to the domain_info table if it does
-// not exist.
-func (ds *Datastore) addDomainWithExcludeReason(dom string, reason string) error {
+// countFetch updates the fetch counters used by CrawlOverview, and, if mime
+// is set, the MIME type counters of dom (see MimeTypeCounts)
+func (ds *Datastore) countFetch(ctx context.Context, dom string, fetchTime time.Time, failed bool, bytes int64,
+	mime string) {
 
-	// Try insert with excluded set to avoid dispatcher picking this domain up before the
-	// excluded reason can be set.
-	query := `INSERT INTO domain_info (dom, claim_tok, dispatched, priority, excluded) 
-					 VALUES (?, ?, false, ?, true) IF NOT EXISTS`
-	err := ds.db.Query(query, dom, gocql.UUID{}, walker.Config.Cassandra.DefaultDomainPriority).Exec()
+	errInc := 0
+	if failed {
+		errInc = 1
+	}
+	mimeCounter := ""
+	if mime != "" {
+		col := "mime_" + mimeClass(mime)
+		mimeCounter = fmt.Sprintf(", %s = %s + 1", col, col)
+	}
+	err := ds.throttle.execCounter(ctx, ds.counterUpdate(`UPDATE domain_counters SET fetches = fetches + 1, fetch_errors = fetch_errors + ?,
+						bytes = bytes + ?`+mimeCounter+` WHERE dom = ?`, errInc, bytes, dom))
 	if err != nil {
-		return err
+		log4go.Error("Failed to update fetch counters for %v: %v", dom, err)
 	}
 
-	// Now set the exclude reason
-	excluded := true
-	if reason == "" {
-		excluded = false
+	err = ds.throttle.execCounter(ctx, ds.counterUpdate(`UPDATE fetch_counts SET fetches = fetches + 1 WHERE bucket = ?`,
+		fetchTime.Truncate(time.Minute)))
+	if err != nil {
+		log4go.Error("Failed to update fetch_counts: %v", err)
+	}
+
+	err = ds.throttle.execCounter(ctx, ds.counterUpdate(`UPDATE domain_fetch_counts SET fetches = fetches + 1, bytes = bytes + ?
+						WHERE dom = ? AND bucket = ?`, bytes, dom, fetchTime.Truncate(domainFetchBucket)))
+	if err != nil {
+		log4go.Error("Failed to update domain_fetch_counts for %v: %v", dom, err)
+	}
+}
+
+// mimeClass returns which of the MIME type counters of domain_counters mime
+// is counted in: html, pdf, image or other
+func mimeClass(mime string) string {
+	mime = strings.ToLower(mime)
+	switch {
+	case mime == "text/html" || mime == "application/xhtml+xml":
+		return "html"
+	case mime == "application/pdf":
+		return "pdf"
+	case strings.HasPrefix(mime, "image/"):
+		return "image"
+	default:
+		return "other"
+	}
+}
+
+// MimeTypeCounts is documented on the ModelDatastore interface.
+func (ds *Datastore) MimeTypeCounts(domain string) (*MimeTypeCounts, error) {
+	counts := &MimeTypeCounts{Domain: domain}
+	err := ds.read(`SELECT mime_html, mime_pdf, mime_image, mime_other FROM domain_counters WHERE dom = ?`,
+		domain).Scan(&counts.HTML, &counts.PDF, &counts.Image, &counts.Other)
+	if err != nil && err != gocql.ErrNotFound {
+		return nil, fmt.Errorf("domain_counters query failed: %v", err)
+	}
+	return counts, nil
+}
+
+// PreviewSegment is documented on the ModelDatastore interface.
+func (ds *Datastore) PreviewSegment(domain string) (*SegmentPreview, error) {
+	sg := &SegmentGenerator{DB: ds.db}
+	return sg.Preview(domain)
+}
+
+// timingToMap converts a FetchTiming to the map stored in the timing column of
+// the links table
+func timingToMap(t walker.FetchTiming) map[string]int64 {
+	return map[string]int64{
+		"dns":      int64(t.DNS),
+		"connect":  int64(t.Connect),
+		"tls":      int64(t.TLS),
+		"ttfb":     int64(t.TTFB),
+		"transfer": int64(t.Transfer),
+		"bytes":    t.Bytes,
+	}
+}
+
+// timingFromMap is the inverse of timingToMap. Missing keys are left zero.
+func timingFromMap(m map[string]int64) walker.FetchTiming {
+	return walker.FetchTiming{
+		DNS:      time.Duration(m["dns"]),
+		Connect:  time.Duration(m["connect"]),
+		TLS:      time.Duration(m["tls"]),
+		TTFB:     time.Duration(m["ttfb"]),
+		Transfer: time.Duration(m["transfer"]),
+		Bytes:    m["bytes"],
+	}
+}
+
+// StoreParsedURL is documented on the walker.Datastore interface.
+func (ds *Datastore) StoreParsedURL(ctx context.Context, u *walker.URL, fr *walker.FetchResults) {
+	ctx, span := walker.Tracer().Start(ctx, "cassandra.StoreParsedURL",
+		trace.WithAttributes(walker.AttrURL.String(u.String())))
+	defer span.End()
+
+	if _, ok := ds.shouldStoreParsedURL(ctx, u); !ok {
+		return
+	}
+	// shouldStoreParsedURL has already checked that u has a domain
+	dom, subdom, path, proto, _ := u.PrimaryKey()
+	log4go.Fine("Inserting parsed URL: %v", u)
+	if ds.storeReferrers(fr) {
+		batch := ds.spilling(ds.throttle.batcher(ctx, ds.db))
+		ds.addParsedURLWithReferrer(ctx, batch, dom, subdom, path, proto, u, fr.URL)
+		if err := batch.flush(); err != nil {
+			log4go.Error("failed inserting parsed url (%v): %v", u, err)
+		}
+		return
+	}
+	err := ds.spilling(ds.throttle.batcherOfSize(ctx, ds.db, 1)).add(insertParsedURL,
+		dom, subdom, path, proto, walker.NotYetCrawled, u.Nofollow, u.JSRedirect)
+	if err != nil {
+		log4go.Error("failed inserting parsed url (%v): %v", u, err)
+	}
+}
+
+// StoreParsedURLs is documented on the walker.BatchDatastore interface. Links
+// are grouped by domain (the partition key of the links table) and written in
+// unlogged batches of up to cassandra.parsed_link_batch_size.
+func (ds *Datastore) StoreParsedURLs(ctx context.Context, urls []*walker.URL, fr *walker.FetchResults) {
+	ctx, span := walker.Tracer().Start(ctx, "cassandra.StoreParsedURLs",
+		trace.WithAttributes(attribute.Int("walker.parsed_links", len(urls))))
+	defer span.End()
+
+	byDomain := map[string][]*walker.URL{}
+	var doms []string
+	for _, u := range urls {
+		dom, ok := ds.shouldStoreParsedURL(ctx, u)
+		if !ok {
+			continue
+		}
+		if _, seen := byDomain[dom]; !seen {
+			doms = append(doms, dom)
+		}
+		byDomain[dom] = append(byDomain[dom], u)
+	}
+
+	for _, dom := range doms {
+		batch := ds.spilling(ds.throttle.batcherOfSize(ctx, ds.db, walker.Config.Cassandra.ParsedLinkBatchSize))
+		for _, u := range byDomain[dom] {
+			_, subdom, path, proto, _ := u.PrimaryKey()
+			log4go.Fine("Inserting parsed URL: %v", u)
+			if ds.storeReferrers(fr) {
+				ds.addParsedURLWithReferrer(ctx, batch, dom, subdom, path, proto, u, fr.URL)
+				continue
+			}
+			err := batch.add(insertParsedURL,
+				dom, subdom, path, proto, walker.NotYetCrawled, u.Nofollow, u.JSRedirect)
+			if err != nil {
+				log4go.Error("failed inserting parsed urls of %v: %v", dom, err)
+			}
+		}
+		if err := batch.flush(); err != nil {
+			log4go.Error("failed inserting parsed urls of %v: %v", dom, err)
+		}
+	}
+}
+
+const insertParsedURL = `INSERT INTO links (dom, subdom, path, proto, time, nofollow, js_redirect)
+							VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+// shouldStoreParsedURL returns the domain of u and true if it
+// should be stored, adding its domain to domain_info (or counting it as
+// pending) as configured
+func (ds *Datastore) shouldStoreParsedURL(ctx context.Context, u *walker.URL) (string, bool) {
+	if !u.IsAbs() {
+		log4go.Warn("Link should not have made it to StoreParsedURL: %v", u)
+		return "", false
+	}
+	dom, err := u.ToplevelDomainPlusOne()
+	if err != nil {
+		log4go.Debug("StoreParsedURL not storing %v: %v", u, err)
+		return "", false
+	}
+
+	exists := ds.hasDomain(ctx, dom)
+
+	if !exists && walker.Config.Cassandra.AddNewDomains {
+		log4go.Debug("Adding new domain to system: %v", dom)
+		ds.addDomain(dom)
+		exists = true
+	}
+
+	if !exists && walker.Config.Cassandra.TrackPendingDomains {
+		err = ds.counterUpdate(`UPDATE pending_domains SET refs = refs + 1 WHERE dom = ?`, dom).WithContext(ctx).Exec()
+		if err != nil {
+			log4go.Error("Failed to count pending domain %v: %v", dom, err)
+		}
+		return "", false
+	}
+
+	if exists && !ds.sampled(ctx, dom, u) {
+		log4go.Fine("Not storing %v, it was not chosen by link sampling", u)
+		return "", false
+	}
+
+	return dom, exists
+}
+
+// KeepAlive is documented on the walker.Datastore interface.
+func (ds *Datastore) KeepAlive(ctx context.Context) error {
+	err := ds.db.Query(`INSERT INTO active_fetchers (tok) VALUES (?) USING TTL ?`,
+		ds.crawlerUUID, ds.activeFetchersTTL).WithContext(ctx).Exec()
+	return err
+}
+
+// ErrorCount is documented on the walker.ErrorCountingDatastore interface. It
+// returns the number of writes that failed (see WriteStats.Failures).
+func (ds *Datastore) ErrorCount() int64 {
+	return CurrentWriteStats().Failures
+}
+
+// Retire is documented on the walker.RetiringDatastore interface. It unclaims
+// the hosts claimed with this crawler's UUID, removes the UUID from
+// active_fetchers and switches to a new one.
+func (ds *Datastore) Retire(ctx context.Context) error {
+	ds.mu.Lock()
+	ds.domains = nil
+	ds.mu.Unlock()
+
+	iter := ds.read(`SELECT dom FROM domain_info WHERE claim_tok = ?`, ds.crawlerUUID).WithContext(ctx).Iter()
+	var claimed []string
+	var dom string
+	for iter.Scan(&dom) {
+		claimed = append(claimed, dom)
+	}
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf("Failed to find domains claimed by %v: %v", ds.crawlerUUID, err)
+	}
+	for _, dom := range claimed {
+		log4go.Info("Retiring crawler %v, unclaiming %v", ds.crawlerUUID, dom)
+		ds.UnclaimHost(ctx, dom)
+	}
+
+	err := ds.db.Query(`DELETE FROM active_fetchers WHERE tok = ?`, ds.crawlerUUID).WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("Failed to remove %v from active_fetchers: %v", ds.crawlerUUID, err)
+	}
+
+	u, err := gocql.RandomUUID()
+	if err != nil {
+		return err
+	}
+	ds.crawlerUUID = u
+	ds.mu.Lock()
+	ds.recentHosts = map[string]time.Time{}
+	ds.mu.Unlock()
+	return nil
+}
+
+// sampleCacheTTL is how long the sampling settings of a domain are cached by
+// sampled before being read again
+var sampleCacheTTL = 5 * time.Minute
+
+// domainSampling caches the domain_info fields needed by sampled
+type domainSampling struct {
+	uncrawled int
+	threshold int
+	percent   float64
+	expires   time.Time
+}
+
+// sampled returns true if u should be stored under link sampling (see
+// cassandra.sample_threshold in walker.yaml): either dom is not being sampled
+// or u hashes into the sampled percentage of links. The domain's own settings
+// apply even if sampling is off in the config, but domain_info is only read
+// for them if some domain was given any.
+func (ds *Datastore) sampled(ctx context.Context, dom string, u *walker.URL) bool {
+	cfg := walker.Config.Cassandra
+	if (cfg.SampleThreshold <= 0 || cfg.SamplePercent >= 100.0) && !ds.anyDomainSampling() {
+		return true
+	}
+
+	var s *domainSampling
+	if cached, ok := ds.sampleCache.Get(dom); ok && time.Now().Before(cached.(*domainSampling).expires) {
+		s = cached.(*domainSampling)
+	} else {
+		var threshold int
+		var percent float32
+		s = &domainSampling{expires: time.Now().Add(sampleCacheTTL)}
+		err := ds.read(`SELECT uncrawled_links, sample_threshold, sample_percent FROM domain_info WHERE dom = ?`,
+			dom).WithContext(ctx).Scan(&s.uncrawled, &threshold, &percent)
+		if err != nil {
+			log4go.Error("Failed to read sampling settings for %v: %v", dom, err)
+			return true // with error, store the link rather than lose it
+		}
+		s.threshold = walker.Config.Cassandra.SampleThreshold
+		if threshold > 0 {
+			s.threshold = threshold
+		}
+		s.percent = walker.Config.Cassandra.SamplePercent
+		if percent > 0 {
+			s.percent = float64(percent)
+		}
+		ds.sampleCache.Add(dom, s)
+	}
+
+	if s.threshold <= 0 || s.uncrawled <= s.threshold || s.percent >= 100.0 {
+		return true
+	}
+	return sampleHash(u) < s.percent
+}
+
+// domainSamplingKey is the walker_globals key set once any domain is given
+// sampling settings of its own. It is never unset.
+const domainSamplingKey = "domain_sampling"
+
+// anyDomainSampling returns true if any domain may have sampling settings of
+// its own, as recorded in walker_globals. Until it is set it is re-read every
+// sampleCacheTTL.
+func (ds *Datastore) anyDomainSampling() bool {
+	ds.sampleMu.Lock()
+	defer ds.sampleMu.Unlock()
+	if ds.domainSampling || time.Now().Before(ds.domainSamplingNeedFetch) {
+		return ds.domainSampling
+	}
+	var val int
+	err := ds.read(`SELECT val FROM walker_globals WHERE key = ?`, domainSamplingKey).Scan(&val)
+	if err != nil && err != gocql.ErrNotFound {
+		log4go.Error("Failed to read %v: %v", domainSamplingKey, err)
+		return true // with error, check the domain rather than skip its settings
+	}
+	ds.domainSampling = val > 0
+	ds.domainSamplingNeedFetch = time.Now().Add(sampleCacheTTL)
+	return ds.domainSampling
+}
+
+// noteDomainSampling sets domainSamplingKey in walker_globals if info gives a
+// domain sampling settings of its own
+func (ds *Datastore) noteDomainSampling(info *DomainInfo) error {
+	if info.SampleThreshold <= 0 && (info.SamplePercent <= 0 || info.SamplePercent >= 100) {
+		return nil
+	}
+	err := ds.db.Query(`INSERT INTO walker_globals (key, val) VALUES (?, ?)`, domainSamplingKey, 1).Exec()
+	if err != nil {
+		return fmt.Errorf("Failed to set %v: %v", domainSamplingKey, err)
+	}
+	ds.sampleMu.Lock()
+	ds.domainSampling = true
+	ds.sampleMu.Unlock()
+	return nil
+}
+
+// sampleHash deterministically maps u to a number in [0, 100), so the same
+// links are always chosen for a given sample percentage
+func sampleHash(u *walker.URL) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(u.String()))
+	return float64(h.Sum64()%10000) / 100.0
+}
+
+// hasDomain expects a TopLevelDomain+1 (no subdomain) and returns true if the
+// domain exists in the domain_info table
+func (ds *Datastore) hasDomain(ctx context.Context, dom string) bool {
+	exists, ok := ds.domainCache.Get(dom)
+	if ok {
+		return exists.(bool)
+	}
+	var count int
+	err := ds.read(`SELECT COUNT(*) FROM domain_info WHERE dom = ?`, dom).WithContext(ctx).Scan(&count)
+	if err != nil {
+		log4go.Error("Failed to check if %v is in domain_info: %v", dom, err)
+		return false // with error, assume we don't have it
+	}
+	existsDB := (count == 1)
+	ds.domainCache.Add(dom, existsDB)
+	return existsDB
+}
+
+// addDomain adds the domain to the domain_info table if it does not exist. If
+// it encounters an error it will log it and move on.
+func (ds *Datastore) addDomain(dom string) {
+	err := ds.addDomainWithExcludeReason(dom, "")
+	if err != nil {
+		log4go.Error("Failed to add new dom %v: %v", dom, err)
+	}
+}
+
+// addDomainWithExcludeReason adds a domain to the domain_info table if it does
+// not exist.
+func (ds *Datastore) addDomainWithExcludeReason(dom string, reason string) error {
+
+	// Try insert with excluded set to avoid dispatcher picking this domain up before the
+	// excluded reason can be set.
+	query := `INSERT INTO domain_info (dom, claim_tok, dispatched, priority, excluded) 
+					 VALUES (?, ?, false, ?, true) IF NOT EXISTS`
+	err := ds.db.Query(query, dom, gocql.UUID{}, walker.Config.Cassandra.DefaultDomainPriority).Exec()
+	if err != nil {
+		return err
+	}
+	if prio := walker.Config.Cassandra.DefaultDomainPriority; prio > ds.MaxPriority() {
+		ds.raiseMaxPriority(prio)
+	}
+
+	// Now set the exclude reason
+	excluded := true
+	if reason == "" {
+		excluded = false
 	}
 	query = `UPDATE domain_info 
 	     	 SET 
@@ -534,10 +1409,14 @@ func (ds *Datastore) addDomainWithExcludeReason(dom string, reason string) error
 	return nil
 }
 
+// MaxPriority returns the highest priority of any domain, as recorded in
+// walker_globals. It is re-read every MaxPriorityPeriod.
 func (ds *Datastore) MaxPriority() int {
+	ds.maxPrioMu.Lock()
+	defer ds.maxPrioMu.Unlock()
 	if time.Now().After(ds.maxPrioNeedFetch) {
 		var prio int
-		err := ds.db.Query("SELECT val FROM walker_globals WHERE key = ?", "max_priority").Scan(&prio)
+		err := ds.read("SELECT val FROM walker_globals WHERE key = ?", maxPriorityKey).Scan(&prio)
 		if err != nil {
 			log4go.Error("MaxPriority failed to read max_priority: %v", err)
 		} else {
@@ -548,20 +1427,77 @@ func (ds *Datastore) MaxPriority() int {
 	return ds.maxPrio
 }
 
+// maxPriorityKey is the walker_globals key of the highest domain priority
+const maxPriorityKey = "max_priority"
+
+// raiseMaxPriority makes sure max_priority is at least prio, after a domain
+// has been given that priority. Lowering a priority never lowers
+// max_priority here; the dispatcher's repairMaxPriority picks that up.
+func (ds *Datastore) raiseMaxPriority(prio int) {
+	if err := setMaxPriorityAtLeast(ds.db, prio); err != nil {
+		log4go.Error("Failed to raise max_priority to %d: %v", prio, err)
+		return
+	}
+	ds.maxPrioMu.Lock()
+	if prio > ds.maxPrio {
+		ds.maxPrio = prio
+	}
+	ds.maxPrioMu.Unlock()
+}
+
+// setMaxPriorityAtLeast sets max_priority in walker_globals to prio unless it is
+// already at least that high. Lightweight transactions are used so that
+// concurrent raises never lower it.
+func setMaxPriorityAtLeast(db *gocql.Session, prio int) error {
+	for i := 0; i < 5; i++ {
+		casMap := map[string]interface{}{}
+		applied, err := db.Query(`UPDATE walker_globals SET val = ? WHERE key = ? IF val < ?`,
+			prio, maxPriorityKey, prio).MapScanCAS(casMap)
+		if err != nil {
+			return err
+		}
+		if applied {
+			return nil
+		}
+		if _, ok := casMap["val"]; ok {
+			// Already at least prio
+			return nil
+		}
+
+		// There is no max_priority yet
+		applied, err = db.Query(`INSERT INTO walker_globals (key, val) VALUES (?, ?) IF NOT EXISTS`,
+			maxPriorityKey, prio).MapScanCAS(map[string]interface{}{})
+		if err != nil || applied {
+			return err
+		}
+	}
+	return fmt.Errorf("max_priority kept changing")
+}
+
 //
 // DomainInfo calls
 //
 
 func (ds *Datastore) FindDomain(domain string) (*DomainInfo, error) {
-	itr := ds.db.Query(`SELECT claim_tok, claim_time, excluded, exclude_reason, priority, tot_links, uncrawled_links, 
-						queued_links FROM domain_info WHERE dom = ?`, domain).Iter()
+	itr := ds.read(`SELECT claim_tok, claim_time, excluded, exclude_reason, paused, priority, tot_links, uncrawled_links, 
+						queued_links, sample_threshold, sample_percent, byte_budget, crawl_window, crawl_timezone,
+						user_agent, crawl_delay, favicon_url, favicon_time, favicon_stat, favicon_mime, favicon_fnv,
+						robots_fnv, robots_time, robots_changed, tags, trap_patterns, trap_accepted, trap_time,
+						trap_excluded
+						FROM domain_info WHERE dom = ?`, domain).Iter()
 	var claimTok gocql.UUID
-	var claimTime time.Time
-	var excluded bool
-	var excludeReason string
-	var priority, linksCount, uncrawledLinksCount, queuedLinksCount int
-	if !itr.Scan(&claimTok, &claimTime, &excluded, &excludeReason, &priority, &linksCount, &uncrawledLinksCount,
-		&queuedLinksCount) {
+	var claimTime, faviconTime, robotsTime, trapTime time.Time
+	var excluded, paused, robotsChanged, trapExcluded bool
+	var excludeReason, crawlWindow, crawlTimezone, userAgent, faviconURL, faviconMime string
+	var priority, linksCount, uncrawledLinksCount, queuedLinksCount, sampleThreshold, crawlDelay, faviconStatus int
+	var samplePercent float32
+	var byteBudget, faviconFnv, robotsFnv int64
+	var tags, trapPatterns, trapAccepted []string
+	if !itr.Scan(&claimTok, &claimTime, &excluded, &excludeReason, &paused, &priority, &linksCount, &uncrawledLinksCount,
+		&queuedLinksCount, &sampleThreshold, &samplePercent, &byteBudget, &crawlWindow, &crawlTimezone,
+		&userAgent, &crawlDelay, &faviconURL, &faviconTime, &faviconStatus, &faviconMime, &faviconFnv,
+		&robotsFnv, &robotsTime, &robotsChanged, &tags, &trapPatterns, &trapAccepted, &trapTime,
+		&trapExcluded) {
 		err := itr.Close()
 		return nil, err
 	}
@@ -579,10 +1515,31 @@ func (ds *Datastore) FindDomain(domain string) (*DomainInfo, error) {
 		ClaimTime:            claimTime,
 		Excluded:             excluded,
 		ExcludeReason:        reason,
+		Paused:               paused,
+		Tags:                 tags,
 		Priority:             priority,
 		NumberLinksTotal:     linksCount,
 		NumberLinksUncrawled: uncrawledLinksCount,
 		NumberLinksQueued:    queuedLinksCount,
+		SampleThreshold:      sampleThreshold,
+		SamplePercent:        samplePercent,
+		ByteBudget:           byteBudget,
+		CrawlWindow:          crawlWindow,
+		CrawlTimezone:        crawlTimezone,
+		UserAgent:            userAgent,
+		CrawlDelay:           time.Duration(crawlDelay) * time.Millisecond,
+		FaviconURL:           faviconURL,
+		FaviconTime:          faviconTime,
+		FaviconStatus:        faviconStatus,
+		FaviconMimeType:      faviconMime,
+		FaviconFingerprint:   faviconFnv,
+		RobotsFingerprint:    robotsFnv,
+		RobotsTime:           robotsTime,
+		RobotsChanged:        robotsChanged,
+		TrapPatterns:         trapPatterns,
+		TrapAccepted:         trapAccepted,
+		TrapTime:             trapTime,
+		TrapExcluded:         trapExcluded,
 	}
 	err := itr.Close()
 	if err != nil {
@@ -599,13 +1556,19 @@ func (ds *Datastore) ListDomains(query DQ) ([]*DomainInfo, error) {
 		conditions = append(conditions, "dispatched = true")
 	}
 
+	if query.Tag != "" {
+		conditions = append(conditions, "tags CONTAINS ?")
+		args = append(args, query.Tag)
+	}
+
 	if query.Seed != "" {
 		conditions = append(conditions, "TOKEN(dom) > TOKEN(?)")
 		args = append(args, query.Seed)
 	}
 
-	cql := `SELECT dom, claim_tok, claim_time, excluded, exclude_reason, priority,
-				   tot_links, uncrawled_links, queued_links 
+	cql := `SELECT dom, claim_tok, claim_time, excluded, exclude_reason, paused, priority,
+				   tot_links, uncrawled_links, queued_links, sample_threshold, sample_percent, byte_budget,
+				   crawl_window, crawl_timezone, user_agent, crawl_delay, tags
 			FROM domain_info`
 
 	if len(conditions) > 0 {
@@ -617,17 +1580,27 @@ func (ds *Datastore) ListDomains(query DQ) ([]*DomainInfo, error) {
 		args = append(args, query.Limit)
 	}
 
+	// Both dispatched and tags are indexed, but a query can only use one
+	// index
+	if query.Working && query.Tag != "" {
+		cql += " ALLOW FILTERING"
+	}
+
 	log4go.Debug("Listing domains with query: %v %v", cql, args)
-	itr := ds.db.Query(cql, args...).Iter()
+	itr := ds.read(cql, args...).Iter()
 
 	var dinfos []*DomainInfo
-	var domain, excludeReason string
+	var domain, excludeReason, crawlWindow, crawlTimezone, userAgent string
 	var claimTok gocql.UUID
 	var claimTime time.Time
-	var excluded bool
-	var priority, linksCount, uncrawledLinksCount, queuedLinksCount int
-	for itr.Scan(&domain, &claimTok, &claimTime, &excluded, &excludeReason, &priority, &linksCount,
-		&uncrawledLinksCount, &queuedLinksCount) {
+	var excluded, paused bool
+	var priority, linksCount, uncrawledLinksCount, queuedLinksCount, sampleThreshold, crawlDelay int
+	var samplePercent float32
+	var byteBudget int64
+	var tags []string
+	for itr.Scan(&domain, &claimTok, &claimTime, &excluded, &excludeReason, &paused, &priority, &linksCount,
+		&uncrawledLinksCount, &queuedLinksCount, &sampleThreshold, &samplePercent, &byteBudget,
+		&crawlWindow, &crawlTimezone, &userAgent, &crawlDelay, &tags) {
 		reason := ""
 		if excludeReason != "" {
 			reason = excludeReason
@@ -642,10 +1615,19 @@ func (ds *Datastore) ListDomains(query DQ) ([]*DomainInfo, error) {
 			ClaimTime:            claimTime,
 			Excluded:             excluded,
 			ExcludeReason:        reason,
+			Paused:               paused,
+			Tags:                 tags,
 			Priority:             priority,
 			NumberLinksTotal:     linksCount,
 			NumberLinksUncrawled: uncrawledLinksCount,
 			NumberLinksQueued:    queuedLinksCount,
+			SampleThreshold:      sampleThreshold,
+			SamplePercent:        samplePercent,
+			ByteBudget:           byteBudget,
+			CrawlWindow:          crawlWindow,
+			CrawlTimezone:        crawlTimezone,
+			UserAgent:            userAgent,
+			CrawlDelay:           time.Duration(crawlDelay) * time.Millisecond,
 		})
 	}
 	err := itr.Close()
@@ -657,6 +1639,50 @@ func (ds *Datastore) ListDomains(query DQ) ([]*DomainInfo, error) {
 }
 
 func (ds *Datastore) UpdateDomain(domain string, info *DomainInfo, cfg DomainInfoUpdateConfig) error {
+	vars, args, err := domainUpdateFields(info, cfg)
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("UPDATE domain_info\n")
+	buffer.WriteString("SET\n")
+	for i, v := range vars {
+		buffer.WriteString(v)
+		if i != len(vars)-1 {
+			buffer.WriteString(" = ?,\n")
+		} else {
+			buffer.WriteString(" = ?\n")
+		}
+	}
+	buffer.WriteString("WHERE dom = ?\n")
+	args = append(args, domain)
+	query := buffer.String()
+
+	if cfg.Sampling {
+		if err := ds.noteDomainSampling(info); err != nil {
+			return err
+		}
+	}
+	err = ds.db.Query(query, args...).Exec()
+	if err != nil {
+		return err
+	}
+	if cfg.Priority {
+		ds.raiseMaxPriority(info.Priority)
+	}
+	return nil
+}
+
+// domainUpdateFields returns the domain_info columns cfg says to update, and
+// their values from info, after checking info's crawl window if it is to be
+// updated
+func domainUpdateFields(info *DomainInfo, cfg DomainInfoUpdateConfig) ([]string, []interface{}, error) {
+	if cfg.CrawlWindow {
+		if _, err := ParseCrawlWindow(info.CrawlWindow, info.CrawlTimezone); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	vars := []string{}
 	args := []interface{}{}
@@ -675,27 +1701,340 @@ func (ds *Datastore) UpdateDomain(domain string, info *DomainInfo, cfg DomainInf
 		args = append(args, info.Priority)
 	}
 
+	if cfg.Sampling {
+		vars = append(vars, "sample_threshold", "sample_percent")
+		args = append(args, info.SampleThreshold, info.SamplePercent)
+	}
+
+	if cfg.ByteBudget {
+		vars = append(vars, "byte_budget")
+		args = append(args, info.ByteBudget)
+	}
+
+	if cfg.Fetching {
+		vars = append(vars, "user_agent", "crawl_delay")
+		args = append(args, info.UserAgent, int(info.CrawlDelay/time.Millisecond))
+	}
+
+	if cfg.CrawlWindow {
+		vars = append(vars, "crawl_window", "crawl_timezone")
+		args = append(args, info.CrawlWindow, info.CrawlTimezone)
+	}
+
 	if len(vars) < 1 {
-		return fmt.Errorf("Expected at least one variable set in cfg (of type DomainInfoUpdateConfig)")
+		return nil, nil, fmt.Errorf("Expected at least one variable set in cfg (of type DomainInfoUpdateConfig)")
 	}
+	return vars, args, nil
+}
 
-	var buffer bytes.Buffer
-	buffer.WriteString("UPDATE domain_info\n")
-	buffer.WriteString("SET\n")
+// updateDomainsPageSize is the number of domains UpdateDomains lists at a time
+var updateDomainsPageSize = 1000
+
+// UpdateDomains is documented on the ModelDatastore interface.
+func (ds *Datastore) UpdateDomains(query DQ, info *DomainInfo, cfg DomainInfoUpdateConfig) (*DomainsUpdate, error) {
+	vars, args, err := domainUpdateFields(info, cfg)
+	if err != nil {
+		return nil, err
+	}
+	sets := make([]string, len(vars))
 	for i, v := range vars {
-		buffer.WriteString(v)
-		if i != len(vars)-1 {
-			buffer.WriteString(" = ?,\n")
+		sets[i] = v + " = ?"
+	}
+	cql := `UPDATE domain_info SET ` + strings.Join(sets, ", ") + ` WHERE dom = ?`
+	if cfg.Sampling {
+		if err := ds.noteDomainSampling(info); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &DomainsUpdate{}
+	page := DQ{Seed: query.Seed, Working: query.Working, Tag: query.Tag}
+	for {
+		page.Limit = updateDomainsPageSize
+		if query.Limit > 0 && query.Limit-result.Matched < page.Limit {
+			page.Limit = query.Limit - result.Matched
+		}
+		if page.Limit <= 0 {
+			break
+		}
+		dinfos, err := ds.ListDomains(page)
+		if err != nil {
+			return result, fmt.Errorf("Listing domains after %q failed: %v", page.Seed, err)
+		}
+
+		for _, d := range dinfos {
+			result.Matched++
+			if cfg.IfUnchanged {
+				applied, err := ds.updateDomainIfUnchanged(d, vars, args, cfg)
+				if err != nil {
+					return result, fmt.Errorf("Failed to update %v: %v", d.Domain, err)
+				}
+				if !applied {
+					log4go.Debug("Not updating domain %v, it changed since it was listed", d.Domain)
+					result.Conflicts = append(result.Conflicts, d.Domain)
+					continue
+				}
+			} else {
+				err := ds.db.Query(cql, append(args, d.Domain)...).Exec()
+				if err != nil {
+					return result, fmt.Errorf("Failed to update %v: %v", d.Domain, err)
+				}
+			}
+			result.Updated++
+		}
+
+		if len(dinfos) < page.Limit {
+			break
+		}
+		page.Seed = dinfos[len(dinfos)-1].Domain
+	}
+
+	if cfg.Priority && result.Updated > 0 {
+		ds.raiseMaxPriority(info.Priority)
+	}
+	return result, nil
+}
+
+// updateDomainIfUnchanged sets vars of domain_info to args for the domain of
+// current with a lightweight transaction, applied only if the columns being
+// set still have the values in current. A column
+// holding its zero value in current may also be null, since ListDomains reads
+// null as zero. exclude_reason isn't compared, since ListDomains fills in a
+// placeholder for excluded domains without one.
+func (ds *Datastore) updateDomainIfUnchanged(current *DomainInfo, vars []string, args []interface{},
+	cfg DomainInfoUpdateConfig) (bool, error) {
+	// The current crawl window is compared as it is, valid or not
+	cfg.CrawlWindow = false
+	_, currentArgs, _ := domainUpdateFields(current, cfg)
+	if len(vars) > len(currentArgs) {
+		currentArgs = append(currentArgs, current.CrawlWindow, current.CrawlTimezone)
+	}
+
+	sets := make([]string, len(vars))
+	conditions := []string{}
+	condArgs := []interface{}{}
+	for i, v := range vars {
+		sets[i] = v + " = ?"
+		if v == "exclude_reason" {
+			continue
+		}
+		if reflect.ValueOf(currentArgs[i]).IsZero() {
+			conditions = append(conditions, v+" IN (?, null)")
 		} else {
-			buffer.WriteString(" = ?\n")
+			conditions = append(conditions, v+" = ?")
 		}
+		condArgs = append(condArgs, currentArgs[i])
 	}
-	buffer.WriteString("WHERE dom = ?\n")
-	args = append(args, domain)
-	query := buffer.String()
+	cql := `UPDATE domain_info SET ` + strings.Join(sets, ", ") + ` WHERE dom = ? IF ` +
+		strings.Join(conditions, " AND ")
 
-	err := ds.db.Query(query, args...).Exec()
-	return err
+	qargs := append(append(append([]interface{}{}, args...), current.Domain), condArgs...)
+	casMap := map[string]interface{}{}
+	return ds.db.Query(cql, qargs...).MapScanCAS(casMap)
+}
+
+// excludeDomainsPageSize is the page size used when scanning domain_info in
+// ExcludeDomainsMatching
+var excludeDomainsPageSize = 1000
+
+// ExcludeDomainsMatching is documented on the ModelDatastore interface.
+func (ds *Datastore) ExcludeDomainsMatching(pattern string, reason string) (int, error) {
+	if reason == "" {
+		return 0, fmt.Errorf("ExcludeDomainsMatching requires an exclude reason")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, fmt.Errorf("Bad domain pattern %q: %v", pattern, err)
+	}
+
+	// Domains are excluded as we go rather than collected first, so we never
+	// hold more than a page of domain_info in memory
+	itr := ds.read(`SELECT dom FROM domain_info`).PageSize(excludeDomainsPageSize).Iter()
+	count := 0
+	var dom string
+	for itr.Scan(&dom) {
+		if !re.MatchString(dom) {
+			continue
+		}
+		err := ds.db.Query(`UPDATE domain_info SET excluded = true, exclude_reason = ? WHERE dom = ?`,
+			reason, dom).Exec()
+		if err != nil {
+			itr.Close()
+			return count, fmt.Errorf("Failed to exclude %v: %v", dom, err)
+		}
+		log4go.Debug("Excluded domain %v: %v", dom, reason)
+		count++
+	}
+	if err := itr.Close(); err != nil {
+		return count, fmt.Errorf("domain_info scan failed: %v", err)
+	}
+	return count, nil
+}
+
+// PauseDomain is documented on the ModelDatastore interface.
+func (ds *Datastore) PauseDomain(domain string) error {
+	return ds.setDomainPaused(domain, true)
+}
+
+// ResumeDomain is documented on the ModelDatastore interface.
+func (ds *Datastore) ResumeDomain(domain string) error {
+	return ds.setDomainPaused(domain, false)
+}
+
+func (ds *Datastore) setDomainPaused(domain string, paused bool) error {
+	err := ds.db.Query(`UPDATE domain_info SET paused = ? WHERE dom = ?`, paused, domain).Exec()
+	if err != nil {
+		return fmt.Errorf("Failed to set paused = %v for %v: %v", paused, domain, err)
+	}
+	if !paused && strings.ToLower(walker.Config.Cassandra.ClaimStrategy) == "weighted" {
+		if err := ds.requeueForClaim(domain); err != nil {
+			return fmt.Errorf("Failed to queue resumed domain %v for claiming: %v", domain, err)
+		}
+	}
+	return nil
+}
+
+// SetTrapExcluded is documented on the ModelDatastore interface.
+func (ds *Datastore) SetTrapExcluded(domain string, excluded bool) error {
+	var accepted []string
+	if excluded {
+		err := ds.db.Query(`SELECT trap_patterns FROM domain_info WHERE dom = ?`, domain).Scan(&accepted)
+		if err != nil {
+			return fmt.Errorf("Failed to read trap patterns of %v: %v", domain, err)
+		}
+	}
+	err := ds.db.Query(`UPDATE domain_info SET trap_excluded = ?, trap_accepted = ? WHERE dom = ?`,
+		excluded, accepted, domain).Exec()
+	if err != nil {
+		return fmt.Errorf("Failed to set trap_excluded = %v for %v: %v", excluded, domain, err)
+	}
+	return nil
+}
+
+// MarkGetNow is documented on the ModelDatastore interface.
+func (ds *Datastore) MarkGetNow(u *walker.URL) error {
+	dom, subdom, path, proto, err := u.PrimaryKey()
+	if err != nil {
+		return err
+	}
+
+	// getnow is read from the most recent row of a link
+	var crawlTime time.Time
+	err = ds.read(`SELECT time FROM links
+						WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?
+						ORDER BY time DESC LIMIT 1`,
+		dom, subdom, path, proto).Scan(&crawlTime)
+	if err == gocql.ErrNotFound {
+		// A new link; the update below inserts it
+		if !ds.hasDomain(context.Background(), dom) {
+			if err := ds.addDomainWithExcludeReason(dom, ""); err != nil {
+				return fmt.Errorf("Failed to add domain %v: %v", dom, err)
+			}
+		}
+		crawlTime = walker.NotYetCrawled
+	} else if err != nil {
+		return fmt.Errorf("Failed to find %v: %v", u, err)
+	}
+
+	err = ds.db.Query(`UPDATE links SET getnow = true
+						WHERE dom = ? AND subdom = ? AND path = ? AND proto = ? AND time = ?`,
+		dom, subdom, path, proto, crawlTime).Exec()
+	if err != nil {
+		return fmt.Errorf("Failed to set getnow for %v: %v", u, err)
+	}
+	return nil
+}
+
+// pendingDomainsBatch caps how many domains ListPendingDomains looks up in
+// domain_info per query
+const pendingDomainsBatch = 100
+
+// ListPendingDomains is documented on the ModelDatastore interface.
+func (ds *Datastore) ListPendingDomains(limit int) ([]*PendingDomain, error) {
+	itr := ds.read(`SELECT dom, refs FROM pending_domains`).Iter()
+	var pending []*PendingDomain
+	var dom string
+	var refs int64
+	for itr.Scan(&dom, &refs) {
+		pending = append(pending, &PendingDomain{Domain: dom, References: refs})
+	}
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("pending_domains scan failed: %v", err)
+	}
+
+	// Fetchers that cached a domain as unknown before it was resolved can
+	// still count references to it; skip those rather than list it again
+	known := map[string]bool{}
+	for start := 0; start < len(pending); start += pendingDomainsBatch {
+		end := start + pendingDomainsBatch
+		if end > len(pending) {
+			end = len(pending)
+		}
+		doms := make([]string, 0, end-start)
+		for _, p := range pending[start:end] {
+			doms = append(doms, p.Domain)
+		}
+		itr := ds.read(`SELECT dom FROM domain_info WHERE dom IN ?`, doms).Iter()
+		for itr.Scan(&dom) {
+			known[dom] = true
+		}
+		if err := itr.Close(); err != nil {
+			return nil, fmt.Errorf("Failed to check which pending domains are in domain_info: %v", err)
+		}
+	}
+	kept := 0
+	for _, p := range pending {
+		if !known[p.Domain] {
+			pending[kept] = p
+			kept++
+		}
+	}
+	pending = pending[:kept]
+
+	sort.Sort(byReferences(pending))
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+// byReferences sorts PendingDomains most referenced first
+type byReferences []*PendingDomain
+
+func (b byReferences) Len() int      { return len(b) }
+func (b byReferences) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byReferences) Less(i, j int) bool {
+	if b[i].References != b[j].References {
+		return b[i].References > b[j].References
+	}
+	return b[i].Domain < b[j].Domain
+}
+
+// ApprovePendingDomain is documented on the ModelDatastore interface.
+func (ds *Datastore) ApprovePendingDomain(domain string) error {
+	return ds.resolvePendingDomain(domain, "")
+}
+
+// RejectPendingDomain is documented on the ModelDatastore interface.
+func (ds *Datastore) RejectPendingDomain(domain string, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("RejectPendingDomain requires an exclude reason")
+	}
+	return ds.resolvePendingDomain(domain, reason)
+}
+
+// resolvePendingDomain adds domain to domain_info, excluded if reason is not
+// empty (or, if it already exists, updates its exclusion to match), and drops
+// it from pending_domains.
+func (ds *Datastore) resolvePendingDomain(domain string, reason string) error {
+	if err := ds.addDomainWithExcludeReason(domain, reason); err != nil {
+		return fmt.Errorf("Failed to add pending domain %v: %v", domain, err)
+	}
+	err := ds.db.Query(`DELETE FROM pending_domains WHERE dom = ?`, domain).Exec()
+	if err != nil {
+		return fmt.Errorf("Failed to remove pending domain %v: %v", domain, err)
+	}
+	return nil
 }
 
 //
@@ -709,181 +2048,409 @@ type rememberTimes struct {
 }
 
 func (ds *Datastore) FindLink(u *walker.URL, collectContent bool) (*LinkInfo, error) {
-	tld1, subtld1, err := u.TLDPlusOneAndSubdomain()
+	return ds.findLink(u, collectContent, time.Time{})
+}
+
+// FindLinkAsOf is documented on the ModelDatastore interface.
+func (ds *Datastore) FindLinkAsOf(u *walker.URL, t time.Time) (*LinkInfo, error) {
+	return ds.findLink(u, false, t)
+}
+
+// findLink implements FindLink, only considering rows with crawl times not
+// after asOf unless it is zero
+func (ds *Datastore) findLink(u *walker.URL, collectContent bool, asOf time.Time) (*LinkInfo, error) {
+	dom, subdom, path, proto, err := u.PrimaryKey()
 	if err != nil {
 		return nil, err
 	}
 
 	extraSelect := ""
 	if collectContent {
-		extraSelect = ", body, headers "
+		extraSelect = ", body, body_z, headers "
+	}
+	extraWhere := ""
+	args := []interface{}{dom, subdom, path, proto}
+	if !asOf.IsZero() {
+		extraWhere = " AND time <= ?"
+		args = append(args, asOf)
 	}
 
-	itr := ds.db.Query(
-		`SELECT dom, subdom, path, proto, time, stat, err, robot_ex `+
+	itr := ds.read(
+		`SELECT dom, subdom, path, proto, time, stat, err, robot_ex, nofollow, js_redirect, title, description, `+
+			`ref_first, ref_last `+
 			extraSelect+
 			"FROM links "+
 			"WHERE dom = ? AND"+
 			"	  subdom = ? AND"+
 			"     path = ? AND"+
-			"     proto = ?", tld1, subtld1, u.RequestURI(), u.Scheme).Iter()
+			"     proto = ?"+extraWhere, args...).Iter()
 	rtimes := map[string]rememberTimes{}
 	linfos, err := ds.collectLinkInfos(nil, rtimes, itr, 1, nil, collectContent)
 	if err != nil {
 		itr.Close()
 		return nil, err
 	}
-
-	err = itr.Close()
-	if err != nil {
+
+	err = itr.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(linfos) == 0 {
+		return nil, nil
+	}
+
+	linfo := linfos[0]
+	if collectContent && linfo.Status == http.StatusNotModified && linfo.Body == "" {
+		c, err := ds.crawlAt(u, linfo.CrawlTime)
+		if err != nil {
+			return nil, err
+		}
+		linfo.Body = c.body
+	}
+	return linfo, nil
+}
+
+// Pagination note:
+// ListLinks reads a domain's partition of the links table in primary key
+// order (subdom, path, proto, then crawl_time). A page ends after the last
+// row of a link, and the page token handed back encodes that link's position.
+// The next page then reads the rows after it with one multi-column slice:
+//
+//   SELECT ... FROM links WHERE dom = ? AND (subdom, path, proto) > (?, ?, ?)
+//
+// The position is taken from the row as stored, rather than worked out again
+// from a URL, so it does not depend on the URL parsing the same way twice.
+
+// linkPosition is the position in a domain's links partition a page token
+// encodes
+type linkPosition struct {
+	Dom    string `json:"d"`
+	Subdom string `json:"s"`
+	Path   string `json:"p"`
+	Proto  string `json:"r"`
+
+	// For pages not sorted by path: the sort, and how many sorted links
+	// came before this position
+	Sort   LinkSort `json:"o,omitempty"`
+	Offset int      `json:"n,omitempty"`
+}
+
+// pageTokenEncoding is used for page tokens so that they can be put in a URL
+// path as they are
+var pageTokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// token returns the page token listing the links after pos
+func (pos linkPosition) token() string {
+	b, err := json.Marshal(pos)
+	if err != nil {
+		panic(err) // This won't happen b/c linkPosition only has strings
+	}
+	return pageTokenEncoding.EncodeToString(b)
+}
+
+// after returns true if pos comes after other in the clustering order of the
+// links table
+func (pos linkPosition) after(other linkPosition) bool {
+	if pos.Subdom != other.Subdom {
+		return pos.Subdom > other.Subdom
+	}
+	if pos.Path != other.Path {
+		return pos.Path > other.Path
+	}
+	return pos.Proto > other.Proto
+}
+
+// parsePageToken returns the position a page token encodes
+func parsePageToken(token string) (*linkPosition, error) {
+	b, err := pageTokenEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("Bad page token %q: %v", token, err)
+	}
+	pos := &linkPosition{}
+	if err := json.Unmarshal(b, pos); err != nil || pos.Dom == "" {
+		return nil, fmt.Errorf("Bad page token %q", token)
+	}
+	return pos, nil
+}
+
+// LinkPageToken returns a page token for ListLinks that lists the links of
+// u's domain starting just after u
+func LinkPageToken(u *walker.URL) (string, error) {
+	dom, sub, path, proto, err := u.PrimaryKey()
+	if err != nil {
+		return "", err
+	}
+	return linkPosition{Dom: dom, Subdom: sub, Path: path, Proto: proto}.token(), nil
+}
+
+// pushdown returns conditions on the rows of the links table, with their
+// values, that the most recent row of every link accepted by query's filters
+// also meets, so Cassandra can skip the links that cannot match. Only the
+// filters testing a regular column for a single value can be pushed down.
+func (query LQ) pushdown() ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if query.StatusFilter != 0 {
+		conds = append(conds, "stat = ?")
+		args = append(args, query.StatusFilter)
+	}
+	if query.RobotsExcluded != nil && *query.RobotsExcluded {
+		conds = append(conds, "robot_ex = ?")
+		args = append(args, true)
+	}
+	return conds, args
+}
+
+// filter returns a function accepting only the links matching query's
+// filters, or nil if it has none
+func (query LQ) filter() (func(*LinkInfo) bool, error) {
+	var re *regexp.Regexp
+	if query.FilterRegex != "" {
+		var err error
+		re, err = regexp.Compile(query.FilterRegex)
+		if err != nil {
+			return nil, fmt.Errorf("FilterRegex compile error: %v", err)
+		}
+	}
+	if re == nil && query.StatusFilter == 0 && !query.Dead && query.HasError == nil && query.RobotsExcluded == nil &&
+		query.CrawledAfter.IsZero() && query.CrawledBefore.IsZero() {
+		return nil, nil
+	}
+
+	return func(linfo *LinkInfo) bool {
+		if re != nil && !re.MatchString(linfo.URL.String()) {
+			return false
+		}
+		if query.StatusFilter != 0 && linfo.Status != query.StatusFilter {
+			return false
+		}
+		if query.Dead && !IsDeadStatus(linfo.Status) {
+			return false
+		}
+		if query.HasError != nil && (linfo.Error != "") != *query.HasError {
+			return false
+		}
+		if query.RobotsExcluded != nil && linfo.RobotsExcluded != *query.RobotsExcluded {
+			return false
+		}
+		if !query.CrawledAfter.IsZero() && linfo.CrawlTime.Before(query.CrawledAfter) {
+			return false
+		}
+		if !query.CrawledBefore.IsZero() && !linfo.CrawlTime.Before(query.CrawledBefore) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func (ds *Datastore) ListLinks(domain string, query LQ) (*LinkPage, error) {
+	if query.Limit <= 0 {
+		return nil, fmt.Errorf("Bad value for limit parameter %d", query.Limit)
+	}
+
+	filter, err := query.filter()
+	if err != nil {
+		return nil, err
+	}
+	// Count the links read and matched, to estimate the total from
+	examined, matched := 0, 0
+	acceptLink := func(linfo *LinkInfo) bool {
+		examined++
+		if filter != nil && !filter(linfo) {
+			return false
+		}
+		matched++
+		return true
+	}
+
+	sorted := query.Sort != "" && query.Sort != SortByPath
+	switch query.Sort {
+	case "", SortByPath, SortByCrawlTime, SortByStatus:
+	default:
+		return nil, fmt.Errorf("Bad value for sort parameter %q", query.Sort)
+	}
+
+	var pos *linkPosition
+	if query.PageToken != "" {
+		pos, err = parsePageToken(query.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		if pos.Dom != domain {
+			return nil, fmt.Errorf("Page token is for %v, not %v", pos.Dom, domain)
+		}
+		if sorted != (pos.Sort != "") || (sorted && pos.Sort != query.Sort) {
+			return nil, fmt.Errorf("Page token is not for sort %q", query.Sort)
+		}
+	}
+	if sorted {
+		return ds.listSortedLinks(domain, query, pos, filter)
+	}
+
+	if conds, condArgs := query.pushdown(); len(conds) > 0 {
+		cands, err := ds.collectLinkCandidates(domain, pos, conds, condArgs, query.Limit, acceptLink)
+		if err != nil {
+			return nil, err
+		}
+		page := &LinkPage{Links: cands.linfos}
+		if len(cands.linfos) >= query.Limit {
+			page.NextPageToken = cands.linfos[len(cands.linfos)-1].position.token()
+		}
+		page.EstimatedTotal = len(cands.linfos)
+		if examined > 0 {
+			unread := cands.before + cands.after
+			page.EstimatedTotal += int(float64(unread) * float64(matched) / float64(examined))
+		}
+		return page, nil
+	}
+
+	var itr *gocql.Iter
+	if pos == nil {
+		itr = ds.read(`SELECT dom, subdom, path, proto, time, stat, err, robot_ex, nofollow, js_redirect, title, description,
+				ref_first, ref_last
+			FROM links
+			WHERE dom = ?`, domain).Iter()
+	} else {
+		itr = ds.read(`SELECT dom, subdom, path, proto, time, stat, err, robot_ex, nofollow, js_redirect, title, description,
+				ref_first, ref_last
+			FROM links
+			WHERE dom = ? AND (subdom, path, proto) > (?, ?, ?)`,
+			domain, pos.Subdom, pos.Path, pos.Proto).Iter()
+	}
+	linfos, err := ds.collectLinkInfos(nil, map[string]rememberTimes{}, itr, query.Limit, acceptLink, false)
+	if err != nil {
+		itr.Close()
+		return nil, err
+	}
+	if err := itr.Close(); err != nil {
 		return nil, err
 	}
 
-	if len(linfos) == 0 {
-		return nil, nil
+	page := &LinkPage{Links: linfos}
+	if len(linfos) >= query.Limit {
+		page.NextPageToken = linfos[len(linfos)-1].position.token()
 	}
 
-	return linfos[0], nil
-}
-
-// Pagination note:
-// To paginate a single column you can do
-//
-//   SELECT a FROM table WHERE a > startingA
-//
-// If you have two columns though, it requires two queries
-//
-//   SELECT a,b from table WHERE a == startingA AND b > startingB
-//   SELECT a,b from table WHERE a > startingA
-//
-// With 3 columns it looks like this
-//
-//   SELECT a,b,c FROM table WHERE a == startingA AND b == startingB AND c > startingC
-//   SELECT a,b,c FROM table WHERE a == startingA AND b > startingB
-//   SELECT a,b,c FROM table WHERE a > startingA
-//
-// Particularly for our links table, with primary key domain, subdomain, path, protocol, crawl_time
-// For right now, ignore the crawl time we write
-//
-// SELECT * FROM links WHERE domain = startDomain AND subdomain = startSubDomain AND path = startPath
-//                           AND protocol > startProtocol
-// SELECT * FROM links WHERE domain = startDomain AND subdomain = startSubDomain AND path > startPath
-// SELECT * FROM links WHERE domain = startDomain AND subdomain > startSubDomain
-//
-// Now the only piece left, is that crawl_time is part of the primary key. Generally we're only going to take the latest
-// crawl time. But see Historical query
-//
-
-// queryEntry is a helper struct for the layered queries in ListLinks
-type queryEntry struct {
-	query string
-	args  []interface{}
-}
-
-func (ds *Datastore) ListLinks(domain string, query LQ) ([]*LinkInfo, error) {
-	if query.Limit <= 0 {
-		return nil, fmt.Errorf("Bad value for limit parameter %d", query.Limit)
+	if pos == nil && page.NextPageToken == "" {
+		page.EstimatedTotal = len(linfos)
+		return page, nil
 	}
-
-	var acceptLink func(string) bool
-	if query.FilterRegex != "" {
-		re, err := regexp.Compile(query.FilterRegex)
-		if err != nil {
-			return nil, fmt.Errorf("FilterRegex compile error: %v", err)
-		}
-		acceptLink = func(s string) bool {
-			return re.MatchString(s)
-		}
+	var total int
+	err = ds.read(`SELECT tot_links FROM domain_info WHERE dom = ?`, domain).Scan(&total)
+	if err != nil && err != gocql.ErrNotFound {
+		return nil, err
+	}
+	if examined > 0 {
+		page.EstimatedTotal = int(float64(total) * float64(matched) / float64(examined))
 	}
+	if page.EstimatedTotal < len(linfos) {
+		page.EstimatedTotal = len(linfos)
+	}
+	return page, nil
+}
 
-	var linfos []*LinkInfo
-	rtimes := map[string]rememberTimes{}
-	var table []queryEntry
+// listSortedLinks lists the page of the links of domain after pos when they
+// are sorted by query.Sort. Cassandra can only return a partition in
+// clustering order, and the latest row of each link is not known until all
+// of its rows are read, so the links are read and sorted here.
+func (ds *Datastore) listSortedLinks(domain string, query LQ, pos *linkPosition,
+	filter func(*LinkInfo) bool) (*LinkPage, error) {
 
-	if query.Seed == nil {
-		table = []queryEntry{
-			queryEntry{
-				query: `SELECT dom, subdom, path, proto, time, stat, err, robot_ex
-                      FROM links 
-                      WHERE dom = ?`,
-				args: []interface{}{domain},
-			},
+	// Read one more than the cap to tell whether there were more
+	maxSortedLinks := walker.Config.Console.MaxSortedLinks
+	var linfos []*LinkInfo
+	if conds, condArgs := query.pushdown(); len(conds) > 0 {
+		cands, err := ds.collectLinkCandidates(domain, nil, conds, condArgs, maxSortedLinks+1, filter)
+		if err != nil {
+			return nil, err
 		}
+		linfos = cands.linfos
 	} else {
-		dom, sub, err := query.Seed.TLDPlusOneAndSubdomain()
+		itr := ds.read(`SELECT dom, subdom, path, proto, time, stat, err, robot_ex, nofollow, js_redirect, title,
+				description, ref_first, ref_last
+			FROM links
+			WHERE dom = ?`, domain).Iter()
+		var err error
+		linfos, err = ds.collectLinkInfos(nil, map[string]rememberTimes{}, itr, maxSortedLinks+1, filter, false)
 		if err != nil {
-			return linfos, err
+			itr.Close()
+			return nil, err
 		}
-
-		pat := query.Seed.RequestURI()
-		pro := query.Seed.Scheme
-
-		table = []queryEntry{
-			queryEntry{
-				query: `SELECT dom, subdom, path, proto, time, stat, err, robot_ex
-                      FROM links 
-                      WHERE dom = ? AND 
-                            subdom = ? AND 
-                            path = ? AND 
-                            proto > ?`,
-				args: []interface{}{dom, sub, pat, pro},
-			},
-			queryEntry{
-				query: `SELECT dom, subdom, path, proto, time, stat, err, robot_ex 
-                      FROM links 
-                      WHERE dom = ? AND subdom = ? AND 
-                            path > ?`,
-				args: []interface{}{dom, sub, pat},
-			},
-			queryEntry{
-				query: `SELECT dom, subdom, path, proto, time, stat, err, robot_ex 
-                      FROM links 
-                      WHERE dom = ? AND 
-                            subdom > ?`,
-				args: []interface{}{dom, sub},
-			},
+		if err := itr.Close(); err != nil {
+			return nil, err
 		}
 	}
+	truncated := len(linfos) > maxSortedLinks
+	if truncated {
+		log4go.Warn("Sorting only the first %d links of %v", maxSortedLinks, domain)
+		linfos = linfos[:maxSortedLinks]
+	}
 
-	var err error
-	for _, qt := range table {
-		itr := ds.db.Query(qt.query, qt.args...).Iter()
-		linfos, err = ds.collectLinkInfos(linfos, rtimes, itr, query.Limit, acceptLink, false)
-		if err != nil {
-			return linfos, err
+	var less func(a, b *LinkInfo) bool
+	switch query.Sort {
+	case SortByCrawlTime:
+		less = func(a, b *LinkInfo) bool {
+			return a.CrawlTime.After(b.CrawlTime)
 		}
-
-		err = itr.Close()
-		if err != nil {
-			return linfos, err
-		} else if len(linfos) >= query.Limit {
-			return linfos, nil
+	case SortByStatus:
+		less = func(a, b *LinkInfo) bool {
+			// Links without a status (never fetched, or failed) go last
+			if a.Status == 0 || b.Status == 0 {
+				return a.Status != 0 && b.Status == 0
+			}
+			return a.Status < b.Status
 		}
 	}
+	sort.SliceStable(linfos, func(i, j int) bool { return less(linfos[i], linfos[j]) })
 
-	return linfos, nil
+	offset := 0
+	if pos != nil {
+		offset = pos.Offset
+	}
+	if offset > len(linfos) {
+		offset = len(linfos)
+	}
+	end := offset + query.Limit
+	if end > len(linfos) {
+		end = len(linfos)
+	}
+
+	page := &LinkPage{Links: linfos[offset:end], EstimatedTotal: len(linfos), Truncated: truncated}
+	if end < len(linfos) {
+		page.NextPageToken = linkPosition{Dom: domain, Sort: query.Sort, Offset: end}.token()
+	}
+	return page, nil
 }
 
 func (ds *Datastore) ListLinkHistorical(u *walker.URL) ([]*LinkInfo, error) {
 	query := `SELECT dom, subdom, path, proto, time, stat,
-						err, robot_ex, redto_url, getnow, mime, fnv
+						err, robot_ex, precheck_skip, trunc, redto_url, getnow, nofollow, js_redirect, mime, content_lang,
+						fnv, fnv_txt, sha256, simhash, timing, title, description, req_headers, ref_first, ref_last,
+						crawl_id
               FROM links
               WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?`
-	tld1, subtld1, err := u.TLDPlusOneAndSubdomain()
+	keyDom, keySubdom, keyPath, keyProto, err := u.PrimaryKey()
 	if err != nil {
 		return nil, err
 	}
 
-	itr := ds.db.Query(query, tld1, subtld1, u.RequestURI(), u.Scheme).Iter()
+	itr := ds.read(query, keyDom, keySubdom, keyPath, keyProto).Iter()
 
 	var linfos []*LinkInfo
-	var dom, sub, path, prot, getError, mime, redtoURL string
+	var dom, sub, path, prot, getError, mime, contentLang, redtoURL, title, description, refFirst, refLast string
+	var crawlID string
 	var crawlTime time.Time
 	var status int
-	var fnvFP int64
-	var robotsExcluded, getnow bool
+	var fnvFP, fnvTextFP, simhash int64
+	var sha256 []byte
+	var robotsExcluded, precheckSkip, truncated, getnow, nofollow, jsRedirect bool
+	var timing map[string]int64
+	var reqHeaders map[string]string
 	for itr.Scan(&dom, &sub, &path, &prot, &crawlTime, &status,
-		&getError, &robotsExcluded, &redtoURL, &getnow, &mime, &fnvFP) {
+		&getError, &robotsExcluded, &precheckSkip, &truncated, &redtoURL, &getnow, &nofollow, &jsRedirect, &mime,
+		&contentLang, &fnvFP, &fnvTextFP, &sha256, &simhash, &timing, &title, &description, &reqHeaders, &refFirst, &refLast,
+		&crawlID) {
 		// If we need pagination here at some point...
 		//if count < seedIndex {
 		//	count++
@@ -897,13 +2464,35 @@ func (ds *Datastore) ListLinkHistorical(u *walker.URL) ([]*LinkInfo, error) {
 			Error:              getError,
 			CrawlTime:          crawlTime,
 			RobotsExcluded:     robotsExcluded,
+			SkippedByPrecheck:  precheckSkip,
+			Truncated:          truncated,
 			RedirectedTo:       redtoURL,
 			GetNow:             getnow,
+			Nofollow:           nofollow,
+			JSRedirect:         jsRedirect,
+			FirstReferrer:      refFirst,
+			LastReferrer:       refLast,
 			Mime:               mime,
+			ContentLanguage:    contentLang,
+			CrawlIdentity:      crawlID,
 			FnvFingerprint:     fnvFP,
-			FnvTextFingerprint: fnvFP,
+			FnvTextFingerprint: fnvTextFP,
+			SHA256Fingerprint:  sha256,
+			SimHashFingerprint: simhash,
+			Timing:             timingFromMap(timing),
+			Title:              title,
+			Description:        description,
+		}
+		if reqHeaders != nil {
+			linfo.RequestHeaders = http.Header{}
+			for k, v := range reqHeaders {
+				linfo.RequestHeaders[k] = strings.Split(v, "\000")
+			}
 		}
 		linfos = append(linfos, linfo)
+		timing = nil
+		reqHeaders = nil
+		sha256 = nil
 
 		//if len(linfos) >= limit {
 		//	break
@@ -914,6 +2503,150 @@ func (ds *Datastore) ListLinkHistorical(u *walker.URL) ([]*LinkInfo, error) {
 	return linfos, err
 }
 
+// storedCrawl is the content of one crawl of a link, as read by crawlAt
+type storedCrawl struct {
+	status      int
+	fnv         int64
+	fnvText     int64
+	sha256      []byte
+	simhash     int64
+	mime        string
+	contentLang string
+	title       string
+	description string
+	body        string
+
+	// Set if this crawl was a 304 Not Modified and body was read from the
+	// earlier crawl at bodyTime
+	bodyTime time.Time
+}
+
+// crawlAt reads the crawl of u made at crawlTime
+func (ds *Datastore) crawlAt(u *walker.URL, crawlTime time.Time) (*storedCrawl, error) {
+	c, err := ds.crawlMetaAt(u, crawlTime)
+	if err != nil {
+		return nil, err
+	}
+	bodyTime := c.bodyTime
+	if bodyTime.IsZero() {
+		bodyTime = crawlTime
+	}
+	c.body, err = ds.bodyAt(u, bodyTime)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// crawlMetaAt reads the crawl of u made at crawlTime like crawlAt, but leaves
+// out the body
+func (ds *Datastore) crawlMetaAt(u *walker.URL, crawlTime time.Time) (*storedCrawl, error) {
+	dom, subdom, path, proto, err := u.PrimaryKey()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &storedCrawl{}
+	err = ds.read(`SELECT stat, fnv, fnv_txt, sha256, simhash, mime, content_lang, title, description, body_time
+					FROM links WHERE dom = ? AND subdom = ? AND path = ? AND proto = ? AND time = ?`,
+		dom, subdom, path, proto, crawlTime).Scan(&c.status, &c.fnv, &c.fnvText, &c.sha256,
+		&c.simhash, &c.mime, &c.contentLang, &c.title, &c.description, &c.bodyTime)
+	if err == gocql.ErrNotFound {
+		return nil, fmt.Errorf("%v was not crawled at %v", u, crawlTime)
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read crawl of %v at %v: %v", u, crawlTime, err)
+	}
+	return c, nil
+}
+
+// bodyAt reads the body stored for the crawl of u made at crawlTime
+func (ds *Datastore) bodyAt(u *walker.URL, crawlTime time.Time) (string, error) {
+	dom, subdom, path, proto, err := u.PrimaryKey()
+	if err != nil {
+		return "", err
+	}
+	var body string
+	var bodyZ []byte
+	err = ds.read(`SELECT body, body_z FROM links WHERE dom = ? AND subdom = ? AND path = ? AND proto = ? AND time = ?`,
+		dom, subdom, path, proto, crawlTime).Scan(&body, &bodyZ)
+	if err != nil && err != gocql.ErrNotFound {
+		return "", fmt.Errorf("Failed to read body of %v at %v: %v", u, crawlTime, err)
+	}
+	body, err = decodeBody(body, bodyZ)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read body of %v at %v: %v", u, crawlTime, err)
+	}
+	return body, nil
+}
+
+// DiffLink is documented on the ModelDatastore interface.
+func (ds *Datastore) DiffLink(u *walker.URL, t1, t2 time.Time) (*LinkDiff, error) {
+	before, err := ds.crawlAt(u, t1)
+	if err != nil {
+		return nil, err
+	}
+	after, err := ds.crawlAt(u, t2)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &LinkDiff{
+		URL:          u,
+		Before:       t1,
+		After:        t2,
+		StatusBefore: before.status,
+		StatusAfter:  after.status,
+		Changed:      before.fnv != after.fnv,
+		TextChanged:  before.fnvText != after.fnvText,
+		HasBodies:    before.body != "" && after.body != "",
+	}
+	if d.HasBodies {
+		d.SizeBefore = len(before.body)
+		d.SizeAfter = len(after.body)
+		d.LinesAdded, d.LinesRemoved, d.LinesUnchanged = diffLines(bodyLines(before), bodyLines(after))
+	}
+	return d, nil
+}
+
+// bodyLines splits the text of a stored body into its non-blank lines. HTML
+// bodies are parsed first so only the page text is compared.
+func bodyLines(c *storedCrawl) []string {
+	text := c.body
+	if strings.Contains(c.mime, "html") {
+		p := &walker.HTMLParser{}
+		p.Parse([]byte(c.body))
+		text = string(p.Text)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// diffLines counts the lines only in after (added), only in before (removed)
+// and in both (unchanged). Repeated lines are matched up one for one.
+func diffLines(before, after []string) (added, removed, unchanged int) {
+	counts := map[string]int{}
+	for _, line := range before {
+		counts[line]++
+	}
+	for _, line := range after {
+		if counts[line] > 0 {
+			counts[line]--
+			unchanged++
+		} else {
+			added++
+		}
+	}
+	removed = len(before) - unchanged
+	return
+}
+
 func (ds *Datastore) InsertLink(link string, excludeDomainReason string) error {
 	errors := ds.InsertLinks([]string{link}, excludeDomainReason)
 	if len(errors) > 0 {
@@ -923,6 +2656,16 @@ func (ds *Datastore) InsertLink(link string, excludeDomainReason string) error {
 }
 
 func (ds *Datastore) InsertLinks(links []string, excludeDomainReason string) []error {
+	return ds.InsertLinksWithTags(links, excludeDomainReason, nil)
+}
+
+// InsertLinksWithTags is documented on the ModelDatastore interface.
+func (ds *Datastore) InsertLinksWithTags(links []string, excludeDomainReason string, tags []string) []error {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return []error{err}
+	}
+
 	//
 	// Collect domains
 	//
@@ -977,18 +2720,25 @@ func (ds *Datastore) InsertLinks(links []string, excludeDomainReason string) []e
 				errList = append(errList, fmt.Errorf("%v # add domain: %v", link, err))
 				continue
 			}
+			if len(tags) > 0 {
+				err = db.Query(`UPDATE domain_info SET tags = tags + ? WHERE dom = ?`, tags, d).Exec()
+				if err != nil {
+					errList = append(errList, fmt.Errorf("%v # tag domain: %v", link, err))
+					continue
+				}
+			}
 		}
 		seen[d] = true
 
-		subdom, err := u.Subdomain()
+		_, subdom, path, proto, err := u.PrimaryKey()
 		if err != nil {
-			errList = append(errList, fmt.Errorf("%v # Subdomain(): %v", link, err))
+			errList = append(errList, fmt.Errorf("%v # PrimaryKey(): %v", link, err))
 			continue
 		}
 
 		err = db.Query(`INSERT INTO links (dom, subdom, path, proto, time)
                                      VALUES (?, ?, ?, ?, ?)`, d, subdom,
-			u.RequestURI(), u.Scheme, walker.NotYetCrawled).Exec()
+			path, proto, walker.NotYetCrawled).Exec()
 		if err != nil {
 			errList = append(errList, fmt.Errorf("%v # `insert query`: %v", link, err))
 			continue
@@ -998,26 +2748,152 @@ func (ds *Datastore) InsertLinks(links []string, excludeDomainReason string) []e
 	return errList
 }
 
+// linkCandidateBatch is how many candidate links collectLinkCandidates reads
+// the rows of in one query
+var linkCandidateBatch = 100
+
+// linkCandidates is the result of collectLinkCandidates
+type linkCandidates struct {
+	linfos []*LinkInfo
+
+	// The number of candidates before the position listed from, and after
+	// the last one whose rows were read
+	before, after int
+}
+
+// collectLinkCandidates collects the links of domain after pos like
+// collectLinkInfos, but only reads the rows of the candidate links: those
+// with a row meeting conds (see LQ.pushdown), which Cassandra finds. The most
+// recent row of each candidate is then passed to accept as usual, since the
+// row that met conds may not be the most recent one.
+func (ds *Datastore) collectLinkCandidates(domain string, pos *linkPosition, conds []string, condArgs []interface{},
+	limit int, accept func(*LinkInfo) bool) (*linkCandidates, error) {
+
+	// Candidates are listed from the start of the partition, only to count
+	// the ones before pos
+	args := append([]interface{}{domain}, condArgs...)
+	itr := ds.read(`SELECT subdom, path, proto FROM links WHERE dom = ? AND `+strings.Join(conds, " AND ")+
+		` ALLOW FILTERING`, args...).Iter()
+
+	res := &linkCandidates{}
+	rtimes := map[string]rememberTimes{}
+	var batch []linkPosition
+	readBatch := func() error {
+		marks := make([]string, len(batch))
+		args := []interface{}{domain}
+		for i, p := range batch {
+			marks[i] = "(?, ?, ?)"
+			args = append(args, p.Subdom, p.Path, p.Proto)
+		}
+		itr := ds.read(`SELECT dom, subdom, path, proto, time, stat, err, robot_ex, nofollow, js_redirect, title,
+				description, ref_first, ref_last
+			FROM links
+			WHERE dom = ? AND (subdom, path, proto) IN (`+strings.Join(marks, ", ")+`)`, args...).Iter()
+		read := 0
+		counted := func(linfo *LinkInfo) bool {
+			read++
+			return accept == nil || accept(linfo)
+		}
+		var err error
+		res.linfos, err = ds.collectLinkInfos(res.linfos, rtimes, itr, limit, counted, false)
+		if err != nil {
+			itr.Close()
+			return err
+		}
+		if err := itr.Close(); err != nil {
+			return err
+		}
+		res.after += len(batch) - read
+		batch = batch[:0]
+		return nil
+	}
+
+	var last linkPosition
+	var sub, path, proto string
+	for itr.Scan(&sub, &path, &proto) {
+		p := linkPosition{Dom: domain, Subdom: sub, Path: path, Proto: proto}
+		if p == last {
+			continue
+		}
+		last = p
+		if pos != nil && !p.after(*pos) {
+			res.before++
+			continue
+		}
+		if len(res.linfos) >= limit {
+			res.after++
+			continue
+		}
+		batch = append(batch, p)
+		if len(batch) >= linkCandidateBatch {
+			if err := readBatch(); err != nil {
+				itr.Close()
+				return nil, err
+			}
+		}
+	}
+	if err := itr.Close(); err != nil {
+		return nil, err
+	}
+	if len(batch) > 0 {
+		if err := readBatch(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
 //collectLinkInfos populates a []LinkInfo list given a cassandra iterator. Arguments are described as:
 // (a) linfos is the list of LinkInfo's to build on
 // (b) rtimes is scratch space used to filter most recent link
 // (c) itr is a gocql.Iter instance to be read
 // (d) limit is the max length of linfos
-// (e) linkAccept is a func(string)bool. If linkAccept(linkText) returns false, the link IS NOT retained in linfos [
-//  This is used to implement filterRegex on ListLinks]
+// (e) accept is a func(*LinkInfo)bool. If accept(linfo) returns false for the most recent row of a link, the link IS
+//  NOT retained in linfos [This is used to implement the filters of ListLinks]
+//
+// The rows of a link must be read together, as they are when selecting from the links table in primary key order.
 func (ds *Datastore) collectLinkInfos(linfos []*LinkInfo, rtimes map[string]rememberTimes, itr *gocql.Iter, limit int,
-	linkAccept func(string) bool, collectContent bool) ([]*LinkInfo, error) {
-	var domain, subdomain, path, protocol, anerror string
+	accept func(*LinkInfo) bool, collectContent bool) ([]*LinkInfo, error) {
+	var domain, subdomain, path, protocol, anerror, title, description, refFirst, refLast string
 	var crawlTime time.Time
-	var robotsExcluded bool
+	var robotsExcluded, nofollow, jsRedirect bool
 	var status int
 	var body string
+	var bodyZ []byte
 	var headers map[string]string
 	var httpHeaders http.Header
 
-	args := []interface{}{&domain, &subdomain, &path, &protocol, &crawlTime, &status, &anerror, &robotsExcluded}
+	args := []interface{}{&domain, &subdomain, &path, &protocol, &crawlTime, &status, &anerror, &robotsExcluded,
+		&nofollow, &jsRedirect, &title, &description, &refFirst, &refLast}
 	if collectContent {
-		args = append(args, &body, &headers)
+		args = append(args, &body, &bodyZ, &headers)
+	}
+
+	// current is the most recent row seen so far of the link being read. It
+	// is only filtered (and kept) once all of that link's rows have been read.
+	var current *LinkInfo
+	finish := func() {
+		if current == nil {
+			return
+		}
+		linfo := current
+		current = nil
+		if accept != nil && !accept(linfo) {
+			return
+		}
+
+		urlString := linfo.URL.String()
+		qq, yes := rtimes[urlString]
+		if yes {
+			if qq.ctm.After(linfo.CrawlTime) {
+				return
+			}
+			linfos[qq.ind] = linfo
+		} else {
+			linfos = append(linfos, linfo)
+			qq.ind = len(linfos) - 1
+		}
+		rtimes[urlString] = rememberTimes{ctm: linfo.CrawlTime, ind: qq.ind}
 	}
 
 	for itr.Scan(args...) {
@@ -1025,15 +2901,9 @@ func (ds *Datastore) collectLinkInfos(linfos []*LinkInfo, rtimes map[string]reme
 		if err != nil {
 			return linfos, err
 		}
-		urlString := u.String()
-
-		if linkAccept != nil && !linkAccept(urlString) {
-			continue
-		}
-
-		qq, yes := rtimes[urlString]
 
-		if yes && qq.ctm.After(crawlTime) {
+		sameLink := current != nil && current.URL.String() == u.String()
+		if sameLink && current.CrawlTime.After(crawlTime) {
 			continue
 		}
 
@@ -1047,6 +2917,11 @@ func (ds *Datastore) collectLinkInfos(linfos []*LinkInfo, rtimes map[string]reme
 				}
 			}
 			headers = nil
+			body, err = decodeBody(body, bodyZ)
+			if err != nil {
+				return linfos, fmt.Errorf("Failed to read body of %v: %v", u, err)
+			}
+			bodyZ = nil
 		}
 
 		linfo := &LinkInfo{
@@ -1054,29 +2929,326 @@ func (ds *Datastore) collectLinkInfos(linfos []*LinkInfo, rtimes map[string]reme
 			Status:         status,
 			Error:          anerror,
 			RobotsExcluded: robotsExcluded,
+			Nofollow:       nofollow,
+			JSRedirect:     jsRedirect,
 			CrawlTime:      crawlTime,
+			Title:          title,
+			Description:    description,
+			FirstReferrer:  refFirst,
+			LastReferrer:   refLast,
 			Body:           body,
 			Headers:        httpHeaders,
+			position:       linkPosition{Dom: domain, Subdom: subdomain, Path: path, Proto: protocol},
 		}
 
-		nindex := -1
-		if yes {
-			nindex = qq.ind
-			linfos[qq.ind] = linfo
+		if sameLink {
+			// The nofollow and js_redirect flags and the referrers are only
+			// set on the not-yet-crawled row, so carry them over to later
+			// crawls of the link
+			linfo.Nofollow = linfo.Nofollow || current.Nofollow
+			linfo.JSRedirect = linfo.JSRedirect || current.JSRedirect
+			if linfo.FirstReferrer == "" {
+				linfo.FirstReferrer = current.FirstReferrer
+			}
+			if linfo.LastReferrer == "" {
+				linfo.LastReferrer = current.LastReferrer
+			}
 		} else {
+			finish()
 			// If you've reached the limit, then we're all done
 			if len(linfos) >= limit {
-				break
+				return linfos, nil
 			}
-			linfos = append(linfos, linfo)
-			nindex = len(linfos) - 1
 		}
-		rtimes[urlString] = rememberTimes{ctm: crawlTime, ind: nindex}
+		current = linfo
 	}
+	finish()
 
 	return linfos, nil
 }
 
+//
+// Aggregate calls
+//
+
+// numTopErrorDomains is how many domains CrawlOverview lists in
+// TopErrorDomains
+var numTopErrorDomains = 10
+
+// CrawlOverview is documented on the ModelDatastore interface.
+func (ds *Datastore) CrawlOverview() (*CrawlOverview, error) {
+	ov := &CrawlOverview{}
+
+	itr := ds.read(`SELECT dispatched, tot_links, uncrawled_links, queued_links FROM domain_info`).Iter()
+	var dispatched bool
+	var total, uncrawled, queued int
+	for itr.Scan(&dispatched, &total, &uncrawled, &queued) {
+		ov.NumberDomains++
+		if dispatched {
+			ov.NumberDomainsDispatched++
+		}
+		ov.NumberLinksTotal += total
+		ov.NumberLinksUncrawled += uncrawled
+		ov.NumberLinksQueued += queued
+	}
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("domain_info scan failed: %v", err)
+	}
+
+	err := ds.read(`SELECT COUNT(*) FROM active_fetchers`).Scan(&ov.NumberActiveFetchers)
+	if err != nil {
+		return nil, fmt.Errorf("active_fetchers count failed: %v", err)
+	}
+
+	ov.FetchesLastHour, err = ds.fetchesSince(time.Now().Add(-time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	ov.TopErrorDomains, err = ds.topErrorDomains(numTopErrorDomains)
+	if err != nil {
+		return nil, err
+	}
+
+	var p ScanProgress
+	err = ds.read(`SELECT iteration, started, shards, shards_done, scanned, queued, fraction, updated
+					FROM dispatcher_progress WHERE id = 0`).Scan(&p.Iteration, &p.Started, &p.Shards,
+		&p.ShardsDone, &p.Scanned, &p.Queued, &p.Fraction, &p.Updated)
+	if err == nil {
+		ov.Scan = &p
+	} else if err != gocql.ErrNotFound {
+		return nil, fmt.Errorf("dispatcher_progress query failed: %v", err)
+	}
+
+	return ov, nil
+}
+
+// domainFetchBucket is the width of the buckets in domain_fetch_counts
+const domainFetchBucket = 10 * time.Minute
+
+// frontierRateWindow is how far back FrontierEstimate looks to find the
+// recent fetch rate of a domain
+var frontierRateWindow = time.Hour
+
+// FrontierEstimate is documented on the ModelDatastore interface.
+func (ds *Datastore) FrontierEstimate(domain string) (*FrontierEstimate, error) {
+	est := &FrontierEstimate{Domain: domain}
+	itr := ds.read(`SELECT uncrawled_links, queued_links FROM domain_info WHERE dom = ?`, domain).Iter()
+	found := itr.Scan(&est.Uncrawled, &est.Queued)
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("domain_info query failed: %v", err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	now := time.Now()
+	start := now.Add(-frontierRateWindow)
+	itr = ds.read(`SELECT fetches FROM domain_fetch_counts WHERE dom = ? AND bucket >= ?`,
+		domain, start.Truncate(domainFetchBucket)).Iter()
+	var fetches int64
+	for itr.Scan(&fetches) {
+		est.RecentFetches += fetches
+	}
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("domain_fetch_counts query failed: %v", err)
+	}
+
+	if est.RecentFetches > 0 {
+		// The oldest bucket may begin before start, so measure from where it
+		// begins to get the rate right
+		window := now.Sub(start.Truncate(domainFetchBucket))
+		est.Rate = float64(est.RecentFetches) / window.Seconds()
+	} else {
+		delay, err := time.ParseDuration(walker.HotConfig().Fetcher.DefaultCrawlDelay)
+		if err != nil {
+			panic(err) // This won't happen b/c this duration is checked in Config
+		}
+		if delay > 0 {
+			est.Rate = 1.0 / delay.Seconds()
+		}
+		est.RateFromCrawlDelay = true
+	}
+
+	if est.Rate > 0 {
+		est.QueuedDrain = time.Duration(float64(est.Queued) / est.Rate * float64(time.Second))
+		est.UncrawledDrain = time.Duration(float64(est.Uncrawled) / est.Rate * float64(time.Second))
+		est.DrainedAt = now.Add(est.UncrawledDrain)
+	}
+	return est, nil
+}
+
+// fetchesSince sums the fetch_counts buckets from the one containing start up
+// to now
+func (ds *Datastore) fetchesSince(start time.Time) (int64, error) {
+	var buckets []time.Time
+	now := time.Now()
+	for b := start.Truncate(time.Minute); !b.After(now); b = b.Add(time.Minute) {
+		buckets = append(buckets, b)
+	}
+
+	itr := ds.read(`SELECT fetches FROM fetch_counts WHERE bucket IN ?`, buckets).Iter()
+	var total, fetches int64
+	for itr.Scan(&fetches) {
+		total += fetches
+	}
+	if err := itr.Close(); err != nil {
+		return 0, fmt.Errorf("fetch_counts query failed: %v", err)
+	}
+	return total, nil
+}
+
+// BandwidthUsage is documented on the ModelDatastore interface.
+func (ds *Datastore) BandwidthUsage(domain string) (*BandwidthUsage, error) {
+	usage := &BandwidthUsage{Domain: domain}
+	itr := ds.read(`SELECT byte_budget FROM domain_info WHERE dom = ?`, domain).Iter()
+	var override int64
+	found := itr.Scan(&override)
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("domain_info query failed: %v", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	usage.Budget = domainByteBudget(override)
+	usage.Window = domainByteBudgetWindow()
+
+	err := ds.read(`SELECT bytes FROM domain_counters WHERE dom = ?`, domain).Scan(&usage.TotalBytes)
+	if err != nil && err != gocql.ErrNotFound {
+		return nil, fmt.Errorf("domain_counters query failed: %v", err)
+	}
+
+	usage.WindowBytes, err = domainBytesSince(ds.db, domain, time.Now().Add(-usage.Window))
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// GetDomainConfig is documented on the ModelDatastore interface.
+func (ds *Datastore) GetDomainConfig(domain string) (*DomainConfig, error) {
+	itr := ds.read(`SELECT sample_threshold, sample_percent, byte_budget, crawl_window, crawl_timezone,
+						user_agent, crawl_delay
+						FROM domain_info WHERE dom = ?`, domain).Iter()
+	cfg := &DomainConfig{}
+	var crawlDelay int
+	found := itr.Scan(&cfg.SampleThreshold, &cfg.SamplePercent, &cfg.ByteBudget, &cfg.CrawlWindow, &cfg.CrawlTimezone,
+		&cfg.UserAgent, &crawlDelay)
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("domain_info query failed: %v", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	cfg.CrawlDelay = time.Duration(crawlDelay) * time.Millisecond
+	return cfg, nil
+}
+
+// SetDomainConfig is documented on the ModelDatastore interface.
+func (ds *Datastore) SetDomainConfig(domain string, cfg *DomainConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	info := &DomainInfo{
+		SampleThreshold: cfg.SampleThreshold,
+		SamplePercent:   cfg.SamplePercent,
+		ByteBudget:      cfg.ByteBudget,
+		CrawlWindow:     cfg.CrawlWindow,
+		CrawlTimezone:   cfg.CrawlTimezone,
+		UserAgent:       cfg.UserAgent,
+		CrawlDelay:      cfg.CrawlDelay,
+	}
+	err := ds.UpdateDomain(domain, info, DomainInfoUpdateConfig{
+		Sampling:    true,
+		ByteBudget:  true,
+		CrawlWindow: true,
+		Fetching:    true,
+	})
+	if err != nil {
+		return err
+	}
+	// Don't keep sampling with the old settings until the cache expires
+	ds.sampleCache.Remove(domain)
+	return nil
+}
+
+// domainByteBudget returns the bandwidth budget in effect for a domain with
+// the given byte_budget override, or 0 if it has none
+func domainByteBudget(override int64) int64 {
+	if override < 0 {
+		return 0
+	}
+	if override > 0 {
+		return override
+	}
+	return walker.Config.Dispatcher.DomainByteBudget
+}
+
+// domainByteBudgetWindow returns the parsed dispatcher.domain_byte_budget_window
+func domainByteBudgetWindow() time.Duration {
+	window, err := time.ParseDuration(walker.Config.Dispatcher.DomainByteBudgetWindow)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	return window
+}
+
+// domainBytesSince sums the response body bytes fetched for domain since the
+// given time. domain_fetch_counts is bucketed, so this includes the whole
+// bucket start falls in.
+func domainBytesSince(db *gocql.Session, domain string, start time.Time) (int64, error) {
+	itr := db.Query(`SELECT bytes FROM domain_fetch_counts WHERE dom = ? AND bucket >= ?`,
+		domain, start.Truncate(domainFetchBucket)).Iter()
+	var total, bucketBytes int64
+	for itr.Scan(&bucketBytes) {
+		total += bucketBytes
+	}
+	if err := itr.Close(); err != nil {
+		return 0, fmt.Errorf("domain_fetch_counts query failed: %v", err)
+	}
+	return total, nil
+}
+
+// byErrorRate sorts DomainErrorRates worst first; ties go to the domain with
+// more errors
+type byErrorRate []*DomainErrorRate
+
+func (b byErrorRate) Len() int      { return len(b) }
+func (b byErrorRate) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byErrorRate) Less(i, j int) bool {
+	ri, rj := b[i].Rate(), b[j].Rate()
+	if ri != rj {
+		return ri > rj
+	}
+	if b[i].Errors != b[j].Errors {
+		return b[i].Errors > b[j].Errors
+	}
+	return b[i].Domain < b[j].Domain
+}
+
+// topErrorDomains returns up to limit domains with the highest error rates.
+// Domains without any failed fetches are left out.
+func (ds *Datastore) topErrorDomains(limit int) ([]*DomainErrorRate, error) {
+	itr := ds.read(`SELECT dom, fetches, fetch_errors FROM domain_counters`).Iter()
+	var rates []*DomainErrorRate
+	var dom string
+	var fetches, errors int64
+	for itr.Scan(&dom, &fetches, &errors) {
+		if errors > 0 {
+			rates = append(rates, &DomainErrorRate{Domain: dom, Fetches: fetches, Errors: errors})
+		}
+	}
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("domain_counters scan failed: %v", err)
+	}
+
+	sort.Sort(byErrorRate(rates))
+	if len(rates) > limit {
+		rates = rates[:limit]
+	}
+	return rates, nil
+}
+
 //
 // Extra helper methods
 //
@@ -1085,10 +3257,10 @@ func (ds *Datastore) collectLinkInfos(linfos []*LinkInfo, rtimes map[string]reme
 // by themselves, but this is used in case crawlers crash or are killed and
 // have left domains claimed.
 func (ds *Datastore) UnclaimAll() error {
-	iter := ds.db.Query(`SELECT dom FROM domain_info WHERE dispatched = true`).Iter()
+	iter := ds.read(`SELECT dom FROM domain_info WHERE dispatched = true`).Iter()
 	var dom string
 	for iter.Scan(&dom) {
-		ds.UnclaimHost(dom)
+		ds.UnclaimHost(context.Background(), dom)
 	}
 	return iter.Close()
 }
@@ -3,11 +3,14 @@
 package cassandra
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -122,12 +125,12 @@ func TestDatastoreBasic(t *testing.T) {
 		}
 	}
 
-	host := ds.ClaimNewHost()
+	host := ds.ClaimNewHost(context.Background())
 	if host != "test2.com" {
 		t.Errorf("Expected test2.com but got %q", host)
 	}
 
-	host = ds.ClaimNewHost()
+	host = ds.ClaimNewHost(context.Background())
 	if host != "test.com" {
 		t.Errorf("Expected test.com but got %q", host)
 	}
@@ -137,15 +140,15 @@ func TestDatastoreBasic(t *testing.T) {
 		*page1URL.URL: true,
 		*page2URL.URL: true,
 	}
-	for u := range ds.LinksForHost("test.com") {
+	for u := range ds.LinksForHost(context.Background(), "test.com") {
 		links[*u.URL] = true
 	}
 	if !reflect.DeepEqual(links, expectedLinks) {
 		t.Errorf("Expected links from LinksForHost: %v\nBut got: %v", expectedLinks, links)
 	}
 
-	ds.StoreURLFetchResults(page1Fetch)
-	ds.StoreURLFetchResults(page2Fetch)
+	ds.StoreURLFetchResults(context.Background(), page1Fetch)
+	ds.StoreURLFetchResults(context.Background(), page2Fetch)
 
 	expectedResults := map[url.URL]int{
 		*page1URL.URL: 200,
@@ -168,8 +171,8 @@ func TestDatastoreBasic(t *testing.T) {
 			expectedResults, results)
 	}
 
-	ds.StoreParsedURL(walker.MustParse("http://test2.com/page1-1.html"), page1Fetch)
-	ds.StoreParsedURL(walker.MustParse("http://test2.com/page2-1.html"), page2Fetch)
+	ds.StoreParsedURL(context.Background(), walker.MustParse("http://test2.com/page1-1.html"), page1Fetch)
+	ds.StoreParsedURL(context.Background(), walker.MustParse("http://test2.com/page2-1.html"), page2Fetch)
 
 	var count int
 	db.Query(`SELECT COUNT(*) FROM links WHERE dom = 'test2.com'`).Scan(&count)
@@ -177,7 +180,7 @@ func TestDatastoreBasic(t *testing.T) {
 		t.Errorf("Expected 2 parsed links to be inserted for test2.com, found %v", count)
 	}
 
-	ds.UnclaimHost("test.com")
+	ds.UnclaimHost(context.Background(), "test.com")
 
 	db.Query(`SELECT COUNT(*) FROM segments WHERE dom = 'test.com'`).Scan(&count)
 	if count != 0 {
@@ -196,6 +199,60 @@ func TestDatastoreBasic(t *testing.T) {
 	}
 }
 
+func TestRequeueHost(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	ctx := context.Background()
+
+	queries := []*gocql.Query{
+		db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched) VALUES (?, ?, ?, ?)`,
+			"test.com", gocql.UUID{}, 0, true),
+		db.Query(`INSERT INTO segments (dom, subdom, path, proto) VALUES (?, ?, ?, ?)`,
+			"test.com", "", "/page1.html", "http"),
+		db.Query(`INSERT INTO segments (dom, subdom, path, proto) VALUES (?, ?, ?, ?)`,
+			"test.com", "", "/page2.html", "http"),
+	}
+	for _, q := range queries {
+		if err := q.Exec(); err != nil {
+			t.Fatalf("Failed to insert test data: %v\nQuery: %v", err, q)
+		}
+	}
+
+	if host := ds.ClaimNewHost(ctx); host != "test.com" {
+		t.Fatalf("Expected to claim test.com, got %q", host)
+	}
+	var left []*walker.URL
+	for u := range ds.LinksForHost(ctx, "test.com") {
+		if u.Path == "/page2.html" {
+			left = append(left, u)
+		}
+	}
+	ds.RequeueHost(ctx, "test.com", left)
+	ds.Close()
+
+	// Another datastore can claim the host and gets only the links left
+	ds = getDS(t)
+	defer ds.Close()
+	if host := ds.ClaimNewHost(ctx); host != "test.com" {
+		t.Fatalf("Expected requeued test.com to be claimable, got %q", host)
+	}
+	var links []string
+	for u := range ds.LinksForHost(ctx, "test.com") {
+		links = append(links, u.String())
+	}
+	if !reflect.DeepEqual(links, []string{"http://test.com/page2.html"}) {
+		t.Errorf("Expected only the links left in the segment, got %v", links)
+	}
+
+	var queued int
+	if err := db.Query(`SELECT queued_links FROM domain_info WHERE dom = ?`, "test.com").Scan(&queued); err != nil {
+		t.Fatalf("Failed to read domain_info: %v", err)
+	}
+	if queued != 1 {
+		t.Errorf("Expected queued_links 1, got %d", queued)
+	}
+}
+
 func TestNewDomainAdditions(t *testing.T) {
 	db := GetTestDB()
 	ds := getDS(t)
@@ -204,7 +261,7 @@ func TestNewDomainAdditions(t *testing.T) {
 	defer func() { walker.Config.Cassandra.AddNewDomains = origAddNewDomains }()
 
 	walker.Config.Cassandra.AddNewDomains = false
-	ds.StoreParsedURL(walker.MustParse("http://test.com/page1-1.html"), page1Fetch)
+	ds.StoreParsedURL(context.Background(), walker.MustParse("http://test.com/page1-1.html"), page1Fetch)
 
 	var count int
 	db.Query(`SELECT COUNT(*) FROM domain_info WHERE dom = 'test.com'`).Scan(&count)
@@ -218,7 +275,7 @@ func TestNewDomainAdditions(t *testing.T) {
 	}
 
 	walker.Config.Cassandra.AddNewDomains = true
-	ds.StoreParsedURL(walker.MustParse("http://test.com/page1-1.html"), page1Fetch)
+	ds.StoreParsedURL(context.Background(), walker.MustParse("http://test.com/page1-1.html"), page1Fetch)
 
 	err := db.Query(`SELECT COUNT(*) FROM domain_info
 						WHERE dom = 'test.com'
@@ -233,7 +290,7 @@ func TestNewDomainAdditions(t *testing.T) {
 	}
 
 	db.Query(`DELETE FROM domain_info WHERE dom = 'test.com'`).Exec()
-	ds.StoreParsedURL(walker.MustParse("http://test.com/page1-1.html"), page1Fetch)
+	ds.StoreParsedURL(context.Background(), walker.MustParse("http://test.com/page1-1.html"), page1Fetch)
 	db.Query(`SELECT COUNT(*) FROM domain_info WHERE dom = 'test.com'`).Scan(&count)
 	if count != 0 {
 		t.Error("Expected test.com not to be added to domain_info due to cache")
@@ -441,7 +498,7 @@ func TestStoreURLFetchResults(t *testing.T) {
 	ds := getDS(t)
 
 	for _, tcase := range StoreURLExpectations {
-		ds.StoreURLFetchResults(tcase.Input)
+		ds.StoreURLFetchResults(context.Background(), tcase.Input)
 		exp := tcase.Expected
 
 		actual := &LinksExpectation{}
@@ -602,19 +659,21 @@ func TestAddingRedirects(t *testing.T) {
 	}
 
 	fr := walker.FetchResults{
-		URL:            walker.MustParse(link(1)),
-		RedirectedFrom: []*walker.URL{walker.MustParse(link(2)), walker.MustParse(link(3))},
-		FetchTime:      time.Unix(0, 0),
+		URL:              walker.MustParse(link(1)),
+		RedirectedFrom:   []*walker.URL{walker.MustParse(link(2)), walker.MustParse(link(3))},
+		RedirectStatuses: []int{301, 0},
+		FetchTime:        time.Unix(0, 0),
 	}
 
-	ds.StoreURLFetchResults(&fr)
+	ds.StoreURLFetchResults(context.Background(), &fr)
 
 	expected := []struct {
 		link  string
 		redto string
+		stat  int
 	}{
-		{link: link(1), redto: link(2)},
-		{link: link(2), redto: link(3)},
+		{link: link(1), redto: link(2), stat: 301},
+		{link: link(2), redto: link(3), stat: 0},
 		{link: link(3), redto: ""},
 	}
 
@@ -622,13 +681,14 @@ func TestAddingRedirects(t *testing.T) {
 		url := walker.MustParse(exp.link)
 
 		dom, subdom, _ := url.TLDPlusOneAndSubdomain()
-		itr := db.Query("SELECT redto_url FROM links WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?",
+		itr := db.Query("SELECT redto_url, stat FROM links WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?",
 			dom,
 			subdom,
 			url.RequestURI(),
 			url.Scheme).Iter()
 		var redto string
-		if !itr.Scan(&redto) {
+		var stat int
+		if !itr.Scan(&redto, &stat) {
 			t.Errorf("Failed to find link %q", exp.link)
 			continue
 		}
@@ -642,373 +702,2330 @@ func TestAddingRedirects(t *testing.T) {
 		if redto != exp.redto {
 			t.Errorf("Redirect mismatch: got %q, expected %q", redto, exp.redto)
 		}
+		if exp.redto != "" && stat != exp.stat {
+			t.Errorf("Redirect status mismatch for %v: got %d, expected %d", exp.link, stat, exp.stat)
+		}
 	}
 }
 
-func TestUnclaimAll(t *testing.T) {
-	db := GetTestDB()
+func TestStoreFetchTiming(t *testing.T) {
+	orig := walker.Config.Cassandra.StoreFetchTiming
+	defer func() { walker.Config.Cassandra.StoreFetchTiming = orig }()
+	walker.Config.Cassandra.StoreFetchTiming = true
+
+	GetTestDB()
 	ds := getDS(t)
 
-	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
-								VALUES (?, ?, ?, ?)`
-	insertSegment := `INSERT INTO segments (dom, subdom, path, proto)
-						VALUES (?, ?, ?, ?)`
+	timing := walker.FetchTiming{
+		DNS:      2 * time.Millisecond,
+		Connect:  3 * time.Millisecond,
+		TLS:      5 * time.Millisecond,
+		TTFB:     7 * time.Millisecond,
+		Transfer: 11 * time.Millisecond,
+		Bytes:    13,
+	}
+	fr := walker.FetchResults{
+		URL:       walker.MustParse("http://timing.com/page1.html"),
+		FetchTime: time.Now(),
+		Timing:    timing,
+	}
+	ds.StoreURLFetchResults(context.Background(), &fr)
 
-	queries := []*gocql.Query{
-		db.Query(insertDomainInfo, "test.com", gocql.TimeUUID(), 1, true),
-		db.Query(insertSegment, "test.com", "", "page1.html", "http"),
-		db.Query(insertSegment, "test.com", "", "page2.html", "http"),
+	linfos, err := ds.ListLinkHistorical(fr.URL)
+	if err != nil {
+		t.Fatalf("ListLinkHistorical failed: %v", err)
 	}
-	for _, q := range queries {
-		err := q.Exec()
-		if err != nil {
-			t.Fatalf("Failed to insert test data: %v\nQuery: %v", err, q)
-		}
+	if len(linfos) != 1 {
+		t.Fatalf("Expected 1 historical entry, got %d", len(linfos))
 	}
+	if linfos[0].Timing != timing {
+		t.Errorf("Timing mismatch: got %+v, expected %+v", linfos[0].Timing, timing)
+	}
+}
 
-	ds.UnclaimAll()
+func TestStoreRequestHeaders(t *testing.T) {
+	orig := walker.Config.Cassandra.StoreRequestHeaders
+	defer func() { walker.Config.Cassandra.StoreRequestHeaders = orig }()
+	walker.Config.Cassandra.StoreRequestHeaders = true
 
-	var count int
-	db.Query(`SELECT COUNT(*) FROM segments WHERE dom = 'test.com'`).Scan(&count)
-	if count != 0 {
-		t.Errorf("Expected links from unclaimed domain to be deleted, found %v", count)
+	GetTestDB()
+	ds := getDS(t)
+
+	req, _ := http.NewRequest("GET", "http://reqheaders.com/page1.html", nil)
+	req.Header.Set("User-Agent", "Walker")
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header["X-Custom"] = []string{"one", "two"}
+	fr := walker.FetchResults{
+		URL:       walker.MustParse("http://reqheaders.com/page1.html"),
+		FetchTime: time.Now(),
+		Response:  &http.Response{StatusCode: 200, Request: req},
 	}
+	ds.StoreURLFetchResults(context.Background(), &fr)
 
-	err := db.Query(`SELECT COUNT(*) FROM domain_info
-						WHERE dom = 'test.com'
-						AND claim_tok = 00000000-0000-0000-0000-000000000000
-						AND dispatched = false ALLOW FILTERING`).Scan(&count)
+	linfos, err := ds.ListLinkHistorical(fr.URL)
 	if err != nil {
-		t.Fatalf("Failed to query for test.com in domain_info: %v", err)
+		t.Fatalf("ListLinkHistorical failed: %v", err)
 	}
-	if count != 1 {
-		t.Fatalf("test.com has not been correctly undispatched in domain_info after unclaim all")
+	if len(linfos) != 1 {
+		t.Fatalf("Expected 1 historical entry, got %d", len(linfos))
+	}
+	expected := http.Header{
+		"User-Agent":    []string{"Walker"},
+		"Authorization": []string{"Bearer ****"},
+		"X-Custom":      []string{"one", "two"},
+	}
+	if !reflect.DeepEqual(linfos[0].RequestHeaders, expected) {
+		t.Errorf("Request headers mismatch: got %v, expected %v", linfos[0].RequestHeaders, expected)
 	}
 }
 
-func TestClaimHostConcurrency(t *testing.T) {
-	largePriority := 10
-	numInstances := 10
-	numDomain := 1000
+func TestStoreCrawlIdentity(t *testing.T) {
+	GetTestDB()
+	ds := getDS(t)
 
-	db := GetTestDB()
-	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, dispatched, priority) VALUES (?, 00000000-0000-0000-0000-000000000000, true, ?)`
-	for i := 0; i < numDomain; i++ {
-		err := db.Query(insertDomainInfo, fmt.Sprintf("d%d.com", i), largePriority).Exec()
-		if err != nil {
-			t.Fatalf("Failed to insert domain d%d.com: %v", i, err)
+	u := walker.MustParse("http://crawlid.com/page1.html")
+	identities := []string{"first-crawl", "", "second-crawl"}
+	for i, id := range identities {
+		fr := walker.FetchResults{
+			URL:           u,
+			FetchTime:     time.Now().Add(time.Duration(i-len(identities)) * time.Hour),
+			CrawlIdentity: id,
+			Response:      &http.Response{StatusCode: 200},
 		}
+		ds.StoreURLFetchResults(context.Background(), &fr)
 	}
-	db.Close()
 
-	var startWg, finishWg sync.WaitGroup
-	var hosts [][]string = make([][]string, numInstances)
-	for i := 0; i < numInstances; i++ {
-		finishWg.Add(1)
-		startWg.Add(1)
-		go func(index int) {
-			ds := getDS(t)
-			startWg.Done()
-			startWg.Wait()
-			var h []string
-			for {
-				host := ds.ClaimNewHost()
-				if host == "" {
-					break
-				}
-				h = append(h, host)
-			}
-			hosts[index] = h
-			ds.Close()
-			finishWg.Done()
-		}(i)
+	linfos, err := ds.ListLinkHistorical(u)
+	if err != nil {
+		t.Fatalf("ListLinkHistorical failed: %v", err)
 	}
-	finishWg.Wait()
-
-	allDomains := map[string]bool{}
-	for _, hlist := range hosts {
-		for _, host := range hlist {
-			if allDomains[host] {
-				t.Fatalf("Double counted domain %s", host)
-			}
-			allDomains[host] = true
-		}
+	var got []string
+	for _, linfo := range linfos {
+		got = append(got, linfo.CrawlIdentity)
 	}
-	for i := 0; i < numDomain; i++ {
-		host := fmt.Sprintf("d%d.com", i)
-		if !allDomains[host] {
-			t.Fatalf("Failed to claim domain %s", host)
-		}
+	if !reflect.DeepEqual(got, identities) {
+		t.Errorf("Expected crawl identities %q, got %q", identities, got)
 	}
 }
 
-func TestDomainPriority(t *testing.T) {
-	// This is a simple priority test. It's set up so all of the yes.com domains should be served by ClaimNewHost,
-	// and all the NO.com's should NOT be served.
-	rand.Seed(12345)
-
-	// I can't pull in cassandra.MaxPriority because it becomes cyclic dependency.
-	maxPriority := 10
-	numElements := 50
-
-	db := GetTestDB()
+func TestStoreFingerprints(t *testing.T) {
+	GetTestDB()
 	ds := getDS(t)
 
-	insertDomainInfo := `INSERT INTO domain_info (dom, priority, claim_tok, dispatched) VALUES (?, ?, 00000000-0000-0000-0000-000000000000, true)`
-	count := 0
-	for i := 0; i < numElements; i++ {
-		if rand.Float32() < 0.5 {
-			count++
-			host := fmt.Sprintf("yes%d.com", i)
-			err := db.Query(insertDomainInfo, host, maxPriority).Exec()
-			if err != nil {
-				t.Fatalf("Failed to insert domain yes.com: %v", err)
-			}
-		} else {
-			host := fmt.Sprintf("NO%d.com", i)
-			err := db.Query(insertDomainInfo, host, 0).Exec() // This isn't legal in normal use, but it'll be fine here
-			if err != nil {
-				t.Fatalf("Failed to insert domain NO.com: %v", err)
-			}
-		}
+	fr := walker.FetchResults{
+		URL:                walker.MustParse("http://fingerprints.com/page1.html"),
+		FetchTime:          time.Now(),
+		Response:           &http.Response{StatusCode: 200},
+		FnvFingerprint:     1,
+		FnvTextFingerprint: 2,
+		SHA256Fingerprint:  []byte{0xde, 0xad, 0xbe, 0xef},
+		SimHashFingerprint: 3,
 	}
+	ds.StoreURLFetchResults(context.Background(), &fr)
 
-	ncount := 0
-	for {
-		host := ds.ClaimNewHost()
-		if host == "" {
-			break
-		}
-		ncount++
-		if !strings.HasPrefix(host, "yes") {
-			t.Errorf("Bad domain found: %q", host)
-		}
+	linfos, err := ds.ListLinkHistorical(fr.URL)
+	if err != nil {
+		t.Fatalf("ListLinkHistorical failed: %v", err)
 	}
-
-	if ncount != count {
-		t.Fatalf("Count mismatch: got %d, expected %d", ncount, count)
+	if len(linfos) != 1 {
+		t.Fatalf("Expected 1 historical entry, got %d", len(linfos))
+	}
+	l := linfos[0]
+	if l.FnvFingerprint != 1 || l.FnvTextFingerprint != 2 || !reflect.DeepEqual(l.SHA256Fingerprint, fr.SHA256Fingerprint) ||
+		l.SimHashFingerprint != 3 {
+		t.Errorf("Fingerprint mismatch, got %v %v %x %v", l.FnvFingerprint, l.FnvTextFingerprint,
+			l.SHA256Fingerprint, l.SimHashFingerprint)
 	}
 }
 
-func TestDomainPriorityRatio(t *testing.T) {
-	// This test checks the ratio of fetched domains. The deal is we have two domains, d1 and d2, with priorities, p1
-	// and p2. The number of fetches of d1 (d2) is f1 (f2): that is the number of times d1 is claimed (by ClaimNewHost)
-	// is f1. In this case if the priority system is working correctly it should be that p1/p2 is approximately equal
-	// to f1/f2
-	rand.Seed(12345)
-	db := GetTestDB()
-	ds := getDS(t)
-	maxPriority := ds.MaxPriority()
+func TestFindLinksByFingerprint(t *testing.T) {
+	orig := walker.Config.Cassandra.IndexFingerprints
+	defer func() { walker.Config.Cassandra.IndexFingerprints = orig }()
+	walker.Config.Cassandra.IndexFingerprints = true
 
-	type Dom struct {
-		name     string
-		priority int
-	}
-	doms := []Dom{
-		Dom{"dom1.com", maxPriority / 2},
-		Dom{"dom2.com", maxPriority},
-	}
+	GetTestDB()
+	ds := getDS(t)
 
-	insertDomainInfo := `INSERT INTO domain_info (dom, priority, claim_tok, dispatched) VALUES (?, ?, 00000000-0000-0000-0000-000000000000, true)`
-	for _, d := range doms {
-		err := db.Query(insertDomainInfo, d.name, d.priority).Exec()
+	store := func(link string, fnv, fnvText int64, sum []byte, fetchTime time.Time) {
+		ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:                walker.MustParse(link),
+			FetchTime:          fetchTime,
+			Response:           &http.Response{StatusCode: 200},
+			FnvFingerprint:     fnv,
+			FnvTextFingerprint: fnvText,
+			SHA256Fingerprint:  sum,
+		})
+	}
+	earlier := time.Now().Add(-time.Hour)
+	store("http://dup.com/page.html", 1, 10, []byte{1}, earlier)
+	store("http://dup.com/page.html?session=1", 2, 10, []byte{2}, earlier)
+	store("http://www.mirror.com/page.html", 1, 10, []byte{1}, earlier)
+	store("http://dup.com/other.html", 3, 30, []byte{3}, earlier)
+	// changed since, so it no longer has the fingerprints above
+	store("http://dup.com/changed.html", 1, 10, []byte{1}, earlier)
+	store("http://dup.com/changed.html", 4, 40, []byte{4}, time.Now())
+
+	tests := []struct {
+		tag      string
+		find     func() ([]*LinkInfo, error)
+		expected []string
+	}{
+		{"fnv", func() ([]*LinkInfo, error) { return ds.FindLinksByFingerprint(1) },
+			[]string{"http://dup.com/page.html", "http://www.mirror.com/page.html"}},
+		{"fnv_txt", func() ([]*LinkInfo, error) { return ds.FindLinksByFingerprint(10) },
+			[]string{"http://dup.com/page.html", "http://dup.com/page.html?session=1", "http://www.mirror.com/page.html"}},
+		{"sha256", func() ([]*LinkInfo, error) { return ds.FindLinksBySHA256([]byte{1}) },
+			[]string{"http://dup.com/page.html", "http://www.mirror.com/page.html"}},
+		{"changed", func() ([]*LinkInfo, error) { return ds.FindLinksByFingerprint(40) },
+			[]string{"http://dup.com/changed.html"}},
+		{"none", func() ([]*LinkInfo, error) { return ds.FindLinksByFingerprint(5) },
+			nil},
+	}
+	for _, test := range tests {
+		linfos, err := test.find()
 		if err != nil {
-			t.Fatalf("Failed to insert domain yes.com: %v", err)
-		}
-	}
-
-	numRuns := 1000
-	hosts := 0
-	got := map[string]int{}
-	for i := 0; i < numRuns; i++ {
-		host := ds.ClaimNewHost()
-		if host == "" {
+			t.Errorf("%v: failed to find links: %v", test.tag, err)
 			continue
 		}
-		hosts++
-		cnt, cntOk := got[host]
-		if !cntOk {
-			cnt = 0
+		var got []string
+		for _, linfo := range linfos {
+			got = append(got, linfo.URL.String())
 		}
-		got[host] = cnt + 1
-
-		ds.UnclaimHost(host)
-		update := `UPDATE domain_info 
-				   SET 
-				   	claim_tok = 00000000-0000-0000-0000-000000000000, 
-				   	dispatched = true
-				   WHERE 
-				   	dom = ?`
-
-		err := db.Query(update, host).Exec()
-		if err != nil {
-			t.Fatalf("Failed to update domain_info: %v", err)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v: expected links %v, got %v", test.tag, test.expected, got)
 		}
 	}
+}
 
-	prio1 := doms[0].priority
-	prio2 := doms[1].priority
-	expRatio := float64(prio1) / float64(prio2)
+func TestStoreReferrers(t *testing.T) {
+	orig := walker.Config.Cassandra.StoreReferrers
+	defer func() { walker.Config.Cassandra.StoreReferrers = orig }()
+	walker.Config.Cassandra.StoreReferrers = true
 
-	count1, count1Ok := got[doms[0].name]
-	if !count1Ok {
-		t.Fatalf("Failed to find domain %q", doms[0].name)
-	} else {
-		delete(got, doms[0].name)
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1)`, "test.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	ds := getDS(t)
+	defer ds.Close()
+	ctx := context.Background()
+
+	target := walker.MustParse("http://test.com/target.html")
+	parse := func(from string) {
+		fr := &walker.FetchResults{URL: walker.MustParse(from), FetchTime: time.Now()}
+		ds.StoreParsedURLs(ctx, []*walker.URL{walker.MustParse(target.String())}, fr)
+	}
+	parse("http://test.com/b.html")
+	parse("http://test.com/a.html")
+	// An existing first referrer is kept even once the link is not cached
+	ds.referrerCache.Purge()
+	parse("http://test.com/c.html")
+	ds.StoreParsedURL(ctx, walker.MustParse(target.String()), &walker.FetchResults{
+		URL: walker.MustParse("http://test.com/d.html"),
+	})
+
+	linfo, err := ds.FindLink(target, false)
+	if err != nil {
+		t.Fatalf("FindLink failed: %v", err)
+	}
+	if linfo.FirstReferrer != "http://test.com/b.html" || linfo.LastReferrer != "http://test.com/d.html" {
+		t.Errorf("Expected referrers b.html and d.html, got %q and %q", linfo.FirstReferrer, linfo.LastReferrer)
 	}
 
-	count2, count2Ok := got[doms[1].name]
-	if !count2Ok {
-		t.Fatalf("Failed to find domain %q", doms[1].name)
-	} else {
-		delete(got, doms[1].name)
+	// Crawling the link should not lose them
+	ds.StoreURLFetchResults(ctx, &walker.FetchResults{
+		URL:       walker.MustParse(target.String()),
+		FetchTime: time.Now(),
+		Response:  &http.Response{StatusCode: 200},
+	})
+	linfo, err = ds.FindLink(target, false)
+	if err != nil {
+		t.Fatalf("FindLink failed: %v", err)
+	}
+	if linfo.FirstReferrer != "http://test.com/b.html" || linfo.LastReferrer != "http://test.com/d.html" {
+		t.Errorf("Expected referrers to carry over to the crawl, got %q and %q", linfo.FirstReferrer, linfo.LastReferrer)
 	}
 
-	gotRatio := float64(count1) / float64(count2)
+	refs, err := ds.ListReferrers(target, 10)
+	if err != nil {
+		t.Fatalf("ListReferrers failed: %v", err)
+	}
+	var got []string
+	for _, ref := range refs {
+		got = append(got, ref.URL.String())
+		if ref.LastSeen.IsZero() {
+			t.Errorf("Expected a last seen time for %v", ref.URL)
+		}
+	}
+	expected := []string{"http://test.com/a.html", "http://test.com/b.html", "http://test.com/c.html",
+		"http://test.com/d.html"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected referrers %v, got %v", expected, got)
+	}
 
-	tol := 0.01
-	if gotRatio < expRatio-tol || gotRatio > expRatio+tol {
-		t.Fatalf("Bad ratio: expected %f, but got %f: for a difference of %f using tol %f", expRatio, gotRatio,
-			expRatio-gotRatio, tol)
+	refs, err = ds.ListReferrers(target, 2)
+	if err != nil {
+		t.Fatalf("ListReferrers failed: %v", err)
+	}
+	if len(refs) != 2 {
+		t.Errorf("Expected ListReferrers to stop at its limit, got %d", len(refs))
 	}
 
-	for k := range got {
-		t.Errorf("Unexpected domain %q found", k)
+	// Without the option, parsed links record no referrer
+	walker.Config.Cassandra.StoreReferrers = false
+	other := walker.MustParse("http://test.com/other.html")
+	ds.StoreParsedURL(ctx, other, &walker.FetchResults{URL: walker.MustParse("http://test.com/a.html")})
+	linfo, err = ds.FindLink(other, false)
+	if err != nil {
+		t.Fatalf("FindLink failed: %v", err)
+	}
+	if linfo.FirstReferrer != "" || linfo.LastReferrer != "" {
+		t.Errorf("Expected no referrers with store_referrers off, got %q and %q", linfo.FirstReferrer, linfo.LastReferrer)
 	}
 }
 
-func TestKeepAlive(t *testing.T) {
-	orig := walker.Config.Fetcher.ActiveFetchersTTL
-	defer func() {
-		walker.Config.Fetcher.ActiveFetchersTTL = orig
-	}()
-	walker.Config.Fetcher.ActiveFetchersTTL = "1s"
+func TestStoreStructuredData(t *testing.T) {
+	orig := walker.Config.Cassandra.StoreStructuredData
+	defer func() { walker.Config.Cassandra.StoreStructuredData = orig }()
+	walker.Config.Cassandra.StoreStructuredData = true
 
 	db := GetTestDB()
 	ds := getDS(t)
-	read := func() (int, gocql.UUID) {
-		itr := db.Query(`SELECT tok FROM active_fetchers`).Iter()
-		var tok gocql.UUID
-		count := 0
-		for itr.Scan(&tok) {
-			count++
-		}
-		err := itr.Close()
-		if err != nil {
-			panic(err)
+
+	fetchTime := time.Now().Truncate(time.Millisecond)
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       walker.MustParse("http://test.com/product.html"),
+		FetchTime: fetchTime,
+		Response:  &http.Response{StatusCode: 200},
+		StructuredData: &walker.StructuredData{
+			OpenGraph: map[string][]string{"og:title": []string{"A Product"}},
+		},
+	})
+
+	var structured string
+	err := db.Query(`SELECT structured FROM links
+					 WHERE dom = 'test.com' AND subdom = '' AND path = '/product.html' AND proto = 'http'
+					 AND time = ?`, fetchTime).Scan(&structured)
+	if err != nil {
+		t.Fatalf("Failed to read link: %v", err)
+	}
+	expected := `{"opengraph":{"og:title":["A Product"]}}`
+	if structured != expected {
+		t.Errorf("Expected structured %q, got %q", expected, structured)
+	}
+}
+
+func TestStoreTitleAndDescription(t *testing.T) {
+	GetTestDB()
+	ds := getDS(t)
+
+	u := walker.MustParse("http://test.com/titled.html")
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:         u,
+		FetchTime:   time.Now().Truncate(time.Millisecond),
+		Response:    &http.Response{StatusCode: 200},
+		Title:       "A Title",
+		Description: "A description",
+	})
+
+	linfo, err := ds.FindLink(u, false)
+	if err != nil {
+		t.Fatalf("FindLink failed: %v", err)
+	}
+	if linfo == nil {
+		t.Fatalf("Expected to find %v", u)
+	}
+	if linfo.Title != "A Title" || linfo.Description != "A description" {
+		t.Errorf("Unexpected title and description from FindLink: %q, %q", linfo.Title, linfo.Description)
+	}
+
+	linfos, err := ds.ListLinkHistorical(u)
+	if err != nil {
+		t.Fatalf("ListLinkHistorical failed: %v", err)
+	}
+	if len(linfos) != 1 {
+		t.Fatalf("Expected 1 historical link, got %d", len(linfos))
+	}
+	if linfos[0].Title != "A Title" || linfos[0].Description != "A description" {
+		t.Errorf("Unexpected title and description from ListLinkHistorical: %q, %q",
+			linfos[0].Title, linfos[0].Description)
+	}
+}
+
+func TestStoreContentLanguage(t *testing.T) {
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	u := walker.MustParse("http://test.com/seite.html")
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:             u,
+		FetchTime:       time.Now().Truncate(time.Millisecond),
+		Response:        &http.Response{StatusCode: 200},
+		ContentLanguage: "de-DE",
+	})
+
+	linfos, err := ds.ListLinkHistorical(u)
+	if err != nil {
+		t.Fatalf("ListLinkHistorical failed: %v", err)
+	}
+	if len(linfos) != 1 {
+		t.Fatalf("Expected 1 historical link, got %d", len(linfos))
+	}
+	if linfos[0].ContentLanguage != "de-DE" {
+		t.Errorf("Expected Content-Language de-DE, got %q", linfos[0].ContentLanguage)
+	}
+}
+
+func TestFindLinkAsOf(t *testing.T) {
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	u := walker.MustParse("http://test.com/asof.html")
+	first := time.Now().Add(-2 * time.Hour).Truncate(time.Millisecond)
+	second := first.Add(time.Hour)
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       u,
+		FetchTime: first,
+		Response:  &http.Response{StatusCode: 200},
+		Title:     "First",
+	})
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       u,
+		FetchTime: second,
+		Response:  &http.Response{StatusCode: 404},
+	})
+
+	tests := []struct {
+		asOf     time.Time
+		expected time.Time
+		status   int
+	}{
+		{first.Add(-time.Minute), time.Time{}, 0},
+		{first, first, 200},
+		{first.Add(30 * time.Minute), first, 200},
+		{second, second, 404},
+		{time.Now(), second, 404},
+	}
+	for _, tst := range tests {
+		linfo, err := ds.FindLinkAsOf(u, tst.asOf)
+		if err != nil {
+			t.Fatalf("FindLinkAsOf(%v) failed: %v", tst.asOf, err)
+		}
+		if tst.expected.IsZero() {
+			if linfo != nil {
+				t.Errorf("Expected nothing as of %v, got crawl at %v", tst.asOf, linfo.CrawlTime)
+			}
+			continue
+		}
+		if linfo == nil {
+			t.Errorf("Expected a link as of %v, got nil", tst.asOf)
+			continue
+		}
+		if !linfo.CrawlTime.Equal(tst.expected) {
+			t.Errorf("Crawl time as of %v: got %v, expected %v", tst.asOf, linfo.CrawlTime, tst.expected)
+		}
+		if linfo.Status != tst.status {
+			t.Errorf("Status as of %v: got %v, expected %v", tst.asOf, linfo.Status, tst.status)
+		}
+	}
+}
+
+func TestDiffLink(t *testing.T) {
+	orig := walker.Config.Cassandra.StoreResponseBody
+	defer func() { walker.Config.Cassandra.StoreResponseBody = orig }()
+	walker.Config.Cassandra.StoreResponseBody = true
+
+	GetTestDB()
+	ds := getDS(t)
+
+	u := walker.MustParse("http://test.com/changing.html")
+	t1 := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	t2 := time.Now().Truncate(time.Millisecond)
+	t3 := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	crawls := []struct {
+		t    time.Time
+		stat int
+		body string
+		fnv  int64
+	}{
+		{t1, 200, "<html><body><p>first</p><p>second</p><p>third</p></body></html>", 1},
+		{t2, 200, "<html><body><p>second</p><p>first</p><p>fourth</p><p>fifth</p></body></html>", 2},
+		{t3, 304, "", 2},
+	}
+	for _, c := range crawls {
+		ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:                u,
+			FetchTime:          c.t,
+			Response:           &http.Response{StatusCode: c.stat},
+			MimeType:           "text/html",
+			Body:               c.body,
+			FnvFingerprint:     c.fnv,
+			FnvTextFingerprint: c.fnv,
+		})
+	}
+
+	diff, err := ds.DiffLink(u, t1, t2)
+	if err != nil {
+		t.Fatalf("DiffLink failed: %v", err)
+	}
+	expected := &LinkDiff{
+		URL:            u,
+		Before:         t1,
+		After:          t2,
+		StatusBefore:   200,
+		StatusAfter:    200,
+		Changed:        true,
+		TextChanged:    true,
+		HasBodies:      true,
+		SizeBefore:     len(crawls[0].body),
+		SizeAfter:      len(crawls[1].body),
+		LinesAdded:     2,
+		LinesRemoved:   1,
+		LinesUnchanged: 2,
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("DiffLink mismatch\nexpected: %+v\ngot:      %+v", expected, diff)
+	}
+	if diff.SizeDelta() != len(crawls[1].body)-len(crawls[0].body) {
+		t.Errorf("Unexpected SizeDelta %d", diff.SizeDelta())
+	}
+
+	// Without a body only the fingerprints can be compared
+	diff, err = ds.DiffLink(u, t2, t3)
+	if err != nil {
+		t.Fatalf("DiffLink failed: %v", err)
+	}
+	if diff.HasBodies || diff.Changed || diff.StatusAfter != 304 || diff.LinesAdded != 0 {
+		t.Errorf("Unexpected diff without bodies: %+v", diff)
+	}
+
+	_, err = ds.DiffLink(u, t1, t1.Add(time.Minute))
+	if err == nil {
+		t.Errorf("Expected an error diffing a crawl that does not exist")
+	}
+}
+
+func TestStoreTLSInfo(t *testing.T) {
+	orig := walker.Config.Cassandra.StoreTLSInfo
+	defer func() { walker.Config.Cassandra.StoreTLSInfo = orig }()
+	walker.Config.Cassandra.StoreTLSInfo = true
+
+	db := GetTestDB()
+	ds := getDS(t)
+
+	info := &walker.TLSInfo{
+		Version:     "TLS 1.2",
+		CipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		Issuer:      "CN=Test CA",
+		Subject:     "CN=www.tls.com",
+		NotAfter:    time.Now().AddDate(0, 1, 0).Truncate(time.Millisecond),
+		SANs:        []string{"www.tls.com", "tls.com"},
+	}
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       walker.MustParse("https://www.tls.com/page1.html"),
+		FetchTime: time.Now(),
+		Response:  &http.Response{StatusCode: 200},
+		TLS:       info,
+	})
+	// Plain http fetches have nothing to store
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       walker.MustParse("http://plain.tls.com/page1.html"),
+		FetchTime: time.Now(),
+		Response:  &http.Response{StatusCode: 200},
+	})
+
+	var got walker.TLSInfo
+	var host string
+	itr := db.Query(`SELECT host, version, cipher, issuer, subject, not_after, sans FROM tls_certs WHERE dom = ?`,
+		"tls.com").Iter()
+	count := 0
+	for itr.Scan(&host, &got.Version, &got.CipherSuite, &got.Issuer, &got.Subject, &got.NotAfter, &got.SANs) {
+		count++
+	}
+	if err := itr.Close(); err != nil {
+		t.Fatalf("Failed to read tls_certs: %v", err)
+	}
+	if count != 1 || host != "www.tls.com" {
+		t.Fatalf("Expected a single row for www.tls.com, got %d rows (last host %q)", count, host)
+	}
+	if !got.NotAfter.Equal(info.NotAfter) {
+		t.Errorf("NotAfter mismatch: got %v, expected %v", got.NotAfter, info.NotAfter)
+	}
+	got.NotAfter = info.NotAfter
+	if !reflect.DeepEqual(&got, info) {
+		t.Errorf("TLS info mismatch: got %+v, expected %+v", got, *info)
+	}
+}
+
+func TestCrawlOverview(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+
+	insertDomain := `INSERT INTO domain_info (dom, claim_tok, priority, dispatched, tot_links, uncrawled_links, queued_links)
+						VALUES (?, ?, 1, ?, ?, ?, ?)`
+	domains := []struct {
+		dom                      string
+		dispatched               bool
+		total, uncrawled, queued int
+	}{
+		{"a.com", true, 10, 4, 2},
+		{"b.com", false, 5, 5, 0},
+		{"c.com", true, 1, 0, 1},
+	}
+	for _, d := range domains {
+		err := db.Query(insertDomain, d.dom, gocql.UUID{}, d.dispatched, d.total, d.uncrawled, d.queued).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain %v: %v", d.dom, err)
+		}
+	}
+
+	store := func(link string, status int, fetchErr error) {
+		fr := &walker.FetchResults{
+			URL:        walker.MustParse(link),
+			FetchTime:  time.Now(),
+			FetchError: fetchErr,
+		}
+		if fetchErr == nil {
+			fr.Response = &http.Response{StatusCode: status}
+		}
+		ds.StoreURLFetchResults(context.Background(), fr)
+	}
+	store("http://a.com/page1.html", 200, nil)
+	store("http://a.com/page2.html", 404, nil)
+	store("http://b.com/page1.html", 0, fmt.Errorf("connection refused"))
+	store("http://c.com/page1.html", 200, nil)
+
+	if err := ds.KeepAlive(context.Background()); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+
+	ov, err := ds.CrawlOverview()
+	if err != nil {
+		t.Fatalf("CrawlOverview failed: %v", err)
+	}
+
+	if ov.NumberDomains != 3 {
+		t.Errorf("NumberDomains: got %d, expected 3", ov.NumberDomains)
+	}
+	if ov.NumberDomainsDispatched != 2 {
+		t.Errorf("NumberDomainsDispatched: got %d, expected 2", ov.NumberDomainsDispatched)
+	}
+	if ov.NumberLinksTotal != 16 || ov.NumberLinksUncrawled != 9 || ov.NumberLinksQueued != 3 {
+		t.Errorf("Link counts: got %d/%d/%d, expected 16/9/3",
+			ov.NumberLinksTotal, ov.NumberLinksUncrawled, ov.NumberLinksQueued)
+	}
+	if ov.FetchesLastHour != 4 {
+		t.Errorf("FetchesLastHour: got %d, expected 4", ov.FetchesLastHour)
+	}
+	if ov.NumberActiveFetchers != 1 {
+		t.Errorf("NumberActiveFetchers: got %d, expected 1", ov.NumberActiveFetchers)
+	}
+
+	expected := []DomainErrorRate{
+		{Domain: "b.com", Fetches: 1, Errors: 1},
+		{Domain: "a.com", Fetches: 2, Errors: 1},
+	}
+	if len(ov.TopErrorDomains) != len(expected) {
+		t.Fatalf("TopErrorDomains: got %d domains, expected %d", len(ov.TopErrorDomains), len(expected))
+	}
+	for i, exp := range expected {
+		if *ov.TopErrorDomains[i] != exp {
+			t.Errorf("TopErrorDomains[%d]: got %+v, expected %+v", i, *ov.TopErrorDomains[i], exp)
+		}
+	}
+}
+
+func TestInsertLinksWithTags(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+
+	errs := ds.InsertLinksWithTags([]string{"http://a.com/page1.html", "http://b.com/page1.html"}, "",
+		[]string{"news", " customer-x ", "news"})
+	if len(errs) > 0 {
+		t.Fatalf("InsertLinksWithTags failed: %v", errs)
+	}
+	errs = ds.InsertLinksWithTags([]string{"http://b.com/page2.html", "http://c.com/page1.html"}, "",
+		[]string{"news"})
+	if len(errs) > 0 {
+		t.Fatalf("InsertLinksWithTags failed: %v", errs)
+	}
+	if errs := ds.InsertLinks([]string{"http://d.com/page1.html"}, ""); len(errs) > 0 {
+		t.Fatalf("InsertLinks failed: %v", errs)
+	}
+	if errs := ds.InsertLinksWithTags([]string{"http://e.com/page1.html"}, "", []string{" "}); len(errs) != 1 {
+		t.Errorf("Expected an empty tag to be refused, got %v", errs)
+	}
+
+	dinfo, err := ds.FindDomain("b.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if !reflect.DeepEqual(dinfo.Tags, []string{"customer-x", "news"}) {
+		t.Errorf("Expected b.com to be tagged customer-x and news, got %v", dinfo.Tags)
+	}
+
+	dinfos, err := ds.ListDomains(DQ{Tag: "news"})
+	if err != nil {
+		t.Fatalf("ListDomains failed: %v", err)
+	}
+	var got []string
+	for _, d := range dinfos {
+		got = append(got, d.Domain)
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"a.com", "b.com", "c.com"}) {
+		t.Errorf("Expected a.com, b.com and c.com tagged news, got %v", got)
+	}
+
+	err = db.Query(`UPDATE domain_info SET dispatched = true, tot_links = 10, uncrawled_links = 4
+					WHERE dom = ?`, "a.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to update a.com: %v", err)
+	}
+	dinfos, err = ds.ListDomains(DQ{Tag: "news", Working: true})
+	if err != nil {
+		t.Fatalf("ListDomains failed: %v", err)
+	}
+	if len(dinfos) != 1 || dinfos[0].Domain != "a.com" {
+		t.Errorf("Expected only a.com to be dispatched and tagged news, got %v", dinfos)
+	}
+
+	reports, err := ds.TagReports()
+	if err != nil {
+		t.Fatalf("TagReports failed: %v", err)
+	}
+	expected := []*TagReport{
+		{Tag: "customer-x", NumberDomains: 2, NumberDomainsDispatched: 1, NumberLinksTotal: 10,
+			NumberLinksUncrawled: 4},
+		{Tag: "news", NumberDomains: 3, NumberDomainsDispatched: 1, NumberLinksTotal: 10, NumberLinksUncrawled: 4},
+	}
+	if !reflect.DeepEqual(reports, expected) {
+		t.Errorf("TagReports: expected %+v, got %+v", expected, reports)
+		for _, r := range reports {
+			t.Logf("%+v", r)
+		}
+	}
+}
+
+func TestUnclaimAll(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+
+	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+								VALUES (?, ?, ?, ?)`
+	insertSegment := `INSERT INTO segments (dom, subdom, path, proto)
+						VALUES (?, ?, ?, ?)`
+
+	queries := []*gocql.Query{
+		db.Query(insertDomainInfo, "test.com", gocql.TimeUUID(), 1, true),
+		db.Query(insertSegment, "test.com", "", "page1.html", "http"),
+		db.Query(insertSegment, "test.com", "", "page2.html", "http"),
+	}
+	for _, q := range queries {
+		err := q.Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert test data: %v\nQuery: %v", err, q)
+		}
+	}
+
+	ds.UnclaimAll()
+
+	var count int
+	db.Query(`SELECT COUNT(*) FROM segments WHERE dom = 'test.com'`).Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected links from unclaimed domain to be deleted, found %v", count)
+	}
+
+	err := db.Query(`SELECT COUNT(*) FROM domain_info
+						WHERE dom = 'test.com'
+						AND claim_tok = 00000000-0000-0000-0000-000000000000
+						AND dispatched = false ALLOW FILTERING`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to query for test.com in domain_info: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("test.com has not been correctly undispatched in domain_info after unclaim all")
+	}
+}
+
+func TestRetire(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	ctx := context.Background()
+
+	if err := ds.KeepAlive(ctx); err != nil {
+		t.Fatalf("Failed KeepAlive: %v", err)
+	}
+	oldTok := ds.crawlerUUID
+
+	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+								VALUES (?, ?, ?, ?)`
+	insertSegment := `INSERT INTO segments (dom, subdom, path, proto)
+						VALUES (?, ?, ?, ?)`
+	queries := []*gocql.Query{
+		db.Query(insertDomainInfo, "test.com", oldTok, 1, true),
+		db.Query(insertDomainInfo, "other.com", gocql.TimeUUID(), 1, true),
+		db.Query(insertSegment, "test.com", "", "page1.html", "http"),
+		db.Query(insertSegment, "other.com", "", "page1.html", "http"),
+	}
+	for _, q := range queries {
+		if err := q.Exec(); err != nil {
+			t.Fatalf("Failed to insert test data: %v\nQuery: %v", err, q)
+		}
+	}
+
+	if err := ds.Retire(ctx); err != nil {
+		t.Fatalf("Retire failed: %v", err)
+	}
+
+	var count int
+	db.Query(`SELECT COUNT(*) FROM active_fetchers WHERE tok = ?`, oldTok).Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected the retired token to be removed from active_fetchers")
+	}
+	var claimTok gocql.UUID
+	var dispatched bool
+	db.Query(`SELECT claim_tok, dispatched FROM domain_info WHERE dom = 'test.com'`).Scan(&claimTok, &dispatched)
+	if claimTok != (gocql.UUID{}) || dispatched {
+		t.Errorf("Expected test.com to be unclaimed, got claim_tok %v, dispatched %v", claimTok, dispatched)
+	}
+	db.Query(`SELECT COUNT(*) FROM segments WHERE dom = 'other.com'`).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected the segment of a domain claimed by another crawler to be left alone")
+	}
+	if ds.crawlerUUID == oldTok {
+		t.Errorf("Expected the datastore to continue with a new token")
+	}
+}
+
+func TestClaimHostConcurrency(t *testing.T) {
+	largePriority := 10
+	numInstances := 10
+	numDomain := 1000
+
+	db := GetTestDB()
+	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, dispatched, priority) VALUES (?, 00000000-0000-0000-0000-000000000000, true, ?)`
+	for i := 0; i < numDomain; i++ {
+		err := db.Query(insertDomainInfo, fmt.Sprintf("d%d.com", i), largePriority).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain d%d.com: %v", i, err)
+		}
+	}
+	db.Close()
+
+	var startWg, finishWg sync.WaitGroup
+	var hosts [][]string = make([][]string, numInstances)
+	for i := 0; i < numInstances; i++ {
+		finishWg.Add(1)
+		startWg.Add(1)
+		go func(index int) {
+			ds := getDS(t)
+			startWg.Done()
+			startWg.Wait()
+			var h []string
+			for {
+				host := ds.ClaimNewHost(context.Background())
+				if host == "" {
+					break
+				}
+				h = append(h, host)
+			}
+			hosts[index] = h
+			ds.Close()
+			finishWg.Done()
+		}(i)
+	}
+	finishWg.Wait()
+
+	allDomains := map[string]bool{}
+	for _, hlist := range hosts {
+		for _, host := range hlist {
+			if allDomains[host] {
+				t.Fatalf("Double counted domain %s", host)
+			}
+			allDomains[host] = true
+		}
+	}
+	for i := 0; i < numDomain; i++ {
+		host := fmt.Sprintf("d%d.com", i)
+		if !allDomains[host] {
+			t.Fatalf("Failed to claim domain %s", host)
+		}
+	}
+}
+
+func TestDomainPriority(t *testing.T) {
+	// This is a simple priority test. It's set up so all of the yes.com domains should be served by ClaimNewHost,
+	// and all the NO.com's should NOT be served.
+	rand.Seed(12345)
+
+	// I can't pull in cassandra.MaxPriority because it becomes cyclic dependency.
+	maxPriority := 10
+	numElements := 50
+
+	db := GetTestDB()
+	ds := getDS(t)
+
+	insertDomainInfo := `INSERT INTO domain_info (dom, priority, claim_tok, dispatched) VALUES (?, ?, 00000000-0000-0000-0000-000000000000, true)`
+	count := 0
+	for i := 0; i < numElements; i++ {
+		if rand.Float32() < 0.5 {
+			count++
+			host := fmt.Sprintf("yes%d.com", i)
+			err := db.Query(insertDomainInfo, host, maxPriority).Exec()
+			if err != nil {
+				t.Fatalf("Failed to insert domain yes.com: %v", err)
+			}
+		} else {
+			host := fmt.Sprintf("NO%d.com", i)
+			err := db.Query(insertDomainInfo, host, 0).Exec() // This isn't legal in normal use, but it'll be fine here
+			if err != nil {
+				t.Fatalf("Failed to insert domain NO.com: %v", err)
+			}
+		}
+	}
+
+	ncount := 0
+	for {
+		host := ds.ClaimNewHost(context.Background())
+		if host == "" {
+			break
+		}
+		ncount++
+		if !strings.HasPrefix(host, "yes") {
+			t.Errorf("Bad domain found: %q", host)
+		}
+	}
+
+	if ncount != count {
+		t.Fatalf("Count mismatch: got %d, expected %d", ncount, count)
+	}
+}
+
+func TestDomainPriorityRatio(t *testing.T) {
+	// This test checks the ratio of fetched domains. The deal is we have two domains, d1 and d2, with priorities, p1
+	// and p2. The number of fetches of d1 (d2) is f1 (f2): that is the number of times d1 is claimed (by ClaimNewHost)
+	// is f1. In this case if the priority system is working correctly it should be that p1/p2 is approximately equal
+	// to f1/f2
+	rand.Seed(12345)
+	db := GetTestDB()
+	ds := getDS(t)
+	maxPriority := ds.MaxPriority()
+
+	type Dom struct {
+		name     string
+		priority int
+	}
+	doms := []Dom{
+		Dom{"dom1.com", maxPriority / 2},
+		Dom{"dom2.com", maxPriority},
+	}
+
+	insertDomainInfo := `INSERT INTO domain_info (dom, priority, claim_tok, dispatched) VALUES (?, ?, 00000000-0000-0000-0000-000000000000, true)`
+	for _, d := range doms {
+		err := db.Query(insertDomainInfo, d.name, d.priority).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain yes.com: %v", err)
+		}
+	}
+
+	numRuns := 1000
+	hosts := 0
+	got := map[string]int{}
+	for i := 0; i < numRuns; i++ {
+		host := ds.ClaimNewHost(context.Background())
+		if host == "" {
+			continue
+		}
+		hosts++
+		cnt, cntOk := got[host]
+		if !cntOk {
+			cnt = 0
+		}
+		got[host] = cnt + 1
+
+		ds.UnclaimHost(context.Background(), host)
+		update := `UPDATE domain_info 
+				   SET 
+				   	claim_tok = 00000000-0000-0000-0000-000000000000, 
+				   	dispatched = true
+				   WHERE 
+				   	dom = ?`
+
+		err := db.Query(update, host).Exec()
+		if err != nil {
+			t.Fatalf("Failed to update domain_info: %v", err)
+		}
+	}
+
+	prio1 := doms[0].priority
+	prio2 := doms[1].priority
+	expRatio := float64(prio1) / float64(prio2)
+
+	count1, count1Ok := got[doms[0].name]
+	if !count1Ok {
+		t.Fatalf("Failed to find domain %q", doms[0].name)
+	} else {
+		delete(got, doms[0].name)
+	}
+
+	count2, count2Ok := got[doms[1].name]
+	if !count2Ok {
+		t.Fatalf("Failed to find domain %q", doms[1].name)
+	} else {
+		delete(got, doms[1].name)
+	}
+
+	gotRatio := float64(count1) / float64(count2)
+
+	tol := 0.01
+	if gotRatio < expRatio-tol || gotRatio > expRatio+tol {
+		t.Fatalf("Bad ratio: expected %f, but got %f: for a difference of %f using tol %f", expRatio, gotRatio,
+			expRatio-gotRatio, tol)
+	}
+
+	for k := range got {
+		t.Errorf("Unexpected domain %q found", k)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	orig := walker.Config.Fetcher.ActiveFetchersTTL
+	defer func() {
+		walker.Config.Fetcher.ActiveFetchersTTL = orig
+	}()
+	walker.Config.Fetcher.ActiveFetchersTTL = "1s"
+
+	db := GetTestDB()
+	ds := getDS(t)
+	read := func() (int, gocql.UUID) {
+		itr := db.Query(`SELECT tok FROM active_fetchers`).Iter()
+		var tok gocql.UUID
+		count := 0
+		for itr.Scan(&tok) {
+			count++
+		}
+		err := itr.Close()
+		if err != nil {
+			panic(err)
+		}
+		return count, tok
+	}
+
+	keepAlive := func() {
+		err := ds.KeepAlive(context.Background())
+		if err != nil {
+			t.Fatalf("Failed KeepAlive: %v", err)
+		}
+	}
+
+	count, tok := read()
+	if count != 0 {
+		t.Fatalf("Expected active_fetchers to be empty, but it wasn't")
+	}
+	keepAlive()
+	count, tok = read()
+	if count != 1 {
+		t.Fatalf("Failed to add tok to active_fetchers correctly, count was %d", count)
+	}
+
+	keepAlive()
+	count, tok2 := read()
+	if count != 1 {
+		t.Fatalf("Failed to reread count")
+	}
+	if tok != tok2 {
+		t.Fatalf("Failed to reread token")
+	}
+
+	keepAlive()
+	time.Sleep(1500 * time.Millisecond)
+	count, _ = read()
+	if count != 0 {
+		t.Fatalf("Failed to expire from active_fetchers")
+	}
+}
+
+func TestUpdateDomain(t *testing.T) {
+
+	// Check variables
+	excluded := false
+	excludeReason := ""
+	priority := 1
+
+	// Other variables
+	domain := "foo.com"
+	insertDomainInfo := `INSERT INTO domain_info (dom, excluded, exclude_reason, priority)
+						     VALUES (?, ?, ?, ?)`
+	ds := getDS(t)
+
+	// the check function will verify that the DomainInfo found in the database for foo.com, has the expected values
+	// (see the Check variables above)
+	check := func(tag string) {
+		dinfo, err := ds.FindDomain(domain)
+		if dinfo == nil || err != nil {
+			t.Fatalf("Failed to FindDomain for tag %s: %v", tag, err)
+		}
+
+		var buffer string
+
+		if dinfo.Excluded != excluded {
+			buffer += fmt.Sprintf("\tExcluded mismatch: got %v, expected %v\n", dinfo.Excluded, excluded)
+		}
+
+		if dinfo.ExcludeReason != excludeReason {
+			buffer += fmt.Sprintf("\tExcludeReason mismatch: got %q, expected %q\n", dinfo.ExcludeReason, excludeReason)
+		}
+
+		if dinfo.Priority != priority {
+			buffer += fmt.Sprintf("\tPriority mismatch: got %d, expected %d\n", dinfo.Priority, priority)
+		}
+
+		if buffer != "" {
+			t.Errorf("Domain info had unexpected problems for tag %s:\n%s", tag, buffer)
+		}
+	}
+
+	db := GetTestDB()
+	err := db.Query(insertDomainInfo, domain, excluded, excludeReason, priority).Exec()
+	if err != nil {
+		t.Fatalf("Unexpected error during domain insert: %v", err)
+	}
+	check("First Insert")
+
+	excluded = true
+	excludeReason = "Excluded reason"
+	ds.UpdateDomain(domain, &DomainInfo{Excluded: excluded, ExcludeReason: excludeReason},
+		DomainInfoUpdateConfig{Exclude: true})
+	check("Exclude updated")
+
+	excluded = false
+	excludeReason = ""
+	ds.UpdateDomain(domain, &DomainInfo{Excluded: excluded, ExcludeReason: excludeReason},
+		DomainInfoUpdateConfig{Exclude: true})
+	check("clear 1")
+
+	priority = 4
+	ds.UpdateDomain(domain, &DomainInfo{Priority: priority}, DomainInfoUpdateConfig{Priority: true})
+	check("Priority")
+
+	priority = 1
+	ds.UpdateDomain(domain, &DomainInfo{Priority: priority}, DomainInfoUpdateConfig{Priority: true})
+	check("clear 2")
+
+	excluded = true
+	excludeReason = "Excluded reason"
+	priority = 4
+	ds.UpdateDomain(domain, &DomainInfo{
+		Excluded:      excluded,
+		ExcludeReason: excludeReason,
+		Priority:      priority,
+	}, DomainInfoUpdateConfig{Exclude: true, Priority: true})
+	check("Priority & Exclude")
+
+}
+
+func TestExcludeDomainsMatching(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+
+	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, priority, dispatched) VALUES (?, ?, 1, false)`
+	domains := []string{"spam1.com", "spam22.com", "notspam.com", "good.org"}
+	for _, d := range domains {
+		err := db.Query(insertDomainInfo, d, gocql.UUID{}).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain %v: %v", d, err)
+		}
+	}
+
+	// Force paging through domain_info
+	origPageSize := excludeDomainsPageSize
+	defer func() { excludeDomainsPageSize = origPageSize }()
+	excludeDomainsPageSize = 1
+
+	count, err := ds.ExcludeDomainsMatching(`^spam[0-9]+\.com$`, "Spam network")
+	if err != nil {
+		t.Fatalf("ExcludeDomainsMatching failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 domains excluded, got %d", count)
+	}
+
+	expected := map[string]string{
+		"spam1.com":   "Spam network",
+		"spam22.com":  "Spam network",
+		"notspam.com": "",
+		"good.org":    "",
+	}
+	for dom, reason := range expected {
+		dinfo, err := ds.FindDomain(dom)
+		if err != nil || dinfo == nil {
+			t.Fatalf("FindDomain(%v) failed: %v", dom, err)
+		}
+		if dinfo.Excluded != (reason != "") {
+			t.Errorf("Excluded mismatch for %v: got %v", dom, dinfo.Excluded)
+		}
+		if dinfo.ExcludeReason != reason {
+			t.Errorf("ExcludeReason mismatch for %v: got %q, expected %q", dom, dinfo.ExcludeReason, reason)
+		}
+	}
+
+	if _, err := ds.ExcludeDomainsMatching(`(`, "Bad pattern"); err == nil {
+		t.Errorf("Expected an error for a bad pattern")
+	}
+	if _, err := ds.ExcludeDomainsMatching(`.*`, ""); err == nil {
+		t.Errorf("Expected an error for an empty reason")
+	}
+}
+
+func TestUpdateDomains(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+
+	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, priority, dispatched, tags) VALUES (?, ?, 1, false, ?)`
+	domains := map[string][]string{
+		"news1.com": {"news"},
+		"news2.com": {"news"},
+		"news3.com": {"news"},
+		"other.com": nil,
+	}
+	for d, tags := range domains {
+		err := db.Query(insertDomainInfo, d, gocql.UUID{}, tags).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain %v: %v", d, err)
+		}
+	}
+
+	// Force paging through domain_info
+	origPageSize := updateDomainsPageSize
+	defer func() { updateDomainsPageSize = origPageSize }()
+	updateDomainsPageSize = 1
+
+	result, err := ds.UpdateDomains(DQ{Tag: "news"}, &DomainInfo{Priority: 5}, DomainInfoUpdateConfig{Priority: true})
+	if err != nil {
+		t.Fatalf("UpdateDomains failed: %v", err)
+	}
+	if result.Matched != 3 || result.Updated != 3 || len(result.Conflicts) != 0 {
+		t.Errorf("Expected 3 domains matched and updated, got %+v", result)
+	}
+	for d, tags := range domains {
+		dinfo, err := ds.FindDomain(d)
+		if err != nil || dinfo == nil {
+			t.Fatalf("FindDomain(%v) failed: %v", d, err)
+		}
+		expected := 1
+		if len(tags) > 0 {
+			expected = 5
+		}
+		if dinfo.Priority != expected {
+			t.Errorf("Expected priority %d for %v, got %d", expected, d, dinfo.Priority)
+		}
+	}
+
+	// With IfUnchanged, a domain whose priority differs from what was listed
+	// (as if changed concurrently) is skipped
+	cfg := DomainInfoUpdateConfig{Priority: true, Exclude: true, IfUnchanged: true}
+	applied, err := ds.updateDomainIfUnchanged(&DomainInfo{Domain: "news1.com", Priority: 2}, []string{"priority"},
+		[]interface{}{7}, DomainInfoUpdateConfig{Priority: true})
+	if err != nil || applied {
+		t.Errorf("Expected a stale update not to apply, got %v (%v)", applied, err)
+	}
+	result, err = ds.UpdateDomains(DQ{Limit: 2}, &DomainInfo{Priority: 7, Excluded: true, ExcludeReason: "Bulk"}, cfg)
+	if err != nil {
+		t.Fatalf("UpdateDomains failed: %v", err)
+	}
+	if result.Matched != 2 || result.Updated != 2 {
+		t.Errorf("Expected 2 domains matched and updated, got %+v", result)
+	}
+
+	if _, err := ds.UpdateDomains(DQ{}, &DomainInfo{}, DomainInfoUpdateConfig{}); err == nil {
+		t.Errorf("Expected an error for an empty DomainInfoUpdateConfig")
+	}
+}
+
+func TestPauseDomain(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+
+	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, dispatched, priority) VALUES (?, 00000000-0000-0000-0000-000000000000, true, 10)`
+	for _, d := range []string{"paused.com", "running.com"} {
+		err := db.Query(insertDomainInfo, d).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain %v: %v", d, err)
+		}
+	}
+
+	if err := ds.PauseDomain("paused.com"); err != nil {
+		t.Fatalf("PauseDomain failed: %v", err)
+	}
+	dinfo, err := ds.FindDomain("paused.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if !dinfo.Paused {
+		t.Errorf("Expected paused.com to be paused")
+	}
+
+	ctx := context.Background()
+	var claimed []string
+	for host := ds.ClaimNewHost(ctx); host != ""; host = ds.ClaimNewHost(ctx) {
+		claimed = append(claimed, host)
+		ds.UnclaimHost(ctx, host)
+	}
+	if len(claimed) != 1 || claimed[0] != "running.com" {
+		t.Errorf("Expected to claim only running.com, got %v", claimed)
+	}
+
+	if err := ds.ResumeDomain("paused.com"); err != nil {
+		t.Fatalf("ResumeDomain failed: %v", err)
+	}
+	dinfo, err = ds.FindDomain("paused.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if dinfo.Paused {
+		t.Errorf("Expected paused.com to be resumed")
+	}
+
+	ds.Close()
+	ds = getDS(t)
+	defer ds.Close()
+	host := ds.ClaimNewHost(ctx)
+	if host != "paused.com" {
+		t.Errorf("Expected to claim resumed domain paused.com, got %q", host)
+	}
+}
+
+func TestMarkGetNow(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	crawled := time.Now().Add(-time.Hour)
+	err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time)
+					 VALUES (?, ?, ?, ?, ?)`, "test.com", "", "/page1.html", "http", walker.NotYetCrawled).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert link: %v", err)
+	}
+	err = db.Query(`INSERT INTO links (dom, subdom, path, proto, time, stat)
+					 VALUES (?, ?, ?, ?, ?, ?)`, "test.com", "", "/page1.html", "http", crawled, 200).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert link: %v", err)
+	}
+
+	getnow := func(u *walker.URL) []bool {
+		linfos, err := ds.ListLinkHistorical(u)
+		if err != nil {
+			t.Fatalf("ListLinkHistorical failed: %v", err)
+		}
+		var flags []bool
+		for _, linfo := range linfos {
+			flags = append(flags, linfo.GetNow)
+		}
+		return flags
+	}
+
+	// Only the latest crawl is marked
+	u := walker.MustParse("http://test.com/page1.html")
+	if err := ds.MarkGetNow(u); err != nil {
+		t.Fatalf("MarkGetNow failed: %v", err)
+	}
+	if got := getnow(u); !reflect.DeepEqual(got, []bool{false, true}) {
+		t.Errorf("Expected only the latest crawl of %v to be getnow, got %v", u, got)
+	}
+
+	// Unknown links are added, along with their domain
+	u = walker.MustParse("http://www.new.com/page1.html")
+	if err := ds.MarkGetNow(u); err != nil {
+		t.Fatalf("MarkGetNow failed: %v", err)
+	}
+	if got := getnow(u); !reflect.DeepEqual(got, []bool{true}) {
+		t.Errorf("Expected %v to be added as getnow, got %v", u, got)
+	}
+	dinfo, err := ds.FindDomain("new.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if dinfo == nil || dinfo.Excluded {
+		t.Errorf("Expected new.com to be added to the crawl, got %+v", dinfo)
+	}
+}
+
+func TestDatastoreKeyspaces(t *testing.T) {
+	GetTestDB()
+	otherKeyspace := "walker_test_tenant2"
+	if err := CreateSchemaForKeyspace(otherKeyspace); err != nil {
+		t.Fatalf("Failed to create %v schema: %v", otherKeyspace, err)
+	}
+
+	ds := getDS(t)
+	defer ds.Close()
+	other, err := NewDatastoreForKeyspace(otherKeyspace)
+	if err != nil {
+		t.Fatalf("Failed to create datastore for %v: %v", otherKeyspace, err)
+	}
+	defer other.Close()
+
+	if err := other.InsertLink("http://tenant2.com/page1.html", ""); err != nil {
+		t.Fatalf("InsertLink failed: %v", err)
+	}
+
+	u := walker.MustParse("http://tenant2.com/page1.html")
+	linfo, err := other.FindLink(u, false)
+	if err != nil || linfo == nil {
+		t.Errorf("Expected to find link in %v, got %v (err %v)", otherKeyspace, linfo, err)
+	}
+	linfo, err = ds.FindLink(u, false)
+	if err != nil {
+		t.Fatalf("FindLink failed: %v", err)
+	}
+	if linfo != nil {
+		t.Errorf("Link inserted in %v leaked into %v", otherKeyspace, walker.Config.Cassandra.Keyspace)
+	}
+	dinfo, err := ds.FindDomain("tenant2.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if dinfo != nil {
+		t.Errorf("Domain inserted in %v leaked into %v", otherKeyspace, walker.Config.Cassandra.Keyspace)
+	}
+
+	if _, err := NewDatastoreForKeyspace("bad-keyspace; DROP"); err == nil {
+		t.Errorf("Expected an error for an invalid keyspace name")
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	var sink walker.DeadLetterSink = ds
+	u := walker.MustParse("http://sub.dead.com/page1.html")
+	fr := &walker.FetchResults{
+		URL:      u,
+		Response: &http.Response{StatusCode: 200},
+	}
+	sink.DeadLetter(context.Background(), fr, fmt.Errorf("handler panicked: boom"))
+
+	var url, errStr string
+	var stat int
+	err := db.Query(`SELECT url, stat, err FROM handler_dead_letters WHERE dom = ?`, "dead.com").Scan(&url, &stat, &errStr)
+	if err != nil {
+		t.Fatalf("Failed to read dead letter: %v", err)
+	}
+	if url != u.String() {
+		t.Errorf("Dead letter url mismatch: got %q, expected %q", url, u.String())
+	}
+	if stat != 200 {
+		t.Errorf("Dead letter stat mismatch: got %d, expected 200", stat)
+	}
+	if errStr != "handler panicked: boom" {
+		t.Errorf("Dead letter err mismatch: got %q", errStr)
+	}
+}
+
+func TestLinkSampling(t *testing.T) {
+	origThreshold := walker.Config.Cassandra.SampleThreshold
+	origPercent := walker.Config.Cassandra.SamplePercent
+	defer func() {
+		walker.Config.Cassandra.SampleThreshold = origThreshold
+		walker.Config.Cassandra.SamplePercent = origPercent
+	}()
+	walker.Config.Cassandra.SampleThreshold = 5
+	walker.Config.Cassandra.SamplePercent = 25.0
+
+	db := GetTestDB()
+	if err := db.Query(`DELETE FROM walker_globals WHERE key = ?`, domainSamplingKey).Exec(); err != nil {
+		t.Fatalf("Failed to clear %v: %v", domainSamplingKey, err)
+	}
+	insertDomainInfo := `INSERT INTO domain_info (dom, claim_tok, dispatched, priority, uncrawled_links)
+						 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1, ?)`
+	domains := map[string]int{
+		"big.com":    100, // sampled
+		"small.com":  2,   // under the threshold
+		"exempt.com": 100, // sampled, but overridden below
+	}
+	for d, uncrawled := range domains {
+		if err := db.Query(insertDomainInfo, d, uncrawled).Exec(); err != nil {
+			t.Fatalf("Failed to insert domain %v: %v", d, err)
+		}
+	}
+
+	ds := getDS(t)
+	defer ds.Close()
+	err := ds.UpdateDomain("exempt.com", &DomainInfo{SamplePercent: 100}, DomainInfoUpdateConfig{Sampling: true})
+	if err != nil {
+		t.Fatalf("UpdateDomain failed: %v", err)
+	}
+
+	numLinks := 200
+	for d := range domains {
+		expected := map[string]bool{}
+		for i := 0; i < numLinks; i++ {
+			u := walker.MustParse(fmt.Sprintf("http://%s/page%d.html", d, i))
+			// Store each link twice; sampling must choose the same links
+			ds.StoreParsedURL(context.Background(), u, nil)
+			ds.StoreParsedURL(context.Background(), u, nil)
+			if d != "big.com" || sampleHash(u) < 25.0 {
+				expected[u.RequestURI()] = true
+			}
+		}
+
+		got := map[string]bool{}
+		itr := db.Query(`SELECT path FROM links WHERE dom = ?`, d).Iter()
+		var path string
+		for itr.Scan(&path) {
+			got[path] = true
+		}
+		if err := itr.Close(); err != nil {
+			t.Fatalf("Failed to read links for %v: %v", d, err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Stored links mismatch for %v: got %d links, expected %d", d, len(got), len(expected))
+		}
+		if d == "big.com" && (len(got) < numLinks/8 || len(got) > numLinks/2) {
+			t.Errorf("Expected roughly 25%% of %d links to be sampled for big.com, got %d", numLinks, len(got))
+		}
+	}
+
+	// With sampling off in the config and no domain sampled on its own,
+	// domain_info isn't read for sampling settings
+	walker.Config.Cassandra.SampleThreshold = 0
+	ds.sampleCache.Purge()
+	ds.StoreParsedURL(context.Background(), walker.MustParse("http://big.com/unsampled.html"), nil)
+	if ds.sampleCache.Len() != 0 {
+		t.Errorf("Expected sampling settings not to be read with sampling off everywhere")
+	}
+	if linfo, err := ds.FindLink(walker.MustParse("http://big.com/unsampled.html"), false); err != nil || linfo == nil {
+		t.Errorf("Expected link to be stored with sampling off: %v", err)
+	}
+
+	// A domain's own threshold applies with sampling off in the config
+	if err := db.Query(insertDomainInfo, "own.com", 100).Exec(); err != nil {
+		t.Fatalf("Failed to insert domain own.com: %v", err)
+	}
+	err = ds.UpdateDomain("own.com", &DomainInfo{SampleThreshold: 5, SamplePercent: 25},
+		DomainInfoUpdateConfig{Sampling: true})
+	if err != nil {
+		t.Fatalf("UpdateDomain failed: %v", err)
+	}
+	stored := 0
+	for i := 0; i < numLinks; i++ {
+		u := walker.MustParse(fmt.Sprintf("http://own.com/page%d.html", i))
+		ds.StoreParsedURL(context.Background(), u, nil)
+		if sampleHash(u) < 25.0 {
+			stored++
+		}
+	}
+	var count int
+	if err := db.Query(`SELECT COUNT(*) FROM links WHERE dom = ?`, "own.com").Scan(&count); err != nil {
+		t.Fatalf("Failed to count links of own.com: %v", err)
+	}
+	if count != stored {
+		t.Errorf("Expected %d sampled links for own.com, got %d", stored, count)
+	}
+}
+
+func TestStoreParsedURLNofollow(t *testing.T) {
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1)`, "test.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	ds := getDS(t)
+	defer ds.Close()
+
+	followed := walker.MustParse("http://test.com/followed.html")
+	nofollow := walker.MustParse("http://test.com/nofollow.html")
+	nofollow.Nofollow = true
+	ds.StoreParsedURL(context.Background(), followed, nil)
+	ds.StoreParsedURL(context.Background(), nofollow, nil)
+
+	// Crawling the link should not lose the flag
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       walker.MustParse(nofollow.String()),
+		FetchTime: time.Now(),
+		Response:  &http.Response{StatusCode: 200},
+	})
+
+	for _, u := range []*walker.URL{followed, nofollow} {
+		linfo, err := ds.FindLink(u, false)
+		if err != nil {
+			t.Fatalf("FindLink(%v) failed: %v", u, err)
+		}
+		if linfo == nil {
+			t.Fatalf("Expected to find %v", u)
+		}
+		if linfo.Nofollow != u.Nofollow {
+			t.Errorf("Nofollow mismatch for %v, got %v, expected %v", u, linfo.Nofollow, u.Nofollow)
+		}
+	}
+
+	page, err := ds.ListLinks("test.com", LQ{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListLinks failed: %v", err)
+	}
+	linfos := page.Links
+	if len(linfos) != 2 {
+		t.Errorf("Expected 2 links, got %d", len(linfos))
+	}
+	for _, linfo := range linfos {
+		expected := linfo.URL.String() == nofollow.String()
+		if linfo.Nofollow != expected {
+			t.Errorf("ListLinks Nofollow mismatch for %v, got %v, expected %v", linfo.URL, linfo.Nofollow, expected)
+		}
+	}
+}
+
+func TestStoreParsedURLJSRedirect(t *testing.T) {
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1)`, "test.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	ds := getDS(t)
+	defer ds.Close()
+
+	redirect := walker.MustParse("http://test.com/redirect.html")
+	redirect.JSRedirect = true
+	ds.StoreParsedURL(context.Background(), redirect, nil)
+	ds.StoreParsedURLs(context.Background(), []*walker.URL{walker.MustParse("http://test.com/plain.html")}, nil)
+
+	// Crawling the link should not lose the flag
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       walker.MustParse(redirect.String()),
+		FetchTime: time.Now(),
+		Response:  &http.Response{StatusCode: 200},
+	})
+
+	page, err := ds.ListLinks("test.com", LQ{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListLinks failed: %v", err)
+	}
+	if len(page.Links) != 2 {
+		t.Errorf("Expected 2 links, got %d", len(page.Links))
+	}
+	for _, linfo := range page.Links {
+		expected := linfo.URL.String() == redirect.String()
+		if linfo.JSRedirect != expected {
+			t.Errorf("JSRedirect mismatch for %v, got %v, expected %v", linfo.URL, linfo.JSRedirect, expected)
+		}
+	}
+
+	history, err := ds.ListLinkHistorical(redirect)
+	if err != nil {
+		t.Fatalf("ListLinkHistorical failed: %v", err)
+	}
+	var flagged bool
+	for _, linfo := range history {
+		flagged = flagged || linfo.JSRedirect
+	}
+	if !flagged {
+		t.Errorf("Expected the history of %v to include its js_redirect flag", redirect)
+	}
+}
+
+func TestStoreParsedURLs(t *testing.T) {
+	origBatchSize := walker.Config.Cassandra.ParsedLinkBatchSize
+	origAddNewDomains := walker.Config.Cassandra.AddNewDomains
+	defer func() {
+		walker.Config.Cassandra.ParsedLinkBatchSize = origBatchSize
+		walker.Config.Cassandra.AddNewDomains = origAddNewDomains
+	}()
+	walker.Config.Cassandra.ParsedLinkBatchSize = 2
+	walker.Config.Cassandra.AddNewDomains = false
+
+	db := GetTestDB()
+	for _, dom := range []string{"test.com", "other.com"} {
+		err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority)
+						 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1)`, dom).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain: %v", err)
+		}
+	}
+	ds := getDS(t)
+	defer ds.Close()
+
+	urls := []*walker.URL{
+		walker.MustParse("http://test.com/1.html"),
+		walker.MustParse("http://other.com/1.html"),
+		walker.MustParse("http://sub.test.com/2.html"),
+		walker.MustParse("http://unknown.com/1.html"),
+		walker.MustParse("http://test.com/3.html"),
+	}
+	urls[4].Nofollow = true
+
+	before := CurrentWriteStats().Batches
+	ds.StoreParsedURLs(context.Background(), urls, nil)
+
+	// test.com's 3 links take 2 batches, other.com's 1 link takes 1
+	if batches := CurrentWriteStats().Batches - before; batches != 3 {
+		t.Errorf("Expected 3 batches written, got %d", batches)
+	}
+	for i, u := range urls {
+		linfo, err := ds.FindLink(u, false)
+		if err != nil {
+			t.Fatalf("FindLink(%v) failed: %v", u, err)
+		}
+		if u.Host == "unknown.com" {
+			if linfo != nil {
+				t.Errorf("Expected %v not to be stored, its domain is not crawled", u)
+			}
+			continue
+		}
+		if linfo == nil {
+			t.Errorf("Expected to find %v", u)
+			continue
+		}
+		if !linfo.CrawlTime.Equal(walker.NotYetCrawled) {
+			t.Errorf("Expected %v to be uncrawled, got crawl time %v", u, linfo.CrawlTime)
+		}
+		if linfo.Nofollow != (i == 4) {
+			t.Errorf("Nofollow mismatch for %v, got %v", u, linfo.Nofollow)
+		}
+	}
+}
+
+func TestFrontierEstimate(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority, uncrawled_links, queued_links)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, true, 1, ?, ?)`, "frontier.com", 100, 10).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	est, err := ds.FrontierEstimate("nosuchdomain.com")
+	if err != nil || est != nil {
+		t.Errorf("Expected nil estimate for a missing domain, got %v (err %v)", est, err)
+	}
+
+	// Without fetches the rate comes from the crawl delay
+	est, err = ds.FrontierEstimate("frontier.com")
+	if err != nil {
+		t.Fatalf("FrontierEstimate failed: %v", err)
+	}
+	if est.Queued != 10 || est.Uncrawled != 100 {
+		t.Errorf("Expected 10 queued and 100 uncrawled links, got %d and %d", est.Queued, est.Uncrawled)
+	}
+	if !est.RateFromCrawlDelay || est.RecentFetches != 0 {
+		t.Errorf("Expected rate to come from the crawl delay: %+v", est)
+	}
+
+	numFetches := 30
+	for i := 0; i < numFetches; i++ {
+		ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:       walker.MustParse(fmt.Sprintf("http://frontier.com/page%d.html", i)),
+			FetchTime: time.Now(),
+			Response:  &http.Response{StatusCode: 200},
+		})
+	}
+
+	est, err = ds.FrontierEstimate("frontier.com")
+	if err != nil {
+		t.Fatalf("FrontierEstimate failed: %v", err)
+	}
+	if est.RateFromCrawlDelay {
+		t.Errorf("Expected rate to come from recent fetches")
+	}
+	if est.RecentFetches != int64(numFetches) {
+		t.Errorf("Expected %d recent fetches, got %d", numFetches, est.RecentFetches)
+	}
+	if est.Rate <= 0 {
+		t.Fatalf("Expected a positive rate, got %v", est.Rate)
+	}
+	expected := time.Duration(100 / est.Rate * float64(time.Second))
+	if est.UncrawledDrain != expected {
+		t.Errorf("Expected UncrawledDrain %v, got %v", expected, est.UncrawledDrain)
+	}
+	if est.QueuedDrain >= est.UncrawledDrain {
+		t.Errorf("Expected segment to drain before the whole backlog: %v >= %v", est.QueuedDrain, est.UncrawledDrain)
+	}
+}
+
+func TestStoreTransientFailureRetries(t *testing.T) {
+	origBackoff := walker.Config.Fetcher.TransientRetryBackoff
+	origMax := walker.Config.Fetcher.TransientRetryMaxBackoff
+	defer func() {
+		walker.Config.Fetcher.TransientRetryBackoff = origBackoff
+		walker.Config.Fetcher.TransientRetryMaxBackoff = origMax
+	}()
+	walker.Config.Fetcher.TransientRetryBackoff = "1m"
+	walker.Config.Fetcher.TransientRetryMaxBackoff = "3m"
+
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	store := func(fetchTime time.Time, status int) {
+		ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:       walker.MustParse("http://test.com/flaky.html"),
+			FetchTime: fetchTime,
+			Response:  &http.Response{StatusCode: status},
+		})
+	}
+	latest := func() (int, time.Time) {
+		var retries int
+		var nextRetryAt time.Time
+		err := db.Query(`SELECT retries, next_retry_at FROM links
+						 WHERE dom = 'test.com' AND subdom = '' AND path = '/flaky.html' AND proto = 'http'
+						 ORDER BY time DESC LIMIT 1`).Scan(&retries, &nextRetryAt)
+		if err != nil {
+			t.Fatalf("Failed to read link: %v", err)
 		}
-		return count, tok
+		return retries, nextRetryAt
 	}
 
-	keepAlive := func() {
-		err := ds.KeepAlive()
+	start := time.Now().Truncate(time.Millisecond)
+	expected := []struct {
+		retries int
+		backoff time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 3 * time.Minute}, // capped by transient_retry_max_backoff
+	}
+	for i, exp := range expected {
+		fetchTime := start.Add(time.Duration(i) * time.Second)
+		store(fetchTime, 503)
+		retries, nextRetryAt := latest()
+		if retries != exp.retries {
+			t.Errorf("Failure %d: expected retries %d, got %d", i+1, exp.retries, retries)
+		}
+		if !nextRetryAt.Equal(fetchTime.Add(exp.backoff)) {
+			t.Errorf("Failure %d: expected next_retry_at %v, got %v", i+1, fetchTime.Add(exp.backoff), nextRetryAt)
+		}
+	}
+
+	// A success resets the count
+	store(start.Add(time.Minute), 200)
+	if retries, nextRetryAt := latest(); retries != 0 || !nextRetryAt.IsZero() {
+		t.Errorf("Expected success to clear retries, got %d, %v", retries, nextRetryAt)
+	}
+	store(start.Add(2*time.Minute), 500)
+	if retries, _ := latest(); retries != 1 {
+		t.Errorf("Expected retries to restart at 1, got %d", retries)
+	}
+}
+
+func TestStoreRefetchAfter(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	fetchTime := time.Now().Truncate(time.Millisecond)
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       walker.MustParse("http://test.com/cached.css"),
+		FetchTime: fetchTime,
+		Response: &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Cache-Control": []string{"max-age=3600"}},
+		},
+	})
+
+	var refetchAfter time.Time
+	err := db.Query(`SELECT refetch_after FROM links
+					 WHERE dom = 'test.com' AND subdom = '' AND path = '/cached.css' AND proto = 'http'
+					 AND time = ?`, fetchTime).Scan(&refetchAfter)
+	if err != nil {
+		t.Fatalf("Failed to read link: %v", err)
+	}
+	if !refetchAfter.Equal(fetchTime.Add(time.Hour)) {
+		t.Errorf("Expected refetch_after %v, got %v", fetchTime.Add(time.Hour), refetchAfter)
+	}
+}
+
+func TestStoredResponse(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	crawled := time.Now().Add(-time.Hour)
+	inserts := []struct {
+		time    time.Time
+		stat    interface{}
+		headers map[string]string
+		body    string
+	}{
+		{crawled.Add(-time.Hour), 200, nil, "old body"},
+		{crawled, 200, map[string]string{"Content-Type": "text/html", "X-Multi": "a\000b"}, "<html>new body</html>"},
+		// a later failed fetch has no response to replay
+		{crawled.Add(time.Minute), nil, nil, ""},
+	}
+	for _, ins := range inserts {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, stat, headers, body)
+						 VALUES ('test.com', '', '/page.html', 'http', ?, ?, ?, ?)`,
+			ins.time, ins.stat, ins.headers, ins.body).Exec()
 		if err != nil {
-			t.Fatalf("Failed KeepAlive: %v", err)
+			t.Fatalf("Failed to insert link: %v", err)
 		}
 	}
 
-	count, tok := read()
-	if count != 0 {
-		t.Fatalf("Expected active_fetchers to be empty, but it wasn't")
+	res, err := ds.StoredResponse(walker.MustParse("http://test.com/page.html"))
+	if err != nil {
+		t.Fatalf("StoredResponse failed: %v", err)
 	}
-	keepAlive()
-	count, tok = read()
-	if count != 1 {
-		t.Fatalf("Failed to add tok to active_fetchers correctly, count was %d", count)
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != 200 || string(body) != "<html>new body</html>" {
+		t.Errorf("Expected the latest successful response, got %v %q", res.StatusCode, body)
+	}
+	expectedHeader := http.Header{"Content-Type": []string{"text/html"}, "X-Multi": []string{"a", "b"}}
+	if !reflect.DeepEqual(res.Header, expectedHeader) {
+		t.Errorf("Expected headers %v, got %v", expectedHeader, res.Header)
 	}
 
-	keepAlive()
-	count, tok2 := read()
+	if _, err := ds.StoredResponse(walker.MustParse("http://test.com/missing.html")); err != walker.ErrNotStored {
+		t.Errorf("Expected ErrNotStored for a missing link, got %v", err)
+	}
+}
+
+func TestStoreNotModified(t *testing.T) {
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+	ctx := context.Background()
+
+	crawled := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	ds.StoreURLFetchResults(ctx, &walker.FetchResults{
+		URL:                walker.MustParse("http://test.com/page.html"),
+		FetchTime:          crawled,
+		Response:           &http.Response{StatusCode: 200},
+		Body:               "<html>the body</html>",
+		MimeType:           "text/html",
+		Title:              "The Page",
+		FnvFingerprint:     1,
+		FnvTextFingerprint: 2,
+	})
+
+	// Two 304s in a row should both point to the body of the 200
+	u := walker.MustParse("http://test.com/page.html")
+	for i := 1; i <= 2; i++ {
+		u.LastCrawled = crawled.Add(time.Duration(i-1) * time.Minute)
+		ds.StoreURLFetchResults(ctx, &walker.FetchResults{
+			URL:         u,
+			FetchTime:   crawled.Add(time.Duration(i) * time.Minute),
+			Response:    &http.Response{StatusCode: http.StatusNotModified},
+			NotModified: true,
+		})
+	}
+
+	for i := 1; i <= 2; i++ {
+		c, err := ds.crawlAt(u, crawled.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("crawlAt failed: %v", err)
+		}
+		if c.status != http.StatusNotModified || c.fnv != 1 || c.fnvText != 2 || c.mime != "text/html" ||
+			c.title != "The Page" || c.body != "<html>the body</html>" || !c.bodyTime.Equal(crawled) {
+			t.Errorf("Expected 304 %d to copy the 200 forward, got %+v", i, c)
+		}
+	}
+
+	linfo, err := ds.FindLink(u, true)
+	if err != nil {
+		t.Fatalf("FindLink failed: %v", err)
+	}
+	if linfo.Status != http.StatusNotModified || linfo.Body != "<html>the body</html>" {
+		t.Errorf("Expected FindLink to return the copied forward body, got %v %q", linfo.Status, linfo.Body)
+	}
+
+	res, err := ds.StoredResponse(u)
+	if err != nil {
+		t.Fatalf("StoredResponse failed: %v", err)
+	}
+	if res.StatusCode != 200 {
+		t.Errorf("Expected StoredResponse to skip the 304s, got %v", res.StatusCode)
+	}
+
+	diff, err := ds.DiffLink(u, crawled, crawled.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("DiffLink failed: %v", err)
+	}
+	if diff.Changed || diff.TextChanged {
+		t.Errorf("Expected no change across a 304, got %+v", diff)
+	}
+}
+
+func TestPendingDomains(t *testing.T) {
+	origAdd := walker.Config.Cassandra.AddNewDomains
+	origTrack := walker.Config.Cassandra.TrackPendingDomains
+	defer func() {
+		walker.Config.Cassandra.AddNewDomains = origAdd
+		walker.Config.Cassandra.TrackPendingDomains = origTrack
+	}()
+	walker.Config.Cassandra.AddNewDomains = false
+	walker.Config.Cassandra.TrackPendingDomains = true
+
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	for _, link := range []string{
+		"http://a.com/page1.html",
+		"http://www.a.com/page2.html",
+		"http://b.com/page1.html",
+		"http://a.com/page3.html",
+	} {
+		ds.StoreParsedURL(context.Background(), walker.MustParse(link), nil)
+	}
+
+	pending, err := ds.ListPendingDomains(0)
+	if err != nil {
+		t.Fatalf("ListPendingDomains failed: %v", err)
+	}
+	expected := []*PendingDomain{
+		{Domain: "a.com", References: 3},
+		{Domain: "b.com", References: 1},
+	}
+	if !reflect.DeepEqual(pending, expected) {
+		t.Fatalf("Expected pending domains %v, got %v", expected, pending)
+	}
+
+	if err := ds.ApprovePendingDomain("a.com"); err != nil {
+		t.Fatalf("ApprovePendingDomain failed: %v", err)
+	}
+	if err := ds.RejectPendingDomain("b.com", "not relevant"); err != nil {
+		t.Fatalf("RejectPendingDomain failed: %v", err)
+	}
+
+	dinfo, err := ds.FindDomain("a.com")
+	if err != nil || dinfo == nil {
+		t.Fatalf("Expected to find approved domain a.com: %v", err)
+	}
+	if dinfo.Excluded {
+		t.Errorf("Expected a.com not to be excluded")
+	}
+	dinfo, err = ds.FindDomain("b.com")
+	if err != nil || dinfo == nil {
+		t.Fatalf("Expected to find rejected domain b.com: %v", err)
+	}
+	if !dinfo.Excluded || dinfo.ExcludeReason != "not relevant" {
+		t.Errorf("Expected b.com to be excluded for %q, got %v, %q", "not relevant", dinfo.Excluded, dinfo.ExcludeReason)
+	}
+
+	// Both are known now, so neither becomes pending again
+	ds.StoreParsedURL(context.Background(), walker.MustParse("http://b.com/page2.html"), nil)
+	ds.StoreParsedURL(context.Background(), walker.MustParse("http://a.com/page4.html"), nil)
+	pending, err = ds.ListPendingDomains(0)
+	if err != nil {
+		t.Fatalf("ListPendingDomains failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending domains, got %v", pending)
+	}
+	linfo, err := ds.FindLink(walker.MustParse("http://a.com/page4.html"), false)
+	if err != nil || linfo == nil {
+		t.Errorf("Expected links of the approved domain to be stored: %v", err)
+	}
+
+	// A stale reference counted by a fetcher that cached a.com as unknown is
+	// skipped, and listing leaves the row alone
+	db := GetTestDB()
+	if err := db.Query(`UPDATE pending_domains SET refs = refs + 1 WHERE dom = ?`, "a.com").Exec(); err != nil {
+		t.Fatalf("Failed to add stale pending domain: %v", err)
+	}
+	pending, err = ds.ListPendingDomains(0)
+	if err != nil {
+		t.Fatalf("ListPendingDomains failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected known domain not to be listed as pending, got %v", pending)
+	}
+	var count int
+	if err := db.Query(`SELECT COUNT(*) FROM pending_domains WHERE dom = ?`, "a.com").Scan(&count); err != nil {
+		t.Fatalf("Failed to count pending domains: %v", err)
+	}
 	if count != 1 {
-		t.Fatalf("Failed to reread count")
+		t.Errorf("Expected ListPendingDomains not to delete the stale row, found %v rows", count)
 	}
-	if tok != tok2 {
-		t.Fatalf("Failed to reread token")
+}
+
+func TestBandwidthUsage(t *testing.T) {
+	origBudget := walker.Config.Dispatcher.DomainByteBudget
+	defer func() {
+		walker.Config.Dispatcher.DomainByteBudget = origBudget
+	}()
+	walker.Config.Dispatcher.DomainByteBudget = 1500
+
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1)`, "test.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
 	}
+	ds := getDS(t)
+	defer ds.Close()
 
-	keepAlive()
-	time.Sleep(1500 * time.Millisecond)
-	count, _ = read()
-	if count != 0 {
-		t.Fatalf("Failed to expire from active_fetchers")
+	for _, path := range []string{"/page1.html", "/page2.html"} {
+		ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:       walker.MustParse("http://test.com" + path),
+			FetchTime: time.Now(),
+			Response:  &http.Response{StatusCode: 200},
+			Timing:    walker.FetchTiming{Bytes: 500},
+		})
+	}
+
+	usage, err := ds.BandwidthUsage("test.com")
+	if err != nil {
+		t.Fatalf("BandwidthUsage failed: %v", err)
+	}
+	if usage.TotalBytes != 1000 || usage.WindowBytes != 1000 {
+		t.Errorf("Expected 1000 total and window bytes, got %d and %d", usage.TotalBytes, usage.WindowBytes)
+	}
+	if usage.Budget != 1500 || usage.Remaining() != 500 {
+		t.Errorf("Expected a budget of 1500 with 500 remaining, got %d with %d", usage.Budget, usage.Remaining())
+	}
+
+	err = ds.UpdateDomain("test.com", &DomainInfo{ByteBudget: -1}, DomainInfoUpdateConfig{ByteBudget: true})
+	if err != nil {
+		t.Fatalf("UpdateDomain failed: %v", err)
+	}
+	usage, err = ds.BandwidthUsage("test.com")
+	if err != nil {
+		t.Fatalf("BandwidthUsage failed: %v", err)
+	}
+	if usage.Budget != 0 || usage.Remaining() != -1 {
+		t.Errorf("Expected no budget after override, got %d with %d remaining", usage.Budget, usage.Remaining())
+	}
+
+	usage, err = ds.BandwidthUsage("nosuchdomain.com")
+	if err != nil || usage != nil {
+		t.Errorf("Expected nil usage for unknown domain, got %v, %v", usage, err)
 	}
 }
 
-func TestUpdateDomain(t *testing.T) {
+func TestMimeTypeCounts(t *testing.T) {
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1)`, "test.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	ds := getDS(t)
+	defer ds.Close()
 
-	// Check variables
-	excluded := false
-	excludeReason := ""
-	priority := 1
+	fetches := []struct {
+		path   string
+		status int
+		mime   string
+	}{
+		{"/page1.html", 200, "text/html"},
+		{"/page2.html", 200, "text/html"},
+		{"/doc.pdf", 200, "application/pdf"},
+		{"/logo.png", 200, "image/png"},
+		{"/data.json", 200, "application/json"},
+		{"/missing.pdf", 404, "text/html"},
+	}
+	for _, f := range fetches {
+		ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:       walker.MustParse("http://test.com" + f.path),
+			FetchTime: time.Now(),
+			Response:  &http.Response{StatusCode: f.status},
+			MimeType:  f.mime,
+		})
+	}
+
+	counts, err := ds.MimeTypeCounts("test.com")
+	if err != nil {
+		t.Fatalf("MimeTypeCounts failed: %v", err)
+	}
+	expected := &MimeTypeCounts{Domain: "test.com", HTML: 2, PDF: 1, Image: 1, Other: 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("MimeTypeCounts got %+v, expected %+v", counts, expected)
+	}
+	if counts.Total() != 5 {
+		t.Errorf("Expected 5 counted fetches, got %d", counts.Total())
+	}
 
-	// Other variables
-	domain := "foo.com"
-	insertDomainInfo := `INSERT INTO domain_info (dom, excluded, exclude_reason, priority)
-						     VALUES (?, ?, ?, ?)`
+	counts, err = ds.MimeTypeCounts("nosuchdomain.com")
+	if err != nil || counts.Total() != 0 {
+		t.Errorf("Expected no counts for unknown domain, got %+v, %v", counts, err)
+	}
+}
+
+func TestDomainConfig(t *testing.T) {
+	db := GetTestDB()
 	ds := getDS(t)
+	defer ds.Close()
 
-	// the check function will verify that the DomainInfo found in the database for foo.com, has the expected values
-	// (see the Check variables above)
-	check := func(tag string) {
-		dinfo, err := ds.FindDomain(domain)
-		if dinfo == nil || err != nil {
-			t.Fatalf("Failed to FindDomain for tag %s: %v", tag, err)
-		}
+	err := db.Query(`INSERT INTO domain_info (dom, priority) VALUES (?, ?)`, "test.com", 1).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
 
-		var buffer string
+	cfg, err := ds.GetDomainConfig("test.com")
+	if err != nil {
+		t.Fatalf("GetDomainConfig failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, &DomainConfig{}) {
+		t.Errorf("Expected no overrides, got %+v", cfg)
+	}
 
-		if dinfo.Excluded != excluded {
-			buffer += fmt.Sprintf("\tExcluded mismatch: got %v, expected %v\n", dinfo.Excluded, excluded)
-		}
+	expected := &DomainConfig{
+		SampleThreshold: 100,
+		SamplePercent:   12.5,
+		ByteBudget:      -1,
+		CrawlWindow:     "Mon-Fri 01:00-05:00",
+		CrawlTimezone:   "America/New_York",
+		UserAgent:       "SpecialBot/1.0",
+		CrawlDelay:      2500 * time.Millisecond,
+	}
+	if ds.HostSettings(context.Background(), "test.com") != nil {
+		t.Errorf("Expected no host settings before SetDomainConfig")
+	}
+	if err := ds.SetDomainConfig("test.com", expected); err != nil {
+		t.Fatalf("SetDomainConfig failed: %v", err)
+	}
+	cfg, err = ds.GetDomainConfig("test.com")
+	if err != nil {
+		t.Fatalf("GetDomainConfig failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+	settings := ds.HostSettings(context.Background(), "test.com")
+	expectedSettings := &walker.HostSettings{UserAgent: "SpecialBot/1.0", CrawlDelay: 2500 * time.Millisecond}
+	if !reflect.DeepEqual(settings, expectedSettings) {
+		t.Errorf("Expected host settings %+v, got %+v", expectedSettings, settings)
+	}
+	dinfo, err := ds.FindDomain("test.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if dinfo.UserAgent != expected.UserAgent || dinfo.CrawlDelay != expected.CrawlDelay {
+		t.Errorf("Expected FindDomain to return the fetching overrides, got %+v", dinfo)
+	}
 
-		if dinfo.ExcludeReason != excludeReason {
-			buffer += fmt.Sprintf("\tExcludeReason mismatch: got %q, expected %q\n", dinfo.ExcludeReason, excludeReason)
+	bad := []*DomainConfig{
+		{SampleThreshold: -1},
+		{SamplePercent: 100.5},
+		{CrawlDelay: -time.Second},
+		{UserAgent: "Bot\r\nX-Injected: 1"},
+		{CrawlWindow: "sometimes"},
+		{CrawlWindow: "01:00-05:00", CrawlTimezone: "Mars/Olympus_Mons"},
+	}
+	for _, b := range bad {
+		if err := ds.SetDomainConfig("test.com", b); err == nil {
+			t.Errorf("Expected SetDomainConfig to reject %+v", b)
 		}
+	}
 
-		if dinfo.Priority != priority {
-			buffer += fmt.Sprintf("\tPriority mismatch: got %d, expected %d\n", dinfo.Priority, priority)
-		}
+	cfg, err = ds.GetDomainConfig("nosuchdomain.com")
+	if err != nil || cfg != nil {
+		t.Errorf("Expected nil config for unknown domain, got %v, %v", cfg, err)
+	}
+}
 
-		if buffer != "" {
-			t.Errorf("Domain info had unexpected problems for tag %s:\n%s", tag, buffer)
-		}
+func TestStoreTruncated(t *testing.T) {
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	u := walker.MustParse("http://test.com/big.html")
+	ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+		URL:       u,
+		FetchTime: time.Now(),
+		Response:  &http.Response{StatusCode: http.StatusPartialContent},
+		Truncated: true,
+	})
+
+	linfos, err := ds.ListLinkHistorical(u)
+	if err != nil {
+		t.Fatalf("ListLinkHistorical failed: %v", err)
 	}
+	if len(linfos) != 1 || !linfos[0].Truncated {
+		t.Errorf("Expected one truncated crawl, got %+v", linfos)
+	}
+}
 
+func TestStoreDomainAssets(t *testing.T) {
 	db := GetTestDB()
-	err := db.Query(insertDomainInfo, domain, excluded, excludeReason, priority).Exec()
+	ds := getDS(t)
+	defer ds.Close()
+
+	if err := db.Query(`INSERT INTO domain_info (dom, priority) VALUES (?, ?)`, "test.com", 1).Exec(); err != nil {
+		t.Fatalf("Failed to insert test domain info: %v", err)
+	}
+	fetched := time.Now().Truncate(time.Millisecond)
+	ds.StoreDomainAssets(context.Background(), "test.com", &walker.DomainAssets{
+		FaviconURL:         walker.MustParse("http://test.com/favicon.ico"),
+		FaviconTime:        fetched,
+		FaviconStatus:      200,
+		FaviconMimeType:    "image/x-icon",
+		FaviconFingerprint: 42,
+	})
+
+	dinfo, err := ds.FindDomain("test.com")
 	if err != nil {
-		t.Fatalf("Unexpected error during domain insert: %v", err)
+		t.Fatalf("FindDomain failed: %v", err)
 	}
-	check("First Insert")
+	if dinfo.FaviconURL != "http://test.com/favicon.ico" || !dinfo.FaviconTime.Equal(fetched) ||
+		dinfo.FaviconStatus != 200 || dinfo.FaviconMimeType != "image/x-icon" || dinfo.FaviconFingerprint != 42 {
+		t.Errorf("Unexpected favicon in %+v", dinfo)
+	}
+}
 
-	excluded = true
-	excludeReason = "Excluded reason"
-	ds.UpdateDomain(domain, &DomainInfo{Excluded: excluded, ExcludeReason: excludeReason},
-		DomainInfoUpdateConfig{Exclude: true})
-	check("Exclude updated")
+// alertRecorder is a walker.Notifier remembering the alerts it was sent
+type alertRecorder struct {
+	alerts []*walker.Alert
+}
 
-	excluded = false
-	excludeReason = ""
-	ds.UpdateDomain(domain, &DomainInfo{Excluded: excluded, ExcludeReason: excludeReason},
-		DomainInfoUpdateConfig{Exclude: true})
-	check("clear 1")
+func (r *alertRecorder) Notify(a *walker.Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
 
-	priority = 4
-	ds.UpdateDomain(domain, &DomainInfo{Priority: priority}, DomainInfoUpdateConfig{Priority: true})
-	check("Priority")
+func TestStoreRobotsFingerprint(t *testing.T) {
+	orig := walker.Config.Alerts.RobotsChanged
+	defer func() {
+		walker.Config.Alerts.RobotsChanged = orig
+	}()
+	walker.Config.Alerts.RobotsChanged = true
 
-	priority = 1
-	ds.UpdateDomain(domain, &DomainInfo{Priority: priority}, DomainInfoUpdateConfig{Priority: true})
-	check("clear 2")
+	db := GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+	rec := &alertRecorder{}
+	ds.Alerter.Notifiers = []walker.Notifier{rec}
 
-	excluded = true
-	excludeReason = "Excluded reason"
-	priority = 4
-	ds.UpdateDomain(domain, &DomainInfo{
-		Excluded:      excluded,
-		ExcludeReason: excludeReason,
-		Priority:      priority,
-	}, DomainInfoUpdateConfig{Exclude: true, Priority: true})
-	check("Priority & Exclude")
+	if err := db.Query(`INSERT INTO domain_info (dom, priority) VALUES (?, ?)`, "test.com", 1).Exec(); err != nil {
+		t.Fatalf("Failed to insert test domain info: %v", err)
+	}
+	ctx := context.Background()
 
+	tests := []struct {
+		fp      int64
+		changed bool
+		alerts  int
+	}{
+		{fp: 42, changed: false, alerts: 0},
+		{fp: 42, changed: false, alerts: 0},
+		{fp: 0, changed: true, alerts: 1},
+	}
+	for i, tst := range tests {
+		ds.StoreRobotsFingerprint(ctx, "test.com", tst.fp)
+		ds.Alerter.Wait()
+		dinfo, err := ds.FindDomain("test.com")
+		if err != nil {
+			t.Fatalf("FindDomain failed: %v", err)
+		}
+		if dinfo.RobotsTime.IsZero() || dinfo.RobotsFingerprint != tst.fp || dinfo.RobotsChanged != tst.changed {
+			t.Errorf("Store %d: expected robots fingerprint %v (changed %v), got %+v", i, tst.fp, tst.changed, dinfo)
+		}
+		if len(rec.alerts) != tst.alerts {
+			t.Errorf("Store %d: expected %d alerts, got %v", i, tst.alerts, rec.alerts)
+		}
+	}
+	if len(rec.alerts) > 0 && (rec.alerts[0].Condition != AlertRobotsChanged || rec.alerts[0].Subject != "test.com") {
+		t.Errorf("Unexpected alert %v", rec.alerts[0])
+	}
 }
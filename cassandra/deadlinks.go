@@ -0,0 +1,70 @@
+package cassandra
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// deadLinksPageSize is the number of links ForEachDeadLink lists at a time
+var deadLinksPageSize = 1000
+
+// IsDeadStatus returns true if a link whose latest fetch got status is dead:
+// 404 Not Found, 410 Gone or any 5XX
+func IsDeadStatus(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusGone || status >= 500
+}
+
+// ForEachDeadLink calls fn with each link of domain whose latest fetch got a
+// dead status (see IsDeadStatus), in path order, until fn returns an error.
+// The links include their first and last referrers if cassandra.store_referrers
+// was on when they were found.
+func ForEachDeadLink(ds ModelDatastore, domain string, fn func(*LinkInfo) error) error {
+	query := LQ{Dead: true, Limit: deadLinksPageSize}
+	for {
+		page, err := ds.ListLinks(domain, query)
+		if err != nil {
+			return err
+		}
+		for _, linfo := range page.Links {
+			if err := fn(linfo); err != nil {
+				return err
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		query.PageToken = page.NextPageToken
+	}
+}
+
+// DeadLinksCSVHeader is the header row WriteDeadLinksCSV writes
+var DeadLinksCSVHeader = []string{"url", "status", "crawl_time", "first_referrer", "last_referrer"}
+
+// WriteDeadLinksCSV writes the dead links of domain (see ForEachDeadLink) to
+// w as CSV, with a DeadLinksCSVHeader row first. It returns the number of
+// links written.
+func WriteDeadLinksCSV(w io.Writer, ds ModelDatastore, domain string) (int, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(DeadLinksCSVHeader); err != nil {
+		return 0, err
+	}
+	count := 0
+	err := ForEachDeadLink(ds, domain, func(linfo *LinkInfo) error {
+		count++
+		return cw.Write([]string{
+			linfo.URL.String(),
+			strconv.Itoa(linfo.Status),
+			linfo.CrawlTime.UTC().Format(time.RFC3339),
+			linfo.FirstReferrer,
+			linfo.LastReferrer,
+		})
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return count, err
+}
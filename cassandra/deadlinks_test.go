@@ -0,0 +1,99 @@
+//go:build cassandra
+// +build cassandra
+
+package cassandra
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestDeadLinks(t *testing.T) {
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1)`, "test.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	ds := getDS(t)
+	defer ds.Close()
+
+	if errs := ds.InsertLinks([]string{"http://test.com/new.html"}, ""); len(errs) > 0 {
+		t.Fatalf("Failed to insert link: %v", errs)
+	}
+	fetches := []struct {
+		path   string
+		status int
+	}{
+		// Only the latest crawl of each link counts
+		{"/fixed.html", 404},
+		{"/fixed.html", 200},
+		{"/broke.html", 200},
+		{"/broke.html", 500},
+		{"/gone.html", 410},
+		{"/missing.html", 404},
+		{"/moved.html", 301},
+	}
+	fetchTime := time.Now().Add(-time.Hour)
+	for _, f := range fetches {
+		ds.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:       walker.MustParse("http://test.com" + f.path),
+			FetchTime: fetchTime,
+			Response:  &http.Response{StatusCode: f.status},
+		})
+		fetchTime = fetchTime.Add(time.Minute)
+	}
+	err = db.Query(`UPDATE links SET ref_first = ?, ref_last = ? WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?
+					AND time = ?`, "http://test.com/a.html", "http://test.com/b.html", "test.com", "", "/missing.html",
+		"http", walker.NotYetCrawled).Exec()
+	if err != nil {
+		t.Fatalf("Failed to set referrers: %v", err)
+	}
+
+	// Force paging through the links
+	origPageSize := deadLinksPageSize
+	defer func() { deadLinksPageSize = origPageSize }()
+	deadLinksPageSize = 1
+
+	var buf bytes.Buffer
+	count, err := WriteDeadLinksCSV(&buf, ds, "test.com")
+	if err != nil {
+		t.Fatalf("WriteDeadLinksCSV failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 dead links, got %d", count)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read the CSV written: %v", err)
+	}
+	var got [][]string
+	for _, row := range rows {
+		got = append(got, []string{row[0], row[1], row[3], row[4]})
+	}
+	expected := [][]string{
+		{"url", "status", "first_referrer", "last_referrer"},
+		{"http://test.com/broke.html", "500", "", ""},
+		{"http://test.com/gone.html", "410", "", ""},
+		{"http://test.com/missing.html", "404", "http://test.com/a.html", "http://test.com/b.html"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected dead links\n%q\ngot\n%q", expected, got)
+	}
+}
+
+func TestIsDeadStatus(t *testing.T) {
+	for status, dead := range map[int]bool{200: false, 301: false, 403: false, 404: true, 410: true, 429: false,
+		500: true, 503: true} {
+		if IsDeadStatus(status) != dead {
+			t.Errorf("Expected IsDeadStatus(%d) to be %v", status, dead)
+		}
+	}
+}
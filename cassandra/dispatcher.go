@@ -1,18 +1,24 @@
 package cassandra
 
 import (
-	"container/heap"
+	"context"
 	"fmt"
 	"math"
-	"sort"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"code.google.com/p/log4go"
 	"github.com/gocql/gocql"
 	"github.com/iParadigms/walker"
 	"github.com/iParadigms/walker/semaphore"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Dispatcher analyzes what we've crawled so far (generally on a per-domain
@@ -30,8 +36,11 @@ type Dispatcher struct {
 	cf *gocql.ClusterConfig
 	db *gocql.Session
 
-	domains chan string   // For passing domains to generate to worker goroutines
-	quit    chan struct{} // Channel to close to stop the dispatcher (used by `Stop()`)
+	queue *domainQueue  // For passing domains to generate to worker goroutines
+	quit  chan struct{} // Channel to close to stop the dispatcher (used by `Stop()`)
+
+	// progress of the current pass over domain_info
+	scan scanState
 
 	// synchronizes when all generator routines have exited, so
 	// `StopDispatcher()` can wait until all processing is done
@@ -52,18 +61,65 @@ type Dispatcher struct {
 	removedToks      map[gocql.UUID]bool
 	removedToksMutex sync.Mutex
 
-	// map of active UUIDs -- i.e. fetchers that are still alive
-	activeToks map[gocql.UUID]time.Time
+	// map of active UUIDs -- i.e. fetchers that are still alive (and mutex
+	// to protect it, since the shards of the scan check claims concurrently)
+	activeToks      map[gocql.UUID]time.Time
+	activeToksMutex sync.Mutex
 
 	// If true, this field signals that this dispatcher run should quit as soon as all
 	// available work is done.
 	oneShotIterations int
+
+	// Rate limits and retries segment writes; shared by all generateRoutines
+	throttle *writeThrottle
+
+	// Segments is where generated segments are written. It is set from
+	// cassandra.segment_store; replace it before starting the dispatcher to
+	// write them somewhere else.
+	Segments SegmentStore
+
+	// Alerter raises the alerts configured in the alerts section of
+	// walker.yaml. Add to its Notifiers before starting the dispatcher to
+	// send alerts somewhere else too.
+	Alerter *walker.Alerter
+
+	// Prober checks domains before their first dispatch. It is set if
+	// dispatcher.probe_new_domains is true; replace it before starting the
+	// dispatcher to probe domains differently.
+	Prober DomainProber
+
+	// Strategy chooses the links of each segment. It is a
+	// DefaultSegmentStrategy; replace it before starting the dispatcher to
+	// choose them differently.
+	Strategy SegmentStrategy
+
+	// number of links dispatched during the current domain iteration (updated
+	// atomically by the generateRoutines)
+	dispatchedLinks int64
+
+	// number of domain iterations in a row that generated segments but
+	// dispatched no links
+	emptyIterations int
+
+	// failed cassandra writes counted by the previous domain iteration (see
+	// WriteStats)
+	lastWriteFailures int64
 }
 
+// NewDispatcher creates a Dispatcher for the configured keyspace
 func NewDispatcher() (*Dispatcher, error) {
+	return NewDispatcherForKeyspace(walker.Config.Cassandra.Keyspace)
+}
+
+// NewDispatcherForKeyspace creates a Dispatcher for the crawl stored in the
+// given keyspace (see NewDatastoreForKeyspace)
+func NewDispatcherForKeyspace(keyspace string) (*Dispatcher, error) {
+	if !walker.ValidKeyspace(keyspace) {
+		return nil, fmt.Errorf("Invalid keyspace name %q", keyspace)
+	}
 	d := &Dispatcher{}
 
-	d.cf = GetConfig()
+	d.cf = GetConfigForKeyspace(keyspace)
 	var err error
 	d.db, err = d.cf.CreateSession()
 	if err != nil {
@@ -71,7 +127,7 @@ func NewDispatcher() (*Dispatcher, error) {
 	}
 
 	d.quit = make(chan struct{})
-	d.domains = make(chan string)
+	d.queue = newDomainQueue(domainQueueSize)
 	d.removedToks = make(map[gocql.UUID]bool)
 	d.activeToks = make(map[gocql.UUID]time.Time)
 	d.finishWG = semaphore.New()
@@ -82,17 +138,31 @@ func NewDispatcher() (*Dispatcher, error) {
 		panic(err) //Not going to happen, parsed in config
 	}
 
-	d.dispatchInterval, err = time.ParseDuration(walker.Config.Dispatcher.DispatchInterval)
+	d.dispatchInterval, err = time.ParseDuration(walker.HotConfig().Dispatcher.DispatchInterval)
 	if err != nil {
 		panic(err) // Should not happen since it is parsed at config load
 	}
 	d.activeFetcherCachetime = time.Duration(float32(ttl) * walker.Config.Fetcher.ActiveFetchersCacheratio)
+	d.throttle = newWriteThrottle()
+	d.Segments, err = newSegmentStore(keyspace, d.db, d.throttle, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.Alerter = walker.NewAlerter()
+	if walker.Config.Dispatcher.ProbeNewDomains {
+		d.Prober = NewHTTPProber()
+	}
+	d.Strategy = &DefaultSegmentStrategy{}
+	d.lastWriteFailures = CurrentWriteStats().Failures
 
 	return d, nil
 }
 
 func (d *Dispatcher) StartDispatcher() error {
 	log4go.Info("Starting CassandraDispatcher")
+	scanDispatcher.Lock()
+	scanDispatcher.d = d
+	scanDispatcher.Unlock()
 
 	for i := 0; i < walker.Config.Dispatcher.NumConcurrentDomains; i++ {
 		d.finishWG.Add(1)
@@ -104,7 +174,13 @@ func (d *Dispatcher) StartDispatcher() error {
 
 	d.finishWG.Add(1)
 	go func() {
-		d.pollMaxPriority()
+		d.repairMaxPriority()
+		d.finishWG.Done()
+	}()
+
+	d.finishWG.Add(1)
+	go func() {
+		d.collectHistory()
 		d.finishWG.Done()
 	}()
 
@@ -130,81 +206,118 @@ func (d *Dispatcher) StopDispatcher() error {
 	log4go.Info("Stopping CassandraDispatcher")
 	close(d.quit)
 	d.finishWG.Wait()
+	if stats := CurrentWriteStats(); stats.Throttled > 0 || stats.TimeoutRetries > 0 || stats.Failures > 0 {
+		log4go.Info("Cassandra write stats: %v", stats)
+	}
+	if stats := CurrentStrandedClaimStats(); stats.Domains > 0 {
+		log4go.Info("Stranded claims: %v", stats)
+	}
 	d.db.Close()
 	return nil
 }
 
-func (d *Dispatcher) pollMaxPriority() {
-	// Set the loop interval
-	loopPeriod := 60 * time.Second
-
-	dispatch_interval, err := time.ParseDuration(walker.Config.Dispatcher.DispatchInterval)
-	if err != nil {
-		panic(err)
-	}
-	if loopPeriod < dispatch_interval {
-		loopPeriod = dispatch_interval
-	}
-
-	// Loop forever
-	timer := time.NewTimer(loopPeriod)
-	max_priority := "max_priority"
+// repairMaxPriority keeps max_priority in walker_globals correct. It is
+// raised as domain priorities are set (see Datastore.raiseMaxPriority), so
+// this only has to catch what that misses, like priorities being lowered or
+// domain_info being written directly. It scans every domain's priority at
+// start and then every dispatcher.max_priority_repair_interval.
+func (d *Dispatcher) repairMaxPriority() {
 	for {
-		var err error
-		start := time.Now()
-		iter := d.db.Query(`SELECT priority FROM domain_info`).Iter()
-		max := -1
-		prio := 0
-		scansPerQuit := 10
-		count := 0
-		for iter.Scan(&prio) {
-			if prio > max {
-				max = prio
-			}
-			count++
-			if (count % scansPerQuit) == 0 {
-				select {
-				case <-d.quit:
-					goto LOOP
-				default:
-				}
-			}
-		}
-		err = iter.Close()
-		if err != nil {
-			log4go.Error("pollMaxPriority failed to fetch all priorities: %v", err)
-			goto LOOP
-		}
-		if max < 0 {
-			goto LOOP
+		if err := d.repairMaxPriorityOnce(); err != nil {
+			log4go.Error("repairMaxPriority failed: %v", err)
 		}
 
-		err = d.db.Query("INSERT INTO walker_globals (key, val) VALUES (?, ?)", max_priority, max).Exec()
+		interval, err := time.ParseDuration(walker.HotConfig().Dispatcher.MaxPriorityRepairInterval)
 		if err != nil {
-			log4go.Error("pollMaxPriority failed to insert into walker_globals: %v", err)
-			goto LOOP
+			panic(err) // This won't happen b/c this duration is checked in Config
 		}
-
-	LOOP:
-		timer.Reset(loopPeriod - time.Since(start))
+		timer := time.NewTimer(interval)
 		select {
 		case <-d.quit:
+			timer.Stop()
 			return
 		case <-timer.C:
 		}
 	}
 }
 
+// repairMaxPriorityOnce sets max_priority to the highest priority in
+// domain_info
+func (d *Dispatcher) repairMaxPriorityOnce() error {
+	var old int
+	err := d.db.Query(`SELECT val FROM walker_globals WHERE key = ?`, maxPriorityKey).Scan(&old)
+	exists := true
+	if err == gocql.ErrNotFound {
+		exists = false
+	} else if err != nil {
+		return fmt.Errorf("failed to read max_priority: %v", err)
+	}
+
+	iter := d.db.Query(`SELECT priority FROM domain_info`).Iter()
+	max := -1
+	prio := 0
+	scansPerQuit := 10
+	count := 0
+	for iter.Scan(&prio) {
+		if prio > max {
+			max = prio
+		}
+		count++
+		if (count % scansPerQuit) == 0 {
+			select {
+			case <-d.quit:
+				iter.Close()
+				return nil
+			default:
+			}
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf("failed to fetch all priorities: %v", err)
+	}
+	if max < 0 || (exists && max == old) {
+		return nil
+	}
+
+	// Only replace the value read above; if a priority was raised during the
+	// scan, the scan may have missed it
+	var applied bool
+	if exists {
+		applied, err = d.db.Query(`UPDATE walker_globals SET val = ? WHERE key = ? IF val = ?`,
+			max, maxPriorityKey, old).MapScanCAS(map[string]interface{}{})
+	} else {
+		applied, err = d.db.Query(`INSERT INTO walker_globals (key, val) VALUES (?, ?) IF NOT EXISTS`,
+			maxPriorityKey, max).MapScanCAS(map[string]interface{}{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set max_priority: %v", err)
+	}
+	if !applied {
+		return setMaxPriorityAtLeast(d.db, max)
+	}
+	if exists {
+		log4go.Info("Repaired max_priority from %d to %d", old, max)
+	}
+	return nil
+}
+
 func (d *Dispatcher) cleanStrandedClaims(tok gocql.UUID) {
 	tag := "cleanStrandedClaims"
 	var err error
 
 	db := d.db
-	iter := db.Query(`SELECT dom FROM domain_info WHERE claim_tok = ?`, tok).Iter()
+	iter := db.Query(`SELECT dom, claim_time FROM domain_info WHERE claim_tok = ?`, tok).Iter()
 	var domain string
+	var claimTime time.Time
 	ecount := 0
-	for iter.Scan(&domain) && ecount < 5 {
-		err = db.Query(`DELETE FROM segments WHERE dom = ?`, domain).Exec()
+	released := 0
+	for iter.Scan(&domain, &claimTime) && ecount < 5 {
+		links, err := d.Segments.SegmentLinks(context.Background(), domain)
+		if err != nil {
+			log4go.Error("%s failed to read segment of %v: %v", tag, domain, err)
+		}
+
+		err = d.Segments.DeleteSegment(context.Background(), domain)
 		if err != nil {
 			log4go.Error("%s failed to DELETE from segments: %v", tag, err)
 			ecount++
@@ -218,12 +331,18 @@ func (d *Dispatcher) cleanStrandedClaims(tok gocql.UUID) {
 		if err != nil {
 			log4go.Error("%s failed to UPDATE domain_info: %v", tag, err)
 			ecount++
+			continue
 		}
+		d.auditStrandedClaim(domain, tok, claimTime, len(links))
+		released++
 	}
 	err = iter.Close()
 	if err != nil {
 		log4go.Error("%s failed to find domain: %v", tag, err)
 	}
+	if released > 0 {
+		atomic.AddInt64(&strandedClaimStats.fetchers, 1)
+	}
 
 	d.removedToksMutex.Lock()
 	delete(d.removedToks, tok)
@@ -255,6 +374,8 @@ func (d *Dispatcher) fetcherIsAlive(claimTok gocql.UUID) bool {
 	if claimTok == zeroTok {
 		return true
 	}
+	d.activeToksMutex.Lock()
+	defer d.activeToksMutex.Unlock()
 
 	// If the token is already queued up to be removed, you must
 	// return true here so that cleanStrandedClaims is not called
@@ -271,6 +392,7 @@ func (d *Dispatcher) fetcherIsAlive(claimTok gocql.UUID) bool {
 		d.updateActiveFetchersCache(claimTok)
 		_, present := d.activeToks[claimTok]
 		if !present {
+			d.fetcherLost(claimTok)
 			d.removedToksMutex.Lock()
 			d.removedToks[claimTok] = true
 			d.removedToksMutex.Unlock()
@@ -286,32 +408,10 @@ func (d *Dispatcher) domainIterator() {
 	for {
 		iteration++
 		log4go.Debug("Starting new domain iteration")
-		domainiter := d.db.Query(`SELECT dom, dispatched, claim_tok, excluded FROM domain_info`).Iter()
-
-		var domain string
-		var dispatched bool
-		var claimTok gocql.UUID
-		var excluded bool
-		for domainiter.Scan(&domain, &dispatched, &claimTok, &excluded) {
-			if d.quitSignaled() {
-				close(d.domains)
-				return
-			}
-
-			if !dispatched && !excluded {
-				d.generatingWG.Add(1)
-				d.domains <- domain
-			} else if !d.fetcherIsAlive(claimTok) {
-				if d.oneShotIterations == 0 {
-					go d.cleanStrandedClaims(claimTok)
-				} else {
-					d.cleanStrandedClaims(claimTok)
-				}
-			}
-		}
-
-		if err := domainiter.Close(); err != nil {
-			log4go.Error("Error iterating domains from domain_info: %v", err)
+		queued, finished := d.scanDomains(iteration)
+		if !finished {
+			d.queue.close()
+			return
 		}
 
 		// We wait here until all the generateRoutine's finish. The reason is that
@@ -319,20 +419,25 @@ func (d *Dispatcher) domainIterator() {
 		// sure they've done all they're work (particularly setting the dispatched field)
 		// before we start a new iteration.
 		d.generatingWG.Wait()
+		d.checkIterationAlerts(queued)
 
 		// Check for quit signal right away, otherwise if there are no domains
 		// to claim and the dispatchInterval is 0, then the dispatcher will
 		// never quit
 		osi := d.oneShotIterations
 		if (osi > 0 && iteration >= osi) || d.quitSignaled() {
-			close(d.domains)
+			d.queue.close()
 			return
 		}
 
+		// Re-read each iteration so ReloadConfig can change it
+		if interval, err := time.ParseDuration(walker.HotConfig().Dispatcher.DispatchInterval); err == nil {
+			d.dispatchInterval = interval
+		}
 		endSleep := time.Now().Add(d.dispatchInterval)
 		for time.Now().Before(endSleep) {
 			if d.quitSignaled() {
-				close(d.domains)
+				d.queue.close()
 				return
 			}
 			time.Sleep(time.Millisecond * 10)
@@ -360,6 +465,17 @@ type cell struct {
 	crawlTime           time.Time
 	getnow              bool
 	fnvText             int64
+	nextRetryAt         time.Time
+	refetchAfter        time.Time
+	status              int
+	fetchErr            string
+	redirectTo          string
+	tombstoned          bool
+	redirectLoop        bool
+
+	// The number of consecutive crawls, ending with this one, that
+	// permanently redirected to redirectTo
+	permanentRedirects int
 }
 
 // equivalent checks if the full link string of 2 cells are the same
@@ -391,10 +507,18 @@ func createInsertAllColumns(table string, itr *gocql.Iter) (string, []string) {
 }
 
 func (d *Dispatcher) generateRoutine() {
-	generator := &SegmentGenerator{DB: d.db}
-	for domain := range d.domains {
+	generator := &SegmentGenerator{DB: d.db, Segments: d.Segments, Strategy: d.Strategy, throttle: d.throttle,
+		prober: d.Prober}
+	for {
+		domain, ok := d.queue.pop()
+		if !ok {
+			break
+		}
 		if err := generator.Generate(domain); err != nil {
 			log4go.Error("error generating segment for %v: %v", domain, err)
+		} else {
+			atomic.AddInt64(&d.dispatchedLinks, int64(len(generator.linksToDispatch)))
+			d.checkDomainErrorRate(generator)
 		}
 		d.generatingWG.Done()
 	}
@@ -408,10 +532,23 @@ type SegmentGenerator struct {
 	// constructing a SegmentGenerator
 	DB *gocql.Session
 
+	// Where segments are written; if nil, the segments table in DB
+	Segments SegmentStore
+
+	// Chooses the links of each segment; if nil, a DefaultSegmentStrategy
+	Strategy SegmentStrategy
+
 	// do not dispatch any link that has been crawled within this amount of
 	// time; set by dispatcher.min_link_refresh_time config parameter
 	minRecrawlDelta time.Duration
 
+	// minRecrawlDelta by the last status of a link (dispatcher.status_refresh_times)
+	statusRecrawlDeltas map[string]time.Duration
+
+	// do not dispatch a link whose last crawl ended in a redirect loop within
+	// this amount of time (dispatcher.redirect_loop_cooldown)
+	redirectLoopCooldown time.Duration
+
 	// How long do we wait before retrying a domain that didn't have any links.
 	emptyDispatchRetryInterval time.Duration
 
@@ -430,8 +567,96 @@ type SegmentGenerator struct {
 	// Count of the links not yet crawled in this domain
 	uncrawledLinksCount int
 
+	// Count of the links last fetched within alerts.domain_error_rate_window,
+	// and how many of those fetches failed (see Dispatcher.checkDomainErrorRate)
+	errorRateWindow time.Duration
+	recentFetches   int
+	recentErrors    int
+
 	// after analysis, the links we actually want to put in the segment
 	linksToDispatch []*LinkInfo
+	// links left out of the segment because, after filtering, they were
+	// identical to a link already in it
+	duplicateLinks []*LinkInfo
+	// links replaced by the target they permanently redirect to (see
+	// rewritePermanentRedirect), mapping old URL to new
+	permanentRedirects map[string]string
+	// crawled links not yet due for a refresh; only collected by Preview
+	tooFreshLinks []*LinkInfo
+
+	// The link counts of the domain at its last dispatch, its trap patterns
+	// (see dispatcher.trap_detection), those of them accepted and, if they
+	// are applied, their regex
+	prevTotalLinks     int
+	prevUncrawledLinks int
+	trapPatterns       []string
+	trapAccepted       []string
+	trapExclude        *regexp.Regexp
+	// uncrawled links left out because they match trapExclude
+	trapExcludedLinks int
+	// trap patterns detectTrap found in this run
+	suggestedTrapPatterns []string
+
+	// Rate limits and batches segment inserts; nil means no limit
+	throttle *writeThrottle
+
+	// Probes domains before their first dispatch; nil means no probe
+	prober DomainProber
+
+	// if true, nothing is written to cassandra (see Preview)
+	dryRun bool
+}
+
+// SegmentPreview describes the segment SegmentGenerator.Generate would
+// produce for a domain, without it being written. See
+// SegmentGenerator.Preview.
+type SegmentPreview struct {
+	Domain string
+
+	// Why no segment would be generated at all; empty if one would be
+	Skipped string
+
+	// Number of links in the domain, and how many of those were never crawled
+	TotalLinks     int
+	UncrawledLinks int
+
+	// Number of not yet crawled and already crawled links eligible for this
+	// segment, before it is limited to dispatcher.num_links_per_segment
+	EligibleUncrawled int
+	EligibleRefresh   int
+
+	// The links that would be dispatched, split by the list they come from
+	GetNow    []*LinkInfo
+	Uncrawled []*LinkInfo
+	Refresh   []*LinkInfo
+
+	// Links whose query parameters were removed by the duplicate content
+	// filter, mapping the original URL to the one that would be dispatched
+	Rewritten map[string]string
+
+	// Links left out because they were identical to another link after
+	// filtering
+	Duplicates []*LinkInfo
+
+	// Links that would be tombstoned for permanently redirecting, mapping
+	// each to the target that would replace it (see
+	// dispatcher.rewrite_permanent_redirects)
+	PermanentRedirects map[string]string
+
+	// Eligible links left for a later segment because this one is full (see
+	// dispatcher.num_links_per_segment)
+	Deferred []*LinkInfo
+
+	// Crawled links left out because they are not due for a refresh yet
+	// (see dispatcher.min_link_refresh_time), up to
+	// dispatcher.num_links_per_segment of them
+	TooFresh []*LinkInfo
+
+	// Exclude patterns trap detection found in the uncrawled links (see
+	// dispatcher.trap_detection), and how many uncrawled links were left out
+	// by the trap patterns applied to the domain
+	TrapPatterns      []string
+	TrapExcludedLinks int
 }
 
 // LinkList is a list of LinkInfos that implements sort.Interface, so we can
@@ -442,8 +667,9 @@ func (l LinkList) Len() int           { return len(l) }
 func (l LinkList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
 func (l LinkList) Less(i, j int) bool { return l[i].URL.Path < l[j].URL.Path }
 
-// Uniq assumes this list of links is sorted and gets rid of identical links
-func (l LinkList) Uniq() {
+// Uniq assumes this list of links is sorted and returns it without identical
+// links
+func (l LinkList) Uniq() LinkList {
 	deduped := make(LinkList, 0, len(l))
 	if len(l) > 0 {
 		deduped = append(deduped, l[0])
@@ -464,17 +690,33 @@ func (l LinkList) Uniq() {
 			log4go.Fine("%v", link.URL)
 		}
 	}
-	l = deduped
+	return deduped
 }
 
 // reset zeroes instance data for another Generate run
 func (sg *SegmentGenerator) reset() {
+	cfg := walker.HotConfig()
 	var err error
-	sg.minRecrawlDelta, err = time.ParseDuration(walker.Config.Dispatcher.MinLinkRefreshTime)
+	sg.minRecrawlDelta, err = time.ParseDuration(cfg.Dispatcher.MinLinkRefreshTime)
+	if err != nil {
+		panic(err)
+	}
+	sg.statusRecrawlDeltas = map[string]time.Duration{}
+	for status, refresh := range cfg.Dispatcher.StatusRefreshTimes {
+		sg.statusRecrawlDeltas[status], err = time.ParseDuration(refresh)
+		if err != nil {
+			panic(err)
+		}
+	}
+	sg.redirectLoopCooldown, err = time.ParseDuration(walker.Config.Dispatcher.RedirectLoopCooldown)
+	if err != nil {
+		panic(err)
+	}
+	sg.emptyDispatchRetryInterval, err = time.ParseDuration(cfg.Dispatcher.EmptyDispatchRetryInterval)
 	if err != nil {
 		panic(err)
 	}
-	sg.emptyDispatchRetryInterval, err = time.ParseDuration(walker.Config.Dispatcher.EmptyDispatchRetryInterval)
+	sg.errorRateWindow, err = time.ParseDuration(walker.Config.Alerts.DomainErrorRateWindow)
 	if err != nil {
 		panic(err)
 	}
@@ -484,12 +726,31 @@ func (sg *SegmentGenerator) reset() {
 	sg.crawledLinks = []*LinkInfo{}
 	sg.totalLinksCount = 0
 	sg.uncrawledLinksCount = 0
+	sg.recentFetches = 0
+	sg.recentErrors = 0
 	sg.linksToDispatch = []*LinkInfo{}
+	sg.duplicateLinks = []*LinkInfo{}
+	sg.permanentRedirects = map[string]string{}
+	sg.tooFreshLinks = []*LinkInfo{}
+	sg.prevTotalLinks = 0
+	sg.prevUncrawledLinks = 0
+	sg.trapPatterns = nil
+	sg.trapAccepted = nil
+	sg.trapExclude = nil
+	sg.trapExcludedLinks = 0
+	sg.suggestedTrapPatterns = nil
 }
 
 // Generate reads links in for this domain, generates a segment for it, and
 // inserts the domain into domains_to_crawl (assuming a segment is ready to go)
-func (sg *SegmentGenerator) Generate(domain string) error {
+func (sg *SegmentGenerator) Generate(domain string) (err error) {
+	_, span := walker.Tracer().Start(context.Background(), "cassandra.Generate",
+		trace.WithAttributes(walker.AttrDomain.String(domain)))
+	defer func() {
+		span.SetAttributes(attribute.Int("walker.segment_links", len(sg.linksToDispatch)))
+		walker.EndSpan(span, err)
+	}()
+
 	sg.reset()
 	sg.domain = domain
 
@@ -497,13 +758,28 @@ func (sg *SegmentGenerator) Generate(domain string) error {
 		log4go.Debug("Domain %v recently dispatched with no links, not generating segment again", domain)
 		return nil
 	}
+	if sg.overByteBudget() {
+		log4go.Debug("Domain %v is over its bandwidth budget, not generating segment", domain)
+		return nil
+	}
+	if sg.outsideCrawlWindow() {
+		log4go.Debug("Domain %v is outside its crawl window, not generating segment", domain)
+		return nil
+	}
+	if sg.nonstandardPort() {
+		log4go.Debug("Domain %v has a non-standard port, not generating segment", domain)
+		return nil
+	}
+	if sg.failsProbe() {
+		return nil
+	}
 	log4go.Info("Generating a crawl segment for %v", domain)
 
 	if err := sg.collectLinks(); err != nil {
 		return err
 	}
-	sg.filterLinksByDuplicateContent()
-	sg.buildLinksToDispatch()
+	sg.detectTrap()
+	sg.selectLinks()
 	if err := sg.insertSegment(); err != nil {
 		return err
 	}
@@ -512,6 +788,108 @@ func (sg *SegmentGenerator) Generate(domain string) error {
 	return nil
 }
 
+// Preview runs the same analysis as Generate for this domain and returns what
+// the segment would contain, without writing anything to cassandra. This is
+// meant for debugging dispatch decisions.
+func (sg *SegmentGenerator) Preview(domain string) (*SegmentPreview, error) {
+	sg.reset()
+	sg.domain = domain
+	sg.dryRun = true
+	defer func() { sg.dryRun = false }()
+
+	p := &SegmentPreview{Domain: domain, Rewritten: map[string]string{}}
+	if sg.dispatchedEmptyRecently() {
+		p.Skipped = "dispatched with no links within dispatcher.empty_dispatch_retry_interval"
+		return p, nil
+	}
+	if sg.overByteBudget() {
+		p.Skipped = "over its bandwidth budget"
+		return p, nil
+	}
+	if sg.outsideCrawlWindow() {
+		p.Skipped = "outside its crawl window"
+		return p, nil
+	}
+	if sg.nonstandardPort() {
+		p.Skipped = "has a non-standard port (see fetcher.nonstandard_ports)"
+		return p, nil
+	}
+
+	if err := sg.collectLinks(); err != nil {
+		return nil, err
+	}
+	sg.detectTrap()
+	p.TrapPatterns = sg.suggestedTrapPatterns
+	p.TrapExcludedLinks = sg.trapExcludedLinks
+	p.TotalLinks = sg.totalLinksCount
+	p.UncrawledLinks = sg.uncrawledLinksCount
+	p.EligibleUncrawled = len(sg.uncrawledLinks)
+	p.EligibleRefresh = len(sg.crawledLinks)
+
+	// Remember where each link came from and what it looked like, since
+	// filtering rewrites the links in place
+	getNow := map[*LinkInfo]bool{}
+	refresh := map[*LinkInfo]bool{}
+	original := map[*LinkInfo]string{}
+	for _, l := range sg.getNowLinks {
+		getNow[l] = true
+	}
+	for _, l := range sg.crawledLinks {
+		refresh[l] = true
+		original[l] = l.URL.String()
+	}
+	for _, l := range sg.uncrawledLinks {
+		original[l] = l.URL.String()
+	}
+	eligible := append(append([]*LinkInfo{}, sg.uncrawledLinks...), sg.crawledLinks...)
+
+	sg.selectLinks()
+
+	for l, u := range original {
+		if l.URL.String() != u {
+			p.Rewritten[u] = l.URL.String()
+		}
+	}
+	for _, l := range sg.linksToDispatch {
+		switch {
+		case getNow[l]:
+			p.GetNow = append(p.GetNow, l)
+		case refresh[l]:
+			p.Refresh = append(p.Refresh, l)
+		default:
+			p.Uncrawled = append(p.Uncrawled, l)
+		}
+	}
+	p.Duplicates = sg.duplicateLinks
+	p.PermanentRedirects = sg.permanentRedirects
+
+	left := map[*LinkInfo]bool{}
+	for _, l := range sg.linksToDispatch {
+		left[l] = true
+	}
+	for _, l := range sg.duplicateLinks {
+		left[l] = true
+	}
+	for _, l := range eligible {
+		if !left[l] {
+			p.Deferred = append(p.Deferred, l)
+		}
+	}
+	p.TooFresh = sg.tooFreshLinks
+	return p, nil
+}
+
+// nonstandardPort returns true if the current domain includes a port (ex.
+// test.com:8443, since links to the default port are stored without it) and
+// fetcher.nonstandard_ports says such links are not to be crawled
+func (sg *SegmentGenerator) nonstandardPort() bool {
+	if strings.ToLower(walker.Config.Fetcher.NonstandardPorts) == "crawl" {
+		return false
+	}
+	_, _, err := net.SplitHostPort(sg.domain)
+	return err == nil
+}
+
 // dispatchedEmptyRecently returns true if this given domain was dispatched
 // empty (meaning no links were chosen to be crawled so no segment was
 // generated) within the past dispatch_retry_interval (see walker.yaml). This
@@ -530,10 +908,67 @@ func (sg *SegmentGenerator) dispatchedEmptyRecently() bool {
 	return false
 }
 
+// overByteBudget returns true if the current domain has fetched at least its
+// bandwidth budget (see dispatcher.domain_byte_budget) within the budget
+// window
+func (sg *SegmentGenerator) overByteBudget() bool {
+	var override int64
+	err := sg.DB.Query(`SELECT byte_budget FROM domain_info WHERE dom = ?`, sg.domain).Scan(&override)
+	if err != nil {
+		log4go.Error("Failed to read byte_budget for %q: %v", sg.domain, err)
+		return false
+	}
+	budget := domainByteBudget(override)
+	if budget <= 0 {
+		return false
+	}
+
+	used, err := domainBytesSince(sg.DB, sg.domain, time.Now().Add(-domainByteBudgetWindow()))
+	if err != nil {
+		log4go.Error("Failed to read bytes fetched for %q: %v", sg.domain, err)
+		return false
+	}
+	return used >= budget
+}
+
+// outsideCrawlWindow returns true if the current domain has a crawl window
+// (the crawl_window column of domain_info) and it is not currently open. Only
+// the start of a crawl is held to the window (here, and again when a fetcher
+// claims the domain); a segment claimed near its end may be fetched past it
+// (fetcher.max_time_per_host bounds how far).
+func (sg *SegmentGenerator) outsideCrawlWindow() bool {
+	var spec, tz string
+	err := sg.DB.Query(`SELECT crawl_window, crawl_timezone FROM domain_info WHERE dom = ?`,
+		sg.domain).Scan(&spec, &tz)
+	if err != nil {
+		log4go.Error("Failed to read crawl_window for %q: %v", sg.domain, err)
+		return false
+	}
+	return crawlWindowClosed(sg.domain, spec, tz, time.Now())
+}
+
+// selectLinks runs the collected links through the segment strategy to fill
+// linksToDispatch and duplicateLinks
+func (sg *SegmentGenerator) selectLinks() {
+	strategy := sg.Strategy
+	if strategy == nil {
+		strategy = &DefaultSegmentStrategy{}
+	}
+	c := &SegmentCandidates{
+		Domain:    sg.domain,
+		GetNow:    sg.getNowLinks,
+		Uncrawled: sg.uncrawledLinks,
+		Refresh:   sg.crawledLinks,
+	}
+	strategy.Filter(c)
+	sg.linksToDispatch, sg.duplicateLinks = strategy.Select(c, walker.Config.Dispatcher.MaxLinksPerSegment)
+}
+
 // collectLinks scans the links table for the current domain and populates our
 // link lists
 func (sg *SegmentGenerator) collectLinks() error {
 	start := time.Now()
+	sg.readTrapState()
 
 	// Making this query consistency = One ensures that when we do this
 	// potentially massive read, the cassandra nodes don't have to waste big
@@ -541,7 +976,8 @@ func (sg *SegmentGenerator) collectLinks() error {
 	// The only risk is: if a node is down and does not receive some link
 	// writes, then comes back up and is read for this query it may be missing
 	// some of the newly crawled links. This is unlikely and seems acceptable.
-	q := sg.DB.Query(`SELECT subdom, path, proto, time, getnow, fnv_txt
+	q := sg.DB.Query(`SELECT subdom, path, proto, time, getnow, fnv_txt, next_retry_at,
+						refetch_after, stat, err, redto_url, tombstoned, redir_loop
 						FROM links WHERE dom = ?`, sg.domain)
 	q.Consistency(gocql.One)
 
@@ -550,7 +986,17 @@ func (sg *SegmentGenerator) collectLinks() error {
 	var current cell
 	var previous cell
 	iter := q.Iter()
-	for iter.Scan(&current.subdom, &current.path, &current.proto, &current.crawlTime, &current.getnow, &current.fnvText) {
+	for iter.Scan(&current.subdom, &current.path, &current.proto, &current.crawlTime, &current.getnow, &current.fnvText, &current.nextRetryAt,
+		&current.refetchAfter, &current.status, &current.fetchErr, &current.redirectTo, &current.tombstoned,
+		&current.redirectLoop) {
+		current.permanentRedirects = 0
+		if isPermanentRedirect(current.status) && current.redirectTo != "" {
+			current.permanentRedirects = 1
+			if scanStarted && current.equivalent(&previous) && current.redirectTo == previous.redirectTo {
+				current.permanentRedirects = previous.permanentRedirects + 1
+			}
+		}
+
 		if !scanStarted {
 			previous = current
 			scanStarted = true
@@ -587,9 +1033,17 @@ func (sg *SegmentGenerator) collectLinks() error {
 // logs failure if CreateURL fails. It also keeps track of total and uncrawled
 // links by incrementing sg.linksCount and sg.uncrawledLinksCount
 func (sg *SegmentGenerator) cellPush(c *cell) {
+	if c.tombstoned {
+		return
+	}
 	sg.totalLinksCount++
 	if c.crawlTime.Equal(walker.NotYetCrawled) {
 		sg.uncrawledLinksCount++
+	} else if time.Since(c.crawlTime) < sg.errorRateWindow {
+		sg.recentFetches++
+		if c.fetchErr != "" || c.status >= 500 {
+			sg.recentErrors++
+		}
 	}
 
 	u, err := walker.CreateURL(sg.domain, c.subdom, c.path, c.proto, c.crawlTime)
@@ -598,8 +1052,21 @@ func (sg *SegmentGenerator) cellPush(c *cell) {
 		return
 	}
 
+	if walker.Config.Dispatcher.RewritePermanentRedirects && !c.getnow &&
+		c.permanentRedirects >= walker.Config.Dispatcher.PermanentRedirectCrawls {
+		if sg.rewritePermanentRedirect(u, c) {
+			return
+		}
+	}
+
 	if walker.Config.Dispatcher.CorrectLinkNormalization {
-		u = sg.correctURLNormalization(u)
+		var merged bool
+		u, merged = sg.correctURLNormalization(u)
+		if merged {
+			// The corrected link already has rows of its own, which are
+			// dispatched when its own cell is pushed
+			return
+		}
 	}
 
 	l := &LinkInfo{
@@ -610,41 +1077,108 @@ func (sg *SegmentGenerator) cellPush(c *cell) {
 	if c.getnow {
 		sg.getNowLinks = append(sg.getNowLinks, l)
 	} else if c.crawlTime.Equal(walker.NotYetCrawled) {
+		if sg.trapExcluded(u) {
+			return
+		}
 		if len(sg.uncrawledLinks) < walker.Config.Dispatcher.MaxLinksPerSegment {
 			sg.uncrawledLinks = append(sg.uncrawledLinks, l)
 		}
+	} else if !c.nextRetryAt.IsZero() {
+		// The last fetch failed transiently; retry once its backoff is over,
+		// regardless of MinLinkRefreshTime
+		if c.nextRetryAt.Before(time.Now()) {
+			sg.crawledLinks = append(sg.crawledLinks, l)
+		} else {
+			sg.previewTooFresh(l)
+		}
 	} else {
-		// Was this link crawled less than MinLinkRefreshTime?
-		if c.crawlTime.Add(sg.minRecrawlDelta).Before(time.Now()) {
+		// Was this link crawled less than its refresh time ago?
+		if c.crawlTime.Add(sg.recrawlDelta(c)).Before(time.Now()) && !sg.cachedUntilLater(c) {
 			sg.crawledLinks = append(sg.crawledLinks, l)
+		} else {
+			sg.previewTooFresh(l)
 		}
 	}
 
 	return
 }
 
+// previewTooFresh records a crawled link left out because it is not due for
+// a refresh yet, when previewing
+func (sg *SegmentGenerator) previewTooFresh(l *LinkInfo) {
+	if sg.dryRun && len(sg.tooFreshLinks) < walker.Config.Dispatcher.MaxLinksPerSegment {
+		sg.tooFreshLinks = append(sg.tooFreshLinks, l)
+	}
+}
+
+// recrawlDelta returns how long after its last crawl the link in c can be
+// dispatched again: the dispatcher.status_refresh_times entry for its status
+// code, or else for its class of status codes, or else
+// dispatcher.min_link_refresh_time. Links whose last crawl ended in a redirect
+// loop wait at least dispatcher.redirect_loop_cooldown.
+func (sg *SegmentGenerator) recrawlDelta(c *cell) time.Duration {
+	delta := sg.statusRecrawlDelta(c)
+	if c.redirectLoop && sg.redirectLoopCooldown > delta {
+		return sg.redirectLoopCooldown
+	}
+	return delta
+}
+
+// statusRecrawlDelta returns the recrawlDelta of c by its status alone
+func (sg *SegmentGenerator) statusRecrawlDelta(c *cell) time.Duration {
+	if len(sg.statusRecrawlDeltas) == 0 {
+		return sg.minRecrawlDelta
+	}
+	keys := []string{"error"}
+	if c.status > 0 {
+		keys = []string{strconv.Itoa(c.status), fmt.Sprintf("%dxx", c.status/100)}
+	} else if c.fetchErr == "" {
+		return sg.minRecrawlDelta
+	}
+	for _, key := range keys {
+		if delta, ok := sg.statusRecrawlDeltas[key]; ok {
+			return delta
+		}
+	}
+	return sg.minRecrawlDelta
+}
+
+// cachedUntilLater returns true if the last response for this cell said it
+// can be cached past now, and cache headers are being honored
+func (sg *SegmentGenerator) cachedUntilLater(c *cell) bool {
+	return walker.Config.Dispatcher.HonorCacheHeaders && c.refetchAfter.After(time.Now())
+}
+
 // correctURLNormalization will verify that u is normalized. This method always
 // returns the normalized link. If this method finds that it's argument url is
 // NOT normalized then the Datastore will be updated to reflect the normalized
-// link.
-func (sg *SegmentGenerator) correctURLNormalization(u *walker.URL) *walker.URL {
+// link: the rows of u are moved to the normalized link, and a segments row for
+// u is replaced with one for the normalized link.
+//
+// If the normalized link already has rows the histories are merged, keeping
+// the union of their crawl times (the normalized link's own row wins when
+// both have one for the same time), and merged is returned true.
+func (sg *SegmentGenerator) correctURLNormalization(u *walker.URL) (corrected *walker.URL, merged bool) {
 	c := u.NormalizedForm()
 	if c == nil {
-		return u
+		return u, false
 	}
 
 	log4go.Debug("correctURLNormalization correcting %v --> %v", u, c)
+	if sg.dryRun {
+		return c, false
+	}
 
 	// Grab primary keys of old and new urls
-	dom, subdom, path, proto, _, err := u.PrimaryKey()
+	dom, subdom, path, proto, err := u.PrimaryKey()
 	if err != nil {
 		log4go.Error("correctURLNormalization error; can't get primary key for URL %v: %v", u.URL, err)
-		return u
+		return u, false
 	}
-	newdom, newsubdom, newpath, newproto, _, err := c.PrimaryKey()
+	newdom, newsubdom, newpath, newproto, err := c.PrimaryKey()
 	if err != nil {
 		log4go.Error("correctURLNormalization error; can't get NEW primary key for URL %v: %v", u.URL, err)
-		return u
+		return u, false
 	}
 
 	// Create a new domain_info if needed. XXX: note that currently old domain_infos are left alone, since we
@@ -656,7 +1190,7 @@ func (sg *SegmentGenerator) correctURLNormalization(u *walker.URL) *walker.URL {
 		itr := sg.DB.Query(`SELECT * FROM domain_info WHERE dom = ?`, dom).Iter()
 		if !itr.MapScan(mp) {
 			log4go.Error("correctURLNormalization error; Failed to select from domain_info for URL %v", u.URL)
-			return u
+			return u, false
 		}
 		err := itr.Close()
 		if err != nil {
@@ -674,13 +1208,30 @@ func (sg *SegmentGenerator) correctURLNormalization(u *walker.URL) *walker.URL {
 		err = sg.DB.Query(insert, vals...).Exec()
 		if err != nil {
 			log4go.Error("correctURLNormalization error; Failed to insert into domain_info for URL %v: %v", u.URL, err)
-			return u
+			return u, false
 		}
 	}
 
+	// Find the rows the normalized link already has, which are kept as they are
+	existing := map[int64]bool{}
+	var crawlTime time.Time
+	itr := sg.DB.Query(`SELECT time FROM links WHERE dom = ? AND subdom = ? AND proto = ? AND path = ?`,
+		newdom, newsubdom, newproto, newpath).Iter()
+	for itr.Scan(&crawlTime) {
+		existing[crawlTime.UnixNano()] = true
+	}
+	if err := itr.Close(); err != nil {
+		log4go.Error("correctURLNormalization error; Failed to select rows of NEW URL %v: %v", c.URL, err)
+		return u, false
+	}
+	merged = len(existing) > 0
+	if merged {
+		log4go.Debug("correctURLNormalization merging %v into existing link %v", u, c)
+	}
+
 	// Create read iterator
 	read := `SELECT * FROM links WHERE dom = ? AND subdom = ? AND proto = ? AND path = ?`
-	itr := sg.DB.Query(read, dom, subdom, proto, path).Iter()
+	itr = sg.DB.Query(read, dom, subdom, proto, path).Iter()
 
 	// Use the read iterator to fashion a generic insert statement to move all fields from one primary key
 	// to another.
@@ -690,6 +1241,10 @@ func (sg *SegmentGenerator) correctURLNormalization(u *walker.URL) *walker.URL {
 	// the column names in this algorithm in order to make this code resilient against  adding NON-PRIMARY-KEY columns.
 	mp := map[string]interface{}{}
 	for itr.MapScan(mp) {
+		if t, ok := mp["time"].(time.Time); ok && existing[t.UnixNano()] {
+			mp = map[string]interface{}{}
+			continue
+		}
 		mp["dom"] = newdom
 		mp["subdom"] = newsubdom
 		mp["path"] = newpath
@@ -703,7 +1258,7 @@ func (sg *SegmentGenerator) correctURLNormalization(u *walker.URL) *walker.URL {
 		err := sg.DB.Query(insert, vals...).Exec()
 		if err != nil {
 			log4go.Error("correctURLNormalization error; Failed to insert for URL %v: %v", u.URL, err)
-			return u
+			return u, false
 		}
 
 		// MapScan will choke if you don't clear this map before re-using it.
@@ -712,228 +1267,100 @@ func (sg *SegmentGenerator) correctURLNormalization(u *walker.URL) *walker.URL {
 	err = itr.Close()
 	if err != nil {
 		log4go.Error("correctURLNormalization error; Failed to insert for URL %v: %v", u.URL, err)
-		return u
+		return u, false
 	}
 
-	// Now clobber the old rows
-	del := `DELETE FROM links WHERE dom = ? AND subdom = ? AND proto = ? AND path = ?`
-	err = sg.DB.Query(del, dom, subdom, proto, path).Exec()
+	// Now clobber the old rows, moving any segments row of the old link along
+	// in the same (logged) batch so a claimed segment never loses the link
+	batch := sg.DB.NewBatch(gocql.LoggedBatch)
+	batch.Query(`DELETE FROM links WHERE dom = ? AND subdom = ? AND proto = ? AND path = ?`,
+		dom, subdom, proto, path)
+	if sg.usesSegmentsTable() {
+		var segTime time.Time
+		var segPos int
+		err = sg.DB.Query(`SELECT time, pos FROM segments WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?`,
+			dom, subdom, path, proto).Scan(&segTime, &segPos)
+		if err == nil {
+			batch.Query(`DELETE FROM segments WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?`,
+				dom, subdom, path, proto)
+			batch.Query(`INSERT INTO segments (dom, subdom, path, proto, time, pos) VALUES (?, ?, ?, ?, ?, ?)`,
+				newdom, newsubdom, newpath, newproto, segTime, segPos)
+		} else if err != gocql.ErrNotFound {
+			log4go.Error("correctURLNormalization error; Failed to select from segments for URL %v: %v", u.URL, err)
+			return u, false
+		}
+	}
+	err = sg.DB.ExecuteBatch(batch)
 	if err != nil {
 		log4go.Error("correctURLNormalization error; Failed to delete for URL %v: %v", u.URL, err)
-		return u
+		return u, false
 	}
 
-	return c
-}
-
-// filterLinksByDuplicateContent uses the raw data pulled in by collectLinks
-// and filters links, ex. to cut out repeated query parameters that don't
-// affect content
-func (sg *SegmentGenerator) filterLinksByDuplicateContent() {
-	start := time.Now()
-	dupClusters := sg.buildDuplicateLinkClusters()
-	removableParams := sg.discoverRemoveableQueryParameters(dupClusters)
-	sg.filterLinksWithRules(removableParams)
-	log4go.Debug("Filtered links for %v in %v", sg.domain, time.Since(start))
+	return c, merged
 }
 
-// Build clusters of links with duplicate content. One "Cluster" is a group
-// of links with the same fingerprint, which is further grouped by
-// subdomain + path (without query parameters). An example entry might be:
-//		{
-//			29034823084: map[string]LinkList{
-//				"www/index.html": LinkList{
-//					<LinkInfo representing www.test.com/index.html>,
-//					<LinkInfo representing www.test.com/index.html?foo=bar>,
-//				},
-//				"www/": LinkList{
-//					<LinkInfo representing www.test.com/>,
-//					<LinkInfo representing www.test.com/?foo=bar>,
-//				},
-//			},
-//		}
-// In this example all four pages have the same textual content.
-func (sg *SegmentGenerator) buildDuplicateLinkClusters() map[int64]map[string]LinkList {
-	dupClusters := map[int64]map[string]LinkList{}
-	for _, linkList := range []LinkList{sg.uncrawledLinks, sg.crawledLinks} {
-		for _, l := range linkList {
-			entry := dupClusters[l.FnvTextFingerprint]
-			if entry == nil {
-				entry = map[string]LinkList{}
-				dupClusters[l.FnvTextFingerprint] = entry
-			}
-			subdom, err := l.URL.Subdomain()
-			if err != nil {
-				log4go.Error("Dispatcher creating query rules could not get subdomain: %v", err)
-				continue
-			}
-			key := subdom + l.URL.Path
-			entry[key] = append(entry[key], l)
-		}
+// usesSegmentsTable returns true if segments are written to the segments
+// table (rather than another SegmentStore)
+func (sg *SegmentGenerator) usesSegmentsTable() bool {
+	if sg.Segments == nil {
+		return true
 	}
-	log4go.Fine("Duplicate cluster map created: %v", dupClusters)
-	return dupClusters
+	_, ok := sg.Segments.(*cassandraSegments)
+	return ok
 }
 
-// Discover which query parameters within a given cluster and path (path
-// meaning subdomain+path) differ, so we know those query parameters don't
-// affect content and can be deleted. Build a map identifying, for each
-// path, which we can delete (ex. the parameter 'foo' in examples above)
-func (sg *SegmentGenerator) discoverRemoveableQueryParameters(dupClusters map[int64]map[string]LinkList) map[string]map[string]bool {
-	removableParamsByPath := map[string]map[string]bool{}
-	for _, linksByPath := range dupClusters {
-		for path, links := range linksByPath {
-			removableParams := map[string]bool{}
-			if len(links) <= 1 {
-				continue
-			}
-
-			// Use the first link as baseline for comparison. Any differences
-			// or absent parameters in remaining links will mark that parameter
-			// as removable.
-			compareValues := links[0].URL.Query()
-			for _, l := range links {
-				currentValues := l.URL.Query()
-
-				// First check that all parameters for this link are the same as the comparison
-				for param, vals := range currentValues {
-					if removableParams[param] {
-						continue
-					}
-
-					compareVals, ok := compareValues[param]
-					if !ok || !stringListsEqual(vals, compareVals) {
-						removableParams[param] = true
-						continue
-					}
-				}
-
-				// Then see if this link is missing any parameters that are in the comparison
-				for param := range compareValues {
-					_, ok := currentValues[param]
-					if !ok {
-						removableParams[param] = true
-						continue
-					}
-				}
-			}
-			if len(removableParams) > 0 {
-				removableParamsByPath[path] = removableParams
-				log4go.Debug("Created parameter removal for subdomain/path %v -- %v", path, removableParams)
-			}
-		}
-	}
-	return removableParamsByPath
+// isPermanentRedirect returns true if status is a permanent redirect
+func isPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
 }
 
-// Filter all links, removing parameters, then sort these lists and remove
-// links that are no longer unique. Ex. www.test.com/?foo=bar will turn
-// into www.test.com/, duplicating the other link in the cluster, so one
-// will be removed.
-func (sg *SegmentGenerator) filterLinksWithRules(removableParamsByPath map[string]map[string]bool) {
-	for _, linkList := range []LinkList{sg.uncrawledLinks, sg.crawledLinks} {
-		for _, l := range linkList {
-			subdom, err := l.URL.Subdomain()
-			if err != nil {
-				log4go.Error("Dispatcher filtering links could not get subdomain: %v", err)
-				continue
-			}
-			key := subdom + l.URL.Path
-			removableParams := removableParamsByPath[key]
-			vals := l.URL.Query()
-
-			// Remove any parameters marked as removable for this path; use a
-			// boolean as a small optimization to prevent re-encoding query
-			// parameters for URLs where no replacements were made, which is
-			// most URLs
-			paramReplaced := false
-			for param := range removableParams {
-				vals.Del(param)
-				paramReplaced = true
-			}
-			if paramReplaced {
-				beforeFilter := l.URL.String()
-				l.URL.RawQuery = vals.Encode()
-				log4go.Debug("Dispatcher filtering parameters, turning %s => %s", beforeFilter, l.URL)
-			}
-		}
-		sort.Sort(linkList)
-		linkList.Uniq()
+// rewritePermanentRedirect replaces u, which has permanently redirected to
+// c.redirectTo for its last few crawls, with the redirect target: the target
+// is stored as a new link and the latest row of u is tombstoned so it is not
+// dispatched again. It returns false if u was left alone.
+func (sg *SegmentGenerator) rewritePermanentRedirect(u *walker.URL, c *cell) bool {
+	target, err := walker.ParseAndNormalizeURL(c.redirectTo)
+	if err != nil {
+		log4go.Debug("rewritePermanentRedirect not rewriting %v, bad target %q: %v", u, c.redirectTo, err)
+		return false
 	}
-}
-
-// buildLinksToDispatch takes the final link lists, post-filtration, and
-// produces a dispatch set (limiting to the requested dispatch size and so on)
-func (sg *SegmentGenerator) buildLinksToDispatch() {
-	start := time.Now()
-
-	sg.linksToDispatch = append(sg.linksToDispatch, sg.getNowLinks...)
-
-	// Create a priority structure out of already-crawled links so we recrawl
-	// the oldest first.
-	crawledPrioritized := &PriorityURL{}
-	heap.Init(crawledPrioritized)
-	for _, l := range sg.crawledLinks {
-		heap.Push(crawledPrioritized, l)
-	}
-
-	// Since we filter query parameters and rewrite links, the crawled and
-	// uncrawled lists could end up with identical links. We use this map to
-	// deduplicate our final segment (keyed by full URL)
-	alreadyAdded := map[string]bool{}
-
-	limit := walker.Config.Dispatcher.MaxLinksPerSegment
-	numRemain := limit - len(sg.linksToDispatch)
-	if numRemain > 0 {
-		refreshDecimal := walker.Config.Dispatcher.RefreshPercentage / 100.0
-		idealCrawled := round(refreshDecimal * float64(numRemain))
-		idealUncrawled := numRemain - idealCrawled
-
-		for i := 0; i < idealUncrawled && len(sg.uncrawledLinks) > 0 && len(sg.linksToDispatch) < limit; i++ {
-			l := sg.uncrawledLinks[0]
-			sg.uncrawledLinks = sg.uncrawledLinks[1:]
-			if alreadyAdded[l.URL.String()] {
-				i--
-				continue
-			} else {
-				sg.linksToDispatch = append(sg.linksToDispatch, l)
-				alreadyAdded[l.URL.String()] = true
-			}
-		}
-
-		for i := 0; i < idealCrawled && crawledPrioritized.Len() > 0 && len(sg.linksToDispatch) < limit; i++ {
-			l := heap.Pop(crawledPrioritized).(*LinkInfo)
-			if alreadyAdded[l.URL.String()] {
-				i--
-				continue
-			} else {
-				sg.linksToDispatch = append(sg.linksToDispatch, l)
-				alreadyAdded[l.URL.String()] = true
-			}
+	tdom, tsubdom, tpath, tproto, err := target.PrimaryKey()
+	if err != nil {
+		log4go.Debug("rewritePermanentRedirect not rewriting %v, bad target %v: %v", u, target, err)
+		return false
+	}
+	if tdom != sg.domain {
+		var dom string
+		err := sg.DB.Query(`SELECT dom FROM domain_info WHERE dom = ?`, tdom).Scan(&dom)
+		if err == gocql.ErrNotFound {
+			log4go.Debug("rewritePermanentRedirect not rewriting %v, target domain %v is not crawled", u, tdom)
+			return false
+		} else if err != nil {
+			log4go.Error("rewritePermanentRedirect failed to read domain_info for %v: %v", tdom, err)
+			return false
 		}
+	}
 
-		for len(sg.uncrawledLinks) > 0 && len(sg.linksToDispatch) < limit {
-			l := sg.uncrawledLinks[0]
-			sg.uncrawledLinks = sg.uncrawledLinks[1:]
-			if alreadyAdded[l.URL.String()] {
-				continue
-			} else {
-				sg.linksToDispatch = append(sg.linksToDispatch, l)
-				alreadyAdded[l.URL.String()] = true
-			}
-		}
+	log4go.Debug("rewritePermanentRedirect rewriting %v --> %v", u, target)
+	sg.permanentRedirects[u.String()] = target.String()
+	if sg.dryRun {
+		return true
+	}
 
-		for crawledPrioritized.Len() > 0 && len(sg.linksToDispatch) < limit {
-			l := heap.Pop(crawledPrioritized).(*LinkInfo)
-			if alreadyAdded[l.URL.String()] {
-				continue
-			} else {
-				sg.linksToDispatch = append(sg.linksToDispatch, l)
-				alreadyAdded[l.URL.String()] = true
-			}
-		}
+	err = sg.DB.Query(`INSERT INTO links (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+		tdom, tsubdom, tpath, tproto, walker.NotYetCrawled).Exec()
+	if err != nil {
+		log4go.Error("rewritePermanentRedirect failed to insert %v: %v", target, err)
+		return false
+	}
+	err = sg.DB.Query(`UPDATE links SET tombstoned = true
+						WHERE dom = ? AND subdom = ? AND path = ? AND proto = ? AND time = ?`,
+		sg.domain, c.subdom, c.path, c.proto, c.crawlTime).Exec()
+	if err != nil {
+		log4go.Error("rewritePermanentRedirect failed to tombstone %v: %v", u, err)
+		return false
 	}
-	log4go.Debug("Build final segment for %v in %v", sg.domain, time.Since(start))
+	return true
 }
 
 // insertSegment inserts the links in sg.linksToDispatch into cassandra and
@@ -941,19 +1368,16 @@ func (sg *SegmentGenerator) buildLinksToDispatch() {
 func (sg *SegmentGenerator) insertSegment() error {
 	start := time.Now()
 
-	for _, l := range sg.linksToDispatch {
-		log4go.Debug("Inserting link in segment: %s", l.URL)
-		dom, subdom, err := l.URL.TLDPlusOneAndSubdomain()
-		if err != nil {
-			return fmt.Errorf("generateSegment not inserting %v: %v", l.URL, err)
-		}
-		err = sg.DB.Query(`INSERT INTO segments
-			(dom, subdom, path, proto, time)
-			VALUES (?, ?, ?, ?, ?)`,
-			dom, subdom, l.URL.RequestURI(), l.URL.Scheme, l.URL.LastCrawled).Exec()
-		if err != nil {
-			log4go.Error("Failed to insert link (%v), error: %v", l.URL, err)
-		}
+	segments := sg.Segments
+	if segments == nil {
+		segments = &cassandraSegments{db: sg.DB, throttle: sg.throttle, read: sg.DB.Query}
+	}
+	links := make([]*walker.URL, len(sg.linksToDispatch))
+	for i, l := range sg.linksToDispatch {
+		links[i] = l.URL
+	}
+	if err := segments.StoreSegment(context.Background(), sg.domain, links); err != nil {
+		log4go.Error("Failed to insert segment links for %v, error: %v", sg.domain, err)
 	}
 
 	//
@@ -989,6 +1413,20 @@ func (sg *SegmentGenerator) insertSegment() error {
 		return fmt.Errorf("error inserting %v to domain_info: %v", sg.domain, err)
 	}
 
+	if dispatched {
+		err = sg.DB.Query(`INSERT INTO dispatch_log (dom, time, links) VALUES (?, ?, ?)`,
+			sg.domain, dispatchStamp, len(sg.linksToDispatch)).Exec()
+		if err != nil {
+			log4go.Error("Failed to log the dispatch of %v: %v", sg.domain, err)
+		}
+	}
+
+	if dispatched && strings.ToLower(walker.Config.Cassandra.ClaimStrategy) == "weighted" {
+		if err := sg.queueForClaim(dispatchStamp); err != nil {
+			return fmt.Errorf("error queueing %v in claim_queue: %v", sg.domain, err)
+		}
+	}
+
 	log4go.Debug("Inserted segment for %v in %v", sg.domain, time.Since(start))
 	return nil
 }
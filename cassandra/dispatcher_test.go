@@ -3,9 +3,12 @@
 package cassandra
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -675,6 +678,48 @@ func TestDispatcherBasic(t *testing.T) {
 	}
 }
 
+func TestDispatcherBatchedWrites(t *testing.T) {
+	origBatchSize := walker.Config.Cassandra.WriteBatchSize
+	origRateLimit := walker.Config.Cassandra.WriteRateLimit
+	defer func() {
+		walker.Config.Cassandra.WriteBatchSize = origBatchSize
+		walker.Config.Cassandra.WriteRateLimit = origRateLimit
+	}()
+	walker.Config.Cassandra.WriteBatchSize = 4
+	walker.Config.Cassandra.WriteRateLimit = 1000
+
+	db := GetTestDB()
+	q := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+					VALUES (?, ?, ?, ?)`, "test.com", gocql.UUID{}, 1, false)
+	if err := q.Exec(); err != nil {
+		t.Fatalf("Failed to insert test domain info: %v\nQuery: %v", err, q)
+	}
+	numLinks := 10
+	for i := 0; i < numLinks; i++ {
+		q = db.Query(`INSERT INTO links (dom, subdom, path, proto, time, getnow)
+						VALUES (?, ?, ?, ?, ?, ?)`,
+			"test.com", "", fmt.Sprintf("/page%d.html", i), "http", walker.NotYetCrawled, false)
+		if err := q.Exec(); err != nil {
+			t.Fatalf("Failed to insert test link: %v\nQuery: %v", err, q)
+		}
+	}
+
+	before := CurrentWriteStats()
+	runDispatcher(t)
+	after := CurrentWriteStats()
+
+	var count int
+	if err := db.Query(`SELECT COUNT(*) FROM segments WHERE dom = ?`, "test.com").Scan(&count); err != nil {
+		t.Fatalf("Failed to count segment links: %v", err)
+	}
+	if count != numLinks {
+		t.Errorf("Expected %d links in segment, got %d", numLinks, count)
+	}
+	if batches := after.Batches - before.Batches; batches != 3 {
+		t.Errorf("Expected segment to be inserted in 3 batches, got %d", batches)
+	}
+}
+
 func TestDispatcherDispatchedFalseIfNoLinks(t *testing.T) {
 	db := GetTestDB()
 	q := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
@@ -695,6 +740,198 @@ func TestDispatcherDispatchedFalseIfNoLinks(t *testing.T) {
 	}
 }
 
+func TestDispatcherSkipsPausedDomains(t *testing.T) {
+	db := GetTestDB()
+	q := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched, paused)
+					VALUES (?, ?, ?, ?, ?)`, "test.com", gocql.UUID{}, 1, false, true)
+	if err := q.Exec(); err != nil {
+		t.Fatalf("Failed to insert test domain info: %v\nQuery: %v", err, q)
+	}
+	q = db.Query(`INSERT INTO links (dom, subdom, path, proto, time, getnow)
+					VALUES (?, ?, ?, ?, ?, ?)`, "test.com", "", "/page1.html", "http", walker.NotYetCrawled, false)
+	if err := q.Exec(); err != nil {
+		t.Fatalf("Failed to insert test link: %v\nQuery: %v", err, q)
+	}
+
+	runDispatcher(t)
+
+	var dispatched bool
+	q = db.Query(`SELECT dispatched FROM domain_info WHERE dom = ?`, "test.com")
+	if err := q.Scan(&dispatched); err != nil {
+		t.Fatalf("Failed to find domain info: %v\nQuery: %v", err, q)
+	}
+	if dispatched {
+		t.Errorf("Paused domain was dispatched")
+	}
+
+	if err := db.Query(`UPDATE domain_info SET paused = false WHERE dom = ?`, "test.com").Exec(); err != nil {
+		t.Fatalf("Failed to resume domain: %v", err)
+	}
+
+	runDispatcher(t)
+
+	q = db.Query(`SELECT dispatched FROM domain_info WHERE dom = ?`, "test.com")
+	if err := q.Scan(&dispatched); err != nil {
+		t.Fatalf("Failed to find domain info: %v\nQuery: %v", err, q)
+	}
+	if !dispatched {
+		t.Errorf("Resumed domain was not dispatched")
+	}
+}
+
+// fakeProber excludes the domains in its map with their reason, and records
+// which domains it probed
+type fakeProber struct {
+	reasons map[string]string
+	probed  []string
+}
+
+func (p *fakeProber) Probe(ctx context.Context, domain string) string {
+	p.probed = append(p.probed, domain)
+	return p.reasons[domain]
+}
+
+func TestDispatcherProbesNewDomains(t *testing.T) {
+	db := GetTestDB()
+	for _, dom := range []string{"live.com", "dead.com", "old.com"} {
+		q := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+						VALUES (?, ?, ?, ?)`, dom, gocql.UUID{}, 1, false)
+		if err := q.Exec(); err != nil {
+			t.Fatalf("Failed to insert test domain info: %v\nQuery: %v", err, q)
+		}
+		q = db.Query(`INSERT INTO links (dom, subdom, path, proto, time)
+						VALUES (?, ?, ?, ?, ?)`, dom, "", "/page1.html", "http", walker.NotYetCrawled)
+		if err := q.Exec(); err != nil {
+			t.Fatalf("Failed to insert test link: %v\nQuery: %v", err, q)
+		}
+	}
+	// Dispatched before, so not probed even though its probe would fail
+	err := db.Query(`UPDATE domain_info SET last_dispatch = ? WHERE dom = ?`,
+		time.Now().Add(-time.Hour), "old.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to set last_dispatch: %v", err)
+	}
+
+	prober := &fakeProber{reasons: map[string]string{
+		"dead.com": "Probe: does not resolve",
+		"old.com":  "Probe: does not resolve",
+	}}
+	runProbingDispatcher := func() {
+		d, err := NewDispatcher()
+		if err != nil {
+			t.Fatalf("Failed to create dispatcher: %v", err)
+		}
+		d.Prober = prober
+		if err := d.oneShot(1); err != nil {
+			t.Fatalf("Failed to run dispatcher: %v", err)
+		}
+	}
+	runProbingDispatcher()
+
+	tests := []struct {
+		dom        string
+		dispatched bool
+		excluded   bool
+		reason     string
+	}{
+		{"live.com", true, false, ""},
+		{"dead.com", false, true, "Probe: does not resolve"},
+		{"old.com", true, false, ""},
+	}
+	for _, tst := range tests {
+		var dispatched, excluded bool
+		var reason string
+		err := db.Query(`SELECT dispatched, excluded, exclude_reason FROM domain_info WHERE dom = ?`,
+			tst.dom).Scan(&dispatched, &excluded, &reason)
+		if err != nil {
+			t.Fatalf("Failed to find domain info: %v", err)
+		}
+		if dispatched != tst.dispatched || excluded != tst.excluded || reason != tst.reason {
+			t.Errorf("%v: expected dispatched %v, excluded %v (%q), got %v, %v (%q)", tst.dom,
+				tst.dispatched, tst.excluded, tst.reason, dispatched, excluded, reason)
+		}
+	}
+	if len(prober.probed) != 2 {
+		t.Errorf("Expected live.com and dead.com to be probed, got %v", prober.probed)
+	}
+
+	// Domains are only probed once
+	prober.probed = nil
+	if err := db.Query(`UPDATE domain_info SET dispatched = false WHERE dom = ?`, "live.com").Exec(); err != nil {
+		t.Fatalf("Failed to reset dispatched: %v", err)
+	}
+	runProbingDispatcher()
+	if len(prober.probed) != 0 {
+		t.Errorf("Expected no domain to be probed again, got %v", prober.probed)
+	}
+}
+
+func TestParkingHost(t *testing.T) {
+	tests := []struct {
+		host   string
+		expect string
+	}{
+		{"ns1.sedoparking.com.", "sedoparking.com"},
+		{"www.HugeDomains.com", "hugedomains.com"},
+		{"dan.com", "dan.com"},
+		{"notdan.com", ""},
+		{"test.com", ""},
+	}
+	for _, tst := range tests {
+		if got := parkingHost(tst.host); got != tst.expect {
+			t.Errorf("parkingHost(%q): expected %q, got %q", tst.host, tst.expect, got)
+		}
+	}
+}
+
+func TestMaxPriority(t *testing.T) {
+	db := GetTestDB()
+	ds := getDS(t)
+	maxPriority := func() int {
+		var val int
+		err := db.Query(`SELECT val FROM walker_globals WHERE key = ?`, "max_priority").Scan(&val)
+		if err != nil {
+			t.Fatalf("Failed to read max_priority: %v", err)
+		}
+		return val
+	}
+
+	for _, dom := range []string{"a.com", "b.com"} {
+		if err := db.Query(`INSERT INTO domain_info (dom, priority) VALUES (?, ?)`, dom, 2).Exec(); err != nil {
+			t.Fatalf("Failed to insert %v: %v", dom, err)
+		}
+	}
+	runDispatcher(t)
+	if p := maxPriority(); p != 2 {
+		t.Errorf("Expected the dispatcher to set max_priority to 2, got %d", p)
+	}
+
+	// Raising a priority raises max_priority right away
+	err := ds.UpdateDomain("a.com", &DomainInfo{Priority: 7}, DomainInfoUpdateConfig{Priority: true})
+	if err != nil {
+		t.Fatalf("Failed to update a.com: %v", err)
+	}
+	if p := maxPriority(); p != 7 {
+		t.Errorf("Expected UpdateDomain to raise max_priority to 7, got %d", p)
+	}
+	if p := ds.MaxPriority(); p != 7 {
+		t.Errorf("Expected the datastore to see max_priority 7, got %d", p)
+	}
+
+	// Lowering it is left to the dispatcher's repair
+	err = ds.UpdateDomain("a.com", &DomainInfo{Priority: 3}, DomainInfoUpdateConfig{Priority: true})
+	if err != nil {
+		t.Fatalf("Failed to update a.com: %v", err)
+	}
+	if p := maxPriority(); p != 7 {
+		t.Errorf("Expected UpdateDomain not to lower max_priority, got %d", p)
+	}
+	runDispatcher(t)
+	if p := maxPriority(); p != 3 {
+		t.Errorf("Expected the dispatcher to repair max_priority to 3, got %d", p)
+	}
+}
+
 func TestMinLinkRefreshTime(t *testing.T) {
 	origMinLinkRefreshTime := walker.Config.Dispatcher.MinLinkRefreshTime
 	defer func() {
@@ -980,6 +1217,18 @@ func TestAutoUnclaim(t *testing.T) {
 				t.Errorf("Expected to find domain %v, but didn't", dom)
 			}
 		}
+
+		// The release of dead.com should be audited
+		ds := getDS(t)
+		claims, err := ds.ListStrandedClaims(time.Now().Add(-time.Hour))
+		ds.Close()
+		if err != nil {
+			t.Fatalf("ListStrandedClaims failed: %v", err)
+		}
+		if len(claims) != 1 || claims[0].Domain != "dead.com" || claims[0].ClaimToken != deadUuid ||
+			claims[0].Links != 2 {
+			t.Errorf("Expected one stranded claim of dead.com with 2 links, got %+v", claims)
+		}
 	}
 }
 
@@ -1008,10 +1257,10 @@ func TestDispatchInterval(t *testing.T) {
 		time.Sleep(time.Millisecond * 200)
 
 		// By now the link should have been dispatched. Pretend we crawled it.
-		host := ds.ClaimNewHost()
-		for _ = range ds.LinksForHost(host) {
+		host := ds.ClaimNewHost(context.Background())
+		for _ = range ds.LinksForHost(context.Background(), host) {
 		}
-		ds.UnclaimHost(host)
+		ds.UnclaimHost(context.Background(), host)
 
 		// Give it time to dispatch again; it should not do it due to 600ms interval
 		time.Sleep(time.Millisecond * 200)
@@ -1027,7 +1276,7 @@ func TestDispatchInterval(t *testing.T) {
 			continue // retry
 		}
 
-		host = ds.ClaimNewHost()
+		host = ds.ClaimNewHost(context.Background())
 		if duration <= tolerance && host != "" {
 			t.Error("Expected host not to be dispatched again due to dispatch interval")
 		}
@@ -1187,6 +1436,98 @@ func TestURLCorrection(t *testing.T) {
 	}
 }
 
+func TestURLCorrectionMerge(t *testing.T) {
+	origPurgeSidList := walker.Config.Fetcher.PurgeSidList
+	origCorrectLinkNormalization := walker.Config.Dispatcher.CorrectLinkNormalization
+	defer func() {
+		walker.Config.Fetcher.PurgeSidList = origPurgeSidList
+		walker.Config.Dispatcher.CorrectLinkNormalization = origCorrectLinkNormalization
+		walker.PostConfigHooks()
+	}()
+	walker.Config.Fetcher.PurgeSidList = []string{"jsessionid"}
+	walker.Config.Dispatcher.CorrectLinkNormalization = true
+	walker.PostConfigHooks()
+
+	db := GetTestDB()
+
+	oldPath := "/page.html;jsessionid=436100313FAFBBB9B4DC8BA3C2EC267B"
+	newPath := "/page.html"
+	oldCrawl := time.Now().AddDate(0, 0, -10).Truncate(time.Millisecond)
+	newCrawl := time.Now().AddDate(0, 0, -5).Truncate(time.Millisecond)
+	inserts := []struct {
+		path string
+		time time.Time
+		stat int
+	}{
+		{oldPath, walker.NotYetCrawled, 0},
+		{oldPath, oldCrawl, 404},
+		{oldPath, newCrawl, 500},
+		{newPath, walker.NotYetCrawled, 0},
+		{newPath, newCrawl, 200},
+	}
+	for _, in := range inserts {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, stat) VALUES (?, ?, ?, ?, ?, ?)`,
+			"merge.com", "", in.path, "http", in.time, in.stat).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert into links: %v", err)
+		}
+	}
+	if err := db.Query(`INSERT INTO domain_info (dom, priority) VALUES (?, ?)`, "merge.com", MaxPriority).Exec(); err != nil {
+		t.Fatalf("Failed to insert into domain_info: %v", err)
+	}
+	// A stale segment still referencing the unnormalized link
+	err := db.Query(`INSERT INTO segments (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+		"merge.com", "", oldPath, "http", oldCrawl).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert into segments: %v", err)
+	}
+
+	runDispatcher(t)
+
+	expected := map[time.Time]int{
+		walker.NotYetCrawled: 0,
+		oldCrawl:             404,
+		newCrawl:             200,
+	}
+	var path string
+	var crawlTime time.Time
+	var stat int
+	got := map[time.Time]int{}
+	itr := db.Query(`SELECT path, time, stat FROM links WHERE dom = ?`, "merge.com").Iter()
+	for itr.Scan(&path, &crawlTime, &stat) {
+		if path != newPath {
+			t.Errorf("Expected only rows of %q to remain, found a row of %q", newPath, path)
+			continue
+		}
+		got[crawlTime.UTC()] = stat
+	}
+	if err := itr.Close(); err != nil {
+		t.Fatalf("Failed to iterate over links: %v", err)
+	}
+	if len(got) != len(expected) {
+		t.Errorf("Expected %d merged rows, got %d: %v", len(expected), len(got), got)
+	}
+	for tm, st := range expected {
+		if gst, ok := got[tm.UTC()]; !ok {
+			t.Errorf("Expected a merged row at %v", tm)
+		} else if gst != st {
+			t.Errorf("Expected status %d for the merged row at %v, got %d", st, tm, gst)
+		}
+	}
+
+	paths := map[string]bool{}
+	itr = db.Query(`SELECT path FROM segments WHERE dom = ?`, "merge.com").Iter()
+	for itr.Scan(&path) {
+		paths[path] = true
+	}
+	if err := itr.Close(); err != nil {
+		t.Fatalf("Failed to iterate over segments: %v", err)
+	}
+	if !reflect.DeepEqual(paths, map[string]bool{newPath: true}) {
+		t.Errorf("Expected the segment to hold only %q, got %v", newPath, paths)
+	}
+}
+
 func TestDomainInfoStats(t *testing.T) {
 	orig := walker.Config.Dispatcher.MinLinkRefreshTime
 	func() {
@@ -1354,3 +1695,736 @@ func TestDispatchPruning(t *testing.T) {
 	}
 
 }
+
+func TestDispatcherTransientRetries(t *testing.T) {
+	origMinLinkRefreshTime := walker.Config.Dispatcher.MinLinkRefreshTime
+	defer func() {
+		walker.Config.Dispatcher.MinLinkRefreshTime = origMinLinkRefreshTime
+	}()
+	walker.Config.Dispatcher.MinLinkRefreshTime = "49h"
+
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, "test.com", MaxPriority).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		path        string
+		nextRetryAt time.Time
+		getnow      bool
+		expected    bool
+	}{
+		// backoff is not over yet
+		{"/waiting.html", now.Add(time.Hour), false, false},
+
+		// backoff is over, dispatched despite MinLinkRefreshTime
+		{"/due.html", now.Add(-time.Minute), false, true},
+
+		// getnow wins over the backoff
+		{"/getnow.html", now.Add(time.Hour), true, true},
+	}
+	for _, tst := range tests {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, stat, retries, next_retry_at, getnow)
+						 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			"test.com", "", tst.path, "http", now.Add(-2*time.Hour), 503, 1, tst.nextRetryAt, tst.getnow).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+
+	runDispatcher(t)
+
+	got := map[string]bool{}
+	iter := db.Query(`SELECT path FROM segments WHERE dom = 'test.com'`).Iter()
+	var path string
+	for iter.Scan(&path) {
+		got[path] = true
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatalf("Failed to read segments: %v", err)
+	}
+	for _, tst := range tests {
+		if got[tst.path] != tst.expected {
+			t.Errorf("Expected %v dispatched == %v, got %v", tst.path, tst.expected, got[tst.path])
+		}
+	}
+}
+
+func TestDispatcherRedirectLoopCooldown(t *testing.T) {
+	origCooldown := walker.Config.Dispatcher.RedirectLoopCooldown
+	defer func() {
+		walker.Config.Dispatcher.RedirectLoopCooldown = origCooldown
+	}()
+	walker.Config.Dispatcher.RedirectLoopCooldown = "24h"
+
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, "test.com", MaxPriority).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		path     string
+		crawled  time.Time
+		loop     bool
+		getnow   bool
+		expected bool
+	}{
+		// looped within the cooldown
+		{"/looping.html", now.Add(-2 * time.Hour), true, false, false},
+
+		// looped before the cooldown
+		{"/looped.html", now.Add(-48 * time.Hour), true, false, true},
+
+		// an ordinary error is refreshed as usual
+		{"/error.html", now.Add(-2 * time.Hour), false, false, true},
+
+		// getnow wins over the cooldown
+		{"/getnow.html", now.Add(-2 * time.Hour), true, true, true},
+	}
+	for _, tst := range tests {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, err, redir_loop, redir_chain, getnow)
+						 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			"test.com", "", tst.path, "http", tst.crawled, "redirect loop", tst.loop,
+			[]string{"http://test.com" + tst.path, "http://test.com/other.html", "http://test.com" + tst.path},
+			tst.getnow).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+
+	runDispatcher(t)
+
+	got := map[string]bool{}
+	iter := db.Query(`SELECT path FROM segments WHERE dom = 'test.com'`).Iter()
+	var path string
+	for iter.Scan(&path) {
+		got[path] = true
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatalf("Failed to read segments: %v", err)
+	}
+	for _, tst := range tests {
+		if got[tst.path] != tst.expected {
+			t.Errorf("Expected %v dispatched == %v, got %v", tst.path, tst.expected, got[tst.path])
+		}
+	}
+}
+
+func TestDispatcherCacheHeaders(t *testing.T) {
+	origHonor := walker.Config.Dispatcher.HonorCacheHeaders
+	defer func() {
+		walker.Config.Dispatcher.HonorCacheHeaders = origHonor
+	}()
+
+	now := time.Now()
+	tests := []struct {
+		path         string
+		refetchAfter time.Time
+		getnow       bool
+		honored      bool
+		unhonored    bool
+	}{
+		// still cacheable, only dispatched when cache headers are ignored
+		{"/cached.html", now.Add(time.Hour), false, false, true},
+
+		// cache expired
+		{"/expired.html", now.Add(-time.Minute), false, true, true},
+
+		// no cache headers at all
+		{"/plain.html", time.Time{}, false, true, true},
+
+		// getnow wins over the cache headers
+		{"/getnow.html", now.Add(time.Hour), true, true, true},
+	}
+
+	for _, honor := range []bool{true, false} {
+		walker.Config.Dispatcher.HonorCacheHeaders = honor
+
+		db := GetTestDB()
+		err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+						 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, "test.com", MaxPriority).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain: %v", err)
+		}
+		for _, tst := range tests {
+			err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, stat, refetch_after, getnow)
+							 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				"test.com", "", tst.path, "http", now.Add(-2*time.Hour), 200, tst.refetchAfter, tst.getnow).Exec()
+			if err != nil {
+				t.Fatalf("Failed to insert link: %v", err)
+			}
+		}
+
+		runDispatcher(t)
+
+		got := map[string]bool{}
+		iter := db.Query(`SELECT path FROM segments WHERE dom = 'test.com'`).Iter()
+		var path string
+		for iter.Scan(&path) {
+			got[path] = true
+		}
+		if err := iter.Close(); err != nil {
+			t.Fatalf("Failed to read segments: %v", err)
+		}
+		for _, tst := range tests {
+			expected := tst.unhonored
+			if honor {
+				expected = tst.honored
+			}
+			if got[tst.path] != expected {
+				t.Errorf("With HonorCacheHeaders %v, expected %v dispatched == %v, got %v",
+					honor, tst.path, expected, got[tst.path])
+			}
+		}
+	}
+}
+
+func TestDispatcherStatusRefreshTimes(t *testing.T) {
+	origMin := walker.Config.Dispatcher.MinLinkRefreshTime
+	origStatus := walker.Config.Dispatcher.StatusRefreshTimes
+	defer func() {
+		walker.Config.Dispatcher.MinLinkRefreshTime = origMin
+		walker.Config.Dispatcher.StatusRefreshTimes = origStatus
+	}()
+	walker.Config.Dispatcher.MinLinkRefreshTime = "1h"
+	walker.Config.Dispatcher.StatusRefreshTimes = map[string]string{
+		"200":   "72h",
+		"404":   "720h",
+		"5xx":   "24h",
+		"error": "0s",
+	}
+
+	now := time.Now()
+	tests := []struct {
+		path       string
+		status     int
+		fetchErr   string
+		crawled    time.Time
+		dispatched bool
+	}{
+		{"/ok-recent.html", 200, "", now.Add(-48 * time.Hour), false},
+		{"/ok-old.html", 200, "", now.Add(-96 * time.Hour), true},
+		{"/missing.html", 404, "", now.Add(-96 * time.Hour), false},
+		{"/unavailable.html", 503, "", now.Add(-25 * time.Hour), true},
+		{"/server-error.html", 500, "", now.Add(-12 * time.Hour), false},
+		{"/moved.html", 301, "", now.Add(-2 * time.Hour), true}, // min_link_refresh_time
+		{"/failed.html", 0, "connection refused", now.Add(-time.Minute), true},
+	}
+
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, "test.com", MaxPriority).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	for _, tst := range tests {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, stat, err)
+						 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			"test.com", "", tst.path, "http", tst.crawled, tst.status, tst.fetchErr).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+
+	runDispatcher(t)
+
+	got := map[string]bool{}
+	iter := db.Query(`SELECT path FROM segments WHERE dom = 'test.com'`).Iter()
+	var path string
+	for iter.Scan(&path) {
+		got[path] = true
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatalf("Failed to read segments: %v", err)
+	}
+	for _, tst := range tests {
+		if got[tst.path] != tst.dispatched {
+			t.Errorf("Expected %v (status %d) dispatched == %v, got %v",
+				tst.path, tst.status, tst.dispatched, got[tst.path])
+		}
+	}
+}
+
+func TestDispatcherByteBudget(t *testing.T) {
+	origBudget := walker.Config.Dispatcher.DomainByteBudget
+	defer func() {
+		walker.Config.Dispatcher.DomainByteBudget = origBudget
+	}()
+	walker.Config.Dispatcher.DomainByteBudget = 1000
+
+	db := GetTestDB()
+	tests := []struct {
+		dom      string
+		override int64
+		bytes    int64
+		expected bool
+	}{
+		// under budget
+		{"under.com", 0, 999, true},
+		// at the configured budget
+		{"over.com", 0, 1000, false},
+		// over the configured budget, but raised for this domain
+		{"raised.com", 5000, 1000, true},
+		// over the configured budget, but exempt
+		{"exempt.com", -1, 1000000, true},
+		// bytes fetched outside the window don't count
+		{"old.com", 0, 0, true},
+	}
+	now := time.Now()
+	for _, tst := range tests {
+		err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched, byte_budget)
+						 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false, ?)`,
+			tst.dom, MaxPriority, tst.override).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain: %v", err)
+		}
+		err = db.Query(`INSERT INTO links (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+			tst.dom, "", "/page1.html", "http", walker.NotYetCrawled).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+		err = db.Query(`UPDATE domain_fetch_counts SET bytes = bytes + ? WHERE dom = ? AND bucket = ?`,
+			tst.bytes, tst.dom, now.Truncate(domainFetchBucket)).Exec()
+		if err != nil {
+			t.Fatalf("Failed to count bytes: %v", err)
+		}
+	}
+	err := db.Query(`UPDATE domain_fetch_counts SET bytes = bytes + ? WHERE dom = ? AND bucket = ?`,
+		int64(1000000), "old.com", now.Add(-48*time.Hour).Truncate(domainFetchBucket)).Exec()
+	if err != nil {
+		t.Fatalf("Failed to count bytes: %v", err)
+	}
+
+	runDispatcher(t)
+
+	for _, tst := range tests {
+		var count int
+		err := db.Query(`SELECT COUNT(*) FROM segments WHERE dom = ?`, tst.dom).Scan(&count)
+		if err != nil {
+			t.Fatalf("Failed to read segments: %v", err)
+		}
+		if (count > 0) != tst.expected {
+			t.Errorf("Expected %v dispatched == %v, got %v", tst.dom, tst.expected, count > 0)
+		}
+	}
+}
+
+func TestDispatcherNonstandardPorts(t *testing.T) {
+	orig := walker.Config.Fetcher.NonstandardPorts
+	defer func() {
+		walker.Config.Fetcher.NonstandardPorts = orig
+	}()
+
+	for _, policy := range []string{"crawl", "record", "drop"} {
+		walker.Config.Fetcher.NonstandardPorts = policy
+		db := GetTestDB()
+		for _, dom := range []string{"test.com", "test.com:8443"} {
+			err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+							 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, dom, MaxPriority).Exec()
+			if err != nil {
+				t.Fatalf("Failed to insert domain: %v", err)
+			}
+			err = db.Query(`INSERT INTO links (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+				dom, "", "/page1.html", "http", walker.NotYetCrawled).Exec()
+			if err != nil {
+				t.Fatalf("Failed to insert link: %v", err)
+			}
+		}
+
+		runDispatcher(t)
+
+		expected := map[string]bool{"test.com": true, "test.com:8443": policy == "crawl"}
+		for dom, dispatched := range expected {
+			var count int
+			err := db.Query(`SELECT COUNT(*) FROM segments WHERE dom = ?`, dom).Scan(&count)
+			if err != nil {
+				t.Fatalf("Failed to read segments: %v", err)
+			}
+			if (count > 0) != dispatched {
+				t.Errorf("With nonstandard_ports %q expected %v dispatched == %v, got %v",
+					policy, dom, dispatched, count > 0)
+			}
+		}
+	}
+}
+
+func TestSegmentPreview(t *testing.T) {
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, "test.com", MaxPriority).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	crawled := time.Now().Add(-2 * time.Hour)
+	links := []struct {
+		path   string
+		time   time.Time
+		getnow bool
+		fnv    int64
+	}{
+		{"/getnow.html", walker.NotYetCrawled, true, 0},
+		{"/new.html", walker.NotYetCrawled, false, 0},
+		{"/old.html", crawled, false, 0},
+		{"/dup.html?sess=1", crawled, false, 5},
+		{"/dup.html?sess=2", crawled, false, 5},
+	}
+	for _, l := range links {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, getnow, fnv_txt)
+						 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			"test.com", "", l.path, "http", l.time, l.getnow, l.fnv).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+
+	sg := &SegmentGenerator{DB: db}
+	p, err := sg.Preview("test.com")
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if p.Skipped != "" {
+		t.Fatalf("Expected a segment, got skipped: %v", p.Skipped)
+	}
+	if p.TotalLinks != 5 || p.UncrawledLinks != 2 {
+		t.Errorf("Expected 5 links, 2 uncrawled; got %d, %d", p.TotalLinks, p.UncrawledLinks)
+	}
+
+	paths := func(links []*LinkInfo) map[string]bool {
+		m := map[string]bool{}
+		for _, l := range links {
+			m[l.URL.RequestURI()] = true
+		}
+		return m
+	}
+	if got := paths(p.GetNow); !reflect.DeepEqual(got, map[string]bool{"/getnow.html": true}) {
+		t.Errorf("Unexpected getnow links: %v", got)
+	}
+	if got := paths(p.Uncrawled); !reflect.DeepEqual(got, map[string]bool{"/new.html": true}) {
+		t.Errorf("Unexpected uncrawled links: %v", got)
+	}
+	if got := paths(p.Refresh); !reflect.DeepEqual(got, map[string]bool{"/old.html": true, "/dup.html": true}) {
+		t.Errorf("Unexpected refresh links: %v", got)
+	}
+	expectedRewritten := map[string]string{
+		"http://test.com/dup.html?sess=1": "http://test.com/dup.html",
+		"http://test.com/dup.html?sess=2": "http://test.com/dup.html",
+	}
+	if !reflect.DeepEqual(p.Rewritten, expectedRewritten) {
+		t.Errorf("Expected rewritten links %v, got %v", expectedRewritten, p.Rewritten)
+	}
+	if got := paths(p.Duplicates); !reflect.DeepEqual(got, map[string]bool{"/dup.html": true}) || len(p.Duplicates) != 1 {
+		t.Errorf("Expected one dropped /dup.html, got %v", p.Duplicates)
+	}
+
+	// Nothing should have been written
+	var count int
+	if err := db.Query(`SELECT COUNT(*) FROM segments WHERE dom = 'test.com'`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count segments: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no segment to be written, found %d links", count)
+	}
+	var dispatched bool
+	if err := db.Query(`SELECT dispatched FROM domain_info WHERE dom = 'test.com'`).Scan(&dispatched); err != nil {
+		t.Fatalf("Failed to read domain_info: %v", err)
+	}
+	if dispatched {
+		t.Errorf("Expected domain not to be marked dispatched")
+	}
+}
+
+func TestSegmentPreviewLeftOut(t *testing.T) {
+	origMax := walker.Config.Dispatcher.MaxLinksPerSegment
+	origRefresh := walker.Config.Dispatcher.MinLinkRefreshTime
+	defer func() {
+		walker.Config.Dispatcher.MaxLinksPerSegment = origMax
+		walker.Config.Dispatcher.MinLinkRefreshTime = origRefresh
+	}()
+	walker.Config.Dispatcher.MaxLinksPerSegment = 2
+	walker.Config.Dispatcher.MinLinkRefreshTime = "1h"
+
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, "test.com", MaxPriority).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	links := []struct {
+		path string
+		time time.Time
+	}{
+		{"/a.html", walker.NotYetCrawled},
+		{"/b.html", walker.NotYetCrawled},
+		{"/c.html", walker.NotYetCrawled},
+		{"/fresh.html", time.Now().Add(-time.Minute)},
+	}
+	for _, l := range links {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+			"test.com", "", l.path, "http", l.time).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+
+	ds := getDS(t)
+	defer ds.Close()
+	p, err := ds.PreviewSegment("test.com")
+	if err != nil {
+		t.Fatalf("PreviewSegment failed: %v", err)
+	}
+	if len(p.Uncrawled) != 2 {
+		t.Errorf("Expected 2 uncrawled links dispatched, got %v", p.Uncrawled)
+	}
+	if len(p.Deferred) != 1 || p.Deferred[0].URL.Path != "/c.html" {
+		t.Errorf("Expected /c.html to be left for a later segment, got %v", p.Deferred)
+	}
+	if len(p.TooFresh) != 1 || p.TooFresh[0].URL.Path != "/fresh.html" {
+		t.Errorf("Expected /fresh.html to be too fresh, got %v", p.TooFresh)
+	}
+}
+
+func TestDispatcherKeepsConfiguredQueryParams(t *testing.T) {
+	orig := walker.Config.Fetcher.DomainQueryParams
+	defer func() {
+		walker.Config.Fetcher.DomainQueryParams = orig
+		walker.PostConfigHooks()
+	}()
+	walker.Config.Fetcher.DomainQueryParams = map[string]walker.DomainQueryParams{
+		"test.com": {Keep: []string{"id"}},
+	}
+	walker.PostConfigHooks()
+
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, "test.com", MaxPriority).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	// Both pages have the same content, but id is kept while sess is not
+	crawled := time.Now().Add(-2 * time.Hour)
+	for _, path := range []string{"/item.html?id=1&sess=1", "/item.html?id=2&sess=2"} {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, fnv_txt)
+						 VALUES (?, ?, ?, ?, ?, ?)`,
+			"test.com", "", path, "http", crawled, 5).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+
+	sg := &SegmentGenerator{DB: db}
+	p, err := sg.Preview("test.com")
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	expectedRewritten := map[string]string{
+		"http://test.com/item.html?id=1&sess=1": "http://test.com/item.html?id=1",
+		"http://test.com/item.html?id=2&sess=2": "http://test.com/item.html?id=2",
+	}
+	if !reflect.DeepEqual(p.Rewritten, expectedRewritten) {
+		t.Errorf("Expected rewritten links %v, got %v", expectedRewritten, p.Rewritten)
+	}
+	if len(p.Refresh) != 2 || len(p.Duplicates) != 0 {
+		t.Errorf("Expected both links to be dispatched, got %v (duplicates %v)", p.Refresh, p.Duplicates)
+	}
+}
+
+type recordingNotifier struct {
+	alerts []*walker.Alert
+}
+
+func (n *recordingNotifier) Notify(a *walker.Alert) error {
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+func TestDispatcherDomainErrorRateAlert(t *testing.T) {
+	origRate := walker.Config.Alerts.DomainErrorRate
+	origMin := walker.Config.Alerts.DomainErrorRateMinFetches
+	defer func() {
+		walker.Config.Alerts.DomainErrorRate = origRate
+		walker.Config.Alerts.DomainErrorRateMinFetches = origMin
+	}()
+	walker.Config.Alerts.DomainErrorRate = 0.5
+	walker.Config.Alerts.DomainErrorRateMinFetches = 3
+
+	now := time.Now()
+	links := []struct {
+		dom, path string
+		stat      int
+		err       string
+		crawled   time.Time
+	}{
+		{"failing.com", "/1.html", 500, "", now.Add(-time.Minute)},
+		{"failing.com", "/2.html", 0, "timeout", now.Add(-time.Minute)},
+		{"failing.com", "/3.html", 200, "", now.Add(-time.Minute)},
+		// Outside of the error rate window
+		{"failing.com", "/4.html", 200, "", now.Add(-24 * time.Hour)},
+
+		{"healthy.com", "/1.html", 200, "", now.Add(-time.Minute)},
+		{"healthy.com", "/2.html", 200, "", now.Add(-time.Minute)},
+		{"healthy.com", "/3.html", 503, "", now.Add(-time.Minute)},
+
+		// Too few fetches to judge
+		{"small.com", "/1.html", 500, "", now.Add(-time.Minute)},
+	}
+
+	db := GetTestDB()
+	for _, dom := range []string{"failing.com", "healthy.com", "small.com"} {
+		err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+						 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, dom, MaxPriority).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain: %v", err)
+		}
+	}
+	for _, l := range links {
+		err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, stat, err)
+						 VALUES (?, '', ?, 'http', ?, ?, ?)`, l.dom, l.path, l.crawled, l.stat, l.err).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+
+	d, err := NewDispatcher()
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	rec := &recordingNotifier{}
+	d.Alerter.Notifiers = []walker.Notifier{rec}
+	if err := d.oneShot(1); err != nil {
+		t.Fatalf("Failed to run dispatcher: %v", err)
+	}
+	d.Alerter.Wait()
+
+	if len(rec.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d: %v", len(rec.alerts), rec.alerts)
+	}
+	a := rec.alerts[0]
+	if a.Condition != AlertDomainErrorRate || a.Subject != "failing.com" {
+		t.Errorf("Unexpected alert %v", a)
+	}
+	if !strings.Contains(a.Message, "2 of 3 links") {
+		t.Errorf("Unexpected alert message %q", a.Message)
+	}
+}
+
+func TestDispatcherPermanentRedirects(t *testing.T) {
+	origRewrite := walker.Config.Dispatcher.RewritePermanentRedirects
+	origCrawls := walker.Config.Dispatcher.PermanentRedirectCrawls
+	defer func() {
+		walker.Config.Dispatcher.RewritePermanentRedirects = origRewrite
+		walker.Config.Dispatcher.PermanentRedirectCrawls = origCrawls
+	}()
+	walker.Config.Dispatcher.RewritePermanentRedirects = true
+	walker.Config.Dispatcher.PermanentRedirectCrawls = 2
+
+	now := time.Now()
+	type crawl struct {
+		stat  int
+		redto string
+	}
+	tests := []struct {
+		path       string
+		crawls     []crawl
+		tombstoned bool
+	}{
+		// Consistently moved within the domain
+		{"/moved.html", []crawl{{301, "http://test.com/new.html"}, {301, "http://test.com/new.html"}}, true},
+
+		// Consistently moved to another known domain
+		{"/away.html", []crawl{{308, "http://other.com/page.html"}, {301, "http://other.com/page.html"}}, true},
+
+		// Only redirected once
+		{"/once.html", []crawl{{200, ""}, {301, "http://test.com/new.html"}}, false},
+
+		// Redirected somewhere different each time
+		{"/moving.html", []crawl{{301, "http://test.com/a.html"}, {301, "http://test.com/b.html"}}, false},
+
+		// Temporary redirects
+		{"/temp.html", []crawl{{302, "http://test.com/new.html"}, {302, "http://test.com/new.html"}}, false},
+
+		// The target's domain is not crawled
+		{"/unknown.html", []crawl{{301, "http://unknown.com/"}, {301, "http://unknown.com/"}}, false},
+	}
+
+	db := GetTestDB()
+	for _, dom := range []string{"test.com", "other.com"} {
+		err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+						 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false)`, dom, MaxPriority).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain: %v", err)
+		}
+	}
+	for _, tst := range tests {
+		for i, c := range tst.crawls {
+			crawled := now.Add(-time.Duration(len(tst.crawls)-i) * time.Hour)
+			err := db.Query(`INSERT INTO links (dom, subdom, path, proto, time, stat, redto_url)
+							 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				"test.com", "", tst.path, "http", crawled, c.stat, c.redto).Exec()
+			if err != nil {
+				t.Fatalf("Failed to insert link: %v", err)
+			}
+		}
+	}
+
+	runDispatcher(t)
+
+	dispatched := map[string]bool{}
+	iter := db.Query(`SELECT path FROM segments WHERE dom = 'test.com'`).Iter()
+	var path string
+	for iter.Scan(&path) {
+		dispatched[path] = true
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatalf("Failed to read segments: %v", err)
+	}
+
+	for _, tst := range tests {
+		if dispatched[tst.path] == tst.tombstoned {
+			t.Errorf("Expected %v dispatched == %v", tst.path, !tst.tombstoned)
+		}
+
+		var tombstoned bool
+		var redto string
+		err := db.Query(`SELECT tombstoned, redto_url FROM links
+						 WHERE dom = 'test.com' AND subdom = '' AND path = ? AND proto = 'http'
+						 ORDER BY time DESC LIMIT 1`, tst.path).Scan(&tombstoned, &redto)
+		if err != nil {
+			t.Fatalf("Failed to read latest row of %v: %v", tst.path, err)
+		}
+		if tombstoned != tst.tombstoned {
+			t.Errorf("Expected %v tombstoned == %v, got %v", tst.path, tst.tombstoned, tombstoned)
+		}
+		if redto != tst.crawls[len(tst.crawls)-1].redto {
+			t.Errorf("Expected %v to keep redto_url %q, got %q", tst.path, tst.crawls[len(tst.crawls)-1].redto, redto)
+		}
+	}
+
+	targets := []struct {
+		dom, path string
+	}{
+		{"test.com", "/new.html"},
+		{"other.com", "/page.html"},
+	}
+	for _, target := range targets {
+		var crawled time.Time
+		err := db.Query(`SELECT time FROM links WHERE dom = ? AND subdom = '' AND path = ? AND proto = 'http'`,
+			target.dom, target.path).Scan(&crawled)
+		if err != nil {
+			t.Errorf("Expected redirect target %v%v to be stored: %v", target.dom, target.path, err)
+		} else if !crawled.Equal(walker.NotYetCrawled) {
+			t.Errorf("Expected redirect target %v%v to be uncrawled, got time %v", target.dom, target.path, crawled)
+		}
+	}
+	var count int
+	if err := db.Query(`SELECT COUNT(*) FROM links WHERE dom = 'unknown.com'`).Scan(&count); err != nil || count != 0 {
+		t.Errorf("Expected no links stored for unknown.com, got %d (err %v)", count, err)
+	}
+}
@@ -0,0 +1,90 @@
+package cassandra
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// doctorProbeKey is the walker_globals key ProbeWrite writes and deletes
+const doctorProbeKey = "doctor_probe"
+
+var schemaTableRegex = regexp.MustCompile(`^CREATE TABLE \{\{\.Keyspace\}\}\.(\w+) \(`)
+
+// schemaColumns returns the columns of each table in the walker schema
+func schemaColumns() map[string][]string {
+	tables := map[string][]string{}
+	table := ""
+	scanner := bufio.NewScanner(strings.NewReader(schemaTemplate))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := schemaTableRegex.FindStringSubmatch(line); m != nil {
+			table = m[1]
+			tables[table] = nil
+			continue
+		}
+		if table == "" || line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		if strings.HasPrefix(line, "PRIMARY KEY") || strings.HasPrefix(line, ")") {
+			table = ""
+			continue
+		}
+		tables[table] = append(tables[table], strings.Fields(line)[0])
+	}
+	return tables
+}
+
+// SchemaProblems compares the datastore's keyspace with the walker schema (see
+// GetSchema), returning a description of each table or column that is
+// missing, which usually means walker was upgraded without migrating the
+// schema. Extra tables and columns are not problems.
+func (ds *Datastore) SchemaProblems() ([]string, error) {
+	meta, err := ds.db.KeyspaceMetadata(ds.cf.Keyspace)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the schema of keyspace %v: %v", ds.cf.Keyspace, err)
+	}
+
+	var problems []string
+	for table, columns := range schemaColumns() {
+		tmeta, ok := meta.Tables[table]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("table %v is missing", table))
+			continue
+		}
+		for _, col := range columns {
+			if _, ok := tmeta.Columns[col]; !ok {
+				problems = append(problems, fmt.Sprintf("column %v.%v is missing", table, col))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// ProbeWrite writes a row to walker_globals, reads it back and deletes it,
+// returning an error if any of that fails or the row read is not the one
+// written.
+func (ds *Datastore) ProbeWrite() error {
+	val := rand.Int31()
+	err := ds.db.Query(`INSERT INTO walker_globals (key, val) VALUES (?, ?)`, doctorProbeKey, val).Exec()
+	if err != nil {
+		return fmt.Errorf("Failed to write probe row: %v", err)
+	}
+	defer func() {
+		ds.db.Query(`DELETE FROM walker_globals WHERE key = ?`, doctorProbeKey).Exec()
+	}()
+
+	var got int32
+	err = ds.read(`SELECT val FROM walker_globals WHERE key = ?`, doctorProbeKey).Scan(&got)
+	if err != nil {
+		return fmt.Errorf("Failed to read probe row: %v", err)
+	}
+	if got != val {
+		return fmt.Errorf("Read probe value %v, but wrote %v", got, val)
+	}
+	return nil
+}
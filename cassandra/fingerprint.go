@@ -0,0 +1,119 @@
+package cassandra
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// maxFingerprintLinks is the most links FindLinksByFingerprint and
+// FindLinksBySHA256 return, since a fingerprint like that of an empty page
+// may be shared by a great many
+var maxFingerprintLinks = 1000
+
+// indexFingerprints records the fingerprints of fr (a fetch of u) in
+// links_by_fnv and links_by_sha256
+func (ds *Datastore) indexFingerprints(ctx context.Context, u *walker.URL, fr *walker.FetchResults) {
+	dom, subdom, path, proto, err := u.PrimaryKey()
+	if err != nil {
+		log4go.Error("Failed to index fingerprints of %v: %v", u, err)
+		return
+	}
+	batch := ds.throttle.batcher(ctx, ds.db)
+	for _, fp := range []int64{fr.FnvFingerprint, fr.FnvTextFingerprint} {
+		if fp == 0 {
+			continue
+		}
+		err := batch.add(`INSERT INTO links_by_fnv (fnv, dom, subdom, path, proto) VALUES (?, ?, ?, ?, ?)`,
+			fp, dom, subdom, path, proto)
+		if err != nil {
+			log4go.Error("Failed to index fingerprint %x of %v: %v", uint64(fp), u, err)
+		}
+	}
+	if fr.SHA256Fingerprint != nil {
+		err := batch.add(`INSERT INTO links_by_sha256 (sha256, dom, subdom, path, proto) VALUES (?, ?, ?, ?, ?)`,
+			fr.SHA256Fingerprint, dom, subdom, path, proto)
+		if err != nil {
+			log4go.Error("Failed to index sha256 fingerprint of %v: %v", u, err)
+		}
+	}
+	if err := batch.flush(); err != nil {
+		log4go.Error("Failed to index fingerprints of %v: %v", u, err)
+	}
+}
+
+// FindLinksByFingerprint is documented on the ModelDatastore interface.
+func (ds *Datastore) FindLinksByFingerprint(fnv int64) ([]*LinkInfo, error) {
+	itr := ds.read(`SELECT dom, subdom, path, proto FROM links_by_fnv WHERE fnv = ?`, fnv).Iter()
+	return ds.fingerprintedLinks(itr, func(linfo *LinkInfo) bool {
+		return linfo.FnvFingerprint == fnv || linfo.FnvTextFingerprint == fnv
+	})
+}
+
+// FindLinksBySHA256 is documented on the ModelDatastore interface.
+func (ds *Datastore) FindLinksBySHA256(sum []byte) ([]*LinkInfo, error) {
+	itr := ds.read(`SELECT dom, subdom, path, proto FROM links_by_sha256 WHERE sha256 = ?`, sum).Iter()
+	return ds.fingerprintedLinks(itr, func(linfo *LinkInfo) bool {
+		return bytes.Equal(linfo.SHA256Fingerprint, sum)
+	})
+}
+
+// fingerprintedLinks reads the latest fetch of each link itr (a query of
+// links_by_fnv or links_by_sha256) returns, keeping those that still match
+func (ds *Datastore) fingerprintedLinks(itr *gocql.Iter, match func(*LinkInfo) bool) ([]*LinkInfo, error) {
+	var linfos []*LinkInfo
+	var dom, subdom, path, proto string
+	for len(linfos) < maxFingerprintLinks && itr.Scan(&dom, &subdom, &path, &proto) {
+		linfo, err := ds.latestFetch(dom, subdom, path, proto)
+		if err != nil {
+			itr.Close()
+			return linfos, err
+		}
+		if linfo != nil && match(linfo) {
+			linfos = append(linfos, linfo)
+		}
+	}
+	return linfos, itr.Close()
+}
+
+// latestFetch returns the latest fetch of the given link with its
+// fingerprints, or nil if it is not in the links table
+func (ds *Datastore) latestFetch(dom, subdom, path, proto string) (*LinkInfo, error) {
+	var crawlTime time.Time
+	var status int
+	var getError, title string
+	var robotsExcluded bool
+	var fnvFP, fnvTextFP int64
+	var sha256 []byte
+	err := ds.read(`SELECT time, stat, err, robot_ex, fnv, fnv_txt, sha256, title FROM links
+						WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?
+						ORDER BY time DESC LIMIT 1`,
+		dom, subdom, path, proto).Scan(&crawlTime, &status, &getError, &robotsExcluded, &fnvFP, &fnvTextFP,
+		&sha256, &title)
+	if err == gocql.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	u, err := walker.CreateURL(dom, subdom, path, proto, crawlTime)
+	if err != nil {
+		return nil, err
+	}
+	return &LinkInfo{
+		URL:                u,
+		Status:             status,
+		Error:              getError,
+		CrawlTime:          crawlTime,
+		RobotsExcluded:     robotsExcluded,
+		FnvFingerprint:     fnvFP,
+		FnvTextFingerprint: fnvTextFP,
+		SHA256Fingerprint:  sha256,
+		Title:              title,
+		position:           linkPosition{Dom: dom, Subdom: subdom, Path: path, Proto: proto},
+	}, nil
+}
@@ -14,6 +14,12 @@ import (
 
 // GetConfig returns a fresh ClusterConfig, configured against walker.Config
 func GetConfig() *gocql.ClusterConfig {
+	return GetConfigForKeyspace(walker.Config.Cassandra.Keyspace)
+}
+
+// GetConfigForKeyspace returns a fresh ClusterConfig like GetConfig, but
+// using the given keyspace instead of the configured one
+func GetConfigForKeyspace(keyspace string) *gocql.ClusterConfig {
 	timeout, err := time.ParseDuration(walker.Config.Cassandra.Timeout)
 	if err != nil {
 		// This shouldn't happen because it is tested in assertConfigInvariants
@@ -21,7 +27,7 @@ func GetConfig() *gocql.ClusterConfig {
 	}
 
 	config := gocql.NewCluster(walker.Config.Cassandra.Hosts...)
-	config.Keyspace = walker.Config.Cassandra.Keyspace
+	config.Keyspace = keyspace
 	config.Timeout = timeout
 	config.CQLVersion = walker.Config.Cassandra.CQLVersion
 	config.ProtoVersion = walker.Config.Cassandra.ProtoVersion
@@ -31,9 +37,54 @@ func GetConfig() *gocql.ClusterConfig {
 	config.DiscoverHosts = walker.Config.Cassandra.DiscoverHosts
 	config.MaxPreparedStmts = walker.Config.Cassandra.MaxPreparedStmts
 	config.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: walker.Config.Cassandra.NumQueryRetries}
+
+	// These were all checked in assertConfigInvariants
+	config.ConnectTimeout, _ = time.ParseDuration(walker.Config.Cassandra.ConnectTimeout)
+	config.Consistency = gocql.ParseConsistency(walker.Config.Cassandra.Consistency)
+	config.SerialConsistency = parseSerialConsistency(walker.Config.Cassandra.SerialConsistency)
+	config.PoolConfig.HostSelectionPolicy = hostSelectionPolicy()
 	return config
 }
 
+// hostSelectionPolicy returns a new policy for cassandra.host_selection_policy
+// (a policy may not be shared between sessions)
+func hostSelectionPolicy() gocql.HostSelectionPolicy {
+	localDC := walker.Config.Cassandra.LocalDC
+	switch strings.ToLower(walker.Config.Cassandra.HostSelectionPolicy) {
+	case "token_aware":
+		if localDC != "" {
+			return gocql.TokenAwareHostPolicy(gocql.DCAwareRoundRobinPolicy(localDC))
+		}
+		return gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	case "dc_aware":
+		return gocql.DCAwareRoundRobinPolicy(localDC)
+	}
+	return gocql.RoundRobinHostPolicy()
+}
+
+func parseSerialConsistency(s string) gocql.SerialConsistency {
+	if strings.ToLower(s) == "local_serial" {
+		return gocql.LocalSerial
+	}
+	return gocql.Serial
+}
+
+// speculativeExecution returns the policy for cassandra.speculative_attempts,
+// or nil if it is disabled
+func speculativeExecution() gocql.SpeculativeExecutionPolicy {
+	if walker.Config.Cassandra.SpeculativeAttempts <= 0 {
+		return nil
+	}
+	delay, err := time.ParseDuration(walker.Config.Cassandra.SpeculativeDelay)
+	if err != nil {
+		panic(err) // Should not happen since it is parsed at config load
+	}
+	return &gocql.SimpleSpeculativeExecution{
+		NumAttempts:  walker.Config.Cassandra.SpeculativeAttempts,
+		TimeoutDelay: delay,
+	}
+}
+
 // initdb ensures we only try to create the cassandra schema once in testing
 var initdb sync.Once
 
@@ -60,7 +111,9 @@ func GetTestDB() *gocql.Session {
 		panic(fmt.Sprintf("Could not connect to local cassandra db: %v", err))
 	}
 
-	tables := []string{"links", "segments", "domain_info", "active_fetchers"}
+	tables := []string{"links", "segments", "domain_info", "active_fetchers", "domain_counters", "fetch_counts",
+		"handler_dead_letters", "domain_fetch_counts", "pending_domains", "tls_certs",
+		"claim_queue", "claims_audit", "dispatcher_scan", "dispatcher_progress", "links_by_fnv", "links_by_sha256", "link_referrers"}
 	for _, table := range tables {
 		err := db.Query(fmt.Sprintf(`TRUNCATE %v`, table)).Exec()
 		if err != nil {
@@ -76,21 +129,32 @@ func GetTestDB() *gocql.Session {
 // data), with the exception of the walker_test schema, which it will drop
 // automatically.
 func CreateSchema() error {
-	config := GetConfig()
-	config.Keyspace = ""
+	return CreateSchemaForKeyspace(walker.Config.Cassandra.Keyspace)
+}
+
+// CreateSchemaForKeyspace is like CreateSchema, but creates the schema in the
+// given keyspace. This allows several independent crawls to share a cluster.
+// Test keyspaces (walker_test and walker_test_*) are dropped automatically.
+func CreateSchemaForKeyspace(keyspace string) error {
+	if !walker.ValidKeyspace(keyspace) {
+		return fmt.Errorf("Invalid keyspace name %q", keyspace)
+	}
+
+	config := GetConfigForKeyspace("")
 	db, err := config.CreateSession()
 	if err != nil {
 		return fmt.Errorf("Could not connect to create cassandra schema: %v", err)
 	}
+	defer db.Close()
 
-	if walker.Config.Cassandra.Keyspace == "walker_test" {
-		err := db.Query("DROP KEYSPACE IF EXISTS walker_test").Exec()
+	if keyspace == "walker_test" || strings.HasPrefix(keyspace, "walker_test_") {
+		err := db.Query(fmt.Sprintf("DROP KEYSPACE IF EXISTS %s", keyspace)).Exec()
 		if err != nil {
-			return fmt.Errorf("Failed to drop walker_test keyspace: %v", err)
+			return fmt.Errorf("Failed to drop %v keyspace: %v", keyspace, err)
 		}
 	}
 
-	schema := GetSchema()
+	schema := GetSchemaForKeyspace(keyspace)
 	for _, q := range strings.Split(schema, ";") {
 		q = strings.TrimSpace(q)
 		if q == "" {
@@ -108,12 +172,20 @@ func CreateSchema() error {
 // datastore. Certain values, like keyspace and replication factor, are
 // dynamically inserted.
 func GetSchema() string {
+	return GetSchemaForKeyspace(walker.Config.Cassandra.Keyspace)
+}
+
+// GetSchemaForKeyspace returns the CQL schema like GetSchema, but for the
+// given keyspace
+func GetSchemaForKeyspace(keyspace string) string {
 	t, err := template.New("schema").Parse(schemaTemplate)
 	if err != nil {
 
 		panic(fmt.Sprintf("Failure parsing the CQL schema template: %v", err))
 	}
+	cfg := walker.Config.Cassandra
+	cfg.Keyspace = keyspace
 	var b bytes.Buffer
-	t.Execute(&b, walker.Config.Cassandra)
+	t.Execute(&b, cfg)
 	return b.String()
 }
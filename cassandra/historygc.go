@@ -0,0 +1,221 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// historyGCProgressInterval is how many links the history collector scans
+// between progress log lines
+const historyGCProgressInterval = 100000
+
+// HistoryGCStats counts what a pass of the history collector did
+type HistoryGCStats struct {
+	// Number of links scanned
+	Links int64
+
+	// Number of links that had crawls deleted
+	Pruned int64
+
+	// Number of crawl rows deleted (with their bodies)
+	Rows int64
+
+	// Number of rows past the limits kept because a newer 304 Not Modified
+	// crawl uses their body
+	KeptForBody int64
+}
+
+// String formats HistoryGCStats for logging
+func (s HistoryGCStats) String() string {
+	return fmt.Sprintf("deleted %d crawl rows of %d links (%d links scanned, %d rows kept for their bodies)",
+		s.Rows, s.Pruned, s.Links, s.KeptForBody)
+}
+
+// historyCollector deletes the rows of the links table that are past the
+// retention limits of cassandra.history_max_crawls and history_max_age
+type historyCollector struct {
+	db        *gocql.Session
+	throttle  *writeThrottle
+	maxCrawls int
+	maxAge    time.Duration
+}
+
+// newHistoryCollector builds a historyCollector from the current config. Its
+// deletes go through their own throttle, limited to cassandra.history_gc_rate
+func newHistoryCollector(db *gocql.Session) *historyCollector {
+	maxAge, err := time.ParseDuration(walker.Config.Cassandra.HistoryMaxAge)
+	if err != nil {
+		panic(err) // Should not happen since it is parsed at config load
+	}
+	throttle := newWriteThrottle()
+	throttle.rate = float64(walker.Config.Cassandra.HistoryGCRate)
+	throttle.burst = throttle.rate
+	throttle.tokens = throttle.burst
+	return &historyCollector{
+		db:        db,
+		throttle:  throttle,
+		maxCrawls: walker.Config.Cassandra.HistoryMaxCrawls,
+		maxAge:    maxAge,
+	}
+}
+
+// historyRow is one crawl of a link, as read by the history collector
+type historyRow struct {
+	time     time.Time
+	bodyTime time.Time
+}
+
+// run makes one pass over the links table, deleting the crawls past the
+// limits. It stops early, returning ctx's error, if ctx is done.
+func (c *historyCollector) run(ctx context.Context) (HistoryGCStats, error) {
+	var stats HistoryGCStats
+	if c.maxCrawls == 0 && c.maxAge == 0 {
+		log4go.Info("No link history limits configured, nothing to collect")
+		return stats, nil
+	}
+	var cutoff time.Time
+	if c.maxAge > 0 {
+		cutoff = time.Now().Add(-c.maxAge)
+	}
+
+	itr := c.db.Query(`SELECT dom, subdom, path, proto, time, body_time FROM links`).WithContext(ctx).Iter()
+	var dom, subdom, path, proto string
+	var curDom, curSubdom, curPath, curProto string
+	var rows []historyRow
+	var row historyRow
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		stats.Links++
+		if stats.Links%historyGCProgressInterval == 0 {
+			log4go.Info("History collection progress: %v", stats)
+		}
+		err := c.prune(ctx, &stats, cutoff, rows, curDom, curSubdom, curPath, curProto)
+		rows = rows[:0]
+		return err
+	}
+	for itr.Scan(&dom, &subdom, &path, &proto, &row.time, &row.bodyTime) {
+		if dom != curDom || subdom != curSubdom || path != curPath || proto != curProto {
+			if err := flush(); err != nil {
+				itr.Close()
+				return stats, err
+			}
+			curDom, curSubdom, curPath, curProto = dom, subdom, path, proto
+		}
+		rows = append(rows, row)
+		row = historyRow{}
+	}
+	if err := itr.Close(); err != nil {
+		return stats, fmt.Errorf("Failed to scan links: %v", err)
+	}
+	return stats, flush()
+}
+
+// prune deletes the crawls of one link (rows, in ascending time order) that
+// are past the limits. The not-yet-crawled row and the latest crawl are
+// always kept.
+func (c *historyCollector) prune(ctx context.Context, stats *HistoryGCStats, cutoff time.Time,
+	rows []historyRow, dom, subdom, path, proto string) error {
+
+	var crawls []historyRow
+	for _, r := range rows {
+		if !r.time.Equal(walker.NotYetCrawled) {
+			crawls = append(crawls, r)
+		}
+	}
+
+	// Index of the first crawl to keep
+	first := 0
+	if c.maxCrawls > 0 && len(crawls) > c.maxCrawls {
+		first = len(crawls) - c.maxCrawls
+	}
+	if !cutoff.IsZero() {
+		for first < len(crawls) && crawls[first].time.Before(cutoff) {
+			first++
+		}
+	}
+	if first >= len(crawls) {
+		first = len(crawls) - 1
+	}
+	if first <= 0 {
+		return nil
+	}
+
+	// Bodies of 304 crawls are read from the earlier crawl they refer to
+	usedBodies := map[time.Time]bool{}
+	for _, r := range crawls[first:] {
+		if !r.bodyTime.IsZero() {
+			usedBodies[r.bodyTime] = true
+		}
+	}
+
+	deleted := false
+	for _, r := range crawls[:first] {
+		if usedBodies[r.time] {
+			stats.KeptForBody++
+			continue
+		}
+		err := c.throttle.exec(ctx, c.db.Query(
+			`DELETE FROM links WHERE dom = ? AND subdom = ? AND path = ? AND proto = ? AND time = ?`,
+			dom, subdom, path, proto, r.time))
+		if err != nil {
+			return fmt.Errorf("Failed to delete crawl of %v%v at %v: %v", dom, path, r.time, err)
+		}
+		stats.Rows++
+		deleted = true
+	}
+	if deleted {
+		stats.Pruned++
+	}
+	return nil
+}
+
+// CollectHistory deletes the crawls of every link that are past the limits
+// set by cassandra.history_max_crawls and history_max_age (see walker.yaml),
+// returning what it did. It runs until the whole links table has been
+// scanned or ctx is done.
+func (ds *Datastore) CollectHistory(ctx context.Context) (HistoryGCStats, error) {
+	stats, err := newHistoryCollector(ds.db).run(ctx)
+	log4go.Info("History collection %v", stats)
+	return stats, err
+}
+
+// collectHistory prunes link history every dispatcher.history_gc_interval,
+// if it is set, until the dispatcher is stopped
+func (d *Dispatcher) collectHistory() {
+	interval, err := time.ParseDuration(walker.Config.Dispatcher.HistoryGCInterval)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	if interval == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-d.quit
+		cancel()
+	}()
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-d.quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		stats, err := newHistoryCollector(d.db).run(ctx)
+		if err != nil && ctx.Err() == nil {
+			log4go.Error("History collection failed: %v", err)
+		}
+		log4go.Info("History collection %v", stats)
+	}
+}
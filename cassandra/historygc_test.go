@@ -0,0 +1,125 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestCollectHistory(t *testing.T) {
+	origCrawls := walker.Config.Cassandra.HistoryMaxCrawls
+	origAge := walker.Config.Cassandra.HistoryMaxAge
+	defer func() {
+		walker.Config.Cassandra.HistoryMaxCrawls = origCrawls
+		walker.Config.Cassandra.HistoryMaxAge = origAge
+	}()
+
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Millisecond)
+	store := func(link string, ago time.Duration, notModifiedSince time.Duration) {
+		u := walker.MustParse(link)
+		res := &walker.FetchResults{
+			URL:       u,
+			FetchTime: now.Add(-ago),
+			Response:  &http.Response{StatusCode: 200},
+			Body:      "<html>" + link + "</html>",
+		}
+		if notModifiedSince > 0 {
+			u.LastCrawled = now.Add(-notModifiedSince)
+			res.Response.StatusCode = http.StatusNotModified
+			res.NotModified = true
+			res.Body = ""
+		}
+		ds.StoreURLFetchResults(ctx, res)
+	}
+
+	// Four crawls, the two oldest past history_max_crawls
+	for _, ago := range []time.Duration{4 * time.Hour, 3 * time.Hour, 2 * time.Hour, time.Hour} {
+		store("http://test.com/a.html", ago, 0)
+	}
+	// The 200 is past history_max_age, but the 304 reads its body
+	store("http://test.com/b.html", 4*time.Hour, 0)
+	store("http://test.com/b.html", time.Hour, 4*time.Hour)
+	// The only crawl is past history_max_age, but the latest is always kept
+	store("http://test.com/c.html", 10*time.Hour, 0)
+	// Not yet crawled
+	ds.StoreParsedURL(ctx, walker.MustParse("http://test.com/d.html"), nil)
+
+	// Nothing happens with no limits set
+	walker.Config.Cassandra.HistoryMaxCrawls = 0
+	walker.Config.Cassandra.HistoryMaxAge = "0s"
+	stats, err := ds.CollectHistory(ctx)
+	if err != nil {
+		t.Fatalf("CollectHistory failed: %v", err)
+	}
+	if stats != (HistoryGCStats{}) {
+		t.Errorf("Expected nothing done without limits, got %+v", stats)
+	}
+
+	walker.Config.Cassandra.HistoryMaxCrawls = 2
+	walker.Config.Cassandra.HistoryMaxAge = "150m"
+	stats, err = ds.CollectHistory(ctx)
+	if err != nil {
+		t.Fatalf("CollectHistory failed: %v", err)
+	}
+	if stats.Rows != 2 || stats.Pruned != 1 || stats.KeptForBody != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	tests := []struct {
+		link  string
+		times []time.Duration
+	}{
+		{"http://test.com/a.html", []time.Duration{2 * time.Hour, time.Hour}},
+		{"http://test.com/b.html", []time.Duration{4 * time.Hour, time.Hour}},
+		{"http://test.com/c.html", []time.Duration{10 * time.Hour}},
+		{"http://test.com/d.html", nil},
+	}
+	for _, tst := range tests {
+		linfos, err := ds.ListLinkHistorical(walker.MustParse(tst.link))
+		if err != nil {
+			t.Fatalf("ListLinkHistorical(%v) failed: %v", tst.link, err)
+		}
+		var crawls []*LinkInfo
+		for _, linfo := range linfos {
+			if !linfo.CrawlTime.Equal(walker.NotYetCrawled) {
+				crawls = append(crawls, linfo)
+			}
+		}
+		if len(crawls) != len(tst.times) {
+			t.Errorf("Expected %d crawls of %v left, got %d", len(tst.times), tst.link, len(crawls))
+			continue
+		}
+		for i, ago := range tst.times {
+			if !crawls[i].CrawlTime.Equal(now.Add(-ago)) {
+				t.Errorf("Crawl %d of %v: got %v, expected %v", i, tst.link, crawls[i].CrawlTime, now.Add(-ago))
+			}
+		}
+	}
+
+	linfo, err := ds.FindLink(walker.MustParse("http://test.com/d.html"), false)
+	if err != nil {
+		t.Fatalf("FindLink failed: %v", err)
+	}
+	if linfo == nil {
+		t.Errorf("Expected the not yet crawled link to be kept")
+	}
+
+	// The body of the kept 304 can still be read
+	linfo, err = ds.FindLink(walker.MustParse("http://test.com/b.html"), true)
+	if err != nil {
+		t.Fatalf("FindLink failed: %v", err)
+	}
+	if linfo.Body != "<html>http://test.com/b.html</html>" {
+		t.Errorf("Expected the 304 to keep its body, got %q", linfo.Body)
+	}
+}
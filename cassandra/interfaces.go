@@ -1,8 +1,11 @@
 package cassandra
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/gocql/gocql"
 	"github.com/iParadigms/walker"
@@ -31,18 +34,88 @@ type ModelDatastore interface {
 	// UpdateDomain.
 	UpdateDomain(domain string, info *DomainInfo, cfg DomainInfoUpdateConfig) error
 
+	// UpdateDomains makes the same update as UpdateDomain to every domain
+	// matching query, listing them a page at a time. query.Seed is where to
+	// start and query.Limit caps the number of domains matched (0 for all).
+	// If cfg.IfUnchanged is set each domain is updated with a lightweight
+	// transaction, and skipped as a conflict if it was changed since it was
+	// listed. It returns what was done even if it fails part way.
+	UpdateDomains(query DQ, info *DomainInfo, cfg DomainInfoUpdateConfig) (*DomainsUpdate, error)
+
+	// ExcludeDomainsMatching excludes every domain whose name matches the
+	// regular expression pattern, recording reason as the exclude reason
+	// (which must not be empty). It returns the number of domains excluded.
+	ExcludeDomainsMatching(pattern string, reason string) (int, error)
+
+	// PauseDomain stops the given domain from being dispatched or claimed
+	// until ResumeDomain is called. Unlike exclusion this is meant to be
+	// temporary; nothing about the domain or its links is changed.
+	PauseDomain(domain string) error
+
+	// ResumeDomain undoes PauseDomain
+	ResumeDomain(domain string) error
+
+	// SetTrapExcluded applies (or with false, lifts) the trap patterns the
+	// dispatcher suggested for the given domain (see
+	// dispatcher.trap_detection), so uncrawled links matching them are left
+	// out of its segments. Only the patterns suggested so far are applied;
+	// ones the dispatcher suggests later need excluding again.
+	SetTrapExcluded(domain string, excluded bool) error
+
+	// MarkGetNow sets the getnow flag on u, so it is put in the next segment
+	// dispatched for its domain regardless of when it was last crawled. The
+	// flag is cleared once the link is crawled. u is added (along with its
+	// domain) if walker does not know it yet, so it should be normalized.
+	MarkGetNow(u *walker.URL) error
+
 	// FindLink returns a LinkInfo matching the given URL. Arguments to this
 	// function are: (a) u is the url to find (b) collectContent, if true,
 	// indicates that Body and Headers field of LinkInfo will be populated.
 	FindLink(u *walker.URL, collectContent bool) (*LinkInfo, error)
 
-	// ListLinks fetches links for the given domain according to the given LQ
-	// (Link Query)
-	ListLinks(domain string, query LQ) ([]*LinkInfo, error)
+	// FindLinkAsOf returns what was known of the given URL at time t: the
+	// LinkInfo of its latest crawl not after t. A link that was parsed but
+	// not crawled by then is returned with CrawlTime walker.NotYetCrawled
+	// (walker does not record when links were parsed, so this is also the
+	// case for links found after t). Returns nil if the link is unknown.
+	FindLinkAsOf(u *walker.URL, t time.Time) (*LinkInfo, error)
+
+	// ListLinks fetches a page of links for the given domain according to
+	// the given LQ (Link Query)
+	ListLinks(domain string, query LQ) (*LinkPage, error)
+
+	// FindLinksByFingerprint returns the links whose latest fetch had the
+	// given fnv fingerprint, of either the body or its text (see
+	// LinkInfo.FnvFingerprint and FnvTextFingerprint), across all domains.
+	// Links are only found if cassandra.index_fingerprints was on when they
+	// were fetched.
+	FindLinksByFingerprint(fnv int64) ([]*LinkInfo, error)
+
+	// FindLinksBySHA256 is FindLinksByFingerprint for the SHA-256 digest of
+	// the body (see fetcher.fingerprints)
+	FindLinksBySHA256(sum []byte) ([]*LinkInfo, error)
 
 	// ListLinkHistorical gets the crawl history of a specific link
 	ListLinkHistorical(u *walker.URL) ([]*LinkInfo, error)
 
+	// ListReferrers returns up to limit of the pages u was parsed from (while
+	// cassandra.store_referrers was on), in order of their URLs
+	ListReferrers(u *walker.URL, limit int) ([]*Referrer, error)
+
+	// ListPolitenessAudits returns up to limit of the most recent politeness
+	// audits of dom (see cassandra.store_politeness_audit), newest first
+	ListPolitenessAudits(dom string, limit int) ([]*walker.PolitenessAudit, error)
+
+	// PreviewSegment returns which links the dispatcher would put in the next
+	// segment of domain, and why the others would be left out, without
+	// dispatching anything (see SegmentGenerator.Preview)
+	PreviewSegment(domain string) (*SegmentPreview, error)
+
+	// DiffLink compares the crawls of u made at t1 and t2 (crawl times as
+	// returned by ListLinkHistorical). It returns an error if u was not
+	// crawled at one of those times.
+	DiffLink(u *walker.URL, t1, t2 time.Time) (*LinkDiff, error)
+
 	// InsertLink inserts the given link into the database, adding it's domain
 	// if it does not exist. If excludeDomainReason is not empty, this domain
 	// will be excluded from crawling marked with the given reason.
@@ -52,21 +125,159 @@ type ModelDatastore interface {
 	// will insert as many as it can (it won't stop once it hits a bad link)
 	// and only return errors for problematic links or domains.
 	InsertLinks(links []string, excludeDomainReason string) []error
+
+	// InsertLinksWithTags is InsertLinks, also adding the given tags to the
+	// domains of the links (tags are kept per domain). Tags are trimmed, and
+	// must not be empty.
+	InsertLinksWithTags(links []string, excludeDomainReason string, tags []string) []error
+
+	// TagReports returns aggregate numbers for the domains carrying each
+	// tag, in order of tag. Like CrawlOverview it scans every domain.
+	TagReports() ([]*TagReport, error)
+
+	// FrontierEstimate estimates how long it will take to crawl the links
+	// waiting in the given domain, based on its recent fetch rate (or the
+	// default crawl delay if it has not been fetched recently). Returns nil
+	// if the domain does not exist.
+	FrontierEstimate(domain string) (*FrontierEstimate, error)
+
+	// BandwidthUsage reports the bytes fetched for the given domain and how
+	// much of its bandwidth budget (see dispatcher.domain_byte_budget) is
+	// left. Returns nil if the domain does not exist.
+	BandwidthUsage(domain string) (*BandwidthUsage, error)
+
+	// MimeTypeCounts reports how many successful fetches of the given domain
+	// got HTML, PDF, images or other content.
+	MimeTypeCounts(domain string) (*MimeTypeCounts, error)
+
+	// DomainStats gathers statistics about the crawl of the given domain for
+	// reporting. It reads every link of the domain, so it is as expensive as
+	// dispatching it. Returns nil if the domain does not exist.
+	DomainStats(domain string) (*DomainStats, error)
+
+	// GetDomainConfig returns the settings the given domain overrides.
+	// Returns nil if the domain does not exist.
+	GetDomainConfig(domain string) (*DomainConfig, error)
+
+	// SetDomainConfig replaces the settings the given domain overrides with
+	// cfg, returning an error without storing anything if cfg is not valid
+	SetDomainConfig(domain string, cfg *DomainConfig) error
+
+	// CrawlOverview returns aggregate numbers describing the crawl as a
+	// whole. It scans every domain, so it is more expensive than the other
+	// calls here.
+	CrawlOverview() (*CrawlOverview, error)
+
+	// ListPendingDomains returns up to limit domains waiting in
+	// pending_domains (see cassandra.track_pending_domains), most referenced
+	// first. A limit <= 0 returns all of them.
+	ListPendingDomains(limit int) ([]*PendingDomain, error)
+
+	// ApprovePendingDomain adds the given domain to the crawl and removes it
+	// from the pending domains
+	ApprovePendingDomain(domain string) error
+
+	// RejectPendingDomain adds the given domain as excluded, with the given
+	// reason, and removes it from the pending domains. Being known, it will
+	// not become pending again.
+	RejectPendingDomain(domain string, reason string) error
+
+	// ListStrandedClaims returns the domains the dispatcher released since
+	// the given time because the fetcher that claimed them stopped without
+	// releasing them (see claims_audit), most recent first
+	ListStrandedClaims(since time.Time) ([]*StrandedClaim, error)
 }
 
 // LQ is a link query struct used for gettings links from cassandra.
 // Zero-values mean use default behavior.
 type LQ struct {
-	// When listing links, the seed should be the URL preceding the queried
-	// set. When paginating, use the last URL of the previous set as the seed.
+	// The NextPageToken of the previous page, to list the links after it
+	// (see also LinkPageToken).
 	// Default: select from the beginning
-	Seed *walker.URL
+	PageToken string
 
 	// Limit the returned results, used for pagination.
 	// Default: no limit
 	Limit int
 
+	// Only return links whose URL matches this regular expression.
+	// Default: no filter
 	FilterRegex string
+
+	// The remaining filters apply to the most recent fetch of each link. The
+	// status and robots excluded (true) filters are passed to Cassandra to
+	// find the candidate links, so only the rows of those are read; the others
+	// are checked as links are read from the domain's partition, so a page
+	// filtered only by them may read many more rows than it returns.
+
+	// Only return links whose most recent fetch got this status code.
+	// Default (0): any status
+	StatusFilter int
+
+	// Only return links whose most recent fetch got a dead link status (see
+	// IsDeadStatus).
+	// Default (false): any status
+	Dead bool
+
+	// Only return links that did (true) or did not (false) have an error.
+	// Default (nil): either
+	HasError *bool
+
+	// Only return links that were (true) or were not (false) excluded by
+	// robots.txt.
+	// Default (nil): either
+	RobotsExcluded *bool
+
+	// Only return links crawled at or after CrawledAfter, and before
+	// CrawledBefore. Links not yet crawled count as crawled at
+	// walker.NotYetCrawled.
+	// Default (zero time): no bound
+	CrawledAfter  time.Time
+	CrawledBefore time.Time
+
+	// The order to list links in. Sorting other than by path reads all of
+	// the domain's links (up to console.max_sorted_links, see
+	// LinkPage.Truncated) for every page.
+	// Default (empty): SortByPath
+	Sort LinkSort
+}
+
+// LinkSort is an order ListLinks can list links in
+type LinkSort string
+
+const (
+	// SortByPath lists links in the order they are stored: by subdomain,
+	// then path
+	SortByPath LinkSort = "path"
+
+	// SortByCrawlTime lists the most recently crawled links first, and links
+	// not yet crawled last
+	SortByCrawlTime LinkSort = "time"
+
+	// SortByStatus lists links by the status code of their most recent
+	// fetch, lowest first, and links without one last
+	SortByStatus LinkSort = "status"
+)
+
+// LinkPage is a page of links returned by ListLinks
+type LinkPage struct {
+	Links []*LinkInfo
+
+	// Pass as LQ.PageToken to get the next page. It is empty when this page
+	// came up short of the limit, meaning there are no more links.
+	NextPageToken string
+
+	// An estimate of how many links of the domain match the query. It is
+	// exact if they all fit on the first page; otherwise it is the domain's
+	// link count (see NumberLinksTotal in DomainInfo), or the number of
+	// candidate links if Cassandra found them (see LQ), scaled by the share
+	// of the links read for this page that matched the filters.
+	EstimatedTotal int
+
+	// True if the links were sorted (see LQ.Sort) but the domain has more
+	// than console.max_sorted_links of them, so only that many (the first by
+	// path) were sorted and listed
+	Truncated bool
 }
 
 // LinkInfo defines a row from the link or segment table
@@ -86,26 +297,75 @@ type LinkInfo struct {
 	// Was this excluded by robots
 	RobotsExcluded bool
 
+	// Was this left undownloaded because of a HEAD precheck (only populated
+	// by ListLinkHistorical)
+	SkippedByPrecheck bool
+
+	// Was the body cut off at fetcher.max_http_content_size_bytes (only
+	// populated by ListLinkHistorical)
+	Truncated bool
+
 	// URL this link redirected to if it was a redirect
 	RedirectedTo string
 
 	// Whether this link was flagged for immediate fetching
 	GetNow bool
 
+	// Whether this link was parsed from an anchor marked rel="nofollow",
+	// "ugc" or "sponsored"
+	Nofollow bool
+
+	// Whether this link was parsed as the target of a JavaScript redirect
+	JSRedirect bool
+
+	// The pages this link was first and most recently parsed from (if
+	// cassandra.store_referrers was on)
+	FirstReferrer string
+	LastReferrer  string
+
 	// Mime type (or Content-Type) of the returned data
 	Mime string
 
+	// Content-Language of the returned data, if the server sent one
+	ContentLanguage string
+
+	// The fetcher.crawl_identity the link was fetched under, if one was set
+	// (only populated by ListLinkHistorical)
+	CrawlIdentity string
+
+	// The page's <title> and meta description, if it had them
+	Title       string
+	Description string
+
 	// FNV hash of the contents
 	FnvFingerprint int64
 
 	// FNV hash of the text extracted from the page
 	FnvTextFingerprint int64
 
+	// SHA-256 digest of the contents and SimHash of the text extracted from
+	// the page (if enabled in fetcher.fingerprints; only populated by
+	// ListLinkHistorical, and the SHA-256 digest by FindLinksByFingerprint and
+	// FindLinksBySHA256 too)
+	SHA256Fingerprint  []byte
+	SimHashFingerprint int64
+
 	// Body of request (if configured to be stored)
 	Body string
 
+	// Timing breakdown of the fetch (if configured to be stored; only
+	// populated by ListLinkHistorical)
+	Timing walker.FetchTiming
+
 	// Header of request (if configured to be stored)
 	Headers http.Header
+
+	// Headers sent with the request (if configured to be stored; only
+	// populated by ListLinkHistorical)
+	RequestHeaders http.Header
+
+	// Where the row was read from in the links table, for page tokens
+	position linkPosition
 }
 
 // DQ is a domain query struct used for getting domains from cassandra.
@@ -124,6 +384,10 @@ type DQ struct {
 	// Set to true to get only dispatched domains
 	// default: get all domains
 	Working bool
+
+	// Set to get only domains carrying this tag
+	// default: get all domains
+	Tag string
 }
 
 // DomainInfo defines a row from the domain_info table
@@ -137,6 +401,56 @@ type DomainInfo struct {
 	// Why did this domain get excluded, or empty if not excluded
 	ExcludeReason string
 
+	// Is crawling of this domain paused?
+	Paused bool
+
+	// The tags of this domain (see ModelDatastore.InsertLinksWithTags)
+	Tags []string
+
+	// Per-domain link sampling settings, overriding cassandra.sample_threshold
+	// and cassandra.sample_percent; zero means use the configured value
+	SampleThreshold int
+	SamplePercent   float32
+
+	// Per-domain bandwidth budget, overriding dispatcher.domain_byte_budget;
+	// zero means use the configured value, negative means no budget
+	ByteBudget int64
+
+	// When this domain may be dispatched (see ParseCrawlWindow), in the IANA
+	// time zone CrawlTimezone; empty means any time
+	CrawlWindow   string
+	CrawlTimezone string
+
+	// The user agent to crawl this domain as and its crawl delay, overriding
+	// the fetcher configuration (see walker.HostSettings); zero means use the
+	// configured value
+	UserAgent  string
+	CrawlDelay time.Duration
+
+	// The domain's favicon as last fetched (see walker.DomainAssets); empty if
+	// it has never been fetched. Only populated by FindDomain.
+	FaviconURL         string
+	FaviconTime        time.Time
+	FaviconStatus      int
+	FaviconMimeType    string
+	FaviconFingerprint int64
+
+	// The fnv fingerprint of the domain's robots.txt (0 if it has none), since
+	// when it has had it, and whether it has ever changed; RobotsTime is zero
+	// if it has never been fetched. Only populated by FindDomain.
+	RobotsFingerprint int64
+	RobotsTime        time.Time
+	RobotsChanged     bool
+
+	// The exclude patterns the dispatcher suggested when it last flagged this
+	// domain as a crawler trap, when, and whether they are applied (only
+	// those in TrapAccepted are); empty if it never was. Only populated by
+	// FindDomain.
+	TrapPatterns []string
+	TrapAccepted []string
+	TrapTime     time.Time
+	TrapExcluded bool
+
 	// When did this domain last get queued to be crawled. Or TimeQueed.IsZero() if not crawled
 	ClaimTime time.Time
 
@@ -156,6 +470,315 @@ type DomainInfo struct {
 	Priority int
 }
 
+// CrawlOverview holds aggregate numbers for the whole crawl, as returned by
+// ModelDatastore.CrawlOverview
+type CrawlOverview struct {
+	// Number of domains in the crawl
+	NumberDomains int
+
+	// Number of domains with a segment ready to be crawled
+	NumberDomainsDispatched int
+
+	// Sum of the per-domain link counts (see DomainInfo; these are updated by
+	// the dispatcher, so can be stale)
+	NumberLinksTotal     int
+	NumberLinksUncrawled int
+	NumberLinksQueued    int
+
+	// Number of fetches stored over the last hour
+	FetchesLastHour int64
+
+	// Number of fetch managers that have checked in recently
+	NumberActiveFetchers int
+
+	// Domains with the highest fraction of failed fetches, worst first
+	TopErrorDomains []*DomainErrorRate
+
+	// Progress of the dispatcher's current (or last) pass over domain_info,
+	// as last saved by the dispatcher; nil if it has not saved any
+	Scan *ScanProgress
+}
+
+// TagReport holds aggregate numbers for the domains carrying a tag, as
+// returned by ModelDatastore.TagReports
+type TagReport struct {
+	Tag string
+
+	// Number of domains carrying the tag, and how many of those are
+	// dispatched or excluded
+	NumberDomains           int
+	NumberDomainsDispatched int
+	NumberDomainsExcluded   int
+
+	// Sum of the link counts of those domains (see CrawlOverview)
+	NumberLinksTotal     int
+	NumberLinksUncrawled int
+	NumberLinksQueued    int
+}
+
+// StrandedClaim records a domain released by the dispatcher because the
+// fetcher that claimed it was no longer active, as returned by
+// ModelDatastore.ListStrandedClaims
+type StrandedClaim struct {
+	Domain string
+
+	// When the dispatcher released the domain
+	Time time.Time
+
+	// The token of the fetcher that had claimed it
+	ClaimToken gocql.UUID
+
+	// How long the domain had been claimed (zero if its claim time was not
+	// known)
+	ClaimAge time.Duration
+
+	// Number of links in the segment thrown back to be dispatched again
+	Links int
+}
+
+// Referrer is a page a link was parsed from, as returned by
+// ModelDatastore.ListReferrers
+type Referrer struct {
+	URL *walker.URL
+
+	// When a crawl of the page last found the link
+	LastSeen time.Time
+}
+
+// FrontierEstimate is an estimate of when a domain's backlog of links will be
+// crawled, as returned by ModelDatastore.FrontierEstimate
+type FrontierEstimate struct {
+	Domain string
+
+	// Links in the current segment and links not yet crawled (see DomainInfo;
+	// these are updated by the dispatcher, so can be stale)
+	Queued    int
+	Uncrawled int
+
+	// Number of fetches stored for this domain over the last hour
+	RecentFetches int64
+
+	// Fetches per second the estimate is based on. If RateFromCrawlDelay is
+	// true the domain had no recent fetches, and this is the fastest rate the
+	// default crawl delay allows.
+	Rate               float64
+	RateFromCrawlDelay bool
+
+	// Estimated time to crawl the current segment and all uncrawled links,
+	// and when the uncrawled links should be done. These are zero if Rate is
+	// zero.
+	QueuedDrain    time.Duration
+	UncrawledDrain time.Duration
+	DrainedAt      time.Time
+}
+
+// BandwidthUsage describes the bytes fetched for a domain against its
+// bandwidth budget, as returned by ModelDatastore.BandwidthUsage
+type BandwidthUsage struct {
+	Domain string
+
+	// Response body bytes fetched for this domain, ever and within Window
+	TotalBytes  int64
+	WindowBytes int64
+
+	// The budget in effect for this domain (0 if it has none) and the window
+	// it applies to
+	Budget int64
+	Window time.Duration
+}
+
+// MimeTypeCounts counts the successful fetches of a domain by the MIME type
+// of their content, as returned by ModelDatastore.MimeTypeCounts. 304 Not
+// Modified responses are not counted.
+type MimeTypeCounts struct {
+	Domain string
+
+	// text/html and application/xhtml+xml
+	HTML int64
+	// application/pdf
+	PDF int64
+	// image/*
+	Image int64
+	// Everything else with a Content-Type
+	Other int64
+}
+
+// Total returns the number of fetches counted
+func (c *MimeTypeCounts) Total() int64 {
+	return c.HTML + c.PDF + c.Image + c.Other
+}
+
+// DomainStats holds statistics about the crawl of a domain, as returned by
+// ModelDatastore.DomainStats. The link figures are computed from the latest
+// crawl of each link.
+type DomainStats struct {
+	Domain string
+
+	// Number of links in the domain, and how many were never crawled
+	Links          int
+	UncrawledLinks int
+
+	// Number of crawled links by the status code of their latest crawl;
+	// crawls that got no response are counted under 0
+	StatusCounts map[int]int
+
+	// Number of fetches made, how many of them failed, and the response body
+	// bytes they read
+	Fetches     int64
+	FetchErrors int64
+	Bytes       int64
+
+	// Average duration of the latest crawls that stored their timing (see
+	// cassandra.store_fetch_timing), and how many did; 0 if none did. Fetches
+	// that failed before a connection was made have no timing.
+	AverageLatency time.Duration
+	TimedFetches   int
+
+	// The latest dispatches of the domain, newest first
+	Dispatches []*DomainDispatch
+
+	// The most common errors of the latest crawls, most common first
+	TopErrors []*ErrorCount
+}
+
+// DomainDispatch is a segment the dispatcher generated for a domain
+type DomainDispatch struct {
+	Time  time.Time
+	Links int
+}
+
+// ErrorCount is a fetch error and how many links it happened to
+type ErrorCount struct {
+	Error string
+	Count int
+}
+
+// Remaining returns how many bytes can still be fetched within the window, or
+// -1 if there is no budget
+func (b *BandwidthUsage) Remaining() int64 {
+	if b.Budget <= 0 {
+		return -1
+	}
+	if b.WindowBytes >= b.Budget {
+		return 0
+	}
+	return b.Budget - b.WindowBytes
+}
+
+// DomainConfig holds the settings a domain can override, as returned by
+// ModelDatastore.GetDomainConfig. Zero values mean the configured setting
+// applies.
+type DomainConfig struct {
+	// Link sampling, overriding cassandra.sample_threshold and
+	// cassandra.sample_percent
+	SampleThreshold int
+	SamplePercent   float32
+
+	// Bandwidth budget, overriding dispatcher.domain_byte_budget; negative
+	// means no budget
+	ByteBudget int64
+
+	// When the domain may be dispatched (see ParseCrawlWindow), in the IANA
+	// time zone CrawlTimezone; empty means any time
+	CrawlWindow   string
+	CrawlTimezone string
+
+	// The user agent to crawl the domain as, overriding fetcher.user_agent
+	// and fetcher.user_agents
+	UserAgent string
+
+	// Crawl delay, overriding fetcher.default_crawl_delay. A longer
+	// Crawl-delay in the domain's robots.txt still applies.
+	CrawlDelay time.Duration
+}
+
+// Validate returns an error describing what is wrong with c, or nil if it
+// can be stored
+func (c *DomainConfig) Validate() error {
+	if c.SampleThreshold < 0 {
+		return fmt.Errorf("Sample threshold must be >= 0, not %d", c.SampleThreshold)
+	}
+	if c.SamplePercent < 0 || c.SamplePercent > 100 {
+		return fmt.Errorf("Sample percent must be between 0 and 100, not %v", c.SamplePercent)
+	}
+	if _, err := ParseCrawlWindow(c.CrawlWindow, c.CrawlTimezone); err != nil {
+		return err
+	}
+	if c.CrawlDelay < 0 {
+		return fmt.Errorf("Crawl delay must be >= 0, not %v", c.CrawlDelay)
+	}
+	if strings.IndexFunc(c.UserAgent, unicode.IsControl) >= 0 {
+		return fmt.Errorf("User agent %q must not contain control characters", c.UserAgent)
+	}
+	return nil
+}
+
+// LinkDiff compares two crawls of a link, as returned by
+// ModelDatastore.DiffLink
+type LinkDiff struct {
+	URL *walker.URL
+
+	// Crawl times of the two crawls compared
+	Before time.Time
+	After  time.Time
+
+	// Status of each crawl
+	StatusBefore int
+	StatusAfter  int
+
+	// Whether the body, and the text parsed out of it, changed (according
+	// to their fnv fingerprints)
+	Changed     bool
+	TextChanged bool
+
+	// True if both crawls had their body stored (see
+	// cassandra.store_response_body). The fields below are only filled in if
+	// so.
+	HasBodies bool
+
+	// Size of each body in bytes
+	SizeBefore int
+	SizeAfter  int
+
+	// Counts of the lines of text added, removed and kept between the two
+	// bodies. Lines are compared regardless of order, so moved lines count as
+	// unchanged.
+	LinesAdded     int
+	LinesRemoved   int
+	LinesUnchanged int
+}
+
+// SizeDelta returns how many bytes larger the later body is (negative if it
+// shrank)
+func (d *LinkDiff) SizeDelta() int {
+	return d.SizeAfter - d.SizeBefore
+}
+
+// PendingDomain is a domain found in parsed links but not added to the crawl,
+// as returned by ModelDatastore.ListPendingDomains
+type PendingDomain struct {
+	Domain string
+
+	// Number of parsed links that referenced this domain
+	References int64
+}
+
+// DomainErrorRate counts fetches and failed fetches for a domain. A fetch is
+// considered failed if it had a FetchError or a status of 400 or above.
+type DomainErrorRate struct {
+	Domain  string
+	Fetches int64
+	Errors  int64
+}
+
+// Rate returns the fraction of fetches that failed
+func (d *DomainErrorRate) Rate() float64 {
+	if d.Fetches == 0 {
+		return 0
+	}
+	return float64(d.Errors) / float64(d.Fetches)
+}
+
 // DomainInfoUpdateConfig is used to configure the method Datastore.UpdateDomain
 type DomainInfoUpdateConfig struct {
 
@@ -166,4 +789,41 @@ type DomainInfoUpdateConfig struct {
 	// Setting Priority to true indicates that the Priority field of the
 	// DomainInfo passed to UpdateDomain should be persisted to the database.
 	Priority bool
+
+	// Setting Sampling to true indicates that the SampleThreshold and
+	// SamplePercent fields of the DomainInfo passed to UpdateDomain should be
+	// persisted to the database.
+	Sampling bool
+
+	// Setting ByteBudget to true indicates that the ByteBudget field of the
+	// DomainInfo passed to UpdateDomain should be persisted to the database.
+	ByteBudget bool
+
+	// Setting CrawlWindow to true indicates that the CrawlWindow and
+	// CrawlTimezone fields of the DomainInfo passed to UpdateDomain should be
+	// persisted to the database.
+	CrawlWindow bool
+
+	// Setting Fetching to true indicates that the UserAgent and CrawlDelay
+	// fields of the DomainInfo passed to UpdateDomain should be persisted to
+	// the database.
+	Fetching bool
+
+	// Setting IfUnchanged to true makes UpdateDomains only update a domain if
+	// the fields being set still have the values it listed, so concurrent
+	// changes to a domain are not overwritten. UpdateDomain ignores it.
+	IfUnchanged bool
+}
+
+// DomainsUpdate describes what an UpdateDomains call did
+type DomainsUpdate struct {
+	// Number of domains matching the query
+	Matched int
+
+	// Number of those that were updated
+	Updated int
+
+	// The domains not updated because they changed after being listed (see
+	// DomainInfoUpdateConfig.IfUnchanged)
+	Conflicts []string
 }
@@ -1,6 +1,10 @@
 package cassandra
 
-import "github.com/iParadigms/walker"
+import (
+	"time"
+
+	"github.com/iParadigms/walker"
+)
 
 // MockModelDatastore implements walker/cassandra's ModelDatastore interface
 // for testing.
@@ -13,18 +17,53 @@ func (ds *MockModelDatastore) FindLink(u *walker.URL, collectContent bool) (*Lin
 	return args.Get(0).(*LinkInfo), args.Error(1)
 }
 
+func (ds *MockModelDatastore) FindLinkAsOf(u *walker.URL, t time.Time) (*LinkInfo, error) {
+	args := ds.Mock.Called(u, t)
+	return args.Get(0).(*LinkInfo), args.Error(1)
+}
+
+func (ds *MockModelDatastore) ListReferrers(u *walker.URL, limit int) ([]*Referrer, error) {
+	args := ds.Mock.Called(u, limit)
+	return args.Get(0).([]*Referrer), args.Error(1)
+}
+
+func (ds *MockModelDatastore) ListPolitenessAudits(dom string, limit int) ([]*walker.PolitenessAudit, error) {
+	args := ds.Mock.Called(dom, limit)
+	return args.Get(0).([]*walker.PolitenessAudit), args.Error(1)
+}
+
+func (ds *MockModelDatastore) PreviewSegment(domain string) (*SegmentPreview, error) {
+	args := ds.Mock.Called(domain)
+	return args.Get(0).(*SegmentPreview), args.Error(1)
+}
+
 func (ds *MockModelDatastore) ListLinkHistorical(u *walker.URL) ([]*LinkInfo, error) {
 	args := ds.Mock.Called(u)
 	return args.Get(0).([]*LinkInfo), args.Error(1)
 }
 
+func (ds *MockModelDatastore) DiffLink(u *walker.URL, t1, t2 time.Time) (*LinkDiff, error) {
+	args := ds.Mock.Called(u, t1, t2)
+	return args.Get(0).(*LinkDiff), args.Error(1)
+}
+
 func (ds *MockModelDatastore) InsertLink(link string, excludeDomainReason string) error {
 	args := ds.Mock.Called(link, excludeDomainReason)
 	return args.Error(0)
 }
 
-func (ds *MockModelDatastore) ListLinks(domain string, query LQ) ([]*LinkInfo, error) {
+func (ds *MockModelDatastore) ListLinks(domain string, query LQ) (*LinkPage, error) {
 	args := ds.Mock.Called(domain, query)
+	return args.Get(0).(*LinkPage), args.Error(1)
+}
+
+func (ds *MockModelDatastore) FindLinksByFingerprint(fnv int64) ([]*LinkInfo, error) {
+	args := ds.Mock.Called(fnv)
+	return args.Get(0).([]*LinkInfo), args.Error(1)
+}
+
+func (ds *MockModelDatastore) FindLinksBySHA256(sum []byte) ([]*LinkInfo, error) {
+	args := ds.Mock.Called(sum)
 	return args.Get(0).([]*LinkInfo), args.Error(1)
 }
 
@@ -33,6 +72,16 @@ func (ds *MockModelDatastore) InsertLinks(links []string, excludeDomainReason st
 	return args.Get(0).([]error)
 }
 
+func (ds *MockModelDatastore) InsertLinksWithTags(links []string, excludeDomainReason string, tags []string) []error {
+	args := ds.Mock.Called(links, excludeDomainReason, tags)
+	return args.Get(0).([]error)
+}
+
+func (ds *MockModelDatastore) TagReports() ([]*TagReport, error) {
+	args := ds.Mock.Called()
+	return args.Get(0).([]*TagReport), args.Error(1)
+}
+
 func (ds *MockModelDatastore) FindDomain(domain string) (*DomainInfo, error) {
 	args := ds.Mock.Called(domain)
 	return args.Get(0).(*DomainInfo), args.Error(1)
@@ -43,7 +92,92 @@ func (ds *MockModelDatastore) ListDomains(query DQ) ([]*DomainInfo, error) {
 	return args.Get(0).([]*DomainInfo), args.Error(1)
 }
 
+func (ds *MockModelDatastore) ExcludeDomainsMatching(pattern string, reason string) (int, error) {
+	args := ds.Mock.Called(pattern, reason)
+	return args.Int(0), args.Error(1)
+}
+
+func (ds *MockModelDatastore) PauseDomain(domain string) error {
+	args := ds.Mock.Called(domain)
+	return args.Error(0)
+}
+
+func (ds *MockModelDatastore) ResumeDomain(domain string) error {
+	args := ds.Mock.Called(domain)
+	return args.Error(0)
+}
+
+func (ds *MockModelDatastore) SetTrapExcluded(domain string, excluded bool) error {
+	args := ds.Mock.Called(domain, excluded)
+	return args.Error(0)
+}
+
+func (ds *MockModelDatastore) MarkGetNow(u *walker.URL) error {
+	args := ds.Mock.Called(u)
+	return args.Error(0)
+}
+
+func (ds *MockModelDatastore) FrontierEstimate(domain string) (*FrontierEstimate, error) {
+	args := ds.Mock.Called(domain)
+	return args.Get(0).(*FrontierEstimate), args.Error(1)
+}
+
+func (ds *MockModelDatastore) BandwidthUsage(domain string) (*BandwidthUsage, error) {
+	args := ds.Mock.Called(domain)
+	return args.Get(0).(*BandwidthUsage), args.Error(1)
+}
+
+func (ds *MockModelDatastore) DomainStats(domain string) (*DomainStats, error) {
+	args := ds.Mock.Called(domain)
+	return args.Get(0).(*DomainStats), args.Error(1)
+}
+
+func (ds *MockModelDatastore) MimeTypeCounts(domain string) (*MimeTypeCounts, error) {
+	args := ds.Mock.Called(domain)
+	return args.Get(0).(*MimeTypeCounts), args.Error(1)
+}
+
+func (ds *MockModelDatastore) CrawlOverview() (*CrawlOverview, error) {
+	args := ds.Mock.Called()
+	return args.Get(0).(*CrawlOverview), args.Error(1)
+}
+
+func (ds *MockModelDatastore) ListStrandedClaims(since time.Time) ([]*StrandedClaim, error) {
+	args := ds.Mock.Called(since)
+	return args.Get(0).([]*StrandedClaim), args.Error(1)
+}
+
+func (ds *MockModelDatastore) ListPendingDomains(limit int) ([]*PendingDomain, error) {
+	args := ds.Mock.Called(limit)
+	return args.Get(0).([]*PendingDomain), args.Error(1)
+}
+
+func (ds *MockModelDatastore) ApprovePendingDomain(domain string) error {
+	args := ds.Mock.Called(domain)
+	return args.Error(0)
+}
+
+func (ds *MockModelDatastore) RejectPendingDomain(domain string, reason string) error {
+	args := ds.Mock.Called(domain, reason)
+	return args.Error(0)
+}
+
+func (ds *MockModelDatastore) GetDomainConfig(domain string) (*DomainConfig, error) {
+	args := ds.Mock.Called(domain)
+	return args.Get(0).(*DomainConfig), args.Error(1)
+}
+
+func (ds *MockModelDatastore) SetDomainConfig(domain string, cfg *DomainConfig) error {
+	args := ds.Mock.Called(domain, cfg)
+	return args.Error(0)
+}
+
 func (ds *MockModelDatastore) UpdateDomain(domain string, info *DomainInfo, cfg DomainInfoUpdateConfig) error {
 	args := ds.Mock.Called(domain, info, cfg)
 	return args.Error(0)
 }
+
+func (ds *MockModelDatastore) UpdateDomains(query DQ, info *DomainInfo, cfg DomainInfoUpdateConfig) (*DomainsUpdate, error) {
+	args := ds.Mock.Called(query, info, cfg)
+	return args.Get(0).(*DomainsUpdate), args.Error(1)
+}
@@ -12,6 +12,7 @@ package cassandra
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -708,11 +709,21 @@ func TestListLinks(t *testing.T) {
 		if test.omittest {
 			continue
 		}
-		linfos, err := store.ListLinks(test.domain, LQ{Seed: test.seedURL, Limit: test.limit})
+		query := LQ{Limit: test.limit}
+		if test.seedURL != nil {
+			var err error
+			query.PageToken, err = LinkPageToken(test.seedURL)
+			if err != nil {
+				t.Errorf("LinkPageToken for tag %s direct error %v", test.tag, err)
+				continue
+			}
+		}
+		page, err := store.ListLinks(test.domain, query)
 		if err != nil {
 			t.Errorf("ListLinks for tag %s direct error %v", test.tag, err)
 			continue
 		}
+		linfos := page.Links
 		if len(linfos) != len(test.expected) {
 			t.Errorf("ListLinks for tag %s length mismatch got %d, expected %d", test.tag, len(linfos), len(test.expected))
 			continue
@@ -890,12 +901,13 @@ func TestInsertLinks(t *testing.T) {
 
 		allDomains := []string{}
 		for domain, exp := range expect {
-			linfos, err := store.ListLinks(domain, LQ{Limit: LIM})
+			page, err := store.ListLinks(domain, LQ{Limit: LIM})
 			if err != nil {
 				t.Errorf("InsertLinks:ListLinks for tag %s direct error %v", test.tag, err)
+				continue
 			}
 			gotHash := map[string]bool{}
-			for _, linfo := range linfos {
+			for _, linfo := range page.Links {
 				gotHash[linfo.URL.String()] = true
 			}
 
@@ -987,11 +999,21 @@ func TestCloseToLimitBug(t *testing.T) {
 		if test.omittest {
 			continue
 		}
-		linfos, err := store.ListLinks(test.domain, LQ{Seed: test.seedURL, Limit: test.limit})
+		query := LQ{Limit: test.limit}
+		if test.seedURL != nil {
+			var err error
+			query.PageToken, err = LinkPageToken(test.seedURL)
+			if err != nil {
+				t.Errorf("LinkPageToken for tag %s direct error %v", test.tag, err)
+				continue
+			}
+		}
+		page, err := store.ListLinks(test.domain, query)
 		if err != nil {
 			t.Errorf("ListLinks for tag %s direct error %v", test.tag, err)
 			continue
 		}
+		linfos := page.Links
 		if len(linfos) != len(test.expected) {
 			t.Errorf("ListLinks for tag %s length mismatch got %d, expected %d", test.tag, len(linfos), len(test.expected))
 			continue
@@ -1118,7 +1140,7 @@ func TestFilterRegex(t *testing.T) {
 		if test.omittest {
 			continue
 		}
-		linfos, err := store.ListLinks(test.domain, LQ{
+		page, err := store.ListLinks(test.domain, LQ{
 			Limit:       test.limit,
 			FilterRegex: test.filterRegex,
 		})
@@ -1126,6 +1148,7 @@ func TestFilterRegex(t *testing.T) {
 			t.Errorf("ListLinks for tag %s direct error %v", test.tag, err)
 			continue
 		}
+		linfos := page.Links
 
 		if len(linfos) != len(test.expected) {
 			t.Errorf("ListLinks for tag %s length mismatch got %d, expected %d", test.tag, len(linfos), len(test.expected))
@@ -1143,3 +1166,293 @@ func TestFilterRegex(t *testing.T) {
 	store.Close()
 
 }
+
+func TestListLinksFilters(t *testing.T) {
+	store := getModelTestDatastore(t)
+	defer store.Close()
+
+	yes, no := true, false
+	cutoff := time.Now().Add(-36 * time.Hour)
+	tests := []struct {
+		tag      string
+		domain   string
+		query    LQ
+		expected []string
+	}{
+		{"Status", "test.com", LQ{StatusFilter: 404},
+			[]string{"http://test.com/page3.html"}},
+		{"HasError", "test.com", LQ{HasError: &yes},
+			[]string{"http://test.com/page4.html"}},
+		{"RobotsExcluded", "test.com", LQ{RobotsExcluded: &yes},
+			[]string{"http://test.com/page5.html"}},
+		{"Combined", "test.com", LQ{StatusFilter: 200, HasError: &no, RobotsExcluded: &no},
+			[]string{
+				"http://test.com/page1.html",
+				"http://test.com/page2.html",
+				"http://sub.test.com/page6.html",
+				"https://sub.test.com/page7.html",
+				"https://sub.test.com/page8.html",
+			}},
+		{"CombinedWithRegex", "test.com", LQ{HasError: &no, RobotsExcluded: &no, FilterRegex: `^https`},
+			[]string{
+				"https://sub.test.com/page7.html",
+				"https://sub.test.com/page8.html",
+			}},
+
+		// Only the most recent crawl of a link is considered: page1 was
+		// crawled before the cutoff too, but its last crawl was after it
+		{"CrawledAfter", "baz.com", LQ{CrawledAfter: cutoff},
+			[]string{"http://sub.baz.com/page1.html"}},
+		{"CrawledBefore", "baz.com", LQ{CrawledBefore: cutoff},
+			nil},
+	}
+
+	for _, test := range tests {
+		test.query.Limit = LIM
+		page, err := store.ListLinks(test.domain, test.query)
+		if err != nil {
+			t.Errorf("ListLinks for tag %s direct error %v", test.tag, err)
+			continue
+		}
+		linfos := page.Links
+
+		got := map[string]bool{}
+		for _, linfo := range linfos {
+			got[linfo.URL.String()] = true
+		}
+		if len(linfos) != len(test.expected) {
+			t.Errorf("ListLinks for tag %s length mismatch got %d, expected %d", test.tag, len(linfos), len(test.expected))
+		}
+		for _, e := range test.expected {
+			if !got[e] {
+				t.Errorf("ListLinks for tag %s expected to find %v", test.tag, e)
+			}
+		}
+	}
+
+	// The limit counts filtered links only
+	page, err := store.ListLinks("test.com", LQ{Limit: 2, HasError: &no, RobotsExcluded: &no})
+	if err != nil {
+		t.Fatalf("ListLinks direct error %v", err)
+	}
+	linfos := page.Links
+	if len(linfos) != 2 {
+		t.Errorf("ListLinks with limit length mismatch got %d, expected 2", len(linfos))
+	}
+	for _, linfo := range linfos {
+		if linfo.Error != "" || linfo.RobotsExcluded {
+			t.Errorf("ListLinks with limit returned filtered link %v", linfo.URL)
+		}
+	}
+}
+
+func TestListLinksPushdown(t *testing.T) {
+	store := getModelTestDatastore(t)
+	defer store.Close()
+	db := GetTestDB()
+
+	origBatch := linkCandidateBatch
+	defer func() {
+		linkCandidateBatch = origBatch
+	}()
+	linkCandidateBatch = 1
+
+	// Only the most recent crawl of a link counts, though an older one meets
+	// the filter Cassandra finds candidates with
+	old, recent := time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour)
+	insertLink := `INSERT INTO links (dom, subdom, path, proto, time, stat, err, robot_ex) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	rows := []struct {
+		path   string
+		crawl  time.Time
+		status int
+		robots bool
+	}{
+		{"/a.html", old, 404, false},
+		{"/a.html", recent, 200, false},
+		{"/b.html", recent, 404, false},
+		{"/c.html", recent, 200, false},
+		{"/d.html", old, 200, false},
+		{"/d.html", recent, 404, false},
+		{"/e.html", old, 200, true},
+		{"/e.html", recent, 200, false},
+	}
+	for _, r := range rows {
+		err := db.Query(insertLink, "push.com", "", r.path, "http", r.crawl, r.status, "", r.robots).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+
+	listAll := func(query LQ) []string {
+		var links []string
+		for {
+			page, err := store.ListLinks("push.com", query)
+			if err != nil {
+				t.Fatalf("ListLinks failed: %v", err)
+			}
+			for _, linfo := range page.Links {
+				links = append(links, linfo.URL.String())
+			}
+			if page.NextPageToken == "" {
+				return links
+			}
+			query.PageToken = page.NextPageToken
+		}
+	}
+
+	yes := true
+	tests := []struct {
+		tag      string
+		query    LQ
+		expected []string
+	}{
+		{"Status", LQ{Limit: LIM, StatusFilter: 404},
+			[]string{"http://push.com/b.html", "http://push.com/d.html"}},
+		{"StatusPaged", LQ{Limit: 1, StatusFilter: 404},
+			[]string{"http://push.com/b.html", "http://push.com/d.html"}},
+		{"StatusSorted", LQ{Limit: LIM, StatusFilter: 404, Sort: SortByStatus},
+			[]string{"http://push.com/b.html", "http://push.com/d.html"}},
+		{"RobotsExcluded", LQ{Limit: LIM, RobotsExcluded: &yes}, nil},
+	}
+	for _, test := range tests {
+		got := listAll(test.query)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("ListLinks for tag %s: expected %v, got %v", test.tag, test.expected, got)
+		}
+	}
+
+	page, err := store.ListLinks("push.com", LQ{Limit: 1, StatusFilter: 404})
+	if err != nil {
+		t.Fatalf("ListLinks failed: %v", err)
+	}
+	// 3 candidates (a, b and d), of which 1 of the 2 read matched
+	if page.EstimatedTotal < 1 || page.EstimatedTotal > 3 {
+		t.Errorf("Expected an estimate of 1 to 3 links, got %d", page.EstimatedTotal)
+	}
+}
+
+func TestListLinksPageTokens(t *testing.T) {
+	store := getModelTestDatastore(t)
+	defer store.Close()
+
+	page, err := store.ListLinks("test.com", LQ{Limit: LIM})
+	if err != nil {
+		t.Fatalf("ListLinks direct error %v", err)
+	}
+	if page.NextPageToken != "" {
+		t.Errorf("Expected no next page when all links fit, got token %q", page.NextPageToken)
+	}
+	if page.EstimatedTotal != len(testComLinkOrder) {
+		t.Errorf("EstimatedTotal mismatch got %d, expected %d", page.EstimatedTotal, len(testComLinkOrder))
+	}
+
+	// Following the tokens two links at a time lists every link once, in order
+	var got []string
+	query := LQ{Limit: 2}
+	for pages := 0; pages <= len(testComLinkOrder); pages++ {
+		page, err := store.ListLinks("test.com", query)
+		if err != nil {
+			t.Fatalf("ListLinks for page %d direct error %v", pages, err)
+		}
+		for _, linfo := range page.Links {
+			got = append(got, linfo.URL.String())
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		query.PageToken = page.NextPageToken
+	}
+	if len(got) != len(testComLinkOrder) {
+		t.Fatalf("Paging length mismatch got %d, expected %d: %v", len(got), len(testComLinkOrder), got)
+	}
+	for i, u := range got {
+		if u != testComLinkOrder[i].URL.String() {
+			t.Errorf("Paging link %d mismatch got %v, expected %v", i, u, testComLinkOrder[i].URL)
+		}
+	}
+
+	for _, token := range []string{"not a token", query.PageToken} {
+		if _, err := store.ListLinks("foo.com", LQ{Limit: 2, PageToken: token}); err == nil {
+			t.Errorf("Expected ListLinks of foo.com to fail with page token %q", token)
+		}
+	}
+}
+
+func TestListLinksSorted(t *testing.T) {
+	store := getModelTestDatastore(t)
+	defer store.Close()
+
+	// Read the links sorted by status a few at a time
+	var linfos []*LinkInfo
+	query := LQ{Limit: 3, Sort: SortByStatus}
+	for pages := 0; ; pages++ {
+		if pages > len(testComLinkOrder) {
+			t.Fatalf("ListLinks sorted by status never ran out of pages")
+		}
+		page, err := store.ListLinks("test.com", query)
+		if err != nil {
+			t.Fatalf("ListLinks sorted by status direct error %v", err)
+		}
+		if page.EstimatedTotal != len(testComLinkOrder) {
+			t.Errorf("ListLinks sorted by status EstimatedTotal got %d, expected %d",
+				page.EstimatedTotal, len(testComLinkOrder))
+		}
+		linfos = append(linfos, page.Links...)
+		if page.NextPageToken == "" {
+			break
+		}
+		query.PageToken = page.NextPageToken
+	}
+	if len(linfos) != len(testComLinkOrder) {
+		t.Fatalf("ListLinks sorted by status length mismatch got %d, expected %d", len(linfos), len(testComLinkOrder))
+	}
+	for i, linfo := range linfos[:len(linfos)-1] {
+		if linfo.Status != 200 {
+			t.Errorf("ListLinks sorted by status got status %d at %d, expected 200", linfo.Status, i)
+		}
+	}
+	if last := linfos[len(linfos)-1]; last.URL.String() != "http://test.com/page3.html" {
+		t.Errorf("ListLinks sorted by status expected the 404 last, got %v", last.URL)
+	}
+
+	// Links crawled at the same time keep their path order
+	page, err := store.ListLinks("test.com", LQ{Limit: LIM, Sort: SortByCrawlTime})
+	if err != nil {
+		t.Fatalf("ListLinks sorted by crawl time direct error %v", err)
+	}
+	if len(page.Links) != len(testComLinkOrder) {
+		t.Fatalf("ListLinks sorted by crawl time length mismatch got %d, expected %d",
+			len(page.Links), len(testComLinkOrder))
+	}
+	for i, linfo := range page.Links {
+		if linfo.URL.String() != testComLinkOrder[i].URL.String() {
+			t.Errorf("ListLinks sorted by crawl time got %v at %d, expected %v", linfo.URL, i, testComLinkOrder[i].URL)
+		}
+	}
+
+	// Page tokens are only good for the sort they came from
+	if _, err := store.ListLinks("test.com", LQ{Limit: 3, PageToken: query.PageToken}); err == nil {
+		t.Errorf("Expected an error using a page token sorted by status to list links by path")
+	}
+	if _, err := store.ListLinks("test.com", LQ{Limit: 3, Sort: "size"}); err == nil {
+		t.Errorf("Expected an error for an unknown sort")
+	}
+	if page.Truncated {
+		t.Errorf("Expected all the links of test.com to be sorted")
+	}
+
+	// Only the first console.max_sorted_links links are sorted
+	origMax := walker.Config.Console.MaxSortedLinks
+	defer func() {
+		walker.Config.Console.MaxSortedLinks = origMax
+	}()
+	walker.Config.Console.MaxSortedLinks = 2
+	page, err = store.ListLinks("test.com", LQ{Limit: LIM, Sort: SortByCrawlTime})
+	if err != nil {
+		t.Fatalf("ListLinks sorted by crawl time direct error %v", err)
+	}
+	if !page.Truncated || len(page.Links) != 2 || page.EstimatedTotal != 2 {
+		t.Errorf("Expected 2 links and a truncated page, got %d links, total %d, truncated %v",
+			len(page.Links), page.EstimatedTotal, page.Truncated)
+	}
+}
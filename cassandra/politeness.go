@@ -0,0 +1,88 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/iParadigms/walker"
+)
+
+// StorePolitenessAudit is documented on the walker.PolitenessDatastore
+// interface. Nothing is stored unless cassandra.store_politeness_audit is on.
+func (ds *Datastore) StorePolitenessAudit(ctx context.Context, audit *walker.PolitenessAudit) {
+	if !walker.Config.Cassandra.StorePolitenessAudit {
+		return
+	}
+	var events []string
+	for _, e := range audit.Events {
+		events = append(events, fmt.Sprintf("%s %d %s", e.Time.UTC().Format(time.RFC3339), e.Status, e.URL))
+	}
+	err := ds.throttle.exec(ctx, ds.db.Query(`INSERT INTO politeness_audit (dom, start, crawler, dur, crawl_delay,
+							requests, stat_429, stat_503, events) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		audit.Host, audit.Start, ds.crawlerUUID, int64(audit.Duration/time.Millisecond),
+		int64(audit.CrawlDelay/time.Millisecond), audit.Requests, audit.TooManyRequests,
+		audit.ServiceUnavailable, events))
+	if err != nil {
+		log4go.Error("Failed to store politeness audit of %v: %v", audit.Host, err)
+	}
+}
+
+// ListPolitenessAudits is documented on the ModelDatastore interface.
+func (ds *Datastore) ListPolitenessAudits(dom string, limit int) ([]*walker.PolitenessAudit, error) {
+	itr := ds.read(`SELECT start, dur, crawl_delay, requests, stat_429, stat_503, events FROM politeness_audit
+					WHERE dom = ? LIMIT ?`, dom, limit).Iter()
+	var audits []*walker.PolitenessAudit
+	var start time.Time
+	var dur, delay int64
+	var requests, tooMany, unavailable int
+	var events []string
+	for itr.Scan(&start, &dur, &delay, &requests, &tooMany, &unavailable, &events) {
+		a := &walker.PolitenessAudit{
+			Host:               dom,
+			Start:              start,
+			Duration:           time.Duration(dur) * time.Millisecond,
+			CrawlDelay:         time.Duration(delay) * time.Millisecond,
+			Requests:           requests,
+			TooManyRequests:    tooMany,
+			ServiceUnavailable: unavailable,
+		}
+		for _, e := range events {
+			event, err := parsePolitenessEvent(e)
+			if err != nil {
+				log4go.Warn("Skipping politeness event %q of %v: %v", e, dom, err)
+				continue
+			}
+			a.Events = append(a.Events, event)
+		}
+		audits = append(audits, a)
+		events = nil
+	}
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("politeness_audit read failed: %v", err)
+	}
+	return audits, nil
+}
+
+// parsePolitenessEvent parses an entry of politeness_audit's events column
+func parsePolitenessEvent(e string) (walker.PolitenessEvent, error) {
+	var event walker.PolitenessEvent
+	fields := strings.SplitN(e, " ", 3)
+	if len(fields) != 3 {
+		return event, fmt.Errorf("expected time, status and url")
+	}
+	var err error
+	event.Time, err = time.Parse(time.RFC3339, fields[0])
+	if err != nil {
+		return event, err
+	}
+	event.Status, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return event, err
+	}
+	event.URL = fields[2]
+	return event, nil
+}
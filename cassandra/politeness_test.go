@@ -0,0 +1,76 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestPolitenessAudits(t *testing.T) {
+	orig := walker.Config.Cassandra.StorePolitenessAudit
+	defer func() { walker.Config.Cassandra.StorePolitenessAudit = orig }()
+	walker.Config.Cassandra.StorePolitenessAudit = true
+
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	events := []walker.PolitenessEvent{
+		{Time: start.Add(time.Minute).UTC(), URL: "http://test.com/busy page.html", Status: 429},
+		{Time: start.Add(2 * time.Minute).UTC(), URL: "http://test.com/down.html", Status: 503},
+	}
+	older := &walker.PolitenessAudit{
+		Host:       "test.com",
+		Start:      start,
+		Duration:   10 * time.Minute,
+		CrawlDelay: time.Second,
+		Requests:   40,
+	}
+	newer := &walker.PolitenessAudit{
+		Host:               "test.com",
+		Start:              start.Add(30 * time.Minute),
+		Duration:           5 * time.Minute,
+		CrawlDelay:         2 * time.Second,
+		Requests:           12,
+		TooManyRequests:    1,
+		ServiceUnavailable: 1,
+		Events:             events,
+	}
+	ds.StorePolitenessAudit(context.Background(), older)
+	ds.StorePolitenessAudit(context.Background(), newer)
+
+	audits, err := ds.ListPolitenessAudits("test.com", 10)
+	if err != nil {
+		t.Fatalf("ListPolitenessAudits failed: %v", err)
+	}
+	if len(audits) != 2 {
+		t.Fatalf("Expected 2 audits, got %d", len(audits))
+	}
+	for i, expected := range []*walker.PolitenessAudit{newer, older} {
+		got := audits[i]
+		if !got.Start.Equal(expected.Start) || got.Duration != expected.Duration ||
+			got.CrawlDelay != expected.CrawlDelay || got.Requests != expected.Requests ||
+			got.TooManyRequests != expected.TooManyRequests || got.ServiceUnavailable != expected.ServiceUnavailable {
+			t.Errorf("Audit %d: expected %+v, got %+v", i, expected, got)
+		}
+		if !reflect.DeepEqual(got.Events, expected.Events) {
+			t.Errorf("Audit %d: expected events %v, got %v", i, expected.Events, got.Events)
+		}
+	}
+
+	walker.Config.Cassandra.StorePolitenessAudit = false
+	ds.StorePolitenessAudit(context.Background(), &walker.PolitenessAudit{Host: "test.com", Start: time.Now()})
+	audits, err = ds.ListPolitenessAudits("test.com", 10)
+	if err != nil {
+		t.Fatalf("ListPolitenessAudits failed: %v", err)
+	}
+	if len(audits) != 2 {
+		t.Errorf("Expected nothing stored with store_politeness_audit off, got %d audits", len(audits))
+	}
+}
@@ -0,0 +1,171 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/iParadigms/walker"
+)
+
+// probeExcludePrefix starts the exclude_reason of domains excluded by a probe
+const probeExcludePrefix = "Probe: "
+
+// parkingHosts are the domains of parking services. A domain whose name
+// servers or root page are under one of these is considered parked.
+var parkingHosts = []string{
+	"above.com",
+	"afternic.com",
+	"bodis.com",
+	"dan.com",
+	"domainmarket.com",
+	"hugedomains.com",
+	"parkingcrew.net",
+	"parklogic.com",
+	"sedo.com",
+	"sedoparking.com",
+	"undeveloped.com",
+}
+
+// parkingHost returns the parking service host is under, or "" if it isn't
+// under one
+func parkingHost(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, p := range parkingHosts {
+		if host == p || strings.HasSuffix(host, "."+p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// DomainProber checks a domain before the dispatcher generates its first
+// segment (see dispatcher.probe_new_domains)
+type DomainProber interface {
+	// Probe returns why domain should be excluded from the crawl, or "" if
+	// it should be crawled. Probes that fail for reasons that may not last
+	// (timeouts, refused connections) should return "".
+	Probe(ctx context.Context, domain string) string
+}
+
+// HTTPProber is the DomainProber used by the dispatcher: it excludes domains
+// that don't resolve or that are parked
+type HTTPProber struct {
+	Timeout  time.Duration
+	Resolver *net.Resolver
+	Client   *http.Client
+}
+
+// NewHTTPProber creates an HTTPProber using dispatcher.probe_timeout
+func NewHTTPProber() *HTTPProber {
+	timeout, err := time.ParseDuration(walker.Config.Dispatcher.ProbeTimeout)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	return &HTTPProber{
+		Timeout:  timeout,
+		Resolver: net.DefaultResolver,
+		Client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Probe is documented on the DomainProber interface.
+func (p *HTTPProber) Probe(ctx context.Context, domain string) string {
+	host, ok := p.resolve(ctx, domain)
+	if !ok {
+		return probeExcludePrefix + "does not resolve"
+	}
+	if host == "" {
+		// Resolution failed in a way that may not last
+		return ""
+	}
+
+	nsCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+	nss, err := p.Resolver.LookupNS(nsCtx, domain)
+	cancel()
+	if err != nil {
+		log4go.Debug("Probe of %v failed to look up name servers: %v", domain, err)
+	}
+	for _, ns := range nss {
+		if parked := parkingHost(ns.Host); parked != "" {
+			return fmt.Sprintf("%sparked (name server %v)", probeExcludePrefix, ns.Host)
+		}
+	}
+
+	req, err := http.NewRequest("HEAD", "http://"+host+"/", nil)
+	if err != nil {
+		log4go.Error("Probe of %v failed to build request: %v", domain, err)
+		return ""
+	}
+	req.Header.Set("User-Agent", walker.Config.Fetcher.UserAgent)
+	res, err := p.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		log4go.Debug("Probe of %v failed to HEAD its root page: %v", domain, err)
+		return ""
+	}
+	res.Body.Close()
+	if parked := parkingHost(res.Request.URL.Hostname()); parked != "" {
+		return fmt.Sprintf("%sparked (redirects to %v)", probeExcludePrefix, res.Request.URL.Hostname())
+	}
+	return ""
+}
+
+// resolve returns the host to probe domain at: domain itself, or www.domain
+// if only that resolves. It returns false if neither exists, and "" and true
+// if resolution failed for another reason.
+func (p *HTTPProber) resolve(ctx context.Context, domain string) (string, bool) {
+	notFound := true
+	for _, host := range []string{domain, "www." + domain} {
+		lookupCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+		_, err := p.Resolver.LookupHost(lookupCtx, host)
+		cancel()
+		if err == nil {
+			return host, true
+		}
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+			log4go.Debug("Probe of %v failed to resolve %v: %v", domain, host, err)
+			notFound = false
+		}
+	}
+	return "", !notFound
+}
+
+// failsProbe probes the current domain if it has never been dispatched nor
+// probed, excluding it and returning true if the probe says to. The probe
+// time is recorded either way, so each domain is only probed once.
+func (sg *SegmentGenerator) failsProbe() bool {
+	if sg.prober == nil {
+		return false
+	}
+	var lastDispatch, probeTime time.Time
+	err := sg.DB.Query(`SELECT last_dispatch, probe_time FROM domain_info WHERE dom = ?`,
+		sg.domain).Scan(&lastDispatch, &probeTime)
+	if err != nil {
+		log4go.Error("Failed to read last_dispatch and probe_time for %q: %v", sg.domain, err)
+		return false
+	}
+	if !lastDispatch.IsZero() || !probeTime.IsZero() {
+		return false
+	}
+
+	reason := sg.prober.Probe(context.Background(), sg.domain)
+	if reason == "" {
+		err = sg.DB.Query(`UPDATE domain_info SET probe_time = ? WHERE dom = ?`, time.Now(), sg.domain).Exec()
+		if err != nil {
+			log4go.Error("Failed to record probe of %q: %v", sg.domain, err)
+		}
+		return false
+	}
+
+	log4go.Info("Excluding %v after probing it: %v", sg.domain, reason)
+	err = sg.DB.Query(`UPDATE domain_info SET excluded = true, exclude_reason = ?, probe_time = ? WHERE dom = ?`,
+		reason, time.Now(), sg.domain).Exec()
+	if err != nil {
+		log4go.Error("Failed to exclude %q after probing it: %v", sg.domain, err)
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/iParadigms/walker"
+)
+
+// newRedisPool creates a pool of connections to the server configured in
+// walker.Config.Redis. Connections are made as they are needed.
+func newRedisPool() (*redis.Pool, error) {
+	cfg := walker.Config.Redis
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	password := cfg.Password
+	if password != "" {
+		password, err = walker.ResolveSecret(password)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resolve redis.password: %v", err)
+		}
+	}
+	return &redis.Pool{
+		MaxIdle: cfg.MaxIdleConns,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			conn, err := redis.DialContext(ctx, "tcp", cfg.Address,
+				redis.DialConnectTimeout(timeout),
+				redis.DialReadTimeout(timeout),
+				redis.DialWriteTimeout(timeout),
+				redis.DialPassword(password),
+				redis.DialDatabase(cfg.Database))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to connect to redis at %v: %v", cfg.Address, err)
+			}
+			return conn, nil
+		},
+	}, nil
+}
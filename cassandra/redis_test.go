@@ -0,0 +1,217 @@
+package cassandra
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+// fakeRedis serves the handful of Redis commands redisSegments uses, keeping
+// lists in memory
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu    sync.Mutex
+	lists map[string][]string
+	conns int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	r := &fakeRedis{ln: ln, password: password, lists: map[string][]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r.mu.Lock()
+			r.conns++
+			r.mu.Unlock()
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := r.password == ""
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		if !authed && args[0] != "AUTH" {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		switch {
+		case args[0] == "AUTH":
+			if args[1] != r.password {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			authed = true
+			io.WriteString(conn, "+OK\r\n")
+		case args[0] == "MULTI":
+			inMulti = true
+			io.WriteString(conn, "+OK\r\n")
+		case args[0] == "EXEC":
+			fmt.Fprintf(conn, "*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				io.WriteString(conn, r.exec(cmd))
+			}
+			queued = nil
+			inMulti = false
+		case inMulti:
+			queued = append(queued, args)
+			io.WriteString(conn, "+QUEUED\r\n")
+		default:
+			io.WriteString(conn, r.exec(args))
+		}
+	}
+}
+
+func (r *fakeRedis) exec(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch args[0] {
+	case "DEL":
+		_, ok := r.lists[args[1]]
+		delete(r.lists, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "RPUSH":
+		r.lists[args[1]] = append(r.lists[args[1]], args[2:]...)
+		return fmt.Sprintf(":%d\r\n", len(r.lists[args[1]]))
+	case "LRANGE":
+		l := r.lists[args[1]]
+		s := fmt.Sprintf("*%d\r\n", len(l))
+		for _, e := range l {
+			s += fmt.Sprintf("$%d\r\n%s\r\n", len(e), e)
+		}
+		return s
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(line[1 : len(line)-2])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err = rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(line[1 : len(line)-2])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisSegmentStore(t *testing.T) {
+	server := newFakeRedis(t, "sekrit")
+	defer server.ln.Close()
+
+	orig := walker.Config
+	defer func() { walker.Config = orig }()
+	walker.Config.Cassandra.SegmentStore = "redis"
+	walker.Config.Redis.Address = server.ln.Addr().String()
+	walker.Config.Redis.Password = "sekrit"
+
+	store, err := newSegmentStore("walker_test", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create segment store: %v", err)
+	}
+	ctx := context.Background()
+
+	crawled := time.Unix(1400000000, 0).UTC()
+	links := []*walker.URL{
+		walker.MustParse("http://test.com/page1.html"),
+		walker.MustParse("https://sub.test.com/page2.html?a=b"),
+	}
+	links[1].LastCrawled = crawled
+	if err := store.StoreSegment(ctx, "test.com", links); err != nil {
+		t.Fatalf("StoreSegment failed: %v", err)
+	}
+	if _, ok := server.lists["walker:segments:walker_test:test.com"]; !ok {
+		t.Errorf("Expected segment under the configured key, got %v", server.lists)
+	}
+
+	got, err := store.SegmentLinks(ctx, "test.com")
+	if err != nil {
+		t.Fatalf("SegmentLinks failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 links, got %v", got)
+	}
+	if got[0].String() != "http://test.com/page1.html" || got[1].String() != "https://sub.test.com/page2.html?a=b" {
+		t.Errorf("Unexpected links: %v", got)
+	}
+	if !got[1].LastCrawled.Equal(crawled) {
+		t.Errorf("Expected LastCrawled %v, got %v", crawled, got[1].LastCrawled)
+	}
+
+	// Storing again replaces the segment
+	if err := store.StoreSegment(ctx, "test.com", links[:1]); err != nil {
+		t.Fatalf("StoreSegment failed: %v", err)
+	}
+	got, _ = store.SegmentLinks(ctx, "test.com")
+	if len(got) != 1 {
+		t.Errorf("Expected the segment to be replaced, got %v", got)
+	}
+
+	if err := store.DeleteSegment(ctx, "test.com"); err != nil {
+		t.Fatalf("DeleteSegment failed: %v", err)
+	}
+	got, err = store.SegmentLinks(ctx, "test.com")
+	if err != nil || len(got) != 0 {
+		t.Errorf("Expected no links after DeleteSegment, got %v, %v", got, err)
+	}
+
+	// All of that should have gone over one pooled connection
+	if server.conns != 1 {
+		t.Errorf("Expected connections to be reused, got %d connections", server.conns)
+	}
+
+	walker.Config.Redis.Password = "wrong"
+	store, err = newSegmentStore("walker_test", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create segment store: %v", err)
+	}
+	if _, err := store.SegmentLinks(ctx, "test.com"); err == nil {
+		t.Errorf("Expected an error with the wrong password")
+	}
+}
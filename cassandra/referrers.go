@@ -0,0 +1,88 @@
+package cassandra
+
+import (
+	"context"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/iParadigms/walker"
+)
+
+const insertParsedURLWithReferrer = `INSERT INTO links (dom, subdom, path, proto, time, nofollow, js_redirect, ref_last)
+										VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+// storeReferrers returns true if the page links parsed from fr should be
+// recorded as their referrer
+func (ds *Datastore) storeReferrers(fr *walker.FetchResults) bool {
+	return walker.Config.Cassandra.StoreReferrers && fr != nil && fr.URL != nil
+}
+
+// addParsedURLWithReferrer adds the statements storing u, parsed from the
+// page ref, to batch: its not-yet-crawled row with ref as its last referrer
+// and its row of link_referrers. If u has no first referrer yet, ref is
+// stored as such right away.
+func (ds *Datastore) addParsedURLWithReferrer(ctx context.Context, batch *writeBatcher, dom, subdom, path, proto string,
+	u *walker.URL, ref *walker.URL) {
+
+	err := batch.add(insertParsedURLWithReferrer,
+		dom, subdom, path, proto, walker.NotYetCrawled, u.Nofollow, u.JSRedirect, ref.String())
+	if err != nil {
+		log4go.Error("failed inserting parsed url (%v): %v", u, err)
+	}
+	err = batch.add(`INSERT INTO link_referrers (dom, subdom, path, proto, ref, last_seen) VALUES (?, ?, ?, ?, ?, ?)`,
+		dom, subdom, path, proto, ref.String(), time.Now())
+	if err != nil {
+		log4go.Error("failed inserting referrer of %v: %v", u, err)
+	}
+	ds.setFirstReferrer(ctx, u, ref)
+}
+
+// setFirstReferrer stores ref as the first referrer of u unless it already
+// has one, with a conditional write so that of two fetchers finding a new
+// link at the same time only the first is kept. Links known to have one are
+// cached (see cassandra.referrer_cache_size), so only the first time this
+// datastore sees a link costs the write.
+func (ds *Datastore) setFirstReferrer(ctx context.Context, u *walker.URL, ref *walker.URL) {
+	key := u.String()
+	if _, ok := ds.referrerCache.Get(key); ok {
+		return
+	}
+	dom, subdom, path, proto, err := u.PrimaryKey()
+	if err != nil {
+		log4go.Error("Failed to store first referrer of %v: %v", u, err)
+		return
+	}
+
+	err = ds.throttle.exec(ctx, ds.db.Query(
+		`UPDATE links SET ref_first = ? WHERE dom = ? AND subdom = ? AND path = ? AND proto = ? AND time = ?
+		 IF ref_first = null`,
+		ref.String(), dom, subdom, path, proto, walker.NotYetCrawled))
+	if err != nil {
+		log4go.Error("Failed to store first referrer of %v: %v", u, err)
+		return
+	}
+	ds.referrerCache.Add(key, true)
+}
+
+// ListReferrers is documented on the ModelDatastore interface.
+func (ds *Datastore) ListReferrers(u *walker.URL, limit int) ([]*Referrer, error) {
+	dom, subdom, path, proto, err := u.PrimaryKey()
+	if err != nil {
+		return nil, err
+	}
+
+	itr := ds.read(`SELECT ref, last_seen FROM link_referrers WHERE dom = ? AND subdom = ? AND path = ? AND proto = ?
+					LIMIT ?`, dom, subdom, path, proto, limit).Iter()
+	var refs []*Referrer
+	var ref string
+	var lastSeen time.Time
+	for itr.Scan(&ref, &lastSeen) {
+		refURL, err := walker.ParseURL(ref)
+		if err != nil {
+			log4go.Warn("Skipping unparseable referrer %q of %v: %v", ref, u, err)
+			continue
+		}
+		refs = append(refs, &Referrer{URL: refURL, LastSeen: lastSeen})
+	}
+	return refs, itr.Close()
+}
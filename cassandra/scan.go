@@ -0,0 +1,403 @@
+package cassandra
+
+import (
+	"container/heap"
+	"expvar"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// domainQueueSize bounds how many domains the scan may queue ahead of the
+// generateRoutines. Domains are handed out highest priority first among
+// those queued.
+const domainQueueSize = 1000
+
+// scanCheckpointInterval is how many domains a shard scans between saving
+// its position in dispatcher_scan
+const scanCheckpointInterval = 1000
+
+// scanProgressLogInterval is how often progress is logged (and saved to
+// dispatcher_progress) during a pass
+const scanProgressLogInterval = time.Minute
+
+// tokenRange is a range of the Murmur3 token ring, (start, end]
+type tokenRange struct {
+	start, end int64
+}
+
+// tokenRanges splits the whole token ring into n contiguous ranges
+func tokenRanges(n int) []tokenRange {
+	width := math.MaxUint64 / uint64(n)
+	ranges := make([]tokenRange, n)
+	start := int64(math.MinInt64)
+	for i := range ranges {
+		end := int64(math.MaxInt64)
+		if i < n-1 {
+			end = start + int64(width)
+		}
+		ranges[i] = tokenRange{start: start, end: end}
+		start = end
+	}
+	return ranges
+}
+
+// fraction returns how far through r the token pos is, from 0 to 1
+func (r tokenRange) fraction(pos int64) float64 {
+	return (float64(pos) - float64(r.start)) / (float64(r.end) - float64(r.start))
+}
+
+// ScanProgress describes how far the dispatcher has got through its current
+// pass over domain_info, as returned by Dispatcher.ScanProgress
+type ScanProgress struct {
+	// The number of the pass (starting at 1) and when it started
+	Iteration int
+	Started   time.Time
+
+	// The number of token range shards the pass is split into (see
+	// dispatcher.scan_shards) and how many of them are finished
+	Shards     int
+	ShardsDone int
+
+	// Domains read and domains queued for segment generation so far
+	Scanned int64
+	Queued  int64
+
+	// Estimated fraction (0 to 1) of domain_info scanned so far, from the
+	// position of each shard in its token range
+	Fraction float64
+
+	// When this progress was saved to dispatcher_progress; only set when it
+	// is read back from there (see CrawlOverview.Scan)
+	Updated time.Time
+}
+
+// String formats ScanProgress for logging
+func (p ScanProgress) String() string {
+	return fmt.Sprintf("pass %d: %.1f%% scanned (%d/%d shards done), %d domains read, %d queued, running %v",
+		p.Iteration, p.Fraction*100, p.ShardsDone, p.Shards, p.Scanned, p.Queued,
+		time.Since(p.Started).Truncate(time.Second))
+}
+
+// scanState tracks the dispatcher's current pass over domain_info
+type scanState struct {
+	mu        sync.Mutex
+	iteration int
+	started   time.Time
+	ranges    []tokenRange
+
+	// current token of each shard, and whether it is done (accessed
+	// atomically)
+	positions []int64
+	done      []int32
+
+	scanned int64
+	queued  int64
+}
+
+// scanDispatcher is the dispatcher whose progress is published as the
+// dispatcher_scan expvar: the last one started in this process
+var scanDispatcher struct {
+	sync.Mutex
+	d *Dispatcher
+}
+
+func init() {
+	expvar.Publish("dispatcher_scan", expvar.Func(func() interface{} {
+		scanDispatcher.Lock()
+		d := scanDispatcher.d
+		scanDispatcher.Unlock()
+		if d == nil {
+			return nil
+		}
+		return d.ScanProgress()
+	}))
+}
+
+// ScanProgress returns the progress of the dispatcher's current (or last)
+// pass over domain_info
+func (d *Dispatcher) ScanProgress() ScanProgress {
+	s := &d.scan
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := ScanProgress{
+		Iteration: s.iteration,
+		Started:   s.started,
+		Shards:    len(s.ranges),
+		Scanned:   atomic.LoadInt64(&s.scanned),
+		Queued:    atomic.LoadInt64(&s.queued),
+	}
+	for i, r := range s.ranges {
+		if atomic.LoadInt32(&s.done[i]) != 0 {
+			p.ShardsDone++
+			p.Fraction += 1
+		} else {
+			p.Fraction += r.fraction(atomic.LoadInt64(&s.positions[i]))
+		}
+	}
+	if p.Shards > 0 {
+		p.Fraction /= float64(p.Shards)
+	}
+	return p
+}
+
+// scanDomains makes one pass over domain_info, split into
+// dispatcher.scan_shards token ranges scanned concurrently, queueing the
+// domains that need a segment and releasing the claims of lost fetchers.
+// Shards resume from the position saved in dispatcher_scan if a previous
+// pass did not finish. It returns the number of domains queued, and false if
+// the dispatcher was stopped before the pass finished.
+func (d *Dispatcher) scanDomains(iteration int) (int, bool) {
+	ranges := tokenRanges(walker.Config.Dispatcher.ScanShards)
+	resume := d.readScanCheckpoints(len(ranges))
+
+	s := &d.scan
+	s.mu.Lock()
+	s.iteration = iteration
+	s.started = time.Now()
+	s.ranges = ranges
+	s.positions = make([]int64, len(ranges))
+	s.done = make([]int32, len(ranges))
+	for i, r := range ranges {
+		s.positions[i] = r.start
+		if pos, ok := resume[i]; ok {
+			s.positions[i] = pos
+		}
+	}
+	atomic.StoreInt64(&s.scanned, 0)
+	atomic.StoreInt64(&s.queued, 0)
+	s.mu.Unlock()
+	if len(resume) > 0 {
+		log4go.Info("Resuming the scan of domain_info in %d of %d shards", len(resume), len(ranges))
+	}
+	d.saveScanProgress(d.ScanProgress())
+
+	var wg sync.WaitGroup
+	finished := int32(1)
+	for i := range ranges {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if !d.scanShard(i) {
+				atomic.StoreInt32(&finished, 0)
+			}
+		}(i)
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(waited)
+	}()
+	ticker := time.NewTicker(scanProgressLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-waited:
+			p := d.ScanProgress()
+			log4go.Info("Dispatcher finished %v", p)
+			d.saveScanProgress(p)
+			return int(p.Queued), atomic.LoadInt32(&finished) != 0
+		case <-ticker.C:
+			p := d.ScanProgress()
+			log4go.Info("Dispatcher progress: %v", p)
+			d.saveScanProgress(p)
+		}
+	}
+}
+
+// saveScanProgress records p in dispatcher_progress, for the console
+func (d *Dispatcher) saveScanProgress(p ScanProgress) {
+	err := d.db.Query(`INSERT INTO dispatcher_progress (id, iteration, started, shards, shards_done, scanned,
+						queued, fraction, updated) VALUES (0, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Iteration, p.Started, p.Shards, p.ShardsDone, p.Scanned, p.Queued, p.Fraction, time.Now()).Exec()
+	if err != nil {
+		log4go.Error("Failed to save dispatcher_progress: %v", err)
+	}
+}
+
+// scanShard scans shard i of the current pass, returning false if the
+// dispatcher was stopped before it finished
+func (d *Dispatcher) scanShard(i int) bool {
+	s := &d.scan
+	r := s.ranges[i]
+	from := atomic.LoadInt64(&s.positions[i])
+	iter := d.db.Query(`SELECT token(dom), dom, dispatched, claim_tok, excluded, paused, priority
+						FROM domain_info WHERE token(dom) > ? AND token(dom) <= ?`, from, r.end).Iter()
+
+	var token int64
+	var domain string
+	var dispatched bool
+	var claimTok gocql.UUID
+	var excluded, paused bool
+	var priority int
+	count := 0
+	for iter.Scan(&token, &domain, &dispatched, &claimTok, &excluded, &paused, &priority) {
+		if d.quitSignaled() {
+			iter.Close()
+			d.saveScanCheckpoint(i, atomic.LoadInt64(&s.positions[i]))
+			return false
+		}
+
+		if !dispatched && !excluded && !paused {
+			d.generatingWG.Add(1)
+			if !d.queue.push(domain, priority) {
+				d.generatingWG.Done()
+				iter.Close()
+				d.saveScanCheckpoint(i, atomic.LoadInt64(&s.positions[i]))
+				return false
+			}
+			atomic.AddInt64(&s.queued, 1)
+		} else if !d.fetcherIsAlive(claimTok) {
+			if d.oneShotIterations == 0 {
+				go d.cleanStrandedClaims(claimTok)
+			} else {
+				d.cleanStrandedClaims(claimTok)
+			}
+		}
+
+		atomic.StoreInt64(&s.positions[i], token)
+		atomic.AddInt64(&s.scanned, 1)
+		count++
+		if count%scanCheckpointInterval == 0 {
+			d.saveScanCheckpoint(i, token)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		// The next pass picks up from here
+		log4go.Error("Error scanning shard %d of domain_info: %v", i, err)
+		d.saveScanCheckpoint(i, atomic.LoadInt64(&s.positions[i]))
+		return true
+	}
+
+	atomic.StoreInt32(&s.done[i], 1)
+	err := d.db.Query(`DELETE FROM dispatcher_scan WHERE shard = ?`, i).Exec()
+	if err != nil {
+		log4go.Error("Failed to clear dispatcher_scan checkpoint of shard %d: %v", i, err)
+	}
+	return true
+}
+
+// saveScanCheckpoint records that shard i of the scan has got up to token
+func (d *Dispatcher) saveScanCheckpoint(i int, token int64) {
+	err := d.db.Query(`INSERT INTO dispatcher_scan (shard, shards, last_token, updated) VALUES (?, ?, ?, ?)`,
+		i, len(d.scan.ranges), token, time.Now()).Exec()
+	if err != nil {
+		log4go.Error("Failed to save dispatcher_scan checkpoint of shard %d: %v", i, err)
+	}
+}
+
+// readScanCheckpoints returns the saved position of each unfinished shard,
+// ignoring checkpoints saved with a different number of shards
+func (d *Dispatcher) readScanCheckpoints(shards int) map[int]int64 {
+	resume := map[int]int64{}
+	iter := d.db.Query(`SELECT shard, shards, last_token FROM dispatcher_scan`).Iter()
+	var shard, n int
+	var token int64
+	for iter.Scan(&shard, &n, &token) {
+		if n == shards && shard >= 0 && shard < shards {
+			resume[shard] = token
+		}
+	}
+	if err := iter.Close(); err != nil {
+		log4go.Error("Failed to read dispatcher_scan, scanning all of domain_info: %v", err)
+		return map[int]int64{}
+	}
+	return resume
+}
+
+// domainQueue is a bounded queue of domains waiting for a generateRoutine,
+// handed out highest priority first
+type domainQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  queuedDomains
+	seq    int64
+	size   int
+	closed bool
+}
+
+func newDomainQueue(size int) *domainQueue {
+	q := &domainQueue{size: size}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues domain, waiting while the queue is full. It returns false if
+// the queue was closed.
+func (q *domainQueue) push(domain string, priority int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) >= q.size && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	q.seq++
+	heap.Push(&q.items, &queuedDomain{domain: domain, priority: priority, seq: q.seq})
+	q.cond.Broadcast()
+	return true
+}
+
+// pop returns the highest priority domain queued, waiting while the queue
+// is empty. It returns false once the queue is closed.
+func (q *domainQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return "", false
+	}
+	qd := heap.Pop(&q.items).(*queuedDomain)
+	q.cond.Broadcast()
+	return qd.domain, true
+}
+
+// close wakes everything waiting on the queue and drops the domains still
+// in it
+func (q *domainQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.items = nil
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+type queuedDomain struct {
+	domain   string
+	priority int
+	seq      int64 // order queued, so equal priorities are first in first out
+}
+
+// queuedDomains implements heap.Interface, highest priority first
+type queuedDomains []*queuedDomain
+
+func (qd queuedDomains) Len() int { return len(qd) }
+func (qd queuedDomains) Less(i, j int) bool {
+	if qd[i].priority != qd[j].priority {
+		return qd[i].priority > qd[j].priority
+	}
+	return qd[i].seq < qd[j].seq
+}
+func (qd queuedDomains) Swap(i, j int) { qd[i], qd[j] = qd[j], qd[i] }
+
+func (qd *queuedDomains) Push(x interface{}) {
+	*qd = append(*qd, x.(*queuedDomain))
+}
+
+func (qd *queuedDomains) Pop() interface{} {
+	old := *qd
+	n := len(old)
+	x := old[n-1]
+	*qd = old[:n-1]
+	return x
+}
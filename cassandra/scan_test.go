@@ -0,0 +1,129 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+func TestTokenRanges(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 16} {
+		ranges := tokenRanges(n)
+		if len(ranges) != n {
+			t.Fatalf("Expected %d ranges, got %d", n, len(ranges))
+		}
+		if ranges[0].start != math.MinInt64 || ranges[n-1].end != math.MaxInt64 {
+			t.Errorf("Ranges %v do not cover the whole token ring", ranges)
+		}
+		for i := 1; i < n; i++ {
+			if ranges[i].start != ranges[i-1].end || ranges[i].start >= ranges[i].end {
+				t.Errorf("Ranges %v are not contiguous", ranges)
+			}
+		}
+	}
+
+	r := tokenRanges(1)[0]
+	if f := r.fraction(0); f < 0.49 || f > 0.51 {
+		t.Errorf("Expected token 0 to be half way through the ring, got %v", f)
+	}
+}
+
+func TestDomainQueue(t *testing.T) {
+	q := newDomainQueue(10)
+	q.push("low.com", 0)
+	q.push("high1.com", 5)
+	q.push("mid.com", 1)
+	q.push("high2.com", 5)
+
+	expected := []string{"high1.com", "high2.com", "mid.com", "low.com"}
+	for _, e := range expected {
+		got, ok := q.pop()
+		if !ok || got != e {
+			t.Errorf("Expected to pop %v, got %v (%v)", e, got, ok)
+		}
+	}
+
+	q.push("dropped.com", 1)
+	q.close()
+	if got, ok := q.pop(); ok {
+		t.Errorf("Expected nothing to pop from a closed queue, got %v", got)
+	}
+	if q.push("late.com", 1) {
+		t.Errorf("Expected push to a closed queue to fail")
+	}
+}
+
+func TestDispatcherScanShards(t *testing.T) {
+	orig := walker.Config.Dispatcher.ScanShards
+	defer func() {
+		walker.Config.Dispatcher.ScanShards = orig
+	}()
+	walker.Config.Dispatcher.ScanShards = 4
+
+	db := GetTestDB()
+	var domains []string
+	for i := 0; i < 20; i++ {
+		domain := fmt.Sprintf("test%d.com", i)
+		domains = append(domains, domain)
+		q := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+						VALUES (?, ?, ?, ?)`, domain, gocql.UUID{}, i%3, false)
+		if err := q.Exec(); err != nil {
+			t.Fatalf("Failed to insert test domain info: %v\nQuery: %v", err, q)
+		}
+		q = db.Query(`INSERT INTO links (dom, subdom, path, proto, time, getnow)
+						VALUES (?, ?, ?, ?, ?, ?)`, domain, "", "/page1.html", "http", walker.NotYetCrawled, false)
+		if err := q.Exec(); err != nil {
+			t.Fatalf("Failed to insert test link: %v\nQuery: %v", err, q)
+		}
+	}
+
+	d, err := NewDispatcher()
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	if err := d.oneShot(1); err != nil {
+		t.Fatalf("Failed to run dispatcher: %v", err)
+	}
+
+	for _, domain := range domains {
+		var dispatched bool
+		q := db.Query(`SELECT dispatched FROM domain_info WHERE dom = ?`, domain)
+		if err := q.Scan(&dispatched); err != nil {
+			t.Fatalf("Failed to find domain info: %v\nQuery: %v", err, q)
+		}
+		if !dispatched {
+			t.Errorf("Expected %v to be dispatched", domain)
+		}
+	}
+
+	p := d.ScanProgress()
+	if p.Shards != 4 || p.ShardsDone != 4 || p.Scanned != 20 || p.Queued != 20 || p.Fraction != 1 {
+		t.Errorf("Unexpected scan progress %+v", p)
+	}
+
+	ds := getDS(t)
+	defer ds.Close()
+	ov, err := ds.CrawlOverview()
+	if err != nil {
+		t.Fatalf("CrawlOverview failed: %v", err)
+	}
+	if ov.Scan == nil {
+		t.Fatalf("Expected CrawlOverview to include the saved scan progress")
+	}
+	if ov.Scan.ShardsDone != 4 || ov.Scan.Scanned != 20 || ov.Scan.Queued != 20 || ov.Scan.Updated.IsZero() {
+		t.Errorf("Unexpected saved scan progress %+v", *ov.Scan)
+	}
+
+	var checkpoints int
+	if err := db.Query(`SELECT COUNT(*) FROM dispatcher_scan`).Scan(&checkpoints); err != nil {
+		t.Fatalf("Failed to count dispatcher_scan: %v", err)
+	}
+	if checkpoints != 0 {
+		t.Errorf("Expected finished shards to clear their checkpoints, found %d", checkpoints)
+	}
+}
@@ -43,28 +43,117 @@ CREATE TABLE {{.Keyspace}}.links (
 	-- (null implies we were not excluded)
 	robot_ex boolean,
 
+	-- true if this link was not downloaded because a HEAD request showed it
+	-- was too large or not an accepted content type (see
+	-- fetcher.head_precheck_domains); null implies it was not skipped
+	precheck_skip boolean,
+
+	-- true if the body was cut off at fetcher.max_http_content_size_bytes (see
+	-- fetcher.range_request_domains); null implies it was complete
+	trunc boolean,
+
 	-- If this link redirects to another link target, the target link is stored
-	-- in this field
+	-- in this field (and the redirect's status code, ex. 301, in stat)
 	redto_url text,
 
+	-- true if the dispatcher retired this link because it kept permanently
+	-- redirecting to redto_url (see dispatcher.rewrite_permanent_redirects);
+	-- the target was stored as a link of its own and this one is no longer
+	-- dispatched. Set on the row of the link's last crawl.
+	tombstoned boolean,
+
 	-- getnow is true if this link should be queued ASAP to be crawled
 	getnow boolean,
 
+	-- true if this link was parsed from an anchor marked rel="nofollow",
+	-- "ugc" or "sponsored" (set on the not-yet-crawled row when the link is
+	-- parsed; null implies it was not)
+	nofollow boolean,
+
+	-- true if this link was parsed as the target of a JavaScript redirect
+	-- (see fetcher.detect_js_redirects); set like nofollow
+	js_redirect boolean,
+
+	-- the pages this link was first and most recently parsed from, if
+	-- cassandra.store_referrers is on; set like nofollow
+	ref_first text,
+	ref_last text,
+
+	-- true if this fetch was given up because its redirects looped or went on
+	-- past fetcher.max_redirects, in which case redir_chain lists every URL
+	-- requested, starting with this link (see dispatcher.redirect_loop_cooldown)
+	redir_loop boolean,
+	redir_chain list<text>,
+
+	-- number of consecutive transient failures (timeouts and 5XX statuses)
+	-- of this link as of this fetch (null if the fetch did not fail this way)
+	retries int,
+
+	-- if this fetch failed transiently, the time before which the dispatcher
+	-- should not queue the link again (see fetcher.transient_retry_backoff)
+	next_retry_at timestamp,
+
+	-- the earliest time worth fetching this link again according to the
+	-- response's Cache-Control max-age or Expires header (null if it gave
+	-- none; see dispatcher.honor_cache_headers)
+	refetch_after timestamp,
+
 	-- mime type, also known as Content-Type (ex. "text/html")
 	mime text,
 
+	-- the Content-Language header of the response (see fetcher.accept_language)
+	content_lang text,
+
+	-- the fetcher.crawl_identity the link was fetched under, if one was set
+	crawl_id text,
+
 	-- fnv fingerprint, a hash of the page contents for identity comparison
 	fnv bigint,
 
 	-- fnv fingerprint of the text pulled from the body
 	fnv_txt bigint,
 
+	-- sha-256 digest of the page contents (if "sha256" is in
+	-- fetcher.fingerprints)
+	sha256 blob,
+
+	-- simhash of the text pulled from the body, close for pages with similar
+	-- text (if "simhash" is in fetcher.fingerprints)
+	simhash bigint,
+
+	-- the page's <title> and <meta name="description"> (null if it had none
+	-- or was not HTML)
+	title text,
+	description text,
+
 	-- body stores the content for this link (if cassandra.store_response_body is true)
 	body text,
 
+	-- body_z stores the body instead of body if cassandra.body_compression is
+	-- set: its first byte marks the codec it was compressed with
+	body_z blob,
+
+	-- for a 304 Not Modified fetch, the time of the earlier fetch of this link
+	-- whose body is the content (the fingerprints, mime, title and
+	-- description are copied forward from the previous fetch)
+	body_time timestamp,
+
 	-- headers stores the http headers for this link (if cassandra.store_response_headers is true)
 	headers map<text,text>,
 
+	-- req_headers stores the http headers sent to fetch this link, with
+	-- credentials masked (if cassandra.store_request_headers is true)
+	req_headers map<text,text>,
+
+	-- JSON encoded OpenGraph, JSON-LD and microdata extracted from the page
+	-- (if cassandra.store_structured_data is true)
+	structured text,
+
+	-- timing breakdown of the fetch (if cassandra.store_fetch_timing is
+	-- true). Keys are dns, connect, tls, ttfb and transfer, with durations in
+	-- nanoseconds, plus bytes for the size of the body read
+	timing map<text,bigint>,
+
 	---- Items yet to be added to walker
 
 	-- structure fingerprint, a hash of the page structure only (defined as:
@@ -97,6 +186,10 @@ CREATE TABLE {{.Keyspace}}.segments (
 	-- time this link was last crawled, so that we can use if-modified-since headers
 	time timestamp,
 
+	-- position of the link in the segment: links are read back in this order,
+	-- which is the order the dispatcher wants them fetched in
+	pos int,
+
 	PRIMARY KEY (dom, subdom, path, proto)
 ) WITH compaction = { 'class' : 'LeveledCompactionStrategy' }
 	AND caching = 'NONE'
@@ -130,6 +223,11 @@ CREATE TABLE {{.Keyspace}}.domain_info (
 	-- stopped abnormally)
 	claim_time timestamp, -- define as last time crawled?
 
+	-- UUID of the crawler that last crawled this domain, kept after it
+	-- unclaims it so that crawler can be given the first chance to claim it
+	-- again (see cassandra.host_affinity)
+	affinity_tok uuid,
+
 	-- true if this domain has had a segment generated and is ready for crawling
 	dispatched boolean,
 
@@ -138,6 +236,52 @@ CREATE TABLE {{.Keyspace}}.domain_info (
 	-- the reason this domain is excluded, null if not excluded
 	exclude_reason text,
 
+	-- true if crawling of this domain is paused (null implies not paused). A
+	-- paused domain keeps its links and history, but the dispatcher will not
+	-- generate a segment for it and fetchers will not claim it.
+	paused boolean,
+
+	-- per-domain overrides of cassandra.sample_threshold and
+	-- cassandra.sample_percent (see walker.yaml), which apply even if
+	-- sampling is off in the config; null or 0 means use the configured value
+	sample_threshold int,
+	sample_percent float,
+
+	-- per-domain override of dispatcher.domain_byte_budget (see walker.yaml);
+	-- null or 0 means use the configured value, < 0 means no budget
+	byte_budget bigint,
+
+	-- when this domain may be dispatched, e.g. "Mon-Fri 01:00-05:00; Sat,Sun 00:00-24:00"
+	-- (see cassandra.ParseCrawlWindow), evaluated in the IANA time zone
+	-- crawl_timezone (UTC if null); null means any time
+	crawl_window text,
+	crawl_timezone text,
+
+	-- per-domain fetcher settings (see walker.HostSettings): the user agent
+	-- to crawl this domain as, overriding fetcher.user_agent(s), and the
+	-- crawl delay in milliseconds, overriding fetcher.default_crawl_delay;
+	-- null or 0 means use the configured value
+	user_agent text,
+	crawl_delay int,
+
+	-- The domain's favicon, as last fetched by a fetcher claiming it (see
+	-- fetcher.favicon_domains): the URL requested (or redirected to), when,
+	-- the status it got, and (for a 2XX) its mime type and fnv fingerprint.
+	-- All null if it has never been fetched.
+	favicon_url text,
+	favicon_time timestamp,
+	favicon_stat int,
+	favicon_mime text,
+	favicon_fnv bigint,
+
+	-- The fnv fingerprint of the domain's robots.txt (0 if it has none), as
+	-- last fetched by a fetcher claiming it, and since when it has had that
+	-- fingerprint. robots_changed is set once the fingerprint changes after
+	-- first being recorded. All null if it has never been fetched.
+	robots_fnv bigint,
+	robots_time timestamp,
+	robots_changed boolean,
+
 	-- How many links does this domain have. NOTE: this data item is updated by the dispatcher during dispatch. That
 	-- means that this number could be stale if the dispatcher hasn't run recently. uncrawled_links and queued_links
 	-- has the same pathology.
@@ -157,6 +301,27 @@ CREATE TABLE {{.Keyspace}}.domain_info (
 	-- The last time the dispatcher saw that this domain had no links to dispatch
 	last_empty_dispatch timestamp,
 
+	-- Labels attached to this domain when links were inserted into it (see
+	-- ModelDatastore.InsertLinksWithTags), so seed batches can be tracked
+	-- and reported on separately
+	tags set<text>,
+
+	-- When the dispatcher probed this domain before its first dispatch (see
+	-- dispatcher.probe_new_domains); null if it has not been probed
+	probe_time timestamp,
+
+	-- Exclude patterns (regexes matched against the path, like
+	-- fetcher.exclude_link_patterns) suggested when the dispatcher last
+	-- flagged this domain as a crawler trap (see dispatcher.trap_detection),
+	-- and when; null if it never was. If trap_excluded is true, uncrawled
+	-- links matching trap_accepted are left out of segments: the patterns
+	-- there were when they were accepted in the console, or all of them if
+	-- dispatcher.trap_detection is exclude.
+	trap_patterns set<text>,
+	trap_accepted set<text>,
+	trap_time timestamp,
+	trap_excluded boolean,
+
 	---- Items yet to be added to walker
 
 	-- If not null, identifies another domain as a mirror of this one
@@ -167,6 +332,7 @@ CREATE TABLE {{.Keyspace}}.domain_info (
 CREATE INDEX ON {{.Keyspace}}.domain_info (claim_tok);
 CREATE INDEX ON {{.Keyspace}}.domain_info (priority);
 CREATE INDEX ON {{.Keyspace}}.domain_info (dispatched);
+CREATE INDEX ON {{.Keyspace}}.domain_info (tags);
 
 -- active_fetchers lists the uuids of running fetchers
 CREATE TABLE {{.Keyspace}}.active_fetchers (
@@ -177,9 +343,228 @@ CREATE TABLE {{.Keyspace}}.active_fetchers (
 CREATE TABLE {{.Keyspace}}.domain_counters (
 	dom text,
 	next_crawl counter,
+
+	-- number of fetches made for this domain, and how many of those failed
+	-- (had an error or a status >= 400)
+	fetches counter,
+	fetch_errors counter,
+
+	-- number of response body bytes fetched for this domain
+	bytes counter,
+
+	-- number of successful fetches for this domain by the MIME type of what
+	-- they got: HTML, PDF, images, or anything else (see MimeTypeCounts)
+	mime_html counter,
+	mime_pdf counter,
+	mime_image counter,
+	mime_other counter,
+
+	PRIMARY KEY (dom)
+);
+
+-- fetch_counts counts fetches stored per minute, so the console can show the
+-- recent fetch rate
+CREATE TABLE {{.Keyspace}}.fetch_counts (
+	-- the start of the minute the fetches were stored in
+	bucket timestamp,
+	fetches counter,
+	PRIMARY KEY (bucket)
+);
+
+-- domain_fetch_counts counts fetches (and the response body bytes they read)
+-- stored per domain in ten minute buckets, used to estimate how long a
+-- domain's backlog will take to crawl and to enforce bandwidth budgets
+CREATE TABLE {{.Keyspace}}.domain_fetch_counts (
+	dom text,
+	-- the start of the ten minutes the fetches were stored in
+	bucket timestamp,
+	fetches counter,
+	bytes counter,
+	PRIMARY KEY (dom, bucket)
+);
+
+-- pending_domains counts references to domains found in parsed links but not
+-- added to the crawl, when cassandra.track_pending_domains is true, so they
+-- can be approved or rejected by hand
+CREATE TABLE {{.Keyspace}}.pending_domains (
+	dom text,
+	-- number of parsed links that referenced the domain
+	refs counter,
 	PRIMARY KEY (dom)
 );
 
+-- tls_certs records the TLS details of the latest https fetch of each host,
+-- when cassandra.store_tls_info is true
+CREATE TABLE {{.Keyspace}}.tls_certs (
+	-- top-level domain plus one component, ex. "google.com"
+	dom text,
+	-- full host name, ex. "www.google.com"
+	host text,
+	-- time of the fetch these details were seen in
+	time timestamp,
+	-- negotiated protocol version and cipher suite
+	version text,
+	cipher text,
+	-- certificate issuer and subject, as distinguished names
+	issuer text,
+	subject text,
+	-- when the certificate expires
+	not_after timestamp,
+	-- subject alternative names of the certificate
+	sans list<text>,
+	PRIMARY KEY (dom, host)
+);
+
+-- claim_queue holds the dispatched domains waiting to be claimed by a
+-- fetcher, bucketed by priority and in the order they were dispatched. It is
+-- only used when cassandra.claim_strategy is weighted.
+CREATE TABLE {{.Keyspace}}.claim_queue (
+	-- the domain's priority when it was dispatched
+	priority int,
+	-- when the domain was dispatched
+	queued timestamp,
+	dom text,
+	PRIMARY KEY (priority, queued, dom)
+);
+
+-- handler_dead_letters records fetches the handler failed to handle, even
+-- after retrying (see fetcher.handler_retries in walker.yaml), so the work can
+-- be replayed later
+CREATE TABLE {{.Keyspace}}.handler_dead_letters (
+	-- top-level domain of the link
+	dom text,
+	-- the time the handler gave up
+	time timestamp,
+	-- the full URL of the link
+	url text,
+	-- HTTP status of the response, if there was one
+	stat int,
+	-- the error the handler failed with
+	err text,
+	PRIMARY KEY (dom, time, url)
+);
+
+-- claims_audit records each domain the dispatcher released because the
+-- fetcher that claimed it stopped without releasing it, so frequent fetcher
+-- deaths can be seen in the console
+CREATE TABLE {{.Keyspace}}.claims_audit (
+	-- the start of the (UTC) day the domain was released in
+	day timestamp,
+	-- when the domain was released
+	time timestamp,
+	dom text,
+	-- the token of the fetcher that had claimed the domain
+	claim_tok uuid,
+	-- how long the domain had been claimed, in seconds (0 if not known)
+	claim_age bigint,
+	-- number of links in the segment thrown back to be dispatched again
+	links int,
+	PRIMARY KEY (day, time, dom)
+) WITH CLUSTERING ORDER BY (time DESC, dom ASC);
+
+-- dispatch_log records each segment the dispatcher generates, so the recent
+-- dispatches of a domain can be reported (see ModelDatastore.DomainStats).
+-- Rows expire after 30 days.
+CREATE TABLE {{.Keyspace}}.dispatch_log (
+	dom text,
+	-- when the segment was generated
+	time timestamp,
+	-- number of links in the segment
+	links int,
+	PRIMARY KEY (dom, time)
+) WITH CLUSTERING ORDER BY (time DESC)
+	AND default_time_to_live = 2592000;
+
+-- dispatcher_scan records how far the dispatcher's pass over domain_info has
+-- got in each of its token range shards (see dispatcher.scan_shards), so a
+-- restarted dispatcher resumes the pass where it left off. A shard's row is
+-- deleted when it finishes.
+CREATE TABLE {{.Keyspace}}.dispatcher_scan (
+	shard int,
+	-- the number of shards the pass was split into; checkpoints saved with a
+	-- different number are ignored
+	shards int,
+	-- token(dom) of the last domain scanned in the shard
+	last_token bigint,
+	updated timestamp,
+	PRIMARY KEY (shard)
+);
+
+-- dispatcher_progress holds the progress of the dispatcher's current (or
+-- last) pass over domain_info (see cassandra.ScanProgress), saved every
+-- minute for the console. It has a single row, with id 0.
+CREATE TABLE {{.Keyspace}}.dispatcher_progress (
+	id int,
+	iteration int,
+	started timestamp,
+	shards int,
+	shards_done int,
+	scanned bigint,
+	queued bigint,
+	fraction double,
+	updated timestamp,
+	PRIMARY KEY (id)
+);
+
+-- links_by_fnv and links_by_sha256 index links by the fingerprints of their
+-- fetches, when cassandra.index_fingerprints is on. A link stays indexed under
+-- fingerprints its content no longer has; FindLinksByFingerprint and
+-- FindLinksBySHA256 check each link's latest fetch.
+CREATE TABLE {{.Keyspace}}.links_by_fnv (
+	-- the fnv fingerprint of the body or of its text (fnv or fnv_txt in links)
+	fnv bigint,
+	dom text,
+	subdom text,
+	path text,
+	proto text,
+	PRIMARY KEY (fnv, dom, subdom, path, proto)
+);
+
+CREATE TABLE {{.Keyspace}}.links_by_sha256 (
+	sha256 blob,
+	dom text,
+	subdom text,
+	path text,
+	proto text,
+	PRIMARY KEY (sha256, dom, subdom, path, proto)
+);
+
+-- politeness_audit records how each domain was crawled each time a fetcher
+-- claimed it, when cassandra.store_politeness_audit is on
+CREATE TABLE {{.Keyspace}}.politeness_audit (
+	dom text,
+	-- when the fetcher started crawling the domain
+	start timestamp,
+	-- the token of the fetcher
+	crawler uuid,
+	-- how long crawling the domain took, and the longest crawl delay observed
+	-- between requests, in milliseconds
+	dur bigint,
+	crawl_delay bigint,
+	-- number of requests made, and of 429 and 503 responses
+	requests int,
+	stat_429 int,
+	stat_503 int,
+	-- the first 429 and 503 responses, each as "<RFC 3339 time> <status> <url>"
+	events list<text>,
+	PRIMARY KEY (dom, start, crawler)
+) WITH CLUSTERING ORDER BY (start DESC, crawler ASC);
+
+-- link_referrers lists the pages each link was parsed from, when
+-- cassandra.store_referrers is on
+CREATE TABLE {{.Keyspace}}.link_referrers (
+	-- the link
+	dom text,
+	subdom text,
+	path text,
+	proto text,
+
+	-- the page linking to it, and when a crawl of that page last found it
+	ref text,
+	last_seen timestamp,
+	PRIMARY KEY ((dom, subdom, path, proto), ref)
+);
+
 CREATE TABLE {{.Keyspace}}.walker_globals (
 	key text,
 	val int,
@@ -0,0 +1,228 @@
+package cassandra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/gomodule/redigo/redis"
+	"github.com/iParadigms/walker"
+)
+
+// SegmentStore holds segments, the links the dispatcher has queued for a
+// domain, from the time they are generated until the fetcher that claimed the
+// domain is done with it. Claims themselves are always kept in domain_info;
+// only the links are kept in the SegmentStore.
+//
+// cassandra.segment_store selects the implementation NewDatastore and
+// NewDispatcher use. There is no SQS store: SegmentLinks reads a domain's
+// whole segment by key, which SQS could only do with a queue per domain.
+type SegmentStore interface {
+	// StoreSegment writes links as the segment for domain
+	StoreSegment(ctx context.Context, domain string, links []*walker.URL) error
+
+	// SegmentLinks returns the links in domain's segment, with LastCrawled
+	// set to when each was last crawled
+	SegmentLinks(ctx context.Context, domain string) ([]*walker.URL, error)
+
+	// DeleteSegment removes domain's segment
+	DeleteSegment(ctx context.Context, domain string) error
+}
+
+// newSegmentStore creates the SegmentStore configured by
+// cassandra.segment_store for the crawl in keyspace. db and throttle are
+// used by the cassandra store, and read (if not nil) creates its reads.
+func newSegmentStore(keyspace string, db *gocql.Session, throttle *writeThrottle,
+	read func(string, ...interface{}) *gocql.Query) (SegmentStore, error) {
+
+	switch strings.ToLower(walker.Config.Cassandra.SegmentStore) {
+	case "redis":
+		pool, err := newRedisPool()
+		if err != nil {
+			return nil, err
+		}
+		prefix := fmt.Sprintf("%ssegments:%s:", walker.Config.Redis.KeyPrefix, keyspace)
+		return &redisSegments{pool: pool, prefix: prefix}, nil
+	default:
+		if read == nil {
+			read = db.Query
+		}
+		return &cassandraSegments{db: db, throttle: throttle, read: read}, nil
+	}
+}
+
+// cassandraSegments is the SegmentStore using the segments table
+type cassandraSegments struct {
+	db       *gocql.Session
+	throttle *writeThrottle
+	read     func(string, ...interface{}) *gocql.Query
+}
+
+// StoreSegment is documented on the SegmentStore interface.
+func (s *cassandraSegments) StoreSegment(ctx context.Context, domain string, links []*walker.URL) error {
+	batch := s.throttle.batcher(ctx, s.db)
+	for i, u := range links {
+		log4go.Debug("Inserting link in segment: %s", u)
+		dom, subdom, path, proto, err := u.PrimaryKey()
+		if err != nil {
+			return fmt.Errorf("generateSegment not inserting %v: %v", u, err)
+		}
+		err = batch.add(`INSERT INTO segments
+			(dom, subdom, path, proto, time, pos)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			dom, subdom, path, proto, u.LastCrawled, i)
+		if err != nil {
+			log4go.Error("Failed to insert link (%v), error: %v", u, err)
+		}
+	}
+	return batch.flush()
+}
+
+// SegmentLinks is documented on the SegmentStore interface. Rows come back in
+// clustering order, so the links are sorted by their position in the segment.
+func (s *cassandraSegments) SegmentLinks(ctx context.Context, domain string) ([]*walker.URL, error) {
+	q := s.read(`SELECT dom, subdom, path, proto, time, pos
+						FROM segments WHERE dom = ?`, domain).WithContext(ctx)
+	iter := q.Iter()
+
+	type positioned struct {
+		u   *walker.URL
+		pos int
+	}
+	var rows []positioned
+	var dbdomain, subdomain, path, protocol string
+	var crawlTime time.Time
+	var pos int
+	for iter.Scan(&dbdomain, &subdomain, &path, &protocol, &crawlTime, &pos) {
+		u, e := walker.CreateURL(dbdomain, subdomain, path, protocol, crawlTime)
+		if e != nil {
+			log4go.Error("Error adding link (%v) to crawl: %v", u, e)
+		} else {
+			log4go.Debug("Adding link: %v", u)
+			rows = append(rows, positioned{u, pos})
+		}
+	}
+	err := iter.Close()
+
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].pos < rows[j].pos })
+	var links []*walker.URL
+	for _, r := range rows {
+		links = append(links, r.u)
+	}
+	return links, err
+}
+
+// DeleteSegment is documented on the SegmentStore interface.
+func (s *cassandraSegments) DeleteSegment(ctx context.Context, domain string) error {
+	return s.db.Query(`DELETE FROM segments WHERE dom = ?`, domain).WithContext(ctx).Exec()
+}
+
+// redisSegments is the SegmentStore keeping each segment in a Redis list
+type redisSegments struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// redisSegmentLink is how a link is encoded in a segment list; the fields
+// are the columns of the segments table
+type redisSegmentLink struct {
+	Dom    string    `json:"dom"`
+	Subdom string    `json:"subdom"`
+	Path   string    `json:"path"`
+	Proto  string    `json:"proto"`
+	Time   time.Time `json:"time"`
+}
+
+// StoreSegment is documented on the SegmentStore interface. It replaces any
+// segment already stored for domain.
+func (s *redisSegments) StoreSegment(ctx context.Context, domain string, links []*walker.URL) error {
+	if len(links) == 0 {
+		return nil
+	}
+	key := s.prefix + domain
+	push := redis.Args{key}
+	for _, u := range links {
+		log4go.Debug("Inserting link in segment: %s", u)
+		dom, subdom, path, proto, err := u.PrimaryKey()
+		if err != nil {
+			return fmt.Errorf("generateSegment not inserting %v: %v", u, err)
+		}
+		b, err := json.Marshal(&redisSegmentLink{
+			Dom:    dom,
+			Subdom: subdom,
+			Path:   path,
+			Proto:  proto,
+			Time:   u.LastCrawled,
+		})
+		if err != nil {
+			return err
+		}
+		push = append(push, b)
+	}
+
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("DEL", key)
+	conn.Send("RPUSH", push...)
+	results, err := redis.Values(redis.DoContext(conn, ctx, "EXEC"))
+	if err == redis.ErrNil {
+		return fmt.Errorf("redis: segment transaction for %v was aborted", domain)
+	} else if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if e, ok := r.(redis.Error); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// SegmentLinks is documented on the SegmentStore interface.
+func (s *redisSegments) SegmentLinks(ctx context.Context, domain string) ([]*walker.URL, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	elems, err := redis.Strings(redis.DoContext(conn, ctx, "LRANGE", s.prefix+domain, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	var links []*walker.URL
+	for _, str := range elems {
+		var l redisSegmentLink
+		if err := json.Unmarshal([]byte(str), &l); err != nil {
+			log4go.Error("Error decoding segment link %q for %v: %v", str, domain, err)
+			continue
+		}
+		u, err := walker.CreateURL(l.Dom, l.Subdom, l.Path, l.Proto, l.Time)
+		if err != nil {
+			log4go.Error("Error adding link (%v) to crawl: %v", u, err)
+			continue
+		}
+		log4go.Debug("Adding link: %v", u)
+		links = append(links, u)
+	}
+	return links, nil
+}
+
+// DeleteSegment is documented on the SegmentStore interface.
+func (s *redisSegments) DeleteSegment(ctx context.Context, domain string) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = redis.DoContext(conn, ctx, "DEL", s.prefix+domain)
+	return err
+}
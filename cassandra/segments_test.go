@@ -0,0 +1,47 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestCassandraSegmentStore(t *testing.T) {
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+	ctx := context.Background()
+
+	// Not in clustering order, which the links must not come back in
+	links := []*walker.URL{
+		walker.MustParse("http://test.com/page3.html"),
+		walker.MustParse("http://test.com/page1.html"),
+		walker.MustParse("http://sub.test.com/page2.html"),
+	}
+	if err := ds.Segments.StoreSegment(ctx, "test.com", links); err != nil {
+		t.Fatalf("StoreSegment failed: %v", err)
+	}
+	got, err := ds.Segments.SegmentLinks(ctx, "test.com")
+	if err != nil {
+		t.Fatalf("SegmentLinks failed: %v", err)
+	}
+	if len(got) != len(links) {
+		t.Fatalf("Expected %d links, got %v", len(links), got)
+	}
+	for i := range links {
+		if got[i].String() != links[i].String() {
+			t.Errorf("Expected links in the order stored, %v, got %v", links, got)
+			break
+		}
+	}
+	if err := ds.Segments.DeleteSegment(ctx, "test.com"); err != nil {
+		t.Fatalf("DeleteSegment failed: %v", err)
+	}
+	got, _ = ds.Segments.SegmentLinks(ctx, "test.com")
+	if len(got) != 0 {
+		t.Errorf("Expected no links after DeleteSegment, got %v", got)
+	}
+}
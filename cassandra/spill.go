@@ -0,0 +1,466 @@
+package cassandra
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// SpillStats counts the writes this process spilled to cassandra.spill_file
+// while cassandra was unavailable
+type SpillStats struct {
+	// Number of statements written to the spill file
+	Spilled int64
+
+	// Number of spilled statements replayed into cassandra
+	Replayed int64
+
+	// Number of statements lost: not spilled because the file was full, or
+	// failed for another reason than unavailability when replayed
+	Dropped int64
+
+	// Size in bytes of the statements waiting to be replayed
+	PendingBytes int64
+}
+
+// String formats SpillStats for logging
+func (s SpillStats) String() string {
+	return fmt.Sprintf("%d writes spilled, %d replayed, %d dropped, %d bytes pending",
+		s.Spilled, s.Replayed, s.Dropped, s.PendingBytes)
+}
+
+var spillStats struct {
+	spilled  int64
+	replayed int64
+	dropped  int64
+	pending  int64
+}
+
+func init() {
+	expvar.Publish("cassandra_spill", expvar.Func(func() interface{} {
+		return CurrentSpillStats()
+	}))
+}
+
+// CurrentSpillStats returns the spill counters accumulated since this
+// process started
+func CurrentSpillStats() SpillStats {
+	return SpillStats{
+		Spilled:      atomic.LoadInt64(&spillStats.spilled),
+		Replayed:     atomic.LoadInt64(&spillStats.replayed),
+		Dropped:      atomic.LoadInt64(&spillStats.dropped),
+		PendingBytes: atomic.LoadInt64(&spillStats.pending),
+	}
+}
+
+// isUnavailable returns true if err means the write may succeed once the
+// cluster recovers, rather than that it was refused
+func isUnavailable(err error) bool {
+	if isTimeout(err) {
+		return true
+	}
+	switch err.(type) {
+	case *gocql.RequestErrUnavailable:
+		return true
+	case net.Error:
+		return true
+	}
+	switch err {
+	case gocql.ErrNoConnections, gocql.ErrUnavailable, gocql.ErrConnectionClosed, gocql.ErrTooManyTimeouts,
+		gocql.ErrSessionClosed:
+		return true
+	}
+	return false
+}
+
+// spilledStatement is one line of the spill file
+type spilledStatement struct {
+	Stmt string       `json:"stmt"`
+	Args []spilledArg `json:"args"`
+}
+
+// spilledArg is a statement argument, tagged with its type so it is bound
+// to the statement the same way when replayed
+type spilledArg struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// newSpilledStatement encodes stmt and its arguments, returning an error if
+// one of them is of a type the spill file can't hold
+func newSpilledStatement(stmt string, args []interface{}) (spilledStatement, error) {
+	s := spilledStatement{Stmt: stmt}
+	for _, arg := range args {
+		var typ string
+		switch arg.(type) {
+		case nil:
+			s.Args = append(s.Args, spilledArg{Type: "nil"})
+			continue
+		case string:
+			typ = "string"
+		case bool:
+			typ = "bool"
+		case int:
+			typ = "int"
+		case int64:
+			typ = "int64"
+		case float64:
+			typ = "float64"
+		case time.Time:
+			typ = "time"
+		case []byte:
+			typ = "bytes"
+		case []string:
+			typ = "strings"
+		case map[string]string:
+			typ = "map_string"
+		case map[string]int64:
+			typ = "map_int64"
+		case gocql.UUID:
+			typ = "uuid"
+		default:
+			return s, fmt.Errorf("can't spill argument of type %T", arg)
+		}
+		b, err := json.Marshal(arg)
+		if err != nil {
+			return s, err
+		}
+		s.Args = append(s.Args, spilledArg{Type: typ, Value: b})
+	}
+	return s, nil
+}
+
+// args decodes the statement's arguments
+func (s *spilledStatement) args() ([]interface{}, error) {
+	var args []interface{}
+	for _, a := range s.Args {
+		var v interface{}
+		var err error
+		switch a.Type {
+		case "nil":
+			args = append(args, nil)
+			continue
+		case "string":
+			var x string
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "bool":
+			var x bool
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "int":
+			var x int
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "int64":
+			var x int64
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "float64":
+			var x float64
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "time":
+			var x time.Time
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "bytes":
+			var x []byte
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "strings":
+			var x []string
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "map_string":
+			var x map[string]string
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "map_int64":
+			var x map[string]int64
+			err, v = json.Unmarshal(a.Value, &x), &x
+		case "uuid":
+			var x gocql.UUID
+			err, v = json.Unmarshal(a.Value, &x), &x
+		default:
+			return nil, fmt.Errorf("unknown argument type %q", a.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad %v argument: %v", a.Type, err)
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
+
+// spillJournal is the append-only file (cassandra.spill_file) statements are
+// spilled to. To replay it, the file is first moved aside to path.replay, so
+// new statements can be spilled while it is read; statements still not
+// written when the cluster goes away again are appended back.
+type spillJournal struct {
+	path     string
+	maxBytes int64
+
+	// Guards the file at path and size, its size
+	mu   sync.Mutex
+	size int64
+
+	// The number of Datastores using the journal, and the channels stopping
+	// and waiting for the goroutine replaying it. Guarded by spillJournals.
+	refs int
+	quit chan struct{}
+	done chan struct{}
+}
+
+// spillJournals holds the journals open in this process by path. Datastores
+// spilling to the same file share its journal, so that one goroutine
+// replays it and the spill counters count it once.
+var spillJournals = struct {
+	sync.Mutex
+	m map[string]*spillJournal
+}{m: map[string]*spillJournal{}}
+
+// newSpillJournal opens the spill file at path, which may hold statements
+// spilled (or left in path.replay) by an earlier run
+func newSpillJournal(path string, maxBytes int64) *spillJournal {
+	j := &spillJournal{path: path, maxBytes: maxBytes}
+	if info, err := os.Stat(path); err == nil {
+		j.size = info.Size()
+	}
+	if info, err := os.Stat(j.replayPath()); err == nil {
+		atomic.AddInt64(&spillStats.pending, info.Size())
+	}
+	atomic.AddInt64(&spillStats.pending, j.size)
+	return j
+}
+
+// openSpillJournal returns the journal the Datastore for keyspace spills to,
+// opening it and starting to replay it if no other Datastore of this process
+// has it open. Call release once done with it.
+func openSpillJournal(keyspace string) (*spillJournal, error) {
+	path := walker.Config.Cassandra.SpillFile
+	if keyspace != walker.Config.Cassandra.Keyspace {
+		// Spilled statements don't name their keyspace, so other keyspaces
+		// can't share the file
+		path += "." + keyspace
+	}
+
+	spillJournals.Lock()
+	defer spillJournals.Unlock()
+	if j, ok := spillJournals.m[path]; ok {
+		j.refs++
+		return j, nil
+	}
+	db, err := GetConfigForKeyspace(keyspace).CreateSession()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create session to replay %v: %v", path, err)
+	}
+	j := newSpillJournal(path, walker.Config.Cassandra.SpillMaxBytes)
+	j.refs = 1
+	j.quit = make(chan struct{})
+	j.done = make(chan struct{})
+	spillJournals.m[path] = j
+	go j.replayLoop(db)
+	return j, nil
+}
+
+// release drops a reference to j, closing it once no Datastore uses it
+func (j *spillJournal) release() {
+	spillJournals.Lock()
+	defer spillJournals.Unlock()
+	j.refs--
+	if j.refs > 0 {
+		return
+	}
+	delete(spillJournals.m, j.path)
+	close(j.quit)
+	<-j.done
+
+	// Whoever opens the file next counts what is left in it again
+	j.mu.Lock()
+	pending := j.size
+	if info, err := os.Stat(j.replayPath()); err == nil {
+		pending += info.Size()
+	}
+	j.mu.Unlock()
+	atomic.AddInt64(&spillStats.pending, -pending)
+}
+
+func (j *spillJournal) replayPath() string {
+	return j.path + ".replay"
+}
+
+// spill appends stmts to the file, unless that would grow it past maxBytes
+func (j *spillJournal) spill(stmts []spilledStatement) error {
+	var buf []byte
+	for i := range stmts {
+		b, err := json.Marshal(&stmts[i])
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.size+int64(len(buf)) > j.maxBytes {
+		atomic.AddInt64(&spillStats.dropped, int64(len(stmts)))
+		return fmt.Errorf("spill file %v is full (%d bytes)", j.path, j.size)
+	}
+	if err := j.appendLocked(func(w io.Writer) (int64, error) {
+		n, err := w.Write(buf)
+		return int64(n), err
+	}); err != nil {
+		return err
+	}
+	atomic.AddInt64(&spillStats.pending, int64(len(buf)))
+	atomic.AddInt64(&spillStats.spilled, int64(len(stmts)))
+	return nil
+}
+
+// appendLocked opens the file for appending and calls write with it. The
+// caller holds j.mu.
+func (j *spillJournal) appendLocked(write func(io.Writer) (int64, error)) error {
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	n, err := write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	j.size += n
+	if err != nil {
+		return fmt.Errorf("writing %v: %v", j.path, err)
+	}
+	return nil
+}
+
+// replay writes the spilled statements to db, through throttle. It stops,
+// appending the statements it did not get to back to the file, as soon as
+// cassandra is unavailable again or ctx is done.
+func (j *spillJournal) replay(ctx context.Context, db *gocql.Session, throttle *writeThrottle) error {
+	rpath := j.replayPath()
+
+	// Statements left by a replay that was interrupted go first
+	if _, err := os.Stat(rpath); os.IsNotExist(err) {
+		j.mu.Lock()
+		if j.size == 0 {
+			j.mu.Unlock()
+			return nil
+		}
+		err = os.Rename(j.path, rpath)
+		if err == nil {
+			j.size = 0
+		}
+		j.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(rpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	replayed := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		} else if err != nil && err != io.EOF {
+			return fmt.Errorf("reading %v: %v", rpath, err)
+		}
+		atomic.AddInt64(&spillStats.pending, -int64(len(line)))
+
+		var s spilledStatement
+		var args []interface{}
+		err = json.Unmarshal(line, &s)
+		if err == nil {
+			args, err = s.args()
+		}
+		if err != nil {
+			log4go.Error("Dropping unreadable line of %v: %v", rpath, err)
+			atomic.AddInt64(&spillStats.dropped, 1)
+			continue
+		}
+
+		err = throttle.exec(ctx, db.Query(s.Stmt, args...))
+		if err != nil && (isUnavailable(err) || ctx.Err() != nil) {
+			j.mu.Lock()
+			rerr := j.appendLocked(func(w io.Writer) (int64, error) {
+				n, err := w.Write(line)
+				if err != nil {
+					return int64(n), err
+				}
+				m, err := io.Copy(w, r)
+				return int64(n) + m, err
+			})
+			j.mu.Unlock()
+			// The rest of the replay file is still counted as pending
+			atomic.AddInt64(&spillStats.pending, int64(len(line)))
+			if rerr != nil {
+				return fmt.Errorf("failed to put back unreplayed writes, %v is left to replay: %v", rpath, rerr)
+			}
+			log4go.Info("Replayed %d spilled writes before cassandra failed again: %v", replayed, err)
+			return os.Remove(rpath)
+		} else if err != nil {
+			log4go.Error("Dropping spilled write that failed: %v (%v)", err, s.Stmt)
+			atomic.AddInt64(&spillStats.dropped, 1)
+			continue
+		}
+		replayed++
+		atomic.AddInt64(&spillStats.replayed, 1)
+	}
+	if replayed > 0 {
+		log4go.Info("Replayed %d spilled writes", replayed)
+	}
+	return os.Remove(rpath)
+}
+
+// spilling makes wb spill the writes that fail because cassandra is
+// unavailable, if cassandra.spill_file is set. Only idempotent writes should
+// go through it: a spilled write may have been applied before it failed.
+func (ds *Datastore) spilling(wb *writeBatcher) *writeBatcher {
+	wb.spill = ds.spill
+	return wb
+}
+
+// replayLoop replays the file through db every
+// cassandra.spill_replay_interval until j is released, then closes db
+func (j *spillJournal) replayLoop(db *gocql.Session) {
+	defer close(j.done)
+	defer db.Close()
+	interval, err := time.ParseDuration(walker.Config.Cassandra.SpillReplayInterval)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	throttle := newWriteThrottle()
+	throttle.rate = float64(walker.Config.Cassandra.SpillReplayRate)
+	throttle.burst = throttle.rate
+	throttle.tokens = throttle.burst
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-j.quit
+		cancel()
+	}()
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-j.quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := j.replay(ctx, db, throttle); err != nil {
+			log4go.Error("Failed to replay spilled writes: %v", err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+func TestSpilledStatementArgs(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	uuid, _ := gocql.RandomUUID()
+	args := []interface{}{
+		"dom", true, 3, int64(4), 1.5, now, []byte("body"), []string{"a", "b"},
+		map[string]string{"k": "v"}, map[string]int64{"ttfb": 10}, uuid, nil,
+	}
+	s, err := newSpilledStatement("INSERT", args)
+	if err != nil {
+		t.Fatalf("newSpilledStatement failed: %v", err)
+	}
+	decoded, err := s.args()
+	if err != nil {
+		t.Fatalf("Decoding args failed: %v", err)
+	}
+	if len(decoded) != len(args) {
+		t.Fatalf("Expected %d args, got %d", len(args), len(decoded))
+	}
+	for i, arg := range args {
+		got := decoded[i]
+		if got != nil {
+			got = reflect.ValueOf(got).Elem().Interface()
+		}
+		if tm, ok := got.(time.Time); ok {
+			if !tm.Equal(now) {
+				t.Errorf("Arg %d: expected %v, got %v", i, now, tm)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, arg) {
+			t.Errorf("Arg %d: expected %#v, got %#v", i, arg, got)
+		}
+	}
+
+	if _, err := newSpilledStatement("INSERT", []interface{}{struct{}{}}); err == nil {
+		t.Errorf("Expected an unknown argument type not to be spillable")
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		err    error
+		expect bool
+	}{
+		{gocql.ErrTimeoutNoResponse, true},
+		{&gocql.RequestErrWriteTimeout{}, true},
+		{&gocql.RequestErrUnavailable{}, true},
+		{gocql.ErrNoConnections, true},
+		{errors.New("syntax error"), false},
+	}
+	for _, tst := range tests {
+		if got := isUnavailable(tst.err); got != tst.expect {
+			t.Errorf("isUnavailable(%v): expected %v, got %v", tst.err, tst.expect, got)
+		}
+	}
+}
+
+func TestSpillJournalLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, _ := newSpilledStatement("INSERT INTO links (dom) VALUES (?)", []interface{}{"test.com"})
+	j := newSpillJournal(filepath.Join(dir, "spill"), 100)
+	before := CurrentSpillStats()
+	if err := j.spill([]spilledStatement{s}); err != nil {
+		t.Fatalf("Expected the first write to be spilled, got %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		j.spill([]spilledStatement{s})
+	}
+	after := CurrentSpillStats()
+	if j.size > 100 {
+		t.Errorf("Expected the spill file to stay under 100 bytes, got %d", j.size)
+	}
+	if after.Spilled-before.Spilled+after.Dropped-before.Dropped != 6 {
+		t.Errorf("Expected every write to be spilled or dropped, got %v", after)
+	}
+	if after.Dropped == before.Dropped {
+		t.Errorf("Expected writes past the limit to be dropped")
+	}
+	if after.PendingBytes-before.PendingBytes != j.size {
+		t.Errorf("Expected %d pending bytes, got %d", j.size, after.PendingBytes-before.PendingBytes)
+	}
+}
+
+func TestSpillQueuedDoesNotReuseSpilled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wb := &writeBatcher{spill: newSpillJournal(filepath.Join(dir, "spill"), 1<<20)}
+	wb.queue("INSERT INTO links (dom) VALUES (?)", []interface{}{"a.com"})
+	spilled, want := wb.queued, wb.queued[0]
+	if err := wb.spillQueued(&gocql.RequestErrUnavailable{}); err != nil {
+		t.Fatalf("Expected the queued write to be spilled, got %v", err)
+	}
+	wb.queue("INSERT INTO links (dom) VALUES (?)", []interface{}{"b.com"})
+	if !reflect.DeepEqual(spilled[0], want) {
+		t.Errorf("Expected the spilled write to be left alone, got %v", spilled[0])
+	}
+}
+
+func TestSpillAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	origCass := walker.Config.Cassandra
+	origFaults := walker.Config.Faults
+	defer func() {
+		walker.Config.Cassandra = origCass
+		walker.Config.Faults = origFaults
+	}()
+	walker.Config.Cassandra.SpillFile = filepath.Join(dir, "spill")
+	walker.Config.Cassandra.SpillReplayInterval = "1h"
+	walker.Config.Cassandra.WriteTimeoutRetries = 0
+
+	GetTestDB()
+	ds := getDS(t)
+	defer ds.Close()
+	ctx := context.Background()
+
+	// Every write fails as if cassandra timed out
+	walker.Config.Faults.Enabled = true
+	walker.Config.Faults.WriteErrorPercent = 100
+	before := CurrentSpillStats()
+	ds.StoreParsedURL(ctx, walker.MustParse("http://test.com/spilled.html"), nil)
+	ds.StoreParsedURLs(ctx, []*walker.URL{
+		walker.MustParse("http://test.com/spilled1.html"),
+		walker.MustParse("http://test.com/spilled2.html"),
+	}, nil)
+	if spilled := CurrentSpillStats().Spilled - before.Spilled; spilled != 3 {
+		t.Errorf("Expected 3 writes spilled, got %d", spilled)
+	}
+
+	// Replaying while cassandra is still unavailable keeps the writes
+	if err := ds.spill.replay(ctx, ds.db, nil); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if replayed := CurrentSpillStats().Replayed - before.Replayed; replayed != 0 {
+		t.Errorf("Expected nothing replayed while writes fail, got %d", replayed)
+	}
+
+	walker.Config.Faults.Enabled = false
+	if err := ds.spill.replay(ctx, ds.db, nil); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	stats := CurrentSpillStats()
+	if replayed := stats.Replayed - before.Replayed; replayed != 3 {
+		t.Errorf("Expected 3 writes replayed, got %d", replayed)
+	}
+	if stats.PendingBytes != before.PendingBytes {
+		t.Errorf("Expected no bytes left pending, got %d", stats.PendingBytes-before.PendingBytes)
+	}
+	for _, link := range []string{"http://test.com/spilled.html", "http://test.com/spilled1.html",
+		"http://test.com/spilled2.html"} {
+
+		linfo, err := ds.FindLink(walker.MustParse(link), false)
+		if err != nil {
+			t.Fatalf("FindLink(%v) failed: %v", link, err)
+		}
+		if linfo == nil {
+			t.Errorf("Expected %v to be stored by the replay", link)
+		}
+	}
+}
+
+func TestSpillJournalShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	origCass := walker.Config.Cassandra
+	defer func() {
+		walker.Config.Cassandra = origCass
+	}()
+	walker.Config.Cassandra.SpillFile = filepath.Join(dir, "spill")
+	walker.Config.Cassandra.SpillReplayInterval = "1h"
+
+	// A spill file left by an earlier run
+	s, _ := newSpilledStatement("INSERT INTO links (dom) VALUES (?)", []interface{}{"test.com"})
+	b, _ := json.Marshal(&s)
+	b = append(b, '\n')
+	if err := ioutil.WriteFile(walker.Config.Cassandra.SpillFile, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	GetTestDB()
+	before := CurrentSpillStats()
+	ds1 := getDS(t)
+	ds2 := getDS(t)
+	if ds1.spill != ds2.spill {
+		t.Errorf("Expected datastores of the same process to share their spill journal")
+	}
+	if pending := CurrentSpillStats().PendingBytes - before.PendingBytes; pending != int64(len(b)) {
+		t.Errorf("Expected the spill file to be counted once (%d bytes pending), got %d", len(b), pending)
+	}
+
+	ds1.Close()
+	select {
+	case <-ds2.spill.done:
+		t.Errorf("Expected the journal to be replayed until its last datastore closes")
+	default:
+	}
+	ds2.Close()
+	if pending := CurrentSpillStats().PendingBytes - before.PendingBytes; pending != 0 {
+		t.Errorf("Expected no bytes pending once the journal is closed, got %d", pending)
+	}
+}
@@ -0,0 +1,130 @@
+package cassandra
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// domainStatsDispatches is the number of recent dispatches DomainStats
+// reports
+const domainStatsDispatches = 10
+
+// domainStatsTopErrors is the number of errors DomainStats reports
+const domainStatsTopErrors = 10
+
+// DomainStats is documented on the ModelDatastore interface.
+func (ds *Datastore) DomainStats(domain string) (*DomainStats, error) {
+	itr := ds.read(`SELECT dom FROM domain_info WHERE dom = ?`, domain).Iter()
+	var dom string
+	found := itr.Scan(&dom)
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("domain_info query failed: %v", err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	stats := &DomainStats{Domain: domain, StatusCounts: map[int]int{}}
+	err := ds.read(`SELECT fetches, fetch_errors, bytes FROM domain_counters WHERE dom = ?`, domain).
+		Scan(&stats.Fetches, &stats.FetchErrors, &stats.Bytes)
+	if err != nil && err != gocql.ErrNotFound {
+		return nil, fmt.Errorf("domain_counters query failed: %v", err)
+	}
+
+	if err := ds.linkStats(stats); err != nil {
+		return nil, err
+	}
+
+	itr = ds.read(`SELECT time, links FROM dispatch_log WHERE dom = ? LIMIT ?`, domain, domainStatsDispatches).Iter()
+	var dispatchTime time.Time
+	var links int
+	for itr.Scan(&dispatchTime, &links) {
+		stats.Dispatches = append(stats.Dispatches, &DomainDispatch{Time: dispatchTime, Links: links})
+	}
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("dispatch_log query failed: %v", err)
+	}
+	return stats, nil
+}
+
+// linkStats fills in the figures of stats that come from the latest crawl of
+// each link of its domain
+func (ds *Datastore) linkStats(stats *DomainStats) error {
+	var total time.Duration
+	errors := map[string]int{}
+	add := func(c *statsCell) {
+		if c.tombstoned {
+			return
+		}
+		stats.Links++
+		if c.crawlTime.Equal(walker.NotYetCrawled) {
+			stats.UncrawledLinks++
+			return
+		}
+		stats.StatusCounts[c.status]++
+		if c.fetchErr != "" {
+			errors[c.fetchErr]++
+		}
+		t := timingFromMap(c.timing)
+		if d := t.DNS + t.Connect + t.TLS + t.TTFB + t.Transfer; d > 0 {
+			total += d
+			stats.TimedFetches++
+		}
+	}
+
+	// Rows of a link come out oldest first, so its latest crawl is the last
+	// row before the next link's (see SegmentGenerator.collectLinks)
+	itr := ds.read(`SELECT subdom, path, proto, time, stat, err, tombstoned, timing FROM links WHERE dom = ?`,
+		stats.Domain).Iter()
+	var current, previous statsCell
+	started := false
+	for itr.Scan(&current.subdom, &current.path, &current.proto, &current.crawlTime, &current.status,
+		&current.fetchErr, &current.tombstoned, &current.timing) {
+		if started && !current.sameLink(&previous) {
+			add(&previous)
+		}
+		previous = current
+		started = true
+	}
+	if started {
+		add(&previous)
+	}
+	if err := itr.Close(); err != nil {
+		return fmt.Errorf("links query failed: %v", err)
+	}
+
+	if stats.TimedFetches > 0 {
+		stats.AverageLatency = total / time.Duration(stats.TimedFetches)
+	}
+	for e, n := range errors {
+		stats.TopErrors = append(stats.TopErrors, &ErrorCount{Error: e, Count: n})
+	}
+	sort.Slice(stats.TopErrors, func(i, j int) bool {
+		if stats.TopErrors[i].Count != stats.TopErrors[j].Count {
+			return stats.TopErrors[i].Count > stats.TopErrors[j].Count
+		}
+		return stats.TopErrors[i].Error < stats.TopErrors[j].Error
+	})
+	if len(stats.TopErrors) > domainStatsTopErrors {
+		stats.TopErrors = stats.TopErrors[:domainStatsTopErrors]
+	}
+	return nil
+}
+
+// statsCell is the part of a links row linkStats reads
+type statsCell struct {
+	subdom, path, proto string
+	crawlTime           time.Time
+	status              int
+	fetchErr            string
+	tombstoned          bool
+	timing              map[string]int64
+}
+
+func (c *statsCell) sameLink(other *statsCell) bool {
+	return c.path == other.path && c.subdom == other.subdom && c.proto == other.proto
+}
@@ -0,0 +1,101 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestDomainStats(t *testing.T) {
+	origTiming := walker.Config.Cassandra.StoreFetchTiming
+	defer func() {
+		walker.Config.Cassandra.StoreFetchTiming = origTiming
+	}()
+	walker.Config.Cassandra.StoreFetchTiming = true
+
+	db := GetTestDB()
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, dispatched, priority)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, false, 1)`, "test.com").Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	ds := getDS(t)
+	defer ds.Close()
+
+	if errs := ds.InsertLinks([]string{"http://test.com/new.html"}, ""); len(errs) > 0 {
+		t.Fatalf("Failed to insert link: %v", errs)
+	}
+	fetches := []struct {
+		path   string
+		status int
+		err    error
+		ttfb   time.Duration
+	}{
+		// Only the latest crawl of each link counts
+		{"/page1.html", 500, nil, 0},
+		{"/page1.html", 200, nil, 10 * time.Millisecond},
+		{"/page2.html", 200, nil, 30 * time.Millisecond},
+		{"/missing.html", 404, nil, 20 * time.Millisecond},
+		{"/down1.html", 0, errors.New("connection refused"), 0},
+		{"/down2.html", 0, errors.New("connection refused"), 0},
+		{"/slow.html", 0, errors.New("timeout"), 0},
+	}
+	fetchTime := time.Now().Add(-time.Hour)
+	for _, f := range fetches {
+		fr := &walker.FetchResults{
+			URL:        walker.MustParse("http://test.com" + f.path),
+			FetchTime:  fetchTime,
+			FetchError: f.err,
+		}
+		if f.err == nil {
+			fr.Response = &http.Response{StatusCode: f.status}
+			fr.Timing = walker.FetchTiming{TTFB: f.ttfb}
+		}
+		ds.StoreURLFetchResults(context.Background(), fr)
+		fetchTime = fetchTime.Add(time.Minute)
+	}
+
+	dispatched := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	err = db.Query(`INSERT INTO dispatch_log (dom, time, links) VALUES (?, ?, ?)`, "test.com", dispatched, 6).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert dispatch: %v", err)
+	}
+
+	stats, err := ds.DomainStats("test.com")
+	if err != nil {
+		t.Fatalf("DomainStats failed: %v", err)
+	}
+	if stats.Links != 7 || stats.UncrawledLinks != 1 {
+		t.Errorf("Expected 7 links, 1 uncrawled, got %d, %d", stats.Links, stats.UncrawledLinks)
+	}
+	expectedStatuses := map[int]int{200: 2, 404: 1, 0: 3}
+	if !reflect.DeepEqual(stats.StatusCounts, expectedStatuses) {
+		t.Errorf("Expected status counts %v, got %v", expectedStatuses, stats.StatusCounts)
+	}
+	if stats.Fetches != int64(len(fetches)) || stats.FetchErrors != 5 {
+		t.Errorf("Expected %d fetches, 5 failed, got %d, %d", len(fetches), stats.Fetches, stats.FetchErrors)
+	}
+	if stats.TimedFetches != 3 || stats.AverageLatency != 20*time.Millisecond {
+		t.Errorf("Expected an average latency of 20ms over 3 fetches, got %v over %d",
+			stats.AverageLatency, stats.TimedFetches)
+	}
+	expectedErrors := []*ErrorCount{{"connection refused", 2}, {"timeout", 1}}
+	if !reflect.DeepEqual(stats.TopErrors, expectedErrors) {
+		t.Errorf("Expected top errors %v, got %v", expectedErrors, stats.TopErrors)
+	}
+	if len(stats.Dispatches) != 1 || !stats.Dispatches[0].Time.Equal(dispatched) || stats.Dispatches[0].Links != 6 {
+		t.Errorf("Expected one dispatch of 6 links at %v, got %+v", dispatched, stats.Dispatches)
+	}
+
+	stats, err = ds.DomainStats("nosuchdomain.com")
+	if err != nil || stats != nil {
+		t.Errorf("Expected no stats for unknown domain, got %+v, %v", stats, err)
+	}
+}
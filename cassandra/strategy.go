@@ -0,0 +1,260 @@
+package cassandra
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/iParadigms/walker"
+)
+
+// SegmentCandidates are the links of a domain eligible for its next segment,
+// as collected by the SegmentGenerator: links marked getnow, links that were
+// never crawled, and crawled links due for a refresh (see
+// dispatcher.min_link_refresh_time)
+type SegmentCandidates struct {
+	Domain string
+
+	GetNow    LinkList
+	Uncrawled LinkList
+	Refresh   LinkList
+}
+
+// SegmentStrategy decides which candidate links go into a domain's segment,
+// and in which order. Replace Dispatcher.Strategy before starting the
+// dispatcher (or set SegmentGenerator.Strategy) to prioritize links
+// differently, for example by a score kept outside of walker.
+//
+// The SegmentGenerator calls Filter and then Select once per segment, from
+// one goroutine at a time per generator; since the dispatcher runs
+// dispatcher.num_concurrent_domains generators sharing its Strategy, an
+// implementation with state must synchronize it.
+type SegmentStrategy interface {
+	// Filter may rewrite the URLs of the candidates (ex. to remove query
+	// parameters) and remove candidates, before any are selected.
+	Filter(c *SegmentCandidates)
+
+	// Select returns the links to dispatch, in the order they should be
+	// fetched, and the candidates left out because they duplicate a link
+	// being dispatched. Links marked getnow should always be dispatched; at
+	// most limit links should be dispatched otherwise. Returned links must be
+	// candidates.
+	Select(c *SegmentCandidates, limit int) (dispatch, duplicates []*LinkInfo)
+}
+
+// DefaultSegmentStrategy is the SegmentStrategy the dispatcher uses unless
+// told otherwise
+type DefaultSegmentStrategy struct{}
+
+// Filter is documented on the SegmentStrategy interface. It cuts out
+// repeated query parameters that don't affect content.
+func (s *DefaultSegmentStrategy) Filter(c *SegmentCandidates) {
+	start := time.Now()
+	dupClusters := buildDuplicateLinkClusters(c)
+	removableParams := discoverRemoveableQueryParameters(dupClusters)
+	filterLinksWithRules(c, removableParams)
+	log4go.Debug("Filtered links for %v in %v", c.Domain, time.Since(start))
+}
+
+// Build clusters of links with duplicate content. One "Cluster" is a group
+// of links with the same fingerprint, which is further grouped by
+// subdomain + path (without query parameters). An example entry might be:
+//		{
+//			29034823084: map[string]LinkList{
+//				"www/index.html": LinkList{
+//					<LinkInfo representing www.test.com/index.html>,
+//					<LinkInfo representing www.test.com/index.html?foo=bar>,
+//				},
+//				"www/": LinkList{
+//					<LinkInfo representing www.test.com/>,
+//					<LinkInfo representing www.test.com/?foo=bar>,
+//				},
+//			},
+//		}
+// In this example all four pages have the same textual content.
+func buildDuplicateLinkClusters(c *SegmentCandidates) map[int64]map[string]LinkList {
+	dupClusters := map[int64]map[string]LinkList{}
+	for _, linkList := range []LinkList{c.Uncrawled, c.Refresh} {
+		for _, l := range linkList {
+			entry := dupClusters[l.FnvTextFingerprint]
+			if entry == nil {
+				entry = map[string]LinkList{}
+				dupClusters[l.FnvTextFingerprint] = entry
+			}
+			subdom, err := l.URL.Subdomain()
+			if err != nil {
+				log4go.Error("Dispatcher creating query rules could not get subdomain: %v", err)
+				continue
+			}
+			key := subdom + l.URL.Path
+			entry[key] = append(entry[key], l)
+		}
+	}
+	log4go.Fine("Duplicate cluster map created: %v", dupClusters)
+	return dupClusters
+}
+
+// Discover which query parameters within a given cluster and path (path
+// meaning subdomain+path) differ, so we know those query parameters don't
+// affect content and can be deleted. Build a map identifying, for each
+// path, which we can delete (ex. the parameter 'foo' in examples above)
+func discoverRemoveableQueryParameters(dupClusters map[int64]map[string]LinkList) map[string]map[string]bool {
+	removableParamsByPath := map[string]map[string]bool{}
+	for _, linksByPath := range dupClusters {
+		for path, links := range linksByPath {
+			removableParams := map[string]bool{}
+			if len(links) <= 1 {
+				continue
+			}
+
+			// Use the first link as baseline for comparison. Any differences
+			// or absent parameters in remaining links will mark that parameter
+			// as removable.
+			compareValues := links[0].URL.Query()
+			for _, l := range links {
+				currentValues := l.URL.Query()
+
+				// First check that all parameters for this link are the same as the comparison
+				for param, vals := range currentValues {
+					if removableParams[param] || l.URL.KeepsQueryParam(param) {
+						continue
+					}
+
+					compareVals, ok := compareValues[param]
+					if !ok || !stringListsEqual(vals, compareVals) {
+						removableParams[param] = true
+						continue
+					}
+				}
+
+				// Then see if this link is missing any parameters that are in the comparison
+				for param := range compareValues {
+					_, ok := currentValues[param]
+					if !ok && !l.URL.KeepsQueryParam(param) {
+						removableParams[param] = true
+						continue
+					}
+				}
+			}
+			if len(removableParams) > 0 {
+				removableParamsByPath[path] = removableParams
+				log4go.Debug("Created parameter removal for subdomain/path %v -- %v", path, removableParams)
+			}
+		}
+	}
+	return removableParamsByPath
+}
+
+// Filter all links, removing parameters, then sort these lists and remove
+// links that are no longer unique. Ex. www.test.com/?foo=bar will turn
+// into www.test.com/, duplicating the other link in the cluster, so one
+// will be removed.
+func filterLinksWithRules(c *SegmentCandidates, removableParamsByPath map[string]map[string]bool) {
+	for _, linkList := range []*LinkList{&c.Uncrawled, &c.Refresh} {
+		for _, l := range *linkList {
+			subdom, err := l.URL.Subdomain()
+			if err != nil {
+				log4go.Error("Dispatcher filtering links could not get subdomain: %v", err)
+				continue
+			}
+			key := subdom + l.URL.Path
+			removableParams := removableParamsByPath[key]
+			if len(removableParams) == 0 {
+				// Most URLs have nothing to remove
+				continue
+			}
+
+			// Remove any parameters marked as removable for this path
+			names := make([]string, 0, len(removableParams))
+			for param := range removableParams {
+				names = append(names, param)
+			}
+			beforeFilter := l.URL.String()
+			l.URL.RawQuery = l.URL.WithoutQueryParams(names...).RawQuery
+			log4go.Debug("Dispatcher filtering parameters, turning %s => %s", beforeFilter, l.URL)
+		}
+		sort.Sort(*linkList)
+		*linkList = linkList.Uniq()
+	}
+}
+
+// Select is documented on the SegmentStrategy interface. Links marked getnow
+// come first, then the rest of the segment is split between not yet crawled
+// links and (oldest first) already crawled ones according to
+// dispatcher.refresh_percentage.
+func (s *DefaultSegmentStrategy) Select(c *SegmentCandidates, limit int) (dispatch, duplicates []*LinkInfo) {
+	start := time.Now()
+
+	dispatch = append(dispatch, c.GetNow...)
+
+	// Create a priority structure out of already-crawled links so we recrawl
+	// the oldest first.
+	crawledPrioritized := &PriorityURL{}
+	heap.Init(crawledPrioritized)
+	for _, l := range c.Refresh {
+		heap.Push(crawledPrioritized, l)
+	}
+
+	// Since we filter query parameters and rewrite links, the crawled and
+	// uncrawled lists could end up with identical links. We use this map to
+	// deduplicate our final segment (keyed by full URL)
+	alreadyAdded := map[string]bool{}
+
+	numRemain := limit - len(dispatch)
+	if numRemain > 0 {
+		refreshDecimal := walker.Config.Dispatcher.RefreshPercentage / 100.0
+		idealCrawled := round(refreshDecimal * float64(numRemain))
+		idealUncrawled := numRemain - idealCrawled
+
+		for i := 0; i < idealUncrawled && len(c.Uncrawled) > 0 && len(dispatch) < limit; i++ {
+			l := c.Uncrawled[0]
+			c.Uncrawled = c.Uncrawled[1:]
+			if alreadyAdded[l.URL.String()] {
+				duplicates = append(duplicates, l)
+				i--
+				continue
+			} else {
+				dispatch = append(dispatch, l)
+				alreadyAdded[l.URL.String()] = true
+			}
+		}
+
+		for i := 0; i < idealCrawled && crawledPrioritized.Len() > 0 && len(dispatch) < limit; i++ {
+			l := heap.Pop(crawledPrioritized).(*LinkInfo)
+			if alreadyAdded[l.URL.String()] {
+				duplicates = append(duplicates, l)
+				i--
+				continue
+			} else {
+				dispatch = append(dispatch, l)
+				alreadyAdded[l.URL.String()] = true
+			}
+		}
+
+		for len(c.Uncrawled) > 0 && len(dispatch) < limit {
+			l := c.Uncrawled[0]
+			c.Uncrawled = c.Uncrawled[1:]
+			if alreadyAdded[l.URL.String()] {
+				duplicates = append(duplicates, l)
+				continue
+			} else {
+				dispatch = append(dispatch, l)
+				alreadyAdded[l.URL.String()] = true
+			}
+		}
+
+		for crawledPrioritized.Len() > 0 && len(dispatch) < limit {
+			l := heap.Pop(crawledPrioritized).(*LinkInfo)
+			if alreadyAdded[l.URL.String()] {
+				duplicates = append(duplicates, l)
+				continue
+			} else {
+				dispatch = append(dispatch, l)
+				alreadyAdded[l.URL.String()] = true
+			}
+		}
+	}
+	log4go.Debug("Build final segment for %v in %v", c.Domain, time.Since(start))
+	return dispatch, duplicates
+}
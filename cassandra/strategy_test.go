@@ -0,0 +1,106 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// lastPathsFirst is a SegmentStrategy dispatching the last paths first, two
+// links at most, and dropping /skip.html
+type lastPathsFirst struct {
+	domains []string
+}
+
+func (s *lastPathsFirst) Filter(c *SegmentCandidates) {
+	s.domains = append(s.domains, c.Domain)
+	var kept LinkList
+	for _, l := range c.Uncrawled {
+		if l.URL.Path != "/skip.html" {
+			kept = append(kept, l)
+		}
+	}
+	c.Uncrawled = kept
+}
+
+func (s *lastPathsFirst) Select(c *SegmentCandidates, limit int) (dispatch, duplicates []*LinkInfo) {
+	for i := len(c.Uncrawled) - 1; i >= 0 && len(dispatch) < 2; i-- {
+		dispatch = append(dispatch, c.Uncrawled[i])
+	}
+	return dispatch, nil
+}
+
+func TestDispatcherSegmentStrategy(t *testing.T) {
+	db := GetTestDB()
+	q := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched)
+					VALUES (?, ?, ?, ?)`, "test.com", gocql.UUID{}, 1, false)
+	if err := q.Exec(); err != nil {
+		t.Fatalf("Failed to insert test domain info: %v\nQuery: %v", err, q)
+	}
+	for _, path := range []string{"/a.html", "/b.html", "/c.html", "/skip.html"} {
+		q = db.Query(`INSERT INTO links (dom, subdom, path, proto, time)
+						VALUES (?, ?, ?, ?, ?)`, "test.com", "", path, "http", walker.NotYetCrawled)
+		if err := q.Exec(); err != nil {
+			t.Fatalf("Failed to insert test link: %v\nQuery: %v", err, q)
+		}
+	}
+
+	d, err := NewDispatcher()
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	if _, ok := d.Strategy.(*DefaultSegmentStrategy); !ok {
+		t.Errorf("Expected the dispatcher to start with a DefaultSegmentStrategy, got %T", d.Strategy)
+	}
+	strategy := &lastPathsFirst{}
+	d.Strategy = strategy
+	if err := d.oneShot(1); err != nil {
+		t.Fatalf("Failed to run dispatcher: %v", err)
+	}
+
+	if len(strategy.domains) != 1 || strategy.domains[0] != "test.com" {
+		t.Errorf("Expected the strategy to filter test.com once, got %v", strategy.domains)
+	}
+	links, err := d.Segments.SegmentLinks(context.Background(), "test.com")
+	if err != nil {
+		t.Fatalf("Failed to read segment: %v", err)
+	}
+	got := map[string]bool{}
+	for _, u := range links {
+		got[u.Path] = true
+	}
+	expected := map[string]bool{"/b.html": true, "/c.html": true}
+	if len(got) != len(expected) {
+		t.Errorf("Expected segment %v, got %v", expected, got)
+	}
+	for path := range expected {
+		if !got[path] {
+			t.Errorf("Expected %v in the segment, got %v", path, got)
+		}
+	}
+}
+
+func TestFilterLinksWithRulesDeduplicates(t *testing.T) {
+	c := &SegmentCandidates{
+		Domain: "test.com",
+		Uncrawled: LinkList{
+			&LinkInfo{URL: walker.MustParse("http://www.test.com/?foo=bar")},
+			&LinkInfo{URL: walker.MustParse("http://www.test.com/")},
+		},
+		Refresh: LinkList{
+			&LinkInfo{URL: walker.MustParse("http://www.test.com/?foo=baz")},
+			&LinkInfo{URL: walker.MustParse("http://www.test.com/?foo=bar")},
+		},
+	}
+	filterLinksWithRules(c, map[string]map[string]bool{"www/": {"foo": true}})
+
+	for name, list := range map[string]LinkList{"Uncrawled": c.Uncrawled, "Refresh": c.Refresh} {
+		if len(list) != 1 || list[0].URL.String() != "http://www.test.com/" {
+			t.Errorf("Expected %v to be deduplicated to http://www.test.com/, got %v", name, list)
+		}
+	}
+}
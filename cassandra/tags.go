@@ -0,0 +1,64 @@
+package cassandra
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// normalizeTags trims tags and drops duplicates, returning an error if one
+// is empty
+func normalizeTags(tags []string) ([]string, error) {
+	var norm []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("Tags must not be empty")
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			norm = append(norm, tag)
+		}
+	}
+	return norm, nil
+}
+
+// TagReports is documented on the ModelDatastore interface.
+func (ds *Datastore) TagReports() ([]*TagReport, error) {
+	reports := map[string]*TagReport{}
+	itr := ds.read(`SELECT tags, dispatched, excluded, tot_links, uncrawled_links, queued_links
+					FROM domain_info`).Iter()
+	var tags []string
+	var dispatched, excluded bool
+	var total, uncrawled, queued int
+	for itr.Scan(&tags, &dispatched, &excluded, &total, &uncrawled, &queued) {
+		for _, tag := range tags {
+			r := reports[tag]
+			if r == nil {
+				r = &TagReport{Tag: tag}
+				reports[tag] = r
+			}
+			r.NumberDomains++
+			if dispatched {
+				r.NumberDomainsDispatched++
+			}
+			if excluded {
+				r.NumberDomainsExcluded++
+			}
+			r.NumberLinksTotal += total
+			r.NumberLinksUncrawled += uncrawled
+			r.NumberLinksQueued += queued
+		}
+	}
+	if err := itr.Close(); err != nil {
+		return nil, fmt.Errorf("domain_info scan failed: %v", err)
+	}
+
+	var list []*TagReport
+	for _, r := range reports {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Tag < list[j].Tag })
+	return list, nil
+}
@@ -0,0 +1,337 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+// WriteStats counts the work done by the write throttle in this process
+type WriteStats struct {
+	// Number of writes that had to wait for the rate limit
+	Throttled int64
+
+	// Total time spent waiting for the rate limit
+	ThrottledTime time.Duration
+
+	// Number of writes retried after a timeout
+	TimeoutRetries int64
+
+	// Number of batches sent
+	Batches int64
+
+	// Number of writes (or batches) that failed, after any retries
+	Failures int64
+}
+
+// String formats WriteStats for logging
+func (s WriteStats) String() string {
+	return fmt.Sprintf("%d throttled writes (%v waiting), %d timeout retries, %d batches, %d failures",
+		s.Throttled, s.ThrottledTime, s.TimeoutRetries, s.Batches, s.Failures)
+}
+
+var writeStats struct {
+	throttled      int64
+	throttledNanos int64
+	timeoutRetries int64
+	batches        int64
+	failures       int64
+}
+
+// CurrentWriteStats returns the write throttle counters accumulated since
+// this process started
+func CurrentWriteStats() WriteStats {
+	return WriteStats{
+		Throttled:      atomic.LoadInt64(&writeStats.throttled),
+		ThrottledTime:  time.Duration(atomic.LoadInt64(&writeStats.throttledNanos)),
+		TimeoutRetries: atomic.LoadInt64(&writeStats.timeoutRetries),
+		Batches:        atomic.LoadInt64(&writeStats.batches),
+		Failures:       atomic.LoadInt64(&writeStats.failures),
+	}
+}
+
+// writeThrottle is a token bucket limiting the rate of mutations sent to
+// cassandra (see cassandra.write_rate_limit in walker.yaml), which also
+// retries writes that time out. A nil *writeThrottle sends everything
+// immediately and never retries.
+type writeThrottle struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second, or 0 for no limit
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	retries int
+	backoff time.Duration
+
+	batchSize int
+}
+
+// newWriteThrottle builds a writeThrottle from the current config
+func newWriteThrottle() *writeThrottle {
+	backoff, err := time.ParseDuration(walker.Config.Cassandra.WriteRetryBackoff)
+	if err != nil {
+		panic(err) // Should not happen since it is parsed at config load
+	}
+	burst := float64(walker.Config.Cassandra.WriteRateBurst)
+	return &writeThrottle{
+		rate:      float64(walker.Config.Cassandra.WriteRateLimit),
+		burst:     burst,
+		tokens:    burst,
+		last:      time.Now(),
+		retries:   walker.Config.Cassandra.WriteTimeoutRetries,
+		backoff:   backoff,
+		batchSize: walker.Config.Cassandra.WriteBatchSize,
+	}
+}
+
+// wait blocks until n more mutations may be sent. Requests larger than the
+// burst are allowed, but borrow against future tokens so the average rate
+// still holds.
+func (t *writeThrottle) wait(ctx context.Context, n int) error {
+	if t == nil || t.rate <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * t.rate
+	if t.tokens > t.burst {
+		t.tokens = t.burst
+	}
+	t.last = now
+	t.tokens -= float64(n)
+	var delay time.Duration
+	if t.tokens < 0 {
+		delay = time.Duration(-t.tokens / t.rate * float64(time.Second))
+	}
+	t.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	atomic.AddInt64(&writeStats.throttled, 1)
+	atomic.AddInt64(&writeStats.throttledNanos, int64(delay))
+	log4go.Fine("Throttling %d cassandra writes for %v", n, delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retry runs write, retrying with backoff and jitter if it times out. Each
+// attempt may be delayed or failed by fault injection (see
+// walker.InjectWriteFault).
+func (t *writeThrottle) retry(ctx context.Context, send func() error) (err error) {
+	defer func() {
+		if err != nil {
+			atomic.AddInt64(&writeStats.failures, 1)
+		}
+	}()
+
+	write := func() error {
+		return sendWrite(ctx, send)
+	}
+
+	err = write()
+	if t == nil {
+		return err
+	}
+	backoff := t.backoff
+	for i := 0; i < t.retries && isTimeout(err); i++ {
+		atomic.AddInt64(&writeStats.timeoutRetries, 1)
+		sleep := backoff
+		if backoff > 0 {
+			sleep += time.Duration(rand.Int63n(int64(backoff)))
+		}
+		log4go.Debug("Cassandra write timed out (%v), retrying in %v", err, sleep)
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+		err = write()
+	}
+	return err
+}
+
+// sendWrite runs send, unless fault injection fails the write (see
+// walker.InjectWriteFault)
+func sendWrite(ctx context.Context, send func() error) error {
+	if walker.InjectWriteFault(ctx) {
+		return gocql.ErrTimeoutNoResponse
+	}
+	return send()
+}
+
+// exec runs a single mutation through the throttle
+func (t *writeThrottle) exec(ctx context.Context, q *gocql.Query) error {
+	if err := t.wait(ctx, 1); err != nil {
+		return err
+	}
+	return t.retry(ctx, func() error {
+		return q.WithContext(ctx).Exec()
+	})
+}
+
+// execCounter runs a counter update through the throttle. Unlike exec it
+// never retries: a counter update that timed out may still have been
+// applied, and applying it again would count it twice.
+func (t *writeThrottle) execCounter(ctx context.Context, q *gocql.Query) error {
+	if err := t.wait(ctx, 1); err != nil {
+		return err
+	}
+	err := sendWrite(ctx, func() error {
+		return q.WithContext(ctx).Exec()
+	})
+	if err != nil {
+		atomic.AddInt64(&writeStats.failures, 1)
+	}
+	return err
+}
+
+// execBatch runs the statements of b through the throttle as one unlogged
+// batch
+func (t *writeThrottle) execBatch(ctx context.Context, db *gocql.Session, b *gocql.Batch) error {
+	if b.Size() == 0 {
+		return nil
+	}
+	if err := t.wait(ctx, b.Size()); err != nil {
+		return err
+	}
+	atomic.AddInt64(&writeStats.batches, 1)
+	return t.retry(ctx, func() error {
+		return db.ExecuteBatch(b.WithContext(ctx))
+	})
+}
+
+// writeBatcher groups statements into unlogged batches of up to size
+// statements (cassandra.write_batch_size unless created with batcherOfSize),
+// sending each batch through the throttle once it is full
+type writeBatcher struct {
+	ctx   context.Context
+	db    *gocql.Session
+	t     *writeThrottle
+	size  int
+	batch *gocql.Batch
+
+	// If set, statements that fail because cassandra is unavailable are
+	// spilled to it instead (see Datastore.spilling)
+	spill  *spillJournal
+	queued []spilledStatement
+}
+
+func (t *writeThrottle) batcher(ctx context.Context, db *gocql.Session) *writeBatcher {
+	size := 1
+	if t != nil {
+		size = t.batchSize
+	}
+	return t.batcherOfSize(ctx, db, size)
+}
+
+// batcherOfSize is like batcher, but with batches of up to size statements
+// instead of cassandra.write_batch_size
+func (t *writeThrottle) batcherOfSize(ctx context.Context, db *gocql.Session, size int) *writeBatcher {
+	return &writeBatcher{ctx: ctx, db: db, t: t, size: size}
+}
+
+// add queues the statement, returning an error if it caused a batch to be
+// sent and that failed. With a batch size of 1 every statement is sent
+// immediately.
+func (wb *writeBatcher) add(stmt string, args ...interface{}) error {
+	if wb.size <= 1 {
+		err := wb.t.exec(wb.ctx, wb.db.Query(stmt, args...))
+		if err != nil && wb.spill != nil {
+			wb.queue(stmt, args)
+			return wb.spillQueued(err)
+		}
+		return err
+	}
+	if wb.batch == nil {
+		wb.batch = wb.db.NewBatch(gocql.UnloggedBatch)
+	}
+	wb.batch.Query(stmt, args...)
+	if wb.spill != nil {
+		wb.queue(stmt, args)
+	}
+	if wb.batch.Size() >= wb.size {
+		return wb.flush()
+	}
+	return nil
+}
+
+// flush sends any statements still queued
+func (wb *writeBatcher) flush() error {
+	if wb.batch == nil {
+		return nil
+	}
+	b := wb.batch
+	wb.batch = nil
+	err := wb.t.execBatch(wb.ctx, wb.db, b)
+	if err != nil && wb.spill != nil {
+		return wb.spillQueued(err)
+	}
+	wb.queued = wb.queued[:0]
+	return err
+}
+
+// queue records a statement sent by this batcher, in case it has to be
+// spilled. A statement that can't be spilled is recorded as an empty one.
+func (wb *writeBatcher) queue(stmt string, args []interface{}) {
+	s, err := newSpilledStatement(stmt, args)
+	if err != nil {
+		log4go.Debug("Statement can't be spilled: %v", err)
+		s = spilledStatement{}
+	}
+	wb.queued = append(wb.queued, s)
+}
+
+// spillQueued spills the statements queued since the last flush, which
+// failed with err, returning nil if they were spilled and err if they can't
+// be (because err is not about cassandra being unavailable, or one of them
+// can't be spilled, or the spill file is full).
+func (wb *writeBatcher) spillQueued(err error) error {
+	// Statements queued from now on go in a new slice, so they can't
+	// overwrite the ones handed to the spill journal
+	queued := wb.queued
+	wb.queued = nil
+	if !isUnavailable(err) {
+		return err
+	}
+	for _, s := range queued {
+		if s.Stmt == "" {
+			return err
+		}
+	}
+	if serr := wb.spill.spill(queued); serr != nil {
+		log4go.Error("Failed to spill %d writes: %v", len(queued), serr)
+		return err
+	}
+	return nil
+}
+
+// isTimeout returns true if err means cassandra (or the driver) gave up
+// waiting for a write to complete
+func isTimeout(err error) bool {
+	switch err.(type) {
+	case *gocql.RequestErrWriteTimeout:
+		return true
+	}
+	return err == gocql.ErrTimeoutNoResponse
+}
@@ -0,0 +1,147 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+)
+
+func TestWriteThrottleRate(t *testing.T) {
+	th := &writeThrottle{rate: 100, burst: 10, tokens: 10, last: time.Now()}
+
+	// The burst should go through without waiting
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := th.wait(context.Background(), 1); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected burst to go through immediately, took %v", elapsed)
+	}
+
+	// The next 20 tokens have to be earned at 100/s
+	before := CurrentWriteStats()
+	start = time.Now()
+	if err := th.wait(context.Background(), 20); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected to be throttled for about 200ms, took %v", elapsed)
+	}
+	if after := CurrentWriteStats(); after.Throttled != before.Throttled+1 {
+		t.Errorf("Expected one throttle event, got %d", after.Throttled-before.Throttled)
+	}
+
+	// A cancelled context should stop the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := th.wait(ctx, 1000); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	var unlimited *writeThrottle
+	if err := unlimited.wait(context.Background(), 1000000); err != nil {
+		t.Errorf("nil throttle should never wait: %v", err)
+	}
+}
+
+func TestWriteThrottleRetry(t *testing.T) {
+	th := &writeThrottle{retries: 3, backoff: time.Millisecond}
+
+	calls := 0
+	err := th.retry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return &gocql.RequestErrWriteTimeout{}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected retry to succeed, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+
+	calls = 0
+	err = th.retry(context.Background(), func() error {
+		calls++
+		return gocql.ErrTimeoutNoResponse
+	})
+	if err != gocql.ErrTimeoutNoResponse {
+		t.Errorf("Expected final timeout error, got %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls (1 + 3 retries), got %d", calls)
+	}
+
+	// Other errors are not retried
+	calls = 0
+	other := errors.New("not a timeout")
+	err = th.retry(context.Background(), func() error {
+		calls++
+		return other
+	})
+	if err != other || calls != 1 {
+		t.Errorf("Expected a single call returning the error, got %d calls and %v", calls, err)
+	}
+}
+
+func TestWriteThrottleInjectedFaults(t *testing.T) {
+	orig := walker.Config.Faults
+	defer func() {
+		walker.Config.Faults = orig
+	}()
+	walker.Config.Faults.Enabled = true
+	walker.Config.Faults.WriteErrorPercent = 100
+
+	th := &writeThrottle{retries: 2, backoff: time.Millisecond}
+	calls := 0
+	err := th.retry(context.Background(), func() error {
+		calls++
+		return nil
+	})
+	if err != gocql.ErrTimeoutNoResponse {
+		t.Errorf("Expected injected faults to fail the write with a timeout, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected a write failed by fault injection not to be sent, got %d calls", calls)
+	}
+
+	walker.Config.Faults.Enabled = false
+	if err := th.retry(context.Background(), func() error { return nil }); err != nil {
+		t.Errorf("Expected no faults to be injected when disabled, got %v", err)
+	}
+}
+
+func TestWriteThrottleCounterNotRetried(t *testing.T) {
+	orig := walker.Config.Faults
+	defer func() {
+		walker.Config.Faults = orig
+	}()
+	walker.Config.Faults.Enabled = true
+	walker.Config.Faults.WriteErrorPercent = 100
+
+	th := &writeThrottle{retries: 3, backoff: time.Millisecond}
+	before := CurrentWriteStats()
+	// Fault injection fails the update before the query is sent
+	err := th.execCounter(context.Background(), &gocql.Query{})
+	if err != gocql.ErrTimeoutNoResponse {
+		t.Errorf("Expected the counter update to time out, got %v", err)
+	}
+	after := CurrentWriteStats()
+	if after.TimeoutRetries != before.TimeoutRetries {
+		t.Errorf("Expected a counter update that timed out not to be retried, got %d retries",
+			after.TimeoutRetries-before.TimeoutRetries)
+	}
+	if after.Failures != before.Failures+1 {
+		t.Errorf("Expected the failed counter update to be counted as a failure")
+	}
+}
@@ -0,0 +1,190 @@
+package cassandra
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/iParadigms/walker"
+)
+
+// trapPatternShare is the fraction of sampled uncrawled links a repeating
+// pattern has to cover to be suggested as a trap
+const trapPatternShare = 0.25
+
+// trapDateRegex finds a date in a link, a year and month and maybe a day,
+// ex. 2031/04 or 2031-04-12
+var trapDateRegex = regexp.MustCompile(`(?:19|20)\d\d[-/](?:0?[1-9]|1[0-2])(?:[-/](?:0?[1-9]|[12]\d|3[01]))?\b`)
+
+// trapDatePattern matches the dates trapDateRegex finds, in trap patterns
+const trapDatePattern = `(?:19|20)\d\d[-/]\d\d?(?:[-/]\d\d?)?`
+
+// trapPageRegex finds a pagination query parameter, ex. ?page=12
+var trapPageRegex = regexp.MustCompile(`(?i)[?&](page|p|pg|offset|start)=\d+`)
+
+// trapPattern returns the exclude pattern a link would fall under if it is part
+// of a calendar or pagination trap: its path up to a date, or its path with a
+// numbered page parameter. It returns "" if the link has neither.
+//
+// A date only counts if it repeats (ex. /cal/2031/04/2031/05, from relative
+// links) or makes up the link's last path segment or a query value (ex.
+// /cal/2031-04-12 or /cal?d=2031-04), since dated articles like
+// /blog/2031/04/launch are not a trap.
+func trapPattern(uri string) string {
+	dates := trapDateRegex.FindAllStringIndex(uri, -1)
+	if len(dates) > 1 {
+		return "^" + regexp.QuoteMeta(uri[:dates[0][0]]) + trapDatePattern + ".*" + trapDatePattern
+	}
+	if len(dates) == 1 {
+		start, end := dates[0][0], dates[0][1]
+		rest := strings.TrimPrefix(uri[end:], "/")
+		if (start == 0 || strings.ContainsAny(uri[start-1:start], "/=")) &&
+			(rest == "" || rest[0] == '?' || rest[0] == '&') {
+			return "^" + regexp.QuoteMeta(uri[:start]) + trapDatePattern + `/?(?:[?&]|$)`
+		}
+	}
+	if m := trapPageRegex.FindStringSubmatchIndex(uri); m != nil {
+		path := uri
+		if i := strings.Index(uri, "?"); i >= 0 {
+			path = uri[:i]
+		}
+		return "^" + regexp.QuoteMeta(path) + `\?(?:.*&)?` + regexp.QuoteMeta(uri[m[2]:m[3]]) + `=\d+`
+	}
+	return ""
+}
+
+// trapPatterns returns the trap patterns (see trapPattern) shared by at least
+// trapPatternShare of uris, most common first
+func trapPatterns(uris []string) []string {
+	counts := map[string]int{}
+	for _, uri := range uris {
+		if p := trapPattern(uri); p != "" {
+			counts[p]++
+		}
+	}
+	var patterns []string
+	for p, n := range counts {
+		if n >= 2 && float64(n) >= trapPatternShare*float64(len(uris)) {
+			patterns = append(patterns, p)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if counts[patterns[i]] != counts[patterns[j]] {
+			return counts[patterns[i]] > counts[patterns[j]]
+		}
+		return patterns[i] < patterns[j]
+	})
+	return patterns
+}
+
+// compileTrapPatterns joins patterns into one regex, or returns nil if there
+// are none or they don't compile
+func compileTrapPatterns(domain string, patterns []string) *regexp.Regexp {
+	if len(patterns) == 0 {
+		return nil
+	}
+	re, err := regexp.Compile("(?:" + strings.Join(patterns, ")|(?:") + ")")
+	if err != nil {
+		log4go.Error("Ignoring trap patterns of %q: %v", domain, err)
+		return nil
+	}
+	return re
+}
+
+// readTrapState reads what the current domain looked like at its last
+// dispatch, and the trap patterns applied to it, if any (see
+// dispatcher.trap_detection)
+func (sg *SegmentGenerator) readTrapState() {
+	var excluded bool
+	err := sg.DB.Query(`SELECT tot_links, uncrawled_links, trap_patterns, trap_accepted, trap_excluded
+						FROM domain_info WHERE dom = ?`, sg.domain).Scan(
+		&sg.prevTotalLinks, &sg.prevUncrawledLinks, &sg.trapPatterns, &sg.trapAccepted, &excluded)
+	if err != nil {
+		log4go.Error("Failed to read trap state of %q: %v", sg.domain, err)
+		return
+	}
+	if excluded {
+		sg.trapExclude = compileTrapPatterns(sg.domain, sg.trapAccepted)
+	}
+}
+
+// trapExcluded returns true if u is an uncrawled link left out of segments by
+// the trap patterns applied to the current domain
+func (sg *SegmentGenerator) trapExcluded(u *walker.URL) bool {
+	if sg.trapExclude == nil || !sg.trapExclude.MatchString(u.RequestURI()) {
+		return false
+	}
+	sg.trapExcludedLinks++
+	return true
+}
+
+// detectTrap flags the current domain as a crawler trap if, since its last
+// dispatch, its uncrawled links grew dispatcher.trap_growth_ratio times
+// faster than its crawled links and the uncrawled links collected share
+// repeating dates or page numbers. The patterns found are added to the
+// domain's trap_patterns, and applied if dispatcher.trap_detection is
+// "exclude". Otherwise they stay suggestions: only the patterns accepted in
+// the console (see ModelDatastore.SetTrapExcluded) are applied.
+func (sg *SegmentGenerator) detectTrap() {
+	dis := walker.Config.Dispatcher
+	mode := strings.ToLower(dis.TrapDetection)
+	if mode == "off" || sg.prevTotalLinks == 0 || sg.uncrawledLinksCount < dis.TrapMinUncrawled {
+		return
+	}
+	uncrawledGrowth := sg.uncrawledLinksCount - sg.prevUncrawledLinks
+	crawledGrowth := (sg.totalLinksCount - sg.uncrawledLinksCount) - (sg.prevTotalLinks - sg.prevUncrawledLinks)
+	if float64(uncrawledGrowth) <= dis.TrapGrowthRatio*math.Max(float64(crawledGrowth), 1) {
+		return
+	}
+
+	uris := make([]string, len(sg.uncrawledLinks))
+	for i, l := range sg.uncrawledLinks {
+		uris[i] = l.URL.RequestURI()
+	}
+	found := trapPatterns(uris)
+	if len(found) == 0 {
+		return
+	}
+	sg.suggestedTrapPatterns = found
+
+	patterns := append([]string{}, sg.trapPatterns...)
+	for _, p := range found {
+		if !containsString(patterns, p) {
+			patterns = append(patterns, p)
+		}
+	}
+	wasExcluded := sg.trapExclude != nil
+	accepted := sg.trapAccepted
+	if mode == "exclude" {
+		accepted = patterns
+	}
+	exclude := mode == "exclude" || wasExcluded
+	if exclude && len(accepted) != len(sg.trapAccepted) {
+		sg.trapExclude = compileTrapPatterns(sg.domain, accepted)
+		kept := LinkList{}
+		for _, l := range sg.uncrawledLinks {
+			if !sg.trapExcluded(l.URL) {
+				kept = append(kept, l)
+			}
+		}
+		sg.uncrawledLinks = kept
+	}
+
+	if len(patterns) == len(sg.trapPatterns) && exclude == wasExcluded && len(accepted) == len(sg.trapAccepted) {
+		log4go.Debug("Domain %v still looks like a crawler trap", sg.domain)
+		return
+	}
+	if sg.dryRun {
+		return
+	}
+	log4go.Warn("Domain %v looks like a crawler trap (%d uncrawled links, %d more than at its last dispatch); "+
+		"suggested exclude patterns: %v", sg.domain, sg.uncrawledLinksCount, uncrawledGrowth, strings.Join(found, " "))
+	err := sg.DB.Query(`UPDATE domain_info SET trap_patterns = ?, trap_accepted = ?, trap_time = ?, trap_excluded = ?
+						WHERE dom = ?`, patterns, accepted, time.Now(), exclude, sg.domain).Exec()
+	if err != nil {
+		log4go.Error("Failed to record trap patterns of %q: %v", sg.domain, err)
+	}
+}
@@ -0,0 +1,214 @@
+// +build cassandra
+
+package cassandra
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+func TestTrapPatterns(t *testing.T) {
+	tests := []struct {
+		tag      string
+		uris     []string
+		expected []string
+	}{
+		{
+			"calendar",
+			[]string{"/events/2031-04-12", "/events/2031-04-13", "/events/2031/5", "/about.html"},
+			[]string{`^/events/(?:19|20)\d\d[-/]\d\d?(?:[-/]\d\d?)?/?(?:[?&]|$)`},
+		},
+		{
+			"calendar query",
+			[]string{"/cal?d=2031-04", "/cal?d=2031-05&view=month", "/cal?d=2031-06"},
+			[]string{`^/cal\?d=(?:19|20)\d\d[-/]\d\d?(?:[-/]\d\d?)?/?(?:[?&]|$)`},
+		},
+		{
+			"repeated dates",
+			[]string{"/cal/2031/04/2031/05", "/cal/2031/04/2031/06", "/cal/2031/05/2031/05/2031/06"},
+			[]string{`^/cal/(?:19|20)\d\d[-/]\d\d?(?:[-/]\d\d?)?.*(?:19|20)\d\d[-/]\d\d?(?:[-/]\d\d?)?`},
+		},
+		{
+			"dated articles",
+			[]string{"/blog/2031/04/launch", "/blog/2031/05/update", "/blog/2031/06/news"},
+			nil,
+		},
+		{
+			"pagination",
+			[]string{"/list?page=2", "/list?sort=new&page=3", "/list?page=4", "/index.html"},
+			[]string{`^/list\?(?:.*&)?page=\d+`},
+		},
+		{
+			"too rare",
+			[]string{"/events/2031-04-12", "/a.html", "/b.html", "/c.html", "/d.html", "/e.html", "/f.html", "/g.html",
+				"/events/2031-04-13"},
+			nil,
+		},
+		{
+			"no dates or pages",
+			[]string{"/article/123456", "/article/123457", "/article/123458"},
+			nil,
+		},
+	}
+	for _, tst := range tests {
+		got := trapPatterns(tst.uris)
+		if !reflect.DeepEqual(got, tst.expected) {
+			t.Errorf("%v: expected patterns %q, got %q", tst.tag, tst.expected, got)
+		}
+	}
+}
+
+func TestDispatcherTrapDetection(t *testing.T) {
+	origDetection := walker.Config.Dispatcher.TrapDetection
+	origMin := walker.Config.Dispatcher.TrapMinUncrawled
+	defer func() {
+		walker.Config.Dispatcher.TrapDetection = origDetection
+		walker.Config.Dispatcher.TrapMinUncrawled = origMin
+	}()
+	walker.Config.Dispatcher.TrapMinUncrawled = 20
+
+	for _, mode := range []string{"off", "suggest", "exclude"} {
+		walker.Config.Dispatcher.TrapDetection = mode
+		db := GetTestDB()
+
+		// At its last dispatch the domain had 10 links, all crawled
+		err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched, tot_links, uncrawled_links)
+						 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false, 10, 0)`, "test.com", MaxPriority).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert domain: %v", err)
+		}
+		crawled := time.Now().Add(-2 * time.Hour)
+		for i := 0; i < 10; i++ {
+			err = db.Query(`INSERT INTO links (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+				"test.com", "", fmt.Sprintf("/page%d.html", i), "http", crawled).Exec()
+			if err != nil {
+				t.Fatalf("Failed to insert link: %v", err)
+			}
+		}
+		for i := 1; i <= 30; i++ {
+			err = db.Query(`INSERT INTO links (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+				"test.com", "", fmt.Sprintf("/calendar/2031-04-%02d", i), "http", walker.NotYetCrawled).Exec()
+			if err != nil {
+				t.Fatalf("Failed to insert link: %v", err)
+			}
+		}
+
+		runDispatcher(t)
+
+		var patterns []string
+		var excluded bool
+		err = db.Query(`SELECT trap_patterns, trap_excluded FROM domain_info WHERE dom = ?`, "test.com").
+			Scan(&patterns, &excluded)
+		if err != nil {
+			t.Fatalf("Failed to read trap patterns: %v", err)
+		}
+		expected := []string{`^/calendar/(?:19|20)\d\d[-/]\d\d?(?:[-/]\d\d?)?/?(?:[?&]|$)`}
+		if mode == "off" {
+			expected = nil
+		}
+		if !reflect.DeepEqual(patterns, expected) {
+			t.Errorf("With trap_detection %q expected trap patterns %q, got %q", mode, expected, patterns)
+		}
+		if excluded != (mode == "exclude") {
+			t.Errorf("With trap_detection %q expected trap_excluded %v, got %v", mode, mode == "exclude", excluded)
+		}
+
+		calendarLinks := 0
+		iter := db.Query(`SELECT path FROM segments WHERE dom = ?`, "test.com").Iter()
+		var path string
+		for iter.Scan(&path) {
+			if strings.HasPrefix(path, "/calendar/") {
+				calendarLinks++
+			}
+		}
+		if err := iter.Close(); err != nil {
+			t.Fatalf("Failed to read segments: %v", err)
+		}
+		if (calendarLinks == 0) != (mode == "exclude") {
+			t.Errorf("With trap_detection %q expected calendar links dispatched == %v, got %d of them",
+				mode, mode != "exclude", calendarLinks)
+		}
+	}
+}
+
+func TestDispatcherTrapAcceptedPatterns(t *testing.T) {
+	origDetection := walker.Config.Dispatcher.TrapDetection
+	origMin := walker.Config.Dispatcher.TrapMinUncrawled
+	defer func() {
+		walker.Config.Dispatcher.TrapDetection = origDetection
+		walker.Config.Dispatcher.TrapMinUncrawled = origMin
+	}()
+	walker.Config.Dispatcher.TrapDetection = "suggest"
+	walker.Config.Dispatcher.TrapMinUncrawled = 20
+	db := GetTestDB()
+
+	// The calendar pattern was accepted in the console
+	calendar := `^/calendar/(?:19|20)\d\d[-/]\d\d?(?:[-/]\d\d?)?/?(?:[?&]|$)`
+	err := db.Query(`INSERT INTO domain_info (dom, claim_tok, priority, dispatched, tot_links, uncrawled_links,
+						trap_patterns, trap_excluded)
+					 VALUES (?, 00000000-0000-0000-0000-000000000000, ?, false, 10, 0, ?, false)`,
+		"test.com", MaxPriority, []string{calendar}).Exec()
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	ds := getDS(t)
+	defer ds.Close()
+	if err := ds.SetTrapExcluded("test.com", true); err != nil {
+		t.Fatalf("SetTrapExcluded failed: %v", err)
+	}
+
+	crawled := time.Now().Add(-2 * time.Hour)
+	for i := 0; i < 10; i++ {
+		err = db.Query(`INSERT INTO links (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+			"test.com", "", fmt.Sprintf("/page%d.html", i), "http", crawled).Exec()
+		if err != nil {
+			t.Fatalf("Failed to insert link: %v", err)
+		}
+	}
+	for i := 1; i <= 30; i++ {
+		for _, path := range []string{fmt.Sprintf("/calendar/2031-04-%02d", i), fmt.Sprintf("/list?page=%d", i)} {
+			err = db.Query(`INSERT INTO links (dom, subdom, path, proto, time) VALUES (?, ?, ?, ?, ?)`,
+				"test.com", "", path, "http", walker.NotYetCrawled).Exec()
+			if err != nil {
+				t.Fatalf("Failed to insert link: %v", err)
+			}
+		}
+	}
+
+	runDispatcher(t)
+
+	dinfo, err := ds.FindDomain("test.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if len(dinfo.TrapPatterns) != 2 {
+		t.Errorf("Expected the pagination pattern to be suggested, got %q", dinfo.TrapPatterns)
+	}
+	if !reflect.DeepEqual(dinfo.TrapAccepted, []string{calendar}) || !dinfo.TrapExcluded {
+		t.Errorf("Expected only the accepted pattern to be applied, got %q (excluded %v)",
+			dinfo.TrapAccepted, dinfo.TrapExcluded)
+	}
+
+	calendarLinks, listLinks := 0, 0
+	iter := db.Query(`SELECT path FROM segments WHERE dom = ?`, "test.com").Iter()
+	var path string
+	for iter.Scan(&path) {
+		if strings.HasPrefix(path, "/calendar/") {
+			calendarLinks++
+		} else if strings.HasPrefix(path, "/list") {
+			listLinks++
+		}
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatalf("Failed to read segments: %v", err)
+	}
+	if calendarLinks != 0 || listLinks == 0 {
+		t.Errorf("Expected only the calendar links to be left out, got %d calendar and %d list links dispatched",
+			calendarLinks, listLinks)
+	}
+}
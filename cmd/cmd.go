@@ -25,12 +25,18 @@ Likewise if you want to set your own Datastore and Dispatcher:
 	}
 
 cmd.Execute() blocks until the program has completed (usually by
-being shutdown gracefully via SIGINT).
+being shutdown gracefully via SIGINT). The crawl and fetch commands also
+accept SIGTERM, which drains the fetchers instead: they finish the hosts
+they are crawling and release all their claims before exiting, which is
+what you want for rolling restarts.
 */
 package cmd
 
 import (
+	"context"
+	"expvar"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -45,6 +51,8 @@ import (
 	"github.com/iParadigms/walker"
 	"github.com/iParadigms/walker/cassandra"
 	"github.com/iParadigms/walker/console"
+	"github.com/iParadigms/walker/eshandler"
+	"github.com/iParadigms/walker/grpc"
 	"github.com/iParadigms/walker/simplehandler"
 	"github.com/spf13/cobra"
 )
@@ -106,12 +114,29 @@ var commander struct {
 // config is potentially set by CLI below
 var config string
 
+// keyspace, if set by CLI below, overrides cassandra.keyspace from the config
+var keyspace string
+
 func initCommand() {
 	if config != "" {
 		if err := walker.ReadConfigFile(config); err != nil {
 			panic(err.Error())
 		}
 	}
+	if keyspace != "" {
+		if !walker.ValidKeyspace(keyspace) {
+			fatalf("Invalid keyspace name %q", keyspace)
+		}
+		walker.Config.Cassandra.Keyspace = keyspace
+	}
+
+	stop, err := walker.StartTracing(context.Background())
+	if err != nil {
+		fatalf("%v", err)
+	}
+	stopTracing = stop
+
+	go reloadConfigOnHangup()
 
 	if os.Getenv("WALKER_PPROF") == "1" {
 		go func() {
@@ -123,6 +148,17 @@ func initCommand() {
 		}()
 	}
 
+	if addr := walker.Config.Metrics.ListenAddress; addr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/debug/vars", expvar.Handler())
+			err := http.ListenAndServe(addr, mux)
+			if err != nil {
+				log4go.Error("Had problem serving metrics on %v: %v", addr, err)
+			}
+		}()
+	}
+
 	// Set default streams
 	if commander.Streams.Printf == nil {
 		commander.Streams.Printf = func(format string, args ...interface{}) {
@@ -141,12 +177,49 @@ func initCommand() {
 	}
 }
 
+// reloadConfigOnHangup reloads the config file each time walker receives
+// SIGHUP (see walker.ReloadConfig)
+func reloadConfigOnHangup() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		if err := walker.ReloadConfig(); err != nil {
+			log4go.Error("Failed to reload config, keeping the current one: %v", err)
+		}
+	}
+}
+
+// stopTracing flushes the spans of the command; see walker.StartTracing
+var stopTracing = func(context.Context) error { return nil }
+
 func fatalf(format string, args ...interface{}) {
 	fmt.Printf(format, args...)
 	fmt.Println()
 	os.Exit(1)
 }
 
+// stopFetchManager stops manager after receiving s: SIGTERM drains it (see
+// FetchManager.Drain), anything else stops it right away. While draining it
+// keeps reading sig, and stops the manager right away on a second signal.
+func stopFetchManager(manager *walker.FetchManager, s os.Signal, sig <-chan os.Signal) {
+	if s != syscall.SIGTERM {
+		manager.Stop()
+		return
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		manager.Drain()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case s = <-sig:
+		log4go.Info("Received %v while draining, stopping now", s)
+		manager.Stop()
+	}
+}
+
 // Options to control the readlink command
 var readLinkLink string
 var readLinkBodyOnly bool
@@ -268,10 +341,17 @@ var readLinkCommand = &cobra.Command{
 func init() {
 	walkerCommand := &cobra.Command{
 		Use: "walker",
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if err := stopTracing(context.Background()); err != nil {
+				log4go.Error("Failed to flush traces: %v", err)
+			}
+		},
 	}
 
 	walkerCommand.PersistentFlags().StringVarP(&config,
 		"config", "c", "", "path to a config file to load")
+	walkerCommand.PersistentFlags().StringVarP(&keyspace,
+		"keyspace", "k", "", "cassandra keyspace of the crawl to use (overrides the config file)")
 
 	var noConsole = false
 	crawlCommand := &cobra.Command{
@@ -292,15 +372,25 @@ func init() {
 				}
 			}
 
+			var esHandler *eshandler.Handler
 			if commander.Handler == nil {
-				commander.Handler = &simplehandler.Handler{}
+				if walker.Config.Elasticsearch.URL != "" {
+					h, err := eshandler.NewHandler()
+					if err != nil {
+						fatalf("Failed creating Elasticsearch handler: %v", err)
+					}
+					esHandler = h
+					commander.Handler = h
+				} else {
+					commander.Handler = &simplehandler.Handler{}
+				}
 			}
 
 			manager := &walker.FetchManager{
 				Datastore: commander.Datastore,
 				Handler:   commander.Handler,
 			}
-			go manager.Start()
+			go manager.Start(context.Background())
 
 			if commander.Dispatcher != nil {
 				go func() {
@@ -315,26 +405,38 @@ func init() {
 				console.Start()
 			}
 
-			sig := make(chan os.Signal)
-			signal.Notify(sig, syscall.SIGINT)
-			<-sig
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+			s := <-sig
 
 			if commander.Dispatcher != nil {
 				commander.Dispatcher.StopDispatcher()
 			}
-			manager.Stop()
+			stopFetchManager(manager, s, sig)
+			if esHandler != nil {
+				esHandler.Close()
+			}
 		},
 	}
 	crawlCommand.Flags().BoolVarP(&noConsole, "no-console", "C", false, "Do not start the console")
 	walkerCommand.AddCommand(crawlCommand)
 
+	var replay = false
+	var replayWARC = ""
+	var urlList = ""
 	fetchCommand := &cobra.Command{
 		Use:   "fetch",
 		Short: "start only a walker fetch manager",
 		Run: func(cmd *cobra.Command, args []string) {
 			initCommand()
 
-			if commander.Datastore == nil {
+			if commander.Datastore == nil && walker.Config.GRPC.DatastoreAddress != "" {
+				ds, err := grpc.NewClient(walker.Config.GRPC.DatastoreAddress)
+				if err != nil {
+					fatalf("Failed creating remote datastore: %v", err)
+				}
+				commander.Datastore = ds
+			} else if commander.Datastore == nil {
 				ds, err := cassandra.NewDatastore()
 				if err != nil {
 					fatalf("Failed creating Cassandra datastore: %v", err)
@@ -346,23 +448,69 @@ func init() {
 				}
 			}
 
+			var esHandler *eshandler.Handler
 			if commander.Handler == nil {
-				commander.Handler = &simplehandler.Handler{}
+				if walker.Config.Elasticsearch.URL != "" {
+					h, err := eshandler.NewHandler()
+					if err != nil {
+						fatalf("Failed creating Elasticsearch handler: %v", err)
+					}
+					esHandler = h
+					commander.Handler = h
+				} else {
+					commander.Handler = &simplehandler.Handler{}
+				}
 			}
 
 			manager := &walker.FetchManager{
 				Datastore: commander.Datastore,
 				Handler:   commander.Handler,
 			}
-			go manager.Start()
-
-			sig := make(chan os.Signal)
-			signal.Notify(sig, syscall.SIGINT)
-			<-sig
+			if replayWARC != "" {
+				src, err := walker.NewWARCSource(replayWARC)
+				if err != nil {
+					fatalf("Failed to load WARC file: %v", err)
+				}
+				manager.Replay = src
+			} else if replay {
+				src, ok := commander.Datastore.(walker.ReplaySource)
+				if !ok {
+					fatalf("Datastore %T does not store responses to replay", commander.Datastore)
+				}
+				manager.Replay = src
+			}
+			if urlList != "" {
+				links, err := walker.ReadURLList(urlList)
+				if err != nil {
+					fatalf("Failed to load URL list: %v", err)
+				}
+				manager.URLList = links
+			}
+			done := make(chan struct{})
+			go func() {
+				manager.Start(context.Background())
+				close(done)
+			}()
 
-			manager.Stop()
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+			select {
+			case s := <-sig:
+				stopFetchManager(manager, s, sig)
+			case <-done:
+				// Only happens with a URL list, once it has all been fetched
+			}
+			if esHandler != nil {
+				esHandler.Close()
+			}
 		},
 	}
+	fetchCommand.Flags().BoolVarP(&replay, "replay", "r", false,
+		"Replay the responses stored in the datastore instead of crawling")
+	fetchCommand.Flags().StringVarP(&replayWARC, "replay-warc", "w", "",
+		"Replay the responses in this WARC file instead of crawling")
+	fetchCommand.Flags().StringVarP(&urlList, "url-list", "l", "",
+		"Fetch exactly the URLs in this file (one per line), then exit")
 	walkerCommand.AddCommand(fetchCommand)
 
 	dispatchCommand := &cobra.Command{
@@ -395,6 +543,46 @@ func init() {
 	}
 	walkerCommand.AddCommand(dispatchCommand)
 
+	datastoreServiceCommand := &cobra.Command{
+		Use:   "datastore-service",
+		Short: "serve the cassandra datastore to remote fetchers",
+		Long: `Datastore-service lets fetchers run on machines that cannot reach
+cassandra: it listens on grpc.listen_address, and fetchers started with
+grpc.datastore_address pointing at it make their datastore calls through it.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			initCommand()
+
+			model, err := cassandra.NewDatastore()
+			if err != nil {
+				fatalf("Failed creating Cassandra datastore: %v", err)
+			}
+			defer model.Close()
+			server, err := grpc.NewServer(model, func() (walker.Datastore, error) {
+				return cassandra.NewDatastore()
+			})
+			if err != nil {
+				fatalf("Failed creating datastore service: %v", err)
+			}
+
+			lis, err := net.Listen("tcp", walker.Config.GRPC.ListenAddress)
+			if err != nil {
+				fatalf("Failed to listen on %v: %v", walker.Config.GRPC.ListenAddress, err)
+			}
+			go func() {
+				if err := server.Serve(lis); err != nil {
+					fatalf("Failed serving datastore: %v", err)
+				}
+			}()
+
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+			<-sig
+
+			server.Stop()
+		},
+	}
+	walkerCommand.AddCommand(datastoreServiceCommand)
+
 	var seedURL string
 	seedCommand := &cobra.Command{
 		Use:   "seed",
@@ -429,7 +617,7 @@ crawl, regardless of the add_new_domains configuration setting.`,
 				commander.Datastore = ds
 			}
 
-			commander.Datastore.StoreParsedURL(u, nil)
+			commander.Datastore.StoreParsedURL(context.Background(), u, nil)
 		},
 	}
 	seedCommand.Flags().StringVarP(&seedURL, "url", "u", "", "URL to add as a seed")
@@ -3,7 +3,12 @@ package walker
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"reflect"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -38,41 +43,119 @@ type ConfigStruct struct {
 	//TODO: allow -1 as a no max value
 
 	Fetcher struct {
-		MaxDNSCacheEntries       int      `yaml:"max_dns_cache_entries"`
-		UserAgent                string   `yaml:"user_agent"`
-		AcceptFormats            []string `yaml:"accept_formats"`
-		AcceptProtocols          []string `yaml:"accept_protocols"`
-		MaxHTTPContentSizeBytes  int64    `yaml:"max_http_content_size_bytes"`
-		IgnoreTags               []string `yaml:"ignore_tags"`
-		MaxLinksPerPage          int      `yaml:"max_links_per_page"`
-		NumSimultaneousFetchers  int      `yaml:"num_simultaneous_fetchers"`
-		BlacklistPrivateIPs      bool     `yaml:"blacklist_private_ips"`
-		HTTPTimeout              string   `yaml:"http_timeout"`
-		HonorMetaNoindex         bool     `yaml:"honor_meta_noindex"`
-		HonorMetaNofollow        bool     `yaml:"honor_meta_nofollow"`
-		ExcludeLinkPatterns      []string `yaml:"exclude_link_patterns"`
-		IncludeLinkPatterns      []string `yaml:"include_link_patterns"`
-		DefaultCrawlDelay        string   `yaml:"default_crawl_delay"`
-		MaxCrawlDelay            string   `yaml:"max_crawl_delay"`
-		PurgeSidList             []string `yaml:"purge_sid_list"`
-		ActiveFetchersTTL        string   `yaml:"active_fetchers_ttl"`
-		ActiveFetchersCacheratio float32  `yaml:"active_fetchers_cacheratio"`
-		ActiveFetchersKeepratio  float32  `yaml:"active_fetchers_keepratio"`
-		HTTPKeepAlive            string   `yaml:"http_keep_alive"`
-		HTTPKeepAliveThreshold   string   `yaml:"http_keep_alive_threshold"`
-		MaxPathLength            int      `yaml:"max_path_length"`
+		MaxDNSCacheEntries       int                          `yaml:"max_dns_cache_entries"`
+		DNSCacheTTL              string                       `yaml:"dns_cache_ttl"`
+		DNSNegativeCacheTTL      string                       `yaml:"dns_negative_cache_ttl"`
+		DNSOverrides             map[string]string            `yaml:"dns_overrides"`
+		UserAgent                string                       `yaml:"user_agent"`
+		UserAgents               []string                     `yaml:"user_agents"`
+		UserAgentRotation        string                       `yaml:"user_agent_rotation"`
+		CrawlIdentity            string                       `yaml:"crawl_identity"`
+		SendCrawlIDHeader        bool                         `yaml:"send_crawl_id_header"`
+		AcceptLanguage           string                       `yaml:"accept_language"`
+		DomainAcceptLanguages    map[string]string            `yaml:"domain_accept_languages"`
+		DomainRobotsAgents       map[string]string            `yaml:"domain_robots_agents"`
+		DomainCredentials        map[string]DomainCredential  `yaml:"domain_credentials"`
+		CredentialsKey           string                       `yaml:"credentials_key"`
+		AcceptFormats            []string                     `yaml:"accept_formats"`
+		SniffContentType         bool                         `yaml:"sniff_content_type"`
+		AcceptProtocols          []string                     `yaml:"accept_protocols"`
+		MaxHTTPContentSizeBytes  int64                        `yaml:"max_http_content_size_bytes"`
+		HeadPrecheckDomains      []string                     `yaml:"head_precheck_domains"`
+		RangeRequestDomains      []string                     `yaml:"range_request_domains"`
+		FaviconDomains           []string                     `yaml:"favicon_domains"`
+		IgnoreTags               []string                     `yaml:"ignore_tags"`
+		MaxLinksPerPage          int                          `yaml:"max_links_per_page"`
+		MaxHTMLNodes             int                          `yaml:"max_html_nodes"`
+		MaxHTMLDepth             int                          `yaml:"max_html_depth"`
+		HostLinkCacheSize        int                          `yaml:"host_link_cache_size"`
+		NumSimultaneousFetchers  int                          `yaml:"num_simultaneous_fetchers"`
+		MinSimultaneousFetchers  int                          `yaml:"min_simultaneous_fetchers"`
+		MaxSimultaneousFetchers  int                          `yaml:"max_simultaneous_fetchers"`
+		AutoscaleInterval        string                       `yaml:"autoscale_interval"`
+		AutoscaleTargetLatency   string                       `yaml:"autoscale_target_latency"`
+		AutoscaleMaxErrorRate    float64                      `yaml:"autoscale_max_error_rate"`
+		HostsPerFetcher          int                          `yaml:"hosts_per_fetcher"`
+		BlacklistPrivateIPs      bool                         `yaml:"blacklist_private_ips"`
+		IPPreference             string                       `yaml:"ip_preference"`
+		LocalAddrs               []string                     `yaml:"local_addrs"`
+		HTTPTimeout              string                       `yaml:"http_timeout"`
+		HonorMetaNoindex         bool                         `yaml:"honor_meta_noindex"`
+		HonorMetaNofollow        bool                         `yaml:"honor_meta_nofollow"`
+		HonorNoarchive           bool                         `yaml:"honor_noarchive"`
+		MetaRefreshAsRedirect    bool                         `yaml:"meta_refresh_as_redirect"`
+		MaxRedirects             int                          `yaml:"max_redirects"`
+		DetectJSRedirects        bool                         `yaml:"detect_js_redirects"`
+		ExtractStructuredData    bool                         `yaml:"extract_structured_data"`
+		Fingerprints             []string                     `yaml:"fingerprints"`
+		RelNofollow              string                       `yaml:"rel_nofollow"`
+		UserinfoURLs             string                       `yaml:"userinfo_urls"`
+		NonstandardPorts         string                       `yaml:"nonstandard_ports"`
+		ExcludeLinkPatterns      []string                     `yaml:"exclude_link_patterns"`
+		IncludeLinkPatterns      []string                     `yaml:"include_link_patterns"`
+		DefaultCrawlDelay        string                       `yaml:"default_crawl_delay"`
+		MaxCrawlDelay            string                       `yaml:"max_crawl_delay"`
+		RobotsUnavailablePeriod  string                       `yaml:"robots_unavailable_period"`
+		MaxTimePerHost           string                       `yaml:"max_time_per_host"`
+		PurgeSidList             []string                     `yaml:"purge_sid_list"`
+		DomainQueryParams        map[string]DomainQueryParams `yaml:"domain_query_params"`
+		ActiveFetchersTTL        string                       `yaml:"active_fetchers_ttl"`
+		ActiveFetchersCacheratio float32                      `yaml:"active_fetchers_cacheratio"`
+		ActiveFetchersKeepratio  float32                      `yaml:"active_fetchers_keepratio"`
+		HTTPKeepAlive            string                       `yaml:"http_keep_alive"`
+		HTTPKeepAliveThreshold   string                       `yaml:"http_keep_alive_threshold"`
+		MaxPathLength            int                          `yaml:"max_path_length"`
+		HandlerRetries           int                          `yaml:"handler_retries"`
+		HandlerRetryDelay        string                       `yaml:"handler_retry_delay"`
+		HandlerAckTimeout        string                       `yaml:"handler_ack_timeout"`
+		DeadLetterFile           string                       `yaml:"dead_letter_file"`
+		HostCompleteWebhook      string                       `yaml:"host_complete_webhook"`
+		TransientRetryBackoff    string                       `yaml:"transient_retry_backoff"`
+		TransientRetryMaxBackoff string                       `yaml:"transient_retry_max_backoff"`
 	} `yaml:"fetcher"`
 
 	Dispatcher struct {
-		MaxLinksPerSegment         int     `yaml:"num_links_per_segment"`
-		RefreshPercentage          float64 `yaml:"refresh_percentage"`
-		NumConcurrentDomains       int     `yaml:"num_concurrent_domains"`
-		MinLinkRefreshTime         string  `yaml:"min_link_refresh_time"`
-		DispatchInterval           string  `yaml:"dispatch_interval"`
-		CorrectLinkNormalization   bool    `yaml:"correct_link_normalization"`
-		EmptyDispatchRetryInterval string  `yaml:"empty_dispatch_retry_interval"`
+		MaxLinksPerSegment         int               `yaml:"num_links_per_segment"`
+		RefreshPercentage          float64           `yaml:"refresh_percentage"`
+		NumConcurrentDomains       int               `yaml:"num_concurrent_domains"`
+		ScanShards                 int               `yaml:"scan_shards"`
+		MinLinkRefreshTime         string            `yaml:"min_link_refresh_time"`
+		StatusRefreshTimes         map[string]string `yaml:"status_refresh_times"`
+		DispatchInterval           string            `yaml:"dispatch_interval"`
+		CorrectLinkNormalization   bool              `yaml:"correct_link_normalization"`
+		EmptyDispatchRetryInterval string            `yaml:"empty_dispatch_retry_interval"`
+		DomainByteBudget           int64             `yaml:"domain_byte_budget"`
+		DomainByteBudgetWindow     string            `yaml:"domain_byte_budget_window"`
+		HonorCacheHeaders          bool              `yaml:"honor_cache_headers"`
+		MaxCacheRefetchDelay       string            `yaml:"max_cache_refetch_delay"`
+		RewritePermanentRedirects  bool              `yaml:"rewrite_permanent_redirects"`
+		PermanentRedirectCrawls    int               `yaml:"permanent_redirect_crawls"`
+		RedirectLoopCooldown       string            `yaml:"redirect_loop_cooldown"`
+		MaxPriorityRepairInterval  string            `yaml:"max_priority_repair_interval"`
+		HistoryGCInterval          string            `yaml:"history_gc_interval"`
+		ProbeNewDomains            bool              `yaml:"probe_new_domains"`
+		ProbeTimeout               string            `yaml:"probe_timeout"`
+		TrapDetection              string            `yaml:"trap_detection"`
+		TrapMinUncrawled           int               `yaml:"trap_min_uncrawled"`
+		TrapGrowthRatio            float64           `yaml:"trap_growth_ratio"`
 	} `yaml:"dispatcher"`
 
+	Alerts struct {
+		WebhookURL                string   `yaml:"webhook_url"`
+		SlackWebhookURL           string   `yaml:"slack_webhook_url"`
+		EmailSMTPServer           string   `yaml:"email_smtp_server"`
+		EmailFrom                 string   `yaml:"email_from"`
+		EmailTo                   []string `yaml:"email_to"`
+		RepeatInterval            string   `yaml:"repeat_interval"`
+		DomainErrorRate           float64  `yaml:"domain_error_rate"`
+		DomainErrorRateMinFetches int      `yaml:"domain_error_rate_min_fetches"`
+		DomainErrorRateWindow     string   `yaml:"domain_error_rate_window"`
+		EmptyDispatchCycles       int      `yaml:"empty_dispatch_cycles"`
+		FetcherTokenLost          bool     `yaml:"fetcher_token_lost"`
+		WriteFailures             int      `yaml:"write_failures"`
+		RobotsChanged             bool     `yaml:"robots_changed"`
+	} `yaml:"alerts"`
+
 	Cassandra struct {
 		Hosts                 []string `yaml:"hosts"`
 		Keyspace              string   `yaml:"keyspace"`
@@ -85,12 +168,51 @@ type ConfigStruct struct {
 		NumStreams            int      `yaml:"num_streams"`
 		DiscoverHosts         bool     `yaml:"discover_hosts"`
 		MaxPreparedStmts      int      `yaml:"max_prepared_stmts"`
+		ConnectTimeout        string   `yaml:"connect_timeout"`
+		Consistency           string   `yaml:"consistency"`
+		ReadConsistency       string   `yaml:"read_consistency"`
+		CounterConsistency    string   `yaml:"counter_consistency"`
+		SerialConsistency     string   `yaml:"serial_consistency"`
+		HostSelectionPolicy   string   `yaml:"host_selection_policy"`
+		LocalDC               string   `yaml:"local_dc"`
+		SpeculativeAttempts   int      `yaml:"speculative_attempts"`
+		SpeculativeDelay      string   `yaml:"speculative_delay"`
 		AddNewDomains         bool     `yaml:"add_new_domains"`
+		TrackPendingDomains   bool     `yaml:"track_pending_domains"`
 		AddedDomainsCacheSize int      `yaml:"added_domains_cache_size"`
 		StoreResponseBody     bool     `yaml:"store_response_body"`
+		BodyCompression       string   `yaml:"body_compression"`
 		StoreResponseHeaders  bool     `yaml:"store_response_headers"`
+		StoreRequestHeaders   bool     `yaml:"store_request_headers"`
+		StoreFetchTiming      bool     `yaml:"store_fetch_timing"`
+		StoreTLSInfo          bool     `yaml:"store_tls_info"`
+		StoreStructuredData   bool     `yaml:"store_structured_data"`
+		IndexFingerprints     bool     `yaml:"index_fingerprints"`
+		StoreReferrers        bool     `yaml:"store_referrers"`
+		ReferrerCacheSize     int      `yaml:"referrer_cache_size"`
+		StorePolitenessAudit  bool     `yaml:"store_politeness_audit"`
 		NumQueryRetries       int      `yaml:"num_query_retries"`
 		DefaultDomainPriority int      `yaml:"default_domain_priority"`
+		WriteRateLimit        int      `yaml:"write_rate_limit"`
+		WriteRateBurst        int      `yaml:"write_rate_burst"`
+		WriteBatchSize        int      `yaml:"write_batch_size"`
+		ParsedLinkBatchSize   int      `yaml:"parsed_link_batch_size"`
+		WriteTimeoutRetries   int      `yaml:"write_timeout_retries"`
+		WriteRetryBackoff     string   `yaml:"write_retry_backoff"`
+		SpillFile             string   `yaml:"spill_file"`
+		SpillMaxBytes         int64    `yaml:"spill_max_bytes"`
+		SpillReplayInterval   string   `yaml:"spill_replay_interval"`
+		SpillReplayRate       int      `yaml:"spill_replay_rate"`
+		ClaimStrategy         string   `yaml:"claim_strategy"`
+		HostAffinity          bool     `yaml:"host_affinity"`
+		HostAffinityWindow    string   `yaml:"host_affinity_window"`
+		HostAffinityGrace     string   `yaml:"host_affinity_grace"`
+		SampleThreshold       int      `yaml:"sample_threshold"`
+		SamplePercent         float64  `yaml:"sample_percent"`
+		SegmentStore          string   `yaml:"segment_store"`
+		HistoryMaxCrawls      int      `yaml:"history_max_crawls"`
+		HistoryMaxAge         string   `yaml:"history_max_age"`
+		HistoryGCRate         int      `yaml:"history_gc_rate"`
 
 		//TODO: Currently only exposing values needed for testing; should expose more?
 		//Consistency      Consistency
@@ -107,12 +229,76 @@ type ConfigStruct struct {
 		TemplateDirectory        string `yaml:"template_directory"`
 		PublicFolder             string `yaml:"public_folder"`
 		MaxAllowedDomainPriority int    `yaml:"max_allowed_domain_priority"`
+		DashboardRefresh         string `yaml:"dashboard_refresh"`
+		MaxSortedLinks           int    `yaml:"max_sorted_links"`
 	} `yaml:"console"`
+
+	GRPC struct {
+		ListenAddress    string `yaml:"listen_address"`
+		DatastoreAddress string `yaml:"datastore_address"`
+		CallTimeout      string `yaml:"call_timeout"`
+		TLSCertFile      string `yaml:"tls_cert_file"`
+		TLSKeyFile       string `yaml:"tls_key_file"`
+		TLS              bool   `yaml:"tls"`
+		TLSCAFile        string `yaml:"tls_ca_file"`
+		AuthToken        string `yaml:"auth_token"`
+	} `yaml:"grpc"`
+
+	Elasticsearch struct {
+		URL           string `yaml:"url"`
+		Index         string `yaml:"index"`
+		Username      string `yaml:"username"`
+		Password      string `yaml:"password"`
+		MappingsFile  string `yaml:"mappings_file"`
+		BulkSize      int    `yaml:"bulk_size"`
+		FlushInterval string `yaml:"flush_interval"`
+		QueueSize     int    `yaml:"queue_size"`
+		MaxRetries    int    `yaml:"max_retries"`
+		RetryBackoff  string `yaml:"retry_backoff"`
+		MaxTextBytes  int    `yaml:"max_text_bytes"`
+	} `yaml:"elasticsearch"`
+
+	Redis struct {
+		Address      string `yaml:"address"`
+		Password     string `yaml:"password"`
+		Database     int    `yaml:"database"`
+		KeyPrefix    string `yaml:"key_prefix"`
+		Timeout      string `yaml:"timeout"`
+		MaxIdleConns int    `yaml:"max_idle_conns"`
+	} `yaml:"redis"`
+
+	Faults struct {
+		Enabled              bool    `yaml:"enabled"`
+		FetchDelayPercent    float64 `yaml:"fetch_delay_percent"`
+		FetchDelay           string  `yaml:"fetch_delay"`
+		FetchErrorPercent    float64 `yaml:"fetch_error_percent"`
+		FetchTruncatePercent float64 `yaml:"fetch_truncate_percent"`
+		WriteDelayPercent    float64 `yaml:"write_delay_percent"`
+		WriteDelay           string  `yaml:"write_delay"`
+		WriteErrorPercent    float64 `yaml:"write_error_percent"`
+	} `yaml:"faults"`
+
+	Tracing struct {
+		Exporter    string  `yaml:"exporter"`
+		Endpoint    string  `yaml:"endpoint"`
+		Insecure    bool    `yaml:"insecure"`
+		ServiceName string  `yaml:"service_name"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+
+	Metrics struct {
+		ListenAddress string `yaml:"listen_address"`
+	} `yaml:"metrics"`
 }
 
 // SetDefaultConfig resets the Config object to default values, regardless of
 // what was set by any configuration file.
 func SetDefaultConfig() {
+	setDefaultConfig(&Config)
+}
+
+// setDefaultConfig sets every setting of c to its default value
+func setDefaultConfig(c *ConfigStruct) {
 	// NOTE: go-yaml has a bug where it does not overwrite sequence values
 	// (i.e. lists), it appends to them.
 	// See https://github.com/go-yaml/yaml/issues/48
@@ -120,60 +306,222 @@ func SetDefaultConfig() {
 	// nil it and then fill in the default value if yaml.Unmarshal did not fill
 	// anything in
 
-	Config.Fetcher.MaxDNSCacheEntries = 20000
-	Config.Fetcher.UserAgent = "Walker (http://github.com/iParadigms/walker)"
-	Config.Fetcher.AcceptFormats = []string{"text/html", "text/*;"} //NOTE you can add quality factors by doing "text/html; q=0.4"
-	Config.Fetcher.AcceptProtocols = []string{"http", "https"}
-	Config.Fetcher.MaxHTTPContentSizeBytes = 20 * 1024 * 1024 // 20MB
-	Config.Fetcher.IgnoreTags = []string{"script", "img", "link"}
-	Config.Fetcher.MaxLinksPerPage = 1000
-	Config.Fetcher.NumSimultaneousFetchers = 10
-	Config.Fetcher.BlacklistPrivateIPs = true
-	Config.Fetcher.HTTPTimeout = "30s"
-	Config.Fetcher.HonorMetaNoindex = true
-	Config.Fetcher.HonorMetaNofollow = false
-	Config.Fetcher.ExcludeLinkPatterns = nil
-	Config.Fetcher.IncludeLinkPatterns = nil
-	Config.Fetcher.DefaultCrawlDelay = "1s"
-	Config.Fetcher.MaxCrawlDelay = "5m"
-	Config.Fetcher.PurgeSidList = nil
-	Config.Fetcher.ActiveFetchersTTL = "15m"
-	Config.Fetcher.ActiveFetchersCacheratio = 0.75
-	Config.Fetcher.ActiveFetchersKeepratio = 0.75
-	Config.Fetcher.HTTPKeepAlive = "always"
-	Config.Fetcher.HTTPKeepAliveThreshold = "15s"
-	Config.Fetcher.MaxPathLength = 2048
-
-	Config.Dispatcher.MaxLinksPerSegment = 500
-	Config.Dispatcher.RefreshPercentage = 25
-	Config.Dispatcher.NumConcurrentDomains = 1
-	Config.Dispatcher.MinLinkRefreshTime = "0s"
-	Config.Dispatcher.DispatchInterval = "10s"
-	Config.Dispatcher.CorrectLinkNormalization = false
-	Config.Dispatcher.EmptyDispatchRetryInterval = "0s"
-
-	Config.Cassandra.Hosts = []string{"localhost"}
-	Config.Cassandra.Keyspace = "walker"
-	Config.Cassandra.ReplicationFactor = 3
-	Config.Cassandra.Timeout = "2s"
-	Config.Cassandra.CQLVersion = "3.0.0"
-	Config.Cassandra.ProtoVersion = 2
-	Config.Cassandra.Port = 9042
-	Config.Cassandra.NumConns = 2
-	Config.Cassandra.NumStreams = 128
-	Config.Cassandra.DiscoverHosts = false
-	Config.Cassandra.MaxPreparedStmts = 1000
-	Config.Cassandra.AddNewDomains = false
-	Config.Cassandra.AddedDomainsCacheSize = 20000
-	Config.Cassandra.StoreResponseBody = false
-	Config.Cassandra.StoreResponseHeaders = false
-	Config.Cassandra.NumQueryRetries = 3
-	Config.Cassandra.DefaultDomainPriority = 1
-
-	Config.Console.Port = 3000
-	Config.Console.TemplateDirectory = "console/templates"
-	Config.Console.PublicFolder = "console/public"
-	Config.Console.MaxAllowedDomainPriority = 100
+	c.Fetcher.MaxDNSCacheEntries = 20000
+	c.Fetcher.DNSCacheTTL = "5m"
+	c.Fetcher.DNSNegativeCacheTTL = "5m"
+	c.Fetcher.DNSOverrides = nil
+	c.Fetcher.UserAgent = "Walker (http://github.com/iParadigms/walker)"
+	c.Fetcher.CrawlIdentity = ""
+	c.Fetcher.SendCrawlIDHeader = false
+	c.Fetcher.UserAgents = nil
+	c.Fetcher.UserAgentRotation = "round_robin"
+	c.Fetcher.AcceptLanguage = ""
+	c.Fetcher.DomainAcceptLanguages = nil
+	c.Fetcher.DomainRobotsAgents = nil
+	c.Fetcher.DomainCredentials = nil
+	c.Fetcher.CredentialsKey = ""
+	c.Fetcher.AcceptFormats = []string{"text/html", "text/*;"} //NOTE you can add quality factors by doing "text/html; q=0.4"
+	c.Fetcher.SniffContentType = false
+	c.Fetcher.AcceptProtocols = []string{"http", "https"}
+	c.Fetcher.MaxHTTPContentSizeBytes = 20 * 1024 * 1024 // 20MB
+	c.Fetcher.HeadPrecheckDomains = nil
+	c.Fetcher.RangeRequestDomains = nil
+	c.Fetcher.FaviconDomains = nil
+	c.Fetcher.IgnoreTags = []string{"script", "img", "link"}
+	c.Fetcher.MaxLinksPerPage = 1000
+	c.Fetcher.MaxHTMLNodes = 500000
+	c.Fetcher.MaxHTMLDepth = 1000
+	c.Fetcher.HostLinkCacheSize = 10000
+	c.Fetcher.NumSimultaneousFetchers = 10
+	c.Fetcher.MinSimultaneousFetchers = 1
+	c.Fetcher.MaxSimultaneousFetchers = 0
+	c.Fetcher.AutoscaleInterval = "1m"
+	c.Fetcher.AutoscaleTargetLatency = "100ms"
+	c.Fetcher.AutoscaleMaxErrorRate = 0.01
+	c.Fetcher.HostsPerFetcher = 1
+	c.Fetcher.BlacklistPrivateIPs = true
+	c.Fetcher.IPPreference = "happy_eyeballs"
+	c.Fetcher.LocalAddrs = []string{}
+	c.Fetcher.HTTPTimeout = "30s"
+	c.Fetcher.HonorMetaNoindex = true
+	c.Fetcher.HonorMetaNofollow = false
+	c.Fetcher.HonorNoarchive = true
+	c.Fetcher.MetaRefreshAsRedirect = false
+	c.Fetcher.MaxRedirects = 10
+	c.Fetcher.DetectJSRedirects = false
+	c.Fetcher.ExtractStructuredData = false
+	c.Fetcher.Fingerprints = []string{FingerprintFNV}
+	c.Fetcher.RelNofollow = "flag"
+	c.Fetcher.UserinfoURLs = "keep"
+	c.Fetcher.NonstandardPorts = "crawl"
+	c.Fetcher.ExcludeLinkPatterns = nil
+	c.Fetcher.IncludeLinkPatterns = nil
+	c.Fetcher.DefaultCrawlDelay = "1s"
+	c.Fetcher.MaxCrawlDelay = "5m"
+	c.Fetcher.RobotsUnavailablePeriod = "30m"
+	c.Fetcher.MaxTimePerHost = "0s"
+	c.Fetcher.PurgeSidList = nil
+	c.Fetcher.DomainQueryParams = nil
+	c.Fetcher.ActiveFetchersTTL = "15m"
+	c.Fetcher.ActiveFetchersCacheratio = 0.75
+	c.Fetcher.ActiveFetchersKeepratio = 0.75
+	c.Fetcher.HTTPKeepAlive = "always"
+	c.Fetcher.HTTPKeepAliveThreshold = "15s"
+	c.Fetcher.MaxPathLength = 2048
+	c.Fetcher.HandlerRetries = 0
+	c.Fetcher.HandlerRetryDelay = "1s"
+	c.Fetcher.HandlerAckTimeout = "1m"
+	c.Fetcher.DeadLetterFile = ""
+	c.Fetcher.HostCompleteWebhook = ""
+	c.Fetcher.TransientRetryBackoff = "1m"
+	c.Fetcher.TransientRetryMaxBackoff = "24h"
+
+	c.Dispatcher.MaxLinksPerSegment = 500
+	c.Dispatcher.RefreshPercentage = 25
+	c.Dispatcher.NumConcurrentDomains = 1
+	c.Dispatcher.ScanShards = 1
+	c.Dispatcher.MinLinkRefreshTime = "0s"
+	c.Dispatcher.StatusRefreshTimes = nil
+	c.Dispatcher.DispatchInterval = "10s"
+	c.Dispatcher.CorrectLinkNormalization = false
+	c.Dispatcher.EmptyDispatchRetryInterval = "0s"
+	c.Dispatcher.DomainByteBudget = 0
+	c.Dispatcher.DomainByteBudgetWindow = "24h"
+	c.Dispatcher.HonorCacheHeaders = true
+	c.Dispatcher.MaxCacheRefetchDelay = "720h"
+	c.Dispatcher.RewritePermanentRedirects = false
+	c.Dispatcher.PermanentRedirectCrawls = 2
+	c.Dispatcher.RedirectLoopCooldown = "168h"
+	c.Dispatcher.MaxPriorityRepairInterval = "1h"
+	c.Dispatcher.HistoryGCInterval = "0s"
+	c.Dispatcher.ProbeNewDomains = false
+	c.Dispatcher.ProbeTimeout = "10s"
+	c.Dispatcher.TrapDetection = "suggest"
+	c.Dispatcher.TrapMinUncrawled = 1000
+	c.Dispatcher.TrapGrowthRatio = 10
+
+	c.Alerts.WebhookURL = ""
+	c.Alerts.SlackWebhookURL = ""
+	c.Alerts.EmailSMTPServer = ""
+	c.Alerts.EmailFrom = ""
+	c.Alerts.EmailTo = nil
+	c.Alerts.RepeatInterval = "1h"
+	c.Alerts.DomainErrorRate = 0
+	c.Alerts.DomainErrorRateMinFetches = 20
+	c.Alerts.DomainErrorRateWindow = "1h"
+	c.Alerts.EmptyDispatchCycles = 0
+	c.Alerts.FetcherTokenLost = true
+	c.Alerts.WriteFailures = 0
+	c.Alerts.RobotsChanged = false
+
+	c.Cassandra.Hosts = []string{"localhost"}
+	c.Cassandra.Keyspace = "walker"
+	c.Cassandra.ReplicationFactor = 3
+	c.Cassandra.Timeout = "2s"
+	c.Cassandra.CQLVersion = "3.0.0"
+	c.Cassandra.ProtoVersion = 2
+	c.Cassandra.Port = 9042
+	c.Cassandra.NumConns = 2
+	c.Cassandra.NumStreams = 128
+	c.Cassandra.DiscoverHosts = false
+	c.Cassandra.MaxPreparedStmts = 1000
+	c.Cassandra.ConnectTimeout = "600ms"
+	c.Cassandra.Consistency = "quorum"
+	c.Cassandra.ReadConsistency = "quorum"
+	c.Cassandra.CounterConsistency = "quorum"
+	c.Cassandra.SerialConsistency = "serial"
+	c.Cassandra.HostSelectionPolicy = "round_robin"
+	c.Cassandra.LocalDC = ""
+	c.Cassandra.SpeculativeAttempts = 0
+	c.Cassandra.SpeculativeDelay = "100ms"
+	c.Cassandra.AddNewDomains = false
+	c.Cassandra.TrackPendingDomains = false
+	c.Cassandra.AddedDomainsCacheSize = 20000
+	c.Cassandra.StoreResponseBody = false
+	c.Cassandra.BodyCompression = "none"
+	c.Cassandra.StoreResponseHeaders = false
+	c.Cassandra.StoreRequestHeaders = false
+	c.Cassandra.StoreFetchTiming = false
+	c.Cassandra.StoreTLSInfo = false
+	c.Cassandra.StoreStructuredData = false
+	c.Cassandra.IndexFingerprints = false
+	c.Cassandra.StoreReferrers = false
+	c.Cassandra.ReferrerCacheSize = 20000
+	c.Cassandra.StorePolitenessAudit = true
+	c.Cassandra.NumQueryRetries = 3
+	c.Cassandra.DefaultDomainPriority = 1
+	c.Cassandra.WriteRateLimit = 0
+	c.Cassandra.WriteRateBurst = 100
+	c.Cassandra.WriteBatchSize = 1
+	c.Cassandra.ParsedLinkBatchSize = 50
+	c.Cassandra.WriteTimeoutRetries = 3
+	c.Cassandra.WriteRetryBackoff = "200ms"
+	c.Cassandra.SpillFile = ""
+	c.Cassandra.SpillMaxBytes = 256 * 1024 * 1024 // 256MB
+	c.Cassandra.SpillReplayInterval = "30s"
+	c.Cassandra.SpillReplayRate = 100
+	c.Cassandra.ClaimStrategy = "token_order"
+	c.Cassandra.HostAffinity = false
+	c.Cassandra.HostAffinityWindow = "1h"
+	c.Cassandra.HostAffinityGrace = "30s"
+	c.Cassandra.SampleThreshold = 0
+	c.Cassandra.SamplePercent = 100.0
+	c.Cassandra.SegmentStore = "cassandra"
+	c.Cassandra.HistoryMaxCrawls = 0
+	c.Cassandra.HistoryMaxAge = "0s"
+	c.Cassandra.HistoryGCRate = 100
+
+	c.Console.Port = 3000
+	c.Console.TemplateDirectory = "console/templates"
+	c.Console.PublicFolder = "console/public"
+	c.Console.MaxAllowedDomainPriority = 100
+	c.Console.DashboardRefresh = "30s"
+	c.Console.MaxSortedLinks = 100000
+
+	c.GRPC.ListenAddress = "127.0.0.1:3001"
+	c.GRPC.DatastoreAddress = ""
+	c.GRPC.CallTimeout = "30s"
+	c.GRPC.TLSCertFile = ""
+	c.GRPC.TLSKeyFile = ""
+	c.GRPC.TLS = false
+	c.GRPC.TLSCAFile = ""
+	c.GRPC.AuthToken = ""
+
+	c.Elasticsearch.URL = ""
+	c.Elasticsearch.Index = "walker"
+	c.Elasticsearch.Username = ""
+	c.Elasticsearch.Password = ""
+	c.Elasticsearch.MappingsFile = ""
+	c.Elasticsearch.BulkSize = 500
+	c.Elasticsearch.FlushInterval = "5s"
+	c.Elasticsearch.QueueSize = 10000
+	c.Elasticsearch.MaxRetries = 3
+	c.Elasticsearch.RetryBackoff = "1s"
+	c.Elasticsearch.MaxTextBytes = 1048576
+
+	c.Redis.Address = ""
+	c.Redis.Password = ""
+	c.Redis.Database = 0
+	c.Redis.KeyPrefix = "walker:"
+	c.Redis.Timeout = "5s"
+	c.Redis.MaxIdleConns = 8
+
+	c.Faults.Enabled = false
+	c.Faults.FetchDelayPercent = 0
+	c.Faults.FetchDelay = "5s"
+	c.Faults.FetchErrorPercent = 0
+	c.Faults.FetchTruncatePercent = 0
+	c.Faults.WriteDelayPercent = 0
+	c.Faults.WriteDelay = "1s"
+	c.Faults.WriteErrorPercent = 0
+
+	c.Tracing.Exporter = ""
+	c.Tracing.Endpoint = "localhost:4317"
+	c.Tracing.Insecure = true
+	c.Tracing.ServiceName = "walker"
+	c.Tracing.SampleRatio = 1.0
+
+	c.Metrics.ListenAddress = ""
 }
 
 // ReadConfigFile sets a new path to find the walker yaml config file and
@@ -191,11 +539,41 @@ func MustReadConfigFile(path string) {
 	}
 }
 
-func assertConfigInvariants() error {
+// validStatusRefreshKey returns true if key can be used in
+// dispatcher.status_refresh_times: an HTTP status code (like 404), a class of
+// them (like 5xx), or "error" for fetches that got no response
+func validStatusRefreshKey(key string) bool {
+	if key == "error" {
+		return true
+	}
+	if len(key) != 3 || key[0] < '1' || key[0] > '5' {
+		return false
+	}
+	if key[1:] == "xx" {
+		return true
+	}
+	for _, c := range key[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var keyspaceRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,47}$`)
+
+// ValidKeyspace returns true if keyspace is usable as a cassandra keyspace
+// name (alphanumerics and underscores, starting with a letter, at most 48
+// characters)
+func ValidKeyspace(keyspace string) bool {
+	return keyspaceRegex.MatchString(keyspace)
+}
+
+func assertConfigInvariants(c *ConfigStruct) error {
 	var errs []string
 	var err error
 
-	dis := &Config.Dispatcher
+	dis := &c.Dispatcher
 	if dis.RefreshPercentage < 0.0 || dis.RefreshPercentage > 100.0 {
 		errs = append(errs, "Dispatcher.RefreshPercentage must be a floating point number b/w 0 and 100")
 	}
@@ -205,10 +583,23 @@ func assertConfigInvariants() error {
 	if dis.NumConcurrentDomains < 1 {
 		errs = append(errs, "Dispatcher.NumConcurrentDomains must be greater than 0")
 	}
+	if dis.ScanShards < 1 {
+		errs = append(errs, "Dispatcher.ScanShards must be greater than 0")
+	}
 	_, err = time.ParseDuration(dis.MinLinkRefreshTime)
 	if err != nil {
 		errs = append(errs, fmt.Sprintf("Dispatcher.MinLinkRefreshTime failed to parse: %v", err))
 	}
+	for status, refresh := range dis.StatusRefreshTimes {
+		if !validStatusRefreshKey(status) {
+			errs = append(errs, fmt.Sprintf("Dispatcher.StatusRefreshTimes key %q is not a status code, "+
+				"class (like 5xx) or \"error\"", status))
+		}
+		_, err = time.ParseDuration(refresh)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("Dispatcher.StatusRefreshTimes for %q failed to parse: %v", status, err))
+		}
+	}
 	_, err = time.ParseDuration(dis.DispatchInterval)
 	if err != nil {
 		errs = append(errs, fmt.Sprintf("Dispatcher.DispatchInterval failed to parse: %v", err))
@@ -217,8 +608,88 @@ func assertConfigInvariants() error {
 	if err != nil {
 		errs = append(errs, fmt.Sprintf("Dispatcher.EmptyDispatchRetryInterval failed to parse: %v", err))
 	}
+	if dis.DomainByteBudget < 0 {
+		errs = append(errs, "Dispatcher.DomainByteBudget must be >= 0")
+	}
+	budgetWindow, err := time.ParseDuration(dis.DomainByteBudgetWindow)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Dispatcher.DomainByteBudgetWindow failed to parse: %v", err))
+	} else if budgetWindow <= 0 {
+		errs = append(errs, "Dispatcher.DomainByteBudgetWindow must be > 0")
+	}
+	maxCacheDelay, err := time.ParseDuration(dis.MaxCacheRefetchDelay)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Dispatcher.MaxCacheRefetchDelay failed to parse: %v", err))
+	} else if maxCacheDelay < 0 {
+		errs = append(errs, "Dispatcher.MaxCacheRefetchDelay must be >= 0")
+	}
+	if dis.PermanentRedirectCrawls < 1 {
+		errs = append(errs, "Dispatcher.PermanentRedirectCrawls must be >= 1")
+	}
+	loopCooldown, err := time.ParseDuration(dis.RedirectLoopCooldown)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Dispatcher.RedirectLoopCooldown failed to parse: %v", err))
+	} else if loopCooldown < 0 {
+		errs = append(errs, "Dispatcher.RedirectLoopCooldown must be >= 0")
+	}
+	repairInterval, err := time.ParseDuration(dis.MaxPriorityRepairInterval)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Dispatcher.MaxPriorityRepairInterval failed to parse: %v", err))
+	} else if repairInterval <= 0 {
+		errs = append(errs, "Dispatcher.MaxPriorityRepairInterval must be > 0")
+	}
+	gcInterval, err := time.ParseDuration(dis.HistoryGCInterval)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Dispatcher.HistoryGCInterval failed to parse: %v", err))
+	} else if gcInterval < 0 {
+		errs = append(errs, "Dispatcher.HistoryGCInterval must be >= 0")
+	}
+	probeTimeout, err := time.ParseDuration(dis.ProbeTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Dispatcher.ProbeTimeout failed to parse: %v", err))
+	} else if probeTimeout <= 0 {
+		errs = append(errs, "Dispatcher.ProbeTimeout must be > 0")
+	}
+	switch strings.ToLower(dis.TrapDetection) {
+	case "off", "suggest", "exclude":
+	default:
+		errs = append(errs, "Dispatcher.TrapDetection not one of (off, suggest, exclude)")
+	}
+	if dis.TrapMinUncrawled < 0 {
+		errs = append(errs, "Dispatcher.TrapMinUncrawled must be >= 0")
+	}
+	if dis.TrapGrowthRatio < 1 {
+		errs = append(errs, "Dispatcher.TrapGrowthRatio must be >= 1")
+	}
+
+	al := &c.Alerts
+	_, err = time.ParseDuration(al.RepeatInterval)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Alerts.RepeatInterval failed to parse: %v", err))
+	}
+	if al.DomainErrorRate < 0.0 || al.DomainErrorRate > 1.0 {
+		errs = append(errs, "Alerts.DomainErrorRate must be a floating point number b/w 0 and 1")
+	}
+	if al.DomainErrorRateMinFetches < 0 {
+		errs = append(errs, "Alerts.DomainErrorRateMinFetches must be >= 0")
+	}
+	errWindow, err := time.ParseDuration(al.DomainErrorRateWindow)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Alerts.DomainErrorRateWindow failed to parse: %v", err))
+	} else if errWindow <= 0 {
+		errs = append(errs, "Alerts.DomainErrorRateWindow must be > 0")
+	}
+	if al.EmptyDispatchCycles < 0 {
+		errs = append(errs, "Alerts.EmptyDispatchCycles must be >= 0")
+	}
+	if al.WriteFailures < 0 {
+		errs = append(errs, "Alerts.WriteFailures must be >= 0")
+	}
+	if len(al.EmailTo) > 0 && (al.EmailSMTPServer == "" || al.EmailFrom == "") {
+		errs = append(errs, "Alerts.EmailSMTPServer and Alerts.EmailFrom must be set to send alerts to Alerts.EmailTo")
+	}
 
-	fet := &Config.Fetcher
+	fet := &c.Fetcher
 	_, err = time.ParseDuration(fet.HTTPTimeout)
 	if err != nil {
 		errs = append(errs, fmt.Sprintf("HTTPTimeout failed to parse: %v", err))
@@ -250,33 +721,429 @@ func assertConfigInvariants() error {
 	if def > max {
 		errs = append(errs, "Consistency problem: MaxCrawlDelay > DefaultCrawlDealy")
 	}
+	robotsUnavailable, err := time.ParseDuration(fet.RobotsUnavailablePeriod)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.RobotsUnavailablePeriod failed to parse: %v", err))
+	} else if robotsUnavailable < 0 {
+		errs = append(errs, "Fetcher.RobotsUnavailablePeriod must be >= 0")
+	}
+	maxTimePerHost, err := time.ParseDuration(fet.MaxTimePerHost)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.MaxTimePerHost failed to parse: %v", err))
+	} else if maxTimePerHost < 0 {
+		errs = append(errs, "Fetcher.MaxTimePerHost must be >= 0")
+	}
+
+	if fet.HandlerRetries < 0 {
+		errs = append(errs, "Fetcher.HandlerRetries must be >= 0")
+	}
+	if fet.MaxRedirects < 0 {
+		errs = append(errs, "Fetcher.MaxRedirects must be >= 0")
+	}
+	if fet.MaxLinksPerPage < 0 {
+		errs = append(errs, "Fetcher.MaxLinksPerPage must be >= 0")
+	}
+	if fet.MaxHTMLNodes < 0 {
+		errs = append(errs, "Fetcher.MaxHTMLNodes must be >= 0")
+	}
+	if fet.MaxHTMLDepth < 0 {
+		errs = append(errs, "Fetcher.MaxHTMLDepth must be >= 0")
+	}
+	if fet.HostLinkCacheSize < 0 {
+		errs = append(errs, "Fetcher.HostLinkCacheSize must be >= 0")
+	}
+
+	if fet.HostsPerFetcher < 1 {
+		errs = append(errs, "Fetcher.HostsPerFetcher must be >= 1")
+	}
+	if fet.MaxSimultaneousFetchers > 0 {
+		if fet.MinSimultaneousFetchers < 1 {
+			errs = append(errs, "Fetcher.MinSimultaneousFetchers must be >= 1")
+		}
+		if fet.NumSimultaneousFetchers < fet.MinSimultaneousFetchers ||
+			fet.NumSimultaneousFetchers > fet.MaxSimultaneousFetchers {
+			errs = append(errs, "Fetcher.NumSimultaneousFetchers must be between Fetcher.MinSimultaneousFetchers"+
+				" and Fetcher.MaxSimultaneousFetchers")
+		}
+	} else if fet.MaxSimultaneousFetchers < 0 {
+		errs = append(errs, "Fetcher.MaxSimultaneousFetchers must be >= 0")
+	}
+	autoscaleInterval, err := time.ParseDuration(fet.AutoscaleInterval)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.AutoscaleInterval failed to parse: %v", err))
+	} else if autoscaleInterval <= 0 {
+		errs = append(errs, "Fetcher.AutoscaleInterval must be > 0")
+	}
+	_, err = time.ParseDuration(fet.AutoscaleTargetLatency)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.AutoscaleTargetLatency failed to parse: %v", err))
+	}
+	if fet.AutoscaleMaxErrorRate < 0 || fet.AutoscaleMaxErrorRate > 1 {
+		errs = append(errs, "Fetcher.AutoscaleMaxErrorRate must be between 0 and 1")
+	}
+	_, err = time.ParseDuration(fet.HandlerRetryDelay)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.HandlerRetryDelay failed to parse: %v", err))
+	}
+	ackTimeout, err := time.ParseDuration(fet.HandlerAckTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.HandlerAckTimeout failed to parse: %v", err))
+	} else if ackTimeout <= 0 {
+		errs = append(errs, "Fetcher.HandlerAckTimeout must be > 0")
+	}
+
+	retryBackoff, err := time.ParseDuration(fet.TransientRetryBackoff)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.TransientRetryBackoff failed to parse: %v", err))
+	} else if retryBackoff < 0 {
+		errs = append(errs, "Fetcher.TransientRetryBackoff must be >= 0")
+	}
+	retryMax, err := time.ParseDuration(fet.TransientRetryMaxBackoff)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.TransientRetryMaxBackoff failed to parse: %v", err))
+	} else if retryMax < retryBackoff {
+		errs = append(errs, "Consistency problem: Fetcher.TransientRetryBackoff > Fetcher.TransientRetryMaxBackoff")
+	}
+
+	dnsTTL, err := time.ParseDuration(fet.DNSCacheTTL)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.DNSCacheTTL failed to parse: %v", err))
+	} else if dnsTTL < 0 {
+		errs = append(errs, "Fetcher.DNSCacheTTL must be >= 0")
+	}
+	dnsNegTTL, err := time.ParseDuration(fet.DNSNegativeCacheTTL)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Fetcher.DNSNegativeCacheTTL failed to parse: %v", err))
+	} else if dnsNegTTL < 0 {
+		errs = append(errs, "Fetcher.DNSNegativeCacheTTL must be >= 0")
+	}
+	for host, ip := range fet.DNSOverrides {
+		if net.ParseIP(ip) == nil {
+			errs = append(errs, fmt.Sprintf("Fetcher.DNSOverrides has an invalid IP address for %v: %q", host, ip))
+		}
+	}
 
 	switch strings.ToLower(fet.HTTPKeepAlive) {
-	case "always", "threshold", "never":
+	case "always", "threshold", "auto", "never":
+	default:
+		errs = append(errs, "Fetcher.HTTPKeepAlive not one of (always, threshold, auto, never)")
+	}
+	switch strings.ToLower(fet.IPPreference) {
+	case "ipv4", "ipv6", "happy_eyeballs":
+	default:
+		errs = append(errs, "Fetcher.IPPreference not one of (ipv4, ipv6, happy_eyeballs)")
+	}
+	for _, addr := range fet.LocalAddrs {
+		if net.ParseIP(addr) == nil {
+			errs = append(errs, fmt.Sprintf("Fetcher.LocalAddrs has an invalid IP address: %q", addr))
+		}
+	}
+	switch strings.ToLower(fet.UserAgentRotation) {
+	case "round_robin", "random":
+	default:
+		errs = append(errs, "Fetcher.UserAgentRotation not one of (round_robin, random)")
+	}
+	if strings.IndexFunc(fet.CrawlIdentity, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+		errs = append(errs, fmt.Sprintf("Fetcher.CrawlIdentity has control characters: %q", fet.CrawlIdentity))
+	}
+	if fet.SendCrawlIDHeader && strings.TrimSpace(fet.CrawlIdentity) == "" {
+		errs = append(errs, "Fetcher.SendCrawlIDHeader is set but Fetcher.CrawlIdentity is empty")
+	}
+	for dom, lang := range fet.DomainAcceptLanguages {
+		if strings.TrimSpace(lang) == "" {
+			errs = append(errs, fmt.Sprintf("Fetcher.DomainAcceptLanguages has an empty language for %q", dom))
+		}
+	}
+	for dom, agent := range fet.DomainRobotsAgents {
+		if strings.TrimSpace(agent) == "" {
+			errs = append(errs, fmt.Sprintf("Fetcher.DomainRobotsAgents has an empty user agent for %q", dom))
+		}
+	}
+	for dom, cred := range fet.DomainCredentials {
+		if err := cred.validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("Fetcher.DomainCredentials for %q: %v", dom, err))
+		}
+	}
+	for dom, dp := range fet.DomainQueryParams {
+		for _, p := range dp.Strip {
+			for _, k := range dp.Keep {
+				if strings.EqualFold(p, k) {
+					errs = append(errs, fmt.Sprintf("Fetcher.DomainQueryParams for %q both strips and keeps %q", dom, p))
+				}
+			}
+		}
+	}
+	for _, fp := range fet.Fingerprints {
+		switch strings.ToLower(fp) {
+		case FingerprintFNV, FingerprintSHA256, FingerprintSimHash:
+		default:
+			errs = append(errs, fmt.Sprintf("Fetcher.Fingerprints has %q, not one of (fnv, sha256, simhash)", fp))
+		}
+	}
+	switch strings.ToLower(fet.RelNofollow) {
+	case "flag", "skip":
 	default:
-		errs = append(errs, "Fetcher.HTTPKeepAlive not one of (always, threshold, never)")
+		errs = append(errs, "Fetcher.RelNofollow not one of (flag, skip)")
+	}
+	switch strings.ToLower(fet.UserinfoURLs) {
+	case "drop", "strip", "keep":
+	default:
+		errs = append(errs, "Fetcher.UserinfoURLs not one of (drop, strip, keep)")
+	}
+	switch strings.ToLower(fet.NonstandardPorts) {
+	case "crawl", "record", "drop":
+	default:
+		errs = append(errs, "Fetcher.NonstandardPorts not one of (crawl, record, drop)")
 	}
 	_, err = time.ParseDuration(fet.HTTPKeepAliveThreshold)
 	if err != nil {
 		errs = append(errs, fmt.Sprintf("Fetcher.HTTPKeepAliveThreshold failed to parse: %v", err))
 	}
 
-	cas := &Config.Cassandra
+	cas := &c.Cassandra
+	if !ValidKeyspace(cas.Keyspace) {
+		errs = append(errs, fmt.Sprintf("Cassandra.Keyspace %q is not a valid keyspace name", cas.Keyspace))
+	}
 	_, err = time.ParseDuration(cas.Timeout)
 	if err != nil {
 		errs = append(errs, fmt.Sprintf("Cassandra.Timeout failed to parse: %v", err))
 	}
+	_, err = time.ParseDuration(cas.ConnectTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Cassandra.ConnectTimeout failed to parse: %v", err))
+	}
+	switch strings.ToLower(cas.Consistency) {
+	case "any", "one", "two", "three", "quorum", "all", "local_quorum", "each_quorum", "local_one":
+	default:
+		errs = append(errs, fmt.Sprintf("Cassandra.Consistency %q not one of (any, one, two, three, quorum, all, "+
+			"local_quorum, each_quorum, local_one)", cas.Consistency))
+	}
+	// Cassandra rejects reads and counter updates at consistency any
+	for _, setting := range []struct{ name, val string }{
+		{"ReadConsistency", cas.ReadConsistency},
+		{"CounterConsistency", cas.CounterConsistency},
+	} {
+		switch strings.ToLower(setting.val) {
+		case "one", "two", "three", "quorum", "all", "local_quorum", "each_quorum", "local_one":
+		default:
+			errs = append(errs, fmt.Sprintf("Cassandra.%v %q not one of (one, two, three, quorum, all, "+
+				"local_quorum, each_quorum, local_one)", setting.name, setting.val))
+		}
+	}
+	switch strings.ToLower(cas.SerialConsistency) {
+	case "serial", "local_serial":
+	default:
+		errs = append(errs, fmt.Sprintf("Cassandra.SerialConsistency %q not one of (serial, local_serial)",
+			cas.SerialConsistency))
+	}
+	switch strings.ToLower(cas.HostSelectionPolicy) {
+	case "round_robin", "token_aware":
+	case "dc_aware":
+		if cas.LocalDC == "" {
+			errs = append(errs, "Cassandra.LocalDC must be set to use the dc_aware HostSelectionPolicy")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("Cassandra.HostSelectionPolicy %q not one of (round_robin, token_aware, dc_aware)",
+			cas.HostSelectionPolicy))
+	}
+	if cas.SpeculativeAttempts < 0 {
+		errs = append(errs, "Cassandra.SpeculativeAttempts must be >= 0")
+	}
+	specDelay, err := time.ParseDuration(cas.SpeculativeDelay)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Cassandra.SpeculativeDelay failed to parse: %v", err))
+	} else if cas.SpeculativeAttempts > 0 && specDelay <= 0 {
+		errs = append(errs, "Cassandra.SpeculativeDelay must be > 0")
+	}
 	if cas.DefaultDomainPriority < 1 {
 		errs = append(errs, fmt.Sprintf("Cassandra.DefaultDomainPriority must be >= 1"))
 	}
+	if cas.ReferrerCacheSize < 1 {
+		errs = append(errs, "Cassandra.ReferrerCacheSize must be greater than 0")
+	}
+	if cas.WriteRateLimit < 0 {
+		errs = append(errs, "Cassandra.WriteRateLimit must be >= 0")
+	}
+	if cas.WriteRateLimit > 0 && cas.WriteRateBurst < 1 {
+		errs = append(errs, "Cassandra.WriteRateBurst must be greater than 0")
+	}
+	if cas.WriteBatchSize < 1 {
+		errs = append(errs, "Cassandra.WriteBatchSize must be greater than 0")
+	}
+	if cas.ParsedLinkBatchSize < 1 {
+		errs = append(errs, "Cassandra.ParsedLinkBatchSize must be greater than 0")
+	}
+	if cas.WriteTimeoutRetries < 0 {
+		errs = append(errs, "Cassandra.WriteTimeoutRetries must be >= 0")
+	}
+	_, err = time.ParseDuration(cas.WriteRetryBackoff)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Cassandra.WriteRetryBackoff failed to parse: %v", err))
+	}
+	if cas.SpillMaxBytes <= 0 {
+		errs = append(errs, "Cassandra.SpillMaxBytes must be > 0")
+	}
+	spillInterval, err := time.ParseDuration(cas.SpillReplayInterval)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Cassandra.SpillReplayInterval failed to parse: %v", err))
+	} else if spillInterval <= 0 {
+		errs = append(errs, "Cassandra.SpillReplayInterval must be > 0")
+	}
+	if cas.SpillReplayRate < 0 {
+		errs = append(errs, "Cassandra.SpillReplayRate must be >= 0")
+	}
+	switch strings.ToLower(cas.BodyCompression) {
+	case "none", "snappy":
+	default:
+		errs = append(errs, fmt.Sprintf("Cassandra.BodyCompression %q not one of (none, snappy)",
+			cas.BodyCompression))
+	}
+	switch strings.ToLower(cas.ClaimStrategy) {
+	case "token_order", "weighted":
+	default:
+		errs = append(errs, fmt.Sprintf("Cassandra.ClaimStrategy %q not one of (token_order, weighted)",
+			cas.ClaimStrategy))
+	}
+	_, err = time.ParseDuration(cas.HostAffinityWindow)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Cassandra.HostAffinityWindow failed to parse: %v", err))
+	}
+	_, err = time.ParseDuration(cas.HostAffinityGrace)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Cassandra.HostAffinityGrace failed to parse: %v", err))
+	}
+	if cas.SampleThreshold < 0 {
+		errs = append(errs, "Cassandra.SampleThreshold must be >= 0")
+	}
+	if cas.SamplePercent <= 0.0 || cas.SamplePercent > 100.0 {
+		errs = append(errs, "Cassandra.SamplePercent must be a floating point number greater than 0 and at most 100")
+	}
+	switch strings.ToLower(cas.SegmentStore) {
+	case "cassandra":
+	case "redis":
+		if c.Redis.Address == "" {
+			errs = append(errs, "Redis.Address must be set if Cassandra.SegmentStore is redis")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("Cassandra.SegmentStore %q not one of (cassandra, redis)",
+			cas.SegmentStore))
+	}
+	if cas.HistoryMaxCrawls < 0 {
+		errs = append(errs, "Cassandra.HistoryMaxCrawls must be >= 0")
+	}
+	maxAge, err := time.ParseDuration(cas.HistoryMaxAge)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Cassandra.HistoryMaxAge failed to parse: %v", err))
+	} else if maxAge < 0 {
+		errs = append(errs, "Cassandra.HistoryMaxAge must be >= 0")
+	}
+	if cas.HistoryGCRate < 0 {
+		errs = append(errs, "Cassandra.HistoryGCRate must be >= 0")
+	}
+
+	_, err = time.ParseDuration(c.Console.DashboardRefresh)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Console.DashboardRefresh failed to parse: %v", err))
+	}
+	if c.Console.MaxSortedLinks <= 0 {
+		errs = append(errs, "Console.MaxSortedLinks must be > 0")
+	}
+
+	_, err = time.ParseDuration(c.GRPC.CallTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("GRPC.CallTimeout failed to parse: %v", err))
+	}
+	if (c.GRPC.TLSCertFile == "") != (c.GRPC.TLSKeyFile == "") {
+		errs = append(errs, "GRPC.TLSCertFile and GRPC.TLSKeyFile must be set together")
+	}
+
+	es := &c.Elasticsearch
+	if es.URL != "" && es.Index == "" {
+		errs = append(errs, "Elasticsearch.Index must be set if Elasticsearch.URL is")
+	}
+	if es.BulkSize < 1 {
+		errs = append(errs, "Elasticsearch.BulkSize must be greater than 0")
+	}
+	flushInterval, err := time.ParseDuration(es.FlushInterval)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Elasticsearch.FlushInterval failed to parse: %v", err))
+	} else if flushInterval <= 0 {
+		errs = append(errs, "Elasticsearch.FlushInterval must be > 0")
+	}
+	if es.QueueSize < 1 {
+		errs = append(errs, "Elasticsearch.QueueSize must be greater than 0")
+	}
+	if es.MaxRetries < 0 {
+		errs = append(errs, "Elasticsearch.MaxRetries must be >= 0")
+	}
+	_, err = time.ParseDuration(es.RetryBackoff)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Elasticsearch.RetryBackoff failed to parse: %v", err))
+	}
+	if es.MaxTextBytes < 0 {
+		errs = append(errs, "Elasticsearch.MaxTextBytes must be >= 0")
+	}
+
+	redisTimeout, err := time.ParseDuration(c.Redis.Timeout)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Redis.Timeout failed to parse: %v", err))
+	} else if redisTimeout <= 0 {
+		errs = append(errs, "Redis.Timeout must be > 0")
+	}
+	if c.Redis.Database < 0 {
+		errs = append(errs, "Redis.Database must be >= 0")
+	}
+	if c.Redis.MaxIdleConns < 0 {
+		errs = append(errs, "Redis.MaxIdleConns must be >= 0")
+	}
+
+	faults := &c.Faults
+	for name, percent := range map[string]float64{
+		"FetchDelayPercent":    faults.FetchDelayPercent,
+		"FetchErrorPercent":    faults.FetchErrorPercent,
+		"FetchTruncatePercent": faults.FetchTruncatePercent,
+		"WriteDelayPercent":    faults.WriteDelayPercent,
+		"WriteErrorPercent":    faults.WriteErrorPercent,
+	} {
+		if percent < 0 || percent > 100 {
+			errs = append(errs, fmt.Sprintf("Faults.%v must be between 0 and 100", name))
+		}
+	}
+	fetchDelay, err := time.ParseDuration(faults.FetchDelay)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Faults.FetchDelay failed to parse: %v", err))
+	} else if fetchDelay < 0 {
+		errs = append(errs, "Faults.FetchDelay must be >= 0")
+	}
+	writeDelay, err := time.ParseDuration(faults.WriteDelay)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("Faults.WriteDelay failed to parse: %v", err))
+	} else if writeDelay < 0 {
+		errs = append(errs, "Faults.WriteDelay must be >= 0")
+	}
 
-	keeprat := Config.Fetcher.ActiveFetchersKeepratio
+	tracing := &c.Tracing
+	switch strings.ToLower(tracing.Exporter) {
+	case "", "stdout":
+	case "otlp":
+		if tracing.Endpoint == "" {
+			errs = append(errs, "Tracing.Endpoint must be set if Tracing.Exporter is otlp")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("Tracing.Exporter %q not one of (otlp, stdout) or empty",
+			tracing.Exporter))
+	}
+	if tracing.SampleRatio < 0 || tracing.SampleRatio > 1 {
+		errs = append(errs, "Tracing.SampleRatio must be between 0 and 1")
+	}
+
+	keeprat := c.Fetcher.ActiveFetchersKeepratio
 	if keeprat < 0 || keeprat >= 1.0 {
 		errs = append(errs, "Fetcher.ActiveFetchersKeepratio failed to be in the correct range:"+
 			" must choose X such that 0 <= X < 1")
 	}
 
-	cacherat := Config.Fetcher.ActiveFetchersCacheratio
+	cacherat := c.Fetcher.ActiveFetchersCacheratio
 	if cacherat < 0 || cacherat >= 1.0 {
 		errs = append(errs, "Fetcher.ActiveFetchersCacheratio failed to be in the correct range:"+
 			" must choose X such that 0 <= X < 1")
@@ -308,28 +1175,179 @@ func PostConfigHooks() {
 	}
 }
 
+// hotReloadSettings are the settings ReloadConfig applies to a running walker.
+// Components that cache them re-read them when configGeneration changes.
+var hotReloadSettings = map[string]bool{
+	"fetcher.default_crawl_delay":              true,
+	"fetcher.max_crawl_delay":                  true,
+	"fetcher.accept_formats":                   true,
+	"fetcher.exclude_link_patterns":            true,
+	"fetcher.include_link_patterns":            true,
+	"dispatcher.dispatch_interval":             true,
+	"dispatcher.empty_dispatch_retry_interval": true,
+	"dispatcher.min_link_refresh_time":         true,
+	"dispatcher.status_refresh_times":          true,
+	"dispatcher.max_priority_repair_interval":  true,
+	"faults.enabled":                           true,
+	"faults.fetch_delay_percent":               true,
+	"faults.fetch_delay":                       true,
+	"faults.fetch_error_percent":               true,
+	"faults.fetch_truncate_percent":            true,
+	"faults.write_delay_percent":               true,
+	"faults.write_delay":                       true,
+	"faults.write_error_percent":               true,
+}
+
+// secretSettings are not logged by ReloadConfig, only that they changed
+var secretSettings = map[string]bool{
+	"fetcher.domain_credentials": true,
+	"fetcher.credentials_key":    true,
+	"alerts.webhook_url":         true,
+	"alerts.slack_webhook_url":   true,
+	"elasticsearch.password":     true,
+	"redis.password":             true,
+}
+
+// configGeneration is incremented each time ReloadConfig changes Config
+var configGeneration uint64
+
+// configMu guards Config while ReloadConfig changes its hotReloadSettings
+var configMu sync.RWMutex
+
+// HotConfig returns a copy of Config for reading hotReloadSettings, which
+// ReloadConfig may change at any time. Other settings never change once the
+// config file is read, so they can be read from Config directly.
+func HotConfig() ConfigStruct {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return Config
+}
+
+func currentConfigGeneration() uint64 {
+	return atomic.LoadUint64(&configGeneration)
+}
+
+// ReloadConfig re-reads ConfigName while walker is running (the walker
+// command does this on SIGHUP). Changed settings listed in hotReloadSettings
+// are applied; other changes are logged but only take effect on restart. If
+// the file fails to load the current config is kept and the error returned.
+//
+// PostConfigHooks is not run again: the settings it sets up are not hot
+// reloadable, and fetchers keep normalizing URLs with them while this runs.
+func ReloadConfig() error {
+	next, err := parseConfigFile(ConfigName)
+	if err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	applied := 0
+	for _, c := range configChanges(&Config, next) {
+		if secretSettings[c.name] {
+			c.from, c.to = "(secret)", "(secret)"
+		}
+		if !hotReloadSettings[c.name] {
+			log4go.Warn("Config reload: %v changed from %v to %v, restart to apply it", c.name, c.from, c.to)
+			continue
+		}
+		log4go.Info("Config reload: %v changed from %v to %v", c.name, c.from, c.to)
+		reflect.ValueOf(&Config).Elem().FieldByIndex(c.index).Set(
+			reflect.ValueOf(next).Elem().FieldByIndex(c.index))
+		applied++
+	}
+	if applied > 0 {
+		atomic.AddUint64(&configGeneration, 1)
+	}
+	log4go.Info("Reloaded config file %v, applied %d changed settings", ConfigName, applied)
+	return nil
+}
+
+// configChange is a setting that differs between two configs
+type configChange struct {
+	// yaml name of the setting, ex. fetcher.default_crawl_delay
+	name     string
+	index    []int
+	from, to interface{}
+}
+
+// configChanges returns the settings that differ between a and b
+func configChanges(a, b *ConfigStruct) []configChange {
+	var changes []configChange
+	var walk func(a, b reflect.Value, prefix string, index []int)
+	walk = func(a, b reflect.Value, prefix string, index []int) {
+		for i := 0; i < a.NumField(); i++ {
+			field := a.Type().Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			idx := append(append([]int{}, index...), i)
+			if field.Type.Kind() == reflect.Struct {
+				walk(a.Field(i), b.Field(i), name, idx)
+				continue
+			}
+			if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+				changes = append(changes, configChange{
+					name:  name,
+					index: idx,
+					from:  a.Field(i).Interface(),
+					to:    b.Field(i).Interface(),
+				})
+			}
+		}
+	}
+	walk(reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem(), "", nil)
+	return changes
+}
+
 func readConfig() error {
-	SetDefaultConfig()
+	c, err := parseConfigFile(ConfigName)
+	configMu.Lock()
+	Config = *c
+	configMu.Unlock()
+	if err == nil {
+		log4go.Info("Loaded config file %v", ConfigName)
+	}
+
+	PostConfigHooks()
+
+	return err
+}
+
+// parseConfigFile reads the config file at path over the default config. The
+// returned config is never nil: if path can't be read or parsed it is the
+// default config, and if it fails assertConfigInvariants it is the config as
+// read, along with the error.
+func parseConfigFile(path string) (*ConfigStruct, error) {
+	c := &ConfigStruct{}
+	setDefaultConfig(c)
 
 	// See NOTE in SetDefaultConfig regarding sequence values
-	Config.Fetcher.AcceptFormats = []string{}
-	Config.Fetcher.AcceptProtocols = []string{}
-	Config.Fetcher.IgnoreTags = []string{}
-	Config.Fetcher.PurgeSidList = []string{}
+	c.Fetcher.AcceptFormats = []string{}
+	c.Fetcher.AcceptProtocols = []string{}
+	c.Fetcher.IgnoreTags = []string{}
+	c.Fetcher.PurgeSidList = []string{}
+	c.Fetcher.Fingerprints = []string{}
 
-	Config.Cassandra.Hosts = []string{}
+	c.Cassandra.Hosts = []string{}
 
-	data, err := ioutil.ReadFile(ConfigName)
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("Failed to read config file (%v): %v", ConfigName, err)
+		setDefaultConfig(c)
+		return c, fmt.Errorf("Failed to read config file (%v): %v", path, err)
 	}
-	err = yaml.Unmarshal(data, &Config)
+	err = yaml.Unmarshal(data, c)
 	if err != nil {
-		return fmt.Errorf("Failed to unmarshal yaml from config file (%v): %v", ConfigName, err)
+		setDefaultConfig(c)
+		return c, fmt.Errorf("Failed to unmarshal yaml from config file (%v): %v", path, err)
 	}
 
 	// See NOTE in SetDefaultConfig regarding sequence values
-	fet := &Config.Fetcher
+	fet := &c.Fetcher
 	if len(fet.AcceptFormats) == 0 {
 		fet.AcceptFormats = []string{"text/html", "text/*;"}
 	}
@@ -342,17 +1360,13 @@ func readConfig() error {
 	if len(fet.PurgeSidList) == 0 {
 		fet.PurgeSidList = []string{"jsessionid", "phpsessid", "aspsessionid"}
 	}
-
-	if len(Config.Cassandra.Hosts) == 0 {
-		Config.Cassandra.Hosts = []string{"localhost"}
+	if len(fet.Fingerprints) == 0 {
+		fet.Fingerprints = []string{FingerprintFNV}
 	}
 
-	err = assertConfigInvariants()
-	if err != nil {
-		log4go.Info("Loaded config file %v", ConfigName)
+	if len(c.Cassandra.Hosts) == 0 {
+		c.Cassandra.Hosts = []string{"localhost"}
 	}
 
-	PostConfigHooks()
-
-	return err
+	return c, assertConfigInvariants(c)
 }
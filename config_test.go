@@ -1,6 +1,8 @@
 package walker
 
 import (
+	"io/ioutil"
+	"os"
 	"path"
 	"reflect"
 	"regexp"
@@ -91,3 +93,235 @@ func TestSequenceOverwrites(t *testing.T) {
 			Config.Cassandra.Hosts)
 	}
 }
+
+func TestReloadConfig(t *testing.T) {
+	defer func() {
+		// Reset config for the remaining tests
+		LoadTestConfig("test-walker.yaml")
+	}()
+
+	f, err := ioutil.TempFile("", "walker-reload")
+	if err != nil {
+		t.Fatalf("Failed to create temp config file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+	writeConfig := func(yaml string) {
+		if err := ioutil.WriteFile(f.Name(), []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+
+	writeConfig(`
+fetcher:
+    user_agent: Before
+    default_crawl_delay: 1s
+    exclude_link_patterns: []
+`)
+	if err := ReadConfigFile(f.Name()); err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	generation := currentConfigGeneration()
+	purgeMap := reflect.ValueOf(parseURLPurgeMap).Pointer()
+
+	writeConfig(`
+fetcher:
+    user_agent: After
+    default_crawl_delay: 3s
+    exclude_link_patterns: ["\\.pdf$"]
+`)
+	if err := ReloadConfig(); err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if Config.Fetcher.DefaultCrawlDelay != "3s" {
+		t.Errorf("Expected default_crawl_delay to be reloaded, got %v", Config.Fetcher.DefaultCrawlDelay)
+	}
+	if !reflect.DeepEqual(Config.Fetcher.ExcludeLinkPatterns, []string{`\.pdf$`}) {
+		t.Errorf("Expected exclude_link_patterns to be reloaded, got %v", Config.Fetcher.ExcludeLinkPatterns)
+	}
+	if Config.Fetcher.UserAgent != "Before" {
+		t.Errorf("Expected user_agent to need a restart, got %v", Config.Fetcher.UserAgent)
+	}
+	if currentConfigGeneration() != generation+1 {
+		t.Errorf("Expected the config generation to be incremented")
+	}
+	if reflect.ValueOf(parseURLPurgeMap).Pointer() != purgeMap {
+		t.Errorf("Expected the normalization rules in use not to be replaced on reload")
+	}
+
+	writeConfig(`
+fetcher:
+    default_crawl_delay: soon
+`)
+	if err := ReloadConfig(); err == nil {
+		t.Errorf("Expected an error reloading an invalid config")
+	}
+	if Config.Fetcher.DefaultCrawlDelay != "3s" || Config.Fetcher.UserAgent != "Before" {
+		t.Errorf("Expected an invalid config to leave the current one, got %v, %v",
+			Config.Fetcher.DefaultCrawlDelay, Config.Fetcher.UserAgent)
+	}
+	if currentConfigGeneration() != generation+1 {
+		t.Errorf("Expected an invalid config to leave the config generation")
+	}
+}
+
+func TestReloadConfigReaders(t *testing.T) {
+	defer func() {
+		// Reset config for the remaining tests
+		LoadTestConfig("test-walker.yaml")
+	}()
+
+	f, err := ioutil.TempFile("", "walker-reload")
+	if err != nil {
+		t.Fatalf("Failed to create temp config file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+	writeDelay := func(delay string) {
+		yaml := "fetcher:\n    default_crawl_delay: " + delay + "\n    max_crawl_delay: 1m\n"
+		if err := ioutil.WriteFile(f.Name(), []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+	writeDelay("2s")
+	if err := ReadConfigFile(f.Name()); err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+
+	// Readers must only ever see the old or the new value, never the
+	// default (1s) the file is parsed over
+	done := make(chan struct{})
+	seen := make(chan string, 1)
+	go func() {
+		defer close(seen)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if delay := HotConfig().Fetcher.DefaultCrawlDelay; delay != "2s" && delay != "3s" {
+				seen <- delay
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		writeDelay([]string{"3s", "2s"}[i%2])
+		if err := ReloadConfig(); err != nil {
+			t.Fatalf("Failed to reload config: %v", err)
+		}
+	}
+	close(done)
+	if delay, ok := <-seen; ok {
+		t.Errorf("Expected readers to see 2s or 3s during reloads, got %v", delay)
+	}
+}
+
+func TestConfigChanges(t *testing.T) {
+	var a, b ConfigStruct
+	a.Fetcher.AcceptFormats = []string{"text/html"}
+	b.Fetcher.AcceptFormats = []string{"text/html", "text/plain"}
+	b.Dispatcher.DispatchInterval = "5s"
+
+	changes := configChanges(&a, &b)
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %v", changes)
+	}
+	if changes[0].name != "fetcher.accept_formats" || changes[1].name != "dispatcher.dispatch_interval" {
+		t.Errorf("Unexpected change names %v, %v", changes[0].name, changes[1].name)
+	}
+	if changes[1].from != "" || changes[1].to != "5s" {
+		t.Errorf("Unexpected change values %v -> %v", changes[1].from, changes[1].to)
+	}
+}
+
+func TestStatusRefreshTimes(t *testing.T) {
+	defer func() {
+		// Reset config for the remaining tests
+		LoadTestConfig("test-walker.yaml")
+	}()
+
+	f, err := ioutil.TempFile("", "walker-status-refresh")
+	if err != nil {
+		t.Fatalf("Failed to create temp config file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	tests := []struct {
+		yaml  string
+		valid bool
+	}{
+		{"{200: 168h, 404: 720h, 5xx: 24h, error: 1h}", true},
+		{"{200: soon}", false},
+		{"{600: 1h}", false},
+		{"{4x4: 1h}", false},
+		{"{ok: 1h}", false},
+	}
+	for _, tst := range tests {
+		yaml := "dispatcher:\n    status_refresh_times: " + tst.yaml + "\n"
+		if err := ioutil.WriteFile(f.Name(), []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+		err := ReadConfigFile(f.Name())
+		if tst.valid && err != nil {
+			t.Errorf("Expected %v to be valid, got %v", tst.yaml, err)
+		} else if !tst.valid && err == nil {
+			t.Errorf("Expected %v to be invalid", tst.yaml)
+		}
+	}
+
+	yaml := "dispatcher:\n    status_refresh_times: {200: 168h, 5xx: 24h}\n"
+	if err := ioutil.WriteFile(f.Name(), []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := ReadConfigFile(f.Name()); err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	expected := map[string]string{"200": "168h", "5xx": "24h"}
+	if !reflect.DeepEqual(Config.Dispatcher.StatusRefreshTimes, expected) {
+		t.Errorf("Expected status_refresh_times %v, got %v", expected, Config.Dispatcher.StatusRefreshTimes)
+	}
+}
+
+func TestConsistencyConfig(t *testing.T) {
+	defer func() {
+		// Reset config for the remaining tests
+		LoadTestConfig("test-walker.yaml")
+	}()
+
+	f, err := ioutil.TempFile("", "walker-consistency")
+	if err != nil {
+		t.Fatalf("Failed to create temp config file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	tests := []struct {
+		yaml  string
+		valid bool
+	}{
+		{"consistency: local_quorum", true},
+		{"consistency: any", true},
+		{"consistency: most", false},
+		{"read_consistency: local_one", true},
+		{"read_consistency: any", false},
+		{"counter_consistency: all", true},
+		{"counter_consistency: any", false},
+		{"serial_consistency: local_serial", true},
+		{"serial_consistency: quorum", false},
+	}
+	for _, tst := range tests {
+		yaml := "cassandra:\n    " + tst.yaml + "\n"
+		if err := ioutil.WriteFile(f.Name(), []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+		err := ReadConfigFile(f.Name())
+		if tst.valid && err != nil {
+			t.Errorf("Expected %v to be valid, got %v", tst.yaml, err)
+		} else if !tst.valid && err == nil {
+			t.Errorf("Expected %v to be invalid", tst.yaml)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package console
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"code.google.com/p/log4go"
 	"github.com/gorilla/mux"
@@ -32,6 +34,7 @@ type dropdownElement struct {
 func Routes() []Route {
 	return []Route{
 		Route{Path: "/", Controller: HomeController},
+		Route{Path: "/dashboard", Controller: DashboardController},
 		Route{Path: "/list", Controller: ListDomainsController},
 		Route{Path: "/list/", Controller: ListDomainsController},
 		Route{Path: "/list/{seed}", Controller: ListDomainsController},
@@ -40,12 +43,26 @@ func Routes() []Route {
 		Route{Path: "/add", Controller: AddLinkIndexController},
 		Route{Path: "/add/", Controller: AddLinkIndexController},
 		Route{Path: "/links/{domain}", Controller: LinksController},
-		Route{Path: "/links/{domain}/{seedURL}", Controller: LinksController},
+		Route{Path: "/links/{domain}/{page}", Controller: LinksController},
 		Route{Path: "/historical/{url}", Controller: LinksHistoricalController},
+		Route{Path: "/diff/{url}", Controller: DiffLinkController},
+		Route{Path: "/fingerprint/{fp}", Controller: FingerprintController},
+		Route{Path: "/referrers/{url}", Controller: ReferrersController},
+		Route{Path: "/politeness/{domain}", Controller: PolitenessController},
+		Route{Path: "/deadLinks/{domain}", Controller: DeadLinksController},
+		Route{Path: "/deadLinks/{domain}/csv", Controller: DeadLinksCSVController},
+		Route{Path: "/segment/{domain}", Controller: SegmentPreviewController},
 		Route{Path: "/findLinks", Controller: FindLinksController},
 		Route{Path: "/filterLinks", Controller: FilterLinksController},
 		Route{Path: "/excludeToggle/{domain}/{direction}", Controller: ExcludeToggleController},
+		Route{Path: "/pauseToggle/{domain}/{direction}", Controller: PauseToggleController},
+		Route{Path: "/trapToggle/{domain}/{direction}", Controller: TrapToggleController},
+		Route{Path: "/getNow/{url}", Controller: GetNowController},
 		Route{Path: "/changePriority", Controller: ChangePriorityController},
+		Route{Path: "/changeDomainConfig", Controller: ChangeDomainConfigController},
+		Route{Path: "/excludeDomains", Controller: ExcludeDomainsController},
+		Route{Path: "/pendingDomains", Controller: PendingDomainsController},
+		Route{Path: "/tags", Controller: TagsController},
 	}
 }
 
@@ -56,6 +73,193 @@ func HomeController(w http.ResponseWriter, req *http.Request) {
 	return
 }
 
+// DashboardController returns the /dashboard page, an overview of the whole
+// crawl
+func DashboardController(w http.ResponseWriter, req *http.Request) {
+	overview, err := DS.CrawlOverview()
+	if err != nil {
+		replyServerError(w, fmt.Errorf("CrawlOverview: %v", err))
+		return
+	}
+
+	refresh, err := time.ParseDuration(walker.Config.Console.DashboardRefresh)
+	if err != nil {
+		// Shouldn't happen, it's checked in assertConfigInvariants
+		replyServerError(w, fmt.Errorf("Console.DashboardRefresh: %v", err))
+		return
+	}
+
+	stranded, err := DS.ListStrandedClaims(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		replyServerError(w, fmt.Errorf("ListStrandedClaims: %v", err))
+		return
+	}
+
+	mp := map[string]interface{}{
+		"Overview":        overview,
+		"FetchesPerMin":   fmt.Sprintf("%.1f", float64(overview.FetchesLastHour)/60.0),
+		"RefreshMillis":   int64(refresh / time.Millisecond),
+		"RefreshInterval": refresh.String(),
+	}
+	if overview.Scan != nil {
+		mp["ScanProgress"] = describeScanProgress(overview.Scan)
+	}
+	if len(stranded) > 0 {
+		mp["HasErrorMessage"] = true
+		mp["ErrorMessage"] = []string{describeStrandedClaims(stranded)}
+	}
+	Render.HTML(w, http.StatusOK, "dashboard", mp)
+}
+
+// describeStrandedClaims summarizes the claims the dispatcher released from
+// lost fetchers, for the dashboard
+func describeStrandedClaims(claims []*cassandra.StrandedClaim) string {
+	fetchers := map[string]bool{}
+	links := 0
+	for _, c := range claims {
+		fetchers[c.ClaimToken.String()] = true
+		links += c.Links
+	}
+	last := claims[0]
+	return fmt.Sprintf("%d fetchers stopped without releasing their domains in the last 24 hours; "+
+		"the dispatcher released %d domains and re-dispatched %d links. The most recent was %v, "+
+		"released from fetcher %v at %v.", len(fetchers), len(claims), links, last.Domain, last.ClaimToken,
+		last.Time.Format(time.RFC1123))
+}
+
+// describeScanProgress summarizes the dispatcher's pass over domain_info for
+// the dashboard
+func describeScanProgress(p *cassandra.ScanProgress) string {
+	if p.Shards > 0 && p.ShardsDone == p.Shards {
+		return fmt.Sprintf("pass %d finished at %v: %d domains read, %d queued", p.Iteration,
+			p.Updated.Format(time.RFC1123), p.Scanned, p.Queued)
+	}
+	return fmt.Sprintf("pass %d: %.1f%% scanned (%d/%d shards done), %d domains read, %d queued; "+
+		"started %v, as of %v", p.Iteration, p.Fraction*100, p.ShardsDone, p.Shards, p.Scanned, p.Queued,
+		p.Started.Format(time.RFC1123), p.Updated.Format(time.RFC1123))
+}
+
+// ExcludeDomainsController returns the /excludeDomains page, which excludes
+// every domain matching a regular expression
+func ExcludeDomainsController(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		mp := map[string]interface{}{}
+		Render.HTML(w, http.StatusOK, "excludeDomains", mp)
+		return
+	}
+
+	err := req.ParseForm()
+	if err != nil {
+		replyServerError(w, err)
+		return
+	}
+
+	pattern := strings.TrimSpace(req.FormValue("pattern"))
+	reason := strings.TrimSpace(req.FormValue("reason"))
+	if reason == "" {
+		reason = "Manual exclude"
+	}
+	if pattern == "" {
+		mp := map[string]interface{}{
+			"HasInfoMessage": true,
+			"InfoMessage":    []string{"Failed to specify a domain pattern"},
+		}
+		Render.HTML(w, http.StatusOK, "excludeDomains", mp)
+		return
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		mp := map[string]interface{}{
+			"Pattern":         pattern,
+			"Reason":          reason,
+			"HasErrorMessage": true,
+			"ErrorMessage":    []string{fmt.Sprintf("Bad domain pattern: %v", err)},
+		}
+		Render.HTML(w, http.StatusOK, "excludeDomains", mp)
+		return
+	}
+
+	count, err := DS.ExcludeDomainsMatching(pattern, reason)
+	if err != nil {
+		mp := map[string]interface{}{
+			"Pattern":         pattern,
+			"Reason":          reason,
+			"HasErrorMessage": true,
+			"ErrorMessage":    []string{fmt.Sprintf("ExcludeDomainsMatching failed after %d domains: %v", count, err)},
+		}
+		Render.HTML(w, http.StatusOK, "excludeDomains", mp)
+		return
+	}
+
+	mp := map[string]interface{}{
+		"HasInfoMessage": true,
+		"InfoMessage":    []string{fmt.Sprintf("Excluded %d domains matching %q", count, pattern)},
+	}
+	Render.HTML(w, http.StatusOK, "excludeDomains", mp)
+}
+
+// pendingDomainsLimit is the most pending domains PendingDomainsController
+// lists at once
+var pendingDomainsLimit = 500
+
+func PendingDomainsController(w http.ResponseWriter, req *http.Request) {
+	var infos, errs []string
+	if req.Method == "POST" {
+		err := req.ParseForm()
+		if err != nil {
+			replyServerError(w, err)
+			return
+		}
+
+		action := req.FormValue("action")
+		reason := strings.TrimSpace(req.FormValue("reason"))
+		if reason == "" {
+			reason = "Rejected pending domain"
+		}
+		domains := req.Form["domain"]
+		if len(domains) == 0 {
+			infos = append(infos, "Failed to select any domains")
+		}
+		count := 0
+		for _, domain := range domains {
+			switch action {
+			case "approve":
+				err = DS.ApprovePendingDomain(domain)
+			case "reject":
+				err = DS.RejectPendingDomain(domain, reason)
+			default:
+				err = fmt.Errorf("unknown action %q", action)
+			}
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			count++
+		}
+		if count > 0 {
+			if action == "approve" {
+				infos = append(infos, fmt.Sprintf("Approved %d domains", count))
+			} else {
+				infos = append(infos, fmt.Sprintf("Rejected %d domains", count))
+			}
+		}
+	}
+
+	pending, err := DS.ListPendingDomains(pendingDomainsLimit)
+	if err != nil {
+		replyServerError(w, err)
+		return
+	}
+
+	mp := map[string]interface{}{
+		"Pending":         pending,
+		"HasInfoMessage":  len(infos) > 0,
+		"InfoMessage":     infos,
+		"HasErrorMessage": len(errs) > 0,
+		"ErrorMessage":    errs,
+	}
+	Render.HTML(w, http.StatusOK, "pendingDomains", mp)
+}
+
 // The links and list templates have a hidden form that is used to track the list of previous links
 // so that the previous button works correctly (see https://jira2.iparadigms.com/browse/TRN-134). The
 // same form is used to allow the user to reset the window-length (i.e. number of results per page).
@@ -176,6 +380,20 @@ func processHiddenForm(req *http.Request, sess *Session, isLinks bool) (string,
 	return theLink, encode32(strings.Join(theList, ";")), nil
 }
 
+// TagsController returns the /tags page, reporting on the domains carrying
+// each tag
+func TagsController(w http.ResponseWriter, req *http.Request) {
+	reports, err := DS.TagReports()
+	if err != nil {
+		replyServerError(w, fmt.Errorf("TagReports: %v", err))
+		return
+	}
+	mp := map[string]interface{}{
+		"Reports": reports,
+	}
+	Render.HTML(w, http.StatusOK, "tags", mp)
+}
+
 // ListDomainsController returns pages rooted at /list
 func ListDomainsController(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
@@ -200,7 +418,8 @@ func ListDomainsController(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	query := cassandra.DQ{Limit: session.ListPageWindowLength()}
+	tag := strings.TrimSpace(req.Form.Get("tag"))
+	query := cassandra.DQ{Limit: session.ListPageWindowLength(), Tag: tag}
 	if seed == "" {
 		prevButtonClass = "disabled"
 	} else {
@@ -241,6 +460,7 @@ func ListDomainsController(w http.ResponseWriter, req *http.Request) {
 		"Prev":            prevLink,
 		"PrevList":        prevList,
 		"PageLengthLinks": pageLenDropdown,
+		"Tag":             tag,
 	}
 	Render.HTML(w, http.StatusOK, "list", mp)
 }
@@ -356,6 +576,13 @@ func AddLinkIndexController(w http.ResponseWriter, req *http.Request) {
 	}
 
 	text := linksExt[0]
+	tagText := req.FormValue("tags")
+	var tags []string
+	for _, tag := range strings.Split(tagText, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
 	lines := strings.Split(text, "\n")
 	links := make([]string, 0, len(lines))
 	var errs []string
@@ -378,6 +605,7 @@ func AddLinkIndexController(w http.ResponseWriter, req *http.Request) {
 		mp := map[string]interface{}{
 			"HasText":         true,
 			"Text":            text,
+			"Tags":            tagText,
 			"HasInfoMessage":  true,
 			"InfoMessage":     []string{"No links added"},
 			"HasErrorMessage": true,
@@ -392,7 +620,12 @@ func AddLinkIndexController(w http.ResponseWriter, req *http.Request) {
 		excludeReason = "Manual exclude"
 	}
 
-	errList := DS.InsertLinks(links, excludeReason)
+	var errList []error
+	if len(tags) > 0 {
+		errList = DS.InsertLinksWithTags(links, excludeReason, tags)
+	} else {
+		errList = DS.InsertLinks(links, excludeReason)
+	}
 	if len(errList) != 0 {
 		for _, e := range errList {
 			errs = append(errs, e.Error())
@@ -426,14 +659,9 @@ func AddLinkIndexController(w http.ResponseWriter, req *http.Request) {
 	return
 }
 
-// LinksController returns pages rooted at /links
-//IMPL NOTE: Why does linksController encode the seedURL in base32, rather than URL encode it?
-// The reason is that various components along the way are tripping on the appearance of the
-// seedURL argument. First, it appears that the browser is unencoding the link BEFORE submitting it
-// to the server. That looks like a problem with the browser to me. But in addition, the server appears
-// to be choking on the url-encoded text as well. For example if the url encoded seedURL ends with
-// .html, it appears that this is causing the server to throw a 301. Unknown why that is. But the net effect
-// is that, if I totally disguise the link in base32, everything works.
+// LinksController returns pages rooted at /links. The page after the first
+// is given by the page token of ListLinks, which is base32 so that it can be
+// put in the path as is.
 func LinksController(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	domain := vars["domain"]
@@ -473,68 +701,116 @@ func LinksController(w http.ResponseWriter, req *http.Request) {
 
 	query := cassandra.LQ{Limit: session.LinksPageWindowLength()}
 
-	seedURL := vars["seedURL"]
+	query.PageToken = vars["page"]
 	needHeader := false
 	prevButtonClass := ""
-	if seedURL == "" {
+	if query.PageToken == "" {
 		needHeader = true
 		query.Limit /= 2
 		prevButtonClass = "disabled"
-	} else {
-		ss, err := decode32(seedURL)
-		if err != nil {
-			replyServerError(w, fmt.Errorf("decode32: %v", err))
-			return
-		}
-		query.Seed, err = walker.ParseURL(ss)
-		if err != nil {
-			replyServerError(w, err)
-			return
-		}
 	}
 
 	//
-	// Get the filterRegex if there is one
+	// Get the filters if there are any
 	//
-	filterRegex := ""
-	filterURLSuffix := ""
-	filterRegexSuffix := ""
-	filterRegexArr, filterRegexOk := req.Form["filterRegex"]
-	if filterRegexOk && len(filterRegexArr) > 0 {
-		filterRegex = filterRegexArr[0]
-		filterURLSuffix = "?filterRegex=" + filterRegex
-		query.FilterRegex, err = decode32(filterRegex)
+	filters := linkFilters{
+		Status: req.Form.Get("status"),
+		Error:  req.Form.Get("error"),
+		Robots: req.Form.Get("robots"),
+		After:  req.Form.Get("after"),
+		Before: req.Form.Get("before"),
+		Sort:   req.Form.Get("sort"),
+	}
+	if filterRegex := req.Form.Get("filterRegex"); filterRegex != "" {
+		filters.Regex, err = decode32(filterRegex)
 		if err != nil {
 			replyServerError(w, fmt.Errorf("decode32 error: %v", err))
 			return
 		}
-		filterRegexSuffix = fmt.Sprintf("(filtered by /%s/)", query.FilterRegex)
+	}
+	err = filters.apply(&query)
+	if err != nil {
+		replyServerError(w, err)
+		return
 	}
 
 	//
 	// Lets grab the links
 	//
-	linfos, err := DS.ListLinks(domain, query)
+	page, err := DS.ListLinks(domain, query)
 	if err != nil {
 		replyServerError(w, fmt.Errorf("ListLinks: %v", err))
 		return
 	}
+	linfos := page.Links
+
+	//
+	// Estimate how long the backlog will take to crawl
+	//
+	frontier := ""
+	if needHeader {
+		est, err := DS.FrontierEstimate(domain)
+		if err != nil {
+			replyServerError(w, fmt.Errorf("FrontierEstimate: %v", err))
+			return
+		}
+		frontier = describeFrontier(est)
+	}
+
+	bandwidth := ""
+	if needHeader {
+		usage, err := DS.BandwidthUsage(domain)
+		if err != nil {
+			replyServerError(w, fmt.Errorf("BandwidthUsage: %v", err))
+			return
+		}
+		bandwidth = describeBandwidth(usage)
+	}
+
+	mimeTypes := ""
+	if needHeader {
+		counts, err := DS.MimeTypeCounts(domain)
+		if err != nil {
+			replyServerError(w, fmt.Errorf("MimeTypeCounts: %v", err))
+			return
+		}
+		mimeTypes = describeMimeTypes(counts)
+	}
+
+	domainConfig := &cassandra.DomainConfig{}
+	if needHeader {
+		cfg, err := DS.GetDomainConfig(domain)
+		if err != nil {
+			replyServerError(w, fmt.Errorf("GetDomainConfig: %v", err))
+			return
+		}
+		if cfg != nil {
+			domainConfig = cfg
+		}
+	}
 
 	//
 	// Odds and ends
 	//
-	nextSeedURL := ""
 	nextButtonClass := "disabled"
-	if len(linfos) == query.Limit {
-		// Use the last link result as seed for next page
-		nextSeedURL = encode32(linfos[len(linfos)-1].URL.String())
+	if page.NextPageToken != "" {
 		nextButtonClass = ""
 	}
 
-	var historyLinks []string
+	linkCount := fmt.Sprintf("About %d links", page.EstimatedTotal)
+	if query.PageToken == "" && page.NextPageToken == "" {
+		linkCount = fmt.Sprintf("%d links", page.EstimatedTotal)
+	}
+	if page.Truncated {
+		linkCount = fmt.Sprintf("Only the first %d matching links by path were sorted (see console.max_sorted_links)",
+			page.EstimatedTotal)
+	}
+
+	var historyLinks, getNowLinks []string
 	for _, linfo := range linfos {
-		path := "/historical/" + encode32(linfo.URL.String())
-		historyLinks = append(historyLinks, path)
+		encoded := encode32(linfo.URL.String())
+		historyLinks = append(historyLinks, "/historical/"+encoded)
+		getNowLinks = append(getNowLinks, "/getNow/"+encoded)
 	}
 
 	excludeTag := "Exclude"
@@ -546,6 +822,24 @@ func LinksController(w http.ResponseWriter, req *http.Request) {
 		excludeLink = fmt.Sprintf("/excludeToggle/%s/un", domain)
 	}
 
+	trapTag := "Exclude"
+	trapColor := "red"
+	trapLink := fmt.Sprintf("/trapToggle/%s/exclude", domain)
+	if dinfo.TrapExcluded && len(newTrapPatterns(dinfo)) == 0 {
+		trapTag = "Include"
+		trapColor = "green"
+		trapLink = fmt.Sprintf("/trapToggle/%s/include", domain)
+	}
+
+	pauseTag := "Pause"
+	pauseColor := "green"
+	pauseLink := fmt.Sprintf("/pauseToggle/%s/pause", domain)
+	if dinfo.Paused {
+		pauseTag = "Resume"
+		pauseColor = "red"
+		pauseLink = fmt.Sprintf("/pauseToggle/%s/resume", domain)
+	}
+
 	// set up page length dropdown
 	pageLenDropdown := []dropdownElement{}
 	for _, ln := range PageWindowLengthChoices {
@@ -566,28 +860,51 @@ func LinksController(w http.ResponseWriter, req *http.Request) {
 	// Lets render
 	//
 	mp := map[string]interface{}{
-		"Dinfo":             dinfo,
-		"NumberCrawled":     dinfo.NumberLinksTotal - dinfo.NumberLinksUncrawled,
-		"HasHeader":         needHeader,
-		"HasLinks":          len(linfos) > 0,
-		"Linfos":            linfos,
-		"NextSeedURL":       nextSeedURL,
-		"FilterURLSuffix":   filterURLSuffix,
-		"FilterRegexSuffix": filterRegexSuffix,
+		"Dinfo":           dinfo,
+		"NumberCrawled":   dinfo.NumberLinksTotal - dinfo.NumberLinksUncrawled,
+		"HasHeader":       needHeader,
+		"HasLinks":        len(linfos) > 0,
+		"Linfos":          linfos,
+		"NextPageToken":   page.NextPageToken,
+		"LinkCount":       linkCount,
+		"FilterURLSuffix": filters.urlSuffix(),
+		"FilterSuffix":    filters.describe(),
+		"HasFilters":      filters.active(),
+		"Filters":         filters,
+		"SortLinks":       filters.sortLinks(domain),
+		"Sort":            string(query.Sort),
 
 		"NextButtonClass": nextButtonClass,
 		"PrevButtonClass": prevButtonClass,
 		"HistoryLinks":    historyLinks,
+		"GetNowLinks":     getNowLinks,
 
 		"ExcludeTag":   excludeTag,
 		"ExcludeColor": excludeColor,
 		"ExcludeLink":  excludeLink,
 
+		"PauseTag":   pauseTag,
+		"PauseColor": pauseColor,
+		"PauseLink":  pauseLink,
+
+		"TrapTag":   trapTag,
+		"TrapColor": trapColor,
+		"TrapLink":  trapLink,
+		"Trap":      describeTrap(dinfo),
+
 		"Prev":            prevLink,
 		"PrevList":        prevList,
 		"PageLengthLinks": pageLenDropdown,
 
 		"MaxAllowedPrio": maxAllowedPrio,
+		"Frontier":       frontier,
+		"Bandwidth":      bandwidth,
+		"MimeTypes":      mimeTypes,
+		"CrawlWindow":    describeCrawlWindow(dinfo),
+		"Favicon":        describeFavicon(dinfo),
+		"Robots":         describeRobots(dinfo),
+		"DomainConfig":   domainConfig,
+		"Overrides":      describeDomainConfig(domainConfig),
 
 		"HasInfoMessage":  len(infos) > 0,
 		"InfoMessage":     infos,
@@ -598,6 +915,170 @@ func LinksController(w http.ResponseWriter, req *http.Request) {
 	return
 }
 
+// describeFrontier summarizes a FrontierEstimate for the links page
+func describeFrontier(est *cassandra.FrontierEstimate) string {
+	if est == nil || est.Rate <= 0 {
+		return "Unknown"
+	}
+	round := func(d time.Duration) time.Duration {
+		if d > time.Hour {
+			return d - d%time.Minute
+		}
+		return d - d%time.Second
+	}
+	basis := "over the last hour"
+	if est.RateFromCrawlDelay {
+		basis = "assuming the default crawl delay, since there were no recent fetches"
+	}
+	return fmt.Sprintf("%v (current segment %v), at %.2f fetches/sec %s",
+		round(est.UncrawledDrain), round(est.QueuedDrain), est.Rate, basis)
+}
+
+// describeBandwidth summarizes a BandwidthUsage for the links page
+func describeBandwidth(usage *cassandra.BandwidthUsage) string {
+	if usage == nil {
+		return "Unknown"
+	}
+	if usage.Budget <= 0 {
+		return fmt.Sprintf("No budget (%v fetched in the last %v)", formatBytes(usage.WindowBytes), usage.Window)
+	}
+	return fmt.Sprintf("%v of %v left (%v fetched in the last %v)", formatBytes(usage.Remaining()),
+		formatBytes(usage.Budget), formatBytes(usage.WindowBytes), usage.Window)
+}
+
+// describeMimeTypes summarizes MimeTypeCounts for the links page
+func describeMimeTypes(counts *cassandra.MimeTypeCounts) string {
+	total := counts.Total()
+	if total == 0 {
+		return "No fetches yet"
+	}
+	pct := func(n int64) int64 { return n * 100 / total }
+	return fmt.Sprintf("%d%% html, %d%% pdf, %d%% images, %d%% other (%d fetches)",
+		pct(counts.HTML), pct(counts.PDF), pct(counts.Image), pct(counts.Other), total)
+}
+
+// describeCrawlWindow summarizes the crawl window of dinfo for the links page
+func describeCrawlWindow(dinfo *cassandra.DomainInfo) string {
+	window, err := cassandra.ParseCrawlWindow(dinfo.CrawlWindow, dinfo.CrawlTimezone)
+	if err != nil {
+		return fmt.Sprintf("Invalid (%v)", err)
+	}
+	if window == nil {
+		return "Any time"
+	}
+	tz := dinfo.CrawlTimezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	state := "closed"
+	if window.Contains(time.Now()) {
+		state = "open"
+	}
+	return fmt.Sprintf("%v (%v), currently %v", dinfo.CrawlWindow, tz, state)
+}
+
+// describeTrap summarizes the trap patterns of dinfo for the links page
+func describeTrap(dinfo *cassandra.DomainInfo) string {
+	if !dinfo.TrapExcluded {
+		return fmt.Sprintf("Flagged %v, suggested: %v", dinfo.TrapTime.Format(time.RFC3339),
+			strings.Join(dinfo.TrapPatterns, "  "))
+	}
+	suggested := newTrapPatterns(dinfo)
+	desc := fmt.Sprintf("Flagged %v, excluded: %v", dinfo.TrapTime.Format(time.RFC3339),
+		strings.Join(dinfo.TrapAccepted, "  "))
+	if len(suggested) > 0 {
+		desc += fmt.Sprintf("; newly suggested: %v", strings.Join(suggested, "  "))
+	}
+	return desc
+}
+
+// newTrapPatterns returns the trap patterns of dinfo suggested since the
+// others were accepted, which are not applied until they are excluded too
+func newTrapPatterns(dinfo *cassandra.DomainInfo) []string {
+	accepted := map[string]bool{}
+	for _, p := range dinfo.TrapAccepted {
+		accepted[p] = true
+	}
+	var suggested []string
+	for _, p := range dinfo.TrapPatterns {
+		if !accepted[p] {
+			suggested = append(suggested, p)
+		}
+	}
+	return suggested
+}
+
+// describeFavicon summarizes the favicon of dinfo for the links page
+func describeFavicon(dinfo *cassandra.DomainInfo) string {
+	if dinfo.FaviconURL == "" {
+		return "Not fetched"
+	}
+	when := dinfo.FaviconTime.Format(time.RFC3339)
+	if dinfo.FaviconStatus < 200 || dinfo.FaviconStatus > 299 {
+		return fmt.Sprintf("%v returned %d (%v)", dinfo.FaviconURL, dinfo.FaviconStatus, when)
+	}
+	return fmt.Sprintf("%v (%v, fingerprint %x, %v)", dinfo.FaviconURL, dinfo.FaviconMimeType,
+		uint64(dinfo.FaviconFingerprint), when)
+}
+
+// describeRobots summarizes the robots.txt of dinfo for the links page
+func describeRobots(dinfo *cassandra.DomainInfo) string {
+	if dinfo.RobotsTime.IsZero() {
+		return "Not fetched"
+	}
+	desc := "None"
+	if dinfo.RobotsFingerprint != 0 {
+		desc = fmt.Sprintf("Fingerprint %016x", uint64(dinfo.RobotsFingerprint))
+	}
+	if dinfo.RobotsChanged {
+		return fmt.Sprintf("%v, changed %v", desc, dinfo.RobotsTime.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%v since %v", desc, dinfo.RobotsTime.Format(time.RFC3339))
+}
+
+// describeDomainConfig lists the settings cfg overrides for the links page
+func describeDomainConfig(cfg *cassandra.DomainConfig) string {
+	var overrides []string
+	if cfg.SampleThreshold > 0 {
+		overrides = append(overrides, fmt.Sprintf("sample threshold %d", cfg.SampleThreshold))
+	}
+	if cfg.SamplePercent > 0 {
+		overrides = append(overrides, fmt.Sprintf("sample percent %v", cfg.SamplePercent))
+	}
+	if cfg.ByteBudget > 0 {
+		overrides = append(overrides, fmt.Sprintf("bandwidth budget %v", formatBytes(cfg.ByteBudget)))
+	} else if cfg.ByteBudget < 0 {
+		overrides = append(overrides, "no bandwidth budget")
+	}
+	if cfg.CrawlWindow != "" {
+		overrides = append(overrides, "crawl window")
+	}
+	if cfg.UserAgent != "" {
+		overrides = append(overrides, fmt.Sprintf("user agent %q", cfg.UserAgent))
+	}
+	if cfg.CrawlDelay > 0 {
+		overrides = append(overrides, fmt.Sprintf("crawl delay %v", cfg.CrawlDelay))
+	}
+	if len(overrides) == 0 {
+		return "None"
+	}
+	return strings.Join(overrides, ", ")
+}
+
+// formatBytes formats a byte count with a binary unit, ex. "1.5 GB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 // LinksHistoricalController returns pages rooted at /links
 func LinksHistoricalController(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
@@ -628,14 +1109,338 @@ func LinksHistoricalController(w http.ResponseWriter, req *http.Request) {
 		replyServerError(w, fmt.Errorf("ListLinkHistorical - ToplevelDomainPlusOne (%v): %v", u, err))
 		return
 	}
+
+	// Crawl times to choose from when comparing two crawls, identified by
+	// their time in milliseconds (the precision cassandra stores)
+	var crawlTimes []dropdownElement
+	for _, linfo := range linfos {
+		if linfo.CrawlTime.Equal(walker.NotYetCrawled) {
+			continue
+		}
+		crawlTimes = append(crawlTimes, dropdownElement{
+			Link: strconv.FormatInt(toMillis(linfo.CrawlTime), 10),
+			Text: ftimeFunc(linfo.CrawlTime),
+		})
+	}
+
+	lastCrawlTime := ""
+	if len(crawlTimes) > 0 {
+		lastCrawlTime = crawlTimes[len(crawlTimes)-1].Link
+	}
+
+	// The referrers are stored on the not yet crawled row
+	var firstReferrer, lastReferrer string
+	for _, linfo := range linfos {
+		if linfo.FirstReferrer != "" {
+			firstReferrer = linfo.FirstReferrer
+		}
+		if linfo.LastReferrer != "" {
+			lastReferrer = linfo.LastReferrer
+		}
+	}
+
 	mp := map[string]interface{}{
-		"Domain":    domain,
-		"LinkTopic": u.String(),
-		"Linfos":    linfos,
+		"Domain":        domain,
+		"LinkTopic":     u.String(),
+		"LinkPath":      url,
+		"GetNowLink":    "/getNow/" + url,
+		"Linfos":        linfos,
+		"CrawlTimes":    crawlTimes,
+		"LastCrawlTime": lastCrawlTime,
+		"FirstReferrer": firstReferrer,
+		"LastReferrer":  lastReferrer,
+		"ReferrersLink": "/referrers/" + url,
+	}
+	if firstReferrer != "" {
+		mp["FirstReferrerLink"] = "/historical/" + encode32(firstReferrer)
+	}
+	if lastReferrer != "" {
+		mp["LastReferrerLink"] = "/historical/" + encode32(lastReferrer)
+	}
+
+	// What was known of the link at a given time (in UTC, as sent by a
+	// datetime-local input)
+	if asOf := req.FormValue("asof"); asOf != "" {
+		t, err := time.Parse(asOfFormat, asOf)
+		if err != nil {
+			replyServerError(w, fmt.Errorf("Bad as-of time %q: %v", asOf, err))
+			return
+		}
+		linfo, err := DS.FindLinkAsOf(u, t.Add(time.Minute-time.Millisecond))
+		if err != nil {
+			replyServerError(w, fmt.Errorf("FindLinkAsOf (%v, %v): %v", u, t, err))
+			return
+		}
+		mp["AsOf"] = asOf
+		mp["AsOfTime"] = t
+		mp["AsOfLinfo"] = linfo
 	}
 	Render.HTML(w, http.StatusOK, "historical", mp)
 }
 
+// FingerprintController returns pages rooted at /fingerprint, listing the
+// links whose latest fetch had the given fingerprint: an fnv fingerprint as 16
+// hex digits, or a sha256 one as 64
+func FingerprintController(w http.ResponseWriter, req *http.Request) {
+	fp := strings.ToLower(mux.Vars(req)["fp"])
+	var linfos []*cassandra.LinkInfo
+	var err error
+	switch len(fp) {
+	case 16:
+		var fnv uint64
+		fnv, err = strconv.ParseUint(fp, 16, 64)
+		if err == nil {
+			linfos, err = DS.FindLinksByFingerprint(int64(fnv))
+		}
+	case 64:
+		var sum []byte
+		sum, err = hex.DecodeString(fp)
+		if err == nil {
+			linfos, err = DS.FindLinksBySHA256(sum)
+		}
+	default:
+		err = fmt.Errorf("expected 16 or 64 hex digits")
+	}
+	if err != nil {
+		replyServerError(w, fmt.Errorf("Bad fingerprint %q: %v", fp, err))
+		return
+	}
+
+	var historyLinks []string
+	for _, linfo := range linfos {
+		historyLinks = append(historyLinks, "/historical/"+encode32(linfo.URL.String()))
+	}
+
+	mp := map[string]interface{}{
+		"Fingerprint":  fp,
+		"Linfos":       linfos,
+		"HistoryLinks": historyLinks,
+		"Indexed":      walker.Config.Cassandra.IndexFingerprints,
+	}
+	Render.HTML(w, http.StatusOK, "fingerprint", mp)
+}
+
+// maxReferrers is the most referrers the /referrers page lists
+var maxReferrers = 1000
+
+// ReferrersController returns pages rooted at /referrers, listing the pages
+// the given link was parsed from
+func ReferrersController(w http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["url"]
+	nurl, err := decode32(url)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("decode32 (%s): %v", url, err))
+		return
+	}
+	u, err := walker.ParseURL(nurl)
+	if err != nil {
+		replyServerError(w, err)
+		return
+	}
+
+	refs, err := DS.ListReferrers(u, maxReferrers)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("ListReferrers (%v): %v", u, err))
+		return
+	}
+	var historyLinks []string
+	for _, ref := range refs {
+		historyLinks = append(historyLinks, "/historical/"+encode32(ref.URL.String()))
+	}
+
+	mp := map[string]interface{}{
+		"LinkTopic":    u.String(),
+		"LinkPath":     url,
+		"Referrers":    refs,
+		"HistoryLinks": historyLinks,
+		"Limited":      len(refs) == maxReferrers,
+		"Stored":       walker.Config.Cassandra.StoreReferrers,
+	}
+	Render.HTML(w, http.StatusOK, "referrers", mp)
+}
+
+// maxPolitenessAudits is the most audits the /politeness page lists
+var maxPolitenessAudits = 200
+
+// PolitenessController returns pages rooted at /politeness, listing the most
+// recent politeness audits of the given domain
+func PolitenessController(w http.ResponseWriter, req *http.Request) {
+	domain := mux.Vars(req)["domain"]
+	audits, err := DS.ListPolitenessAudits(domain, maxPolitenessAudits)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("ListPolitenessAudits (%v): %v", domain, err))
+		return
+	}
+
+	mp := map[string]interface{}{
+		"Domain":  domain,
+		"Audits":  audits,
+		"Limited": len(audits) == maxPolitenessAudits,
+		"Stored":  walker.Config.Cassandra.StorePolitenessAudit,
+	}
+	Render.HTML(w, http.StatusOK, "politeness", mp)
+}
+
+// maxDeadLinks is the most links the /deadLinks page lists; the CSV export
+// has them all
+var maxDeadLinks = 1000
+
+// errDeadLinksLimit stops DeadLinksController listing links at maxDeadLinks
+var errDeadLinksLimit = fmt.Errorf("more than %d dead links", maxDeadLinks)
+
+// DeadLinksController returns pages rooted at /deadLinks, listing the links of
+// the given domain whose latest fetch got a 404, 410 or 5XX status, with
+// their referrers
+func DeadLinksController(w http.ResponseWriter, req *http.Request) {
+	domain := mux.Vars(req)["domain"]
+
+	type DeadLink struct {
+		Link         *cassandra.LinkInfo
+		HistoryPath  string
+		ReferrerPath string
+	}
+	var links []DeadLink
+	err := cassandra.ForEachDeadLink(DS, domain, func(linfo *cassandra.LinkInfo) error {
+		if len(links) == maxDeadLinks {
+			return errDeadLinksLimit
+		}
+		u := linfo.URL.String()
+		links = append(links, DeadLink{
+			Link:         linfo,
+			HistoryPath:  "/historical/" + encode32(u),
+			ReferrerPath: "/referrers/" + encode32(u),
+		})
+		return nil
+	})
+	if err != nil && err != errDeadLinksLimit {
+		replyServerError(w, fmt.Errorf("ForEachDeadLink (%v): %v", domain, err))
+		return
+	}
+
+	mp := map[string]interface{}{
+		"Domain":  domain,
+		"Links":   links,
+		"Limited": err == errDeadLinksLimit,
+		"Stored":  walker.Config.Cassandra.StoreReferrers,
+	}
+	Render.HTML(w, http.StatusOK, "deadLinks", mp)
+}
+
+// DeadLinksCSVController serves the dead links of the given domain (see
+// DeadLinksController) as a CSV download
+func DeadLinksCSVController(w http.ResponseWriter, req *http.Request) {
+	domain := mux.Vars(req)["domain"]
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", domain+"-dead-links.csv"))
+	if _, err := cassandra.WriteDeadLinksCSV(w, DS, domain); err != nil {
+		// The header has already been sent, so all we can do is log it
+		log4go.Error("Failed writing dead links of %v: %v", domain, err)
+	}
+}
+
+// SegmentPreviewController returns pages rooted at /segment, showing which
+// links the dispatcher would put in the next segment of the given domain and
+// why the others would be left out
+func SegmentPreviewController(w http.ResponseWriter, req *http.Request) {
+	domain := mux.Vars(req)["domain"]
+	preview, err := DS.PreviewSegment(domain)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("PreviewSegment (%v): %v", domain, err))
+		return
+	}
+
+	type SegmentLink struct {
+		URL         string
+		HistoryPath string
+		LastCrawled time.Time
+		Reason      string
+	}
+	var links []SegmentLink
+	add := func(reason string, linfos []*cassandra.LinkInfo) {
+		for _, l := range linfos {
+			links = append(links, SegmentLink{
+				URL:         l.URL.String(),
+				HistoryPath: "/historical/" + encode32(l.URL.String()),
+				LastCrawled: l.URL.LastCrawled,
+				Reason:      reason,
+			})
+		}
+	}
+	add("Dispatched: marked getnow", preview.GetNow)
+	add("Dispatched: not yet crawled", preview.Uncrawled)
+	add("Dispatched: due for a refresh", preview.Refresh)
+	add("Left out: duplicates a dispatched link after filtering", preview.Duplicates)
+	add("Left out: the segment is full", preview.Deferred)
+	add("Left out: not due for a refresh yet", preview.TooFresh)
+	for from, to := range preview.PermanentRedirects {
+		links = append(links, SegmentLink{
+			URL:         from,
+			HistoryPath: "/historical/" + encode32(from),
+			Reason:      "Left out: permanently redirects to " + to,
+		})
+	}
+
+	mp := map[string]interface{}{
+		"Domain":  domain,
+		"Preview": preview,
+		"Links":   links,
+		"Limit":   walker.Config.Dispatcher.MaxLinksPerSegment,
+	}
+	Render.HTML(w, http.StatusOK, "segment", mp)
+}
+
+// DiffLinkController returns pages rooted at /diff, comparing the crawls of
+// a link at the times given by the before and after form values (in
+// milliseconds since the epoch)
+func DiffLinkController(w http.ResponseWriter, req *http.Request) {
+	url := mux.Vars(req)["url"]
+	nurl, err := decode32(url)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("decode32 (%s): %v", url, err))
+		return
+	}
+	u, err := walker.ParseURL(nurl)
+	if err != nil {
+		replyServerError(w, err)
+		return
+	}
+
+	var times []time.Time
+	for _, field := range []string{"before", "after"} {
+		ms, err := strconv.ParseInt(req.FormValue(field), 10, 64)
+		if err != nil {
+			replyServerError(w, fmt.Errorf("Bad %v crawl time %q: %v", field, req.FormValue(field), err))
+			return
+		}
+		times = append(times, fromMillis(ms))
+	}
+
+	diff, err := DS.DiffLink(u, times[0], times[1])
+	if err != nil {
+		replyServerError(w, fmt.Errorf("DiffLink (%v): %v", u, err))
+		return
+	}
+
+	mp := map[string]interface{}{
+		"LinkTopic": u.String(),
+		"LinkPath":  url,
+		"Diff":      diff,
+	}
+	Render.HTML(w, http.StatusOK, "diff", mp)
+}
+
+// asOfFormat is the format of the time the historical page is asked to show
+// a link as of, that of a datetime-local input
+const asOfFormat = "2006-01-02T15:04"
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
 // FindLinksController returns pages rooted at /findLinks
 func FindLinksController(w http.ResponseWriter, req *http.Request) {
 	if req.Method != "POST" {
@@ -707,10 +1512,11 @@ func FindLinksController(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	var historyLinks []string
+	var historyLinks, getNowLinks []string
 	for _, linfo := range linfos {
-		path := "/historical/" + encode32(linfo.URL.String())
-		historyLinks = append(historyLinks, path)
+		encoded := encode32(linfo.URL.String())
+		historyLinks = append(historyLinks, "/historical/"+encoded)
+		getNowLinks = append(getNowLinks, "/getNow/"+encoded)
 	}
 
 	mp := map[string]interface{}{
@@ -719,6 +1525,7 @@ func FindLinksController(w http.ResponseWriter, req *http.Request) {
 		"DisableButtons": true,
 		"AltTitle":       true,
 		"HistoryLinks":   historyLinks,
+		"GetNowLinks":    getNowLinks,
 
 		"HasInfoMessage":  needInf,
 		"InfoMessage":     info,
@@ -761,6 +1568,95 @@ func ExcludeToggleController(w http.ResponseWriter, req *http.Request) {
 	http.Redirect(w, req, fmt.Sprintf("/links/%s", domain), http.StatusFound)
 }
 
+// PauseToggleController handles web based pausing and resuming of domains
+func PauseToggleController(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	domain := vars["domain"]
+	direction := vars["direction"]
+	if domain == "" || direction == "" {
+		replyServerError(w, fmt.Errorf("Ill formed URL passed when trying to pause or resume domain"))
+		return
+	}
+
+	var err error
+	switch direction {
+	case "pause":
+		err = DS.PauseDomain(domain)
+	case "resume":
+		err = DS.ResumeDomain(domain)
+	default:
+		replyServerError(w, fmt.Errorf("Ill formed URL passed when trying to pause or resume domain"))
+		return
+	}
+	if err != nil {
+		replyServerError(w, err)
+		return
+	}
+
+	http.Redirect(w, req, fmt.Sprintf("/links/%s", domain), http.StatusFound)
+}
+
+// TrapToggleController handles web based applying and lifting of the trap
+// patterns suggested for a domain
+func TrapToggleController(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	domain := vars["domain"]
+	direction := vars["direction"]
+	if domain == "" || (direction != "exclude" && direction != "include") {
+		replyServerError(w, fmt.Errorf("Ill formed URL passed when trying to apply or lift trap patterns"))
+		return
+	}
+
+	err := DS.SetTrapExcluded(domain, direction == "exclude")
+	if err != nil {
+		replyServerError(w, err)
+		return
+	}
+
+	http.Redirect(w, req, fmt.Sprintf("/links/%s", domain), http.StatusFound)
+}
+
+// GetNowController marks a link to be fetched in the next dispatch of its
+// domain
+func GetNowController(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	url := vars["url"]
+	if url == "" {
+		replyServerError(w, fmt.Errorf("GetNowController called without url"))
+		return
+	}
+	nurl, err := decode32(url)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("decode32 (%s): %v", url, err))
+		return
+	}
+	u, err := walker.ParseURL(nurl)
+	if err != nil {
+		replyServerError(w, err)
+		return
+	}
+	domain, err := u.ToplevelDomainPlusOne()
+	if err != nil {
+		replyServerError(w, fmt.Errorf("GetNowController - ToplevelDomainPlusOne (%v): %v", u, err))
+		return
+	}
+
+	session, err := GetSession(w, req)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("GetSession failed: %v", err))
+		return
+	}
+
+	err = DS.MarkGetNow(u)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("MarkGetNow (%v): %v", u, err))
+		return
+	}
+
+	session.AddInfoFlash(fmt.Sprintf("%v will be fetched in the next dispatch of %v", u, domain))
+	http.Redirect(w, req, fmt.Sprintf("/links/%s", domain), http.StatusFound)
+}
+
 // ChangePriorityController handles web-based priority changes.
 func ChangePriorityController(w http.ResponseWriter, req *http.Request) {
 	err := req.ParseForm()
@@ -824,12 +1720,245 @@ func ChangePriorityController(w http.ResponseWriter, req *http.Request) {
 	return
 }
 
+// ChangeDomainConfigController handles web-based changes to the settings a
+// domain overrides (see cassandra.DomainConfig). Empty fields remove the
+// override.
+func ChangeDomainConfigController(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		replyServerError(w, err)
+		return
+	}
+
+	session, err := GetSession(w, req)
+	if err != nil {
+		replyServerError(w, fmt.Errorf("GetSession failed: %v", err))
+		return
+	}
+
+	domain := req.Form.Get("domain")
+	if domain == "" {
+		replyServerError(w, fmt.Errorf("domain inexplicably is NOT in the hidden form"))
+		return
+	}
+	redirect := func() {
+		http.Redirect(w, req, fmt.Sprintf("/links/%s", domain), http.StatusFound)
+	}
+
+	cfg := &cassandra.DomainConfig{
+		CrawlWindow:   strings.TrimSpace(req.Form.Get("window")),
+		CrawlTimezone: strings.TrimSpace(req.Form.Get("timezone")),
+		UserAgent:     strings.TrimSpace(req.Form.Get("user_agent")),
+	}
+	if str := strings.TrimSpace(req.Form.Get("sample_threshold")); str != "" {
+		cfg.SampleThreshold, err = strconv.Atoi(str)
+		if err != nil {
+			session.AddErrorFlash(fmt.Sprintf("Failed to parse sample threshold %q", str))
+			redirect()
+			return
+		}
+	}
+	if str := strings.TrimSpace(req.Form.Get("sample_percent")); str != "" {
+		percent, err := strconv.ParseFloat(str, 32)
+		if err != nil {
+			session.AddErrorFlash(fmt.Sprintf("Failed to parse sample percent %q", str))
+			redirect()
+			return
+		}
+		cfg.SamplePercent = float32(percent)
+	}
+	if str := strings.TrimSpace(req.Form.Get("byte_budget")); str != "" {
+		cfg.ByteBudget, err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			session.AddErrorFlash(fmt.Sprintf("Failed to parse bandwidth budget %q", str))
+			redirect()
+			return
+		}
+	}
+	if str := strings.TrimSpace(req.Form.Get("crawl_delay")); str != "" {
+		cfg.CrawlDelay, err = time.ParseDuration(str)
+		if err != nil {
+			session.AddErrorFlash(fmt.Sprintf("Failed to parse crawl delay %q", str))
+			redirect()
+			return
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		session.AddErrorFlash(err.Error())
+		redirect()
+		return
+	}
+
+	err = DS.SetDomainConfig(domain, cfg)
+	if err != nil {
+		err = fmt.Errorf("SetDomainConfig failed: %v", err)
+		replyServerError(w, err)
+		return
+	}
+
+	session.AddInfoFlash(fmt.Sprintf("Updated the configuration of %v", domain))
+	redirect()
+	return
+}
+
+// linkFilters holds the filters of a links page (see cassandra.LQ) as they
+// appear in its query string, except Regex which is decoded from base32. The
+// order of the page is kept along with them.
+type linkFilters struct {
+	Regex  string
+	Status string
+	Error  string // "yes", "no" or "" for either
+	Robots string // "yes", "no" or "" for either
+	After  string // filterDateFormat
+	Before string // filterDateFormat
+	Sort   string // a cassandra.LinkSort, or "" for the default
+}
+
+// filterDateFormat is the format of the crawl time range links filters
+const filterDateFormat = "2006-01-02"
+
+// apply sets the filters on query, returning an error if any of them are
+// malformed
+func (lf linkFilters) apply(query *cassandra.LQ) error {
+	if lf.Regex != "" {
+		if _, err := regexp.Compile(lf.Regex); err != nil {
+			return fmt.Errorf("Failed to compile regex %q: %v", lf.Regex, err)
+		}
+		query.FilterRegex = lf.Regex
+	}
+	if lf.Status != "" {
+		status, err := strconv.Atoi(lf.Status)
+		if err != nil || status <= 0 {
+			return fmt.Errorf("Bad status code %q", lf.Status)
+		}
+		query.StatusFilter = status
+	}
+
+	yesNo := func(name, value string) (*bool, error) {
+		switch value {
+		case "":
+			return nil, nil
+		case "yes", "no":
+			b := value == "yes"
+			return &b, nil
+		}
+		return nil, fmt.Errorf("Bad %s filter %q, expected yes or no", name, value)
+	}
+	var err error
+	query.HasError, err = yesNo("error", lf.Error)
+	if err != nil {
+		return err
+	}
+	query.RobotsExcluded, err = yesNo("robots", lf.Robots)
+	if err != nil {
+		return err
+	}
+
+	if lf.After != "" {
+		query.CrawledAfter, err = time.Parse(filterDateFormat, lf.After)
+		if err != nil {
+			return fmt.Errorf("Bad crawled after date %q, expected YYYY-MM-DD", lf.After)
+		}
+	}
+	if lf.Before != "" {
+		query.CrawledBefore, err = time.Parse(filterDateFormat, lf.Before)
+		if err != nil {
+			return fmt.Errorf("Bad crawled before date %q, expected YYYY-MM-DD", lf.Before)
+		}
+	}
+
+	switch sort := cassandra.LinkSort(lf.Sort); sort {
+	case "", cassandra.SortByPath, cassandra.SortByCrawlTime, cassandra.SortByStatus:
+		query.Sort = sort
+	default:
+		return fmt.Errorf("Bad sort %q, expected path, time or status", lf.Sort)
+	}
+	return nil
+}
+
+// sortLinks returns links to the first page of the links of domain with
+// these filters, sorted each way the links page can be
+func (lf linkFilters) sortLinks(domain string) map[string]string {
+	links := map[string]string{}
+	for _, sort := range []cassandra.LinkSort{cassandra.SortByPath, cassandra.SortByCrawlTime, cassandra.SortByStatus} {
+		sorted := lf
+		sorted.Sort = string(sort)
+		links[string(sort)] = "/links/" + domain + sorted.urlSuffix()
+	}
+	return links
+}
+
+// active returns true if any filter is set; the order of the page doesn't
+// count
+func (lf linkFilters) active() bool {
+	unsorted := lf
+	unsorted.Sort = ""
+	return unsorted != linkFilters{}
+}
+
+// urlSuffix returns the query string that sets these filters on a links page
+func (lf linkFilters) urlSuffix() string {
+	var params []string
+	if lf.Regex != "" {
+		params = append(params, "filterRegex="+encode32(lf.Regex))
+	}
+	for _, p := range []struct{ name, value string }{
+		{"status", lf.Status},
+		{"error", lf.Error},
+		{"robots", lf.Robots},
+		{"after", lf.After},
+		{"before", lf.Before},
+		{"sort", lf.Sort},
+	} {
+		if p.value != "" {
+			params = append(params, p.name+"="+url.QueryEscape(p.value))
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + strings.Join(params, "&")
+}
+
+// describe summarizes the filters for the links page header
+func (lf linkFilters) describe() string {
+	var desc []string
+	if lf.Regex != "" {
+		desc = append(desc, fmt.Sprintf("/%s/", lf.Regex))
+	}
+	if lf.Status != "" {
+		desc = append(desc, "status "+lf.Status)
+	}
+	switch lf.Error {
+	case "yes":
+		desc = append(desc, "with errors")
+	case "no":
+		desc = append(desc, "without errors")
+	}
+	switch lf.Robots {
+	case "yes":
+		desc = append(desc, "excluded by robots.txt")
+	case "no":
+		desc = append(desc, "not excluded by robots.txt")
+	}
+	if lf.After != "" {
+		desc = append(desc, "crawled on or after "+lf.After)
+	}
+	if lf.Before != "" {
+		desc = append(desc, "crawled before "+lf.Before)
+	}
+	if len(desc) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(filtered by %s)", strings.Join(desc, ", "))
+}
+
 // FilterLinksController returns pages rooted at /filterLinks
 func FilterLinksController(w http.ResponseWriter, req *http.Request) {
 	if req.Method != "POST" {
 		mp := map[string]interface{}{
 			"InputDomainValue": "",
-			"InputRegexValue":  "",
+			"Filters":          linkFilters{},
 		}
 		Render.HTML(w, http.StatusOK, "filterLinks", mp)
 		return
@@ -848,6 +1977,14 @@ func FilterLinksController(w http.ResponseWriter, req *http.Request) {
 		replyServerError(w, err)
 		return
 	}
+	filters := linkFilters{
+		Regex:  regex[0],
+		Status: strings.TrimSpace(req.Form.Get("status")),
+		Error:  req.Form.Get("error"),
+		Robots: req.Form.Get("robots"),
+		After:  strings.TrimSpace(req.Form.Get("after")),
+		Before: strings.TrimSpace(req.Form.Get("before")),
+	}
 
 	dinfo, err := DS.FindDomain(domain[0])
 	if dinfo == nil || err != nil {
@@ -860,26 +1997,25 @@ func FilterLinksController(w http.ResponseWriter, req *http.Request) {
 			"HasErrorMessage":  true,
 			"ErrorMessage":     []string{estring},
 			"InputDomainValue": domain[0],
-			"InputRegexValue":  regex[0],
+			"Filters":          filters,
 		}
 		Render.HTML(w, http.StatusOK, "filterLinks", mp)
 		return
 	}
 
-	_, err = regexp.Compile(regex[0])
+	err = filters.apply(&cassandra.LQ{})
 	if err != nil {
-		err = fmt.Errorf("Failed to compile regex %q: %v", regex[0], err)
 		mp := map[string]interface{}{
 			"HasErrorMessage":  true,
 			"ErrorMessage":     []string{err.Error()},
 			"InputDomainValue": domain[0],
-			"InputRegexValue":  regex[0],
+			"Filters":          filters,
 		}
 		Render.HTML(w, http.StatusOK, "filterLinks", mp)
 		return
 	}
 
-	url := fmt.Sprintf("/links/%s?filterRegex=%s", domain[0], encode32(regex[0]))
+	url := "/links/" + domain[0] + filters.urlSuffix()
 	http.Redirect(w, req, url, http.StatusSeeOther)
 	return
 }
@@ -5,11 +5,15 @@ package console
 */
 
 import (
+	"fmt"
 	"html/template"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"encoding/base32"
+	"encoding/hex"
 
 	"code.google.com/p/log4go"
 	"github.com/gocql/gocql"
@@ -58,6 +62,47 @@ func ftime2Func(t time.Time) string {
 	return t.Format(timeFormat)
 }
 
+// fdurFunc formats a duration for display, rounded to the millisecond for
+// anything over a second. Zero durations are shown blank.
+func fdurFunc(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	if d > time.Second {
+		d = d - d%time.Millisecond
+	}
+	return d.String()
+}
+
+// fheaderFunc formats headers as sorted "Name: value" lines
+func fheaderFunc(h http.Header) []string {
+	var lines []string
+	for k, v := range h {
+		lines = append(lines, fmt.Sprintf("%v: %v", k, strings.Join(v, ", ")))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// fpercentFunc formats a fraction as a percentage
+func fpercentFunc(f float64) string {
+	return fmt.Sprintf("%.1f%%", 100*f)
+}
+
+// ffnvFunc formats an fnv fingerprint as hex, as the /fingerprint page takes
+// it. Zero (no fingerprint) is shown blank.
+func ffnvFunc(fp int64) string {
+	if fp == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%016x", uint64(fp))
+}
+
+// fshaFunc formats a sha256 fingerprint as hex
+func fshaFunc(sum []byte) string {
+	return hex.EncodeToString(sum)
+}
+
 func fuuidFunc(u gocql.UUID) string {
 	if u == zeroUUID {
 		return ""
@@ -82,6 +127,11 @@ func BuildRender() {
 				"ftime":       ftimeFunc,
 				"ftime2":      ftime2Func,
 				"fuuid":       fuuidFunc,
+				"fdur":        fdurFunc,
+				"fheader":     fheaderFunc,
+				"fpercent":    fpercentFunc,
+				"ffnv":        ffnvFunc,
+				"fsha":        fshaFunc,
 				"statusText":  http.StatusText,
 				"yesOnTrue":   yesOnTrueFunc,
 			},
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"code.google.com/p/log4go"
+	"github.com/gorilla/mux"
 )
 
 //
@@ -23,6 +25,7 @@ import (
 func RestRoutes() []Route {
 	return []Route{
 		Route{Path: "/rest/add", Controller: RestAdd},
+		Route{Path: "/rest/stats/{domain}", Controller: RestDomainStats},
 	}
 }
 
@@ -45,6 +48,9 @@ type restAddRequest struct {
 	Links   []struct {
 		URL string `json:"url"`
 	} `json:"links"`
+
+	// Tags to add to the domains of the links, optional
+	Tags []string `json:"tags"`
 }
 
 // RestAdd manages the rest endpoint rooted at /rest/add.
@@ -73,7 +79,12 @@ func RestAdd(w http.ResponseWriter, req *http.Request) {
 		links = append(links, u)
 	}
 
-	errList := DS.InsertLinks(links, "")
+	var errList []error
+	if len(adds.Tags) > 0 {
+		errList = DS.InsertLinksWithTags(links, "", adds.Tags)
+	} else {
+		errList = DS.InsertLinks(links, "")
+	}
 	if len(errList) != 0 {
 		var buffer bytes.Buffer
 		for _, e := range errList {
@@ -87,3 +98,75 @@ func RestAdd(w http.ResponseWriter, req *http.Request) {
 	Render.JSON(w, http.StatusOK, "")
 	return
 }
+
+type restDomainStatsResponse struct {
+	Version int    `json:"version"`
+	Domain  string `json:"domain"`
+
+	Links          int `json:"links"`
+	UncrawledLinks int `json:"uncrawled_links"`
+
+	// Keyed by status code, as a string since JSON keys must be
+	StatusCounts map[string]int `json:"status_counts"`
+
+	Fetches     int64 `json:"fetches"`
+	FetchErrors int64 `json:"fetch_errors"`
+	Bytes       int64 `json:"bytes"`
+
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+	TimedFetches     int     `json:"timed_fetches"`
+
+	Dispatches []restDispatch   `json:"dispatches"`
+	TopErrors  []restErrorCount `json:"top_errors"`
+}
+
+type restDispatch struct {
+	Time  time.Time `json:"time"`
+	Links int       `json:"links"`
+}
+
+type restErrorCount struct {
+	Error string `json:"error"`
+	Count int    `json:"count"`
+}
+
+// RestDomainStats manages the rest endpoint rooted at /rest/stats, returning
+// the statistics of a domain (see cassandra.DomainStats).
+func RestDomainStats(w http.ResponseWriter, req *http.Request) {
+	domain := mux.Vars(req)["domain"]
+	stats, err := DS.DomainStats(domain)
+	if err != nil {
+		log4go.Error("RestDomainStats failed for %v: %v", domain, err)
+		Render.JSON(w, http.StatusInternalServerError, buildError("domain-stats-error", "%v", err))
+		return
+	}
+	if stats == nil {
+		Render.JSON(w, http.StatusNotFound, buildError("domain-not-found", "Domain %v not found", domain))
+		return
+	}
+
+	res := restDomainStatsResponse{
+		Version:          1,
+		Domain:           stats.Domain,
+		Links:            stats.Links,
+		UncrawledLinks:   stats.UncrawledLinks,
+		StatusCounts:     map[string]int{},
+		Fetches:          stats.Fetches,
+		FetchErrors:      stats.FetchErrors,
+		Bytes:            stats.Bytes,
+		AverageLatencyMs: float64(stats.AverageLatency) / float64(time.Millisecond),
+		TimedFetches:     stats.TimedFetches,
+		Dispatches:       []restDispatch{},
+		TopErrors:        []restErrorCount{},
+	}
+	for status, n := range stats.StatusCounts {
+		res.StatusCounts[fmt.Sprintf("%d", status)] = n
+	}
+	for _, d := range stats.Dispatches {
+		res.Dispatches = append(res.Dispatches, restDispatch{Time: d.Time, Links: d.Links})
+	}
+	for _, e := range stats.TopErrors {
+		res.TopErrors = append(res.TopErrors, restErrorCount{Error: e.Error, Count: e.Count})
+	}
+	Render.JSON(w, http.StatusOK, res)
+}
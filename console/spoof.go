@@ -110,6 +110,14 @@ func spoofDataLong() {
 		}
 	}
 
+	for i := 0; i < 5; i++ {
+		domain := fmt.Sprintf("p%d.com", i)
+		err := db.Query(`UPDATE pending_domains SET refs = refs + ? WHERE dom = ?`, int64(i+1), domain).Exec()
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	for i := 0; i < 10; i++ {
 		domain := fmt.Sprintf("y%d.com", i)
 		err := db.Query(insertDomainInfo, domain).Exec()
@@ -2,14 +2,18 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/mux"
@@ -108,11 +112,15 @@ func TestLayout(t *testing.T) {
 
 	// Make sure the main menu is there
 	mainLinks := map[string]string{
-		"/list":        "List",
-		"/find":        "Find Domains",
-		"/findLinks":   "Find Links",
-		"/add":         "Add",
-		"/filterLinks": "Filter Links",
+		"/dashboard":      "Dashboard",
+		"/list":           "List",
+		"/find":           "Find Domains",
+		"/findLinks":      "Find Links",
+		"/add":            "Add",
+		"/filterLinks":    "Filter Links",
+		"/excludeDomains": "Exclude Domains",
+		"/pendingDomains": "Pending Domains",
+		"/tags":           "Tags",
 	}
 	sub := doc.Find("nav ul li a")
 	if sub.Size() != len(mainLinks) {
@@ -217,6 +225,66 @@ func TestHome(t *testing.T) {
 	})
 }
 
+func TestDashboard(t *testing.T) {
+	spoofData()
+	doc, body, status := callController("http://localhost:3000/dashboard", "", "/dashboard", console.DashboardController)
+	if status != http.StatusOK {
+		t.Errorf("TestDashboard bad status code got %d, expected %d", status, http.StatusOK)
+		t.Log(body)
+		t.FailNow()
+	}
+
+	labels := []string{
+		"Domains",
+		"Dispatched Domains",
+		"Total Links",
+		"Uncrawled Links",
+		"Queued Links",
+		"Fetches in the Last Hour",
+		"Active Fetchers",
+		"Dispatcher Scan",
+	}
+	sub := doc.Find(".container table").First().Find("tbody tr")
+	if sub.Size() != len(labels) {
+		t.Fatalf("[.container table tbody tr] Size mismatch got %d, expected %d", sub.Size(), len(labels))
+	}
+	sub.Each(func(index int, sel *goquery.Selection) {
+		text := strings.TrimSpace(sel.Find("td").First().Text())
+		if text != labels[index] {
+			t.Errorf("[.container table tbody tr] Label mismatch got %q, expected %q", text, labels[index])
+		}
+	})
+
+	if !strings.Contains(body, "window.location.reload()") {
+		t.Errorf("Expected dashboard to auto-refresh")
+	}
+}
+
+func TestExcludeDomains(t *testing.T) {
+	spoofData()
+	_, body, status := callController("http://localhost:3000/excludeDomains", "pattern=%5Enosuchdomain%5C.com%24&reason=testing",
+		"/excludeDomains", console.ExcludeDomainsController)
+	if status != http.StatusOK {
+		t.Errorf("TestExcludeDomains bad status code got %d, expected %d", status, http.StatusOK)
+		t.Log(body)
+		t.FailNow()
+	}
+	if !strings.Contains(body, "Excluded 0 domains") {
+		t.Errorf("TestExcludeDomains expected info message reporting 0 excluded domains")
+		t.Log(body)
+	}
+
+	_, body, status = callController("http://localhost:3000/excludeDomains", "pattern=%28", "/excludeDomains",
+		console.ExcludeDomainsController)
+	if status != http.StatusOK {
+		t.Fatalf("TestExcludeDomains bad status code got %d, expected %d", status, http.StatusOK)
+	}
+	if !strings.Contains(body, "Bad domain pattern") {
+		t.Errorf("TestExcludeDomains expected error message for bad pattern")
+		t.Log(body)
+	}
+}
+
 func TestListDomainsWeb(t *testing.T) {
 	spoofData()
 	doc, body, status := callController("http://localhost:3000/list", "", "/list", console.ListDomainsController)
@@ -340,12 +408,22 @@ func TestListLinksWeb(t *testing.T) {
 	domainKeys := []string{
 		"Domain",
 		"Exclude Reason (if excluded)",
+		"Paused",
 		"Last Claimed By Fetcher",
 		"Current Fetcher Claim ID",
 		"Total Unique Links",
 		"Links Dispatched",
 		"Unique Links Crawled",
 		"Unique Links Not Yet Crawled",
+		"Estimated Time to Crawl Backlog",
+		"Bandwidth Budget",
+		"Content Types",
+		"Crawl Window",
+		"Favicon",
+		"Robots.txt",
+		"Next Segment",
+		"Politeness",
+		"Config Overrides",
 		"Priority",
 	}
 
@@ -378,10 +456,12 @@ func TestListLinksWeb(t *testing.T) {
 	//
 	linksColHeaders := []string{
 		"Link",
+		"Title",
 		"Status",
 		"Error?",
 		"Excluded by robots.txt?",
 		"Last Fetch",
+		"Recrawl",
 	}
 
 	sub = linksTable.Find("thead th")
@@ -398,7 +478,7 @@ func TestListLinksWeb(t *testing.T) {
 		count++
 	})
 
-	linkRows := linksTable.Find("tbody tr td a")
+	linkRows := linksTable.Find("tbody tr td:first-child a")
 	if linkRows.Size() < 5 {
 		t.Fatalf("[.container table tbody tr td a] Row count mismatch got %d, expected %d", linkRows.Size(), 5)
 	}
@@ -538,7 +618,7 @@ func TestListLinksSecondPage(t *testing.T) {
 	// OK now click on the next button
 	//
 	nextPage := "http://localhost:3000" + nextPagePath
-	doc, body, status = callController(nextPage, "", "/links/{domain}/{seedURL}", console.LinksController)
+	doc, body, status = callController(nextPage, "", "/links/{domain}/{page}", console.LinksController)
 	if status != http.StatusOK {
 		t.Errorf("TestListLinks bad status code got %d, expected %d, link %v",
 			status, http.StatusOK, nextPage)
@@ -558,10 +638,12 @@ func TestListLinksSecondPage(t *testing.T) {
 	//
 	linksColHeaders := []string{
 		"Link",
+		"Title",
 		"Status",
 		"Error?",
 		"Excluded by robots.txt?",
 		"Last Fetch",
+		"Recrawl",
 	}
 	sub := linksTable.Find("thead th")
 	count := 0
@@ -574,7 +656,7 @@ func TestListLinksSecondPage(t *testing.T) {
 		count++
 	})
 
-	linkRows := linksTable.Find("tbody tr td a")
+	linkRows := linksTable.Find("tbody tr td:first-child a")
 	if linkRows.Size() < 5 {
 		t.Fatalf("[.container table tbody tr td a] not enough rows")
 	}
@@ -715,7 +797,17 @@ func TestListHistorical(t *testing.T) {
 		"Fetched On",
 		"Robots Excluded",
 		"Status",
+		"Title",
+		"Description",
+		"DNS",
+		"Connect",
+		"TLS",
+		"TTFB",
+		"Transfer",
+		"Bytes",
+		"Request Headers",
 		"Error",
+		"Fingerprints",
 	}
 	count := 0
 	tables.Find("thead th").Each(func(index int, sel *goquery.Selection) {
@@ -954,8 +1046,8 @@ func TestFindLinks(t *testing.T) {
 	}
 
 	res := doc.Find(".container table tbody tr td")
-	if res.Size() != 5 {
-		t.Errorf("[.container table tbody tr td] Size mismatch got %d, expected 1", res.Size())
+	if res.Size() != 7 {
+		t.Errorf("[.container table tbody tr td] Size mismatch got %d, expected 7", res.Size())
 	}
 	res.First().Each(func(index int, sel *goquery.Selection) {
 		text := strings.TrimSpace(sel.Text())
@@ -1054,8 +1146,8 @@ func TestAddLinks(t *testing.T) {
 		t.Fatalf("")
 	}
 	res = doc.Find(".container table tbody tr td")
-	if res.Size() != 5 {
-		t.Errorf("[.container table tbody tr td] Bad size got %d, expected 5", res.Size())
+	if res.Size() != 7 {
+		t.Errorf("[.container table tbody tr td] Bad size got %d, expected 7", res.Size())
 	}
 	res.First().Each(func(index int, sel *goquery.Selection) {
 		text := strings.TrimSpace(sel.Text())
@@ -1081,8 +1173,13 @@ func TestFilterLinks(t *testing.T) {
 	}
 
 	expectedLabels := map[string]bool{
-		"Domain":     true,
-		"Link Regex": true,
+		"Domain":          true,
+		"Link Regex":      true,
+		"Status Code":     true,
+		"Error":           true,
+		"Robots Excluded": true,
+		"Crawled After":   true,
+		"Crawled Before":  true,
 	}
 	doc.Find(".container .row h3").Each(func(index int, sel *goquery.Selection) {
 		text := strings.TrimSpace(sel.Text())
@@ -1093,8 +1190,8 @@ func TestFilterLinks(t *testing.T) {
 	})
 
 	sub := doc.Find(".container .box input[type=text]")
-	if sub.Size() != 2 {
-		t.Errorf("[.container .box input[type=text]] Size mismatch got %d, expected 2", sub.Size())
+	if sub.Size() != 5 {
+		t.Errorf("[.container .box input[type=text]] Size mismatch got %d, expected 5", sub.Size())
 	}
 
 	sub = doc.Find(".container input[type=submit]")
@@ -1145,6 +1242,75 @@ func TestFilterLinks(t *testing.T) {
 		t.Fatalf("[.container .row .col-xs-6 h2] Expected to find %q, but actually found %q", expected, sub.Text())
 	}
 
+	// The other filters are carried in the redirect too
+	rawBody = "domain=t1.com&regex=html&status=200&error=no&robots=&after=2014-01-02&before="
+	doc, body, status, emp = callControllerFull("http://localhost:3000/filterLinks", rawBody, "/filterLinks", console.FilterLinksController)
+	if status != http.StatusSeeOther {
+		t.Fatalf("TestFilterLinks bad status code got %d, expected %d", status, http.StatusSeeOther)
+	}
+	expectedLoc = "/links/t1.com?filterRegex=NB2G23A=&status=200&error=no&after=2014-01-02"
+	if loc := emp["headers"].(http.Header).Get("Location"); loc != expectedLoc {
+		t.Fatalf("TestFilterLinks redirect url got %q, but expected %q", loc, expectedLoc)
+	}
+
+	expected = "Links for domain t1.com (filtered by status 200, without errors)"
+	doc, body, status = callController("http://localhost:3000/links/t1.com?status=200&error=no", "",
+		"/links/{domain}", console.LinksController)
+	sub = doc.Find(".container .row .col-xs-6 h2")
+	if sub.Size() != 1 {
+		t.Fatalf("[.container .row .col-xs-6 h2] Failed to find header message")
+	} else if !strings.Contains(sub.Text(), expected) {
+		t.Fatalf("[.container .row .col-xs-6 h2] Expected to find %q, but actually found %q", expected, sub.Text())
+	}
+
+	// Bad filters are reported on the filter page
+	rawBody = "domain=t1.com&regex=&status=abc"
+	doc, body, status = callController("http://localhost:3000/filterLinks", rawBody, "/filterLinks", console.FilterLinksController)
+	if status != http.StatusOK {
+		t.Fatalf("TestFilterLinks bad status code got %d, expected %d", status, http.StatusOK)
+	}
+	if !strings.Contains(body, "Bad status code") {
+		t.Errorf("TestFilterLinks expected bad status code error, got body %v", body)
+	}
+}
+
+func TestSortLinks(t *testing.T) {
+	spoofData()
+
+	doc, body, status := callController("http://localhost:3000/links/t1.com?status=200&sort=status", "",
+		"/links/{domain}", console.LinksController)
+	if status != http.StatusOK {
+		t.Log(body)
+		t.Fatalf("TestSortLinks bad status code got %d, expected %d", status, http.StatusOK)
+	}
+
+	expected := []string{
+		"/links/t1.com?status=200&sort=path",
+		"/links/t1.com?status=200&sort=status",
+		"/links/t1.com?status=200&sort=time",
+	}
+	var got []string
+	doc.Find(".container table thead a").Each(func(index int, sel *goquery.Selection) {
+		href, _ := sel.Attr("href")
+		got = append(got, href)
+	})
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("TestSortLinks sort links got %v, expected %v", got, expected)
+	}
+
+	sorted := doc.Find(".container table thead th").FilterFunction(func(index int, sel *goquery.Selection) bool {
+		return strings.Contains(sel.Text(), "\u25b2") || strings.Contains(sel.Text(), "\u25bc")
+	})
+	if sorted.Size() != 1 || !strings.Contains(sorted.Text(), "Status") {
+		t.Errorf("TestSortLinks expected only the Status column marked as sorted, got %q", sorted.Text())
+	}
+
+	_, body, status = callController("http://localhost:3000/links/t1.com?sort=size", "",
+		"/links/{domain}", console.LinksController)
+	if status != http.StatusInternalServerError {
+		t.Errorf("TestSortLinks bad status code for an unknown sort got %d, expected %d",
+			status, http.StatusInternalServerError)
+	}
 }
 
 func TestChangePriority(t *testing.T) {
@@ -1216,6 +1382,110 @@ func TestChangePriority(t *testing.T) {
 	}
 }
 
+func TestChangeDomainConfig(t *testing.T) {
+	spoofData()
+
+	domainRow := func(name string) string {
+		doc, body, status := callController("http://localhost:3000/links/t1.com", "", "/links/{domain}",
+			console.LinksController)
+		if status != http.StatusOK {
+			t.Log(body)
+			t.Fatalf("TestChangeDomainConfig bad status code got %d, expected %d", status, http.StatusOK)
+		}
+		sub := doc.Find(".container .row table tr").FilterFunction(func(index int, sel *goquery.Selection) bool {
+			return strings.Contains(sel.Find("td").First().Text(), name)
+		})
+		if sub.Size() < 1 {
+			t.Fatalf("Failed to find %v row", name)
+		}
+		return strings.TrimSpace(sub.Find("td:nth-child(2)").Text())
+	}
+	changeConfig := func(rawBody string) {
+		_, _, status := callController("http://localhost:3000/changeDomainConfig", rawBody, "/changeDomainConfig",
+			console.ChangeDomainConfigController)
+		if status != http.StatusFound {
+			t.Fatalf("TestChangeDomainConfig bad status code got %d, expected %d", status, http.StatusFound)
+		}
+	}
+
+	if row := domainRow("Crawl Window"); row != "Any time" {
+		t.Errorf("Expected no initial crawl window, got %q", row)
+	}
+	if row := domainRow("Config Overrides"); row != "None" {
+		t.Errorf("Expected no initial overrides, got %q", row)
+	}
+
+	changeConfig("domain=t1.com&sample_threshold=100&sample_percent=10&byte_budget=" +
+		"&window=Mon-Fri+01%3A00-05%3A00&timezone=America%2FNew_York")
+	if row := domainRow("Crawl Window"); !strings.HasPrefix(row, "Mon-Fri 01:00-05:00 (America/New_York)") {
+		t.Errorf("Expected the crawl window to be set, got %q", row)
+	}
+	expected := "sample threshold 100, sample percent 10, crawl window"
+	if row := domainRow("Config Overrides"); row != expected {
+		t.Errorf("Expected overrides %q, got %q", expected, row)
+	}
+
+	// Invalid settings are rejected and leave the old ones in place
+	for _, rawBody := range []string{
+		"domain=t1.com&window=sometimes",
+		"domain=t1.com&sample_percent=101",
+		"domain=t1.com&sample_threshold=many",
+	} {
+		changeConfig(rawBody)
+		if row := domainRow("Config Overrides"); row != expected {
+			t.Errorf("Expected %q to be rejected, got overrides %q", rawBody, row)
+		}
+	}
+
+	// Empty fields clear the overrides
+	changeConfig("domain=t1.com&sample_threshold=&sample_percent=&byte_budget=&window=&timezone=")
+	if row := domainRow("Config Overrides"); row != "None" {
+		t.Errorf("Expected overrides to be cleared, got %q", row)
+	}
+}
+
+func TestGetNow(t *testing.T) {
+	spoofData()
+
+	doc, body, status := callController("http://localhost:3000/links/t1.com", "", "/links/{domain}",
+		console.LinksController)
+	if status != http.StatusOK {
+		t.Errorf("TestGetNow bad status code got %d, expected %d", status, http.StatusOK)
+		t.Log(body)
+		t.FailNow()
+	}
+
+	row := doc.Find(".container table").Last().Find("tbody tr").First()
+	link := strings.TrimSpace(row.Find("td").First().Text())
+	getNowLink, ok := row.Find("td:last-child a").Attr("href")
+	if !ok || !strings.HasPrefix(getNowLink, "/getNow/") {
+		t.Fatalf("[.container table tbody tr td:last-child a] Failed to find /getNow link, got %q", getNowLink)
+	}
+
+	_, body, status, mp := callControllerFull("http://localhost:3000"+getNowLink, "", "/getNow/{url}",
+		console.GetNowController)
+	if status != http.StatusFound {
+		t.Errorf("TestGetNow bad status code got %d, expected %d", status, http.StatusFound)
+		t.Log(body)
+		t.FailNow()
+	}
+	if loc := mp["headers"].(http.Header).Get("Location"); loc != "/links/t1.com" {
+		t.Errorf("Expected redirect to /links/t1.com, got %q", loc)
+	}
+
+	u, err := walker.ParseURL(link)
+	if err != nil {
+		t.Fatalf("Failed to parse link %q: %v", link, err)
+	}
+	linfos, err := console.DS.ListLinkHistorical(u)
+	if err != nil {
+		t.Fatalf("ListLinkHistorical failed: %v", err)
+	}
+	if len(linfos) == 0 || !linfos[len(linfos)-1].GetNow {
+		t.Errorf("Expected the latest crawl of %v to be marked getnow", link)
+	}
+}
+
 func TestSetPageLength(t *testing.T) {
 	spoofData()
 
@@ -1264,8 +1534,8 @@ func TestSetPageLength(t *testing.T) {
 	//
 	// Set /links length to 10
 	//
-	linksLink := "http://localhost:3000/links/t1.com/NB2HI4B2F4XWY2LONMXHIMJOMNXW2L3QMFTWKMJSFZUHI3LM"
-	doc, body, status = callController(linksLink, encodeLen(10), "/links/{domain}/{seedURL}",
+	linksLink := "http://localhost:3000/links/t1.com/PMRGIIR2EJ2DCLTDN5WSELBCOMRDUITMNFXGWIRMEJYCEORCF5YGCZ3FGEZC42DUNVWCELBCOIRDUITIOR2HAIT5"
+	doc, body, status = callController(linksLink, encodeLen(10), "/links/{domain}/{page}",
 		console.LinksController)
 	if status != http.StatusOK {
 		t.Errorf("TestChangePriority bad status code got %d, expected %d", status, http.StatusOK)
@@ -1280,7 +1550,7 @@ func TestSetPageLength(t *testing.T) {
 	//
 	// Set /links length > 10
 	//
-	doc, body, status = callController(linksLink, encodeLen(25), "/links/{domain}/{seedURL}",
+	doc, body, status = callController(linksLink, encodeLen(25), "/links/{domain}/{page}",
 		console.LinksController)
 	if status != http.StatusOK {
 		t.Errorf("TestChangePriority bad status code got %d, expected %d", status, http.StatusOK)
@@ -1293,3 +1563,133 @@ func TestSetPageLength(t *testing.T) {
 	}
 
 }
+
+func TestPendingDomains(t *testing.T) {
+	spoofData()
+	doc, body, status := callController("http://localhost:3000/pendingDomains", "", "/pendingDomains",
+		console.PendingDomainsController)
+	if status != http.StatusOK {
+		t.Errorf("TestPendingDomains bad status code got %d, expected %d", status, http.StatusOK)
+		t.Log(body)
+		t.FailNow()
+	}
+
+	// Most referenced first
+	expected := []string{"p4.com", "p3.com", "p2.com", "p1.com", "p0.com"}
+	var got []string
+	doc.Find(".container table tbody tr input").Each(func(index int, sel *goquery.Selection) {
+		domain, _ := sel.Attr("value")
+		got = append(got, domain)
+	})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("TestPendingDomains expected pending domains %v, got %v", expected, got)
+	}
+
+	doc, body, status = callController("http://localhost:3000/pendingDomains", "domain=p4.com&domain=p3.com&action=approve",
+		"/pendingDomains", console.PendingDomainsController)
+	if status != http.StatusOK {
+		t.Fatalf("TestPendingDomains bad status code got %d, expected %d", status, http.StatusOK)
+	}
+	if !strings.Contains(body, "Approved 2 domains") {
+		t.Errorf("TestPendingDomains expected info message reporting 2 approved domains")
+		t.Log(body)
+	}
+	if size := doc.Find(".container table tbody tr").Size(); size != 3 {
+		t.Errorf("TestPendingDomains expected 3 pending domains left, got %d", size)
+	}
+
+	dinfo, err := console.DS.FindDomain("p4.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if dinfo == nil || dinfo.Excluded {
+		t.Errorf("TestPendingDomains expected p4.com to be added to the crawl, got %+v", dinfo)
+	}
+}
+
+func TestTags(t *testing.T) {
+	spoofData()
+	_, body, status := callController("http://localhost:3000/add",
+		"links=http%3A%2F%2Ftagged1.com%2Fpage1.html%0Ahttp%3A%2F%2Ftagged2.com%2Fpage1.html&tags=seed-batch%2C+other",
+		"/add", console.AddLinkIndexController)
+	if status != http.StatusOK {
+		t.Errorf("TestTags bad status code got %d, expected %d", status, http.StatusOK)
+		t.Log(body)
+		t.FailNow()
+	}
+
+	doc, body, status := callController("http://localhost:3000/tags", "", "/tags", console.TagsController)
+	if status != http.StatusOK {
+		t.Errorf("TestTags bad status code got %d, expected %d", status, http.StatusOK)
+		t.Log(body)
+		t.FailNow()
+	}
+	reports := map[string]string{}
+	doc.Find(".container table tbody tr").Each(func(index int, sel *goquery.Selection) {
+		cells := sel.Find("td")
+		reports[strings.TrimSpace(cells.First().Text())] = strings.TrimSpace(cells.Eq(1).Text())
+	})
+	expected := map[string]string{"other": "2", "seed-batch": "2"}
+	if !reflect.DeepEqual(reports, expected) {
+		t.Errorf("TestTags expected tagged domain counts %v, got %v", expected, reports)
+	}
+
+	doc, body, status = callController("http://localhost:3000/list?tag=seed-batch", "", "/list",
+		console.ListDomainsController)
+	if status != http.StatusOK {
+		t.Errorf("TestTags bad status code got %d, expected %d", status, http.StatusOK)
+		t.Log(body)
+		t.FailNow()
+	}
+	var domains []string
+	doc.Find(".container table tbody tr td a").Each(func(index int, sel *goquery.Selection) {
+		domains = append(domains, strings.TrimSpace(sel.Text()))
+	})
+	sort.Strings(domains)
+	if !reflect.DeepEqual(domains, []string{"tagged1.com", "tagged2.com"}) {
+		t.Errorf("TestTags expected the tagged domains to be listed, got %v", domains)
+	}
+}
+
+func TestDeadLinks(t *testing.T) {
+	spoofData()
+	fetches := map[string]int{
+		"http://deadlinks.com/ok.html":      200,
+		"http://deadlinks.com/missing.html": 404,
+		"http://deadlinks.com/broken.html":  503,
+	}
+	for link, status := range fetches {
+		console.DS.StoreURLFetchResults(context.Background(), &walker.FetchResults{
+			URL:       walker.MustParse(link),
+			FetchTime: time.Now(),
+			Response:  &http.Response{StatusCode: status},
+		})
+	}
+
+	doc, body, status := callController("http://localhost:3000/deadLinks/deadlinks.com", "", "/deadLinks/{domain}",
+		console.DeadLinksController)
+	if status != http.StatusOK {
+		t.Errorf("TestDeadLinks bad status code got %d, expected %d", status, http.StatusOK)
+		t.Log(body)
+		t.FailNow()
+	}
+	var got []string
+	doc.Find(".container table tbody tr td:first-child a").Each(func(index int, sel *goquery.Selection) {
+		got = append(got, strings.TrimSpace(sel.Text()))
+	})
+	expected := []string{"http://deadlinks.com/broken.html", "http://deadlinks.com/missing.html"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("TestDeadLinks expected dead links %v, got %v", expected, got)
+	}
+
+	_, body, status = callController("http://localhost:3000/deadLinks/deadlinks.com/csv", "",
+		"/deadLinks/{domain}/csv", console.DeadLinksCSVController)
+	if status != http.StatusOK {
+		t.Fatalf("TestDeadLinks bad CSV status code got %d, expected %d", status, http.StatusOK)
+	}
+	if !strings.HasPrefix(body, "url,status,crawl_time,first_referrer,last_referrer\n") ||
+		!strings.Contains(body, "http://deadlinks.com/missing.html,404,") ||
+		strings.Contains(body, "ok.html") {
+		t.Errorf("TestDeadLinks unexpected CSV:\n%v", body)
+	}
+}
@@ -153,3 +153,47 @@ func TestAdd(t *testing.T) {
 
 	fixtureEnd()
 }
+
+func TestDomainStats(t *testing.T) {
+	fixtureStart()
+	defer fixtureEnd()
+
+	hex := fmt.Sprintf("%x", rand.Uint32())
+	domain := fmt.Sprintf("stats%s.com", hex)
+	mp := map[string]interface{}{
+		"version": 1,
+		"links": []interface{}{
+			map[string]interface{}{"url": fmt.Sprintf("http://%s/page1.html", domain)},
+			map[string]interface{}{"url": fmt.Sprintf("http://%s/page2.html", domain)},
+		},
+	}
+	rmp, status := restReq(target("add"), mp)
+	if status != http.StatusOK {
+		t.Fatalf("Failed to return 200 on add:\n%v", rmp)
+	}
+
+	resp, err := http.Get(target("stats/" + domain))
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got status code %d for stats of %v, expected 200", resp.StatusCode, domain)
+	}
+	var stats map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats["domain"] != domain || stats["links"] != 2.0 || stats["uncrawled_links"] != 2.0 {
+		t.Errorf("Expected 2 uncrawled links in %v, got %v", domain, stats)
+	}
+
+	resp, err = http.Get(target("stats/nosuchdomain" + hex + ".com"))
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Got status code %d for stats of an unknown domain, expected 404", resp.StatusCode)
+	}
+}
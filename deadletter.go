@@ -0,0 +1,71 @@
+package walker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"code.google.com/p/log4go"
+)
+
+// FileDeadLetterSink is a DeadLetterSink that appends each dead letter to a
+// file as a single line of JSON, for example:
+//
+//	{"url":"http://a.com/page1.html","status":200,"error":"handler panicked: ...","time":"..."}
+type FileDeadLetterSink struct {
+	// Path of the file to append to; it is created if it does not exist
+	Path string
+
+	mu sync.Mutex
+}
+
+// deadLetterRecord is the JSON written by FileDeadLetterSink
+type deadLetterRecord struct {
+	URL    string    `json:"url"`
+	Status int       `json:"status,omitempty"`
+	Error  string    `json:"error"`
+	Time   time.Time `json:"time"`
+}
+
+// DeadLetter implements the DeadLetterSink interface
+func (s *FileDeadLetterSink) DeadLetter(ctx context.Context, res *FetchResults, err error) {
+	rec := deadLetterRecord{
+		URL:  res.URL.String(),
+		Time: time.Now(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if res.Response != nil {
+		rec.Status = res.Response.StatusCode
+	}
+	b, jerr := json.Marshal(rec)
+	if jerr != nil {
+		log4go.Error("Failed to encode dead letter for %v: %v", rec.URL, jerr)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if werr := s.appendLine(b); werr != nil {
+		log4go.Error("Failed to write dead letter for %v: %v", rec.URL, werr)
+	}
+}
+
+func (s *FileDeadLetterSink) appendLine(b []byte) error {
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %v: %v", s.Path, err)
+	}
+	return nil
+}
@@ -4,8 +4,10 @@ Package dnscache implements a Dial function that will cache DNS resolutions
 package dnscache
 
 import (
+	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru"
@@ -14,37 +16,124 @@ import (
 //TODO:
 //  - use a time-based cache instead of entry-capped, since we know we'll
 //    need most of the recently-accessed domains and few of the aging entries
-//  - consider not caching failures or doing any blacklisting here; the more
-//    likely usecase will be to retry a few times (in which case we don't want
-//    caching) and then not bother crawling this host at all
+
+// DefaultTTL is how long resolutions (and failures) are cached by the Dial
+// function
+const DefaultTTL = 5 * time.Minute
 
 // Dial wraps the given dial function with Caching of DNS resolutions. When a
 // hostname is found in the cache it will call the provided dial with the IP
 // address instead of the hostname, so no DNS lookup need be performed. It will
-// also cache DNS failures.
+// also cache DNS failures. Entries are kept for DefaultTTL; use New to
+// control this.
 //
 // If the given wrappedDial is nil, net.Dial will be automatically used.
 func Dial(wrappedDial func(network, addr string) (net.Conn, error), maxEntries int) (func(network, addr string) (net.Conn, error), error) {
-	if wrappedDial == nil {
-		wrappedDial = net.Dial
+	c, err := New(maxEntries, DefaultTTL, DefaultTTL)
+	if err != nil {
+		return nil, err
 	}
+	return c.Dial(wrappedDial), nil
+}
+
+// Cache holds the DNS resolutions (and failures) learned while dialing, for
+// up to maxEntries network addresses. One Cache can wrap several dial
+// functions (see Cache.Dial), for example those of different Transports, so
+// they share what they learn.
+type Cache struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	cache       *lru.Cache
+	mu          sync.RWMutex
+
+	// host -> IP address, see Override
+	overrides map[string]string
+
+	hits         int64
+	negativeHits int64
+	misses       int64
+	expired      int64
+	overridden   int64
+}
+
+// New creates a Cache holding up to maxEntries addresses. Successful
+// resolutions are reused for ttl, failures for negativeTTL. A negativeTTL of
+// 0 means failures are not cached at all.
+func New(maxEntries int, ttl, negativeTTL time.Duration) (*Cache, error) {
 	cache, err := lru.New(maxEntries)
 	if err != nil {
 		return nil, err
 	}
-	c := &dnsCache{
-		wrappedDial: wrappedDial,
+	return &Cache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
 		cache:       cache,
+		overrides:   map[string]string{},
+	}, nil
+}
+
+// Stats counts the work done by a Cache
+type Stats struct {
+	// Number of dials that used a cached resolution
+	Hits int64
+
+	// Number of dials that failed because of a cached failure
+	NegativeHits int64
+
+	// Number of dials that had to resolve the host (including Expired)
+	Misses int64
+
+	// Number of dials that found an entry, but it was too old to use
+	Expired int64
+
+	// Number of dials sent to an overridden address
+	Overridden int64
+
+	// Number of addresses currently cached
+	Entries int
+}
+
+// String formats Stats for logging
+func (s Stats) String() string {
+	return fmt.Sprintf("%d entries, %d hits, %d negative hits, %d misses (%d expired), %d overridden",
+		s.Entries, s.Hits, s.NegativeHits, s.Misses, s.Expired, s.Overridden)
+}
+
+// Stats returns the counters accumulated since this Cache was created
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:         atomic.LoadInt64(&c.hits),
+		NegativeHits: atomic.LoadInt64(&c.negativeHits),
+		Misses:       atomic.LoadInt64(&c.misses),
+		Expired:      atomic.LoadInt64(&c.expired),
+		Overridden:   atomic.LoadInt64(&c.overridden),
+		Entries:      c.cache.Len(),
 	}
-	return c.cachingDial, nil
 }
 
-// dnsCache wraps a net.Dial-type function with it's own version that will
-// cache DNS entries in an LRU cache.
-type dnsCache struct {
-	wrappedDial func(network, address string) (net.Conn, error)
-	cache       *lru.Cache
-	mu          sync.RWMutex
+// Override makes every dial to host go to ip instead, without resolving
+// host or caching anything. This is useful for testing and for split-horizon
+// DNS setups. An empty ip removes the override.
+func (c *Cache) Override(host, ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ip == "" {
+		delete(c.overrides, host)
+	} else {
+		c.overrides[host] = ip
+	}
+}
+
+// Dial wraps the given dial function so that it uses (and fills) this
+// Cache. If the given wrappedDial is nil, net.Dial will be automatically
+// used.
+func (c *Cache) Dial(wrappedDial func(network, addr string) (net.Conn, error)) func(network, addr string) (net.Conn, error) {
+	if wrappedDial == nil {
+		wrappedDial = net.Dial
+	}
+	return func(network, addr string) (net.Conn, error) {
+		return c.cachingDial(wrappedDial, network, addr)
+	}
 }
 
 type hostrecord struct {
@@ -54,49 +143,70 @@ type hostrecord struct {
 	lastQuery   time.Time
 }
 
-func (c *dnsCache) cachingDial(network, addr string) (net.Conn, error) {
-	mapEntryName := network + addr
-	c.mu.RLock()
-	if entry, ok := c.cache.Get(mapEntryName); ok {
-		record := entry.(hostrecord)
-		lastQueryTime := record.lastQuery
-		if time.Since(lastQueryTime) > 5*time.Minute {
-			c.mu.RUnlock()
-			c.cacheHost(network, addr)
-			c.mu.RLock()
-			entry, _ = c.cache.Get(mapEntryName)
-			record = entry.(hostrecord)
-		}
-		resolvedAddr := record.ipaddr
+func (c *Cache) cachingDial(wrappedDial func(network, addr string) (net.Conn, error), network, addr string) (net.Conn, error) {
+	if overrideAddr, ok := c.lookupOverride(addr); ok {
+		atomic.AddInt64(&c.overridden, 1)
+		return wrappedDial(network, overrideAddr)
+	}
+
+	record, ok := c.get(network, addr)
+	if ok {
+		ttl := c.ttl
 		if record.blacklisted {
-			returnErr := record.err
-			c.mu.RUnlock()
-			return nil, returnErr
+			ttl = c.negativeTTL
 		}
+		if time.Since(record.lastQuery) <= ttl {
+			if record.blacklisted {
+				atomic.AddInt64(&c.negativeHits, 1)
+				return nil, record.err
+			}
+			atomic.AddInt64(&c.hits, 1)
+			return wrappedDial(network, record.ipaddr)
+		}
+		atomic.AddInt64(&c.expired, 1)
+	}
+	atomic.AddInt64(&c.misses, 1)
+	return c.cacheHost(wrappedDial, network, addr)
+}
 
-		c.mu.RUnlock()
-		return c.wrappedDial(network, resolvedAddr)
-
+// lookupOverride returns the address to dial instead of addr if its host
+// has an override
+func (c *Cache) lookupOverride(addr string) (string, bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
 	}
+	c.mu.RLock()
+	ip, ok := c.overrides[host]
 	c.mu.RUnlock()
-	return c.cacheHost(network, addr)
+	if !ok {
+		return "", false
+	}
+	if port == "" {
+		return ip, true
+	}
+	return net.JoinHostPort(ip, port), true
 }
 
 // cacheHost caches the DNS lookup for this host, overwriting any entry
 // that may have previously existed.
-func (c *dnsCache) cacheHost(network, addr string) (net.Conn, error) {
+func (c *Cache) cacheHost(wrappedDial func(network, addr string) (net.Conn, error), network, addr string) (net.Conn, error) {
 	mapEntryName := network + addr
-	newConn, err := c.wrappedDial(network, addr)
+	newConn, err := wrappedDial(network, addr)
 	queryTime := time.Now()
 	c.mu.Lock()
+	defer c.mu.Unlock()
 	if err != nil {
-		c.cache.Add(mapEntryName, hostrecord{
-			ipaddr:      "",
-			blacklisted: true,
-			err:         err,
-			lastQuery:   queryTime,
-		})
-		c.mu.Unlock()
+		if c.negativeTTL > 0 {
+			c.cache.Add(mapEntryName, hostrecord{
+				ipaddr:      "",
+				blacklisted: true,
+				err:         err,
+				lastQuery:   queryTime,
+			})
+		} else {
+			c.cache.Remove(mapEntryName)
+		}
 		return nil, err
 	}
 	remoteipaddr := newConn.RemoteAddr().String()
@@ -106,22 +216,18 @@ func (c *dnsCache) cacheHost(network, addr string) (net.Conn, error) {
 		err:         nil,
 		lastQuery:   queryTime,
 	})
-	c.mu.Unlock()
 	return newConn, nil
-
 }
 
 // get returns the hostrecord associated with the passed network:address, if it exists.
 // The second return value represents whether the record exists.
-func (c *dnsCache) get(network, addr string) (hostrecord, bool) {
+func (c *Cache) get(network, addr string) (hostrecord, bool) {
 	key := network + addr
 	c.mu.RLock()
+	defer c.mu.RUnlock()
 	valinterface, ok := c.cache.Get(key)
 	if valinterface == nil {
-		c.mu.RUnlock()
 		return hostrecord{}, ok
 	}
-	val := valinterface.(hostrecord)
-	c.mu.RUnlock()
-	return val, ok
+	return valinterface.(hostrecord), ok
 }
@@ -1,6 +1,7 @@
 package dnscache
 
 import (
+	"fmt"
 	"net"
 	"testing"
 	"time"
@@ -116,3 +117,88 @@ func TestHostPushedOutOfCache(t *testing.T) {
 	cdial("tcp", "host3.com")
 	cdial("tcp", "host1.com")
 }
+
+func TestCacheTTL(t *testing.T) {
+	addr := &MockAddr{}
+	addr.On("String").Return("1.2.3.4")
+
+	conn := &MockConn{}
+	conn.On("RemoteAddr").Return(addr)
+
+	dialer := &MockDialer{}
+	dialer.On("Dial", "tcp", "test.com").Return(conn, nil).Twice()
+	dialer.On("Dial", "tcp", "1.2.3.4").Return(conn, nil).Once()
+
+	c, err := New(2, 50*time.Millisecond, 50*time.Millisecond)
+	if err != nil {
+		panic(err)
+	}
+	cdial := c.Dial(dialer.Dial)
+	cdial("tcp", "test.com")
+	cdial("tcp", "test.com")
+	time.Sleep(100 * time.Millisecond)
+	cdial("tcp", "test.com")
+	dialer.AssertExpectations(t)
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Expired != 1 || stats.Entries != 1 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	dialer := &MockDialer{}
+	dialer.On("Dial", "tcp", "bad.com").Return((*MockConn)(nil), fmt.Errorf("no such host")).Once()
+	dialer.On("Dial", "tcp", "uncached.com").Return((*MockConn)(nil), fmt.Errorf("no such host")).Twice()
+
+	c, err := New(10, time.Minute, time.Minute)
+	if err != nil {
+		panic(err)
+	}
+	cdial := c.Dial(dialer.Dial)
+	for i := 0; i < 3; i++ {
+		if _, err := cdial("tcp", "bad.com"); err == nil {
+			t.Errorf("Expected dial of bad.com to fail")
+		}
+	}
+	if stats := c.Stats(); stats.NegativeHits != 2 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+
+	// With a negative TTL of 0 failures are retried every time
+	c, err = New(10, time.Minute, 0)
+	if err != nil {
+		panic(err)
+	}
+	cdial = c.Dial(dialer.Dial)
+	cdial("tcp", "uncached.com")
+	cdial("tcp", "uncached.com")
+	dialer.AssertExpectations(t)
+
+	if stats := c.Stats(); stats.NegativeHits != 0 || stats.Entries != 0 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	conn := &MockConn{}
+
+	dialer := &MockDialer{}
+	dialer.On("Dial", "tcp", "10.0.0.5:80").Return(conn, nil).Twice()
+	dialer.On("Dial", "tcp", "10.0.0.5").Return(conn, nil).Once()
+
+	c, err := New(10, time.Minute, time.Minute)
+	if err != nil {
+		panic(err)
+	}
+	c.Override("internal.com", "10.0.0.5")
+	cdial := c.Dial(dialer.Dial)
+	cdial("tcp", "internal.com:80")
+	cdial("tcp", "internal.com:80")
+	cdial("tcp", "internal.com")
+	dialer.AssertExpectations(t)
+
+	if stats := c.Stats(); stats.Overridden != 3 || stats.Entries != 0 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
@@ -0,0 +1,500 @@
+/*
+Package eshandler provides a walker handler that indexes fetched pages in
+Elasticsearch (or OpenSearch), so walker can be used as the front end of a
+search pipeline. It is configured by the elasticsearch section of walker.yaml.
+*/
+package eshandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"code.google.com/p/log4go"
+	"github.com/iParadigms/walker"
+)
+
+// requestTimeout bounds every request made to the cluster
+const requestTimeout = time.Minute
+
+// DefaultMappings is the body of the create index request used if
+// elasticsearch.mappings_file is not set
+const DefaultMappings = `{
+  "mappings": {
+    "properties": {
+      "url":             {"type": "keyword"},
+      "final_url":       {"type": "keyword"},
+      "domain":          {"type": "keyword"},
+      "host":            {"type": "keyword"},
+      "title":           {"type": "text"},
+      "description":     {"type": "text"},
+      "text":            {"type": "text"},
+      "language":        {"type": "keyword"},
+      "status":          {"type": "integer"},
+      "mime_type":       {"type": "keyword"},
+      "fetch_time":      {"type": "date"},
+      "bytes":           {"type": "long"},
+      "fnv_text":        {"type": "long"},
+      "no_snippet":      {"type": "boolean"}
+    }
+  }
+}`
+
+// Document is what is indexed for each fetched page. Its id is the SHA-1 of
+// URL, so recrawls of a page replace its document.
+type Document struct {
+	// The link that was fetched, and the URL the content came from if it
+	// redirected
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url,omitempty"`
+
+	// TLD+1 of the link (ex. "bbc.co.uk") and its full host
+	Domain string `json:"domain"`
+	Host   string `json:"host"`
+
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// The text of the page (for HTML, without its markup, scripts and
+	// styles), at most elasticsearch.max_text_bytes long
+	Text string `json:"text,omitempty"`
+
+	// From the Content-Language header or the lang attribute of <html>,
+	// lowercased (ex. "en-us")
+	Language string `json:"language,omitempty"`
+
+	Status    int       `json:"status"`
+	MimeType  string    `json:"mime_type,omitempty"`
+	FetchTime time.Time `json:"fetch_time"`
+	Bytes     int64     `json:"bytes"`
+	FnvText   int64     `json:"fnv_text"`
+
+	// True if the page asked not to be shown with a snippet
+	NoSnippet bool `json:"no_snippet,omitempty"`
+}
+
+// bulkItem is a document ready to be sent in a bulk request
+type bulkItem struct {
+	id  string
+	doc []byte
+}
+
+// Handler implements walker.Handler, indexing 2XX text responses. Documents
+// are queued (up to elasticsearch.queue_size of them) and sent from a
+// goroutine of their own with the bulk API, either when
+// elasticsearch.bulk_size of them have accumulated or every
+// elasticsearch.flush_interval, so a slow cluster never holds up fetching.
+// Documents that do not fit in a full queue are dropped. Call Close to send
+// the last of them.
+//
+// NewHandler should be used to create one.
+type Handler struct {
+	client   *http.Client
+	url      string
+	index    string
+	username string
+	password string
+
+	bulkSize      int
+	flushInterval time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+	maxTextBytes  int
+
+	queue chan *bulkItem
+	flush chan chan struct{}
+	done  chan struct{}
+
+	// The number of documents dropped because the queue was full since the
+	// last time that was logged
+	mu      sync.Mutex
+	dropped int
+}
+
+// NewHandler creates a Handler from walker.Config.Elasticsearch, creating the
+// index if it does not exist yet.
+func NewHandler() (*Handler, error) {
+	cfg := walker.Config.Elasticsearch
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("elasticsearch.url is not set")
+	}
+	flushInterval, err := time.ParseDuration(cfg.FlushInterval)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	retryBackoff, err := time.ParseDuration(cfg.RetryBackoff)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	password := cfg.Password
+	if password != "" {
+		password, err = walker.ResolveSecret(password)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resolve elasticsearch.password: %v", err)
+		}
+	}
+
+	h := &Handler{
+		client:        &http.Client{Timeout: requestTimeout},
+		url:           strings.TrimSuffix(cfg.URL, "/"),
+		index:         cfg.Index,
+		username:      cfg.Username,
+		password:      password,
+		bulkSize:      cfg.BulkSize,
+		flushInterval: flushInterval,
+		maxRetries:    cfg.MaxRetries,
+		retryBackoff:  retryBackoff,
+		maxTextBytes:  cfg.MaxTextBytes,
+		queue:         make(chan *bulkItem, cfg.QueueSize),
+		flush:         make(chan chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	mappings := []byte(DefaultMappings)
+	if cfg.MappingsFile != "" {
+		mappings, err = ioutil.ReadFile(cfg.MappingsFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read elasticsearch.mappings_file: %v", err)
+		}
+	}
+	if err := h.createIndex(mappings); err != nil {
+		return nil, err
+	}
+
+	go h.run()
+	return h, nil
+}
+
+// HandleResponse queues a document for the page, unless it was not a 2XX
+// response with a text content type, or was marked noarchive and
+// fetcher.honor_noarchive is true.
+func (h *Handler) HandleResponse(ctx context.Context, fr *walker.FetchResults) {
+	if fr.ExcludedByRobots || fr.Response == nil {
+		return
+	}
+	if walker.Config.Fetcher.HonorNoarchive && fr.NoArchive {
+		log4go.Debug("Marked noarchive, not indexing url: %v", fr.URL)
+		return
+	}
+	if fr.Response.StatusCode < 200 || fr.Response.StatusCode >= 300 {
+		log4go.Debug("Returned %v, not indexing url: %v", fr.Response.StatusCode, fr.URL)
+		return
+	}
+	if !strings.HasPrefix(fr.MimeType, "text/") && fr.MimeType != "application/xhtml+xml" {
+		log4go.Debug("Content type %q, not indexing url: %v", fr.MimeType, fr.URL)
+		return
+	}
+
+	body, err := ioutil.ReadAll(fr.Response.Body)
+	if err != nil {
+		log4go.Error("Failed to read body of %v: %v", fr.URL, err)
+		return
+	}
+	doc, err := h.document(fr, body)
+	if err != nil {
+		log4go.Error("Failed to build document for %v: %v", fr.URL, err)
+		return
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		log4go.Error("Failed to encode document for %v: %v", fr.URL, err)
+		return
+	}
+
+	select {
+	case h.queue <- &bulkItem{id: idFor(doc.URL), doc: b}:
+	default:
+		h.mu.Lock()
+		h.dropped++
+		h.mu.Unlock()
+	}
+}
+
+// idFor returns the document id for a link
+func idFor(link string) string {
+	sum := sha1.Sum([]byte(link))
+	return hex.EncodeToString(sum[:])
+}
+
+// document builds the Document for a fetched page
+func (h *Handler) document(fr *walker.FetchResults, body []byte) (*Document, error) {
+	domain, err := fr.URL.ToplevelDomainPlusOne()
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{
+		URL:         fr.URL.String(),
+		Domain:      domain,
+		Host:        fr.URL.Host,
+		Title:       fr.Title,
+		Description: fr.Description,
+		Status:      fr.Response.StatusCode,
+		MimeType:    fr.MimeType,
+		FetchTime:   fr.FetchTime,
+		Bytes:       int64(len(body)),
+		FnvText:     fr.FnvTextFingerprint,
+		NoSnippet:   fr.NoSnippet,
+	}
+	if n := len(fr.RedirectedFrom); n > 0 {
+		doc.FinalURL = fr.RedirectedFrom[n-1].String()
+	}
+
+	lang := ""
+	if cl := fr.Response.Header.Get("Content-Language"); cl != "" {
+		lang = strings.TrimSpace(strings.Split(cl, ",")[0])
+	}
+	if fr.MimeType == "text/html" || fr.MimeType == "application/xhtml+xml" {
+		p := &walker.HTMLParser{}
+		p.Parse(body)
+		doc.Text = string(p.Text)
+		if doc.Title == "" {
+			doc.Title = p.Title
+		}
+		if doc.Description == "" {
+			doc.Description = p.Description
+		}
+		if lang == "" {
+			lang = p.Lang
+		}
+	} else {
+		doc.Text = string(body)
+	}
+	doc.Language = strings.ToLower(lang)
+	doc.Text = truncate(doc.Text, h.maxTextBytes)
+	return doc, nil
+}
+
+// truncate cuts s to at most max bytes (if max > 0) without splitting a
+// UTF-8 character
+func truncate(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// run sends queued documents in batches of up to bulkSize, and whatever has
+// accumulated every flushInterval or when Flush is called, until Close is
+// called
+func (h *Handler) run() {
+	defer close(h.done)
+	ticker := time.NewTicker(h.flushInterval)
+	defer ticker.Stop()
+
+	var batch []*bulkItem
+	sendBatch := func() {
+		h.logDropped()
+		if len(batch) > 0 {
+			h.send(batch)
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case item, ok := <-h.queue:
+			if !ok {
+				sendBatch()
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= h.bulkSize {
+				sendBatch()
+			}
+		case <-ticker.C:
+			sendBatch()
+		case flushed := <-h.flush:
+			// Take in what was queued before Flush was called
+			for n := len(h.queue); n > 0; n-- {
+				batch = append(batch, <-h.queue)
+				if len(batch) >= h.bulkSize {
+					sendBatch()
+				}
+			}
+			sendBatch()
+			close(flushed)
+		}
+	}
+}
+
+// logDropped logs how many documents were dropped because the queue was full
+// since it was last called
+func (h *Handler) logDropped() {
+	h.mu.Lock()
+	dropped := h.dropped
+	h.dropped = 0
+	h.mu.Unlock()
+	if dropped > 0 {
+		log4go.Warn("Queue of documents for %v was full, dropped %d documents (see elasticsearch.queue_size)",
+			h.index, dropped)
+	}
+}
+
+// Flush sends the documents queued so far, returning once they are sent
+func (h *Handler) Flush() {
+	flushed := make(chan struct{})
+	h.flush <- flushed
+	<-flushed
+}
+
+// Close sends the documents still queued and stops the handler. It must not
+// be called while responses are still being handled.
+func (h *Handler) Close() {
+	close(h.queue)
+	<-h.done
+}
+
+// send indexes batch, retrying the request (or the documents that were
+// rejected with a retryable status) up to elasticsearch.max_retries times
+func (h *Handler) send(batch []*bulkItem) {
+	backoff := h.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := h.bulk(batch)
+		if err == nil && len(retry) == 0 {
+			return
+		}
+		if err == nil {
+			batch = retry
+		}
+		if attempt >= h.maxRetries {
+			if err != nil {
+				log4go.Error("Giving up indexing %d documents in %v: %v", len(batch), h.index, err)
+			} else {
+				log4go.Error("Giving up indexing %d rejected documents in %v", len(batch), h.index)
+			}
+			return
+		}
+		if err != nil {
+			log4go.Warn("Bulk request to %v failed, retrying in %v: %v", h.index, backoff, err)
+		} else {
+			log4go.Warn("%d documents rejected by %v, retrying in %v", len(batch), h.index, backoff)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// bulkResponse is the part of a bulk API response we look at
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk makes a single bulk request for batch. It returns an error if the
+// whole request should be retried, or the documents that should be. Documents
+// rejected for other reasons (ex. a mapping conflict) are logged and dropped.
+func (h *Handler) bulk(batch []*bulkItem) ([]*bulkItem, error) {
+	var buf bytes.Buffer
+	for _, item := range batch {
+		action, _ := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": h.index, "_id": item.id},
+		})
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(item.doc)
+		buf.WriteByte('\n')
+	}
+
+	res, err := h.do("POST", "/_bulk", "application/x-ndjson", buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if retryableStatus(res.StatusCode) {
+		return nil, fmt.Errorf("status %v", res.Status)
+	} else if res.StatusCode < 200 || res.StatusCode >= 300 {
+		log4go.Error("Bulk request to %v failed, dropping %d documents: %v: %s", h.index, len(batch), res.Status, body)
+		return nil, nil
+	}
+
+	var br bulkResponse
+	if err := json.Unmarshal(body, &br); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk response: %v", err)
+	}
+	if !br.Errors {
+		return nil, nil
+	}
+	var retry []*bulkItem
+	for i, item := range br.Items {
+		if i >= len(batch) {
+			break
+		}
+		for _, result := range item {
+			if result.Status >= 200 && result.Status < 300 {
+				continue
+			}
+			if retryableStatus(result.Status) {
+				retry = append(retry, batch[i])
+			} else {
+				log4go.Error("Failed to index document %v in %v: %v: %s", result.ID, h.index, result.Status, result.Error)
+			}
+		}
+	}
+	return retry, nil
+}
+
+// retryableStatus returns true if a request that got status may succeed if
+// it is tried again
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// createIndex creates the index with the given body unless it exists
+func (h *Handler) createIndex(body []byte) error {
+	res, err := h.do("HEAD", "/"+h.index, "", nil)
+	if err != nil {
+		return fmt.Errorf("Failed to reach elasticsearch at %v: %v", h.url, err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	} else if res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("Failed to check for index %v: %v", h.index, res.Status)
+	}
+
+	log4go.Info("Creating elasticsearch index %v", h.index)
+	res, err = h.do("PUT", "/"+h.index, "application/json", body)
+	if err != nil {
+		return fmt.Errorf("Failed to create index %v: %v", h.index, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("Failed to create index %v: %v: %s", h.index, res.Status, msg)
+	}
+	return nil
+}
+
+// do makes a request to the cluster
+func (h *Handler) do(method, path, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, h.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if h.username != "" {
+		req.SetBasicAuth(h.username, h.password)
+	}
+	return h.client.Do(req)
+}
@@ -0,0 +1,264 @@
+package eshandler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+)
+
+// fakeCluster records the requests made to it. The first bulkFailures bulk
+// requests fail with 503, and every document id in rejectOnce is rejected
+// with 429 the first time it is indexed. If block is set, bulk requests wait
+// for it to be closed.
+type fakeCluster struct {
+	block chan struct{}
+
+	mu           sync.Mutex
+	indexExists  bool
+	created      []byte
+	bulkFailures int
+	rejectOnce   map[string]bool
+	bulkRequests int
+	docs         map[string]*Document
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.block != nil && r.URL.Path == "/_bulk" {
+		<-c.block
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch {
+	case r.Method == "HEAD" && r.URL.Path == "/walker":
+		if !c.indexExists {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == "PUT" && r.URL.Path == "/walker":
+		c.indexExists = true
+		c.created = body
+	case r.Method == "POST" && r.URL.Path == "/_bulk":
+		c.bulkRequests++
+		if c.bulkFailures > 0 {
+			c.bulkFailures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var items []string
+		errors := false
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var action struct {
+				Index struct {
+					ID string `json:"_id"`
+				} `json:"index"`
+			}
+			json.Unmarshal(scanner.Bytes(), &action)
+			scanner.Scan()
+			id := action.Index.ID
+			if c.rejectOnce[id] {
+				delete(c.rejectOnce, id)
+				errors = true
+				items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":429,"error":{"type":"es_rejected_execution_exception"}}}`, id))
+				continue
+			}
+			doc := &Document{}
+			json.Unmarshal(scanner.Bytes(), doc)
+			c.docs[id] = doc
+			items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":201}}`, id))
+		}
+		fmt.Fprintf(w, `{"errors":%v,"items":[%s]}`, errors, strings.Join(items, ","))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newFakeCluster() (*fakeCluster, *httptest.Server) {
+	c := &fakeCluster{rejectOnce: map[string]bool{}, docs: map[string]*Document{}}
+	return c, httptest.NewServer(c)
+}
+
+func useCluster(srv *httptest.Server) func() {
+	orig := walker.Config.Elasticsearch
+	walker.Config.Elasticsearch.URL = srv.URL
+	walker.Config.Elasticsearch.Index = "walker"
+	walker.Config.Elasticsearch.BulkSize = 2
+	walker.Config.Elasticsearch.FlushInterval = "1h"
+	walker.Config.Elasticsearch.QueueSize = 100
+	walker.Config.Elasticsearch.MaxRetries = 2
+	walker.Config.Elasticsearch.RetryBackoff = "1ms"
+	walker.Config.Elasticsearch.MaxTextBytes = 1048576
+	return func() { walker.Config.Elasticsearch = orig }
+}
+
+func fetchResults(link string, contentType string, body string) *walker.FetchResults {
+	return &walker.FetchResults{
+		URL:       walker.MustParse(link),
+		FetchTime: time.Unix(1400000000, 0).UTC(),
+		MimeType:  contentType,
+		Response: &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{contentType}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func TestHandlerIndexesPages(t *testing.T) {
+	c, srv := newFakeCluster()
+	defer srv.Close()
+	defer useCluster(srv)()
+
+	h, err := NewHandler()
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	if !c.indexExists || string(c.created) != DefaultMappings {
+		t.Errorf("Expected index to be created with the default mappings, got %q", c.created)
+	}
+
+	html := fetchResults("http://test.com/page.html", "text/html",
+		`<html lang="en-US"><head><title>A page</title></head><body><p>Some text</p></body></html>`)
+	h.HandleResponse(context.Background(), html)
+	plain := fetchResults("http://sub.test.com/notes.txt", "text/plain", "plain notes")
+	plain.Response.Header.Set("Content-Language", "FR, en")
+	h.HandleResponse(context.Background(), plain)
+
+	notFound := fetchResults("http://test.com/missing.html", "text/html", "gone")
+	notFound.Response.StatusCode = http.StatusNotFound
+	h.HandleResponse(context.Background(), notFound)
+	image := fetchResults("http://test.com/image.png", "image/png", "\x89PNG")
+	h.HandleResponse(context.Background(), image)
+	noArchive := fetchResults("http://test.com/private.html", "text/html", "<p>private</p>")
+	noArchive.NoArchive = true
+	h.HandleResponse(context.Background(), noArchive)
+	h.Close()
+
+	if c.bulkRequests != 1 {
+		t.Errorf("Expected a single bulk request of bulk_size documents, got %d", c.bulkRequests)
+	}
+	if len(c.docs) != 2 {
+		t.Fatalf("Expected 2 documents indexed, got %d: %v", len(c.docs), c.docs)
+	}
+	var htmlDoc, plainDoc *Document
+	for _, doc := range c.docs {
+		switch doc.URL {
+		case "http://test.com/page.html":
+			htmlDoc = doc
+		case "http://sub.test.com/notes.txt":
+			plainDoc = doc
+		}
+	}
+	if htmlDoc == nil || plainDoc == nil {
+		t.Fatalf("Expected both pages indexed, got %v", c.docs)
+	}
+	if htmlDoc.Title != "A page" || !strings.Contains(htmlDoc.Text, "Some text") ||
+		strings.Contains(htmlDoc.Text, "<p>") || htmlDoc.Language != "en-us" ||
+		htmlDoc.Domain != "test.com" || htmlDoc.Status != 200 {
+		t.Errorf("Unexpected html document: %+v", htmlDoc)
+	}
+	if plainDoc.Text != "plain notes" || plainDoc.Language != "fr" ||
+		plainDoc.Domain != "test.com" || plainDoc.Host != "sub.test.com" {
+		t.Errorf("Unexpected plain text document: %+v", plainDoc)
+	}
+}
+
+func TestHandlerRetries(t *testing.T) {
+	c, srv := newFakeCluster()
+	defer srv.Close()
+	defer useCluster(srv)()
+	c.indexExists = true
+	c.bulkFailures = 1
+
+	h, err := NewHandler()
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	if c.created != nil {
+		t.Errorf("Expected existing index not to be created again")
+	}
+
+	first := fetchResults("http://test.com/1.html", "text/html", "<p>one</p>")
+	second := fetchResults("http://test.com/2.html", "text/html", "<p>two</p>")
+	c.rejectOnce[idFor("http://test.com/2.html")] = true
+
+	h.HandleResponse(context.Background(), first)
+	h.HandleResponse(context.Background(), second)
+	h.Close()
+
+	// 503, then one document rejected, then the rejected one alone
+	if c.bulkRequests != 3 {
+		t.Errorf("Expected 3 bulk requests, got %d", c.bulkRequests)
+	}
+	if len(c.docs) != 2 {
+		t.Errorf("Expected both documents indexed after retries, got %v", c.docs)
+	}
+}
+
+func TestHandlerDropsWhenQueueFull(t *testing.T) {
+	c, srv := newFakeCluster()
+	defer srv.Close()
+	defer useCluster(srv)()
+	c.block = make(chan struct{})
+	walker.Config.Elasticsearch.BulkSize = 1
+	walker.Config.Elasticsearch.QueueSize = 1
+
+	h, err := NewHandler()
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	// The first document is stuck being sent and at most one more fits in
+	// the queue; handling the rest must not wait on the cluster
+	handled := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			link := fmt.Sprintf("http://test.com/%d.html", i)
+			h.HandleResponse(context.Background(), fetchResults(link, "text/html", "<p>page</p>"))
+		}
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Handling responses blocked on a stalled cluster")
+	}
+	close(c.block)
+	h.Close()
+
+	if len(c.docs) < 1 || len(c.docs) > 2 {
+		t.Errorf("Expected 1 or 2 documents indexed with the rest dropped, got %v", c.docs)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in       string
+		max      int
+		expected string
+	}{
+		{"hello", 0, "hello"},
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+	}
+	for _, tst := range tests {
+		if got := truncate(tst.in, tst.max); got != tst.expected {
+			t.Errorf("truncate(%q, %d) = %q, expected %q", tst.in, tst.max, got, tst.expected)
+		}
+	}
+}
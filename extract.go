@@ -0,0 +1,174 @@
+package walker
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"code.google.com/p/go.net/html"
+	"code.google.com/p/go.net/html/charset"
+)
+
+// StructuredData is the metadata pulled out of an HTML page when
+// fetcher.extract_structured_data is true, so handlers don't each have to
+// parse the page again to find it. See ExtractStructuredData.
+type StructuredData struct {
+	// OpenGraph maps each <meta property="og:..."> property (ex. "og:title")
+	// to its content. Properties given several times (ex. "og:image") keep
+	// every value, in page order.
+	OpenGraph map[string][]string `json:"opengraph,omitempty"`
+
+	// JSONLD holds the contents of every <script type="application/ld+json">
+	// block that is valid JSON
+	JSONLD []json.RawMessage `json:"jsonld,omitempty"`
+
+	// Microdata holds the top-level items (elements with an itemscope
+	// attribute that aren't themselves a property of another item)
+	Microdata []*MicrodataItem `json:"microdata,omitempty"`
+}
+
+// MicrodataItem is one itemscope found in a page
+type MicrodataItem struct {
+	// The itemtype attribute, if any (ex. "http://schema.org/Product")
+	Type string `json:"type,omitempty"`
+
+	// Properties maps each itemprop name to its values, in page order. Values
+	// are strings, or *MicrodataItem for properties that are items
+	// themselves.
+	Properties map[string][]interface{} `json:"properties"`
+}
+
+// Empty returns true if nothing was extracted
+func (sd *StructuredData) Empty() bool {
+	return len(sd.OpenGraph) == 0 && len(sd.JSONLD) == 0 && len(sd.Microdata) == 0
+}
+
+// ExtractStructuredData parses body as HTML and extracts its OpenGraph tags,
+// JSON-LD blocks and microdata items. It returns nil if the page has none.
+// contentType is the Content-Type header of the response: the body is decoded
+// with the charset it names, if any, or else with the one the page declares
+// in a <meta> tag.
+func ExtractStructuredData(body []byte, contentType string) *StructuredData {
+	if contentType == "" {
+		contentType = "text/html"
+	}
+	utf8Reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil
+	}
+	doc, err := html.Parse(utf8Reader)
+	if err != nil {
+		return nil
+	}
+
+	sd := &StructuredData{}
+	sd.walk(doc, nil)
+	if sd.Empty() {
+		return nil
+	}
+	return sd
+}
+
+// walk extracts data from n and its descendants; item is the microdata item
+// n is inside of, if any
+func (sd *StructuredData) walk(n *html.Node, item *MicrodataItem) {
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "meta":
+			if prop, ok := getAttr(n, "property"); ok && strings.HasPrefix(strings.ToLower(prop), "og:") {
+				content, _ := getAttr(n, "content")
+				if sd.OpenGraph == nil {
+					sd.OpenGraph = map[string][]string{}
+				}
+				prop = strings.ToLower(prop)
+				sd.OpenGraph[prop] = append(sd.OpenGraph[prop], content)
+			}
+
+		case "script":
+			if typ, _ := getAttr(n, "type"); strings.EqualFold(strings.TrimSpace(typ), "application/ld+json") {
+				block := []byte(strings.TrimSpace(textContent(n)))
+				if json.Valid(block) {
+					sd.JSONLD = append(sd.JSONLD, json.RawMessage(block))
+				}
+			}
+		}
+
+		itemprop, _ := getAttr(n, "itemprop")
+		props := strings.Fields(itemprop)
+		if _, scoped := getAttr(n, "itemscope"); scoped {
+			typ, _ := getAttr(n, "itemtype")
+			newItem := &MicrodataItem{Type: typ, Properties: map[string][]interface{}{}}
+			if item != nil && len(props) > 0 {
+				for _, p := range props {
+					item.Properties[p] = append(item.Properties[p], newItem)
+				}
+			} else {
+				sd.Microdata = append(sd.Microdata, newItem)
+			}
+			item = newItem
+		} else if item != nil && len(props) > 0 {
+			val := microdataValue(n)
+			for _, p := range props {
+				item.Properties[p] = append(item.Properties[p], val)
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sd.walk(c, item)
+	}
+}
+
+// microdataValue returns the value of an itemprop element, following the
+// microdata spec: a URL attribute for links and media, the content attribute
+// of <meta>, and so on, or the element's text otherwise
+func microdataValue(n *html.Node) string {
+	attr := ""
+	switch n.Data {
+	case "meta":
+		attr = "content"
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		attr = "src"
+	case "a", "area", "link":
+		attr = "href"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		attr = "value"
+	case "time":
+		attr = "datetime"
+	}
+	if attr != "" {
+		if val, ok := getAttr(n, attr); ok {
+			return strings.TrimSpace(val)
+		}
+	}
+	return strings.Join(strings.Fields(textContent(n)), " ")
+}
+
+// getAttr returns the value of n's attribute named key, and whether it has
+// one
+func getAttr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// textContent concatenates all the text inside of n
+func textContent(n *html.Node) string {
+	var buf bytes.Buffer
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return buf.String()
+}
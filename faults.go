@@ -0,0 +1,138 @@
+package walker
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"code.google.com/p/log4go"
+)
+
+// InjectedFault is the error of a fetch or datastore write failed on purpose
+// by fault injection (see faults in walker.yaml). It is a net.Error that
+// reports a timeout, so it is handled the way a real timeout would be.
+type InjectedFault struct {
+	// What was failed, ex. "fetch of http://test.com/page1.html"
+	Op string
+}
+
+func (e *InjectedFault) Error() string {
+	return "injected fault: " + e.Op + " timed out"
+}
+
+// Timeout returns true, see net.Error
+func (e *InjectedFault) Timeout() bool {
+	return true
+}
+
+// Temporary returns true, see net.Error
+func (e *InjectedFault) Temporary() bool {
+	return true
+}
+
+// The faults settings are hot reloadable, so they are read under configMu,
+// but they are copied on their own rather than with the rest of the config
+// (see HotConfig) since they are checked for every fetch and write.
+
+// injectFault returns true percent% of the time
+func injectFault(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// injectDelay sleeps for the given duration (a config value), returning early
+// if ctx is done
+func injectDelay(ctx context.Context, duration string) {
+	d, err := time.ParseDuration(duration)
+	if err != nil || d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// injectFetchFault is called before each request the fetcher sends. It may
+// delay the request (faults.fetch_delay_percent), and returns an error if the
+// request should fail instead of being sent (faults.fetch_error_percent).
+func injectFetchFault(req *http.Request) error {
+	configMu.RLock()
+	faults := Config.Faults
+	configMu.RUnlock()
+	if !faults.Enabled {
+		return nil
+	}
+	if injectFault(faults.FetchDelayPercent) {
+		log4go.Debug("Injecting a %v delay into the fetch of %v", faults.FetchDelay, req.URL)
+		injectDelay(req.Context(), faults.FetchDelay)
+	}
+	if injectFault(faults.FetchErrorPercent) {
+		log4go.Debug("Injecting an error into the fetch of %v", req.URL)
+		return &InjectedFault{Op: "fetch of " + req.URL.String()}
+	}
+	return nil
+}
+
+// injectTruncation is called with each response the fetcher receives. It may
+// (faults.fetch_truncate_percent) replace the body with one that fails with
+// io.ErrUnexpectedEOF part way through.
+func injectTruncation(req *http.Request, res *http.Response) {
+	configMu.RLock()
+	faults := Config.Faults
+	configMu.RUnlock()
+	if res.Body == nil || !faults.Enabled || !injectFault(faults.FetchTruncatePercent) {
+		return
+	}
+	size := res.ContentLength
+	if size <= 0 {
+		size = 16 * 1024
+	}
+	cut := rand.Int63n(size)
+	log4go.Debug("Injecting a truncation after %d bytes into the response from %v", cut, req.URL)
+	res.Body = &truncatedBody{ReadCloser: res.Body, remaining: cut}
+}
+
+// truncatedBody reads from ReadCloser until remaining bytes have been read,
+// then fails with io.ErrUnexpectedEOF
+type truncatedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// InjectWriteFault is called by datastores before each write they send. It
+// may delay the write (faults.write_delay_percent), and returns true if the
+// write should fail as if it had timed out instead of being sent
+// (faults.write_error_percent).
+func InjectWriteFault(ctx context.Context) bool {
+	configMu.RLock()
+	faults := Config.Faults
+	configMu.RUnlock()
+	if !faults.Enabled {
+		return false
+	}
+	if injectFault(faults.WriteDelayPercent) {
+		log4go.Debug("Injecting a %v delay into a datastore write", faults.WriteDelay)
+		injectDelay(ctx, faults.WriteDelay)
+	}
+	if injectFault(faults.WriteErrorPercent) {
+		log4go.Debug("Injecting an error into a datastore write")
+		return true
+	}
+	return false
+}
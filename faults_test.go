@@ -0,0 +1,69 @@
+package walker
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestInjectFetchFault(t *testing.T) {
+	orig := Config.Faults
+	defer func() {
+		Config.Faults = orig
+	}()
+
+	req, err := http.NewRequest("GET", "http://test.com/page1.html", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	Config.Faults.FetchErrorPercent = 100
+	if err := injectFetchFault(req); err != nil {
+		t.Errorf("Expected no fault while fault injection is disabled, got %v", err)
+	}
+
+	Config.Faults.Enabled = true
+	err = injectFetchFault(req)
+	if err == nil {
+		t.Fatalf("Expected an injected fault")
+	}
+	fr := &FetchResults{FetchError: err}
+	if !fr.TransientFailure() {
+		t.Errorf("Expected an injected fault to be a transient failure, got %v", err)
+	}
+
+	Config.Faults.FetchErrorPercent = 0
+	if err := injectFetchFault(req); err != nil {
+		t.Errorf("Expected no fault at 0%%, got %v", err)
+	}
+}
+
+func TestInjectTruncation(t *testing.T) {
+	orig := Config.Faults
+	defer func() {
+		Config.Faults = orig
+	}()
+	Config.Faults.Enabled = true
+	Config.Faults.FetchTruncatePercent = 100
+
+	body := strings.Repeat("walker", 100)
+	req, err := http.NewRequest("GET", "http://test.com/page1.html", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	res := &http.Response{
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	injectTruncation(req, res)
+
+	read, err := ioutil.ReadAll(res.Body)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected a truncated body to fail with io.ErrUnexpectedEOF, got %v", err)
+	}
+	if len(read) >= len(body) || !strings.HasPrefix(body, string(read)) {
+		t.Errorf("Expected a prefix of the body to be read, got %d bytes", len(read))
+	}
+}
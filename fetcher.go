@@ -2,23 +2,33 @@ package walker
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"hash"
 	"hash/fnv"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"mime"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"code.google.com/p/log4go"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/iParadigms/walker/dnscache"
 	"github.com/iParadigms/walker/mimetools"
-	"github.com/temoto/robotstxt.go"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // NotYetCrawled is a convenience for time.Unix(0, 0), used as a crawl time in
@@ -43,10 +53,23 @@ type FetchResults struct {
 	// and this is the URL that furnished the http.Response.
 	RedirectedFrom []*URL
 
+	// RedirectStatuses[i] is the status code of the response that redirected
+	// to RedirectedFrom[i], or 0 if it was a meta refresh.
+	RedirectStatuses []int
+
+	// True if the fetch was given up because its redirects looped back to a
+	// URL already requested or went on past fetcher.max_redirects.
+	// FetchError is then a *RedirectLoopError, RedirectChain holds every URL
+	// requested (starting with URL) and RedirectedFrom is empty, so the
+	// results are stored for URL itself.
+	RedirectLoop  bool
+	RedirectChain []*URL
+
 	// Response object; nil if there was a FetchError or ExcludedByRobots is
 	// true. Response.Body may not be the same object the HTTP request actually
 	// returns; the fetcher may have read in the response to parse out links,
-	// replacing Response.Body with an alternate reader.
+	// replacing Response.Body with an alternate reader. If SkippedByPrecheck
+	// is true this is the response to the HEAD request, without a body.
 	Response *http.Response
 
 	// If the user has set cassandra.store_response_body to true in the config file,
@@ -65,6 +88,45 @@ type FetchResults struct {
 	// robots.txt rules
 	ExcludedByRobots bool
 
+	// The robots.txt rule that excluded this link (ex. "Disallow: /*.php$")
+	// if ExcludedByRobots is true
+	RobotsRule string
+
+	// True if this link was excluded because the host's robots.txt answered
+	// with a 5XX or 429 status (see fetcher.robots_unavailable_period), in
+	// which case RobotsRule is "Disallow: /" and RobotsRetryTime is when the
+	// host may be crawled again
+	RobotsUnavailable bool
+	RobotsRetryTime   time.Time
+
+	// The fetcher.crawl_identity this link was fetched under, if set
+	CrawlIdentity string
+
+	// The user agent whose robots.txt group was obeyed for this link (see
+	// fetcher.domain_robots_agents), and the User-agent line of the group
+	// that applied: "*" for the * group, or empty if the host has no
+	// robots.txt or no group applied
+	RobotsAgent      string
+	RobotsGroupAgent string
+
+	// True if the server answered 304 Not Modified to the If-Modified-Since
+	// header sent with the link's last crawl time. The body was not read or
+	// parsed, so handlers can skip the page; the datastore copies the
+	// fingerprints, mime type and body of the previous crawl forward.
+	NotModified bool
+
+	// True if the body is incomplete: it was cut off at
+	// fetcher.max_http_content_size_bytes, either by the server answering a
+	// Range request (see fetcher.range_request_domains) with part of the
+	// content or by the fetcher when the server ignored the Range header.
+	// Handlers should not treat a Truncated body as the whole page.
+	Truncated bool
+
+	// True if a HEAD request (see fetcher.head_precheck_domains) showed this
+	// link is too large or not an accepted content type, so it was not
+	// downloaded
+	SkippedByPrecheck bool
+
 	// True if the page was marked as 'noindex' via a <meta> tag. Whether it
 	// was crawled depends on the honor_meta_noindex configuration parameter
 	MetaNoIndex bool
@@ -73,9 +135,48 @@ type FetchResults struct {
 	// was crawled depends on the honor_meta_nofollow configuration parameter
 	MetaNoFollow bool
 
+	// True if the page was marked 'noarchive' or 'nosnippet' via a robots
+	// <meta> tag or an X-Robots-Tag header. Handlers that archive pages
+	// should not store the body of a NoArchive page if
+	// fetcher.honor_noarchive is true (walker then leaves Body empty).
+	NoArchive bool
+	NoSnippet bool
+
+	// Number of (distinct) links parsed from the page that were not stored
+	// because the page had more than fetcher.max_links_per_page
+	DroppedLinks int
+
+	// Number of links parsed from the page that were not stored because the
+	// fetcher had already stored them from another page of the same host
+	// (see fetcher.host_link_cache_size)
+	DuplicateLinks int
+
+	// True if parsing the page was stopped for having more tags than
+	// fetcher.max_html_nodes or nesting them deeper than
+	// fetcher.max_html_depth; no links were extracted from it then, nor was
+	// its text fingerprinted
+	ParseAborted bool
+
+	// The page's <title> and <meta name="description">, if it was HTML and
+	// had them
+	Title       string
+	Description string
+
+	// The (absolute) targets of the page's <link rel="icon"> tags, if it was
+	// HTML and had any
+	Icons []*URL
+
 	// The Content-Type of the fetched page.
 	MimeType string
 
+	// The Content-Language header of the response, if it had one
+	ContentLanguage string
+
+	// The Content-Type header the server sent, if fetcher.sniff_content_type
+	// replaced it with the type sniffed from the body (see MimeType and
+	// Response.Header for the sniffed type)
+	DeclaredContentType string
+
 	// Fingerprint of the reponse body computed with fnv algorithm (see
 	// hash/fnv in standard library)
 	FnvFingerprint int64
@@ -83,16 +184,341 @@ type FetchResults struct {
 	// Fingerprint of the text parsed out of the response body, also computed
 	// with fnv
 	FnvTextFingerprint int64
+
+	// SHA-256 digest of the response body (nil unless "sha256" is in
+	// fetcher.fingerprints)
+	SHA256Fingerprint []byte
+
+	// SimHash of the text parsed out of the response body (zero unless
+	// "simhash" is in fetcher.fingerprints); see SimHash
+	SimHashFingerprint int64
+
+	// Timing breaks down how long the different phases of the fetch took
+	// (zero if no request was made)
+	Timing FetchTiming
+
+	// TLS describes the connection the response came over; nil unless the
+	// (final, if redirected) request was made over https
+	TLS *TLSInfo
+
+	// OpenGraph, JSON-LD and microdata found in the page if
+	// fetcher.extract_structured_data is true; nil if there was none
+	StructuredData *StructuredData
+}
+
+// DomainAssets describes the domain-level assets the fetcher found for a host
+// listed in fetcher.favicon_domains, passed to AssetDatastore.StoreDomainAssets
+type DomainAssets struct {
+	// The favicon URL requested (/favicon.ico of the host), or the URL it
+	// redirected to
+	FaviconURL *URL
+
+	// When the favicon was requested, and the HTTP status it got
+	FaviconTime   time.Time
+	FaviconStatus int
+
+	// The Content-Type and fnv fingerprint of the favicon, if the request got
+	// a 2XX status
+	FaviconMimeType    string
+	FaviconFingerprint int64
+}
+
+// HostSettings are the fetcher settings a host can override, returned by
+// HostSettingsDatastore.HostSettings. Zero values mean the configured setting
+// applies.
+type HostSettings struct {
+	// The User-Agent to crawl the host with, instead of fetcher.user_agent or
+	// fetcher.user_agents. Its robots.txt groups are matched against it too.
+	UserAgent string
+
+	// The crawl delay for the host, instead of fetcher.default_crawl_delay. A
+	// longer Crawl-delay in its robots.txt still applies, and either is
+	// capped by fetcher.max_crawl_delay.
+	CrawlDelay time.Duration
+}
+
+// maxPolitenessEvents is the number of 429 and 503 responses kept in a
+// PolitenessAudit; more are only counted
+const maxPolitenessEvents = 50
+
+// PolitenessAudit records how a host was crawled during one claim of it,
+// passed to PolitenessDatastore.StorePolitenessAudit
+type PolitenessAudit struct {
+	Host string
+
+	// When crawling the host started, and how long it took
+	Start    time.Time
+	Duration time.Duration
+
+	// The longest crawl delay observed between the host's requests (see
+	// fetcher.default_crawl_delay and fetcher.max_crawl_delay)
+	CrawlDelay time.Duration
+
+	// Number of HTTP requests sent, including robots.txt, HEAD prechecks and
+	// redirects followed
+	Requests int
+
+	// Number of 429 Too Many Requests and 503 Service Unavailable responses
+	TooManyRequests    int
+	ServiceUnavailable int
+
+	// The first of those responses (up to maxPolitenessEvents)
+	Events []PolitenessEvent
+}
+
+// PolitenessEvent is a 429 or 503 response recorded in a PolitenessAudit
+type PolitenessEvent struct {
+	Time   time.Time
+	URL    string
+	Status int
+}
+
+// record counts a request that got res, which redirected hops times on the
+// way
+func (a *PolitenessAudit) record(res *http.Response, hops int) {
+	a.Requests += 1 + hops
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		a.TooManyRequests++
+	case http.StatusServiceUnavailable:
+		a.ServiceUnavailable++
+	default:
+		return
+	}
+	if len(a.Events) < maxPolitenessEvents {
+		a.Events = append(a.Events, PolitenessEvent{
+			Time:   time.Now(),
+			URL:    res.Request.URL.String(),
+			Status: res.StatusCode,
+		})
+	}
+}
+
+// HostSummary summarizes the crawl of a host during one claim of it, POSTed
+// as JSON to fetcher.host_complete_webhook when the fetcher unclaims the host
+type HostSummary struct {
+	Host string `json:"host"`
+
+	// When crawling the host started, and how long it took in seconds
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+
+	// Number of links fetched, and how many of those failed (fetch errors
+	// and responses with status >= 400)
+	Fetched int `json:"fetched"`
+	Errors  int `json:"errors"`
+
+	// Number of links parsed out of the host's pages and passed to the
+	// Datastore; links already stored for the host during this claim are not
+	// counted (see fetcher.host_link_cache_size)
+	NewLinks int `json:"new_links"`
+}
+
+// postHostSummary POSTs s to fetcher.host_complete_webhook
+func postHostSummary(s *HostSummary) {
+	body, err := json.Marshal(s)
+	if err != nil {
+		log4go.Error("Failed to encode summary of %v: %v", s.Host, err)
+		return
+	}
+	if err := postJSON(Config.Fetcher.HostCompleteWebhook, body); err != nil {
+		log4go.Error("Failed to post summary of %v: %v", s.Host, err)
+	}
+}
+
+// TransientFailure returns true if this fetch failed in a way that is likely
+// to go away by itself: the request timed out or the server returned a 5XX
+// status. Datastores use this to schedule a retry (see RetryBackoff).
+func (fr *FetchResults) TransientFailure() bool {
+	if fr.FetchError != nil {
+		ne, ok := fr.FetchError.(net.Error)
+		return ok && ne.Timeout()
+	}
+	return fr.Response != nil && fr.Response.StatusCode >= 500 && fr.Response.StatusCode <= 599
+}
+
+// RetryBackoff returns how long to wait before retrying a link that has had
+// the given number of consecutive transient failures (starting at 1). The
+// delay starts at Config.Fetcher.TransientRetryBackoff and doubles with each
+// failure, up to Config.Fetcher.TransientRetryMaxBackoff. A zero return means
+// retries should not be scheduled.
+func RetryBackoff(failures int) time.Duration {
+	base, err := time.ParseDuration(Config.Fetcher.TransientRetryBackoff)
+	if err != nil || base <= 0 || failures < 1 {
+		return 0
+	}
+	max, err := time.ParseDuration(Config.Fetcher.TransientRetryMaxBackoff)
+	if err != nil {
+		max = base
+	}
+
+	backoff := base
+	for i := 1; i < failures; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// RequestHeader returns the headers walker sent with the request that got
+// fr.Response (the last one, if redirected), with credentials masked. It does
+// not include headers net/http adds itself as it writes the request, like
+// Host. nil if no request was made.
+func (fr *FetchResults) RequestHeader() http.Header {
+	if fr.Response == nil || fr.Response.Request == nil || fr.Response.Request.Header == nil {
+		return nil
+	}
+	return maskedHeader(fr.Response.Request.Header)
+}
+
+// RefetchAfter returns the earliest time it makes sense to fetch this link
+// again according to the response's cache headers: the fetch time plus the
+// Cache-Control max-age or, failing that, the Expires header (taken relative
+// to the Date header, so clock differences with the server don't matter).
+// The delay is limited to Config.Dispatcher.MaxCacheRefetchDelay. A zero time
+// means the response gave no reason to wait, including when it was marked
+// no-cache or no-store.
+func (fr *FetchResults) RefetchAfter() time.Time {
+	if fr.FetchError != nil || fr.Response == nil || fr.Response.Header == nil ||
+		fr.FetchTime.Equal(NotYetCrawled) || fr.FetchTime.IsZero() {
+		return time.Time{}
+	}
+	h := fr.Response.Header
+
+	var delay time.Duration
+	maxAgeFound := false
+	for _, directive := range strings.Split(strings.Join(h["Cache-Control"], ","), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return time.Time{}
+		case strings.HasPrefix(directive, "max-age="):
+			secs, err := strconv.ParseInt(strings.Trim(directive[len("max-age="):], `"`), 10, 64)
+			if err == nil {
+				// RFC 7234 caps delta-seconds at 2^31
+				if secs > 1<<31 {
+					secs = 1 << 31
+				}
+				delay = time.Duration(secs) * time.Second
+				maxAgeFound = true
+			}
+		}
+	}
+
+	if !maxAgeFound {
+		expires, err := http.ParseTime(h.Get("Expires"))
+		if err != nil {
+			return time.Time{}
+		}
+		if date, err := http.ParseTime(h.Get("Date")); err == nil {
+			delay = expires.Sub(date)
+		} else {
+			delay = expires.Sub(fr.FetchTime)
+		}
+	}
+
+	if delay <= 0 {
+		return time.Time{}
+	}
+	max, err := time.ParseDuration(Config.Dispatcher.MaxCacheRefetchDelay)
+	if err == nil && max > 0 && delay > max {
+		delay = max
+	}
+	return fr.FetchTime.Add(delay)
+}
+
+// FetchTiming records where the time went during a fetch. If the request was
+// redirected, each duration is the sum over all requests made. Phases that did
+// not happen (ex. TLS for an http link, or DNS and Connect when a kept-alive
+// connection was reused) are left zero.
+type FetchTiming struct {
+	// Time spent resolving the host name
+	DNS time.Duration
+
+	// Time spent establishing the TCP connection
+	Connect time.Duration
+
+	// Time spent on the TLS handshake
+	TLS time.Duration
+
+	// Time from the request being written to the first byte of the response
+	// arriving (time to first byte)
+	TTFB time.Duration
+
+	// Time from the first byte of the response to the body being completely
+	// read
+	Transfer time.Duration
+
+	// Number of bytes read from the response body
+	Bytes int64
+}
+
+// TLSInfo records the TLS details of an https fetch: what was negotiated and
+// the certificate the server presented
+type TLSInfo struct {
+	// Negotiated protocol version and cipher suite, ex. "TLS 1.3" and
+	// "TLS_AES_128_GCM_SHA256"
+	Version     string
+	CipherSuite string
+
+	// Issuer and Subject of the server's (leaf) certificate, as
+	// distinguished names
+	Issuer  string
+	Subject string
+
+	// When the certificate expires
+	NotAfter time.Time
+
+	// Subject alternative names of the certificate (DNS names and IP
+	// addresses)
+	SANs []string
+}
+
+// newTLSInfo builds a TLSInfo from the state of a TLS connection, returning
+// nil if there is none
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		info.Issuer = cert.Issuer.String()
+		info.Subject = cert.Subject.String()
+		info.NotAfter = cert.NotAfter
+		info.SANs = append(info.SANs, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			info.SANs = append(info.SANs, ip.String())
+		}
+	}
+	return info
 }
 
 // FetchManager configures and runs the crawl.
 //
 // The calling code must create a FetchManager, set a Datastore and handlers,
-// then call `Start()`
+// then call `Start(ctx)`. The context passed to Start is handed to every
+// Datastore and Handler call the FetchManager makes, so cancellation,
+// deadlines and request-scoped values set by the embedding application reach
+// all of them.
 type FetchManager struct {
-	// Handler must be set to handle fetch responses.
+	// Handler must be set to handle fetch responses, unless AckHandler is.
+	// If it is a StreamingHandler, response bodies are streamed to it.
 	Handler Handler
 
+	// AckHandler can be set instead of Handler to handle fetch responses with
+	// a handler that acknowledges them, possibly asynchronously. Handler is
+	// ignored if it is set.
+	AckHandler AckHandler
+
 	// Datastore must be set to drive the fetching.
 	Datastore Datastore
 
@@ -102,63 +528,147 @@ type FetchManager struct {
 	Transport http.RoundTripper
 
 	// TransNoKeepAlive stores a RoundTripper with Keep-Alive set to 0 IF
-	// http_keep_alive == "threshold". Otherwise it's nil.
+	// http_keep_alive is "threshold" or "auto". Otherwise it's nil.
 	TransNoKeepAlive http.RoundTripper
 
 	// Parsed duration of the string Config.Fetcher.HTTPKeepAliveThreshold
 	KeepAliveThreshold time.Duration
 
+	// Parsed fetcher.robots_unavailable_period, and when each host whose
+	// robots.txt answered with a 5XX or 429 status may be crawled again
+	robotsUnavailablePeriod time.Duration
+	robotsUnavailableMu     sync.Mutex
+	robotsUnavailable       map[string]time.Time
+
+	// DNSCache caches host resolutions for the Dial functions of Transport
+	// and TransNoKeepAlive (when they are *http.Transports). If nil, one is
+	// created from the fetcher.dns_* config settings. Its Stats are logged
+	// when the FetchManager stops.
+	DNSCache *dnscache.Cache
+
+	// DeadLetters can be set to record responses the Handler failed to
+	// handle. If nil, a FileDeadLetterSink is used if
+	// Config.Fetcher.DeadLetterFile is set, otherwise the Datastore if it
+	// implements DeadLetterSink. Failures are only logged if none of these
+	// apply.
+	DeadLetters DeadLetterSink
+
+	// Replay can be set to replay previously fetched responses instead of
+	// crawling: every request is answered from it (see NewReplayTransport,
+	// which replaces Transport), crawl delays are skipped, and fetch results
+	// and parsed links are not stored in the Datastore, which still drives
+	// which hosts and links are fetched. Parsing, fingerprinting and the
+	// Handler run as usual, so this is useful to test them against a real
+	// corpus offline.
+	Replay ReplaySource
+
+	// URLList can be set to fetch exactly these URLs instead of the segments
+	// the Datastore hands out: fetchers claim the list's domains in turn,
+	// still honoring robots.txt and crawl delays, and the FetchManager stops
+	// once every URL has been fetched. Fetch results and parsed links are
+	// stored in the Datastore as usual. If the Datastore is a
+	// HostClaimingDatastore each domain is claimed in it while it is
+	// crawled. See ReadURLList.
+	URLList []*URL
+
+	// AckHandler, or Handler adapted to one (see AdaptHandler)
+	handler AckHandler
+
+	// Handler, if it is a StreamingHandler and AckHandler isn't set
+	streamer StreamingHandler
+
+	// how long to wait between attempts to handle a response
+	handlerRetryDelay time.Duration
+
+	// Parsed fetcher.handler_ack_timeout
+	handlerAckTimeout time.Duration
+
 	activeThreadsWait sync.WaitGroup
 
-	// used to match Content-Type headers
-	acceptFormats *mimetools.Matcher
+	// Parsed fetcher.max_time_per_host; 0 for no limit
+	maxTimePerHost time.Duration
+
+	// number of user agents handed out for fetcher.user_agent_rotation
+	userAgentsUsed uint64
 
-	defCrawlDelay time.Duration
-	maxCrawlDelay time.Duration
+	// fetcher.domain_credentials, resolved (see credentialFor)
+	credentials map[string]*credential
+
+	// The transports bound to each of fetcher.local_addrs, if set and
+	// Transport wasn't given
+	egress []*egressTransports
+
+	// set if http_keep_alive is "auto", in which case fetchers tune the
+	// keep-alive threshold of each host (see keepAliveTuner)
+	autoKeepAlive bool
 
 	// how long to wait between Datastore.KeepAlive() calls.
 	activeFetcherHeartbeat time.Duration
 
-	// close this channel to kill the keep-alive thread
-	keepAliveQuit chan struct{}
+	// close this channel (see stopKeepAlive) to kill the keep-alive thread
+	keepAliveQuit     chan struct{}
+	keepAliveQuitOnce sync.Once
+
+	// closed by Drain to stop fetchers from claiming new hosts
+	drain chan struct{}
+
+	// Scales the number of fetchers if fetcher.max_simultaneous_fetchers is
+	// set; nil otherwise
+	autoscaler *fetcherAutoscaler
+
+	// Waits for the fetchers, and the autoscaler since it may start more
+	fetchWait sync.WaitGroup
+
+	// ctx is the context given to Start; cancel cancels it (see Stop)
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	// These variables explicitly synchornized. See started() and fetchers()
 	sharedVarMutex sync.Mutex
 	_started       bool
 	_fetchers      []*fetcher
 
+	// number of fetchers started so far, to spread them across
+	// fetcher.local_addrs
+	fetchersStarted int
+
 	// If this flag is set, oneShot is set on each child fetcher
 	oneShot bool
 }
 
-// Start begins processing assuming that the datastore and any handlers have
+// egressTransports are the transports dialing from one of
+// fetcher.local_addrs
+type egressTransports struct {
+	addr             string
+	transport        *http.Transport
+	transNoKeepAlive *http.Transport
+}
+
+// run begins processing assuming that the datastore and any handlers have
 // been set. This is a blocking call (run in a goroutine if you want to do
 // other things)
 //
 // You cannot change the datastore or handlers after starting.
-func (fm *FetchManager) run() {
+func (fm *FetchManager) run(ctx context.Context) {
 	log4go.Info("Starting FetchManager")
 	if fm.Datastore == nil {
 		panic("Cannot start a FetchManager without a datastore")
 	}
-	if fm.Handler == nil {
+	if fm.Handler == nil && fm.AckHandler == nil {
 		panic("Cannot start a FetchManager without a handler")
 	}
 	if fm.started() {
 		panic("Cannot start a FetchManager multiple times")
 	}
-
-	var err error
-	fm.defCrawlDelay, err = time.ParseDuration(Config.Fetcher.DefaultCrawlDelay)
-	if err != nil {
-		// This won't happen b/c this duration is checked in Config
-		panic(err)
+	if ctx == nil {
+		panic("Cannot start a FetchManager with a nil context")
 	}
+	fm.ctx, fm.cancel = context.WithCancel(ctx)
 
-	fm.maxCrawlDelay, err = time.ParseDuration(Config.Fetcher.MaxCrawlDelay)
+	var err error
+	fm.maxTimePerHost, err = time.ParseDuration(Config.Fetcher.MaxTimePerHost)
 	if err != nil {
-		// This won't happen b/c this duration is checked in Config
-		panic(err)
+		panic(err) // This won't happen b/c this duration is checked in Config
 	}
 
 	ttl, err := time.ParseDuration(Config.Fetcher.ActiveFetchersTTL)
@@ -167,19 +677,51 @@ func (fm *FetchManager) run() {
 	}
 	fm.activeFetcherHeartbeat = time.Duration(float32(ttl) * Config.Fetcher.ActiveFetchersKeepratio)
 
-	fm.acceptFormats, err = mimetools.NewMatcher(Config.Fetcher.AcceptFormats)
+	fm.handlerRetryDelay, err = time.ParseDuration(Config.Fetcher.HandlerRetryDelay)
 	if err != nil {
-		panic(fmt.Errorf("mimetools.NewMatcher failed to initialize: %v", err))
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	fm.handlerAckTimeout, err = time.ParseDuration(Config.Fetcher.HandlerAckTimeout)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	fm.handler = fm.AckHandler
+	if fm.handler == nil {
+		fm.handler = AdaptHandler(fm.Handler)
+		fm.streamer, _ = fm.Handler.(StreamingHandler)
+	}
+	fm.credentials = loadCredentials()
+	if fm.DeadLetters == nil {
+		if Config.Fetcher.DeadLetterFile != "" {
+			fm.DeadLetters = &FileDeadLetterSink{Path: Config.Fetcher.DeadLetterFile}
+		} else if sink, ok := fm.Datastore.(DeadLetterSink); ok {
+			fm.DeadLetters = sink
+		}
+	}
+
+	if fm.Replay != nil {
+		log4go.Info("Replaying stored responses instead of crawling")
+		fm.Transport = NewReplayTransport(fm.Replay)
+		fm.TransNoKeepAlive = nil
+		fm.Datastore = &replayDatastore{Datastore: fm.Datastore}
+	}
+	if fm.URLList != nil {
+		log4go.Info("Fetching a list of %d URLs instead of crawling", len(fm.URLList))
+		fm.Datastore = newURLListDatastore(fm.Datastore, fm.URLList)
+		// The fetchers are done once they have no more of the list to claim
+		fm.oneShot = true
 	}
 
 	// Make sure that the initial KeepAlive work is done
-	err = fm.Datastore.KeepAlive()
+	err = fm.Datastore.KeepAlive(fm.ctx)
 	if err != nil {
 		err = fmt.Errorf("Initial KeepAlive call fatally failed: %v", err)
 		log4go.Error(err.Error())
 		panic(err)
 	}
 
+	fm.drain = make(chan struct{})
+
 	// Create keep-alive thread
 	fm.keepAliveQuit = make(chan struct{})
 	fm.activeThreadsWait.Add(1)
@@ -189,10 +731,13 @@ func (fm *FetchManager) run() {
 			case <-fm.keepAliveQuit:
 				fm.activeThreadsWait.Done()
 				return
+			case <-fm.ctx.Done():
+				fm.activeThreadsWait.Done()
+				return
 			case <-time.After(fm.activeFetcherHeartbeat):
 			}
 
-			err := fm.Datastore.KeepAlive()
+			err := fm.Datastore.KeepAlive(fm.ctx)
 			if err != nil {
 				log4go.Error("KeepAlive Failed: %v", err)
 			}
@@ -211,103 +756,225 @@ func (fm *FetchManager) run() {
 		panic(err)
 	}
 
+	fm.robotsUnavailablePeriod, err = time.ParseDuration(Config.Fetcher.RobotsUnavailablePeriod)
+	if err != nil {
+		// Shouldn't happen since this variable is parsed in assertConfigInvariants
+		panic(err)
+	}
+
+	keepAliveMode := strings.ToLower(Config.Fetcher.HTTPKeepAlive)
+	fm.autoKeepAlive = keepAliveMode == "auto"
+	switchesKeepAlive := keepAliveMode == "threshold" || fm.autoKeepAlive
 	if fm.Transport == nil {
 		keepAlive := 30 * time.Second
-		if strings.ToLower(Config.Fetcher.HTTPKeepAlive) == "never" {
+		if keepAliveMode == "never" {
 			keepAlive = 0 * time.Second
 		}
 
 		// Set fm.Transport == http.DefaultTransport, but create a new one; we
 		// want to override Dial but don't want to globally override it in
 		// http.DefaultTransport.
-		fm.Transport = &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			Dial: (&net.Dialer{
-				Timeout:   timeout,
-				KeepAlive: keepAlive,
-			}).Dial,
-			TLSHandshakeTimeout: 10 * time.Second,
+		if len(Config.Fetcher.LocalAddrs) > 0 {
+			// Build transports bound to each local address; fetchers are
+			// spread across them in run order
+			for _, addr := range Config.Fetcher.LocalAddrs {
+				ip := net.ParseIP(addr)
+				e := &egressTransports{addr: addr, transport: newTransport(timeout, keepAlive, ip)}
+				if fm.TransNoKeepAlive == nil && switchesKeepAlive {
+					e.transNoKeepAlive = newTransport(timeout, 0*time.Second, ip)
+				}
+				fm.egress = append(fm.egress, e)
+			}
+			fm.Transport = fm.egress[0].transport
+			if fm.egress[0].transNoKeepAlive != nil {
+				fm.TransNoKeepAlive = fm.egress[0].transNoKeepAlive
+			}
+		} else {
+			fm.Transport = newTransport(timeout, keepAlive, nil)
 		}
+	} else if len(Config.Fetcher.LocalAddrs) > 0 {
+		log4go.Info("Given a Transport, ignoring fetcher.local_addrs")
 	}
-	if fm.TransNoKeepAlive == nil && strings.ToLower(Config.Fetcher.HTTPKeepAlive) == "threshold" {
-		fm.TransNoKeepAlive = &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			Dial: (&net.Dialer{
-				Timeout:   timeout,
-				KeepAlive: 0 * time.Second,
-			}).Dial,
-			TLSHandshakeTimeout: 10 * time.Second,
-		}
+	// A replay answers every request from fm.Replay, so it must not be
+	// given a transport to the network
+	if fm.TransNoKeepAlive == nil && switchesKeepAlive && fm.Replay == nil {
+		fm.TransNoKeepAlive = newTransport(timeout, 0*time.Second, nil)
 	}
 
-	t, ok := fm.Transport.(*http.Transport)
-	if ok {
-		var err error
-		t.Dial, err = dnscache.Dial(t.Dial, Config.Fetcher.MaxDNSCacheEntries)
+	if fm.DNSCache == nil {
+		fm.DNSCache, err = newDNSCache()
 		if err != nil {
 			// This should be a very rare panic
-			log4go.Error("Failed to construct dnscacheing Dialer for Transport: %v", err)
+			log4go.Error("Failed to construct dns cache: %v", err)
 			panic(err)
 		}
-	} else {
-		log4go.Info("Given an non-http Transport, not using dns caching")
 	}
 
-	if fm.TransNoKeepAlive != nil {
-		t, ok = fm.TransNoKeepAlive.(*http.Transport)
-		if ok {
-			t.Dial, err = dnscache.Dial(t.Dial, Config.Fetcher.MaxDNSCacheEntries)
-			if err != nil {
-				// This should be a very rare panic
-				log4go.Error("Failed to construct dnscacheing Dialer for TransNoKeepAlive: %v", err)
-				panic(err)
+	if len(fm.egress) > 0 {
+		for _, e := range fm.egress {
+			e.transport.Dial = fm.DNSCache.Dial(e.transport.Dial)
+			if e.transNoKeepAlive != nil {
+				e.transNoKeepAlive.Dial = fm.DNSCache.Dial(e.transNoKeepAlive.Dial)
 			}
+		}
+	} else {
+		t, ok := fm.Transport.(*http.Transport)
+		if ok {
+			t.Dial = fm.DNSCache.Dial(t.Dial)
 		} else {
-			log4go.Info("Given a non-http TransNoKeepAlive, not using dns caching")
+			log4go.Info("Given an non-http Transport, not using dns caching")
+		}
+
+		if fm.TransNoKeepAlive != nil {
+			t, ok = fm.TransNoKeepAlive.(*http.Transport)
+			if ok {
+				t.Dial = fm.DNSCache.Dial(t.Dial)
+			} else {
+				log4go.Info("Given a non-http TransNoKeepAlive, not using dns caching")
+			}
 		}
 	}
 
-	numFetchers := Config.Fetcher.NumSimultaneousFetchers
-	fetchers := make([]*fetcher, numFetchers)
-	var fetchWait sync.WaitGroup
-	for i := 0; i < numFetchers; i++ {
-		f := newFetcher(fm)
-		f.oneShot = fm.oneShot
-		fetchers[i] = f
-		fm.activeThreadsWait.Add(1)
-		fetchWait.Add(1)
+	if Config.Fetcher.MaxSimultaneousFetchers > 0 {
+		if fm.oneShot {
+			log4go.Info("Not autoscaling fetchers since they stop once they run out of work")
+		} else {
+			fm.autoscaler = newFetcherAutoscaler()
+		}
+	}
+	for i := 0; i < Config.Fetcher.NumSimultaneousFetchers; i++ {
+		fm.startFetcher()
+	}
+	if fm.autoscaler != nil {
+		fm.fetchWait.Add(1)
 		go func() {
-			f.start()
-			fetchWait.Done()
-			fm.activeThreadsWait.Done()
+			fm.autoscaler.run(fm)
+			fm.fetchWait.Done()
 		}()
 	}
-	fm.setFetchers(fetchers)
 	fm.setStarted(true)
 
-	fetchWait.Wait()
+	fm.fetchWait.Wait()
 	if fm.oneShot {
 		// In one shot mode, the fetchers decide when they're done. So if we get here, then the fetchers are done
 		// (and called fetchWait.Done()), and we clean up the last (keepAlive) thread.
-		close(fm.keepAliveQuit)
+		fm.stopKeepAlive()
+		fm.cancel()
+	}
+}
+
+// newTransport creates a transport like http.DefaultTransport, dialing with
+// the given timeouts and, if localIP is not nil, from that local address
+func newTransport(timeout, keepAlive time.Duration, localIP net.IP) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: keepAlive,
+	}
+	if localIP != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: localIP}
+	}
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		Dial:                preferIPFamily(localIPFamily(dialer.Dial, localIP)),
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// localIPFamily wraps dial so that tcp connections from localIP only go to
+// addresses of its family, since a socket bound to an IPv4 address cannot
+// connect to an IPv6 one and vice versa. dial is returned as is if localIP is
+// nil.
+func localIPFamily(dial func(network, addr string) (net.Conn, error), localIP net.IP) func(network, addr string) (net.Conn, error) {
+	if localIP == nil {
+		return dial
+	}
+	family := "tcp6"
+	if localIP.To4() != nil {
+		family = "tcp4"
+	}
+	return func(network, addr string) (net.Conn, error) {
+		if network == "tcp" {
+			network = family
+		}
+		return dial(network, addr)
+	}
+}
+
+// preferIPFamily wraps dial according to fetcher.ip_preference. For "ipv4"
+// or "ipv6", tcp connections are first attempted using only that address
+// family, falling back to the other one if that fails. For "happy_eyeballs"
+// dial is returned as is, since net.Dialer already races IPv6 and IPv4
+// addresses (RFC 6555).
+func preferIPFamily(dial func(network, addr string) (net.Conn, error)) func(network, addr string) (net.Conn, error) {
+	var first, second string
+	switch strings.ToLower(Config.Fetcher.IPPreference) {
+	case "ipv4":
+		first, second = "tcp4", "tcp6"
+	case "ipv6":
+		first, second = "tcp6", "tcp4"
+	default:
+		return dial
+	}
+	return func(network, addr string) (net.Conn, error) {
+		if network != "tcp" {
+			return dial(network, addr)
+		}
+		conn, err := dial(first, addr)
+		if err == nil {
+			return conn, nil
+		}
+		log4go.Fine("Failed to dial %v over %v (%v), trying %v", addr, first, err, second)
+		conn, fallbackErr := dial(second, addr)
+		if fallbackErr != nil {
+			// Report the error for the preferred family
+			return nil, err
+		}
+		return conn, nil
 	}
 }
 
-// NOTE on lifecycle: in normal operation the users calls FetchManager.Start() on a separate goroutine. Then later, when
-// the user wants to stop the FetchManager, they call Stop(). Start()/Stop() should always be paired. The other mode
-// of operation is used for testing, and goes through the oneShotRun() method. Users should just call oneShotRun()
-// synchronously, and when it returns all the available work is complete and the FetchManager is done.
+// newDNSCache creates a dnscache.Cache from the fetcher.dns_* config settings
+func newDNSCache() (*dnscache.Cache, error) {
+	ttl, err := time.ParseDuration(Config.Fetcher.DNSCacheTTL)
+	if err != nil {
+		return nil, err // Should not happen since it is parsed at config load
+	}
+	negativeTTL, err := time.ParseDuration(Config.Fetcher.DNSNegativeCacheTTL)
+	if err != nil {
+		return nil, err // Should not happen since it is parsed at config load
+	}
+	c, err := dnscache.New(Config.Fetcher.MaxDNSCacheEntries, ttl, negativeTTL)
+	if err != nil {
+		return nil, err
+	}
+	for host, ip := range Config.Fetcher.DNSOverrides {
+		c.Override(host, ip)
+	}
+	return c, nil
+}
 
-// Start starts a FetchManager. Always pair go Start() with a Stop()
-func (fm *FetchManager) Start() {
+// NOTE on lifecycle: in normal operation the users calls FetchManager.Start(ctx) on a separate goroutine. Then later,
+// when the user wants to stop the FetchManager, they either call Stop() or cancel ctx. Stop() lets the fetchers finish
+// the request they are working on; cancelling ctx aborts in-flight requests and Datastore/Handler calls as well. In
+// both cases Start(ctx) returns once every fetcher has finished. The other mode of operation is used for testing, and
+// goes through the oneShotRun() method. Users should just call oneShotRun() synchronously, and when it returns all the
+// available work is complete and the FetchManager is done.
+
+// Start starts a FetchManager. It blocks until the FetchManager is stopped,
+// either by a call to Stop() or by ctx being cancelled, or (if it was given a
+// URLList) until the whole list has been fetched.
+func (fm *FetchManager) Start(ctx context.Context) {
 	fm.oneShot = false
-	fm.run()
+	fm.run(ctx)
+	fm.activeThreadsWait.Wait()
+	log4go.Info("FetchManager DNS cache: %v", fm.DNSCache.Stats())
+	log4go.Info("FetchManager connections: %v", CurrentConnStats())
 }
 
 // oneShot starts a FetchManager in synchronous (testing) mode
 func (fm *FetchManager) oneShotRun() {
 	fm.oneShot = true
-	fm.run()
+	fm.run(context.Background())
 	fm.activeThreadsWait.Wait()
 }
 
@@ -318,11 +985,105 @@ func (fm *FetchManager) Stop() {
 	if !fm.started() {
 		panic("Cannot stop a FetchManager that has not been started")
 	}
+	fm.stopAutoscaler()
 	for _, f := range fm.fetchers() {
 		go f.stop()
 	}
-	close(fm.keepAliveQuit)
+	fm.stopKeepAlive()
+	fm.activeThreadsWait.Wait()
+	fm.cancel()
+}
+
+// Drain stops the FetchManager without leaving anything claimed, for rolling
+// restarts: fetchers stop claiming new hosts but finish the segments they are
+// crawling, keep-alives stop, and then, if the Datastore is a
+// RetiringDatastore, this crawler is retired. It blocks until all that is
+// done. Stop or cancel the FetchManager's context to give up on the current
+// segments instead.
+func (fm *FetchManager) Drain() {
+	log4go.Info("Draining FetchManager")
+	if !fm.started() {
+		panic("Cannot drain a FetchManager that has not been started")
+	}
+	close(fm.drain)
+	fm.stopAutoscaler()
+	for _, f := range fm.fetchers() {
+		<-f.done
+	}
+	fm.stopKeepAlive()
 	fm.activeThreadsWait.Wait()
+
+	if rds, ok := fm.Datastore.(RetiringDatastore); ok {
+		if err := rds.Retire(context.WithoutCancel(fm.ctx)); err != nil {
+			log4go.Error("Failed to retire crawler: %v", err)
+		} else {
+			log4go.Info("Retired crawler")
+		}
+	}
+	fm.cancel()
+}
+
+// urlListPending returns true if the FetchManager was given a URLList and
+// some of its hosts could not be claimed yet, so one-shot fetchers should
+// keep trying rather than quit
+func (fm *FetchManager) urlListPending() bool {
+	lds, ok := fm.Datastore.(*urlListDatastore)
+	return ok && lds.pending()
+}
+
+// stopKeepAlive stops the keep-alive thread. It may be called more than once,
+// ex. by Stop while a one-shot FetchManager is finishing on its own.
+func (fm *FetchManager) stopKeepAlive() {
+	fm.keepAliveQuitOnce.Do(func() {
+		close(fm.keepAliveQuit)
+	})
+}
+
+// stopAutoscaler stops the autoscaler, if any, so the fetchers can be stopped
+// without more being started
+func (fm *FetchManager) stopAutoscaler() {
+	if fm.autoscaler != nil {
+		fm.autoscaler.stop()
+	}
+}
+
+// startFetcher starts another fetcher
+func (fm *FetchManager) startFetcher() {
+	f := newFetcher(fm)
+	f.oneShot = fm.oneShot
+
+	fm.sharedVarMutex.Lock()
+	if len(fm.egress) > 0 {
+		f.bindEgress(fm.egress[fm.fetchersStarted%len(fm.egress)])
+	}
+	fm.fetchersStarted++
+	fm._fetchers = append(fm._fetchers, f)
+	fm.sharedVarMutex.Unlock()
+
+	fm.activeThreadsWait.Add(1)
+	fm.fetchWait.Add(1)
+	go func() {
+		f.start()
+		fm.fetchWait.Done()
+		fm.activeThreadsWait.Done()
+	}()
+}
+
+// stopFetchers signals the n most recently started fetchers to stop. They
+// unclaim their hosts once they finish their current request.
+func (fm *FetchManager) stopFetchers(n int) {
+	fm.sharedVarMutex.Lock()
+	keep := len(fm._fetchers) - n
+	if keep < 0 {
+		keep = 0
+	}
+	stopping := fm._fetchers[keep:]
+	fm._fetchers = append([]*fetcher(nil), fm._fetchers[:keep]...)
+	fm.sharedVarMutex.Unlock()
+
+	for _, f := range stopping {
+		f.signalQuit()
+	}
 }
 
 func (fm *FetchManager) started() bool {
@@ -343,38 +1104,44 @@ func (fm *FetchManager) fetchers() []*fetcher {
 	return fm._fetchers
 }
 
-func (fm *FetchManager) setFetchers(fetchers []*fetcher) {
-	fm.sharedVarMutex.Lock()
-	defer fm.sharedVarMutex.Unlock()
-	fm._fetchers = fetchers
-}
-
 // fetcher encompasses one of potentially many fetchers the FetchManager may
 // start up. It will effectively manage one goroutine, crawling one host at a
-// time, claiming a new host when it has exhausted the previous one.
+// time (or interleaving up to fetcher.hosts_per_fetcher of them), claiming a
+// new host when it has exhausted the previous one.
 type fetcher struct {
 	fm         *FetchManager
-	host       string
+	ctx        context.Context
 	httpclient *http.Client
 	crawldelay time.Duration
 
-	// quit signals the fetcher to stop
-	quit chan struct{}
+	// The transports httpclient switches between (see
+	// setTransportFromCrawlDelay); the FetchManager's unless the fetcher is
+	// bound to one of fetcher.local_addrs
+	transport        http.RoundTripper
+	transNoKeepAlive http.RoundTripper
+
+	// The host currently being fetched from. When crawling several hosts at
+	// once the fetcher switches this between them.
+	*hostCrawl
+
+	// quit signals the fetcher to stop; close it with signalQuit
+	quit     chan struct{}
+	quitOnce sync.Once
 
 	// done receives when the fetcher has finished; this is necessary because
 	// the fetcher may need to clean up (ex. unclaim the current host) after
 	// reading from quit
 	done chan struct{}
 
-	excludeLink *regexp.Regexp
-	includeLink *regexp.Regexp
-
-	// defRobots holds the robots.txt definition used if a host doesn't
-	// publish a robots.txt file on it's own.
-	defRobots *robotstxt.Group
-
-	// robotsMap maps host -> robots.txt definition to use
-	robotsMap map[string]*robotstxt.Group
+	// Settings that can change with ReloadConfig, as of configGeneration
+	// (see loadConfig)
+	configGeneration uint64
+	acceptFormats    *mimetools.Matcher
+	acceptHeader     string
+	defCrawlDelay    time.Duration
+	maxCrawlDelay    time.Duration
+	excludeLink      *regexp.Regexp
+	includeLink      *regexp.Regexp
 
 	// Where to read content pages into
 	readBuffer bytes.Buffer
@@ -383,6 +1150,69 @@ type fetcher struct {
 	oneShot bool
 }
 
+// hostCrawl holds the state of crawling one claimed host
+type hostCrawl struct {
+	host string
+
+	// the User-Agent the host is crawled with (see
+	// FetchManager.userAgentFor)
+	userAgent string
+
+	// the host's crawl delay override (see HostSettings), 0 if it has none
+	crawlDelay time.Duration
+
+	// the Accept-Language the host is crawled with, if any (see
+	// acceptLanguageFor)
+	acceptLanguage string
+
+	// the user agent whose robots.txt group the host is crawled by (see
+	// robotsAgentFor)
+	robotsAgent string
+
+	// defRobots holds the robots.txt definition used if a host doesn't
+	// publish a robots.txt file on it's own.
+	defRobots *RobotsGroup
+
+	// robotsMap maps host and robots agent (see robotsKey) -> robots.txt
+	// definition to use
+	robotsMap map[string]*RobotsGroup
+
+	// The parsed links stored while crawling the host, so each is only
+	// stored once per host (see fetcher.host_link_cache_size); nil if
+	// disabled. duplicateLinks counts the ones suppressed.
+	hostLinks      *lru.Cache
+	duplicateLinks int
+
+	// The host's segment, nil if the host is not to be crawled (ex. it was
+	// blacklisted)
+	links <-chan *URL
+
+	// The links left in the segment when the host was abandoned after
+	// fetcher.max_time_per_host (see RequeueDatastore)
+	leftover []*URL
+
+	// When crawling the host started
+	start time.Time
+
+	// When the host's crawl delay allows fetching from it again
+	next time.Time
+
+	// The requests made to the host (see PolitenessDatastore)
+	audit PolitenessAudit
+
+	// Picks the host's transport if http_keep_alive is "auto"
+	keepAlive keepAliveTuner
+
+	// Links fetched from the host, how many of them failed, and how many
+	// parsed links were stored (see HostSummary)
+	fetched     int
+	fetchErrors int
+	storedLinks int
+
+	// Responses from the host the handler has yet to ack or nack
+	pending pendingAcks
+}
+
 func aggregateRegex(list []string, sourceName string) (*regexp.Regexp, error) {
 	if len(list) == 0 {
 		return nil, nil
@@ -421,37 +1251,75 @@ func newFetcher(fm *FetchManager) *fetcher {
 
 	f := new(fetcher)
 	f.fm = fm
+	f.ctx = fm.ctx
+	f.transport = fm.Transport
+	f.transNoKeepAlive = fm.TransNoKeepAlive
 	f.httpclient = &http.Client{
 		Transport: fm.Transport,
 		Timeout:   timeout,
 	}
 	f.quit = make(chan struct{})
 	f.done = make(chan struct{})
+	f.hostCrawl = &hostCrawl{userAgent: Config.Fetcher.UserAgent}
+
+	f.loadConfig()
+	return f
+}
+
+// loadConfig parses the fetcher's settings that can be changed by
+// ReloadConfig. It is called again when a host is claimed if the config has
+// been reloaded since.
+func (f *fetcher) loadConfig() {
+	f.configGeneration = currentConfigGeneration()
+
+	cfg := HotConfig()
+	var err error
+	f.defCrawlDelay, err = time.ParseDuration(cfg.Fetcher.DefaultCrawlDelay)
+	if err != nil {
+		// This won't happen b/c this duration is checked in Config
+		panic(err)
+	}
+
+	f.maxCrawlDelay, err = time.ParseDuration(cfg.Fetcher.MaxCrawlDelay)
+	if err != nil {
+		// This won't happen b/c this duration is checked in Config
+		panic(err)
+	}
 
-	if len(Config.Fetcher.ExcludeLinkPatterns) > 0 {
-		f.excludeLink, err = aggregateRegex(Config.Fetcher.ExcludeLinkPatterns, "exclude_link_patterns")
+	f.acceptHeader = strings.Join(cfg.Fetcher.AcceptFormats, ",")
+	f.acceptFormats, err = mimetools.NewMatcher(cfg.Fetcher.AcceptFormats)
+	if err != nil {
+		panic(fmt.Errorf("mimetools.NewMatcher failed to initialize: %v", err))
+	}
+
+	f.excludeLink = nil
+	if len(cfg.Fetcher.ExcludeLinkPatterns) > 0 {
+		f.excludeLink, err = aggregateRegex(cfg.Fetcher.ExcludeLinkPatterns, "exclude_link_patterns")
 		if err != nil {
 			// This shouldn't happen b/c it's already been checked when loading config
 			panic(err)
 		}
 	}
 
-	if len(Config.Fetcher.IncludeLinkPatterns) > 0 {
-		f.includeLink, err = aggregateRegex(Config.Fetcher.IncludeLinkPatterns, "include_link_patterns")
+	f.includeLink = nil
+	if len(cfg.Fetcher.IncludeLinkPatterns) > 0 {
+		f.includeLink, err = aggregateRegex(cfg.Fetcher.IncludeLinkPatterns, "include_link_patterns")
 		if err != nil {
 			// This shouldn't happen b/c it's already been checked when loading config
 			panic(err)
 		}
 	}
-
-	return f
 }
 
 // start blocks until the fetcher has completed by being told to quit.
 func (f *fetcher) start() {
 	log4go.Debug("Starting new fetcher")
-	for f.crawlNewHost() {
-		// Crawl until told to stop...
+	if Config.Fetcher.HostsPerFetcher > 1 {
+		f.crawlHosts()
+	} else {
+		for f.crawlNewHost() {
+			// Crawl until told to stop...
+		}
 	}
 	log4go.Debug("Stopping fetcher")
 	close(f.done)
@@ -459,94 +1327,346 @@ func (f *fetcher) start() {
 
 // stop signals a fetcher to stop and waits until completion.
 func (f *fetcher) stop() {
-	close(f.quit)
+	f.signalQuit()
 	<-f.done
 }
 
+// signalQuit closes f.quit, unless it has already been closed (a one-shot
+// fetcher closes it itself once it runs out of work)
+func (f *fetcher) signalQuit() {
+	f.quitOnce.Do(func() {
+		close(f.quit)
+	})
+}
+
+// quitSignaled returns true if the fetcher has been told to stop, either
+// through stop() or by its context being cancelled.
+func (f *fetcher) quitSignaled() bool {
+	select {
+	case <-f.quit:
+		return true
+	case <-f.ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// draining returns true if the FetchManager is being drained, so the fetcher
+// should not claim another host.
+func (f *fetcher) draining() bool {
+	select {
+	case <-f.fm.drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d to pass. It returns false early if the fetcher was
+// signaled to quit in the meantime.
+func (f *fetcher) sleep(d time.Duration) bool {
+	select {
+	case <-f.quit:
+		return false
+	case <-f.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
 // crawlNewHost host crawls a single host, or delays and returns if there was
 // nothing to crawl.
 // Returns false if it was signaled to quit and the routine should finish
 func (f *fetcher) crawlNewHost() bool {
-	select {
-	case <-f.quit:
+	if f.quitSignaled() || f.draining() {
 		return false
-	default:
 	}
 
-	f.host = f.fm.Datastore.ClaimNewHost()
-	if f.host == "" {
-		if f.oneShot {
-			close(f.quit)
+	h := f.claimHost()
+	if h == nil {
+		if f.oneShot && !f.fm.urlListPending() {
+			f.signalQuit()
 			return false // Signals to start() that this fetcher is done with all it's work
 		}
-		time.Sleep(time.Second)
-		return true
+		return f.sleep(time.Second)
 	}
+	// Let the defer unclaim the host even if we were told to quit
+	defer f.finishHost(h)
+
+	for f.crawlLink(h) {
+		if delta := time.Until(h.next); delta > 0 && !f.sleep(delta) {
+			return false
+		}
+	}
+	return !f.quitSignaled()
+}
+
+// crawlHosts crawls up to fetcher.hosts_per_fetcher hosts at once: it always
+// fetches from the host whose crawl delay runs out first, so that the fetcher
+// is not idle while waiting out one host's crawl delay. It returns once the
+// fetcher is signaled to quit, or is draining (or one-shot) and has finished
+// its hosts.
+func (f *fetcher) crawlHosts() {
+	var hosts []*hostCrawl
 	defer func() {
-		log4go.Info("Finished crawling %v, unclaiming", f.host)
-		f.fm.Datastore.UnclaimHost(f.host)
+		for _, h := range hosts {
+			f.finishHost(h)
+		}
 	}()
 
-	if f.checkForBlacklisting(f.host) {
-		return true
+	// When the datastore last had no host for us; we don't ask again for a
+	// second, as crawlNewHost would sleep
+	var noHostAt time.Time
+	for !f.quitSignaled() {
+		for len(hosts) < Config.Fetcher.HostsPerFetcher && !f.draining() && time.Since(noHostAt) >= time.Second {
+			h := f.claimHost()
+			if h == nil {
+				noHostAt = time.Now()
+				break
+			}
+			hosts = append(hosts, h)
+		}
+
+		if len(hosts) == 0 {
+			if f.draining() {
+				return
+			}
+			if f.oneShot && !f.fm.urlListPending() {
+				f.signalQuit()
+				return
+			}
+			if !f.sleep(time.Until(noHostAt.Add(time.Second))) {
+				return
+			}
+			continue
+		}
+
+		next := 0
+		for i, h := range hosts {
+			if h.next.Before(hosts[next].next) {
+				next = i
+			}
+		}
+		h := hosts[next]
+		if delta := time.Until(h.next); delta > 0 && !f.sleep(delta) {
+			return
+		}
+		if !f.crawlLink(h) {
+			f.finishHost(h)
+			hosts = append(hosts[:next], hosts[next+1:]...)
+		}
+	}
+}
+
+// claimHost claims a new host and gets ready to crawl it, returning nil if
+// there was no host to claim. The host must be passed to finishHost when done.
+func (f *fetcher) claimHost() *hostCrawl {
+	start := time.Now()
+	host := f.fm.Datastore.ClaimNewHost(f.ctx)
+	f.fm.autoscaler.observe(time.Since(start))
+	if host == "" {
+		return nil
+	}
+	if f.configGeneration != currentConfigGeneration() {
+		log4go.Info("Config was reloaded, reloading fetcher settings")
+		f.loadConfig()
+	}
+
+	settings := f.hostSettings(host)
+	h := &hostCrawl{
+		host:           host,
+		userAgent:      f.fm.userAgentFor(settings),
+		crawlDelay:     settings.CrawlDelay,
+		acceptLanguage: acceptLanguageFor(host),
+	}
+	h.robotsAgent = robotsAgentFor(host, h.userAgent)
+	if Config.Fetcher.HostLinkCacheSize > 0 {
+		var err error
+		h.hostLinks, err = lru.New(Config.Fetcher.HostLinkCacheSize)
+		if err != nil {
+			panic(err) // Only happens for a size <= 0
+		}
+	}
+	f.hostCrawl = h
+	h.audit.Start = time.Now()
+
+	if f.checkForBlacklisting(host) {
+		return h
 	}
 
 	// Set up robots map
-	log4go.Info("Crawling host: %v with crawl delay %v", f.host, f.crawldelay)
-	f.initializeRobotsMap(f.host)
-
-	// Loop through the links
-	for link := range f.fm.Datastore.LinksForHost(f.host) {
-		select {
-		case <-f.quit:
-			// Let the defer unclaim the host and the caller indicate that this
-			// goroutine is done
-			return false
-		default:
+	log4go.Info("Crawling host: %v with crawl delay %v", host, f.crawldelay)
+	f.initializeRobotsMap(host)
+
+	f.discoverFavicon(host)
+
+	h.start = time.Now()
+	h.links = f.fm.Datastore.LinksForHost(f.ctx, host)
+	return h
+}
+
+// crawlLink fetches the next link of h, and sets h.next to when h's crawl
+// delay allows fetching the one after it. It returns false instead if h has
+// no more links to crawl, it has been crawled for fetcher.max_time_per_host,
+// or the fetcher was signaled to quit.
+func (f *fetcher) crawlLink(h *hostCrawl) bool {
+	if h.links == nil || f.quitSignaled() {
+		return false
+	}
+	link, ok := <-h.links
+	if !ok {
+		return false
+	}
+	if f.fm.maxTimePerHost > 0 && time.Since(h.start) >= f.fm.maxTimePerHost {
+		h.leftover = append(h.leftover, link)
+		for l := range h.links {
+			h.leftover = append(h.leftover, l)
 		}
+		log4go.Warn("Abandoning %v after fetcher.max_time_per_host (%v) with %d links left in its segment",
+			h.host, f.fm.maxTimePerHost, len(h.leftover))
+		return false
+	}
 
-		robots := f.fetchRobots(link.Host)
+	f.hostCrawl = h
+	robots := f.fetchRobots(link.Host)
 
-		shouldDelay, crawlDelayClockStart := f.fetchAndHandle(link, robots)
-		if shouldDelay {
-			// fetchTime is the last server GET (not counting robots.txt GET's). So
-			// delta represents the amount of the CrawlDelay that still needs to be
-			// waited
-			delta := robots.CrawlDelay - time.Now().Sub(crawlDelayClockStart)
-			if delta > 0 {
-				time.Sleep(delta)
-			}
+	shouldDelay, crawlDelayClockStart := f.fetchAndHandle(link, robots)
+	h.next = time.Time{}
+	if shouldDelay && f.fm.Replay == nil {
+		// fetchTime is the last server GET (not counting robots.txt GET's). So
+		// h.next is when the CrawlDelay will have passed
+		h.next = crawlDelayClockStart.Add(robots.CrawlDelay)
+		if robots.CrawlDelay > h.audit.CrawlDelay {
+			h.audit.CrawlDelay = robots.CrawlDelay
 		}
 	}
 	return true
 }
 
+// finishHost unclaims h, once the handler has acked (or nacked) its responses
+// or fetcher.handler_ack_timeout has passed
+func (f *fetcher) finishHost(h *hostCrawl) {
+	if h.duplicateLinks > 0 {
+		log4go.Info("Suppressed %d duplicate parsed links while crawling %v", h.duplicateLinks, h.host)
+	}
+	acked, _ := h.pending.wait()
+	timer := time.NewTimer(f.fm.handlerAckTimeout)
+	select {
+	case <-acked:
+	case <-timer.C:
+		// Acks arriving later still store their fetch results
+		_, n := h.pending.wait()
+		log4go.Warn("Unclaiming %v with %d responses the handler has not acked after %v",
+			h.host, n, f.fm.handlerAckTimeout)
+	}
+	timer.Stop()
+	if pds, ok := f.fm.Datastore.(PolitenessDatastore); ok && h.audit.Requests > 0 && f.fm.Replay == nil {
+		h.audit.Host = h.host
+		h.audit.Duration = time.Since(h.audit.Start)
+		pds.StorePolitenessAudit(context.WithoutCancel(f.ctx), &h.audit)
+	}
+	// Unclaim even if our context has been cancelled, otherwise the host
+	// stays claimed until the dispatcher cleans up after us.
+	if rds, ok := f.fm.Datastore.(RequeueDatastore); ok && len(h.leftover) > 0 {
+		log4go.Info("Stopped crawling %v, requeueing %d links", h.host, len(h.leftover))
+		rds.RequeueHost(context.WithoutCancel(f.ctx), h.host, h.leftover)
+	} else {
+		log4go.Info("Finished crawling %v, unclaiming", h.host)
+		f.fm.Datastore.UnclaimHost(context.WithoutCancel(f.ctx), h.host)
+	}
+
+	if Config.Fetcher.HostCompleteWebhook != "" && !h.start.IsZero() && f.fm.Replay == nil {
+		summary := &HostSummary{
+			Host:     h.host,
+			Start:    h.start,
+			Duration: time.Since(h.start).Seconds(),
+			Fetched:  h.fetched,
+			Errors:   h.fetchErrors,
+			NewLinks: h.storedLinks,
+		}
+		// Post in the background so a slow webhook doesn't hold up the
+		// fetcher; Start waits for it before returning
+		f.fm.activeThreadsWait.Add(1)
+		go func() {
+			postHostSummary(summary)
+			f.fm.activeThreadsWait.Done()
+		}()
+	}
+}
+
 // fetchAndHandle takes care of fetching and processing a URL beginning to end.
 // Returns true if it did actually perform a fetch (even if it wasn't
 // successful), indicating that crawl-delay should be observed. Returns, also,
 // the time we start the clock for a return visit to the server.
-func (f *fetcher) fetchAndHandle(link *URL, robots *robotstxt.Group) (bool, time.Time) {
-	fr := &FetchResults{URL: link, FetchTime: NotYetCrawled}
+func (f *fetcher) fetchAndHandle(link *URL, robots *RobotsGroup) (bool, time.Time) {
+	fr := &FetchResults{URL: link, FetchTime: NotYetCrawled, CrawlIdentity: Config.Fetcher.CrawlIdentity,
+		RobotsAgent: f.robotsAgent, RobotsGroupAgent: robots.Agent}
+
+	// Everything done for this link (handler and datastore calls included)
+	// is traced as part of its span
+	ctx := f.ctx
+	var span trace.Span
+	f.ctx, span = Tracer().Start(ctx, "walker.Fetch", trace.WithAttributes(
+		AttrURL.String(link.String()), AttrDomain.String(f.host)))
+	defer func() {
+		f.ctx = ctx
+		endFetchSpan(span, fr)
+		if !fr.FetchTime.Equal(NotYetCrawled) && ctx.Err() == nil {
+			f.fetched++
+			if fr.FetchError != nil || (fr.Response != nil && fr.Response.StatusCode >= 400) {
+				f.fetchErrors++
+			}
+		}
+	}()
 
-	if !robots.Test(link.RequestURI()) {
-		log4go.Debug("Not fetching due to robots rules: %v", link)
+	if rule := robots.Match(link.RequestURI()); rule != nil && !rule.Allow {
+		log4go.Debug("Not fetching due to robots rule %q: %v", rule, link)
 		fr.ExcludedByRobots = true
-		f.fm.Datastore.StoreURLFetchResults(fr)
+		fr.RobotsRule = rule.String()
+		if !robots.RetryTime.IsZero() {
+			fr.RobotsUnavailable = true
+			fr.RobotsRetryTime = robots.RetryTime
+		}
+		f.fm.storeURLFetchResults(f.ctx, fr)
 		return false, time.Now()
 	}
 
 	fr.FetchTime = time.Now()
-	fr.Response, fr.RedirectedFrom, fr.FetchError = f.fetch(link)
+	if f.headPrecheck() {
+		if head := f.precheck(link); head != nil {
+			fr.Response = head
+			fr.SkippedByPrecheck = true
+			fr.MimeType = getMimeType(head)
+			fr.ContentLanguage = head.Header.Get("Content-Language")
+			fr.TLS = newTLSInfo(head.TLS)
+			f.fm.storeURLFetchResults(f.ctx, fr)
+			return true, time.Now()
+		}
+	}
+
+	tracer := &fetchTracer{timing: &fr.Timing}
+	fr.Response, fr.RedirectedFrom, fr.RedirectStatuses, fr.FetchError = f.fetch(link, nil, tracer)
+	f.observeConn(fr.FetchTime, tracer)
+	fr.setRedirectLoop()
 	if fr.FetchError != nil {
+		if f.ctx.Err() != nil {
+			// The fetch was aborted because we are shutting down, not because
+			// of anything wrong with the link; don't record it as an error
+			log4go.Debug("Abandoning fetch of %v: %v", link, f.ctx.Err())
+			return false, time.Now()
+		}
 		log4go.Debug("Error fetching %v: %v", link, fr.FetchError)
-		f.fm.Datastore.StoreURLFetchResults(fr)
+		f.fm.storeURLFetchResults(f.ctx, fr)
 		return true, time.Now()
 	}
 	log4go.Debug("Fetched %v -- %v", link, fr.Response.Status)
+	fr.TLS = newTLSInfo(fr.Response.TLS)
 
 	if fr.Response.StatusCode == http.StatusNotModified {
 		log4go.Fine("Received 304 when fetching %v", link)
-		f.fm.Datastore.StoreURLFetchResults(fr)
+		fr.NotModified = true
 
 		// There are some logical problems with this handler call.  For
 		// example, the page we're fetching could have been rejected by the
@@ -554,18 +1674,36 @@ func (f *fetcher) fetchAndHandle(link *URL, robots *robotstxt.Group) (bool, time
 		// !f.isHandleable(fr.Response). BUT, then stored when we go back with
 		// a 304. By definition a 304 is never MetaNoIndex, and f.isHandleable
 		// always returns false. May need to address in the future.
-		f.fm.Handler.HandleResponse(fr)
+		body, err := ioutil.ReadAll(io.LimitReader(fr.Response.Body, int64(Config.Fetcher.MaxHTTPContentSizeBytes)))
+		fr.Response.Body.Close()
+		if err != nil {
+			log4go.Debug("Error reading body of 304 from %v: %v", link, err)
+		}
+		f.handleResponse(fr, body)
 
 		return true, time.Now()
 	}
 
+	if f.streams(fr) {
+		return true, f.streamAndHandle(fr, tracer)
+	}
+
 	//
 	// Nab the body of the request, and compute fingerprint
 	//
-	fr.FetchError = f.fillReadBuffer(fr.Response.Body, fr.Response.Header)
+	fr.Truncated, fr.FetchError = f.fillReadBuffer(fr.Response, f.rangeRequest())
+	tracer.finish(int64(f.readBuffer.Len()))
 	if fr.FetchError != nil {
 		log4go.Debug("Error reading body of %v: %v", link, fr.FetchError)
-		f.fm.Datastore.StoreURLFetchResults(fr)
+		f.fm.storeURLFetchResults(f.ctx, fr)
+		return true, time.Now()
+	}
+	f.sniffContentType(fr)
+
+	if Config.Fetcher.MetaRefreshAsRedirect && !f.followMetaRefreshes(fr) {
+		fr.setRedirectLoop()
+		log4go.Debug("Error following meta refresh from %v: %v", link, fr.FetchError)
+		f.fm.storeURLFetchResults(f.ctx, fr)
 		return true, time.Now()
 	}
 
@@ -574,19 +1712,16 @@ func (f *fetcher) fetchAndHandle(link *URL, robots *robotstxt.Group) (bool, time
 	crawlDelayClockStart := time.Now()
 
 	fr.MimeType = getMimeType(fr.Response)
+	fr.ContentLanguage = fr.Response.Header.Get("Content-Language")
 
 	// Replace the response body so the handler can read it.
 	fr.Response.Body = ioutil.NopCloser(bytes.NewReader(f.readBuffer.Bytes()))
-	if Config.Cassandra.StoreResponseBody {
-		fr.Body = string(f.readBuffer.Bytes())
-	}
+	fr.NoArchive, fr.NoSnippet = xRobotsTagDirectives(fr.Response.Header, f.userAgent)
 
 	//
-	// Get the fingerprint
+	// Get the fingerprints
 	//
-	fnv := fnv.New64()
-	fnv.Write(f.readBuffer.Bytes())
-	fr.FnvFingerprint = int64(fnv.Sum64())
+	fingerprintBody(fr, f.readBuffer.Bytes())
 
 	//
 	// Handle html and generic handlers
@@ -594,97 +1729,641 @@ func (f *fetcher) fetchAndHandle(link *URL, robots *robotstxt.Group) (bool, time
 	if isHTML(fr.Response) {
 		log4go.Fine("Reading and parsing as HTML (%v)", link)
 		f.parseLinks(f.readBuffer.Bytes(), fr)
+		if Config.Fetcher.ExtractStructuredData {
+			fr.StructuredData = ExtractStructuredData(f.readBuffer.Bytes(), fr.Response.Header.Get("Content-Type"))
+		}
+	}
+
+	if Config.Cassandra.StoreResponseBody && !(Config.Fetcher.HonorNoarchive && fr.NoArchive) {
+		fr.Body = string(f.readBuffer.Bytes())
 	}
 
 	if !(Config.Fetcher.HonorMetaNoindex && fr.MetaNoIndex) && f.isHandleable(fr.Response) {
-		f.fm.Handler.HandleResponse(fr)
+		// The fetch results are stored once the handler acks them
+		f.handleResponse(fr, f.readBuffer.Bytes())
+		return true, crawlDelayClockStart
 	}
 
 	//TODO: Wrap the reader and check for read error here
 	log4go.Fine("Storing fetch results for %v", link)
-	f.fm.Datastore.StoreURLFetchResults(fr)
+	f.fm.storeURLFetchResults(f.ctx, fr)
 	return true, crawlDelayClockStart
 }
 
+// streams returns true if the body of fr should be streamed to the
+// StreamingHandler as it is read, rather than buffered first. Bodies are
+// buffered when something needs to look at them before deciding whether (or
+// as what) the response is handled.
+func (f *fetcher) streams(fr *FetchResults) bool {
+	res := fr.Response
+	if f.fm.streamer == nil || !f.acceptedContentType(res.Header) {
+		return false
+	}
+	if isHTML(res) && (Config.Fetcher.HonorMetaNoindex || Config.Fetcher.MetaRefreshAsRedirect) {
+		return false
+	}
+	if Config.Fetcher.SniffContentType && sniffableContentType(res.Header) {
+		return false
+	}
+	// Leave bodies known to be too large to fillReadBuffer, which rejects
+	// them without reading them
+	return res.ContentLength <= Config.Fetcher.MaxHTTPContentSizeBytes || f.rangeRequest()
+}
+
+// streamAndHandle hands the body of fr to the StreamingHandler as it is read
+// from the server, then does the rest of what fetchAndHandle does for
+// buffered bodies: the body is teed into the fingerprints, and into the read
+// buffer only if it is HTML (for link parsing) or is going to be stored.
+// Returns the time to start the Crawl-Delay clock from.
+func (f *fetcher) streamAndHandle(fr *FetchResults, tracer *fetchTracer) time.Time {
+	res := fr.Response
+	html := isHTML(res)
+	fr.MimeType = getMimeType(res)
+	fr.ContentLanguage = res.Header.Get("Content-Language")
+	fr.NoArchive, fr.NoSnippet = xRobotsTagDirectives(res.Header, f.userAgent)
+
+	f.readBuffer.Reset()
+	body := newBodyStream(res.Body, f.rangeRequest())
+	if html || Config.Cassandra.StoreResponseBody {
+		body.tee(&f.readBuffer)
+	}
+	if res.StatusCode == http.StatusPartialContent {
+		body.truncated = partialContent(res.Header.Get("Content-Range"))
+	}
+	res.Body = ioutil.NopCloser(body)
+
+	h := f.hostCrawl
+	h.pending.add()
+	ack := &responseAck{fm: f.fm, ctx: f.ctx, res: fr, pending: &h.pending}
+	err := f.tryHandleStream(fr, body)
+
+	// Whatever the handler didn't read still has to be fingerprinted
+	_, readErr := io.Copy(ioutil.Discard, body)
+	tracer.finish(body.n)
+	crawlDelayClockStart := time.Now()
+
+	fr.Truncated = body.truncated
+	body.fingerprint(fr)
+	if readErr != nil {
+		log4go.Debug("Error reading body of %v: %v", fr.URL, readErr)
+		fr.FetchError = readErr
+	} else if html {
+		log4go.Fine("Parsing streamed body as HTML (%v)", fr.URL)
+		f.parseLinks(f.readBuffer.Bytes(), fr)
+		if Config.Fetcher.ExtractStructuredData {
+			fr.StructuredData = ExtractStructuredData(f.readBuffer.Bytes(), fr.Response.Header.Get("Content-Type"))
+		}
+	}
+	if readErr == nil && Config.Cassandra.StoreResponseBody && !(Config.Fetcher.HonorNoarchive && fr.NoArchive) {
+		fr.Body = string(f.readBuffer.Bytes())
+	}
+
+	if err != nil {
+		ack.Nack(err)
+	} else {
+		ack.Ack()
+	}
+	return crawlDelayClockStart
+}
+
+// tryHandleStream makes a single call to the StreamingHandler, turning a
+// panic into an error
+func (f *fetcher) tryHandleStream(fr *FetchResults, body io.Reader) (err error) {
+	ctx := f.ctx
+	var span trace.Span
+	ctx, span = Tracer().Start(ctx, "walker.HandleStream", trace.WithAttributes(
+		AttrURL.String(fr.URL.String())))
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+		EndSpan(span, err)
+	}()
+	return f.fm.streamer.HandleStream(ctx, fr, body)
+}
+
+// followMetaRefreshes treats a zero-delay <meta http-equiv="refresh"> in the
+// page currently in the read buffer as a redirect: the target is fetched in
+// place of the page and appended to fr.RedirectedFrom (along with any HTTP
+// redirects it triggers). Repeats for refresh chains, which count toward
+// fetcher.max_redirects like HTTP redirects. Returns false, with
+// fr.FetchError set, if a refresh target could not be fetched or the chain
+// looped.
+func (f *fetcher) followMetaRefreshes(fr *FetchResults) bool {
+	for isHTML(fr.Response) {
+		p := &HTMLParser{}
+		p.Parse(f.readBuffer.Bytes())
+		if p.MetaRefresh == nil || p.MetaRefreshDelay != 0 {
+			return true
+		}
+
+		current := fr.URL
+		if len(fr.RedirectedFrom) > 0 {
+			current = fr.RedirectedFrom[len(fr.RedirectedFrom)-1]
+		}
+		target := p.MetaRefresh
+		target.MakeAbsolute(current)
+		target.LastCrawled = NotYetCrawled
+		if target.EqualCanonical(current) {
+			// Pages that refresh themselves are just reloading, not redirecting
+			return true
+		}
+		if !f.shouldStoreParsedLink(target) {
+			log4go.Fine("Not following excluded meta refresh from %v to %v", current, target)
+			return true
+		}
+
+		chain := append([]*URL{fr.URL}, fr.RedirectedFrom...)
+		if err := checkRedirect(chain, target); err != nil {
+			fr.FetchError = err
+			return false
+		}
+		log4go.Fine("Following meta refresh from %v to %v", current, target)
+		res, redirectedFrom, statuses, err := f.fetch(target, chain, nil)
+		fr.RedirectedFrom = append(fr.RedirectedFrom, target)
+		fr.RedirectedFrom = append(fr.RedirectedFrom, redirectedFrom...)
+		fr.RedirectStatuses = append(fr.RedirectStatuses, 0)
+		fr.RedirectStatuses = append(fr.RedirectStatuses, statuses...)
+		if err != nil {
+			fr.FetchError = err
+			return false
+		}
+		fr.Response = res
+		fr.DeclaredContentType = ""
+		fr.Truncated, fr.FetchError = f.fillReadBuffer(res, f.rangeRequest())
+		if fr.FetchError != nil {
+			return false
+		}
+		f.sniffContentType(fr)
+	}
+	return true
+}
+
+// handleResponse passes fr to the handler, retrying if the handler panics or
+// returns an error, and stores fr once the handler acks it. If the handler
+// nacks fr or every attempt fails, fr is sent to the dead-letter sink before
+// it is stored. Each attempt gets a fresh copy of fr.Response (see
+// freshResponse) whose body reads body.
+func (f *fetcher) handleResponse(fr *FetchResults, body []byte) {
+	ctx := f.ctx
+	var span trace.Span
+	f.ctx, span = Tracer().Start(ctx, "walker.HandleResponse", trace.WithAttributes(
+		AttrURL.String(fr.URL.String())))
+	var err error
+	defer func() {
+		f.ctx = ctx
+		EndSpan(span, err)
+	}()
+
+	h := f.hostCrawl
+	h.pending.add()
+	ack := &responseAck{fm: f.fm, ctx: ctx, res: fr, pending: &h.pending}
+
+	res := fr.Response
+	for attempt := 0; attempt <= Config.Fetcher.HandlerRetries; attempt++ {
+		if attempt > 0 {
+			log4go.Debug("Retrying handler for %v (attempt %d): %v", fr.URL, attempt+1, err)
+			if !f.sleep(f.fm.handlerRetryDelay) {
+				break
+			}
+		}
+		fr.Response = freshResponse(res, body)
+		err = f.tryHandleResponse(fr, ack)
+		if err == nil {
+			return
+		}
+		if ack.finished() {
+			log4go.Debug("Not retrying handler for %v, which was already acked: %v", fr.URL, err)
+			return
+		}
+	}
+	ack.Nack(err)
+}
+
+// freshResponse returns a copy of res for a handler attempt, so nothing a
+// previous attempt consumed or changed carries over: it has its own header, a
+// body reading body, and, if the request body can be re-created (see
+// http.Request.GetBody), its own copy of the request.
+func freshResponse(res *http.Response, body []byte) *http.Response {
+	c := *res
+	c.Header = res.Header.Clone()
+	c.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if req := res.Request; req != nil && req.GetBody != nil {
+		if reqBody, err := req.GetBody(); err == nil {
+			c.Request = req.Clone(req.Context())
+			c.Request.Body = reqBody
+		}
+	}
+	return &c
+}
+
+// tryHandleResponse makes a single call to the handler, turning a panic into
+// an error
+func (f *fetcher) tryHandleResponse(fr *FetchResults, ack Ack) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return f.fm.handler.HandleResponse(f.ctx, fr, ack)
+}
+
+// storeURLFetchResults stores fr in the Datastore, timing the call for the
+// autoscaler
+func (fm *FetchManager) storeURLFetchResults(ctx context.Context, fr *FetchResults) {
+	start := time.Now()
+	fm.Datastore.StoreURLFetchResults(ctx, fr)
+	fm.autoscaler.observe(time.Since(start))
+}
+
+// HandlerFailed records that the Handler could not handle res, sending it to
+// the dead-letter sink (see FetchManager.DeadLetters). The FetchManager calls
+// this itself once its retries are exhausted or an AckHandler nacks a
+// response; Handlers that finish their work asynchronously can call it to
+// report failures after HandleResponse has returned.
+func (fm *FetchManager) HandlerFailed(res *FetchResults, err error) {
+	log4go.Error("Handler failed for %v: %v", res.URL, err)
+	if fm.DeadLetters == nil {
+		return
+	}
+	ctx := fm.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// Record the failure even if we are shutting down
+	fm.DeadLetters.DeadLetter(context.WithoutCancel(ctx), res, err)
+}
+
 //
-// fillReadBuffer will fill up readBuffer with the contents of reader. Any
+// fillReadBuffer will fill up readBuffer with the body of res. Any
 // problems with the read will be returned in an error; including (and
-// importantly) if the content size would exceed MaxHTTPContentSizeBytes.
+// importantly) if the content size would exceed MaxHTTPContentSizeBytes,
+// unless truncate is true. In that case the body is cut off at
+// MaxHTTPContentSizeBytes instead, and fillReadBuffer returns true if it (or
+// the server, answering a Range request) left out part of the content.
 //
-func (f *fetcher) fillReadBuffer(reader io.Reader, headers http.Header) error {
+func (f *fetcher) fillReadBuffer(res *http.Response, truncate bool) (bool, error) {
 	f.readBuffer.Reset()
-	lenArr, lenOk := headers["Content-Length"]
+	max := Config.Fetcher.MaxHTTPContentSizeBytes
+	truncated := false
+	lenArr, lenOk := res.Header["Content-Length"]
 	if lenOk && len(lenArr) > 0 {
 		var size int64
 		n, err := fmt.Sscanf(lenArr[0], "%d", &size)
 		if n != 1 || err != nil || size < 0 {
 			log4go.Error("Failed to process Content-Length: %v", err)
-		} else if size > Config.Fetcher.MaxHTTPContentSizeBytes {
-			return fmt.Errorf("Content size exceeded MaxHTTPContentSizeBytes")
-		} else {
+		} else if size > max && !truncate {
+			return false, fmt.Errorf("Content size exceeded MaxHTTPContentSizeBytes")
+		} else if size <= max {
 			f.readBuffer.Grow(int(size))
 		}
 	}
+	if res.StatusCode == http.StatusPartialContent {
+		truncated = partialContent(res.Header.Get("Content-Range"))
+	}
 
-	limitReader := io.LimitReader(reader, Config.Fetcher.MaxHTTPContentSizeBytes+1)
+	limitReader := io.LimitReader(res.Body, max+1)
 	n, err := f.readBuffer.ReadFrom(limitReader)
 	if err != nil {
-		return err
-	} else if n > Config.Fetcher.MaxHTTPContentSizeBytes {
-		return fmt.Errorf("Content size exceeded MaxHTTPContentSizeBytes")
+		return false, err
+	} else if n > max {
+		if !truncate {
+			return false, fmt.Errorf("Content size exceeded MaxHTTPContentSizeBytes")
+		}
+		f.readBuffer.Truncate(int(max))
+		truncated = true
 	}
 
-	return nil
+	return truncated, nil
+}
+
+// bodyStream reads a response body for a StreamingHandler, applying the same
+// size limit as fillReadBuffer and teeing what is read into the body's
+// fingerprints (and the read buffer, if set with tee)
+type bodyStream struct {
+	r         io.Reader
+	truncate  bool
+	max       int64
+	n         int64
+	truncated bool
+	err       error
+	fnv       hash.Hash64
+	sha256    hash.Hash
+	w         io.Writer
+}
+
+func newBodyStream(body io.Reader, truncate bool) *bodyStream {
+	max := Config.Fetcher.MaxHTTPContentSizeBytes
+	s := &bodyStream{
+		// Read one byte past the limit to tell if the body exceeds it
+		r:        io.LimitReader(body, max+1),
+		truncate: truncate,
+		max:      max,
+		fnv:      fnv.New64(),
+	}
+	s.w = s.fnv
+	if fingerprintEnabled(FingerprintSHA256) {
+		s.sha256 = sha256.New()
+		s.w = io.MultiWriter(s.w, s.sha256)
+	}
+	return s
+}
+
+// tee also writes everything read to w
+func (s *bodyStream) tee(w io.Writer) {
+	s.w = io.MultiWriter(s.w, w)
+}
+
+func (s *bodyStream) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.r.Read(p)
+	if s.n+int64(n) > s.max {
+		n = int(s.max - s.n)
+		if s.truncate {
+			s.truncated = true
+			err = io.EOF
+		} else {
+			err = fmt.Errorf("Content size exceeded MaxHTTPContentSizeBytes")
+		}
+	}
+	s.n += int64(n)
+	s.w.Write(p[:n])
+	s.err = err
+	return n, err
+}
+
+// fingerprint fills in the fingerprints of the body read so far
+func (s *bodyStream) fingerprint(fr *FetchResults) {
+	fr.FnvFingerprint = int64(s.fnv.Sum64())
+	if s.sha256 != nil {
+		fr.SHA256Fingerprint = s.sha256.Sum(nil)
+	}
+}
+
+// partialContent returns true if the Content-Range header of a 206 response
+// shows it left out part of the content
+func partialContent(contentRange string) bool {
+	var first, last int64
+	var total string
+	n, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &first, &last, &total)
+	if n != 3 || err != nil {
+		// Without a sensible Content-Range we can't tell; assume the worst
+		return true
+	}
+	if total == "*" {
+		return true
+	}
+	var size int64
+	if _, err := fmt.Sscanf(total, "%d", &size); err != nil {
+		return true
+	}
+	return first > 0 || last+1 < size
+}
+
+// bindEgress makes the fetcher connect from e's local address
+func (f *fetcher) bindEgress(e *egressTransports) {
+	log4go.Debug("Binding fetcher to local address %v", e.addr)
+	f.transport = e.transport
+	if e.transNoKeepAlive != nil {
+		f.transNoKeepAlive = e.transNoKeepAlive
+	}
+	f.httpclient.Transport = f.transport
 }
 
 func (f *fetcher) resetTransport() {
-	if f.fm.TransNoKeepAlive != nil {
-		f.httpclient.Transport = f.fm.TransNoKeepAlive
+	if f.transNoKeepAlive != nil {
+		f.httpclient.Transport = f.transNoKeepAlive
 	}
 }
 
 func (f *fetcher) setTransportFromCrawlDelay(crawlDelay time.Duration) {
-	if f.fm.TransNoKeepAlive != nil {
-		if crawlDelay > f.fm.KeepAliveThreshold {
-			f.httpclient.Transport = f.fm.TransNoKeepAlive
+	if f.transNoKeepAlive != nil {
+		keepAlive := crawlDelay <= f.fm.KeepAliveThreshold
+		if f.fm.autoKeepAlive {
+			keepAlive = f.keepAlive.keepAlive(f.fm.KeepAliveThreshold, crawlDelay)
+		}
+		if keepAlive {
+			f.httpclient.Transport = f.transport
 		} else {
-			f.httpclient.Transport = f.fm.Transport
+			f.httpclient.Transport = f.transNoKeepAlive
 		}
 	}
 }
 
+// observeConn records the connection a page fetch started at start got (see
+// keepAliveTuner)
+func (f *fetcher) observeConn(start time.Time, tracer *fetchTracer) {
+	if f.transNoKeepAlive == nil {
+		return
+	}
+	keptAlive := f.httpclient.Transport != f.transNoKeepAlive
+	if keptAlive {
+		atomic.AddInt64(&connStats.keepAliveFetches, 1)
+	} else {
+		atomic.AddInt64(&connStats.noKeepAliveFetches, 1)
+	}
+	if f.fm.autoKeepAlive {
+		tracer.mu.Lock()
+		reused := tracer.reused
+		tracer.mu.Unlock()
+		f.keepAlive.observe(start, keptAlive, reused)
+	}
+}
+
 // initializeRobotsMap inits the robotsMap system
 func (f *fetcher) initializeRobotsMap(host string) {
 
 	// Set default robots
-	rdata, _ := robotstxt.FromBytes([]byte("User-agent: *\n"))
-	f.defRobots = rdata.FindGroup(Config.Fetcher.UserAgent)
-	f.defRobots.CrawlDelay = f.fm.defCrawlDelay
+	f.defRobots = &RobotsGroup{CrawlDelay: f.hostCrawlDelay()}
 
 	// try read $host/robots.txt. Failure to GET, will just returns
 	// f.defRobots before call
 	f.resetTransport()
-	f.robotsMap = map[string]*robotstxt.Group{}
-	f.defRobots = f.getRobots(host)
-	f.robotsMap[host] = f.defRobots
+	f.robotsMap = map[string]*RobotsGroup{}
+	robots, fp, known := f.getRobots(host)
+	if robots.RetryTime.IsZero() {
+		// An unavailable robots.txt only disallows its own host
+		f.defRobots = robots
+	}
+	f.robotsMap[robotsKey(host, f.robotsAgent)] = robots
 	f.setTransportFromCrawlDelay(f.defRobots.CrawlDelay)
+
+	if rds, ok := f.fm.Datastore.(RobotsDatastore); ok && known {
+		rds.StoreRobotsFingerprint(f.ctx, host, fp)
+	}
+}
+
+// hostSettings returns the settings host overrides, if the datastore is a
+// HostSettingsDatastore, and otherwise empty HostSettings
+func (f *fetcher) hostSettings(host string) *HostSettings {
+	if hds, ok := f.fm.Datastore.(HostSettingsDatastore); ok {
+		if settings := hds.HostSettings(f.ctx, host); settings != nil {
+			return settings
+		}
+	}
+	return &HostSettings{}
+}
+
+// hostCrawlDelay returns the crawl delay of the current host if its
+// robots.txt doesn't give one: its HostSettings override, or
+// fetcher.default_crawl_delay
+func (f *fetcher) hostCrawlDelay() time.Duration {
+	if f.crawlDelay > 0 {
+		return f.crawlDelay
+	}
+	return f.defCrawlDelay
+}
+
+// userAgentFor returns the User-Agent to crawl a host with the given
+// settings: its override if it has one, otherwise one of fetcher.user_agents
+// picked according to fetcher.user_agent_rotation, or fetcher.user_agent if
+// that list is empty
+func (fm *FetchManager) userAgentFor(settings *HostSettings) string {
+	if settings.UserAgent != "" {
+		return settings.UserAgent
+	}
+
+	agents := Config.Fetcher.UserAgents
+	if len(agents) == 0 {
+		return Config.Fetcher.UserAgent
+	}
+	if strings.ToLower(Config.Fetcher.UserAgentRotation) == "random" {
+		return agents[rand.Intn(len(agents))]
+	}
+	n := atomic.AddUint64(&fm.userAgentsUsed, 1) - 1
+	return agents[n%uint64(len(agents))]
+}
+
+// acceptLanguageFor returns the Accept-Language to crawl host with: its
+// fetcher.domain_accept_languages override if it has one, otherwise
+// fetcher.accept_language
+func acceptLanguageFor(host string) string {
+	for dom, lang := range Config.Fetcher.DomainAcceptLanguages {
+		if strings.EqualFold(dom, host) {
+			return lang
+		}
+	}
+	return Config.Fetcher.AcceptLanguage
+}
+
+// robotsAgentFor returns the user agent whose robots.txt group to obey on
+// host: its fetcher.domain_robots_agents entry, or else userAgent, the one it
+// is crawled with
+func robotsAgentFor(host, userAgent string) string {
+	for dom, agent := range Config.Fetcher.DomainRobotsAgents {
+		if strings.EqualFold(dom, host) {
+			return agent
+		}
+	}
+	return userAgent
+}
+
+// robotsKey returns the robotsMap key of the robots.txt group of host obeyed
+// by agent, so a group is never reused for a different agent
+func robotsKey(host, agent string) string {
+	return host + "\x00" + agent
 }
 
 // fetchRobots is a caching version of getRobots
-func (f *fetcher) fetchRobots(host string) *robotstxt.Group {
-	rob, robOk := f.robotsMap[host]
+func (f *fetcher) fetchRobots(host string) *RobotsGroup {
+	key := robotsKey(host, f.robotsAgent)
+	rob, robOk := f.robotsMap[key]
+	if robOk && !rob.RetryTime.IsZero() && time.Now().After(rob.RetryTime) {
+		// Try the host's robots.txt again
+		robOk = false
+	}
 	if !robOk {
 		f.resetTransport()
-		rob = f.getRobots(host)
-		f.robotsMap[host] = rob
+		rob, _, _ = f.getRobots(host)
+		f.robotsMap[key] = rob
 	}
 	f.setTransportFromCrawlDelay(rob.CrawlDelay)
 	return rob
 }
 
-// getRobots will return the robotstxt.Group for the given host, or the
-// default robotstxt.Group if the host doesn't support robots.txt
-func (f *fetcher) getRobots(host string) *robotstxt.Group {
+// robotsMaxRetryAfter caps how long a Retry-After header on a robots.txt
+// response keeps a host from being crawled
+const robotsMaxRetryAfter = 24 * time.Hour
+
+// unavailableRobots returns a RobotsGroup disallowing everything until the
+// given time
+func (f *fetcher) unavailableRobots(until time.Time) *RobotsGroup {
+	return &RobotsGroup{
+		Rules:      []RobotsRule{{Allow: false, Path: "/"}},
+		CrawlDelay: f.hostCrawlDelay(),
+		RetryTime:  until,
+	}
+}
+
+// robotsRetryTime returns when host may be crawled again if its robots.txt
+// recently answered with a 5XX or 429 status, or the zero time
+func (fm *FetchManager) robotsRetryTime(host string) time.Time {
+	fm.robotsUnavailableMu.Lock()
+	defer fm.robotsUnavailableMu.Unlock()
+	until, ok := fm.robotsUnavailable[host]
+	if ok && time.Now().After(until) {
+		delete(fm.robotsUnavailable, host)
+		return time.Time{}
+	}
+	return until
+}
+
+// markRobotsUnavailable records that the robots.txt of host answered with a
+// 5XX or 429 status and the given Retry-After header, returning when the host
+// may be crawled again: after fetcher.robots_unavailable_period, or as long as
+// the header asks (up to robotsMaxRetryAfter)
+func (fm *FetchManager) markRobotsUnavailable(host string, retryAfter string) time.Time {
+	wait := fm.robotsUnavailablePeriod
+	if d, ok := parseRetryAfter(retryAfter, time.Now()); ok {
+		wait = d
+		if wait > robotsMaxRetryAfter {
+			wait = robotsMaxRetryAfter
+		}
+	}
+	until := time.Now().Add(wait)
+
+	fm.robotsUnavailableMu.Lock()
+	defer fm.robotsUnavailableMu.Unlock()
+	if fm.robotsUnavailable == nil {
+		fm.robotsUnavailable = map[string]time.Time{}
+	}
+	fm.robotsUnavailable[host] = until
+	return until
+}
+
+// parseRetryAfter parses a Retry-After header, either a number of seconds or
+// an HTTP date, into how long to wait from now. It returns false if the header
+// is empty or invalid.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if t.Before(now) {
+		return 0, true
+	}
+	return t.Sub(now), true
+}
+
+// getRobots will return the RobotsGroup for the given host, or the default
+// RobotsGroup if the host doesn't support robots.txt. It also returns the fnv
+// fingerprint of the host's robots.txt (0 if it has none), and whether that
+// is known; it isn't if the request failed or got a 5XX status. If the
+// robots.txt answered with a 5XX or 429 status, or did so recently, the group
+// disallows everything for a while (see fetcher.robots_unavailable_period).
+func (f *fetcher) getRobots(host string) (*RobotsGroup, int64, bool) {
 
 	u := &URL{
 		URL: &url.URL{
@@ -695,57 +2374,430 @@ func (f *fetcher) getRobots(host string) *robotstxt.Group {
 		LastCrawled: NotYetCrawled, //explicitly set this so that fetcher.fetch won't send If-Modified-Since
 	}
 
-	res, _, err := f.fetch(u)
+	if until := f.fm.robotsRetryTime(host); !until.IsZero() {
+		return f.unavailableRobots(until), 0, false
+	}
+
+	res, _, _, err := f.fetch(u, nil, nil)
 	gotRobots := err == nil && res.StatusCode >= 200 && res.StatusCode < 300
 	if !gotRobots {
 		if err != nil {
 			log4go.Debug("Could not fetch %v, assuming there is no robots.txt (error: %v)", u, err)
+			return f.defRobots, 0, false
 		}
-		return f.defRobots
+		res.Body.Close()
+		unavailable := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+		if unavailable && f.fm.robotsUnavailablePeriod > 0 {
+			until := f.fm.markRobotsUnavailable(host, res.Header.Get("Retry-After"))
+			log4go.Info("%v answered %v, not crawling %v until %v", u, res.Status, host, until.Format(time.RFC3339))
+			return f.unavailableRobots(until), 0, false
+		}
+		return f.defRobots, 0, res.StatusCode < 500
 	}
 
-	robots, err := robotstxt.FromResponse(res)
+	body, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
+	if err != nil {
+		log4go.Debug("Error reading robots.txt (%v) assuming there is no robots.txt: %v", u, err)
+		return f.defRobots, 0, false
+	}
+	fp := fnvFingerprint(body)
+	robots, err := ParseRobots(bytes.NewReader(body))
 	if err != nil {
 		log4go.Debug("Error parsing robots.txt (%v) assuming there is no robots.txt: %v", u, err)
-		return f.defRobots
+		return f.defRobots, fp, true
 	}
 
-	grp := robots.FindGroup(Config.Fetcher.UserAgent)
-	max := f.fm.maxCrawlDelay
+	grp := robots.FindGroup(f.robotsAgent)
+	if !grp.HasCrawlDelay || grp.CrawlDelay < f.crawlDelay {
+		grp.CrawlDelay = f.hostCrawlDelay()
+	}
+	max := f.maxCrawlDelay
 	if grp.CrawlDelay > max {
 		grp.CrawlDelay = max
 	}
 
-	return grp
+	return grp, fp, true
 }
 
-func (f *fetcher) fetch(u *URL) (*http.Response, []*URL, error) {
-	req, err := http.NewRequest("GET", u.String(), nil)
+// fetch requests u, returning the response along with the URLs it was
+// redirected to and the status codes of the redirects. via holds the URLs
+// already requested for the same link, if u is the target of a meta refresh;
+// redirects are checked against them and u with checkRedirect, and a
+// *RedirectLoopError is returned if they loop or go on too long. If tracer is
+// non-nil it is used to record the timing of the request.
+func (f *fetcher) fetch(u *URL, via []*URL, tracer *fetchTracer) (*http.Response, []*URL, []int, error) {
+	ctx := f.ctx
+	if tracer != nil {
+		ctx = httptrace.WithClientTrace(ctx, tracer.clientTrace())
+	}
+	req, err := f.newRequest(ctx, "GET", u)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Failed to create new request object for %v): %v", u, err)
+		return nil, nil, nil, err
 	}
-
-	req.Header.Set("User-Agent", Config.Fetcher.UserAgent)
-	req.Header.Set("Accept", strings.Join(Config.Fetcher.AcceptFormats, ","))
 	if !u.LastCrawled.Equal(NotYetCrawled) {
 		// Date format used is RFC1123 as specified by
 		// http://www.w3.org/Protocols/rfc2616/rfc2616-sec3.html#sec3.3.1
 		req.Header.Set("If-Modified-Since", u.LastCrawled.Format(time.RFC1123))
 	}
-	log4go.Debug("Sending request: %+v", req)
+	ranged := f.rangeRequest()
+	if ranged {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", Config.Fetcher.MaxHTTPContentSizeBytes-1))
+	}
+	log4go.Debug("Sending request: %v", maskedRequest(req))
 
 	var redirectedFrom []*URL
-	f.httpclient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		redirectedFrom = append(redirectedFrom, &URL{URL: req.URL})
+	var statuses []int
+	f.httpclient.CheckRedirect = func(req *http.Request, _ []*http.Request) error {
+		next := &URL{URL: req.URL}
+		chain := append(append(via[:len(via):len(via)], u), redirectedFrom...)
+		if err := checkRedirect(chain, next); err != nil {
+			return err
+		}
+		redirectedFrom = append(redirectedFrom, next)
+		status := 0
+		if req.Response != nil {
+			status = req.Response.StatusCode
+		}
+		statuses = append(statuses, status)
 		return nil
 	}
 
+	res, err := f.do(req)
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			if loopErr, ok := ue.Err.(*RedirectLoopError); ok {
+				// Return it as is so the fetch isn't taken for a transient
+				// failure (*url.Error is a net.Error)
+				return nil, nil, nil, loopErr
+			}
+		}
+		return nil, nil, nil, err
+	}
+	if ranged && res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// Some servers answer this for empty content; ask again for all of it
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		req.Header.Del("Range")
+		redirectedFrom, statuses = nil, nil
+		res, err = f.do(req)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return res, redirectedFrom, statuses, nil
+}
+
+// RedirectLoopError is the FetchError of a fetch given up because its
+// redirects (including meta refreshes, see fetcher.meta_refresh_as_redirect)
+// looped back to a URL already requested or went on past
+// fetcher.max_redirects
+type RedirectLoopError struct {
+	// Every URL requested, starting with the link fetched and ending with the
+	// redirect that was not followed
+	Chain []*URL
+
+	// True if the last URL of Chain had already been requested, false if the
+	// chain was just too long
+	Loop bool
+}
+
+func (e *RedirectLoopError) Error() string {
+	if e.Loop {
+		return fmt.Sprintf("redirect loop back to %v after %d redirects", e.Chain[len(e.Chain)-1], len(e.Chain)-2)
+	}
+	return fmt.Sprintf("stopped after %d redirects", len(e.Chain)-2)
+}
+
+// checkRedirect returns a *RedirectLoopError if following a redirect to next,
+// after requesting the URLs in chain (the link first), would request a URL
+// again or exceed fetcher.max_redirects
+func checkRedirect(chain []*URL, next *URL) error {
+	full := append(chain[:len(chain):len(chain)], next)
+	for _, u := range chain {
+		if u.String() == next.String() {
+			return &RedirectLoopError{Chain: full, Loop: true}
+		}
+	}
+	if len(chain) > Config.Fetcher.MaxRedirects {
+		return &RedirectLoopError{Chain: full}
+	}
+	return nil
+}
+
+// setRedirectLoop sets fr.RedirectLoop and fr.RedirectChain if fr.FetchError
+// is a *RedirectLoopError. The redirects followed are dropped from
+// fr.RedirectedFrom, so the results are stored for fr.URL and not for
+// whichever URL of the loop came last.
+func (fr *FetchResults) setRedirectLoop() {
+	loopErr, ok := fr.FetchError.(*RedirectLoopError)
+	if !ok {
+		return
+	}
+	fr.RedirectLoop = true
+	fr.RedirectChain = loopErr.Chain
+	fr.RedirectedFrom = nil
+	fr.RedirectStatuses = nil
+	fr.Response = nil
+}
+
+// newRequest creates a request for u with the headers every fetch sends
+func (f *fetcher) newRequest(ctx context.Context, method string, u *URL) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new request object for %v): %v", u, err)
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", f.acceptHeader)
+	if f.acceptLanguage != "" {
+		req.Header.Set("Accept-Language", f.acceptLanguage)
+	}
+	if Config.Fetcher.SendCrawlIDHeader {
+		req.Header.Set("X-Walker-Crawl-ID", Config.Fetcher.CrawlIdentity)
+	}
+	if cred := f.fm.credentialFor(u.Hostname()); cred != nil {
+		cred.authorize(req)
+	}
+	return req, nil
+}
+
+// do sends req, answering a digest auth challenge if the host has digest
+// credentials configured (see fetcher.domain_credentials)
+func (f *fetcher) do(req *http.Request) (*http.Response, error) {
+	if err := injectFetchFault(req); err != nil {
+		return nil, err
+	}
 	res, err := f.httpclient.Do(req)
 	if err != nil {
-		return nil, nil, err
+		return res, err
+	}
+	f.audit(res)
+	injectTruncation(req, res)
+	if res.StatusCode != http.StatusUnauthorized {
+		return res, nil
+	}
+	cred := f.fm.credentialFor(req.URL.Hostname())
+	if cred == nil || cred.typ != "digest" {
+		return res, nil
 	}
-	return res, redirectedFrom, nil
+	retry := cred.authorizeDigest(req, res)
+	if retry == nil {
+		return res, nil
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	log4go.Debug("Answering digest challenge: %v", maskedRequest(retry))
+	res, err = f.httpclient.Do(retry)
+	if err == nil {
+		f.audit(res)
+	}
+	return res, err
+}
+
+// audit records res, a response from the host being crawled, in its
+// PolitenessAudit
+func (f *fetcher) audit(res *http.Response) {
+	if f.hostCrawl == nil {
+		return
+	}
+	hops := 0
+	for r := res.Request; r != nil && r.Response != nil; r = r.Response.Request {
+		hops++
+	}
+	f.hostCrawl.audit.record(res, hops)
+}
+
+// headPrecheck returns true if links of the current host should be checked
+// with a HEAD request before being fetched (see
+// fetcher.head_precheck_domains)
+func (f *fetcher) headPrecheck() bool {
+	for _, d := range Config.Fetcher.HeadPrecheckDomains {
+		if d == "*" || strings.EqualFold(d, f.host) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeRequest returns true if links of the current host should be fetched
+// with a Range header capping them at MaxHTTPContentSizeBytes, and cut off
+// there rather than failed if they are larger (see
+// fetcher.range_request_domains)
+func (f *fetcher) rangeRequest() bool {
+	for _, d := range Config.Fetcher.RangeRequestDomains {
+		if d == "*" || strings.EqualFold(d, f.host) {
+			return true
+		}
+	}
+	return false
+}
+
+// faviconDiscovery returns true if the favicon of the current host should be
+// fetched and recorded when it is claimed (see fetcher.favicon_domains)
+func (f *fetcher) faviconDiscovery() bool {
+	for _, d := range Config.Fetcher.FaviconDomains {
+		if d == "*" || strings.EqualFold(d, f.host) {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverFavicon fetches /favicon.ico of host, if it is listed in
+// fetcher.favicon_domains, and records what it got with the Datastore (which
+// must be an AssetDatastore). Like robots.txt, the request is made when the
+// host is claimed, without waiting out the crawl delay.
+func (f *fetcher) discoverFavicon(host string) {
+	ads, ok := f.fm.Datastore.(AssetDatastore)
+	if !ok || !f.faviconDiscovery() {
+		return
+	}
+	u := &URL{
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   host,
+			Path:   "/favicon.ico",
+		},
+		LastCrawled: NotYetCrawled,
+	}
+	if rule := f.fetchRobots(host).Match(u.RequestURI()); rule != nil && !rule.Allow {
+		log4go.Debug("Not fetching favicon due to robots rule %q: %v", rule, u)
+		return
+	}
+
+	assets := &DomainAssets{FaviconURL: u, FaviconTime: time.Now()}
+	res, redirectedFrom, _, err := f.fetch(u, nil, nil)
+	if err != nil {
+		log4go.Debug("Error fetching favicon %v: %v", u, err)
+		return
+	}
+	defer res.Body.Close()
+	if len(redirectedFrom) > 0 {
+		assets.FaviconURL = redirectedFrom[len(redirectedFrom)-1]
+	}
+	assets.FaviconStatus = res.StatusCode
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		if _, err := f.fillReadBuffer(res, false); err != nil {
+			log4go.Debug("Error reading favicon %v: %v", u, err)
+			return
+		}
+		assets.FaviconMimeType = getMimeType(res)
+		assets.FaviconFingerprint = fnvFingerprint(f.readBuffer.Bytes())
+	}
+	ads.StoreDomainAssets(f.ctx, host, assets)
+}
+
+// precheck issues a HEAD request for u and returns the response if it shows
+// u should not be fetched: its Content-Length exceeds
+// MaxHTTPContentSizeBytes or its Content-Type is not one of AcceptFormats.
+// Otherwise, including when the HEAD request fails or is not answered with
+// a 2XX status (plenty of servers don't support HEAD), it returns nil and u
+// should be fetched as usual.
+func (f *fetcher) precheck(u *URL) *http.Response {
+	req, err := f.newRequest(f.ctx, "HEAD", u)
+	if err != nil {
+		log4go.Debug("Not prechecking %v: %v", u, err)
+		return nil
+	}
+	// Stop at the first redirect: the target may be on another host, and the
+	// GET follows it with the fetcher's redirect checks anyway. A 3XX isn't
+	// a 2XX, so the link is fetched.
+	f.httpclient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	res, err := f.do(req)
+	if err != nil {
+		log4go.Debug("HEAD precheck of %v failed, fetching anyway: %v", u, err)
+		return nil
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil
+	}
+
+	if res.ContentLength > Config.Fetcher.MaxHTTPContentSizeBytes {
+		log4go.Debug("Skipping %v, HEAD precheck reported Content-Length %d", u, res.ContentLength)
+		return res
+	}
+	if len(res.Header["Content-Type"]) > 0 && !f.acceptedContentType(res.Header) &&
+		!(Config.Fetcher.SniffContentType && sniffableContentType(res.Header)) {
+		log4go.Debug("Skipping %v, HEAD precheck reported Content-Type %v", u, res.Header["Content-Type"])
+		return res
+	}
+	return nil
+}
+
+// fetchTracer fills in a FetchTiming from httptrace callbacks. Callbacks may be
+// made from the transport's goroutines, hence the mutex.
+type fetchTracer struct {
+	mu     sync.Mutex
+	timing *FetchTiming
+
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+
+	// whether the first request of the fetch (before any redirects) reused
+	// a kept-alive connection
+	gotConn bool
+	reused  bool
+}
+
+// since adds the time elapsed since start to d, if start has been set
+func (t *fetchTracer) since(d *time.Duration, start *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !start.IsZero() {
+		*d += time.Since(*start)
+	}
+}
+
+// mark sets the given time to now
+func (t *fetchTracer) mark(tm *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*tm = time.Now()
+}
+
+func (t *fetchTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			countConn(info.Reused)
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if !t.gotConn {
+				t.gotConn = true
+				t.reused = info.Reused
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.since(&t.timing.DNS, &t.dnsStart) },
+		ConnectStart: func(network, addr string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.since(&t.timing.Connect, &t.connectStart)
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.since(&t.timing.TLS, &t.tlsStart)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+			t.since(&t.timing.TTFB, &t.wroteRequest)
+		},
+	}
+}
+
+// finish records the transfer time and size once the body has been read
+func (t *fetchTracer) finish(bytes int64) {
+	t.since(&t.timing.Transfer, &t.firstByte)
+	t.mu.Lock()
+	t.timing.Bytes = bytes
+	t.mu.Unlock()
 }
 
 // parseLinks tries to parse the http response in the given FetchResults for
@@ -762,18 +2814,68 @@ func (f *fetcher) parseLinks(body []byte, fr *FetchResults) {
 		fr.MetaNoFollow = true
 		log4go.Fine("Page has nofollow meta tag: %v", fr.URL)
 	}
-
+	fr.NoArchive = fr.NoArchive || p.HasMetaNoArchive
+	fr.NoSnippet = fr.NoSnippet || p.HasMetaNoSnippet
+	fr.Title = p.Title
+	fr.Description = p.Description
+	if p.Aborted != "" {
+		fr.ParseAborted = true
+		log4go.Info("Not extracting links from %v: %v", fr.URL, p.Aborted)
+		return
+	}
+	for _, icon := range p.Icons {
+		icon.MakeAbsolute(fr.URL)
+		fr.Icons = append(fr.Icons, icon)
+	}
+
+	// Links are kept in page order, skipping repeats, up to
+	// fetcher.max_links_per_page, so the same links are kept every time a page
+	// is crawled
+	var links []*URL
+	seen := map[string]*URL{}
+	max := Config.Fetcher.MaxLinksPerPage
 	for _, link := range p.Links {
 		link.MakeAbsolute(fr.URL)
-		if f.shouldStoreParsedLink(link) {
-			log4go.Fine("Storing parsed link: %v", link)
-			f.fm.Datastore.StoreParsedURL(link, fr)
+		if !f.shouldStoreParsedLink(link) {
+			continue
+		}
+		key := link.String()
+		if first, ok := seen[key]; ok {
+			// A page may both link to and redirect to the same URL
+			first.JSRedirect = first.JSRedirect || link.JSRedirect
+			continue
+		}
+		seen[key] = link
+		if max > 0 && len(links) >= max {
+			fr.DroppedLinks++
+			continue
+		}
+		if f.hostLinks != nil {
+			if f.hostLinks.Contains(key) {
+				f.duplicateLinks++
+				fr.DuplicateLinks++
+				continue
+			}
+			f.hostLinks.Add(key, nil)
+		}
+		log4go.Fine("Storing parsed link: %v", link)
+		links = append(links, link)
+	}
+	if fr.DroppedLinks > 0 {
+		log4go.Debug("Dropped %d links from %v, over max_links_per_page (%d)", fr.DroppedLinks, fr.URL, max)
+	}
+	f.storedLinks += len(links)
+	if bds, ok := f.fm.Datastore.(BatchDatastore); ok {
+		if len(links) > 0 {
+			bds.StoreParsedURLs(f.ctx, links, fr)
+		}
+	} else {
+		for _, link := range links {
+			f.fm.Datastore.StoreParsedURL(f.ctx, link, fr)
 		}
 	}
 
-	fnv := fnv.New64()
-	fnv.Write(p.Text)
-	fr.FnvTextFingerprint = int64(fnv.Sum64())
+	fingerprintText(fr, p.Text)
 }
 
 // shouldStoreParsedLink returns true if the argument URL should
@@ -782,26 +2884,45 @@ func (f *fetcher) parseLinks(body []byte, fr *FetchResults) {
 //   (*) it's not in the AcceptProtocols
 //   (*) if the path matches exclude_link_patterns and doesn't match include_link_patterns.
 //   (*) the link's path is longer than (the positive) Config.Fetcher.MaxPathLength variable
+//   (*) the link was marked rel="nofollow" (or ugc, sponsored) and Config.Fetcher.RelNofollow is "skip"
+//   (*) the link has credentials and Config.Fetcher.UserinfoURLs is "drop"
+//   (*) the link has a non-standard port and Config.Fetcher.NonstandardPorts is "drop"
 //
 func (f *fetcher) shouldStoreParsedLink(u *URL) bool {
+	return f.parsedLinkExclusion(u) == ""
+}
+
+// parsedLinkExclusion returns why u would be rejected by
+// shouldStoreParsedLink, or "" if it would be stored
+func (f *fetcher) parsedLinkExclusion(u *URL) string {
+	if u.Nofollow && strings.ToLower(Config.Fetcher.RelNofollow) == "skip" {
+		return "rel=nofollow (fetcher.rel_nofollow)"
+	}
+	if u.User != nil && strings.ToLower(Config.Fetcher.UserinfoURLs) == "drop" {
+		return "credentials in URL (fetcher.userinfo_urls)"
+	}
+	if u.HasNonstandardPort() && strings.ToLower(Config.Fetcher.NonstandardPorts) == "drop" {
+		return "non-standard port (fetcher.nonstandard_ports)"
+	}
+
 	path := u.RequestURI()
 	if Config.Fetcher.MaxPathLength > 0 && len(path) > Config.Fetcher.MaxPathLength {
-		return false
+		return "path too long (fetcher.max_path_length)"
 	}
 
 	include := !(f.excludeLink != nil && f.excludeLink.MatchString(path)) ||
 		(f.includeLink != nil && f.includeLink.MatchString(path))
 	if !include {
-		return false
+		return "excluded path (fetcher.exclude_link_patterns)"
 	}
 
 	for _, f := range Config.Fetcher.AcceptProtocols {
 		if u.Scheme == f {
-			return true
+			return ""
 		}
 	}
 
-	return false
+	return "protocol not accepted (fetcher.accept_protocols)"
 }
 
 // checkForBlacklisting returns true if this site is blacklisted or should be
@@ -817,7 +2938,7 @@ func (f *fetcher) shouldStoreParsedLink(u *URL) bool {
 // TODO: write back to the database that this domain has been blacklisted so we
 // don't just keep re-dispatching it
 func (f *fetcher) checkForBlacklisting(host string) bool {
-	t, ok := f.fm.Transport.(*http.Transport)
+	t, ok := f.transport.(*http.Transport)
 	if !ok {
 		// We need to get the transport's Dial function in order to check the
 		// IP address
@@ -841,17 +2962,66 @@ func (f *fetcher) checkForBlacklisting(host string) bool {
 }
 
 func (f *fetcher) isHandleable(r *http.Response) bool {
-	for _, ct := range r.Header["Content-Type"] {
-		matched, err := f.fm.acceptFormats.Match(ct)
+	if f.acceptedContentType(r.Header) {
+		return true
+	}
+	ctype := strings.Join(r.Header["Content-Type"], ",")
+	log4go.Fine("URL (%v) did not match accepted content types, had: %v", r.Request.URL, ctype)
+	return false
+}
+
+// acceptedContentType returns true if one of the Content-Type headers matches
+// AcceptFormats
+func (f *fetcher) acceptedContentType(h http.Header) bool {
+	for _, ct := range h["Content-Type"] {
+		matched, err := f.acceptFormats.Match(ct)
 		if err == nil && matched {
 			return true
 		}
 	}
-	ctype := strings.Join(r.Header["Content-Type"], ",")
-	log4go.Fine("URL (%v) did not match accepted content types, had: %v", r.Request.URL, ctype)
 	return false
 }
 
+// genericContentTypes are the Content-Types servers send when they don't know
+// better, which fetcher.sniff_content_type may override
+var genericContentTypes = map[string]bool{
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"application/unknown":      true,
+	"application/x-unknown":    true,
+	"text/plain":               true,
+}
+
+// sniffableContentType returns true if h has no usable Content-Type, or only
+// a generic one
+func sniffableContentType(h http.Header) bool {
+	ctype := h.Get("Content-Type")
+	if ctype == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	return err != nil || genericContentTypes[mediaType]
+}
+
+// sniffContentType replaces a missing or generic Content-Type header of
+// fr.Response with the type http.DetectContentType finds in the body in the
+// read buffer, if fetcher.sniff_content_type is on and that type is more
+// specific. The header sent is kept in fr.DeclaredContentType.
+func (f *fetcher) sniffContentType(fr *FetchResults) {
+	if !Config.Fetcher.SniffContentType || f.readBuffer.Len() == 0 || !sniffableContentType(fr.Response.Header) {
+		return
+	}
+	sniffed := http.DetectContentType(f.readBuffer.Bytes())
+	sniffedType, _, err := mime.ParseMediaType(sniffed)
+	if err != nil || sniffedType == "application/octet-stream" || sniffedType == getMimeType(fr.Response) {
+		return
+	}
+	declared := fr.Response.Header.Get("Content-Type")
+	log4go.Fine("Sniffed Content-Type %v for %v, which declared %q", sniffed, fr.URL, declared)
+	fr.DeclaredContentType = declared
+	fr.Response.Header.Set("Content-Type", sniffed)
+}
+
 // getMimeType attempts to get the mime type (i.e. "Content-Type") from the
 // response. Returns an empty string if unable to.
 func getMimeType(r *http.Response) string {
@@ -884,6 +3054,9 @@ var privateNetworks = []*net.IPNet{
 	parseCIDR("192.168.0.0/16"),
 	parseCIDR("172.16.0.0/12"),
 	parseCIDR("127.0.0.0/8"),
+	parseCIDR("::1/128"),   // IPv6 loopback
+	parseCIDR("fc00::/7"),  // IPv6 unique local addresses
+	parseCIDR("fe80::/10"), // IPv6 link-local
 }
 
 // parseCIDR is a convenience for creating our static private IPNet ranges
@@ -896,11 +3069,16 @@ func parseCIDR(netstring string) *net.IPNet {
 }
 
 // isPrivateAddr determines whether the input address belongs to any of the
-// private networks specified in privateNetworkStrings. It returns an error
-// if the input string does not represent an IP address.
+// private networks specified in privateNetworks. addr may be an IPv4 or IPv6
+// address, with or without a port. It returns false if the input string does
+// not represent an IP address.
 func isPrivateAddr(addr string) bool {
 	// Remove the port number if there is one
-	if index := strings.LastIndex(addr, ":"); index != -1 {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	// And the zone of an IPv6 link-local address
+	if index := strings.Index(addr, "%"); index != -1 {
 		addr = addr[:index]
 	}
 
@@ -916,3 +3094,41 @@ func isPrivateAddr(addr string) bool {
 	}
 	return false
 }
+
+// xRobotsTagDirectives reads the noarchive and nosnippet directives of the
+// X-Robots-Tag headers in h. Directives may be given for a specific crawler
+// (ex. "X-Robots-Tag: walker: noarchive"); those for other crawlers are
+// ignored.
+func xRobotsTagDirectives(h http.Header, userAgent string) (noArchive, noSnippet bool) {
+	token := strings.ToLower(productToken(userAgent))
+	for _, v := range h[http.CanonicalHeaderKey("X-Robots-Tag")] {
+		directives := v
+		if i := strings.IndexByte(v, ':'); i >= 0 {
+			agent := strings.ToLower(strings.TrimSpace(v[:i]))
+			if !strings.ContainsAny(agent, ", ") && !xRobotsValuedDirectives[agent] {
+				if agent != token {
+					continue
+				}
+				directives = v[i+1:]
+			}
+		}
+		for _, d := range strings.Split(directives, ",") {
+			switch strings.ToLower(strings.TrimSpace(d)) {
+			case "noarchive":
+				noArchive = true
+			case "nosnippet":
+				noSnippet = true
+			}
+		}
+	}
+	return
+}
+
+// xRobotsValuedDirectives are the X-Robots-Tag directives that take a value
+// after a colon, so are not mistaken for a crawler name
+var xRobotsValuedDirectives = map[string]bool{
+	"unavailable_after": true,
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+}
@@ -0,0 +1,97 @@
+package walker
+
+import (
+	"crypto/sha256"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// Fingerprint algorithms that can be listed in fetcher.fingerprints
+const (
+	FingerprintFNV     = "fnv"
+	FingerprintSHA256  = "sha256"
+	FingerprintSimHash = "simhash"
+)
+
+// fingerprintEnabled returns true if algorithm is listed in
+// fetcher.fingerprints. FNV fingerprints are always computed, since change
+// detection and duplicate clustering rely on them.
+func fingerprintEnabled(algorithm string) bool {
+	if algorithm == FingerprintFNV {
+		return true
+	}
+	for _, a := range Config.Fetcher.Fingerprints {
+		if strings.EqualFold(a, algorithm) {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprintBody fills in the fingerprints of the response body in fr
+func fingerprintBody(fr *FetchResults, body []byte) {
+	fr.FnvFingerprint = fnvFingerprint(body)
+	if fingerprintEnabled(FingerprintSHA256) {
+		sum := sha256.Sum256(body)
+		fr.SHA256Fingerprint = sum[:]
+	}
+}
+
+// fingerprintText fills in the fingerprints of the text parsed out of the
+// response body in fr
+func fingerprintText(fr *FetchResults, text []byte) {
+	fr.FnvTextFingerprint = fnvFingerprint(text)
+	if fingerprintEnabled(FingerprintSimHash) {
+		fr.SimHashFingerprint = SimHash(text)
+	}
+}
+
+func fnvFingerprint(b []byte) int64 {
+	h := fnv.New64()
+	h.Write(b)
+	return int64(h.Sum64())
+}
+
+// SimHash computes the 64 bit SimHash of text, using its (case folded)
+// words as features. Unlike FNV and SHA-256, similar texts get fingerprints
+// that differ in few bits; see SimHashDistance.
+func SimHash(text []byte) int64 {
+	var weights [64]int
+	words := strings.FieldsFunc(strings.ToLower(string(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+	for _, w := range words {
+		h := fnv.New64a()
+		h.Write([]byte(w))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var simhash uint64
+	for i, w := range weights {
+		if w > 0 {
+			simhash |= 1 << uint(i)
+		}
+	}
+	return int64(simhash)
+}
+
+// SimHashDistance returns the number of bits that differ between two
+// SimHash fingerprints; the smaller it is the more alike the texts were.
+func SimHashDistance(a, b int64) int {
+	x := uint64(a ^ b)
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,70 @@
+package walker
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestFingerprints(t *testing.T) {
+	orig := Config.Fetcher.Fingerprints
+	defer func() {
+		Config.Fetcher.Fingerprints = orig
+	}()
+
+	body := []byte("<html><body>some words</body></html>")
+	text := []byte("some words")
+
+	Config.Fetcher.Fingerprints = []string{"fnv"}
+	fr := &FetchResults{}
+	fingerprintBody(fr, body)
+	fingerprintText(fr, text)
+	if fr.FnvFingerprint != fnvFingerprint(body) || fr.FnvTextFingerprint != fnvFingerprint(text) {
+		t.Errorf("Unexpected FNV fingerprints %x and %x", fr.FnvFingerprint, fr.FnvTextFingerprint)
+	}
+	if fr.SHA256Fingerprint != nil || fr.SimHashFingerprint != 0 {
+		t.Errorf("Expected no SHA-256 or SimHash fingerprint, got %x and %x", fr.SHA256Fingerprint, fr.SimHashFingerprint)
+	}
+
+	// FNV is computed even if it is not listed
+	Config.Fetcher.Fingerprints = []string{"SHA256", "simhash"}
+	fr = &FetchResults{}
+	fingerprintBody(fr, body)
+	fingerprintText(fr, text)
+	sum := sha256.Sum256(body)
+	if string(fr.SHA256Fingerprint) != string(sum[:]) {
+		t.Errorf("Expected SHA-256 fingerprint %x, got %x", sum, fr.SHA256Fingerprint)
+	}
+	if fr.SimHashFingerprint != SimHash(text) || fr.SimHashFingerprint == 0 {
+		t.Errorf("Unexpected SimHash fingerprint %x", fr.SimHashFingerprint)
+	}
+	if fr.FnvFingerprint == 0 || fr.FnvTextFingerprint == 0 {
+		t.Errorf("Expected FNV fingerprints to be computed")
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	base := []byte(`Walker is a web crawler. It is designed to scale to billions of
+		pages, storing the links it finds and the pages it fetches in cassandra.`)
+	similar := []byte(`walker is a WEB crawler; it is designed to scale to billions of
+		pages, storing the links it finds and the pages it has fetched in cassandra.`)
+	different := []byte(`The quick brown fox jumps over the lazy dog, and then goes
+		back to sleep under the old oak tree by the river bank.`)
+
+	if SimHash(nil) != 0 {
+		t.Errorf("Expected a zero SimHash of empty text, got %x", SimHash(nil))
+	}
+	if SimHash(base) != SimHash([]byte(string(base))) {
+		t.Errorf("Expected SimHash to be deterministic")
+	}
+	near := SimHashDistance(SimHash(base), SimHash(similar))
+	far := SimHashDistance(SimHash(base), SimHash(different))
+	if near >= far {
+		t.Errorf("Expected similar texts to be closer than different ones, got distances %d and %d", near, far)
+	}
+	if near > 10 {
+		t.Errorf("Expected similar texts to be within 10 bits, got %d", near)
+	}
+	if d := SimHashDistance(0, -1); d != 64 {
+		t.Errorf("Expected a distance of 64, got %d", d)
+	}
+}
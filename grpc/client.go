@@ -0,0 +1,452 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client implements walker.Datastore by calling a remote Server, so a
+// FetchManager can run without access to cassandra. It implements the optional
+// datastore interfaces too (walker.BatchDatastore, walker.RetiringDatastore,
+// walker.AssetDatastore, walker.HostSettingsDatastore,
+// walker.RequeueDatastore, walker.RobotsDatastore, walker.PolitenessDatastore,
+// walker.ErrorCountingDatastore, walker.HostClaimingDatastore); the Server
+// makes those calls if the remote datastore supports them. It also offers the
+// domain calls of cassandra.ModelDatastore that the Server exposes.
+//
+// NewClient should be used to create one.
+type Client struct {
+	conn   *grpc.ClientConn
+	client DatastoreClient
+
+	// Identifies this fetcher to the server; changed by Retire
+	mu      sync.Mutex
+	fetcher string
+
+	// Parsed grpc.call_timeout
+	timeout time.Duration
+
+	// Number of calls that failed, and the last error count of the server's
+	// datastore (see ErrorCount)
+	errors       int64
+	remoteErrors int64
+}
+
+// NewClient creates a Client for the server at address (host:port). The
+// connection uses TLS if grpc.tls is set, and every call carries
+// grpc.auth_token if it is set. opts are passed on to grpc.NewClient.
+func NewClient(address string, opts ...grpc.DialOption) (*Client, error) {
+	timeout, err := time.ParseDuration(walker.Config.GRPC.CallTimeout)
+	if err != nil {
+		panic(err) // This won't happen b/c this duration is checked in Config
+	}
+	u, err := gocql.RandomUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := insecure.NewCredentials()
+	if walker.Config.GRPC.TLS {
+		creds = credentials.NewTLS(nil)
+		if walker.Config.GRPC.TLSCAFile != "" {
+			creds, err = credentials.NewClientTLSFromFile(walker.Config.GRPC.TLSCAFile, "")
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load TLS CA file: %v", err)
+			}
+		}
+	}
+	c := &Client{
+		fetcher: u.String(),
+		timeout: timeout,
+	}
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		// Carry the trace of each call over to the server (see walker.StartTracing)
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(c.countUnary),
+		grpc.WithChainStreamInterceptor(c.countStream),
+	}, opts...)
+	if walker.Config.GRPC.AuthToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials(walker.Config.GRPC.AuthToken)))
+	}
+	conn, err := grpc.NewClient(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to datastore service at %v: %v", address, err)
+	}
+	c.conn = conn
+	c.client = NewDatastoreClient(conn)
+	return c, nil
+}
+
+// countUnary and countStream count the calls that fail (see ErrorCount)
+func (c *Client) countUnary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	}
+	return err
+}
+
+func (c *Client) countStream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+	streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	}
+	return stream, err
+}
+
+// tokenCredentials sends the auth token the Server checks with every call
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity is false so a token can be used on a loopback
+// connection; the Server refuses other addresses without TLS.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// id returns the token identifying this fetcher to the server
+func (c *Client) id() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fetcher
+}
+
+// call returns a context for a single call, bounded by grpc.call_timeout
+func (c *Client) call(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// ClaimNewHost is documented on the walker.Datastore interface.
+func (c *Client) ClaimNewHost(ctx context.Context) string {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	resp, err := c.client.ClaimNewHost(ctx, &ClaimNewHostRequest{Fetcher: c.id()})
+	if err != nil {
+		log4go.Error("Failed to claim new host: %v", err)
+		return ""
+	}
+	return resp.Host
+}
+
+// UnclaimHost is documented on the walker.Datastore interface.
+func (c *Client) UnclaimHost(ctx context.Context, host string) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.UnclaimHost(ctx, &UnclaimHostRequest{Fetcher: c.id(), Host: host})
+	if err != nil {
+		log4go.Error("Failed to unclaim host %v: %v", host, err)
+	}
+}
+
+// LinksForHost is documented on the walker.Datastore interface. The links are
+// streamed from the server as the channel is read; grpc.call_timeout does not
+// apply, since reading them takes as long as crawling them.
+func (c *Client) LinksForHost(ctx context.Context, host string) <-chan *walker.URL {
+	links := make(chan *walker.URL)
+	stream, err := c.client.LinksForHost(ctx, &LinksForHostRequest{Fetcher: c.id(), Host: host})
+	if err != nil {
+		log4go.Error("Failed to grab segment for %v: %v", host, err)
+		close(links)
+		return links
+	}
+
+	go func() {
+		defer close(links)
+		for {
+			pu, err := stream.Recv()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				atomic.AddInt64(&c.errors, 1)
+				log4go.Error("Failed reading segment for %v: %v", host, err)
+				return
+			}
+			u, err := fromURL(pu)
+			if err != nil {
+				log4go.Error("Error adding link (%v) to crawl: %v", pu.Url, err)
+				continue
+			}
+			select {
+			case links <- u:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return links
+}
+
+// StoreURLFetchResults is documented on the walker.Datastore interface.
+func (c *Client) StoreURLFetchResults(ctx context.Context, fr *walker.FetchResults) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.StoreURLFetchResults(ctx, &StoreURLFetchResultsRequest{
+		Fetcher: c.id(),
+		Results: toFetchResults(fr),
+	})
+	if err != nil {
+		log4go.Error("Failed storing fetch results for %v: %v", fr.URL, err)
+	}
+}
+
+// StoreParsedURL is documented on the walker.Datastore interface.
+func (c *Client) StoreParsedURL(ctx context.Context, u *walker.URL, fr *walker.FetchResults) {
+	c.StoreParsedURLs(ctx, []*walker.URL{u}, fr)
+}
+
+// StoreParsedURLs is documented on the walker.BatchDatastore interface.
+func (c *Client) StoreParsedURLs(ctx context.Context, urls []*walker.URL, fr *walker.FetchResults) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.StoreParsedURLs(ctx, &StoreParsedURLsRequest{
+		Fetcher: c.id(),
+		Urls:    toURLs(urls),
+		Results: toFetchResults(fr),
+	})
+	if err != nil {
+		log4go.Error("Failed storing %d parsed urls: %v", len(urls), err)
+	}
+}
+
+// KeepAlive is documented on the walker.Datastore interface.
+func (c *Client) KeepAlive(ctx context.Context) error {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.KeepAlive(ctx, &KeepAliveRequest{Fetcher: c.id()})
+	return err
+}
+
+// Retire is documented on the walker.RetiringDatastore interface. Later
+// calls are made as a new fetcher.
+func (c *Client) Retire(ctx context.Context) error {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.Retire(ctx, &RetireRequest{Fetcher: c.id()})
+	if err != nil {
+		return err
+	}
+	u, err := gocql.RandomUUID()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.fetcher = u.String()
+	c.mu.Unlock()
+	return nil
+}
+
+// StoreDomainAssets is documented on the walker.AssetDatastore interface.
+func (c *Client) StoreDomainAssets(ctx context.Context, host string, assets *walker.DomainAssets) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.StoreDomainAssets(ctx, &StoreDomainAssetsRequest{
+		Fetcher: c.id(),
+		Host:    host,
+		Assets:  toDomainAssets(assets),
+	})
+	if err != nil {
+		log4go.Error("Failed storing domain assets of %v: %v", host, err)
+	}
+}
+
+// HostSettings is documented on the walker.HostSettingsDatastore interface.
+// If the call fails the host is crawled with the configured settings.
+func (c *Client) HostSettings(ctx context.Context, host string) *walker.HostSettings {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	resp, err := c.client.HostSettings(ctx, &HostSettingsRequest{Fetcher: c.id(), Host: host})
+	if err != nil {
+		log4go.Error("Failed getting host settings of %v: %v", host, err)
+		return nil
+	}
+	return fromHostSettings(resp.Settings)
+}
+
+// RequeueHost is documented on the walker.RequeueDatastore interface.
+func (c *Client) RequeueHost(ctx context.Context, host string, links []*walker.URL) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.RequeueHost(ctx, &RequeueHostRequest{
+		Fetcher: c.id(),
+		Host:    host,
+		Links:   toURLs(links),
+	})
+	if err != nil {
+		log4go.Error("Failed to requeue host %v: %v", host, err)
+	}
+}
+
+// StoreRobotsFingerprint is documented on the walker.RobotsDatastore
+// interface.
+func (c *Client) StoreRobotsFingerprint(ctx context.Context, host string, fp int64) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.StoreRobotsFingerprint(ctx, &StoreRobotsFingerprintRequest{
+		Fetcher:     c.id(),
+		Host:        host,
+		Fingerprint: fp,
+	})
+	if err != nil {
+		log4go.Error("Failed storing robots.txt fingerprint of %v: %v", host, err)
+	}
+}
+
+// StorePolitenessAudit is documented on the walker.PolitenessDatastore
+// interface.
+func (c *Client) StorePolitenessAudit(ctx context.Context, audit *walker.PolitenessAudit) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.StorePolitenessAudit(ctx, &StorePolitenessAuditRequest{
+		Fetcher: c.id(),
+		Audit:   toPolitenessAudit(audit),
+	})
+	if err != nil {
+		log4go.Error("Failed storing politeness audit of %v: %v", audit.Host, err)
+	}
+}
+
+// ErrorCount is documented on the walker.ErrorCountingDatastore interface. It
+// returns the number of calls to the server that failed, plus the error count
+// of the server's datastore as of the last successful call to get it.
+func (c *Client) ErrorCount() int64 {
+	ctx, cancel := c.call(context.Background())
+	defer cancel()
+	resp, err := c.client.ErrorCount(ctx, &ErrorCountRequest{Fetcher: c.id()})
+	if err != nil {
+		log4go.Error("Failed getting error count of datastore service: %v", err)
+	} else {
+		atomic.StoreInt64(&c.remoteErrors, resp.Count)
+	}
+	return atomic.LoadInt64(&c.errors) + atomic.LoadInt64(&c.remoteErrors)
+}
+
+// ClaimHost is documented on the walker.HostClaimingDatastore interface. It
+// returns false if the call fails.
+func (c *Client) ClaimHost(ctx context.Context, host string) bool {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	resp, err := c.client.ClaimHost(ctx, &ClaimHostRequest{
+		Fetcher: c.id(),
+		Host:    host,
+	})
+	if err != nil {
+		log4go.Error("Failed to claim host %v: %v", host, err)
+		return false
+	}
+	return resp.Claimed
+}
+
+// ReleaseHost is documented on the walker.HostClaimingDatastore interface.
+func (c *Client) ReleaseHost(ctx context.Context, host string) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	_, err := c.client.ReleaseHost(ctx, &ReleaseHostRequest{
+		Fetcher: c.id(),
+		Host:    host,
+	})
+	if err != nil {
+		log4go.Error("Failed to release host %v: %v", host, err)
+	}
+}
+
+// Close is documented on the walker.Datastore interface. The server closes
+// this fetcher's datastore once it stops hearing from it.
+func (c *Client) Close() {
+	if err := c.conn.Close(); err != nil {
+		log4go.Error("Failed to close connection to datastore service: %v", err)
+	}
+}
+
+// InsertLinks is documented on the cassandra.ModelDatastore interface.
+func (c *Client) InsertLinks(links []string, excludeDomainReason string) []error {
+	ctx, cancel := c.call(context.Background())
+	defer cancel()
+	resp, err := c.client.InsertLinks(ctx, &InsertLinksRequest{
+		Links:               links,
+		ExcludeDomainReason: excludeDomainReason,
+	})
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range resp.Errors {
+		errs = append(errs, errors.New(e))
+	}
+	return errs
+}
+
+// InsertLinksWithTags is documented on the cassandra.ModelDatastore
+// interface.
+func (c *Client) InsertLinksWithTags(links []string, excludeDomainReason string, tags []string) []error {
+	ctx, cancel := c.call(context.Background())
+	defer cancel()
+	resp, err := c.client.InsertLinksWithTags(ctx, &InsertLinksWithTagsRequest{
+		Links:               links,
+		ExcludeDomainReason: excludeDomainReason,
+		Tags:                tags,
+	})
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range resp.Errors {
+		errs = append(errs, errors.New(e))
+	}
+	return errs
+}
+
+// FindDomain is documented on the cassandra.ModelDatastore interface.
+func (c *Client) FindDomain(domain string) (*cassandra.DomainInfo, error) {
+	ctx, cancel := c.call(context.Background())
+	defer cancel()
+	resp, err := c.client.FindDomain(ctx, &FindDomainRequest{Domain: domain})
+	if err != nil {
+		return nil, err
+	}
+	return fromDomainInfo(resp.Domain)
+}
+
+// ListDomains is documented on the cassandra.ModelDatastore interface.
+func (c *Client) ListDomains(query cassandra.DQ) ([]*cassandra.DomainInfo, error) {
+	ctx, cancel := c.call(context.Background())
+	defer cancel()
+	resp, err := c.client.ListDomains(ctx, &ListDomainsRequest{
+		Seed:    query.Seed,
+		Limit:   int32(query.Limit),
+		Working: query.Working,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var infos []*cassandra.DomainInfo
+	for _, pinfo := range resp.Domains {
+		info, err := fromDomainInfo(pinfo)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
@@ -0,0 +1,454 @@
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/gocql/gocql"
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fetchError stands in for the error of a remote fetch. It implements
+// net.Error so FetchResults.TransientFailure still recognizes timeouts.
+type fetchError struct {
+	msg     string
+	timeout bool
+}
+
+func (e *fetchError) Error() string   { return e.msg }
+func (e *fetchError) Timeout() bool   { return e.timeout }
+func (e *fetchError) Temporary() bool { return e.timeout }
+
+var _ net.Error = &fetchError{}
+
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func toURL(u *walker.URL) *URL {
+	if u == nil {
+		return nil
+	}
+	return &URL{
+		Url:         u.String(),
+		LastCrawled: toTimestamp(u.LastCrawled),
+		Nofollow:    u.Nofollow,
+		JsRedirect:  u.JSRedirect,
+	}
+}
+
+func fromURL(pu *URL) (*walker.URL, error) {
+	if pu == nil {
+		return nil, fmt.Errorf("Missing URL")
+	}
+	u, err := walker.ParseURL(pu.Url)
+	if err != nil {
+		return nil, err
+	}
+	u.LastCrawled = fromTimestamp(pu.LastCrawled)
+	u.Nofollow = pu.Nofollow
+	u.JSRedirect = pu.JsRedirect
+	return u, nil
+}
+
+func toURLs(urls []*walker.URL) []*URL {
+	pus := make([]*URL, 0, len(urls))
+	for _, u := range urls {
+		pus = append(pus, toURL(u))
+	}
+	return pus
+}
+
+func fromURLs(pus []*URL) ([]*walker.URL, error) {
+	urls := make([]*walker.URL, 0, len(pus))
+	for _, pu := range pus {
+		u, err := fromURL(pu)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// toFetchResults converts fr for the wire. The response body is not sent
+// (datastores only use fr.Body), nor the request.
+func toFetchResults(fr *walker.FetchResults) *FetchResults {
+	if fr == nil {
+		return nil
+	}
+	pfr := &FetchResults{
+		Url:                 toURL(fr.URL),
+		RedirectedFrom:      toURLs(fr.RedirectedFrom),
+		RedirectLoop:        fr.RedirectLoop,
+		RedirectChain:       toURLs(fr.RedirectChain),
+		Body:                fr.Body,
+		FetchTime:           toTimestamp(fr.FetchTime),
+		ExcludedByRobots:    fr.ExcludedByRobots,
+		RobotsRule:          fr.RobotsRule,
+		SkippedByPrecheck:   fr.SkippedByPrecheck,
+		NotModified:         fr.NotModified,
+		Truncated:           fr.Truncated,
+		Icons:               toURLs(fr.Icons),
+		MetaNoindex:         fr.MetaNoIndex,
+		MetaNofollow:        fr.MetaNoFollow,
+		NoArchive:           fr.NoArchive,
+		NoSnippet:           fr.NoSnippet,
+		DroppedLinks:        int32(fr.DroppedLinks),
+		DuplicateLinks:      int32(fr.DuplicateLinks),
+		Title:               fr.Title,
+		Description:         fr.Description,
+		MimeType:            fr.MimeType,
+		FnvFingerprint:      fr.FnvFingerprint,
+		FnvTextFingerprint:  fr.FnvTextFingerprint,
+		Sha256Fingerprint:   fr.SHA256Fingerprint,
+		SimhashFingerprint:  fr.SimHashFingerprint,
+		CrawlIdentity:       fr.CrawlIdentity,
+		RobotsUnavailable:   fr.RobotsUnavailable,
+		RobotsRetryTime:     toTimestamp(fr.RobotsRetryTime),
+		ParseAborted:        fr.ParseAborted,
+		RobotsAgent:         fr.RobotsAgent,
+		RobotsGroupAgent:    fr.RobotsGroupAgent,
+		ContentLanguage:     fr.ContentLanguage,
+		DeclaredContentType: fr.DeclaredContentType,
+		Timing: &FetchTiming{
+			Dns:      int64(fr.Timing.DNS),
+			Connect:  int64(fr.Timing.Connect),
+			Tls:      int64(fr.Timing.TLS),
+			Ttfb:     int64(fr.Timing.TTFB),
+			Transfer: int64(fr.Timing.Transfer),
+			Bytes:    fr.Timing.Bytes,
+		},
+	}
+	for _, status := range fr.RedirectStatuses {
+		pfr.RedirectStatuses = append(pfr.RedirectStatuses, int32(status))
+	}
+	if fr.Response != nil {
+		pfr.Response = &Response{StatusCode: int32(fr.Response.StatusCode)}
+		if fr.Response.Header != nil {
+			pfr.Response.Header = map[string]*HeaderValues{}
+			for k, v := range fr.Response.Header {
+				pfr.Response.Header[k] = &HeaderValues{Values: v}
+			}
+		}
+		if h := fr.RequestHeader(); h != nil {
+			pfr.Response.RequestHeader = map[string]*HeaderValues{}
+			for k, v := range h {
+				pfr.Response.RequestHeader[k] = &HeaderValues{Values: v}
+			}
+		}
+	}
+	if fr.FetchError != nil {
+		pfr.FetchError = fr.FetchError.Error()
+		if ne, ok := fr.FetchError.(net.Error); ok {
+			pfr.FetchErrorTimeout = ne.Timeout()
+		}
+	}
+	if fr.TLS != nil {
+		pfr.Tls = &TLSInfo{
+			Version:     fr.TLS.Version,
+			CipherSuite: fr.TLS.CipherSuite,
+			Issuer:      fr.TLS.Issuer,
+			Subject:     fr.TLS.Subject,
+			NotAfter:    toTimestamp(fr.TLS.NotAfter),
+			Sans:        fr.TLS.SANs,
+		}
+	}
+	if fr.StructuredData != nil {
+		b, err := json.Marshal(fr.StructuredData)
+		if err != nil {
+			log4go.Error("Failed to encode structured data of %v: %v", fr.URL, err)
+		} else {
+			pfr.StructuredData = b
+		}
+	}
+	return pfr
+}
+
+func fromFetchResults(pfr *FetchResults) (*walker.FetchResults, error) {
+	if pfr == nil {
+		return nil, nil
+	}
+	u, err := fromURL(pfr.Url)
+	if err != nil {
+		return nil, err
+	}
+	fr := &walker.FetchResults{
+		URL:                 u,
+		RedirectLoop:        pfr.RedirectLoop,
+		Body:                pfr.Body,
+		FetchTime:           fromTimestamp(pfr.FetchTime),
+		ExcludedByRobots:    pfr.ExcludedByRobots,
+		RobotsRule:          pfr.RobotsRule,
+		SkippedByPrecheck:   pfr.SkippedByPrecheck,
+		NotModified:         pfr.NotModified,
+		Truncated:           pfr.Truncated,
+		MetaNoIndex:         pfr.MetaNoindex,
+		MetaNoFollow:        pfr.MetaNofollow,
+		NoArchive:           pfr.NoArchive,
+		NoSnippet:           pfr.NoSnippet,
+		DroppedLinks:        int(pfr.DroppedLinks),
+		DuplicateLinks:      int(pfr.DuplicateLinks),
+		Title:               pfr.Title,
+		Description:         pfr.Description,
+		MimeType:            pfr.MimeType,
+		FnvFingerprint:      pfr.FnvFingerprint,
+		FnvTextFingerprint:  pfr.FnvTextFingerprint,
+		SHA256Fingerprint:   pfr.Sha256Fingerprint,
+		SimHashFingerprint:  pfr.SimhashFingerprint,
+		CrawlIdentity:       pfr.CrawlIdentity,
+		RobotsUnavailable:   pfr.RobotsUnavailable,
+		RobotsRetryTime:     fromTimestamp(pfr.RobotsRetryTime),
+		ParseAborted:        pfr.ParseAborted,
+		RobotsAgent:         pfr.RobotsAgent,
+		RobotsGroupAgent:    pfr.RobotsGroupAgent,
+		ContentLanguage:     pfr.ContentLanguage,
+		DeclaredContentType: pfr.DeclaredContentType,
+	}
+	if len(pfr.RedirectedFrom) > 0 {
+		fr.RedirectedFrom, err = fromURLs(pfr.RedirectedFrom)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(pfr.RedirectChain) > 0 {
+		fr.RedirectChain, err = fromURLs(pfr.RedirectChain)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(pfr.Icons) > 0 {
+		fr.Icons, err = fromURLs(pfr.Icons)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, status := range pfr.RedirectStatuses {
+		fr.RedirectStatuses = append(fr.RedirectStatuses, int(status))
+	}
+	if pfr.Response != nil {
+		fr.Response = &http.Response{
+			StatusCode: int(pfr.Response.StatusCode),
+			Status:     fmt.Sprintf("%d %s", pfr.Response.StatusCode, http.StatusText(int(pfr.Response.StatusCode))),
+		}
+		if pfr.Response.Header != nil {
+			fr.Response.Header = http.Header{}
+			for k, v := range pfr.Response.Header {
+				fr.Response.Header[k] = v.Values
+			}
+		}
+		if pfr.Response.RequestHeader != nil {
+			fr.Response.Request = &http.Request{Header: http.Header{}}
+			for k, v := range pfr.Response.RequestHeader {
+				fr.Response.Request.Header[k] = v.Values
+			}
+		}
+	}
+	if pfr.FetchError != "" {
+		fr.FetchError = &fetchError{msg: pfr.FetchError, timeout: pfr.FetchErrorTimeout}
+	}
+	if t := pfr.Timing; t != nil {
+		fr.Timing = walker.FetchTiming{
+			DNS:      time.Duration(t.Dns),
+			Connect:  time.Duration(t.Connect),
+			TLS:      time.Duration(t.Tls),
+			TTFB:     time.Duration(t.Ttfb),
+			Transfer: time.Duration(t.Transfer),
+			Bytes:    t.Bytes,
+		}
+	}
+	if t := pfr.Tls; t != nil {
+		fr.TLS = &walker.TLSInfo{
+			Version:     t.Version,
+			CipherSuite: t.CipherSuite,
+			Issuer:      t.Issuer,
+			Subject:     t.Subject,
+			NotAfter:    fromTimestamp(t.NotAfter),
+			SANs:        t.Sans,
+		}
+	}
+	if len(pfr.StructuredData) > 0 {
+		// Nested microdata items come back as maps rather than
+		// *MicrodataItem, which encodes to the same JSON
+		fr.StructuredData = &walker.StructuredData{}
+		if err := json.Unmarshal(pfr.StructuredData, fr.StructuredData); err != nil {
+			return nil, fmt.Errorf("Failed to decode structured data: %v", err)
+		}
+	}
+	return fr, nil
+}
+
+func toDomainInfo(info *cassandra.DomainInfo) *DomainInfo {
+	if info == nil {
+		return nil
+	}
+	return &DomainInfo{
+		Domain:               info.Domain,
+		Excluded:             info.Excluded,
+		ExcludeReason:        info.ExcludeReason,
+		Paused:               info.Paused,
+		SampleThreshold:      int32(info.SampleThreshold),
+		SamplePercent:        info.SamplePercent,
+		ByteBudget:           info.ByteBudget,
+		ClaimTime:            toTimestamp(info.ClaimTime),
+		ClaimToken:           info.ClaimToken.String(),
+		NumberLinksTotal:     int32(info.NumberLinksTotal),
+		NumberLinksQueued:    int32(info.NumberLinksQueued),
+		NumberLinksUncrawled: int32(info.NumberLinksUncrawled),
+		Priority:             int32(info.Priority),
+		CrawlWindow:          info.CrawlWindow,
+		CrawlTimezone:        info.CrawlTimezone,
+		FaviconUrl:           info.FaviconURL,
+		FaviconTime:          toTimestamp(info.FaviconTime),
+		FaviconStatus:        int32(info.FaviconStatus),
+		FaviconMimeType:      info.FaviconMimeType,
+		FaviconFingerprint:   info.FaviconFingerprint,
+	}
+}
+
+func fromDomainInfo(pinfo *DomainInfo) (*cassandra.DomainInfo, error) {
+	if pinfo == nil {
+		return nil, nil
+	}
+	claimTok, err := gocql.ParseUUID(pinfo.ClaimToken)
+	if err != nil {
+		return nil, fmt.Errorf("Bad claim token for %v: %v", pinfo.Domain, err)
+	}
+	return &cassandra.DomainInfo{
+		Domain:               pinfo.Domain,
+		Excluded:             pinfo.Excluded,
+		ExcludeReason:        pinfo.ExcludeReason,
+		Paused:               pinfo.Paused,
+		SampleThreshold:      int(pinfo.SampleThreshold),
+		SamplePercent:        pinfo.SamplePercent,
+		ByteBudget:           pinfo.ByteBudget,
+		ClaimTime:            fromTimestamp(pinfo.ClaimTime),
+		ClaimToken:           claimTok,
+		NumberLinksTotal:     int(pinfo.NumberLinksTotal),
+		NumberLinksQueued:    int(pinfo.NumberLinksQueued),
+		NumberLinksUncrawled: int(pinfo.NumberLinksUncrawled),
+		Priority:             int(pinfo.Priority),
+		CrawlWindow:          pinfo.CrawlWindow,
+		CrawlTimezone:        pinfo.CrawlTimezone,
+		FaviconURL:           pinfo.FaviconUrl,
+		FaviconTime:          fromTimestamp(pinfo.FaviconTime),
+		FaviconStatus:        int(pinfo.FaviconStatus),
+		FaviconMimeType:      pinfo.FaviconMimeType,
+		FaviconFingerprint:   pinfo.FaviconFingerprint,
+	}, nil
+}
+
+func toDomainAssets(assets *walker.DomainAssets) *DomainAssets {
+	if assets == nil {
+		return nil
+	}
+	return &DomainAssets{
+		FaviconUrl:         toURL(assets.FaviconURL),
+		FaviconTime:        toTimestamp(assets.FaviconTime),
+		FaviconStatus:      int32(assets.FaviconStatus),
+		FaviconMimeType:    assets.FaviconMimeType,
+		FaviconFingerprint: assets.FaviconFingerprint,
+	}
+}
+
+func fromDomainAssets(passets *DomainAssets) (*walker.DomainAssets, error) {
+	if passets == nil {
+		return nil, fmt.Errorf("Missing domain assets")
+	}
+	assets := &walker.DomainAssets{
+		FaviconTime:        fromTimestamp(passets.FaviconTime),
+		FaviconStatus:      int(passets.FaviconStatus),
+		FaviconMimeType:    passets.FaviconMimeType,
+		FaviconFingerprint: passets.FaviconFingerprint,
+	}
+	if passets.FaviconUrl != nil {
+		u, err := fromURL(passets.FaviconUrl)
+		if err != nil {
+			return nil, err
+		}
+		assets.FaviconURL = u
+	}
+	return assets, nil
+}
+
+func toHostSettings(settings *walker.HostSettings) *HostSettings {
+	if settings == nil {
+		return nil
+	}
+	return &HostSettings{
+		CrawlDelay: int64(settings.CrawlDelay),
+		UserAgent:  settings.UserAgent,
+	}
+}
+
+func fromHostSettings(psettings *HostSettings) *walker.HostSettings {
+	if psettings == nil {
+		return nil
+	}
+	return &walker.HostSettings{
+		CrawlDelay: time.Duration(psettings.CrawlDelay),
+		UserAgent:  psettings.UserAgent,
+	}
+}
+
+func toPolitenessAudit(audit *walker.PolitenessAudit) *PolitenessAudit {
+	if audit == nil {
+		return nil
+	}
+	paudit := &PolitenessAudit{
+		Host:               audit.Host,
+		Start:              toTimestamp(audit.Start),
+		Duration:           int64(audit.Duration),
+		CrawlDelay:         int64(audit.CrawlDelay),
+		Requests:           int32(audit.Requests),
+		TooManyRequests:    int32(audit.TooManyRequests),
+		ServiceUnavailable: int32(audit.ServiceUnavailable),
+	}
+	for _, e := range audit.Events {
+		paudit.Events = append(paudit.Events, &PolitenessEvent{
+			Time:   toTimestamp(e.Time),
+			Url:    e.URL,
+			Status: int32(e.Status),
+		})
+	}
+	return paudit
+}
+
+func fromPolitenessAudit(paudit *PolitenessAudit) (*walker.PolitenessAudit, error) {
+	if paudit == nil {
+		return nil, fmt.Errorf("Missing politeness audit")
+	}
+	audit := &walker.PolitenessAudit{
+		Host:               paudit.Host,
+		Start:              fromTimestamp(paudit.Start),
+		Duration:           time.Duration(paudit.Duration),
+		CrawlDelay:         time.Duration(paudit.CrawlDelay),
+		Requests:           int(paudit.Requests),
+		TooManyRequests:    int(paudit.TooManyRequests),
+		ServiceUnavailable: int(paudit.ServiceUnavailable),
+	}
+	for _, e := range paudit.Events {
+		audit.Events = append(audit.Events, walker.PolitenessEvent{
+			Time:   fromTimestamp(e.Time),
+			URL:    e.Url,
+			Status: int(e.Status),
+		})
+	}
+	return audit, nil
+}
@@ -0,0 +1,19 @@
+/*
+Package grpc lets walker fetchers run on machines without access to
+cassandra, by serving the datastore over gRPC.
+
+A Server, run next to cassandra (see `walker datastore-service`), makes the
+calls of remote fetchers on a cassandra Datastore. Fetchers use a Client as
+their walker.Datastore (see grpc.datastore_address in walker.yaml). Outside a
+single machine, the server must be given a TLS certificate and an auth token
+(see the grpc section of walker.yaml), which fetchers then connect with.
+
+The protocol is defined in walker.proto; after changing it, regenerate the Go
+code with
+
+	protoc --go_out=. --go_opt=paths=source_relative \
+		--go-grpc_out=. --go-grpc_opt=paths=source_relative walker.proto
+*/
+package grpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative walker.proto
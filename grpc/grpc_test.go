@@ -0,0 +1,591 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func startServer(t *testing.T, model cassandra.ModelDatastore, ds walker.Datastore) (*Server, *Client) {
+	server, err := NewServer(model, func() (walker.Datastore, error) { return ds, nil })
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(lis)
+
+	client, err := NewClient(lis.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return server, client
+}
+
+func TestFetchResultsRoundTrip(t *testing.T) {
+	fetchTime := time.Now().UTC()
+	fr := &walker.FetchResults{
+		URL:              walker.MustParse("http://test.com/page1.html"),
+		RedirectedFrom:   []*walker.URL{walker.MustParse("http://test.com/page2.html")},
+		RedirectStatuses: []int{301},
+		Response: &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Cache-Control": []string{"max-age=60"}},
+			Request: &http.Request{Header: http.Header{
+				"User-Agent":    []string{"Walker"},
+				"Authorization": []string{"Basic dXNlcjpwYXNz"},
+			}},
+		},
+		Body:               "<html></html>",
+		FetchError:         timeoutError{},
+		FetchTime:          fetchTime,
+		Title:              "Page 1",
+		MimeType:           "text/html",
+		FnvFingerprint:     42,
+		FnvTextFingerprint: 43,
+		SHA256Fingerprint:  []byte{1, 2, 3},
+		SimHashFingerprint: 44,
+		NotModified:        true,
+		Truncated:          true,
+		Icons:              []*walker.URL{walker.MustParse("http://test.com/icon.png")},
+		Timing:             walker.FetchTiming{DNS: time.Millisecond, TTFB: time.Second, Bytes: 13},
+		TLS: &walker.TLSInfo{
+			Version:  "TLS 1.3",
+			NotAfter: fetchTime.Add(time.Hour),
+			SANs:     []string{"test.com"},
+		},
+		StructuredData: &walker.StructuredData{
+			OpenGraph: map[string][]string{"og:title": []string{"Page 1"}},
+		},
+		CrawlIdentity:       "research",
+		RobotsUnavailable:   true,
+		RobotsRetryTime:     fetchTime.Add(time.Hour),
+		ParseAborted:        true,
+		RobotsAgent:         "Walker",
+		RobotsGroupAgent:    "*",
+		ContentLanguage:     "en-US",
+		DeclaredContentType: "application/octet-stream",
+	}
+
+	got, err := fromFetchResults(toFetchResults(fr))
+	if err != nil {
+		t.Fatalf("Failed to convert fetch results: %v", err)
+	}
+	if got.URL.String() != fr.URL.String() || len(got.RedirectedFrom) != 1 ||
+		got.RedirectedFrom[0].String() != fr.RedirectedFrom[0].String() {
+		t.Errorf("Expected URLs %v -> %v, got %v -> %v", fr.URL, fr.RedirectedFrom, got.URL, got.RedirectedFrom)
+	}
+	if !reflect.DeepEqual(got.RedirectStatuses, fr.RedirectStatuses) {
+		t.Errorf("Expected redirect statuses %v, got %v", fr.RedirectStatuses, got.RedirectStatuses)
+	}
+	if got.Response.StatusCode != 200 || got.Response.Header.Get("Cache-Control") != "max-age=60" {
+		t.Errorf("Expected the response status and headers to survive, got %v", got.Response)
+	}
+	if h := got.RequestHeader(); h.Get("User-Agent") != "Walker" || h.Get("Authorization") != "Basic ****" {
+		t.Errorf("Expected the masked request headers to survive, got %v", h)
+	}
+	if !got.TransientFailure() || got.FetchError.Error() != "i/o timeout" {
+		t.Errorf("Expected a timeout fetch error, got %v", got.FetchError)
+	}
+	if !got.FetchTime.Equal(fetchTime) {
+		t.Errorf("Expected fetch time %v, got %v", fetchTime, got.FetchTime)
+	}
+	if got.Body != fr.Body || got.Title != fr.Title || got.MimeType != fr.MimeType ||
+		got.FnvFingerprint != fr.FnvFingerprint || got.FnvTextFingerprint != fr.FnvTextFingerprint ||
+		!bytes.Equal(got.SHA256Fingerprint, fr.SHA256Fingerprint) || got.SimHashFingerprint != fr.SimHashFingerprint ||
+		got.NotModified != fr.NotModified || got.Truncated != fr.Truncated {
+		t.Errorf("Expected %+v, got %+v", fr, got)
+	}
+	if len(got.Icons) != 1 || got.Icons[0].String() != fr.Icons[0].String() {
+		t.Errorf("Expected icons %v, got %v", fr.Icons, got.Icons)
+	}
+	if got.Timing != fr.Timing {
+		t.Errorf("Expected timing %v, got %v", fr.Timing, got.Timing)
+	}
+	if got.TLS == nil || got.TLS.Version != fr.TLS.Version || !got.TLS.NotAfter.Equal(fr.TLS.NotAfter) ||
+		!reflect.DeepEqual(got.TLS.SANs, fr.TLS.SANs) {
+		t.Errorf("Expected TLS info %v, got %v", fr.TLS, got.TLS)
+	}
+	if got.StructuredData == nil || !reflect.DeepEqual(got.StructuredData.OpenGraph, fr.StructuredData.OpenGraph) {
+		t.Errorf("Expected structured data %v, got %v", fr.StructuredData, got.StructuredData)
+	}
+	if got.CrawlIdentity != fr.CrawlIdentity {
+		t.Errorf("Expected crawl identity %q, got %q", fr.CrawlIdentity, got.CrawlIdentity)
+	}
+	if !got.RobotsUnavailable || !got.RobotsRetryTime.Equal(fr.RobotsRetryTime) {
+		t.Errorf("Expected robots.txt unavailable until %v, got %v until %v", fr.RobotsRetryTime, got.RobotsUnavailable,
+			got.RobotsRetryTime)
+	}
+	if !got.ParseAborted {
+		t.Errorf("Expected ParseAborted to survive")
+	}
+	if got.RobotsAgent != fr.RobotsAgent || got.RobotsGroupAgent != fr.RobotsGroupAgent {
+		t.Errorf("Expected robots agents %q and %q, got %q and %q", fr.RobotsAgent, fr.RobotsGroupAgent,
+			got.RobotsAgent, got.RobotsGroupAgent)
+	}
+	if got.ContentLanguage != fr.ContentLanguage {
+		t.Errorf("Expected content language %q, got %q", fr.ContentLanguage, got.ContentLanguage)
+	}
+	if got.DeclaredContentType != fr.DeclaredContentType {
+		t.Errorf("Expected declared content type %q, got %q", fr.DeclaredContentType, got.DeclaredContentType)
+	}
+}
+
+func TestRedirectLoopRoundTrip(t *testing.T) {
+	chain := []*walker.URL{
+		walker.MustParse("http://test.com/a.html"),
+		walker.MustParse("http://test.com/b.html"),
+		walker.MustParse("http://test.com/a.html"),
+	}
+	fr := &walker.FetchResults{
+		URL:           chain[0],
+		FetchError:    &walker.RedirectLoopError{Chain: chain, Loop: true},
+		FetchTime:     time.Now(),
+		RedirectLoop:  true,
+		RedirectChain: chain,
+	}
+	got, err := fromFetchResults(toFetchResults(fr))
+	if err != nil {
+		t.Fatalf("Failed to convert fetch results: %v", err)
+	}
+	if !got.RedirectLoop {
+		t.Errorf("Expected RedirectLoop to survive")
+	}
+	var gotChain []string
+	for _, u := range got.RedirectChain {
+		gotChain = append(gotChain, u.String())
+	}
+	expected := []string{"http://test.com/a.html", "http://test.com/b.html", "http://test.com/a.html"}
+	if !reflect.DeepEqual(gotChain, expected) {
+		t.Errorf("Expected redirect chain %v, got %v", expected, gotChain)
+	}
+}
+
+func TestNotYetCrawledRoundTrip(t *testing.T) {
+	u := walker.MustParse("http://test.com/")
+	u.LastCrawled = walker.NotYetCrawled
+	got, err := fromURL(toURL(u))
+	if err != nil {
+		t.Fatalf("Failed to convert URL: %v", err)
+	}
+	if !got.LastCrawled.Equal(walker.NotYetCrawled) {
+		t.Errorf("Expected LastCrawled to be NotYetCrawled, got %v", got.LastCrawled)
+	}
+}
+
+func TestJSRedirectRoundTrip(t *testing.T) {
+	u := walker.MustParse("http://test.com/landing.html")
+	u.JSRedirect = true
+	got, err := fromURL(toURL(u))
+	if err != nil {
+		t.Fatalf("Failed to convert URL: %v", err)
+	}
+	if !got.JSRedirect {
+		t.Errorf("Expected JSRedirect to survive")
+	}
+}
+
+func TestRemoteDatastore(t *testing.T) {
+	ds := &walker.MockDatastore{}
+	model := &cassandra.MockModelDatastore{}
+	server, client := startServer(t, model, ds)
+	defer server.Stop()
+	defer client.Close()
+	ctx := context.Background()
+
+	links := []*walker.URL{
+		walker.MustParse("http://test.com/page1.html"),
+		walker.MustParse("http://test.com/page2.html"),
+	}
+	ds.On("KeepAlive").Return(nil)
+	ds.On("ClaimNewHost").Return("test.com")
+	ds.On("LinksForHost", "test.com").Return(links)
+	ds.On("UnclaimHost", "test.com").Return()
+	ds.On("Close").Return()
+
+	var stored *walker.FetchResults
+	ds.On("StoreURLFetchResults", mock.AnythingOfType("*walker.FetchResults")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*walker.FetchResults)
+	}).Return()
+	var parsed []string
+	ds.On("StoreParsedURL", mock.AnythingOfType("*walker.URL"), mock.AnythingOfType("*walker.FetchResults")).Run(
+		func(args mock.Arguments) {
+			parsed = append(parsed, args.Get(0).(*walker.URL).String())
+		}).Return()
+
+	if err := client.KeepAlive(ctx); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	if host := client.ClaimNewHost(ctx); host != "test.com" {
+		t.Fatalf("Expected to claim test.com, got %q", host)
+	}
+	var got []string
+	for u := range client.LinksForHost(ctx, "test.com") {
+		got = append(got, u.String())
+	}
+	if !reflect.DeepEqual(got, []string{links[0].String(), links[1].String()}) {
+		t.Errorf("Expected links %v, got %v", links, got)
+	}
+
+	fr := &walker.FetchResults{
+		URL:       links[0],
+		Response:  &http.Response{StatusCode: 404},
+		FetchTime: time.Now(),
+	}
+	client.StoreURLFetchResults(ctx, fr)
+	if stored == nil || stored.URL.String() != links[0].String() || stored.Response.StatusCode != 404 {
+		t.Errorf("Expected fetch results for %v to be stored, got %v", links[0], stored)
+	}
+	client.StoreParsedURLs(ctx, links, fr)
+	if !reflect.DeepEqual(parsed, got) {
+		t.Errorf("Expected parsed links %v to be stored, got %v", got, parsed)
+	}
+	var assets *walker.DomainAssets
+	ds.On("StoreDomainAssets", "test.com", mock.AnythingOfType("*walker.DomainAssets")).Run(func(args mock.Arguments) {
+		assets = args.Get(1).(*walker.DomainAssets)
+	}).Return()
+	client.StoreDomainAssets(ctx, "test.com", &walker.DomainAssets{
+		FaviconURL:         walker.MustParse("http://test.com/favicon.ico"),
+		FaviconTime:        fr.FetchTime,
+		FaviconStatus:      200,
+		FaviconMimeType:    "image/x-icon",
+		FaviconFingerprint: 7,
+	})
+	if assets == nil || assets.FaviconURL.String() != "http://test.com/favicon.ico" || assets.FaviconStatus != 200 ||
+		assets.FaviconMimeType != "image/x-icon" || assets.FaviconFingerprint != 7 || !assets.FaviconTime.Equal(fr.FetchTime) {
+		t.Errorf("Expected domain assets to be stored, got %+v", assets)
+	}
+	client.UnclaimHost(ctx, "test.com")
+
+	model.On("FindDomain", "test.com").Return(&cassandra.DomainInfo{Domain: "test.com", Priority: 3}, nil)
+	info, err := client.FindDomain("test.com")
+	if err != nil {
+		t.Fatalf("FindDomain failed: %v", err)
+	}
+	if info.Domain != "test.com" || info.Priority != 3 {
+		t.Errorf("Expected test.com with priority 3, got %+v", info)
+	}
+	model.On("InsertLinks", []string{"http://new.com/"}, "").Return([]error{})
+	if errs := client.InsertLinks([]string{"http://new.com/"}, ""); len(errs) != 0 {
+		t.Errorf("Expected no InsertLinks errors, got %v", errs)
+	}
+	model.On("InsertLinksWithTags", []string{"http://tagged.com/"}, "", []string{"news"}).Return(
+		[]error{errors.New("bad tag")})
+	if errs := client.InsertLinksWithTags([]string{"http://tagged.com/"}, "", []string{"news"}); len(errs) != 1 ||
+		errs[0].Error() != "bad tag" {
+		t.Errorf("Expected the InsertLinksWithTags error to be returned, got %v", errs)
+	}
+
+	server.Stop()
+	ds.AssertExpectations(t)
+	model.AssertExpectations(t)
+}
+
+func TestRemoteFetchersGetOwnDatastores(t *testing.T) {
+	created := 0
+	server, err := NewServer(&cassandra.MockModelDatastore{}, func() (walker.Datastore, error) {
+		created++
+		ds := &walker.MockDatastore{}
+		ds.On("KeepAlive").Return(nil)
+		return ds, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	for _, fetcher := range []string{"a", "b", "a"} {
+		if _, err := server.KeepAlive(ctx, &KeepAliveRequest{Fetcher: fetcher}); err != nil {
+			t.Fatalf("KeepAlive failed: %v", err)
+		}
+	}
+	if created != 2 {
+		t.Errorf("Expected a datastore for each of the 2 fetchers, got %d", created)
+	}
+	if _, err := server.KeepAlive(ctx, &KeepAliveRequest{}); err == nil {
+		t.Errorf("Expected calls without a fetcher token to fail")
+	}
+}
+
+func TestRemoteDatastoreCreationDoesNotBlock(t *testing.T) {
+	creating, slow := make(chan struct{}), make(chan struct{})
+	server, err := NewServer(&cassandra.MockModelDatastore{}, func() (walker.Datastore, error) {
+		close(creating)
+		<-slow
+		ds := &walker.MockDatastore{}
+		ds.On("KeepAlive").Return(nil)
+		return ds, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ready := &walker.MockDatastore{}
+	ready.On("KeepAlive").Return(nil)
+	server.fetchers["ready"] = &remoteFetcher{ds: ready, lastSeen: time.Now()}
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.KeepAlive(ctx, &KeepAliveRequest{Fetcher: "new"})
+	}()
+	<-creating
+
+	// Calls of a fetcher with a datastore go through while another
+	// fetcher's datastore is being created
+	called := make(chan error, 1)
+	go func() {
+		_, err := server.KeepAlive(ctx, &KeepAliveRequest{Fetcher: "ready"})
+		called <- err
+	}()
+	select {
+	case err := <-called:
+		if err != nil {
+			t.Errorf("KeepAlive failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected calls not to wait on another fetcher's datastore being created")
+	}
+	close(slow)
+	<-done
+}
+
+func TestRemoteRetire(t *testing.T) {
+	var datastores []*walker.MockDatastore
+	server, err := NewServer(&cassandra.MockModelDatastore{}, func() (walker.Datastore, error) {
+		ds := &walker.MockDatastore{}
+		ds.On("KeepAlive").Return(nil)
+		ds.On("Retire").Return(nil)
+		ds.On("Close").Return()
+		datastores = append(datastores, ds)
+		return ds, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+	client, err := NewClient(lis.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := client.KeepAlive(ctx); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	if err := client.Retire(ctx); err != nil {
+		t.Fatalf("Retire failed: %v", err)
+	}
+	if len(datastores) != 1 {
+		t.Fatalf("Expected 1 datastore before retiring, got %d", len(datastores))
+	}
+	datastores[0].AssertCalled(t, "Retire")
+	datastores[0].AssertCalled(t, "Close")
+
+	// The client carries on as a new fetcher
+	if err := client.KeepAlive(ctx); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	if len(datastores) != 2 {
+		t.Errorf("Expected a new datastore after retiring, got %d", len(datastores))
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	orig := walker.Config.GRPC.AuthToken
+	defer func() { walker.Config.GRPC.AuthToken = orig }()
+	walker.Config.GRPC.AuthToken = "secret"
+
+	ds := &walker.MockDatastore{}
+	ds.On("KeepAlive").Return(nil)
+	ds.On("Close").Return()
+	server, client := startServer(t, &cassandra.MockModelDatastore{}, ds)
+	defer server.Stop()
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := client.KeepAlive(ctx); err != nil {
+		t.Fatalf("KeepAlive with the token failed: %v", err)
+	}
+
+	walker.Config.GRPC.AuthToken = "wrong"
+	wrong, err := NewClient(client.conn.Target())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer wrong.Close()
+	if err := wrong.KeepAlive(ctx); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated with the wrong token, got %v", err)
+	}
+	if count := wrong.ErrorCount(); count == 0 {
+		t.Errorf("Expected the failed calls to be counted as errors")
+	}
+
+	walker.Config.GRPC.AuthToken = ""
+	none, err := NewClient(client.conn.Target())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer none.Close()
+	if err := none.KeepAlive(ctx); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token, got %v", err)
+	}
+}
+
+func TestServeRefusesNonLoopback(t *testing.T) {
+	orig := walker.Config.GRPC.AuthToken
+	defer func() { walker.Config.GRPC.AuthToken = orig }()
+	walker.Config.GRPC.AuthToken = "secret"
+
+	server, err := NewServer(&cassandra.MockModelDatastore{}, func() (walker.Datastore, error) {
+		return &walker.MockDatastore{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Stop()
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	// A token alone is not enough without TLS
+	if err := server.Serve(lis); err == nil {
+		t.Errorf("Expected Serve to refuse a non-loopback address without TLS")
+	}
+}
+
+// optionalDatastore is a MockDatastore that also implements the optional
+// datastore interfaces the Server passes calls on to
+type optionalDatastore struct {
+	*walker.MockDatastore
+}
+
+func (ds optionalDatastore) HostSettings(ctx context.Context, host string) *walker.HostSettings {
+	args := ds.Called(host)
+	return args.Get(0).(*walker.HostSettings)
+}
+
+func (ds optionalDatastore) RequeueHost(ctx context.Context, host string, links []*walker.URL) {
+	ds.Called(host, links)
+}
+
+func (ds optionalDatastore) StoreRobotsFingerprint(ctx context.Context, host string, fp int64) {
+	ds.Called(host, fp)
+}
+
+func (ds optionalDatastore) StorePolitenessAudit(ctx context.Context, audit *walker.PolitenessAudit) {
+	ds.Called(audit)
+}
+
+func (ds optionalDatastore) ErrorCount() int64 {
+	args := ds.Called()
+	return args.Get(0).(int64)
+}
+
+func (ds optionalDatastore) ClaimHost(ctx context.Context, host string) bool {
+	args := ds.Called(host)
+	return args.Bool(0)
+}
+
+func (ds optionalDatastore) ReleaseHost(ctx context.Context, host string) {
+	ds.Called(host)
+}
+
+func TestRemoteOptionalDatastore(t *testing.T) {
+	ds := optionalDatastore{&walker.MockDatastore{}}
+	ds.On("Close").Return()
+	server, client := startServer(t, &cassandra.MockModelDatastore{}, ds)
+	defer client.Close()
+	ctx := context.Background()
+
+	ds.On("HostSettings", "slow.com").Return(&walker.HostSettings{CrawlDelay: 5 * time.Second})
+	ds.On("HostSettings", "agent.com").Return(&walker.HostSettings{UserAgent: "Other/1.0"})
+	ds.On("HostSettings", "test.com").Return((*walker.HostSettings)(nil))
+	if settings := client.HostSettings(ctx, "slow.com"); settings == nil || settings.CrawlDelay != 5*time.Second {
+		t.Errorf("Expected a 5s crawl delay for slow.com, got %+v", settings)
+	}
+	if settings := client.HostSettings(ctx, "agent.com"); settings == nil || settings.UserAgent != "Other/1.0" {
+		t.Errorf("Expected user agent Other/1.0 for agent.com, got %+v", settings)
+	}
+	if settings := client.HostSettings(ctx, "test.com"); settings != nil {
+		t.Errorf("Expected no settings for test.com, got %+v", settings)
+	}
+
+	var requeued []string
+	ds.On("RequeueHost", "test.com", mock.AnythingOfType("[]*walker.URL")).Run(func(args mock.Arguments) {
+		for _, u := range args.Get(1).([]*walker.URL) {
+			requeued = append(requeued, u.String())
+		}
+	}).Return()
+	client.RequeueHost(ctx, "test.com", []*walker.URL{walker.MustParse("http://test.com/left.html")})
+	if !reflect.DeepEqual(requeued, []string{"http://test.com/left.html"}) {
+		t.Errorf("Expected the leftover link to be requeued, got %v", requeued)
+	}
+
+	ds.On("StoreRobotsFingerprint", "test.com", int64(42)).Return()
+	client.StoreRobotsFingerprint(ctx, "test.com", 42)
+
+	start := time.Now().UTC()
+	audit := &walker.PolitenessAudit{
+		Host:            "test.com",
+		Start:           start,
+		Duration:        time.Minute,
+		CrawlDelay:      2 * time.Second,
+		Requests:        12,
+		TooManyRequests: 1,
+		Events:          []walker.PolitenessEvent{{Time: start, URL: "http://test.com/busy.html", Status: 429}},
+	}
+	var stored *walker.PolitenessAudit
+	ds.On("StorePolitenessAudit", mock.AnythingOfType("*walker.PolitenessAudit")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*walker.PolitenessAudit)
+	}).Return()
+	client.StorePolitenessAudit(ctx, audit)
+	if !reflect.DeepEqual(stored, audit) {
+		t.Errorf("Expected politeness audit %+v to be stored, got %+v", audit, stored)
+	}
+
+	ds.On("ErrorCount").Return(int64(3))
+	if count := client.ErrorCount(); count != 3 {
+		t.Errorf("Expected the server's 3 errors, got %d", count)
+	}
+
+	ds.On("ClaimHost", "test.com").Return(true)
+	ds.On("ClaimHost", "busy.com").Return(false)
+	ds.On("ReleaseHost", "test.com").Return()
+	if !client.ClaimHost(ctx, "test.com") {
+		t.Errorf("Expected test.com to be claimed")
+	}
+	if client.ClaimHost(ctx, "busy.com") {
+		t.Errorf("Expected busy.com not to be claimed")
+	}
+	client.ReleaseHost(ctx, "test.com")
+
+	server.Stop()
+	ds.AssertExpectations(t)
+}
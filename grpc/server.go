@@ -0,0 +1,527 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"code.google.com/p/log4go"
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves the Datastore service, making the calls of remote fetchers
+// on a local datastore.
+//
+// Each remote fetcher gets its own walker.Datastore (created with the
+// function given to NewServer), so it claims hosts and keeps alive like a
+// local fetcher would. Datastores of fetchers that make no calls for
+// fetcher.active_fetchers_ttl are closed.
+//
+// The server speaks TLS if grpc.tls_cert_file is set, and requires
+// grpc.auth_token on every call if it is set. Without both, Serve only accepts
+// a loopback listener.
+type Server struct {
+	UnimplementedDatastoreServer
+
+	newDatastore func() (walker.Datastore, error)
+	model        cassandra.ModelDatastore
+	ttl          time.Duration
+
+	// Whether the server speaks TLS, and the token calls must carry
+	tls   bool
+	token string
+
+	grpcServer *grpc.Server
+
+	mu       sync.Mutex
+	fetchers map[string]*remoteFetcher
+}
+
+// remoteFetcher is the datastore of one remote fetcher
+type remoteFetcher struct {
+	ds walker.Datastore
+
+	// Number of calls in progress, and when the last one finished
+	calls    int
+	lastSeen time.Time
+}
+
+// NewServer creates a Server. newDatastore creates the datastore for each
+// remote fetcher, and model answers InsertLinks and the domain queries. opts
+// are passed on to grpc.NewServer.
+func NewServer(model cassandra.ModelDatastore, newDatastore func() (walker.Datastore, error),
+	opts ...grpc.ServerOption) (*Server, error) {
+
+	ttl, err := time.ParseDuration(walker.Config.Fetcher.ActiveFetchersTTL)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		newDatastore: newDatastore,
+		model:        model,
+		ttl:          ttl,
+		token:        walker.Config.GRPC.AuthToken,
+		fetchers:     map[string]*remoteFetcher{},
+	}
+
+	opts = append([]grpc.ServerOption{
+		// Calls continue the trace of the fetcher that made them
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(s.authorizeUnary),
+		grpc.ChainStreamInterceptor(s.authorizeStream),
+	}, opts...)
+	if walker.Config.GRPC.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(walker.Config.GRPC.TLSCertFile, walker.Config.GRPC.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load TLS certificate: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+		s.tls = true
+	}
+	s.grpcServer = grpc.NewServer(opts...)
+	RegisterDatastoreServer(s.grpcServer, s)
+	return s, nil
+}
+
+// Serve accepts connections on lis, blocking until Stop is called or lis
+// fails. It refuses a listener that is not on a loopback address unless the
+// server uses both TLS and an auth token.
+func (s *Server) Serve(lis net.Listener) error {
+	if !s.tls || s.token == "" {
+		addr, ok := lis.Addr().(*net.TCPAddr)
+		if !ok || !addr.IP.IsLoopback() {
+			return fmt.Errorf("Refusing to serve on %v without grpc.tls_cert_file and grpc.auth_token; "+
+				"listen on a loopback address or set both", lis.Addr())
+		}
+	}
+	log4go.Info("Serving walker datastore on %v", lis.Addr())
+	return s.grpcServer.Serve(lis)
+}
+
+// authorize checks that the call carries the auth token, if one is configured
+func (s *Server) authorize(ctx context.Context) error {
+	if s.token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(v), []byte("Bearer "+s.token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid auth token")
+}
+
+func (s *Server) authorizeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authorizeStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+
+	if err := s.authorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// Stop waits for the calls in progress to finish, then closes the datastores
+// of every remote fetcher
+func (s *Server) Stop() {
+	s.grpcServer.GracefulStop()
+
+	s.mu.Lock()
+	var closing []walker.Datastore
+	for id, f := range s.fetchers {
+		closing = append(closing, f.ds)
+		delete(s.fetchers, id)
+	}
+	s.mu.Unlock()
+	closeDatastores(closing)
+}
+
+// acquire returns the datastore of the given remote fetcher, creating it if
+// needed. release must be called once the call is done with it. Datastores
+// are created and closed without holding s.mu, so other fetchers' calls don't
+// wait on cassandra sessions being opened or closed.
+func (s *Server) acquire(fetcher string) (walker.Datastore, error) {
+	if fetcher == "" {
+		return nil, status.Error(codes.InvalidArgument, "fetcher token is required")
+	}
+
+	s.mu.Lock()
+	expired := s.expireFetchers()
+	f, ok := s.fetchers[fetcher]
+	if ok {
+		f.calls++
+	}
+	s.mu.Unlock()
+	closeDatastores(expired)
+	if ok {
+		return f.ds, nil
+	}
+
+	ds, err := s.newDatastore()
+	if err != nil {
+		log4go.Error("Failed to create datastore for fetcher %v: %v", fetcher, err)
+		return nil, status.Errorf(codes.Unavailable, "failed to create datastore: %v", err)
+	}
+
+	s.mu.Lock()
+	f, ok = s.fetchers[fetcher]
+	if ok {
+		// Another call of this fetcher created one first
+		f.calls++
+	} else {
+		log4go.Info("New remote fetcher %v", fetcher)
+		f = &remoteFetcher{ds: ds, calls: 1}
+		s.fetchers[fetcher] = f
+	}
+	s.mu.Unlock()
+	if ok {
+		ds.Close()
+	}
+	return f.ds, nil
+}
+
+func (s *Server) release(fetcher string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.fetchers[fetcher]; ok {
+		f.calls--
+		f.lastSeen = time.Now()
+	}
+}
+
+// expireFetchers removes the fetchers that have gone quiet, returning their
+// datastores for the caller to close once it releases s.mu. Must be called
+// with s.mu held.
+func (s *Server) expireFetchers() []walker.Datastore {
+	var expired []walker.Datastore
+	for id, f := range s.fetchers {
+		if f.calls == 0 && time.Since(f.lastSeen) > s.ttl {
+			log4go.Info("Remote fetcher %v has gone away, closing its datastore", id)
+			expired = append(expired, f.ds)
+			delete(s.fetchers, id)
+		}
+	}
+	return expired
+}
+
+// closeDatastores closes each of dss
+func closeDatastores(dss []walker.Datastore) {
+	for _, ds := range dss {
+		ds.Close()
+	}
+}
+
+// ClaimNewHost implements DatastoreServer
+func (s *Server) ClaimNewHost(ctx context.Context, req *ClaimNewHostRequest) (*ClaimNewHostResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	return &ClaimNewHostResponse{Host: ds.ClaimNewHost(ctx)}, nil
+}
+
+// UnclaimHost implements DatastoreServer
+func (s *Server) UnclaimHost(ctx context.Context, req *UnclaimHostRequest) (*UnclaimHostResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	ds.UnclaimHost(ctx, req.Host)
+	return &UnclaimHostResponse{}, nil
+}
+
+// LinksForHost implements DatastoreServer
+func (s *Server) LinksForHost(req *LinksForHostRequest, stream Datastore_LinksForHostServer) error {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return err
+	}
+	defer s.release(req.Fetcher)
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	for u := range ds.LinksForHost(ctx, req.Host) {
+		if err := stream.Send(toURL(u)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StoreURLFetchResults implements DatastoreServer
+func (s *Server) StoreURLFetchResults(ctx context.Context, req *StoreURLFetchResultsRequest) (*StoreURLFetchResultsResponse, error) {
+	fr, err := fromFetchResults(req.Results)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad fetch results: %v", err)
+	} else if fr == nil {
+		return nil, status.Error(codes.InvalidArgument, "fetch results are required")
+	}
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	ds.StoreURLFetchResults(ctx, fr)
+	return &StoreURLFetchResultsResponse{}, nil
+}
+
+// StoreParsedURLs implements DatastoreServer
+func (s *Server) StoreParsedURLs(ctx context.Context, req *StoreParsedURLsRequest) (*StoreParsedURLsResponse, error) {
+	urls, err := fromURLs(req.Urls)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad URL: %v", err)
+	}
+	fr, err := fromFetchResults(req.Results)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad fetch results: %v", err)
+	}
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+
+	if bds, ok := ds.(walker.BatchDatastore); ok {
+		bds.StoreParsedURLs(ctx, urls, fr)
+	} else {
+		for _, u := range urls {
+			ds.StoreParsedURL(ctx, u, fr)
+		}
+	}
+	return &StoreParsedURLsResponse{}, nil
+}
+
+// KeepAlive implements DatastoreServer
+func (s *Server) KeepAlive(ctx context.Context, req *KeepAliveRequest) (*KeepAliveResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	if err := ds.KeepAlive(ctx); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &KeepAliveResponse{}, nil
+}
+
+// StoreDomainAssets implements DatastoreServer. It does nothing if the
+// fetcher's datastore is not a walker.AssetDatastore.
+func (s *Server) StoreDomainAssets(ctx context.Context, req *StoreDomainAssetsRequest) (*StoreDomainAssetsResponse, error) {
+	assets, err := fromDomainAssets(req.Assets)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad domain assets: %v", err)
+	}
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	if ads, ok := ds.(walker.AssetDatastore); ok {
+		ads.StoreDomainAssets(ctx, req.Host, assets)
+	}
+	return &StoreDomainAssetsResponse{}, nil
+}
+
+// HostSettings implements DatastoreServer. The settings are unset if the
+// fetcher's datastore is not a walker.HostSettingsDatastore.
+func (s *Server) HostSettings(ctx context.Context, req *HostSettingsRequest) (*HostSettingsResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	resp := &HostSettingsResponse{}
+	if hds, ok := ds.(walker.HostSettingsDatastore); ok {
+		resp.Settings = toHostSettings(hds.HostSettings(ctx, req.Host))
+	}
+	return resp, nil
+}
+
+// RequeueHost implements DatastoreServer. If the fetcher's datastore is not a
+// walker.RequeueDatastore the host is unclaimed instead.
+func (s *Server) RequeueHost(ctx context.Context, req *RequeueHostRequest) (*RequeueHostResponse, error) {
+	links, err := fromURLs(req.Links)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad URL: %v", err)
+	}
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	if rds, ok := ds.(walker.RequeueDatastore); ok {
+		rds.RequeueHost(ctx, req.Host, links)
+	} else {
+		ds.UnclaimHost(ctx, req.Host)
+	}
+	return &RequeueHostResponse{}, nil
+}
+
+// StoreRobotsFingerprint implements DatastoreServer. It does nothing if the
+// fetcher's datastore is not a walker.RobotsDatastore.
+func (s *Server) StoreRobotsFingerprint(ctx context.Context, req *StoreRobotsFingerprintRequest) (*StoreRobotsFingerprintResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	if rds, ok := ds.(walker.RobotsDatastore); ok {
+		rds.StoreRobotsFingerprint(ctx, req.Host, req.Fingerprint)
+	}
+	return &StoreRobotsFingerprintResponse{}, nil
+}
+
+// StorePolitenessAudit implements DatastoreServer. It does nothing if the
+// fetcher's datastore is not a walker.PolitenessDatastore.
+func (s *Server) StorePolitenessAudit(ctx context.Context, req *StorePolitenessAuditRequest) (*StorePolitenessAuditResponse, error) {
+	audit, err := fromPolitenessAudit(req.Audit)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad politeness audit: %v", err)
+	}
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	if pds, ok := ds.(walker.PolitenessDatastore); ok {
+		pds.StorePolitenessAudit(ctx, audit)
+	}
+	return &StorePolitenessAuditResponse{}, nil
+}
+
+// ErrorCount implements DatastoreServer. The count is 0 if the fetcher's
+// datastore is not a walker.ErrorCountingDatastore.
+func (s *Server) ErrorCount(ctx context.Context, req *ErrorCountRequest) (*ErrorCountResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	resp := &ErrorCountResponse{}
+	if eds, ok := ds.(walker.ErrorCountingDatastore); ok {
+		resp.Count = eds.ErrorCount()
+	}
+	return resp, nil
+}
+
+// ClaimHost implements DatastoreServer. If the fetcher's datastore is not a
+// walker.HostClaimingDatastore there is nothing to claim, and the host is
+// reported claimed.
+func (s *Server) ClaimHost(ctx context.Context, req *ClaimHostRequest) (*ClaimHostResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	resp := &ClaimHostResponse{Claimed: true}
+	if cds, ok := ds.(walker.HostClaimingDatastore); ok {
+		resp.Claimed = cds.ClaimHost(ctx, req.Host)
+	}
+	return resp, nil
+}
+
+// ReleaseHost implements DatastoreServer. It does nothing if the fetcher's
+// datastore is not a walker.HostClaimingDatastore.
+func (s *Server) ReleaseHost(ctx context.Context, req *ReleaseHostRequest) (*ReleaseHostResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(req.Fetcher)
+	if cds, ok := ds.(walker.HostClaimingDatastore); ok {
+		cds.ReleaseHost(ctx, req.Host)
+	}
+	return &ReleaseHostResponse{}, nil
+}
+
+// Retire implements DatastoreServer. If the fetcher's datastore is a
+// walker.RetiringDatastore it is retired, then it is closed.
+func (s *Server) Retire(ctx context.Context, req *RetireRequest) (*RetireResponse, error) {
+	ds, err := s.acquire(req.Fetcher)
+	if err != nil {
+		return nil, err
+	}
+	if rds, ok := ds.(walker.RetiringDatastore); ok {
+		err = rds.Retire(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fetchers[req.Fetcher]
+	f.calls--
+	f.lastSeen = time.Now()
+	if f.calls == 0 {
+		log4go.Info("Remote fetcher %v retired, closing its datastore", req.Fetcher)
+		f.ds.Close()
+		delete(s.fetchers, req.Fetcher)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &RetireResponse{}, nil
+}
+
+// InsertLinks implements DatastoreServer
+func (s *Server) InsertLinks(ctx context.Context, req *InsertLinksRequest) (*InsertLinksResponse, error) {
+	resp := &InsertLinksResponse{}
+	for _, err := range s.model.InsertLinks(req.Links, req.ExcludeDomainReason) {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+	return resp, nil
+}
+
+// InsertLinksWithTags implements DatastoreServer
+func (s *Server) InsertLinksWithTags(ctx context.Context, req *InsertLinksWithTagsRequest) (*InsertLinksResponse, error) {
+	resp := &InsertLinksResponse{}
+	for _, err := range s.model.InsertLinksWithTags(req.Links, req.ExcludeDomainReason, req.Tags) {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+	return resp, nil
+}
+
+// FindDomain implements DatastoreServer
+func (s *Server) FindDomain(ctx context.Context, req *FindDomainRequest) (*FindDomainResponse, error) {
+	info, err := s.model.FindDomain(req.Domain)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &FindDomainResponse{Domain: toDomainInfo(info)}, nil
+}
+
+// ListDomains implements DatastoreServer
+func (s *Server) ListDomains(ctx context.Context, req *ListDomainsRequest) (*ListDomainsResponse, error) {
+	infos, err := s.model.ListDomains(cassandra.DQ{
+		Seed:    req.Seed,
+		Limit:   int(req.Limit),
+		Working: req.Working,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &ListDomainsResponse{}
+	for _, info := range infos {
+		resp.Domains = append(resp.Domains, toDomainInfo(info))
+	}
+	return resp, nil
+}
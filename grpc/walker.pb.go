@@ -0,0 +1,4262 @@
+// Protocol for running walker fetchers against a remote datastore, see the
+// grpc package
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v4.25.3
+// source: walker.proto
+
+package grpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Fetchers are identified by a token they pick when they connect, so claims
+// and keep alives made through a shared Server stay separate
+type ClaimNewHostRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+}
+
+func (x *ClaimNewHostRequest) Reset() {
+	*x = ClaimNewHostRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClaimNewHostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimNewHostRequest) ProtoMessage() {}
+
+func (x *ClaimNewHostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimNewHostRequest.ProtoReflect.Descriptor instead.
+func (*ClaimNewHostRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ClaimNewHostRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+type ClaimNewHostResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Empty if no host is available
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *ClaimNewHostResponse) Reset() {
+	*x = ClaimNewHostResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClaimNewHostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimNewHostResponse) ProtoMessage() {}
+
+func (x *ClaimNewHostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimNewHostResponse.ProtoReflect.Descriptor instead.
+func (*ClaimNewHostResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ClaimNewHostResponse) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type UnclaimHostRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Host    string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *UnclaimHostRequest) Reset() {
+	*x = UnclaimHostRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UnclaimHostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnclaimHostRequest) ProtoMessage() {}
+
+func (x *UnclaimHostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnclaimHostRequest.ProtoReflect.Descriptor instead.
+func (*UnclaimHostRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UnclaimHostRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *UnclaimHostRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type UnclaimHostResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *UnclaimHostResponse) Reset() {
+	*x = UnclaimHostResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UnclaimHostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnclaimHostResponse) ProtoMessage() {}
+
+func (x *UnclaimHostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnclaimHostResponse.ProtoReflect.Descriptor instead.
+func (*UnclaimHostResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{3}
+}
+
+type LinksForHostRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Host    string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *LinksForHostRequest) Reset() {
+	*x = LinksForHostRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LinksForHostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinksForHostRequest) ProtoMessage() {}
+
+func (x *LinksForHostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinksForHostRequest.ProtoReflect.Descriptor instead.
+func (*LinksForHostRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LinksForHostRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *LinksForHostRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type URL struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Url         string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	LastCrawled *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=last_crawled,json=lastCrawled,proto3" json:"last_crawled,omitempty"`
+	Nofollow    bool                   `protobuf:"varint,3,opt,name=nofollow,proto3" json:"nofollow,omitempty"`
+	JsRedirect  bool                   `protobuf:"varint,4,opt,name=js_redirect,json=jsRedirect,proto3" json:"js_redirect,omitempty"`
+}
+
+func (x *URL) Reset() {
+	*x = URL{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *URL) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URL) ProtoMessage() {}
+
+func (x *URL) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URL.ProtoReflect.Descriptor instead.
+func (*URL) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *URL) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *URL) GetLastCrawled() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastCrawled
+	}
+	return nil
+}
+
+func (x *URL) GetNofollow() bool {
+	if x != nil {
+		return x.Nofollow
+	}
+	return false
+}
+
+func (x *URL) GetJsRedirect() bool {
+	if x != nil {
+		return x.JsRedirect
+	}
+	return false
+}
+
+type Response struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	StatusCode int32                    `protobuf:"varint,1,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	Header     map[string]*HeaderValues `protobuf:"bytes,2,rep,name=header,proto3" json:"header,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Headers sent with the request, with credentials masked
+	RequestHeader map[string]*HeaderValues `protobuf:"bytes,3,rep,name=request_header,json=requestHeader,proto3" json:"request_header,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *Response) Reset() {
+	*x = Response{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Response) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Response) ProtoMessage() {}
+
+func (x *Response) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Response.ProtoReflect.Descriptor instead.
+func (*Response) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Response) GetStatusCode() int32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *Response) GetHeader() map[string]*HeaderValues {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *Response) GetRequestHeader() map[string]*HeaderValues {
+	if x != nil {
+		return x.RequestHeader
+	}
+	return nil
+}
+
+type HeaderValues struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Values []string `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+}
+
+func (x *HeaderValues) Reset() {
+	*x = HeaderValues{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HeaderValues) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeaderValues) ProtoMessage() {}
+
+func (x *HeaderValues) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeaderValues.ProtoReflect.Descriptor instead.
+func (*HeaderValues) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HeaderValues) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type FetchTiming struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Dns      int64 `protobuf:"varint,1,opt,name=dns,proto3" json:"dns,omitempty"`
+	Connect  int64 `protobuf:"varint,2,opt,name=connect,proto3" json:"connect,omitempty"`
+	Tls      int64 `protobuf:"varint,3,opt,name=tls,proto3" json:"tls,omitempty"`
+	Ttfb     int64 `protobuf:"varint,4,opt,name=ttfb,proto3" json:"ttfb,omitempty"`
+	Transfer int64 `protobuf:"varint,5,opt,name=transfer,proto3" json:"transfer,omitempty"`
+	Bytes    int64 `protobuf:"varint,6,opt,name=bytes,proto3" json:"bytes,omitempty"`
+}
+
+func (x *FetchTiming) Reset() {
+	*x = FetchTiming{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FetchTiming) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchTiming) ProtoMessage() {}
+
+func (x *FetchTiming) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchTiming.ProtoReflect.Descriptor instead.
+func (*FetchTiming) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FetchTiming) GetDns() int64 {
+	if x != nil {
+		return x.Dns
+	}
+	return 0
+}
+
+func (x *FetchTiming) GetConnect() int64 {
+	if x != nil {
+		return x.Connect
+	}
+	return 0
+}
+
+func (x *FetchTiming) GetTls() int64 {
+	if x != nil {
+		return x.Tls
+	}
+	return 0
+}
+
+func (x *FetchTiming) GetTtfb() int64 {
+	if x != nil {
+		return x.Ttfb
+	}
+	return 0
+}
+
+func (x *FetchTiming) GetTransfer() int64 {
+	if x != nil {
+		return x.Transfer
+	}
+	return 0
+}
+
+func (x *FetchTiming) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+type TLSInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version     string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	CipherSuite string                 `protobuf:"bytes,2,opt,name=cipher_suite,json=cipherSuite,proto3" json:"cipher_suite,omitempty"`
+	Issuer      string                 `protobuf:"bytes,3,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	Subject     string                 `protobuf:"bytes,4,opt,name=subject,proto3" json:"subject,omitempty"`
+	NotAfter    *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	Sans        []string               `protobuf:"bytes,6,rep,name=sans,proto3" json:"sans,omitempty"`
+}
+
+func (x *TLSInfo) Reset() {
+	*x = TLSInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TLSInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TLSInfo) ProtoMessage() {}
+
+func (x *TLSInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TLSInfo.ProtoReflect.Descriptor instead.
+func (*TLSInfo) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TLSInfo) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *TLSInfo) GetCipherSuite() string {
+	if x != nil {
+		return x.CipherSuite
+	}
+	return ""
+}
+
+func (x *TLSInfo) GetIssuer() string {
+	if x != nil {
+		return x.Issuer
+	}
+	return ""
+}
+
+func (x *TLSInfo) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *TLSInfo) GetNotAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotAfter
+	}
+	return nil
+}
+
+func (x *TLSInfo) GetSans() []string {
+	if x != nil {
+		return x.Sans
+	}
+	return nil
+}
+
+type FetchResults struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Url              *URL    `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	RedirectedFrom   []*URL  `protobuf:"bytes,2,rep,name=redirected_from,json=redirectedFrom,proto3" json:"redirected_from,omitempty"`
+	RedirectStatuses []int32 `protobuf:"varint,3,rep,packed,name=redirect_statuses,json=redirectStatuses,proto3" json:"redirect_statuses,omitempty"`
+	// Unset if there was a fetch error or the link was excluded by robots.txt
+	Response *Response `protobuf:"bytes,4,opt,name=response,proto3" json:"response,omitempty"`
+	Body     string    `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	// Empty if there was no fetch error. fetch_error_timeout records whether
+	// the error was a timeout, so the datastore can schedule a retry.
+	FetchError         string                 `protobuf:"bytes,6,opt,name=fetch_error,json=fetchError,proto3" json:"fetch_error,omitempty"`
+	FetchErrorTimeout  bool                   `protobuf:"varint,7,opt,name=fetch_error_timeout,json=fetchErrorTimeout,proto3" json:"fetch_error_timeout,omitempty"`
+	FetchTime          *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=fetch_time,json=fetchTime,proto3" json:"fetch_time,omitempty"`
+	ExcludedByRobots   bool                   `protobuf:"varint,9,opt,name=excluded_by_robots,json=excludedByRobots,proto3" json:"excluded_by_robots,omitempty"`
+	SkippedByPrecheck  bool                   `protobuf:"varint,10,opt,name=skipped_by_precheck,json=skippedByPrecheck,proto3" json:"skipped_by_precheck,omitempty"`
+	MetaNoindex        bool                   `protobuf:"varint,11,opt,name=meta_noindex,json=metaNoindex,proto3" json:"meta_noindex,omitempty"`
+	MetaNofollow       bool                   `protobuf:"varint,12,opt,name=meta_nofollow,json=metaNofollow,proto3" json:"meta_nofollow,omitempty"`
+	Title              string                 `protobuf:"bytes,13,opt,name=title,proto3" json:"title,omitempty"`
+	Description        string                 `protobuf:"bytes,14,opt,name=description,proto3" json:"description,omitempty"`
+	MimeType           string                 `protobuf:"bytes,15,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	FnvFingerprint     int64                  `protobuf:"varint,16,opt,name=fnv_fingerprint,json=fnvFingerprint,proto3" json:"fnv_fingerprint,omitempty"`
+	FnvTextFingerprint int64                  `protobuf:"varint,17,opt,name=fnv_text_fingerprint,json=fnvTextFingerprint,proto3" json:"fnv_text_fingerprint,omitempty"`
+	Timing             *FetchTiming           `protobuf:"bytes,18,opt,name=timing,proto3" json:"timing,omitempty"`
+	Tls                *TLSInfo               `protobuf:"bytes,19,opt,name=tls,proto3" json:"tls,omitempty"`
+	// walker.StructuredData encoded as JSON, empty if there was none
+	StructuredData     []byte `protobuf:"bytes,20,opt,name=structured_data,json=structuredData,proto3" json:"structured_data,omitempty"`
+	RobotsRule         string `protobuf:"bytes,21,opt,name=robots_rule,json=robotsRule,proto3" json:"robots_rule,omitempty"`
+	NoArchive          bool   `protobuf:"varint,22,opt,name=no_archive,json=noArchive,proto3" json:"no_archive,omitempty"`
+	NoSnippet          bool   `protobuf:"varint,23,opt,name=no_snippet,json=noSnippet,proto3" json:"no_snippet,omitempty"`
+	DroppedLinks       int32  `protobuf:"varint,24,opt,name=dropped_links,json=droppedLinks,proto3" json:"dropped_links,omitempty"`
+	DuplicateLinks     int32  `protobuf:"varint,25,opt,name=duplicate_links,json=duplicateLinks,proto3" json:"duplicate_links,omitempty"`
+	Sha256Fingerprint  []byte `protobuf:"bytes,26,opt,name=sha256_fingerprint,json=sha256Fingerprint,proto3" json:"sha256_fingerprint,omitempty"`
+	SimhashFingerprint int64  `protobuf:"varint,27,opt,name=simhash_fingerprint,json=simhashFingerprint,proto3" json:"simhash_fingerprint,omitempty"`
+	NotModified        bool   `protobuf:"varint,28,opt,name=not_modified,json=notModified,proto3" json:"not_modified,omitempty"`
+	Truncated          bool   `protobuf:"varint,29,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	Icons              []*URL `protobuf:"bytes,30,rep,name=icons,proto3" json:"icons,omitempty"`
+	// Set if the redirects looped or went past fetcher.max_redirects;
+	// redirect_chain then holds every URL requested
+	RedirectLoop  bool   `protobuf:"varint,31,opt,name=redirect_loop,json=redirectLoop,proto3" json:"redirect_loop,omitempty"`
+	RedirectChain []*URL `protobuf:"bytes,32,rep,name=redirect_chain,json=redirectChain,proto3" json:"redirect_chain,omitempty"`
+	// fetcher.crawl_identity the link was fetched under
+	CrawlIdentity string `protobuf:"bytes,33,opt,name=crawl_identity,json=crawlIdentity,proto3" json:"crawl_identity,omitempty"`
+	// Set if the link was excluded because the host's robots.txt was
+	// unavailable; robots_retry_time is when the host may be crawled again
+	RobotsUnavailable bool                   `protobuf:"varint,34,opt,name=robots_unavailable,json=robotsUnavailable,proto3" json:"robots_unavailable,omitempty"`
+	RobotsRetryTime   *timestamppb.Timestamp `protobuf:"bytes,35,opt,name=robots_retry_time,json=robotsRetryTime,proto3" json:"robots_retry_time,omitempty"`
+	// Set if parsing the page was stopped for having too many tags or
+	// nesting them too deep
+	ParseAborted bool `protobuf:"varint,36,opt,name=parse_aborted,json=parseAborted,proto3" json:"parse_aborted,omitempty"`
+	// The user agent whose robots.txt group was obeyed, and the User-agent
+	// line of the group that applied
+	RobotsAgent      string `protobuf:"bytes,37,opt,name=robots_agent,json=robotsAgent,proto3" json:"robots_agent,omitempty"`
+	RobotsGroupAgent string `protobuf:"bytes,38,opt,name=robots_group_agent,json=robotsGroupAgent,proto3" json:"robots_group_agent,omitempty"`
+	// The Content-Language header of the response, if it had one
+	ContentLanguage string `protobuf:"bytes,39,opt,name=content_language,json=contentLanguage,proto3" json:"content_language,omitempty"`
+	// The Content-Type header the server sent, if it was replaced with the
+	// sniffed type
+	DeclaredContentType string `protobuf:"bytes,40,opt,name=declared_content_type,json=declaredContentType,proto3" json:"declared_content_type,omitempty"`
+}
+
+func (x *FetchResults) Reset() {
+	*x = FetchResults{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FetchResults) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchResults) ProtoMessage() {}
+
+func (x *FetchResults) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchResults.ProtoReflect.Descriptor instead.
+func (*FetchResults) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FetchResults) GetUrl() *URL {
+	if x != nil {
+		return x.Url
+	}
+	return nil
+}
+
+func (x *FetchResults) GetRedirectedFrom() []*URL {
+	if x != nil {
+		return x.RedirectedFrom
+	}
+	return nil
+}
+
+func (x *FetchResults) GetRedirectStatuses() []int32 {
+	if x != nil {
+		return x.RedirectStatuses
+	}
+	return nil
+}
+
+func (x *FetchResults) GetResponse() *Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *FetchResults) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *FetchResults) GetFetchError() string {
+	if x != nil {
+		return x.FetchError
+	}
+	return ""
+}
+
+func (x *FetchResults) GetFetchErrorTimeout() bool {
+	if x != nil {
+		return x.FetchErrorTimeout
+	}
+	return false
+}
+
+func (x *FetchResults) GetFetchTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FetchTime
+	}
+	return nil
+}
+
+func (x *FetchResults) GetExcludedByRobots() bool {
+	if x != nil {
+		return x.ExcludedByRobots
+	}
+	return false
+}
+
+func (x *FetchResults) GetSkippedByPrecheck() bool {
+	if x != nil {
+		return x.SkippedByPrecheck
+	}
+	return false
+}
+
+func (x *FetchResults) GetMetaNoindex() bool {
+	if x != nil {
+		return x.MetaNoindex
+	}
+	return false
+}
+
+func (x *FetchResults) GetMetaNofollow() bool {
+	if x != nil {
+		return x.MetaNofollow
+	}
+	return false
+}
+
+func (x *FetchResults) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *FetchResults) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *FetchResults) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *FetchResults) GetFnvFingerprint() int64 {
+	if x != nil {
+		return x.FnvFingerprint
+	}
+	return 0
+}
+
+func (x *FetchResults) GetFnvTextFingerprint() int64 {
+	if x != nil {
+		return x.FnvTextFingerprint
+	}
+	return 0
+}
+
+func (x *FetchResults) GetTiming() *FetchTiming {
+	if x != nil {
+		return x.Timing
+	}
+	return nil
+}
+
+func (x *FetchResults) GetTls() *TLSInfo {
+	if x != nil {
+		return x.Tls
+	}
+	return nil
+}
+
+func (x *FetchResults) GetStructuredData() []byte {
+	if x != nil {
+		return x.StructuredData
+	}
+	return nil
+}
+
+func (x *FetchResults) GetRobotsRule() string {
+	if x != nil {
+		return x.RobotsRule
+	}
+	return ""
+}
+
+func (x *FetchResults) GetNoArchive() bool {
+	if x != nil {
+		return x.NoArchive
+	}
+	return false
+}
+
+func (x *FetchResults) GetNoSnippet() bool {
+	if x != nil {
+		return x.NoSnippet
+	}
+	return false
+}
+
+func (x *FetchResults) GetDroppedLinks() int32 {
+	if x != nil {
+		return x.DroppedLinks
+	}
+	return 0
+}
+
+func (x *FetchResults) GetDuplicateLinks() int32 {
+	if x != nil {
+		return x.DuplicateLinks
+	}
+	return 0
+}
+
+func (x *FetchResults) GetSha256Fingerprint() []byte {
+	if x != nil {
+		return x.Sha256Fingerprint
+	}
+	return nil
+}
+
+func (x *FetchResults) GetSimhashFingerprint() int64 {
+	if x != nil {
+		return x.SimhashFingerprint
+	}
+	return 0
+}
+
+func (x *FetchResults) GetNotModified() bool {
+	if x != nil {
+		return x.NotModified
+	}
+	return false
+}
+
+func (x *FetchResults) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *FetchResults) GetIcons() []*URL {
+	if x != nil {
+		return x.Icons
+	}
+	return nil
+}
+
+func (x *FetchResults) GetRedirectLoop() bool {
+	if x != nil {
+		return x.RedirectLoop
+	}
+	return false
+}
+
+func (x *FetchResults) GetRedirectChain() []*URL {
+	if x != nil {
+		return x.RedirectChain
+	}
+	return nil
+}
+
+func (x *FetchResults) GetCrawlIdentity() string {
+	if x != nil {
+		return x.CrawlIdentity
+	}
+	return ""
+}
+
+func (x *FetchResults) GetRobotsUnavailable() bool {
+	if x != nil {
+		return x.RobotsUnavailable
+	}
+	return false
+}
+
+func (x *FetchResults) GetRobotsRetryTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RobotsRetryTime
+	}
+	return nil
+}
+
+func (x *FetchResults) GetParseAborted() bool {
+	if x != nil {
+		return x.ParseAborted
+	}
+	return false
+}
+
+func (x *FetchResults) GetRobotsAgent() string {
+	if x != nil {
+		return x.RobotsAgent
+	}
+	return ""
+}
+
+func (x *FetchResults) GetRobotsGroupAgent() string {
+	if x != nil {
+		return x.RobotsGroupAgent
+	}
+	return ""
+}
+
+func (x *FetchResults) GetContentLanguage() string {
+	if x != nil {
+		return x.ContentLanguage
+	}
+	return ""
+}
+
+func (x *FetchResults) GetDeclaredContentType() string {
+	if x != nil {
+		return x.DeclaredContentType
+	}
+	return ""
+}
+
+type StoreURLFetchResultsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string        `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Results *FetchResults `protobuf:"bytes,2,opt,name=results,proto3" json:"results,omitempty"`
+}
+
+func (x *StoreURLFetchResultsRequest) Reset() {
+	*x = StoreURLFetchResultsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreURLFetchResultsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreURLFetchResultsRequest) ProtoMessage() {}
+
+func (x *StoreURLFetchResultsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreURLFetchResultsRequest.ProtoReflect.Descriptor instead.
+func (*StoreURLFetchResultsRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *StoreURLFetchResultsRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *StoreURLFetchResultsRequest) GetResults() *FetchResults {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type StoreURLFetchResultsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *StoreURLFetchResultsResponse) Reset() {
+	*x = StoreURLFetchResultsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreURLFetchResultsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreURLFetchResultsResponse) ProtoMessage() {}
+
+func (x *StoreURLFetchResultsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreURLFetchResultsResponse.ProtoReflect.Descriptor instead.
+func (*StoreURLFetchResultsResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{12}
+}
+
+type StoreParsedURLsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Urls    []*URL `protobuf:"bytes,2,rep,name=urls,proto3" json:"urls,omitempty"`
+	// The fetch the links were parsed from, unset if they are being seeded
+	Results *FetchResults `protobuf:"bytes,3,opt,name=results,proto3" json:"results,omitempty"`
+}
+
+func (x *StoreParsedURLsRequest) Reset() {
+	*x = StoreParsedURLsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreParsedURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreParsedURLsRequest) ProtoMessage() {}
+
+func (x *StoreParsedURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreParsedURLsRequest.ProtoReflect.Descriptor instead.
+func (*StoreParsedURLsRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *StoreParsedURLsRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *StoreParsedURLsRequest) GetUrls() []*URL {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *StoreParsedURLsRequest) GetResults() *FetchResults {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type StoreParsedURLsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *StoreParsedURLsResponse) Reset() {
+	*x = StoreParsedURLsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreParsedURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreParsedURLsResponse) ProtoMessage() {}
+
+func (x *StoreParsedURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreParsedURLsResponse.ProtoReflect.Descriptor instead.
+func (*StoreParsedURLsResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{14}
+}
+
+type KeepAliveRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+}
+
+func (x *KeepAliveRequest) Reset() {
+	*x = KeepAliveRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KeepAliveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeepAliveRequest) ProtoMessage() {}
+
+func (x *KeepAliveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeepAliveRequest.ProtoReflect.Descriptor instead.
+func (*KeepAliveRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *KeepAliveRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+type KeepAliveResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *KeepAliveResponse) Reset() {
+	*x = KeepAliveResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KeepAliveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeepAliveResponse) ProtoMessage() {}
+
+func (x *KeepAliveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeepAliveResponse.ProtoReflect.Descriptor instead.
+func (*KeepAliveResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{16}
+}
+
+type RetireRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+}
+
+func (x *RetireRequest) Reset() {
+	*x = RetireRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RetireRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetireRequest) ProtoMessage() {}
+
+func (x *RetireRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetireRequest.ProtoReflect.Descriptor instead.
+func (*RetireRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *RetireRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+type RetireResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *RetireResponse) Reset() {
+	*x = RetireResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RetireResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetireResponse) ProtoMessage() {}
+
+func (x *RetireResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetireResponse.ProtoReflect.Descriptor instead.
+func (*RetireResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{18}
+}
+
+type DomainAssets struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FaviconUrl         *URL                   `protobuf:"bytes,1,opt,name=favicon_url,json=faviconUrl,proto3" json:"favicon_url,omitempty"`
+	FaviconTime        *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=favicon_time,json=faviconTime,proto3" json:"favicon_time,omitempty"`
+	FaviconStatus      int32                  `protobuf:"varint,3,opt,name=favicon_status,json=faviconStatus,proto3" json:"favicon_status,omitempty"`
+	FaviconMimeType    string                 `protobuf:"bytes,4,opt,name=favicon_mime_type,json=faviconMimeType,proto3" json:"favicon_mime_type,omitempty"`
+	FaviconFingerprint int64                  `protobuf:"varint,5,opt,name=favicon_fingerprint,json=faviconFingerprint,proto3" json:"favicon_fingerprint,omitempty"`
+}
+
+func (x *DomainAssets) Reset() {
+	*x = DomainAssets{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DomainAssets) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DomainAssets) ProtoMessage() {}
+
+func (x *DomainAssets) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DomainAssets.ProtoReflect.Descriptor instead.
+func (*DomainAssets) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *DomainAssets) GetFaviconUrl() *URL {
+	if x != nil {
+		return x.FaviconUrl
+	}
+	return nil
+}
+
+func (x *DomainAssets) GetFaviconTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FaviconTime
+	}
+	return nil
+}
+
+func (x *DomainAssets) GetFaviconStatus() int32 {
+	if x != nil {
+		return x.FaviconStatus
+	}
+	return 0
+}
+
+func (x *DomainAssets) GetFaviconMimeType() string {
+	if x != nil {
+		return x.FaviconMimeType
+	}
+	return ""
+}
+
+func (x *DomainAssets) GetFaviconFingerprint() int64 {
+	if x != nil {
+		return x.FaviconFingerprint
+	}
+	return 0
+}
+
+type StoreDomainAssetsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string        `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Host    string        `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Assets  *DomainAssets `protobuf:"bytes,3,opt,name=assets,proto3" json:"assets,omitempty"`
+}
+
+func (x *StoreDomainAssetsRequest) Reset() {
+	*x = StoreDomainAssetsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreDomainAssetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreDomainAssetsRequest) ProtoMessage() {}
+
+func (x *StoreDomainAssetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreDomainAssetsRequest.ProtoReflect.Descriptor instead.
+func (*StoreDomainAssetsRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *StoreDomainAssetsRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *StoreDomainAssetsRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *StoreDomainAssetsRequest) GetAssets() *DomainAssets {
+	if x != nil {
+		return x.Assets
+	}
+	return nil
+}
+
+type StoreDomainAssetsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *StoreDomainAssetsResponse) Reset() {
+	*x = StoreDomainAssetsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreDomainAssetsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreDomainAssetsResponse) ProtoMessage() {}
+
+func (x *StoreDomainAssetsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreDomainAssetsResponse.ProtoReflect.Descriptor instead.
+func (*StoreDomainAssetsResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{21}
+}
+
+type HostSettingsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Host    string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *HostSettingsRequest) Reset() {
+	*x = HostSettingsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HostSettingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostSettingsRequest) ProtoMessage() {}
+
+func (x *HostSettingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostSettingsRequest.ProtoReflect.Descriptor instead.
+func (*HostSettingsRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *HostSettingsRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *HostSettingsRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type HostSettings struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Nanoseconds, 0 if not overridden
+	CrawlDelay int64 `protobuf:"varint,1,opt,name=crawl_delay,json=crawlDelay,proto3" json:"crawl_delay,omitempty"`
+	// Empty if not overridden
+	UserAgent string `protobuf:"bytes,2,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+}
+
+func (x *HostSettings) Reset() {
+	*x = HostSettings{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HostSettings) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostSettings) ProtoMessage() {}
+
+func (x *HostSettings) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostSettings.ProtoReflect.Descriptor instead.
+func (*HostSettings) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *HostSettings) GetCrawlDelay() int64 {
+	if x != nil {
+		return x.CrawlDelay
+	}
+	return 0
+}
+
+func (x *HostSettings) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+type HostSettingsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Unset if the host overrides nothing
+	Settings *HostSettings `protobuf:"bytes,1,opt,name=settings,proto3" json:"settings,omitempty"`
+}
+
+func (x *HostSettingsResponse) Reset() {
+	*x = HostSettingsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HostSettingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostSettingsResponse) ProtoMessage() {}
+
+func (x *HostSettingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostSettingsResponse.ProtoReflect.Descriptor instead.
+func (*HostSettingsResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *HostSettingsResponse) GetSettings() *HostSettings {
+	if x != nil {
+		return x.Settings
+	}
+	return nil
+}
+
+type RequeueHostRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Host    string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	// The links left uncrawled in the host's segment
+	Links []*URL `protobuf:"bytes,3,rep,name=links,proto3" json:"links,omitempty"`
+}
+
+func (x *RequeueHostRequest) Reset() {
+	*x = RequeueHostRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RequeueHostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequeueHostRequest) ProtoMessage() {}
+
+func (x *RequeueHostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequeueHostRequest.ProtoReflect.Descriptor instead.
+func (*RequeueHostRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *RequeueHostRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *RequeueHostRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *RequeueHostRequest) GetLinks() []*URL {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+type RequeueHostResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *RequeueHostResponse) Reset() {
+	*x = RequeueHostResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RequeueHostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequeueHostResponse) ProtoMessage() {}
+
+func (x *RequeueHostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequeueHostResponse.ProtoReflect.Descriptor instead.
+func (*RequeueHostResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{26}
+}
+
+type StoreRobotsFingerprintRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Host    string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	// 0 if the host has no robots.txt
+	Fingerprint int64 `protobuf:"varint,3,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+}
+
+func (x *StoreRobotsFingerprintRequest) Reset() {
+	*x = StoreRobotsFingerprintRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreRobotsFingerprintRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreRobotsFingerprintRequest) ProtoMessage() {}
+
+func (x *StoreRobotsFingerprintRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreRobotsFingerprintRequest.ProtoReflect.Descriptor instead.
+func (*StoreRobotsFingerprintRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *StoreRobotsFingerprintRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *StoreRobotsFingerprintRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *StoreRobotsFingerprintRequest) GetFingerprint() int64 {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return 0
+}
+
+type StoreRobotsFingerprintResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *StoreRobotsFingerprintResponse) Reset() {
+	*x = StoreRobotsFingerprintResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StoreRobotsFingerprintResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreRobotsFingerprintResponse) ProtoMessage() {}
+
+func (x *StoreRobotsFingerprintResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreRobotsFingerprintResponse.ProtoReflect.Descriptor instead.
+func (*StoreRobotsFingerprintResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{28}
+}
+
+type PolitenessEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Time   *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Url    string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Status int32                  `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (x *PolitenessEvent) Reset() {
+	*x = PolitenessEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PolitenessEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolitenessEvent) ProtoMessage() {}
+
+func (x *PolitenessEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolitenessEvent.ProtoReflect.Descriptor instead.
+func (*PolitenessEvent) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *PolitenessEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *PolitenessEvent) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *PolitenessEvent) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+type PolitenessAudit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Host  string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Start *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=start,proto3" json:"start,omitempty"`
+	// Nanoseconds
+	Duration           int64              `protobuf:"varint,3,opt,name=duration,proto3" json:"duration,omitempty"`
+	CrawlDelay         int64              `protobuf:"varint,4,opt,name=crawl_delay,json=crawlDelay,proto3" json:"crawl_delay,omitempty"`
+	Requests           int32              `protobuf:"varint,5,opt,name=requests,proto3" json:"requests,omitempty"`
+	TooManyRequests    int32              `protobuf:"varint,6,opt,name=too_many_requests,json=tooManyRequests,proto3" json:"too_many_requests,omitempty"`
+	ServiceUnavailable int32              `protobuf:"varint,7,opt,name=service_unavailable,json=serviceUnavailable,proto3" json:"service_unavailable,omitempty"`
+	Events             []*PolitenessEvent `protobuf:"bytes,8,rep,name=events,proto3" json:"events,omitempty"`
+}
+
+func (x *PolitenessAudit) Reset() {
+	*x = PolitenessAudit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PolitenessAudit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolitenessAudit) ProtoMessage() {}
+
+func (x *PolitenessAudit) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolitenessAudit.ProtoReflect.Descriptor instead.
+func (*PolitenessAudit) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *PolitenessAudit) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *PolitenessAudit) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *PolitenessAudit) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *PolitenessAudit) GetCrawlDelay() int64 {
+	if x != nil {
+		return x.CrawlDelay
+	}
+	return 0
+}
+
+func (x *PolitenessAudit) GetRequests() int32 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *PolitenessAudit) GetTooManyRequests() int32 {
+	if x != nil {
+		return x.TooManyRequests
+	}
+	return 0
+}
+
+func (x *PolitenessAudit) GetServiceUnavailable() int32 {
+	if x != nil {
+		return x.ServiceUnavailable
+	}
+	return 0
+}
+
+func (x *PolitenessAudit) GetEvents() []*PolitenessEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type StorePolitenessAuditRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string           `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Audit   *PolitenessAudit `protobuf:"bytes,2,opt,name=audit,proto3" json:"audit,omitempty"`
+}
+
+func (x *StorePolitenessAuditRequest) Reset() {
+	*x = StorePolitenessAuditRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[31]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StorePolitenessAuditRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StorePolitenessAuditRequest) ProtoMessage() {}
+
+func (x *StorePolitenessAuditRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[31]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StorePolitenessAuditRequest.ProtoReflect.Descriptor instead.
+func (*StorePolitenessAuditRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *StorePolitenessAuditRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *StorePolitenessAuditRequest) GetAudit() *PolitenessAudit {
+	if x != nil {
+		return x.Audit
+	}
+	return nil
+}
+
+type StorePolitenessAuditResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *StorePolitenessAuditResponse) Reset() {
+	*x = StorePolitenessAuditResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[32]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StorePolitenessAuditResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StorePolitenessAuditResponse) ProtoMessage() {}
+
+func (x *StorePolitenessAuditResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[32]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StorePolitenessAuditResponse.ProtoReflect.Descriptor instead.
+func (*StorePolitenessAuditResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{32}
+}
+
+type ErrorCountRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+}
+
+func (x *ErrorCountRequest) Reset() {
+	*x = ErrorCountRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ErrorCountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorCountRequest) ProtoMessage() {}
+
+func (x *ErrorCountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorCountRequest.ProtoReflect.Descriptor instead.
+func (*ErrorCountRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *ErrorCountRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+type ErrorCountResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 0 if the datastore does not count its errors
+	Count int64 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (x *ErrorCountResponse) Reset() {
+	*x = ErrorCountResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[34]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ErrorCountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorCountResponse) ProtoMessage() {}
+
+func (x *ErrorCountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[34]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorCountResponse.ProtoReflect.Descriptor instead.
+func (*ErrorCountResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *ErrorCountResponse) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type ClaimHostRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Host    string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *ClaimHostRequest) Reset() {
+	*x = ClaimHostRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClaimHostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimHostRequest) ProtoMessage() {}
+
+func (x *ClaimHostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimHostRequest.ProtoReflect.Descriptor instead.
+func (*ClaimHostRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *ClaimHostRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *ClaimHostRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type ClaimHostResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// False if another crawler has the host claimed
+	Claimed bool `protobuf:"varint,1,opt,name=claimed,proto3" json:"claimed,omitempty"`
+}
+
+func (x *ClaimHostResponse) Reset() {
+	*x = ClaimHostResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[36]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClaimHostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimHostResponse) ProtoMessage() {}
+
+func (x *ClaimHostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[36]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimHostResponse.ProtoReflect.Descriptor instead.
+func (*ClaimHostResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *ClaimHostResponse) GetClaimed() bool {
+	if x != nil {
+		return x.Claimed
+	}
+	return false
+}
+
+type ReleaseHostRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fetcher string `protobuf:"bytes,1,opt,name=fetcher,proto3" json:"fetcher,omitempty"`
+	Host    string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *ReleaseHostRequest) Reset() {
+	*x = ReleaseHostRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReleaseHostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReleaseHostRequest) ProtoMessage() {}
+
+func (x *ReleaseHostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReleaseHostRequest.ProtoReflect.Descriptor instead.
+func (*ReleaseHostRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *ReleaseHostRequest) GetFetcher() string {
+	if x != nil {
+		return x.Fetcher
+	}
+	return ""
+}
+
+func (x *ReleaseHostRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type ReleaseHostResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ReleaseHostResponse) Reset() {
+	*x = ReleaseHostResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReleaseHostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReleaseHostResponse) ProtoMessage() {}
+
+func (x *ReleaseHostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReleaseHostResponse.ProtoReflect.Descriptor instead.
+func (*ReleaseHostResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{38}
+}
+
+type InsertLinksRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Links               []string `protobuf:"bytes,1,rep,name=links,proto3" json:"links,omitempty"`
+	ExcludeDomainReason string   `protobuf:"bytes,2,opt,name=exclude_domain_reason,json=excludeDomainReason,proto3" json:"exclude_domain_reason,omitempty"`
+}
+
+func (x *InsertLinksRequest) Reset() {
+	*x = InsertLinksRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InsertLinksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertLinksRequest) ProtoMessage() {}
+
+func (x *InsertLinksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertLinksRequest.ProtoReflect.Descriptor instead.
+func (*InsertLinksRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *InsertLinksRequest) GetLinks() []string {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *InsertLinksRequest) GetExcludeDomainReason() string {
+	if x != nil {
+		return x.ExcludeDomainReason
+	}
+	return ""
+}
+
+type InsertLinksResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Errors []string `protobuf:"bytes,1,rep,name=errors,proto3" json:"errors,omitempty"`
+}
+
+func (x *InsertLinksResponse) Reset() {
+	*x = InsertLinksResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InsertLinksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertLinksResponse) ProtoMessage() {}
+
+func (x *InsertLinksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertLinksResponse.ProtoReflect.Descriptor instead.
+func (*InsertLinksResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *InsertLinksResponse) GetErrors() []string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type InsertLinksWithTagsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Links               []string `protobuf:"bytes,1,rep,name=links,proto3" json:"links,omitempty"`
+	ExcludeDomainReason string   `protobuf:"bytes,2,opt,name=exclude_domain_reason,json=excludeDomainReason,proto3" json:"exclude_domain_reason,omitempty"`
+	Tags                []string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+}
+
+func (x *InsertLinksWithTagsRequest) Reset() {
+	*x = InsertLinksWithTagsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[41]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InsertLinksWithTagsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertLinksWithTagsRequest) ProtoMessage() {}
+
+func (x *InsertLinksWithTagsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[41]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertLinksWithTagsRequest.ProtoReflect.Descriptor instead.
+func (*InsertLinksWithTagsRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *InsertLinksWithTagsRequest) GetLinks() []string {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *InsertLinksWithTagsRequest) GetExcludeDomainReason() string {
+	if x != nil {
+		return x.ExcludeDomainReason
+	}
+	return ""
+}
+
+func (x *InsertLinksWithTagsRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type DomainInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Domain               string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Excluded             bool                   `protobuf:"varint,2,opt,name=excluded,proto3" json:"excluded,omitempty"`
+	ExcludeReason        string                 `protobuf:"bytes,3,opt,name=exclude_reason,json=excludeReason,proto3" json:"exclude_reason,omitempty"`
+	Paused               bool                   `protobuf:"varint,4,opt,name=paused,proto3" json:"paused,omitempty"`
+	SampleThreshold      int32                  `protobuf:"varint,5,opt,name=sample_threshold,json=sampleThreshold,proto3" json:"sample_threshold,omitempty"`
+	SamplePercent        float32                `protobuf:"fixed32,6,opt,name=sample_percent,json=samplePercent,proto3" json:"sample_percent,omitempty"`
+	ByteBudget           int64                  `protobuf:"varint,7,opt,name=byte_budget,json=byteBudget,proto3" json:"byte_budget,omitempty"`
+	ClaimTime            *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=claim_time,json=claimTime,proto3" json:"claim_time,omitempty"`
+	ClaimToken           string                 `protobuf:"bytes,9,opt,name=claim_token,json=claimToken,proto3" json:"claim_token,omitempty"`
+	NumberLinksTotal     int32                  `protobuf:"varint,10,opt,name=number_links_total,json=numberLinksTotal,proto3" json:"number_links_total,omitempty"`
+	NumberLinksQueued    int32                  `protobuf:"varint,11,opt,name=number_links_queued,json=numberLinksQueued,proto3" json:"number_links_queued,omitempty"`
+	NumberLinksUncrawled int32                  `protobuf:"varint,12,opt,name=number_links_uncrawled,json=numberLinksUncrawled,proto3" json:"number_links_uncrawled,omitempty"`
+	Priority             int32                  `protobuf:"varint,13,opt,name=priority,proto3" json:"priority,omitempty"`
+	CrawlWindow          string                 `protobuf:"bytes,14,opt,name=crawl_window,json=crawlWindow,proto3" json:"crawl_window,omitempty"`
+	CrawlTimezone        string                 `protobuf:"bytes,15,opt,name=crawl_timezone,json=crawlTimezone,proto3" json:"crawl_timezone,omitempty"`
+	FaviconUrl           string                 `protobuf:"bytes,16,opt,name=favicon_url,json=faviconUrl,proto3" json:"favicon_url,omitempty"`
+	FaviconTime          *timestamppb.Timestamp `protobuf:"bytes,17,opt,name=favicon_time,json=faviconTime,proto3" json:"favicon_time,omitempty"`
+	FaviconStatus        int32                  `protobuf:"varint,18,opt,name=favicon_status,json=faviconStatus,proto3" json:"favicon_status,omitempty"`
+	FaviconMimeType      string                 `protobuf:"bytes,19,opt,name=favicon_mime_type,json=faviconMimeType,proto3" json:"favicon_mime_type,omitempty"`
+	FaviconFingerprint   int64                  `protobuf:"varint,20,opt,name=favicon_fingerprint,json=faviconFingerprint,proto3" json:"favicon_fingerprint,omitempty"`
+}
+
+func (x *DomainInfo) Reset() {
+	*x = DomainInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[42]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DomainInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DomainInfo) ProtoMessage() {}
+
+func (x *DomainInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[42]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DomainInfo.ProtoReflect.Descriptor instead.
+func (*DomainInfo) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *DomainInfo) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *DomainInfo) GetExcluded() bool {
+	if x != nil {
+		return x.Excluded
+	}
+	return false
+}
+
+func (x *DomainInfo) GetExcludeReason() string {
+	if x != nil {
+		return x.ExcludeReason
+	}
+	return ""
+}
+
+func (x *DomainInfo) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *DomainInfo) GetSampleThreshold() int32 {
+	if x != nil {
+		return x.SampleThreshold
+	}
+	return 0
+}
+
+func (x *DomainInfo) GetSamplePercent() float32 {
+	if x != nil {
+		return x.SamplePercent
+	}
+	return 0
+}
+
+func (x *DomainInfo) GetByteBudget() int64 {
+	if x != nil {
+		return x.ByteBudget
+	}
+	return 0
+}
+
+func (x *DomainInfo) GetClaimTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ClaimTime
+	}
+	return nil
+}
+
+func (x *DomainInfo) GetClaimToken() string {
+	if x != nil {
+		return x.ClaimToken
+	}
+	return ""
+}
+
+func (x *DomainInfo) GetNumberLinksTotal() int32 {
+	if x != nil {
+		return x.NumberLinksTotal
+	}
+	return 0
+}
+
+func (x *DomainInfo) GetNumberLinksQueued() int32 {
+	if x != nil {
+		return x.NumberLinksQueued
+	}
+	return 0
+}
+
+func (x *DomainInfo) GetNumberLinksUncrawled() int32 {
+	if x != nil {
+		return x.NumberLinksUncrawled
+	}
+	return 0
+}
+
+func (x *DomainInfo) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *DomainInfo) GetCrawlWindow() string {
+	if x != nil {
+		return x.CrawlWindow
+	}
+	return ""
+}
+
+func (x *DomainInfo) GetCrawlTimezone() string {
+	if x != nil {
+		return x.CrawlTimezone
+	}
+	return ""
+}
+
+func (x *DomainInfo) GetFaviconUrl() string {
+	if x != nil {
+		return x.FaviconUrl
+	}
+	return ""
+}
+
+func (x *DomainInfo) GetFaviconTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FaviconTime
+	}
+	return nil
+}
+
+func (x *DomainInfo) GetFaviconStatus() int32 {
+	if x != nil {
+		return x.FaviconStatus
+	}
+	return 0
+}
+
+func (x *DomainInfo) GetFaviconMimeType() string {
+	if x != nil {
+		return x.FaviconMimeType
+	}
+	return ""
+}
+
+func (x *DomainInfo) GetFaviconFingerprint() int64 {
+	if x != nil {
+		return x.FaviconFingerprint
+	}
+	return 0
+}
+
+type FindDomainRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Domain string `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+}
+
+func (x *FindDomainRequest) Reset() {
+	*x = FindDomainRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[43]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FindDomainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindDomainRequest) ProtoMessage() {}
+
+func (x *FindDomainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[43]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindDomainRequest.ProtoReflect.Descriptor instead.
+func (*FindDomainRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *FindDomainRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+type FindDomainResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Unset if the domain does not exist
+	Domain *DomainInfo `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+}
+
+func (x *FindDomainResponse) Reset() {
+	*x = FindDomainResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[44]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FindDomainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindDomainResponse) ProtoMessage() {}
+
+func (x *FindDomainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[44]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindDomainResponse.ProtoReflect.Descriptor instead.
+func (*FindDomainResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *FindDomainResponse) GetDomain() *DomainInfo {
+	if x != nil {
+		return x.Domain
+	}
+	return nil
+}
+
+type ListDomainsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seed    string `protobuf:"bytes,1,opt,name=seed,proto3" json:"seed,omitempty"`
+	Limit   int32  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Working bool   `protobuf:"varint,3,opt,name=working,proto3" json:"working,omitempty"`
+}
+
+func (x *ListDomainsRequest) Reset() {
+	*x = ListDomainsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[45]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListDomainsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDomainsRequest) ProtoMessage() {}
+
+func (x *ListDomainsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[45]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDomainsRequest.ProtoReflect.Descriptor instead.
+func (*ListDomainsRequest) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *ListDomainsRequest) GetSeed() string {
+	if x != nil {
+		return x.Seed
+	}
+	return ""
+}
+
+func (x *ListDomainsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListDomainsRequest) GetWorking() bool {
+	if x != nil {
+		return x.Working
+	}
+	return false
+}
+
+type ListDomainsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Domains []*DomainInfo `protobuf:"bytes,1,rep,name=domains,proto3" json:"domains,omitempty"`
+}
+
+func (x *ListDomainsResponse) Reset() {
+	*x = ListDomainsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_walker_proto_msgTypes[46]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListDomainsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDomainsResponse) ProtoMessage() {}
+
+func (x *ListDomainsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_walker_proto_msgTypes[46]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDomainsResponse.ProtoReflect.Descriptor instead.
+func (*ListDomainsResponse) Descriptor() ([]byte, []int) {
+	return file_walker_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ListDomainsResponse) GetDomains() []*DomainInfo {
+	if x != nil {
+		return x.Domains
+	}
+	return nil
+}
+
+var File_walker_proto protoreflect.FileDescriptor
+
+var file_walker_proto_rawDesc = []byte{
+	0x0a, 0x0c, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06,
+	0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x2f, 0x0a, 0x13, 0x43, 0x6c, 0x61, 0x69, 0x6d,
+	0x4e, 0x65, 0x77, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18,
+	0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x22, 0x2a, 0x0a, 0x14, 0x43, 0x6c, 0x61, 0x69,
+	0x6d, 0x4e, 0x65, 0x77, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x68, 0x6f, 0x73, 0x74, 0x22, 0x42, 0x0a, 0x12, 0x55, 0x6e, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x48,
+	0x6f, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65,
+	0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74,
+	0x63, 0x68, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x22, 0x15, 0x0a, 0x13, 0x55, 0x6e, 0x63, 0x6c,
+	0x61, 0x69, 0x6d, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
+	0x43, 0x0a, 0x13, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x46, 0x6f, 0x72, 0x48, 0x6f, 0x73, 0x74, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72,
+	0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x68, 0x6f, 0x73, 0x74, 0x22, 0x93, 0x01, 0x0a, 0x03, 0x55, 0x52, 0x4c, 0x12, 0x10, 0x0a, 0x03,
+	0x75, 0x72, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75, 0x72, 0x6c, 0x12, 0x3d,
+	0x0a, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x65, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x43, 0x72, 0x61, 0x77, 0x6c, 0x65, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x6e, 0x6f, 0x66, 0x6f, 0x6c, 0x6c, 0x6f, 0x77, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x08, 0x6e, 0x6f, 0x66, 0x6f, 0x6c, 0x6c, 0x6f, 0x77, 0x12, 0x1f, 0x0a, 0x0b, 0x6a, 0x73, 0x5f,
+	0x72, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a,
+	0x6a, 0x73, 0x52, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x22, 0xd6, 0x02, 0x0a, 0x08, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x34, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65,
+	0x72, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x4a,
+	0x0a, 0x0e, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0d, 0x72, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x1a, 0x4f, 0x0a, 0x0b, 0x48, 0x65,
+	0x61, 0x64, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2a, 0x0a, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x77, 0x61, 0x6c,
+	0x6b, 0x65, 0x72, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x56, 0x0a, 0x12, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x2a, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x14, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x48, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x22, 0x26, 0x0a, 0x0c, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0x91, 0x01, 0x0a, 0x0b,
+	0x46, 0x65, 0x74, 0x63, 0x68, 0x54, 0x69, 0x6d, 0x69, 0x6e, 0x67, 0x12, 0x10, 0x0a, 0x03, 0x64,
+	0x6e, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x64, 0x6e, 0x73, 0x12, 0x18, 0x0a,
+	0x07, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07,
+	0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x6c, 0x73, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x74, 0x6c, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x74, 0x66,
+	0x62, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74, 0x74, 0x66, 0x62, 0x12, 0x1a, 0x0a,
+	0x08, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x08, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x79, 0x74,
+	0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x22,
+	0xc5, 0x01, 0x0a, 0x07, 0x54, 0x4c, 0x53, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x18, 0x0a, 0x07, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x5f,
+	0x73, 0x75, 0x69, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x69, 0x70,
+	0x68, 0x65, 0x72, 0x53, 0x75, 0x69, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x73, 0x73, 0x75,
+	0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x69, 0x73, 0x73, 0x75, 0x65, 0x72,
+	0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x37, 0x0a, 0x09, 0x6e, 0x6f,
+	0x74, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x6e, 0x6f, 0x74, 0x41, 0x66,
+	0x74, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x61, 0x6e, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x04, 0x73, 0x61, 0x6e, 0x73, 0x22, 0xea, 0x0c, 0x0a, 0x0c, 0x46, 0x65, 0x74, 0x63,
+	0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x12, 0x1d, 0x0a, 0x03, 0x75, 0x72, 0x6c, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x55,
+	0x52, 0x4c, 0x52, 0x03, 0x75, 0x72, 0x6c, 0x12, 0x34, 0x0a, 0x0f, 0x72, 0x65, 0x64, 0x69, 0x72,
+	0x65, 0x63, 0x74, 0x65, 0x64, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x55, 0x52, 0x4c, 0x52, 0x0e, 0x72,
+	0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x65, 0x64, 0x46, 0x72, 0x6f, 0x6d, 0x12, 0x2b, 0x0a,
+	0x11, 0x72, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x05, 0x52, 0x10, 0x72, 0x65, 0x64, 0x69, 0x72, 0x65,
+	0x63, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73, 0x12, 0x2c, 0x0a, 0x08, 0x72, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x77,
+	0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x08,
+	0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12, 0x1f, 0x0a, 0x0b,
+	0x66, 0x65, 0x74, 0x63, 0x68, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0a, 0x66, 0x65, 0x74, 0x63, 0x68, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x2e, 0x0a,
+	0x13, 0x66, 0x65, 0x74, 0x63, 0x68, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x6f, 0x75, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x66, 0x65, 0x74, 0x63,
+	0x68, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x12, 0x39, 0x0a,
+	0x0a, 0x66, 0x65, 0x74, 0x63, 0x68, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x66,
+	0x65, 0x74, 0x63, 0x68, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x65, 0x78, 0x63, 0x6c,
+	0x75, 0x64, 0x65, 0x64, 0x5f, 0x62, 0x79, 0x5f, 0x72, 0x6f, 0x62, 0x6f, 0x74, 0x73, 0x18, 0x09,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x10, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x64, 0x42, 0x79,
+	0x52, 0x6f, 0x62, 0x6f, 0x74, 0x73, 0x12, 0x2e, 0x0a, 0x13, 0x73, 0x6b, 0x69, 0x70, 0x70, 0x65,
+	0x64, 0x5f, 0x62, 0x79, 0x5f, 0x70, 0x72, 0x65, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x18, 0x0a, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x11, 0x73, 0x6b, 0x69, 0x70, 0x70, 0x65, 0x64, 0x42, 0x79, 0x50, 0x72,
+	0x65, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x65, 0x74, 0x61, 0x5f, 0x6e,
+	0x6f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x6d, 0x65,
+	0x74, 0x61, 0x4e, 0x6f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x23, 0x0a, 0x0d, 0x6d, 0x65, 0x74,
+	0x61, 0x5f, 0x6e, 0x6f, 0x66, 0x6f, 0x6c, 0x6c, 0x6f, 0x77, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0c, 0x6d, 0x65, 0x74, 0x61, 0x4e, 0x6f, 0x66, 0x6f, 0x6c, 0x6c, 0x6f, 0x77, 0x12, 0x14,
+	0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74,
+	0x69, 0x74, 0x6c, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1b, 0x0a, 0x09, 0x6d, 0x69, 0x6d, 0x65, 0x5f, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6d, 0x69, 0x6d, 0x65, 0x54,
+	0x79, 0x70, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x66, 0x6e, 0x76, 0x5f, 0x66, 0x69, 0x6e, 0x67, 0x65,
+	0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x18, 0x10, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x66, 0x6e,
+	0x76, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x12, 0x30, 0x0a, 0x14,
+	0x66, 0x6e, 0x76, 0x5f, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70,
+	0x72, 0x69, 0x6e, 0x74, 0x18, 0x11, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x66, 0x6e, 0x76, 0x54,
+	0x65, 0x78, 0x74, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x12, 0x2b,
+	0x0a, 0x06, 0x74, 0x69, 0x6d, 0x69, 0x6e, 0x67, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13,
+	0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x46, 0x65, 0x74, 0x63, 0x68, 0x54, 0x69, 0x6d,
+	0x69, 0x6e, 0x67, 0x52, 0x06, 0x74, 0x69, 0x6d, 0x69, 0x6e, 0x67, 0x12, 0x21, 0x0a, 0x03, 0x74,
+	0x6c, 0x73, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65,
+	0x72, 0x2e, 0x54, 0x4c, 0x53, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x03, 0x74, 0x6c, 0x73, 0x12, 0x27,
+	0x0a, 0x0f, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x75, 0x72, 0x65, 0x64, 0x5f, 0x64, 0x61, 0x74,
+	0x61, 0x18, 0x14, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0e, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x75,
+	0x72, 0x65, 0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x6f, 0x62, 0x6f, 0x74,
+	0x73, 0x5f, 0x72, 0x75, 0x6c, 0x65, 0x18, 0x15, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x6f,
+	0x62, 0x6f, 0x74, 0x73, 0x52, 0x75, 0x6c, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x6e, 0x6f, 0x5f, 0x61,
+	0x72, 0x63, 0x68, 0x69, 0x76, 0x65, 0x18, 0x16, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x6e, 0x6f,
+	0x41, 0x72, 0x63, 0x68, 0x69, 0x76, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x6e, 0x6f, 0x5f, 0x73, 0x6e,
+	0x69, 0x70, 0x70, 0x65, 0x74, 0x18, 0x17, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x6e, 0x6f, 0x53,
+	0x6e, 0x69, 0x70, 0x70, 0x65, 0x74, 0x12, 0x23, 0x0a, 0x0d, 0x64, 0x72, 0x6f, 0x70, 0x70, 0x65,
+	0x64, 0x5f, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x18, 0x18, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x64,
+	0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x64,
+	0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x5f, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x18, 0x19,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x4c,
+	0x69, 0x6e, 0x6b, 0x73, 0x12, 0x2d, 0x0a, 0x12, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x5f, 0x66,
+	0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x11, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72,
+	0x69, 0x6e, 0x74, 0x12, 0x2f, 0x0a, 0x13, 0x73, 0x69, 0x6d, 0x68, 0x61, 0x73, 0x68, 0x5f, 0x66,
+	0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x18, 0x1b, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x12, 0x73, 0x69, 0x6d, 0x68, 0x61, 0x73, 0x68, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70,
+	0x72, 0x69, 0x6e, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x6e, 0x6f, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x69,
+	0x66, 0x69, 0x65, 0x64, 0x18, 0x1c, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x6e, 0x6f, 0x74, 0x4d,
+	0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x72, 0x75, 0x6e, 0x63,
+	0x61, 0x74, 0x65, 0x64, 0x18, 0x1d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x74, 0x72, 0x75, 0x6e,
+	0x63, 0x61, 0x74, 0x65, 0x64, 0x12, 0x21, 0x0a, 0x05, 0x69, 0x63, 0x6f, 0x6e, 0x73, 0x18, 0x1e,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x55, 0x52,
+	0x4c, 0x52, 0x05, 0x69, 0x63, 0x6f, 0x6e, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x64, 0x69,
+	0x72, 0x65, 0x63, 0x74, 0x5f, 0x6c, 0x6f, 0x6f, 0x70, 0x18, 0x1f, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x0c, 0x72, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x4c, 0x6f, 0x6f, 0x70, 0x12, 0x32, 0x0a,
+	0x0e, 0x72, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x5f, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x18,
+	0x20, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x55,
+	0x52, 0x4c, 0x52, 0x0d, 0x72, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x43, 0x68, 0x61, 0x69,
+	0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x5f, 0x69, 0x64, 0x65, 0x6e, 0x74,
+	0x69, 0x74, 0x79, 0x18, 0x21, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x63, 0x72, 0x61, 0x77, 0x6c,
+	0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x2d, 0x0a, 0x12, 0x72, 0x6f, 0x62, 0x6f,
+	0x74, 0x73, 0x5f, 0x75, 0x6e, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x22,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x72, 0x6f, 0x62, 0x6f, 0x74, 0x73, 0x55, 0x6e, 0x61, 0x76,
+	0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x46, 0x0a, 0x11, 0x72, 0x6f, 0x62, 0x6f, 0x74,
+	0x73, 0x5f, 0x72, 0x65, 0x74, 0x72, 0x79, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x23, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0f,
+	0x72, 0x6f, 0x62, 0x6f, 0x74, 0x73, 0x52, 0x65, 0x74, 0x72, 0x79, 0x54, 0x69, 0x6d, 0x65, 0x12,
+	0x23, 0x0a, 0x0d, 0x70, 0x61, 0x72, 0x73, 0x65, 0x5f, 0x61, 0x62, 0x6f, 0x72, 0x74, 0x65, 0x64,
+	0x18, 0x24, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x70, 0x61, 0x72, 0x73, 0x65, 0x41, 0x62, 0x6f,
+	0x72, 0x74, 0x65, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x6f, 0x62, 0x6f, 0x74, 0x73, 0x5f, 0x61,
+	0x67, 0x65, 0x6e, 0x74, 0x18, 0x25, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x72, 0x6f, 0x62, 0x6f,
+	0x74, 0x73, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x12, 0x2c, 0x0a, 0x12, 0x72, 0x6f, 0x62, 0x6f, 0x74,
+	0x73, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x5f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x18, 0x26, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x10, 0x72, 0x6f, 0x62, 0x6f, 0x74, 0x73, 0x47, 0x72, 0x6f, 0x75, 0x70,
+	0x41, 0x67, 0x65, 0x6e, 0x74, 0x12, 0x29, 0x0a, 0x10, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x5f, 0x6c, 0x61, 0x6e, 0x67, 0x75, 0x61, 0x67, 0x65, 0x18, 0x27, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x4c, 0x61, 0x6e, 0x67, 0x75, 0x61, 0x67, 0x65,
+	0x12, 0x32, 0x0a, 0x15, 0x64, 0x65, 0x63, 0x6c, 0x61, 0x72, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x6e,
+	0x74, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x28, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x13, 0x64, 0x65, 0x63, 0x6c, 0x61, 0x72, 0x65, 0x64, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x54, 0x79, 0x70, 0x65, 0x22, 0x67, 0x0a, 0x1b, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x55, 0x52, 0x4c,
+	0x46, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x12, 0x2e, 0x0a,
+	0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14,
+	0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x46, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x73, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x22, 0x1e, 0x0a,
+	0x1c, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x55, 0x52, 0x4c, 0x46, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x83, 0x01,
+	0x0a, 0x16, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x61, 0x72, 0x73, 0x65, 0x64, 0x55, 0x52, 0x4c,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63,
+	0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68,
+	0x65, 0x72, 0x12, 0x1f, 0x0a, 0x04, 0x75, 0x72, 0x6c, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x55, 0x52, 0x4c, 0x52, 0x04, 0x75,
+	0x72, 0x6c, 0x73, 0x12, 0x2e, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x46, 0x65,
+	0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x73, 0x22, 0x19, 0x0a, 0x17, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x61, 0x72, 0x73,
+	0x65, 0x64, 0x55, 0x52, 0x4c, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x2c,
+	0x0a, 0x10, 0x4b, 0x65, 0x65, 0x70, 0x41, 0x6c, 0x69, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x22, 0x13, 0x0a, 0x11,
+	0x4b, 0x65, 0x65, 0x70, 0x41, 0x6c, 0x69, 0x76, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x22, 0x29, 0x0a, 0x0d, 0x52, 0x65, 0x74, 0x69, 0x72, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x22, 0x10, 0x0a, 0x0e,
+	0x52, 0x65, 0x74, 0x69, 0x72, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0xff,
+	0x01, 0x0a, 0x0c, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x41, 0x73, 0x73, 0x65, 0x74, 0x73, 0x12,
+	0x2c, 0x0a, 0x0b, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x55, 0x52,
+	0x4c, 0x52, 0x0a, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x55, 0x72, 0x6c, 0x12, 0x3d, 0x0a,
+	0x0c, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
+	0x0b, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x25, 0x0a, 0x0e,
+	0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x53, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x12, 0x2a, 0x0a, 0x11, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x5f, 0x6d,
+	0x69, 0x6d, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f,
+	0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x4d, 0x69, 0x6d, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x2f, 0x0a, 0x13, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x5f, 0x66, 0x69, 0x6e, 0x67, 0x65,
+	0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x66, 0x61,
+	0x76, 0x69, 0x63, 0x6f, 0x6e, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74,
+	0x22, 0x76, 0x0a, 0x18, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x41,
+	0x73, 0x73, 0x65, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07,
+	0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66,
+	0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x2c, 0x0a, 0x06, 0x61, 0x73,
+	0x73, 0x65, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x77, 0x61, 0x6c,
+	0x6b, 0x65, 0x72, 0x2e, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x41, 0x73, 0x73, 0x65, 0x74, 0x73,
+	0x52, 0x06, 0x61, 0x73, 0x73, 0x65, 0x74, 0x73, 0x22, 0x1b, 0x0a, 0x19, 0x53, 0x74, 0x6f, 0x72,
+	0x65, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x41, 0x73, 0x73, 0x65, 0x74, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x43, 0x0a, 0x13, 0x48, 0x6f, 0x73, 0x74, 0x53, 0x65, 0x74,
+	0x74, 0x69, 0x6e, 0x67, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07,
+	0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66,
+	0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x22, 0x4e, 0x0a, 0x0c, 0x48, 0x6f,
+	0x73, 0x74, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x72,
+	0x61, 0x77, 0x6c, 0x5f, 0x64, 0x65, 0x6c, 0x61, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0a, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x44, 0x65, 0x6c, 0x61, 0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x75,
+	0x73, 0x65, 0x72, 0x5f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x75, 0x73, 0x65, 0x72, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x22, 0x48, 0x0a, 0x14, 0x48, 0x6f,
+	0x73, 0x74, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x30, 0x0a, 0x08, 0x73, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x48, 0x6f,
+	0x73, 0x74, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x52, 0x08, 0x73, 0x65, 0x74, 0x74,
+	0x69, 0x6e, 0x67, 0x73, 0x22, 0x65, 0x0a, 0x12, 0x52, 0x65, 0x71, 0x75, 0x65, 0x75, 0x65, 0x48,
+	0x6f, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65,
+	0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74,
+	0x63, 0x68, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x21, 0x0a, 0x05, 0x6c, 0x69, 0x6e, 0x6b,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72,
+	0x2e, 0x55, 0x52, 0x4c, 0x52, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x22, 0x15, 0x0a, 0x13, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x75, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x6f, 0x0a, 0x1d, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x52, 0x6f, 0x62, 0x6f, 0x74,
+	0x73, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x12, 0x12, 0x0a,
+	0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73,
+	0x74, 0x12, 0x20, 0x0a, 0x0b, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72,
+	0x69, 0x6e, 0x74, 0x22, 0x20, 0x0a, 0x1e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x52, 0x6f, 0x62, 0x6f,
+	0x74, 0x73, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x6b, 0x0a, 0x0f, 0x50, 0x6f, 0x6c, 0x69, 0x74, 0x65, 0x6e,
+	0x65, 0x73, 0x73, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x72, 0x6c, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75, 0x72, 0x6c, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x22, 0xbe, 0x02, 0x0a, 0x0f, 0x50, 0x6f, 0x6c, 0x69, 0x74, 0x65, 0x6e, 0x65, 0x73,
+	0x73, 0x41, 0x75, 0x64, 0x69, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x30, 0x0a, 0x05, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x12, 0x1a, 0x0a, 0x08,
+	0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x72, 0x61, 0x77,
+	0x6c, 0x5f, 0x64, 0x65, 0x6c, 0x61, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x63,
+	0x72, 0x61, 0x77, 0x6c, 0x44, 0x65, 0x6c, 0x61, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x72, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x73, 0x12, 0x2a, 0x0a, 0x11, 0x74, 0x6f, 0x6f, 0x5f, 0x6d, 0x61, 0x6e,
+	0x79, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0f, 0x74, 0x6f, 0x6f, 0x4d, 0x61, 0x6e, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x73, 0x12, 0x2f, 0x0a, 0x13, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x75, 0x6e, 0x61,
+	0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x12,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x55, 0x6e, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62,
+	0x6c, 0x65, 0x12, 0x2f, 0x0a, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x08, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x50, 0x6f, 0x6c, 0x69,
+	0x74, 0x65, 0x6e, 0x65, 0x73, 0x73, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x06, 0x65, 0x76, 0x65,
+	0x6e, 0x74, 0x73, 0x22, 0x66, 0x0a, 0x1b, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x6f, 0x6c, 0x69,
+	0x74, 0x65, 0x6e, 0x65, 0x73, 0x73, 0x41, 0x75, 0x64, 0x69, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x12, 0x2d, 0x0a, 0x05,
+	0x61, 0x75, 0x64, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x77, 0x61,
+	0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x50, 0x6f, 0x6c, 0x69, 0x74, 0x65, 0x6e, 0x65, 0x73, 0x73, 0x41,
+	0x75, 0x64, 0x69, 0x74, 0x52, 0x05, 0x61, 0x75, 0x64, 0x69, 0x74, 0x22, 0x1e, 0x0a, 0x1c, 0x53,
+	0x74, 0x6f, 0x72, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x74, 0x65, 0x6e, 0x65, 0x73, 0x73, 0x41, 0x75,
+	0x64, 0x69, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x2d, 0x0a, 0x11, 0x45,
+	0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x18, 0x0a, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x22, 0x2a, 0x0a, 0x12, 0x45, 0x72,
+	0x72, 0x6f, 0x72, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x40, 0x0a, 0x10, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x48,
+	0x6f, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x65,
+	0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x66, 0x65, 0x74,
+	0x63, 0x68, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x22, 0x2d, 0x0a, 0x11, 0x43, 0x6c, 0x61, 0x69,
+	0x6d, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x63, 0x6c, 0x61, 0x69, 0x6d, 0x65, 0x64, 0x22, 0x42, 0x0a, 0x12, 0x52, 0x65, 0x6c, 0x65, 0x61,
+	0x73, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a,
+	0x07, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x22, 0x15, 0x0a, 0x13, 0x52,
+	0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x5e, 0x0a, 0x12, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x4c, 0x69, 0x6e, 0x6b,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6e, 0x6b,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x32,
+	0x0a, 0x15, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x5f, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e,
+	0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x13, 0x65,
+	0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x52, 0x65, 0x61, 0x73,
+	0x6f, 0x6e, 0x22, 0x2d, 0x0a, 0x13, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x4c, 0x69, 0x6e, 0x6b,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x73, 0x22, 0x7a, 0x0a, 0x1a, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x4c, 0x69, 0x6e, 0x6b, 0x73,
+	0x57, 0x69, 0x74, 0x68, 0x54, 0x61, 0x67, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05,
+	0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x32, 0x0a, 0x15, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65,
+	0x5f, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x13, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x44, 0x6f, 0x6d,
+	0x61, 0x69, 0x6e, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x61, 0x67,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x22, 0xac, 0x06,
+	0x0a, 0x0a, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x16, 0x0a, 0x06,
+	0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x6f,
+	0x6d, 0x61, 0x69, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x64,
+	0x12, 0x25, 0x0a, 0x0e, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x5f, 0x72, 0x65, 0x61, 0x73,
+	0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64,
+	0x65, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x61, 0x75, 0x73, 0x65,
+	0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x70, 0x61, 0x75, 0x73, 0x65, 0x64, 0x12,
+	0x29, 0x0a, 0x10, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68,
+	0x6f, 0x6c, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0f, 0x73, 0x61, 0x6d, 0x70, 0x6c,
+	0x65, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x02, 0x52, 0x0d, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e,
+	0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x62, 0x79, 0x74, 0x65, 0x5f, 0x62, 0x75, 0x64, 0x67, 0x65, 0x74,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x62, 0x79, 0x74, 0x65, 0x42, 0x75, 0x64, 0x67,
+	0x65, 0x74, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x5f, 0x74, 0x69, 0x6d, 0x65,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x09, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1f, 0x0a,
+	0x0b, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x2c,
+	0x0a, 0x12, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x5f, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x5f, 0x74,
+	0x6f, 0x74, 0x61, 0x6c, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x6e, 0x75, 0x6d, 0x62,
+	0x65, 0x72, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x12, 0x2e, 0x0a, 0x13,
+	0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x5f, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x5f, 0x71, 0x75, 0x65,
+	0x75, 0x65, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x05, 0x52, 0x11, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x51, 0x75, 0x65, 0x75, 0x65, 0x64, 0x12, 0x34, 0x0a, 0x16,
+	0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x5f, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x5f, 0x75, 0x6e, 0x63,
+	0x72, 0x61, 0x77, 0x6c, 0x65, 0x64, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x05, 0x52, 0x14, 0x6e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x55, 0x6e, 0x63, 0x72, 0x61, 0x77, 0x6c,
+	0x65, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x0d,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x21,
+	0x0a, 0x0c, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x0e,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x57, 0x69, 0x6e, 0x64, 0x6f,
+	0x77, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x72, 0x61, 0x77, 0x6c, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x7a,
+	0x6f, 0x6e, 0x65, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x63, 0x72, 0x61, 0x77, 0x6c,
+	0x54, 0x69, 0x6d, 0x65, 0x7a, 0x6f, 0x6e, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x66, 0x61, 0x76, 0x69,
+	0x63, 0x6f, 0x6e, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x10, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x66,
+	0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x55, 0x72, 0x6c, 0x12, 0x3d, 0x0a, 0x0c, 0x66, 0x61, 0x76,
+	0x69, 0x63, 0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x11, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0b, 0x66, 0x61, 0x76,
+	0x69, 0x63, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x66, 0x61, 0x76, 0x69,
+	0x63, 0x6f, 0x6e, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x12, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0d, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
+	0x2a, 0x0a, 0x11, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x5f, 0x6d, 0x69, 0x6d, 0x65, 0x5f,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x13, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x66, 0x61, 0x76, 0x69,
+	0x63, 0x6f, 0x6e, 0x4d, 0x69, 0x6d, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x2f, 0x0a, 0x13, 0x66,
+	0x61, 0x76, 0x69, 0x63, 0x6f, 0x6e, 0x5f, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69,
+	0x6e, 0x74, 0x18, 0x14, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x66, 0x61, 0x76, 0x69, 0x63, 0x6f,
+	0x6e, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x22, 0x2b, 0x0a, 0x11,
+	0x46, 0x69, 0x6e, 0x64, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x22, 0x40, 0x0a, 0x12, 0x46, 0x69, 0x6e,
+	0x64, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x2a, 0x0a, 0x06, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x12, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x49,
+	0x6e, 0x66, 0x6f, 0x52, 0x06, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x22, 0x58, 0x0a, 0x12, 0x4c,
+	0x69, 0x73, 0x74, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x65, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x73, 0x65, 0x65, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x77,
+	0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x77, 0x6f,
+	0x72, 0x6b, 0x69, 0x6e, 0x67, 0x22, 0x43, 0x0a, 0x13, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x6f, 0x6d,
+	0x61, 0x69, 0x6e, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x07,
+	0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e,
+	0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x49, 0x6e, 0x66,
+	0x6f, 0x52, 0x07, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x73, 0x32, 0xc1, 0x0b, 0x0a, 0x09, 0x44,
+	0x61, 0x74, 0x61, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x12, 0x49, 0x0a, 0x0c, 0x43, 0x6c, 0x61, 0x69,
+	0x6d, 0x4e, 0x65, 0x77, 0x48, 0x6f, 0x73, 0x74, 0x12, 0x1b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65,
+	0x72, 0x2e, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x4e, 0x65, 0x77, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x43,
+	0x6c, 0x61, 0x69, 0x6d, 0x4e, 0x65, 0x77, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x0b, 0x55, 0x6e, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x48, 0x6f,
+	0x73, 0x74, 0x12, 0x1a, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x55, 0x6e, 0x63, 0x6c,
+	0x61, 0x69, 0x6d, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b,
+	0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x55, 0x6e, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x48,
+	0x6f, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3a, 0x0a, 0x0c, 0x4c,
+	0x69, 0x6e, 0x6b, 0x73, 0x46, 0x6f, 0x72, 0x48, 0x6f, 0x73, 0x74, 0x12, 0x1b, 0x2e, 0x77, 0x61,
+	0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x46, 0x6f, 0x72, 0x48, 0x6f, 0x73,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65,
+	0x72, 0x2e, 0x55, 0x52, 0x4c, 0x30, 0x01, 0x12, 0x61, 0x0a, 0x14, 0x53, 0x74, 0x6f, 0x72, 0x65,
+	0x55, 0x52, 0x4c, 0x46, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x12,
+	0x23, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x55, 0x52,
+	0x4c, 0x46, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x53, 0x74,
+	0x6f, 0x72, 0x65, 0x55, 0x52, 0x4c, 0x46, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x52, 0x0a, 0x0f, 0x53, 0x74,
+	0x6f, 0x72, 0x65, 0x50, 0x61, 0x72, 0x73, 0x65, 0x64, 0x55, 0x52, 0x4c, 0x73, 0x12, 0x1e, 0x2e,
+	0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x61, 0x72, 0x73,
+	0x65, 0x64, 0x55, 0x52, 0x4c, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e,
+	0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x61, 0x72, 0x73,
+	0x65, 0x64, 0x55, 0x52, 0x4c, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x40,
+	0x0a, 0x09, 0x4b, 0x65, 0x65, 0x70, 0x41, 0x6c, 0x69, 0x76, 0x65, 0x12, 0x18, 0x2e, 0x77, 0x61,
+	0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x4b, 0x65, 0x65, 0x70, 0x41, 0x6c, 0x69, 0x76, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x4b,
+	0x65, 0x65, 0x70, 0x41, 0x6c, 0x69, 0x76, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x37, 0x0a, 0x06, 0x52, 0x65, 0x74, 0x69, 0x72, 0x65, 0x12, 0x15, 0x2e, 0x77, 0x61, 0x6c,
+	0x6b, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x74, 0x69, 0x72, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x16, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x74, 0x69, 0x72,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x58, 0x0a, 0x11, 0x53, 0x74, 0x6f,
+	0x72, 0x65, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x41, 0x73, 0x73, 0x65, 0x74, 0x73, 0x12, 0x20,
+	0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x6f, 0x6d,
+	0x61, 0x69, 0x6e, 0x41, 0x73, 0x73, 0x65, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x21, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44,
+	0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x41, 0x73, 0x73, 0x65, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x49, 0x0a, 0x0c, 0x48, 0x6f, 0x73, 0x74, 0x53, 0x65, 0x74, 0x74, 0x69,
+	0x6e, 0x67, 0x73, 0x12, 0x1b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x48, 0x6f, 0x73,
+	0x74, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1c, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x53, 0x65,
+	0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46,
+	0x0a, 0x0b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x75, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x12, 0x1a, 0x2e,
+	0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x75, 0x65, 0x48, 0x6f,
+	0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x77, 0x61, 0x6c, 0x6b,
+	0x65, 0x72, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x75, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x67, 0x0a, 0x16, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x52,
+	0x6f, 0x62, 0x6f, 0x74, 0x73, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74,
+	0x12, 0x25, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x52,
+	0x6f, 0x62, 0x6f, 0x74, 0x73, 0x46, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x26, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72,
+	0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x52, 0x6f, 0x62, 0x6f, 0x74, 0x73, 0x46, 0x69, 0x6e, 0x67,
+	0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x61, 0x0a, 0x14, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x74, 0x65, 0x6e, 0x65,
+	0x73, 0x73, 0x41, 0x75, 0x64, 0x69, 0x74, 0x12, 0x23, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72,
+	0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x74, 0x65, 0x6e, 0x65, 0x73, 0x73,
+	0x41, 0x75, 0x64, 0x69, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x77,
+	0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x6f, 0x6c, 0x69, 0x74,
+	0x65, 0x6e, 0x65, 0x73, 0x73, 0x41, 0x75, 0x64, 0x69, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x43, 0x0a, 0x0a, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x75, 0x6e, 0x74,
+	0x12, 0x19, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43,
+	0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x77, 0x61,
+	0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x40, 0x0a, 0x09, 0x43, 0x6c, 0x61, 0x69, 0x6d,
+	0x48, 0x6f, 0x73, 0x74, 0x12, 0x18, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x43, 0x6c,
+	0x61, 0x69, 0x6d, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19,
+	0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x48, 0x6f, 0x73,
+	0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x0b, 0x52, 0x65, 0x6c,
+	0x65, 0x61, 0x73, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x12, 0x1a, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65,
+	0x72, 0x2e, 0x52, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x52, 0x65,
+	0x6c, 0x65, 0x61, 0x73, 0x65, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x46, 0x0a, 0x0b, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x4c, 0x69, 0x6e, 0x6b, 0x73,
+	0x12, 0x1a, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74,
+	0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x77,
+	0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x4c, 0x69, 0x6e, 0x6b,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x56, 0x0a, 0x13, 0x49, 0x6e, 0x73,
+	0x65, 0x72, 0x74, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x57, 0x69, 0x74, 0x68, 0x54, 0x61, 0x67, 0x73,
+	0x12, 0x22, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74,
+	0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x57, 0x69, 0x74, 0x68, 0x54, 0x61, 0x67, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x49, 0x6e,
+	0x73, 0x65, 0x72, 0x74, 0x4c, 0x69, 0x6e, 0x6b, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x43, 0x0a, 0x0a, 0x46, 0x69, 0x6e, 0x64, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x12,
+	0x19, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x44, 0x6f, 0x6d,
+	0x61, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x77, 0x61, 0x6c,
+	0x6b, 0x65, 0x72, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x0b, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x6f,
+	0x6d, 0x61, 0x69, 0x6e, 0x73, 0x12, 0x1a, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x4c,
+	0x69, 0x73, 0x74, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1b, 0x2e, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x44,
+	0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x23,
+	0x5a, 0x21, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x69, 0x50, 0x61,
+	0x72, 0x61, 0x64, 0x69, 0x67, 0x6d, 0x73, 0x2f, 0x77, 0x61, 0x6c, 0x6b, 0x65, 0x72, 0x2f, 0x67,
+	0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_walker_proto_rawDescOnce sync.Once
+	file_walker_proto_rawDescData = file_walker_proto_rawDesc
+)
+
+func file_walker_proto_rawDescGZIP() []byte {
+	file_walker_proto_rawDescOnce.Do(func() {
+		file_walker_proto_rawDescData = protoimpl.X.CompressGZIP(file_walker_proto_rawDescData)
+	})
+	return file_walker_proto_rawDescData
+}
+
+var file_walker_proto_msgTypes = make([]protoimpl.MessageInfo, 49)
+var file_walker_proto_goTypes = []any{
+	(*ClaimNewHostRequest)(nil),            // 0: walker.ClaimNewHostRequest
+	(*ClaimNewHostResponse)(nil),           // 1: walker.ClaimNewHostResponse
+	(*UnclaimHostRequest)(nil),             // 2: walker.UnclaimHostRequest
+	(*UnclaimHostResponse)(nil),            // 3: walker.UnclaimHostResponse
+	(*LinksForHostRequest)(nil),            // 4: walker.LinksForHostRequest
+	(*URL)(nil),                            // 5: walker.URL
+	(*Response)(nil),                       // 6: walker.Response
+	(*HeaderValues)(nil),                   // 7: walker.HeaderValues
+	(*FetchTiming)(nil),                    // 8: walker.FetchTiming
+	(*TLSInfo)(nil),                        // 9: walker.TLSInfo
+	(*FetchResults)(nil),                   // 10: walker.FetchResults
+	(*StoreURLFetchResultsRequest)(nil),    // 11: walker.StoreURLFetchResultsRequest
+	(*StoreURLFetchResultsResponse)(nil),   // 12: walker.StoreURLFetchResultsResponse
+	(*StoreParsedURLsRequest)(nil),         // 13: walker.StoreParsedURLsRequest
+	(*StoreParsedURLsResponse)(nil),        // 14: walker.StoreParsedURLsResponse
+	(*KeepAliveRequest)(nil),               // 15: walker.KeepAliveRequest
+	(*KeepAliveResponse)(nil),              // 16: walker.KeepAliveResponse
+	(*RetireRequest)(nil),                  // 17: walker.RetireRequest
+	(*RetireResponse)(nil),                 // 18: walker.RetireResponse
+	(*DomainAssets)(nil),                   // 19: walker.DomainAssets
+	(*StoreDomainAssetsRequest)(nil),       // 20: walker.StoreDomainAssetsRequest
+	(*StoreDomainAssetsResponse)(nil),      // 21: walker.StoreDomainAssetsResponse
+	(*HostSettingsRequest)(nil),            // 22: walker.HostSettingsRequest
+	(*HostSettings)(nil),                   // 23: walker.HostSettings
+	(*HostSettingsResponse)(nil),           // 24: walker.HostSettingsResponse
+	(*RequeueHostRequest)(nil),             // 25: walker.RequeueHostRequest
+	(*RequeueHostResponse)(nil),            // 26: walker.RequeueHostResponse
+	(*StoreRobotsFingerprintRequest)(nil),  // 27: walker.StoreRobotsFingerprintRequest
+	(*StoreRobotsFingerprintResponse)(nil), // 28: walker.StoreRobotsFingerprintResponse
+	(*PolitenessEvent)(nil),                // 29: walker.PolitenessEvent
+	(*PolitenessAudit)(nil),                // 30: walker.PolitenessAudit
+	(*StorePolitenessAuditRequest)(nil),    // 31: walker.StorePolitenessAuditRequest
+	(*StorePolitenessAuditResponse)(nil),   // 32: walker.StorePolitenessAuditResponse
+	(*ErrorCountRequest)(nil),              // 33: walker.ErrorCountRequest
+	(*ErrorCountResponse)(nil),             // 34: walker.ErrorCountResponse
+	(*ClaimHostRequest)(nil),               // 35: walker.ClaimHostRequest
+	(*ClaimHostResponse)(nil),              // 36: walker.ClaimHostResponse
+	(*ReleaseHostRequest)(nil),             // 37: walker.ReleaseHostRequest
+	(*ReleaseHostResponse)(nil),            // 38: walker.ReleaseHostResponse
+	(*InsertLinksRequest)(nil),             // 39: walker.InsertLinksRequest
+	(*InsertLinksResponse)(nil),            // 40: walker.InsertLinksResponse
+	(*InsertLinksWithTagsRequest)(nil),     // 41: walker.InsertLinksWithTagsRequest
+	(*DomainInfo)(nil),                     // 42: walker.DomainInfo
+	(*FindDomainRequest)(nil),              // 43: walker.FindDomainRequest
+	(*FindDomainResponse)(nil),             // 44: walker.FindDomainResponse
+	(*ListDomainsRequest)(nil),             // 45: walker.ListDomainsRequest
+	(*ListDomainsResponse)(nil),            // 46: walker.ListDomainsResponse
+	nil,                                    // 47: walker.Response.HeaderEntry
+	nil,                                    // 48: walker.Response.RequestHeaderEntry
+	(*timestamppb.Timestamp)(nil),          // 49: google.protobuf.Timestamp
+}
+var file_walker_proto_depIdxs = []int32{
+	49, // 0: walker.URL.last_crawled:type_name -> google.protobuf.Timestamp
+	47, // 1: walker.Response.header:type_name -> walker.Response.HeaderEntry
+	48, // 2: walker.Response.request_header:type_name -> walker.Response.RequestHeaderEntry
+	49, // 3: walker.TLSInfo.not_after:type_name -> google.protobuf.Timestamp
+	5,  // 4: walker.FetchResults.url:type_name -> walker.URL
+	5,  // 5: walker.FetchResults.redirected_from:type_name -> walker.URL
+	6,  // 6: walker.FetchResults.response:type_name -> walker.Response
+	49, // 7: walker.FetchResults.fetch_time:type_name -> google.protobuf.Timestamp
+	8,  // 8: walker.FetchResults.timing:type_name -> walker.FetchTiming
+	9,  // 9: walker.FetchResults.tls:type_name -> walker.TLSInfo
+	5,  // 10: walker.FetchResults.icons:type_name -> walker.URL
+	5,  // 11: walker.FetchResults.redirect_chain:type_name -> walker.URL
+	49, // 12: walker.FetchResults.robots_retry_time:type_name -> google.protobuf.Timestamp
+	10, // 13: walker.StoreURLFetchResultsRequest.results:type_name -> walker.FetchResults
+	5,  // 14: walker.StoreParsedURLsRequest.urls:type_name -> walker.URL
+	10, // 15: walker.StoreParsedURLsRequest.results:type_name -> walker.FetchResults
+	5,  // 16: walker.DomainAssets.favicon_url:type_name -> walker.URL
+	49, // 17: walker.DomainAssets.favicon_time:type_name -> google.protobuf.Timestamp
+	19, // 18: walker.StoreDomainAssetsRequest.assets:type_name -> walker.DomainAssets
+	23, // 19: walker.HostSettingsResponse.settings:type_name -> walker.HostSettings
+	5,  // 20: walker.RequeueHostRequest.links:type_name -> walker.URL
+	49, // 21: walker.PolitenessEvent.time:type_name -> google.protobuf.Timestamp
+	49, // 22: walker.PolitenessAudit.start:type_name -> google.protobuf.Timestamp
+	29, // 23: walker.PolitenessAudit.events:type_name -> walker.PolitenessEvent
+	30, // 24: walker.StorePolitenessAuditRequest.audit:type_name -> walker.PolitenessAudit
+	49, // 25: walker.DomainInfo.claim_time:type_name -> google.protobuf.Timestamp
+	49, // 26: walker.DomainInfo.favicon_time:type_name -> google.protobuf.Timestamp
+	42, // 27: walker.FindDomainResponse.domain:type_name -> walker.DomainInfo
+	42, // 28: walker.ListDomainsResponse.domains:type_name -> walker.DomainInfo
+	7,  // 29: walker.Response.HeaderEntry.value:type_name -> walker.HeaderValues
+	7,  // 30: walker.Response.RequestHeaderEntry.value:type_name -> walker.HeaderValues
+	0,  // 31: walker.Datastore.ClaimNewHost:input_type -> walker.ClaimNewHostRequest
+	2,  // 32: walker.Datastore.UnclaimHost:input_type -> walker.UnclaimHostRequest
+	4,  // 33: walker.Datastore.LinksForHost:input_type -> walker.LinksForHostRequest
+	11, // 34: walker.Datastore.StoreURLFetchResults:input_type -> walker.StoreURLFetchResultsRequest
+	13, // 35: walker.Datastore.StoreParsedURLs:input_type -> walker.StoreParsedURLsRequest
+	15, // 36: walker.Datastore.KeepAlive:input_type -> walker.KeepAliveRequest
+	17, // 37: walker.Datastore.Retire:input_type -> walker.RetireRequest
+	20, // 38: walker.Datastore.StoreDomainAssets:input_type -> walker.StoreDomainAssetsRequest
+	22, // 39: walker.Datastore.HostSettings:input_type -> walker.HostSettingsRequest
+	25, // 40: walker.Datastore.RequeueHost:input_type -> walker.RequeueHostRequest
+	27, // 41: walker.Datastore.StoreRobotsFingerprint:input_type -> walker.StoreRobotsFingerprintRequest
+	31, // 42: walker.Datastore.StorePolitenessAudit:input_type -> walker.StorePolitenessAuditRequest
+	33, // 43: walker.Datastore.ErrorCount:input_type -> walker.ErrorCountRequest
+	35, // 44: walker.Datastore.ClaimHost:input_type -> walker.ClaimHostRequest
+	37, // 45: walker.Datastore.ReleaseHost:input_type -> walker.ReleaseHostRequest
+	39, // 46: walker.Datastore.InsertLinks:input_type -> walker.InsertLinksRequest
+	41, // 47: walker.Datastore.InsertLinksWithTags:input_type -> walker.InsertLinksWithTagsRequest
+	43, // 48: walker.Datastore.FindDomain:input_type -> walker.FindDomainRequest
+	45, // 49: walker.Datastore.ListDomains:input_type -> walker.ListDomainsRequest
+	1,  // 50: walker.Datastore.ClaimNewHost:output_type -> walker.ClaimNewHostResponse
+	3,  // 51: walker.Datastore.UnclaimHost:output_type -> walker.UnclaimHostResponse
+	5,  // 52: walker.Datastore.LinksForHost:output_type -> walker.URL
+	12, // 53: walker.Datastore.StoreURLFetchResults:output_type -> walker.StoreURLFetchResultsResponse
+	14, // 54: walker.Datastore.StoreParsedURLs:output_type -> walker.StoreParsedURLsResponse
+	16, // 55: walker.Datastore.KeepAlive:output_type -> walker.KeepAliveResponse
+	18, // 56: walker.Datastore.Retire:output_type -> walker.RetireResponse
+	21, // 57: walker.Datastore.StoreDomainAssets:output_type -> walker.StoreDomainAssetsResponse
+	24, // 58: walker.Datastore.HostSettings:output_type -> walker.HostSettingsResponse
+	26, // 59: walker.Datastore.RequeueHost:output_type -> walker.RequeueHostResponse
+	28, // 60: walker.Datastore.StoreRobotsFingerprint:output_type -> walker.StoreRobotsFingerprintResponse
+	32, // 61: walker.Datastore.StorePolitenessAudit:output_type -> walker.StorePolitenessAuditResponse
+	34, // 62: walker.Datastore.ErrorCount:output_type -> walker.ErrorCountResponse
+	36, // 63: walker.Datastore.ClaimHost:output_type -> walker.ClaimHostResponse
+	38, // 64: walker.Datastore.ReleaseHost:output_type -> walker.ReleaseHostResponse
+	40, // 65: walker.Datastore.InsertLinks:output_type -> walker.InsertLinksResponse
+	40, // 66: walker.Datastore.InsertLinksWithTags:output_type -> walker.InsertLinksResponse
+	44, // 67: walker.Datastore.FindDomain:output_type -> walker.FindDomainResponse
+	46, // 68: walker.Datastore.ListDomains:output_type -> walker.ListDomainsResponse
+	50, // [50:69] is the sub-list for method output_type
+	31, // [31:50] is the sub-list for method input_type
+	31, // [31:31] is the sub-list for extension type_name
+	31, // [31:31] is the sub-list for extension extendee
+	0,  // [0:31] is the sub-list for field type_name
+}
+
+func init() { file_walker_proto_init() }
+func file_walker_proto_init() {
+	if File_walker_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_walker_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*ClaimNewHostRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*ClaimNewHostResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*UnclaimHostRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*UnclaimHostResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*LinksForHostRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*URL); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*Response); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*HeaderValues); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*FetchTiming); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*TLSInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*FetchResults); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*StoreURLFetchResultsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*StoreURLFetchResultsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[13].Exporter = func(v any, i int) any {
+			switch v := v.(*StoreParsedURLsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[14].Exporter = func(v any, i int) any {
+			switch v := v.(*StoreParsedURLsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[15].Exporter = func(v any, i int) any {
+			switch v := v.(*KeepAliveRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[16].Exporter = func(v any, i int) any {
+			switch v := v.(*KeepAliveResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[17].Exporter = func(v any, i int) any {
+			switch v := v.(*RetireRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[18].Exporter = func(v any, i int) any {
+			switch v := v.(*RetireResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[19].Exporter = func(v any, i int) any {
+			switch v := v.(*DomainAssets); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[20].Exporter = func(v any, i int) any {
+			switch v := v.(*StoreDomainAssetsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[21].Exporter = func(v any, i int) any {
+			switch v := v.(*StoreDomainAssetsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[22].Exporter = func(v any, i int) any {
+			switch v := v.(*HostSettingsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[23].Exporter = func(v any, i int) any {
+			switch v := v.(*HostSettings); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[24].Exporter = func(v any, i int) any {
+			switch v := v.(*HostSettingsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[25].Exporter = func(v any, i int) any {
+			switch v := v.(*RequeueHostRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[26].Exporter = func(v any, i int) any {
+			switch v := v.(*RequeueHostResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[27].Exporter = func(v any, i int) any {
+			switch v := v.(*StoreRobotsFingerprintRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[28].Exporter = func(v any, i int) any {
+			switch v := v.(*StoreRobotsFingerprintResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[29].Exporter = func(v any, i int) any {
+			switch v := v.(*PolitenessEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[30].Exporter = func(v any, i int) any {
+			switch v := v.(*PolitenessAudit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[31].Exporter = func(v any, i int) any {
+			switch v := v.(*StorePolitenessAuditRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[32].Exporter = func(v any, i int) any {
+			switch v := v.(*StorePolitenessAuditResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[33].Exporter = func(v any, i int) any {
+			switch v := v.(*ErrorCountRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[34].Exporter = func(v any, i int) any {
+			switch v := v.(*ErrorCountResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[35].Exporter = func(v any, i int) any {
+			switch v := v.(*ClaimHostRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[36].Exporter = func(v any, i int) any {
+			switch v := v.(*ClaimHostResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[37].Exporter = func(v any, i int) any {
+			switch v := v.(*ReleaseHostRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[38].Exporter = func(v any, i int) any {
+			switch v := v.(*ReleaseHostResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[39].Exporter = func(v any, i int) any {
+			switch v := v.(*InsertLinksRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[40].Exporter = func(v any, i int) any {
+			switch v := v.(*InsertLinksResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[41].Exporter = func(v any, i int) any {
+			switch v := v.(*InsertLinksWithTagsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[42].Exporter = func(v any, i int) any {
+			switch v := v.(*DomainInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[43].Exporter = func(v any, i int) any {
+			switch v := v.(*FindDomainRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[44].Exporter = func(v any, i int) any {
+			switch v := v.(*FindDomainResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[45].Exporter = func(v any, i int) any {
+			switch v := v.(*ListDomainsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_walker_proto_msgTypes[46].Exporter = func(v any, i int) any {
+			switch v := v.(*ListDomainsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_walker_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   49,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_walker_proto_goTypes,
+		DependencyIndexes: file_walker_proto_depIdxs,
+		MessageInfos:      file_walker_proto_msgTypes,
+	}.Build()
+	File_walker_proto = out.File
+	file_walker_proto_rawDesc = nil
+	file_walker_proto_goTypes = nil
+	file_walker_proto_depIdxs = nil
+}
@@ -0,0 +1,818 @@
+// Protocol for running walker fetchers against a remote datastore, see the
+// grpc package
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v4.25.3
+// source: walker.proto
+
+package grpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Datastore_ClaimNewHost_FullMethodName           = "/walker.Datastore/ClaimNewHost"
+	Datastore_UnclaimHost_FullMethodName            = "/walker.Datastore/UnclaimHost"
+	Datastore_LinksForHost_FullMethodName           = "/walker.Datastore/LinksForHost"
+	Datastore_StoreURLFetchResults_FullMethodName   = "/walker.Datastore/StoreURLFetchResults"
+	Datastore_StoreParsedURLs_FullMethodName        = "/walker.Datastore/StoreParsedURLs"
+	Datastore_KeepAlive_FullMethodName              = "/walker.Datastore/KeepAlive"
+	Datastore_Retire_FullMethodName                 = "/walker.Datastore/Retire"
+	Datastore_StoreDomainAssets_FullMethodName      = "/walker.Datastore/StoreDomainAssets"
+	Datastore_HostSettings_FullMethodName           = "/walker.Datastore/HostSettings"
+	Datastore_RequeueHost_FullMethodName            = "/walker.Datastore/RequeueHost"
+	Datastore_StoreRobotsFingerprint_FullMethodName = "/walker.Datastore/StoreRobotsFingerprint"
+	Datastore_StorePolitenessAudit_FullMethodName   = "/walker.Datastore/StorePolitenessAudit"
+	Datastore_ErrorCount_FullMethodName             = "/walker.Datastore/ErrorCount"
+	Datastore_ClaimHost_FullMethodName              = "/walker.Datastore/ClaimHost"
+	Datastore_ReleaseHost_FullMethodName            = "/walker.Datastore/ReleaseHost"
+	Datastore_InsertLinks_FullMethodName            = "/walker.Datastore/InsertLinks"
+	Datastore_InsertLinksWithTags_FullMethodName    = "/walker.Datastore/InsertLinksWithTags"
+	Datastore_FindDomain_FullMethodName             = "/walker.Datastore/FindDomain"
+	Datastore_ListDomains_FullMethodName            = "/walker.Datastore/ListDomains"
+)
+
+// DatastoreClient is the client API for Datastore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Datastore exposes the walker.Datastore calls, plus a few domain queries, of
+// the datastore a Server is backed by
+type DatastoreClient interface {
+	ClaimNewHost(ctx context.Context, in *ClaimNewHostRequest, opts ...grpc.CallOption) (*ClaimNewHostResponse, error)
+	UnclaimHost(ctx context.Context, in *UnclaimHostRequest, opts ...grpc.CallOption) (*UnclaimHostResponse, error)
+	LinksForHost(ctx context.Context, in *LinksForHostRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[URL], error)
+	StoreURLFetchResults(ctx context.Context, in *StoreURLFetchResultsRequest, opts ...grpc.CallOption) (*StoreURLFetchResultsResponse, error)
+	StoreParsedURLs(ctx context.Context, in *StoreParsedURLsRequest, opts ...grpc.CallOption) (*StoreParsedURLsResponse, error)
+	KeepAlive(ctx context.Context, in *KeepAliveRequest, opts ...grpc.CallOption) (*KeepAliveResponse, error)
+	Retire(ctx context.Context, in *RetireRequest, opts ...grpc.CallOption) (*RetireResponse, error)
+	StoreDomainAssets(ctx context.Context, in *StoreDomainAssetsRequest, opts ...grpc.CallOption) (*StoreDomainAssetsResponse, error)
+	HostSettings(ctx context.Context, in *HostSettingsRequest, opts ...grpc.CallOption) (*HostSettingsResponse, error)
+	RequeueHost(ctx context.Context, in *RequeueHostRequest, opts ...grpc.CallOption) (*RequeueHostResponse, error)
+	StoreRobotsFingerprint(ctx context.Context, in *StoreRobotsFingerprintRequest, opts ...grpc.CallOption) (*StoreRobotsFingerprintResponse, error)
+	StorePolitenessAudit(ctx context.Context, in *StorePolitenessAuditRequest, opts ...grpc.CallOption) (*StorePolitenessAuditResponse, error)
+	ErrorCount(ctx context.Context, in *ErrorCountRequest, opts ...grpc.CallOption) (*ErrorCountResponse, error)
+	ClaimHost(ctx context.Context, in *ClaimHostRequest, opts ...grpc.CallOption) (*ClaimHostResponse, error)
+	ReleaseHost(ctx context.Context, in *ReleaseHostRequest, opts ...grpc.CallOption) (*ReleaseHostResponse, error)
+	InsertLinks(ctx context.Context, in *InsertLinksRequest, opts ...grpc.CallOption) (*InsertLinksResponse, error)
+	InsertLinksWithTags(ctx context.Context, in *InsertLinksWithTagsRequest, opts ...grpc.CallOption) (*InsertLinksResponse, error)
+	FindDomain(ctx context.Context, in *FindDomainRequest, opts ...grpc.CallOption) (*FindDomainResponse, error)
+	ListDomains(ctx context.Context, in *ListDomainsRequest, opts ...grpc.CallOption) (*ListDomainsResponse, error)
+}
+
+type datastoreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDatastoreClient(cc grpc.ClientConnInterface) DatastoreClient {
+	return &datastoreClient{cc}
+}
+
+func (c *datastoreClient) ClaimNewHost(ctx context.Context, in *ClaimNewHostRequest, opts ...grpc.CallOption) (*ClaimNewHostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClaimNewHostResponse)
+	err := c.cc.Invoke(ctx, Datastore_ClaimNewHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) UnclaimHost(ctx context.Context, in *UnclaimHostRequest, opts ...grpc.CallOption) (*UnclaimHostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnclaimHostResponse)
+	err := c.cc.Invoke(ctx, Datastore_UnclaimHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) LinksForHost(ctx context.Context, in *LinksForHostRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[URL], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Datastore_ServiceDesc.Streams[0], Datastore_LinksForHost_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[LinksForHostRequest, URL]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Datastore_LinksForHostClient = grpc.ServerStreamingClient[URL]
+
+func (c *datastoreClient) StoreURLFetchResults(ctx context.Context, in *StoreURLFetchResultsRequest, opts ...grpc.CallOption) (*StoreURLFetchResultsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StoreURLFetchResultsResponse)
+	err := c.cc.Invoke(ctx, Datastore_StoreURLFetchResults_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) StoreParsedURLs(ctx context.Context, in *StoreParsedURLsRequest, opts ...grpc.CallOption) (*StoreParsedURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StoreParsedURLsResponse)
+	err := c.cc.Invoke(ctx, Datastore_StoreParsedURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) KeepAlive(ctx context.Context, in *KeepAliveRequest, opts ...grpc.CallOption) (*KeepAliveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeepAliveResponse)
+	err := c.cc.Invoke(ctx, Datastore_KeepAlive_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) Retire(ctx context.Context, in *RetireRequest, opts ...grpc.CallOption) (*RetireResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RetireResponse)
+	err := c.cc.Invoke(ctx, Datastore_Retire_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) StoreDomainAssets(ctx context.Context, in *StoreDomainAssetsRequest, opts ...grpc.CallOption) (*StoreDomainAssetsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StoreDomainAssetsResponse)
+	err := c.cc.Invoke(ctx, Datastore_StoreDomainAssets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) HostSettings(ctx context.Context, in *HostSettingsRequest, opts ...grpc.CallOption) (*HostSettingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HostSettingsResponse)
+	err := c.cc.Invoke(ctx, Datastore_HostSettings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) RequeueHost(ctx context.Context, in *RequeueHostRequest, opts ...grpc.CallOption) (*RequeueHostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RequeueHostResponse)
+	err := c.cc.Invoke(ctx, Datastore_RequeueHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) StoreRobotsFingerprint(ctx context.Context, in *StoreRobotsFingerprintRequest, opts ...grpc.CallOption) (*StoreRobotsFingerprintResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StoreRobotsFingerprintResponse)
+	err := c.cc.Invoke(ctx, Datastore_StoreRobotsFingerprint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) StorePolitenessAudit(ctx context.Context, in *StorePolitenessAuditRequest, opts ...grpc.CallOption) (*StorePolitenessAuditResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StorePolitenessAuditResponse)
+	err := c.cc.Invoke(ctx, Datastore_StorePolitenessAudit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) ErrorCount(ctx context.Context, in *ErrorCountRequest, opts ...grpc.CallOption) (*ErrorCountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ErrorCountResponse)
+	err := c.cc.Invoke(ctx, Datastore_ErrorCount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) ClaimHost(ctx context.Context, in *ClaimHostRequest, opts ...grpc.CallOption) (*ClaimHostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClaimHostResponse)
+	err := c.cc.Invoke(ctx, Datastore_ClaimHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) ReleaseHost(ctx context.Context, in *ReleaseHostRequest, opts ...grpc.CallOption) (*ReleaseHostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReleaseHostResponse)
+	err := c.cc.Invoke(ctx, Datastore_ReleaseHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) InsertLinks(ctx context.Context, in *InsertLinksRequest, opts ...grpc.CallOption) (*InsertLinksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InsertLinksResponse)
+	err := c.cc.Invoke(ctx, Datastore_InsertLinks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) InsertLinksWithTags(ctx context.Context, in *InsertLinksWithTagsRequest, opts ...grpc.CallOption) (*InsertLinksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InsertLinksResponse)
+	err := c.cc.Invoke(ctx, Datastore_InsertLinksWithTags_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) FindDomain(ctx context.Context, in *FindDomainRequest, opts ...grpc.CallOption) (*FindDomainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FindDomainResponse)
+	err := c.cc.Invoke(ctx, Datastore_FindDomain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) ListDomains(ctx context.Context, in *ListDomainsRequest, opts ...grpc.CallOption) (*ListDomainsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDomainsResponse)
+	err := c.cc.Invoke(ctx, Datastore_ListDomains_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DatastoreServer is the server API for Datastore service.
+// All implementations must embed UnimplementedDatastoreServer
+// for forward compatibility.
+//
+// Datastore exposes the walker.Datastore calls, plus a few domain queries, of
+// the datastore a Server is backed by
+type DatastoreServer interface {
+	ClaimNewHost(context.Context, *ClaimNewHostRequest) (*ClaimNewHostResponse, error)
+	UnclaimHost(context.Context, *UnclaimHostRequest) (*UnclaimHostResponse, error)
+	LinksForHost(*LinksForHostRequest, grpc.ServerStreamingServer[URL]) error
+	StoreURLFetchResults(context.Context, *StoreURLFetchResultsRequest) (*StoreURLFetchResultsResponse, error)
+	StoreParsedURLs(context.Context, *StoreParsedURLsRequest) (*StoreParsedURLsResponse, error)
+	KeepAlive(context.Context, *KeepAliveRequest) (*KeepAliveResponse, error)
+	Retire(context.Context, *RetireRequest) (*RetireResponse, error)
+	StoreDomainAssets(context.Context, *StoreDomainAssetsRequest) (*StoreDomainAssetsResponse, error)
+	HostSettings(context.Context, *HostSettingsRequest) (*HostSettingsResponse, error)
+	RequeueHost(context.Context, *RequeueHostRequest) (*RequeueHostResponse, error)
+	StoreRobotsFingerprint(context.Context, *StoreRobotsFingerprintRequest) (*StoreRobotsFingerprintResponse, error)
+	StorePolitenessAudit(context.Context, *StorePolitenessAuditRequest) (*StorePolitenessAuditResponse, error)
+	ErrorCount(context.Context, *ErrorCountRequest) (*ErrorCountResponse, error)
+	ClaimHost(context.Context, *ClaimHostRequest) (*ClaimHostResponse, error)
+	ReleaseHost(context.Context, *ReleaseHostRequest) (*ReleaseHostResponse, error)
+	InsertLinks(context.Context, *InsertLinksRequest) (*InsertLinksResponse, error)
+	InsertLinksWithTags(context.Context, *InsertLinksWithTagsRequest) (*InsertLinksResponse, error)
+	FindDomain(context.Context, *FindDomainRequest) (*FindDomainResponse, error)
+	ListDomains(context.Context, *ListDomainsRequest) (*ListDomainsResponse, error)
+	mustEmbedUnimplementedDatastoreServer()
+}
+
+// UnimplementedDatastoreServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDatastoreServer struct{}
+
+func (UnimplementedDatastoreServer) ClaimNewHost(context.Context, *ClaimNewHostRequest) (*ClaimNewHostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClaimNewHost not implemented")
+}
+func (UnimplementedDatastoreServer) UnclaimHost(context.Context, *UnclaimHostRequest) (*UnclaimHostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnclaimHost not implemented")
+}
+func (UnimplementedDatastoreServer) LinksForHost(*LinksForHostRequest, grpc.ServerStreamingServer[URL]) error {
+	return status.Errorf(codes.Unimplemented, "method LinksForHost not implemented")
+}
+func (UnimplementedDatastoreServer) StoreURLFetchResults(context.Context, *StoreURLFetchResultsRequest) (*StoreURLFetchResultsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StoreURLFetchResults not implemented")
+}
+func (UnimplementedDatastoreServer) StoreParsedURLs(context.Context, *StoreParsedURLsRequest) (*StoreParsedURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StoreParsedURLs not implemented")
+}
+func (UnimplementedDatastoreServer) KeepAlive(context.Context, *KeepAliveRequest) (*KeepAliveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method KeepAlive not implemented")
+}
+func (UnimplementedDatastoreServer) Retire(context.Context, *RetireRequest) (*RetireResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Retire not implemented")
+}
+func (UnimplementedDatastoreServer) StoreDomainAssets(context.Context, *StoreDomainAssetsRequest) (*StoreDomainAssetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StoreDomainAssets not implemented")
+}
+func (UnimplementedDatastoreServer) HostSettings(context.Context, *HostSettingsRequest) (*HostSettingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HostSettings not implemented")
+}
+func (UnimplementedDatastoreServer) RequeueHost(context.Context, *RequeueHostRequest) (*RequeueHostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequeueHost not implemented")
+}
+func (UnimplementedDatastoreServer) StoreRobotsFingerprint(context.Context, *StoreRobotsFingerprintRequest) (*StoreRobotsFingerprintResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StoreRobotsFingerprint not implemented")
+}
+func (UnimplementedDatastoreServer) StorePolitenessAudit(context.Context, *StorePolitenessAuditRequest) (*StorePolitenessAuditResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StorePolitenessAudit not implemented")
+}
+func (UnimplementedDatastoreServer) ErrorCount(context.Context, *ErrorCountRequest) (*ErrorCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ErrorCount not implemented")
+}
+func (UnimplementedDatastoreServer) ClaimHost(context.Context, *ClaimHostRequest) (*ClaimHostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClaimHost not implemented")
+}
+func (UnimplementedDatastoreServer) ReleaseHost(context.Context, *ReleaseHostRequest) (*ReleaseHostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseHost not implemented")
+}
+func (UnimplementedDatastoreServer) InsertLinks(context.Context, *InsertLinksRequest) (*InsertLinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InsertLinks not implemented")
+}
+func (UnimplementedDatastoreServer) InsertLinksWithTags(context.Context, *InsertLinksWithTagsRequest) (*InsertLinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InsertLinksWithTags not implemented")
+}
+func (UnimplementedDatastoreServer) FindDomain(context.Context, *FindDomainRequest) (*FindDomainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindDomain not implemented")
+}
+func (UnimplementedDatastoreServer) ListDomains(context.Context, *ListDomainsRequest) (*ListDomainsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDomains not implemented")
+}
+func (UnimplementedDatastoreServer) mustEmbedUnimplementedDatastoreServer() {}
+func (UnimplementedDatastoreServer) testEmbeddedByValue()                   {}
+
+// UnsafeDatastoreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DatastoreServer will
+// result in compilation errors.
+type UnsafeDatastoreServer interface {
+	mustEmbedUnimplementedDatastoreServer()
+}
+
+func RegisterDatastoreServer(s grpc.ServiceRegistrar, srv DatastoreServer) {
+	// If the following call pancis, it indicates UnimplementedDatastoreServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Datastore_ServiceDesc, srv)
+}
+
+func _Datastore_ClaimNewHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClaimNewHostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).ClaimNewHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_ClaimNewHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).ClaimNewHost(ctx, req.(*ClaimNewHostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_UnclaimHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnclaimHostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).UnclaimHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_UnclaimHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).UnclaimHost(ctx, req.(*UnclaimHostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_LinksForHost_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LinksForHostRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DatastoreServer).LinksForHost(m, &grpc.GenericServerStream[LinksForHostRequest, URL]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Datastore_LinksForHostServer = grpc.ServerStreamingServer[URL]
+
+func _Datastore_StoreURLFetchResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreURLFetchResultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).StoreURLFetchResults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_StoreURLFetchResults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).StoreURLFetchResults(ctx, req.(*StoreURLFetchResultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_StoreParsedURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreParsedURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).StoreParsedURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_StoreParsedURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).StoreParsedURLs(ctx, req.(*StoreParsedURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_KeepAlive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeepAliveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).KeepAlive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_KeepAlive_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).KeepAlive(ctx, req.(*KeepAliveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_Retire_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetireRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).Retire(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_Retire_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).Retire(ctx, req.(*RetireRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_StoreDomainAssets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreDomainAssetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).StoreDomainAssets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_StoreDomainAssets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).StoreDomainAssets(ctx, req.(*StoreDomainAssetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_HostSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostSettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).HostSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_HostSettings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).HostSettings(ctx, req.(*HostSettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_RequeueHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequeueHostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).RequeueHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_RequeueHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).RequeueHost(ctx, req.(*RequeueHostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_StoreRobotsFingerprint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreRobotsFingerprintRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).StoreRobotsFingerprint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_StoreRobotsFingerprint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).StoreRobotsFingerprint(ctx, req.(*StoreRobotsFingerprintRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_StorePolitenessAudit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StorePolitenessAuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).StorePolitenessAudit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_StorePolitenessAudit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).StorePolitenessAudit(ctx, req.(*StorePolitenessAuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_ErrorCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ErrorCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).ErrorCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_ErrorCount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).ErrorCount(ctx, req.(*ErrorCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_ClaimHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClaimHostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).ClaimHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_ClaimHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).ClaimHost(ctx, req.(*ClaimHostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_ReleaseHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseHostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).ReleaseHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_ReleaseHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).ReleaseHost(ctx, req.(*ReleaseHostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_InsertLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertLinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).InsertLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_InsertLinks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).InsertLinks(ctx, req.(*InsertLinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_InsertLinksWithTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertLinksWithTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).InsertLinksWithTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_InsertLinksWithTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).InsertLinksWithTags(ctx, req.(*InsertLinksWithTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_FindDomain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindDomainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).FindDomain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_FindDomain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).FindDomain(ctx, req.(*FindDomainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_ListDomains_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDomainsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).ListDomains(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_ListDomains_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).ListDomains(ctx, req.(*ListDomainsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Datastore_ServiceDesc is the grpc.ServiceDesc for Datastore service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Datastore_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "walker.Datastore",
+	HandlerType: (*DatastoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ClaimNewHost",
+			Handler:    _Datastore_ClaimNewHost_Handler,
+		},
+		{
+			MethodName: "UnclaimHost",
+			Handler:    _Datastore_UnclaimHost_Handler,
+		},
+		{
+			MethodName: "StoreURLFetchResults",
+			Handler:    _Datastore_StoreURLFetchResults_Handler,
+		},
+		{
+			MethodName: "StoreParsedURLs",
+			Handler:    _Datastore_StoreParsedURLs_Handler,
+		},
+		{
+			MethodName: "KeepAlive",
+			Handler:    _Datastore_KeepAlive_Handler,
+		},
+		{
+			MethodName: "Retire",
+			Handler:    _Datastore_Retire_Handler,
+		},
+		{
+			MethodName: "StoreDomainAssets",
+			Handler:    _Datastore_StoreDomainAssets_Handler,
+		},
+		{
+			MethodName: "HostSettings",
+			Handler:    _Datastore_HostSettings_Handler,
+		},
+		{
+			MethodName: "RequeueHost",
+			Handler:    _Datastore_RequeueHost_Handler,
+		},
+		{
+			MethodName: "StoreRobotsFingerprint",
+			Handler:    _Datastore_StoreRobotsFingerprint_Handler,
+		},
+		{
+			MethodName: "StorePolitenessAudit",
+			Handler:    _Datastore_StorePolitenessAudit_Handler,
+		},
+		{
+			MethodName: "ErrorCount",
+			Handler:    _Datastore_ErrorCount_Handler,
+		},
+		{
+			MethodName: "ClaimHost",
+			Handler:    _Datastore_ClaimHost_Handler,
+		},
+		{
+			MethodName: "ReleaseHost",
+			Handler:    _Datastore_ReleaseHost_Handler,
+		},
+		{
+			MethodName: "InsertLinks",
+			Handler:    _Datastore_InsertLinks_Handler,
+		},
+		{
+			MethodName: "InsertLinksWithTags",
+			Handler:    _Datastore_InsertLinksWithTags_Handler,
+		},
+		{
+			MethodName: "FindDomain",
+			Handler:    _Datastore_FindDomain_Handler,
+		},
+		{
+			MethodName: "ListDomains",
+			Handler:    _Datastore_ListDomains_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "LinksForHost",
+			Handler:       _Datastore_LinksForHost_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "walker.proto",
+}
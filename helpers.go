@@ -300,6 +300,19 @@ func response200() *http.Response {
 	}
 }
 
+func responseMetaRefresh(delay int, link string) *http.Response {
+	res := response200()
+	res.Body = ioutil.NopCloser(strings.NewReader(fmt.Sprintf(
+		`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="refresh" content="%d; url=%s">
+<title>Moved</title>
+</head>
+</html>`, delay, link)))
+	return res
+}
+
 // mapRoundTrip maps input links --> http.Response. See TestRedirects for example.
 type mapRoundTrip struct {
 	Responses map[string]*http.Response
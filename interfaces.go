@@ -1,5 +1,10 @@
 package walker
 
+import (
+	"context"
+	"io"
+)
+
 // Handler defines the interface for objects that will be set as handlers on a
 // FetchManager.
 type Handler interface {
@@ -8,7 +13,81 @@ type Handler interface {
 	// be called as long as the request successfully reached the remote server
 	// and got an HTTP code. This means there should never be a FetchError set
 	// on the FetchResults.
-	HandleResponse(res *FetchResults)
+	//
+	// ctx is the context the FetchManager was started with; handlers doing
+	// slow work should abandon it when ctx is done.
+	HandleResponse(ctx context.Context, res *FetchResults)
+}
+
+// RetryableHandler is a Handler that can report that it failed to handle a
+// response. If the Handler given to a FetchManager implements it,
+// TryHandleResponse is called instead of HandleResponse, and an error (like a
+// panic from either call) causes the response to be retried up to
+// fetcher.handler_retries times (none by default) before it is sent to the
+// dead-letter sink.
+type RetryableHandler interface {
+	Handler
+	TryHandleResponse(ctx context.Context, res *FetchResults) error
+}
+
+// StreamingHandler is a Handler that reads response bodies as they are
+// downloaded, rather than after the fetcher has buffered them in full. If the
+// Handler given to a FetchManager implements it, HandleStream is called
+// instead of HandleResponse, which saves memory on large documents.
+//
+// body is also set as res.Response.Body. The fetcher tees it to compute
+// fingerprints and parse links, and reads whatever the handler leaves unread
+// once it returns, so those results (and res.Body) are only filled in after
+// HandleStream returns. A read error (such as the body exceeding
+// fetcher.max_http_content_size_bytes) is returned from body and stored as
+// the response's FetchError.
+//
+// Bodies that must be inspected before the response is handled (HTML pages
+// when fetcher.honor_meta_noindex or fetcher.meta_refresh_as_redirect is on,
+// and bodies whose Content-Type gets sniffed) are still buffered first, then
+// handed to HandleStream. An error (or a panic) sends a streamed response
+// straight to the dead-letter sink, since its body can't be read again for a
+// retry.
+type StreamingHandler interface {
+	Handler
+	HandleStream(ctx context.Context, res *FetchResults, body io.Reader) error
+}
+
+// Ack is handed to an AckHandler with each response, for it to report when it
+// has finished with the response. Only the first call to Ack or Nack counts;
+// later calls are ignored. Both are safe to call from any goroutine.
+type Ack interface {
+	// Ack reports that the response was handled. Its fetch results are then
+	// stored in the Datastore.
+	Ack()
+
+	// Nack reports that handling the response failed with err. The response
+	// is sent to the dead-letter sink (it is not retried) and its fetch
+	// results are then stored in the Datastore.
+	Nack(err error)
+}
+
+// AckHandler is a Handler that may finish with a response after
+// HandleResponse returns, for example after passing it to a queue. A FetchManager
+// does not consider a link processed (store its fetch results or unclaim its
+// host) until the handler acks or nacks the response; see
+// fetcher.handler_ack_timeout.
+type AckHandler interface {
+	// HandleResponse is called as Handler.HandleResponse is, with ack to be
+	// called once the response has been handled, either before returning or
+	// later. The response body is only valid until HandleResponse returns, so
+	// it must be read by then. An error (or a panic) causes the response to
+	// be retried like a RetryableHandler's, unless it has already been acked
+	// or nacked.
+	HandleResponse(ctx context.Context, res *FetchResults, ack Ack) error
+}
+
+// DeadLetterSink records fetch results that a Handler failed to handle, so the
+// work can be replayed later. See FetchManager.DeadLetters.
+type DeadLetterSink interface {
+	// DeadLetter records that handling res failed with err. Implementations
+	// should at least record the URL and the error.
+	DeadLetter(ctx context.Context, res *FetchResults, err error)
 }
 
 // Datastore defines the interface for an object to be used as walker's datastore.
@@ -16,25 +95,29 @@ type Handler interface {
 // Note that this is for link and metadata storage required to make walker
 // function properly. It has nothing to do with storing fetched content (see
 // `Handler` for that).
+//
+// Every call made by the FetchManager carries the context it was started with.
+// Implementations should abandon work when the context is done and respect
+// any deadline it carries.
 type Datastore interface {
 	// ClaimNewHost returns a hostname that is now claimed for this crawler to
 	// crawl. A segment of links for this host is assumed to be available.
 	// Returns the domain of the segment it claimed, or "" if there are none
 	// available.
-	ClaimNewHost() string
+	ClaimNewHost(ctx context.Context) string
 
 	// UnclaimHost indicates that all links from `LinksForHost` have been
 	// processed, so other work may be done with this host. For example the
 	// dispatcher will be free analyze the links and generate a new segment.
-	UnclaimHost(host string)
+	UnclaimHost(ctx context.Context, host string)
 
 	// LinksForHost returns a channel that will feed URLs for a given host.
-	LinksForHost(host string) <-chan *URL
+	LinksForHost(ctx context.Context, host string) <-chan *URL
 
 	// StoreURLFetchResults takes the return data/metadata from a fetch and
 	// stores the visit. Fetchers will call this once for each link in the
 	// segment being crawled.
-	StoreURLFetchResults(fr *FetchResults)
+	StoreURLFetchResults(ctx context.Context, fr *FetchResults)
 
 	// StoreParsedURL stores a URL parsed out of a page (i.e. a URL we may not
 	// have crawled yet). `u` is the URL to store. `fr` is the FetchResults
@@ -46,17 +129,116 @@ type Datastore interface {
 	//
 	// This layer should handle efficiently deduplicating
 	// links (i.e. a fetcher should be safe feeding the same URL many times.
-	StoreParsedURL(u *URL, fr *FetchResults)
+	StoreParsedURL(ctx context.Context, u *URL, fr *FetchResults)
 
 	// KeepAlive will be called periodically in fetcher. This method should
 	// notify the datastore that this fetcher is still alive.
-	KeepAlive() error
+	KeepAlive(ctx context.Context) error
 
 	// Close will be called when no more Datastore calls will be made, allowing
 	// any necessary cleanup to take place.
 	Close()
 }
 
+// BatchDatastore is a Datastore that can store all the links parsed from a
+// page at once. If the Datastore given to a FetchManager implements it,
+// StoreParsedURLs is called once per page instead of StoreParsedURL once per
+// link, so the links can be written together.
+type BatchDatastore interface {
+	Datastore
+	StoreParsedURLs(ctx context.Context, urls []*URL, fr *FetchResults)
+}
+
+// RetiringDatastore is a Datastore that can retire the crawler using it.
+// FetchManager.Drain calls Retire once its fetchers have finished, so a
+// crawler can be shut down or restarted without leaving hosts claimed until
+// the dispatcher notices it is gone.
+type RetiringDatastore interface {
+	Datastore
+
+	// Retire releases every host still claimed by this crawler and removes
+	// it from the active fetchers. The Datastore may be used again
+	// afterwards, but as a new crawler.
+	Retire(ctx context.Context) error
+}
+
+// AssetDatastore is a Datastore that can record domain-level assets. If the
+// Datastore given to a FetchManager implements it, fetchers fetch the favicon
+// of each host listed in fetcher.favicon_domains when they claim it, and pass
+// what they found to StoreDomainAssets.
+type AssetDatastore interface {
+	Datastore
+	StoreDomainAssets(ctx context.Context, host string, assets *DomainAssets)
+}
+
+// RobotsDatastore is a Datastore that can track changes to robots.txt files.
+// If the Datastore given to a FetchManager implements it, fetchers pass the
+// fnv fingerprint of the robots.txt of each host they claim to
+// StoreRobotsFingerprint; fp is 0 if the host has no robots.txt. Nothing is
+// passed if the robots.txt request failed or got a 5XX status.
+type RobotsDatastore interface {
+	Datastore
+	StoreRobotsFingerprint(ctx context.Context, host string, fp int64)
+}
+
+// HostSettingsDatastore is a Datastore that can override fetcher settings
+// for particular hosts. If the Datastore given to a FetchManager implements
+// it, fetchers crawl each host they claim with the HostSettings it returns
+// (nil if the host overrides nothing).
+type HostSettingsDatastore interface {
+	Datastore
+	HostSettings(ctx context.Context, host string) *HostSettings
+}
+
+// RequeueDatastore is a Datastore that can take back the links of a host the
+// fetcher stopped crawling early. If the Datastore given to a FetchManager
+// implements it, fetchers that give up on a host after
+// fetcher.max_time_per_host pass the links left in its segment to RequeueHost
+// instead of calling UnclaimHost, so they are crawled later rather than
+// waiting for the dispatcher to queue them again.
+type RequeueDatastore interface {
+	Datastore
+	RequeueHost(ctx context.Context, host string, links []*URL)
+}
+
+// HostClaimingDatastore is a Datastore that can claim particular hosts. If
+// the Datastore given to a FetchManager with a URLList implements it, fetchers
+// claim each host of the list with ClaimHost before crawling it, waiting while
+// it is claimed by another crawler, and release it with ReleaseHost after.
+type HostClaimingDatastore interface {
+	Datastore
+
+	// ClaimHost claims host for this crawler, without taking the segment of
+	// links the Datastore may have for it. It returns false if host is
+	// claimed by another crawler (or the claim failed). A host the Datastore
+	// does not know has nothing to claim, and ClaimHost returns true.
+	ClaimHost(ctx context.Context, host string) bool
+
+	// ReleaseHost releases a host claimed with ClaimHost, leaving its
+	// segment, if it has one, to be crawled as usual.
+	ReleaseHost(ctx context.Context, host string)
+}
+
+// PolitenessDatastore is a Datastore that keeps an audit of how politely
+// hosts were crawled. If the Datastore given to a FetchManager implements it,
+// fetchers pass a PolitenessAudit of each host they crawl to
+// StorePolitenessAudit before unclaiming it.
+type PolitenessDatastore interface {
+	Datastore
+	StorePolitenessAudit(ctx context.Context, audit *PolitenessAudit)
+}
+
+// ErrorCountingDatastore is a Datastore that counts its failed operations. If
+// the Datastore given to a FetchManager implements it and fetcher
+// autoscaling is enabled (see fetcher.max_simultaneous_fetchers), the
+// FetchManager runs fewer fetchers while the Datastore is failing.
+type ErrorCountingDatastore interface {
+	Datastore
+
+	// ErrorCount returns the number of operations that have failed so far.
+	ErrorCount() int64
+}
+
 // Dispatcher defines the calls a dispatcher should respond to. A dispatcher
 // would typically be paired with a particular Datastore, and not all Datastore
 // implementations may need a Dispatcher.
@@ -0,0 +1,111 @@
+package walker
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats counts the connections page fetches in this process were sent
+// over
+type ConnStats struct {
+	// Number of requests sent over an idle kept-alive connection
+	Reused int64
+
+	// Number of requests that had to open a new connection
+	Established int64
+
+	// Number of fetches sent with the keep-alive and no-keep-alive
+	// transports when http_keep_alive is "threshold" or "auto"
+	KeepAliveFetches   int64
+	NoKeepAliveFetches int64
+}
+
+// String formats ConnStats for logging
+func (s ConnStats) String() string {
+	return fmt.Sprintf("%d reused connections, %d established, %d keep-alive fetches, %d no-keep-alive fetches",
+		s.Reused, s.Established, s.KeepAliveFetches, s.NoKeepAliveFetches)
+}
+
+var connStats struct {
+	reused             int64
+	established        int64
+	keepAliveFetches   int64
+	noKeepAliveFetches int64
+}
+
+// CurrentConnStats returns the connection counters accumulated since this
+// process started
+func CurrentConnStats() ConnStats {
+	return ConnStats{
+		Reused:             atomic.LoadInt64(&connStats.reused),
+		Established:        atomic.LoadInt64(&connStats.established),
+		KeepAliveFetches:   atomic.LoadInt64(&connStats.keepAliveFetches),
+		NoKeepAliveFetches: atomic.LoadInt64(&connStats.noKeepAliveFetches),
+	}
+}
+
+// countConn records a connection got for a request
+func countConn(reused bool) {
+	if reused {
+		atomic.AddInt64(&connStats.reused, 1)
+	} else {
+		atomic.AddInt64(&connStats.established, 1)
+	}
+}
+
+// keepAliveTuner picks keep-alive or not for the fetches of one host when
+// http_keep_alive is "auto". It tracks how far apart the host's fetches
+// actually are (crawl delay, fetch and handling time included) and learns the
+// host's keep-alive threshold, starting from http_keep_alive_threshold: a
+// connection reused after a longer interval raises it, and one the host closed
+// before a shorter interval lowers it.
+type keepAliveTuner struct {
+	threshold time.Duration
+
+	// moving average of the intervals between fetches, 0 until there have
+	// been two
+	interval time.Duration
+
+	// when the last fetch started, and whether it was kept alive
+	last          time.Time
+	lastKeptAlive bool
+}
+
+// keepAlive returns true if the next fetch should use the keep-alive
+// transport. Until the host has been fetched twice its crawl delay stands in
+// for the interval.
+func (k *keepAliveTuner) keepAlive(initial, crawlDelay time.Duration) bool {
+	if k.last.IsZero() {
+		k.threshold = initial
+	}
+	if k.interval == 0 {
+		return crawlDelay <= k.threshold
+	}
+	return k.interval <= k.threshold
+}
+
+// observe records a fetch started at start, whether it was sent with the
+// keep-alive transport and whether it reused a connection
+func (k *keepAliveTuner) observe(start time.Time, keptAlive, reused bool) {
+	if !k.last.IsZero() {
+		i := start.Sub(k.last)
+		if k.interval == 0 {
+			k.interval = i
+		} else {
+			k.interval = (3*k.interval + i) / 4
+		}
+
+		// Only a fetch following a kept-alive one had an idle connection to
+		// reuse
+		if keptAlive && k.lastKeptAlive {
+			if reused && i > k.threshold {
+				k.threshold = i
+			} else if !reused && i <= k.threshold {
+				k.threshold = i * 3 / 4
+			}
+		}
+	}
+	k.last = start
+	k.lastKeptAlive = keptAlive
+}
@@ -0,0 +1,91 @@
+package walker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+func TestKeepAliveTuner(t *testing.T) {
+	k := &keepAliveTuner{}
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	if !k.keepAlive(15*time.Second, 10*time.Second) {
+		t.Errorf("Expected keep-alive from a crawl delay under the threshold")
+	}
+	if k.keepAlive(15*time.Second, 20*time.Second) {
+		t.Errorf("Expected no keep-alive from a crawl delay over the threshold")
+	}
+
+	// Fetches 10s apart that reuse their connection
+	k.observe(at(0), true, false)
+	k.observe(at(10*time.Second), true, true)
+	if k.interval != 10*time.Second || k.threshold != 15*time.Second {
+		t.Errorf("Expected interval 10s and threshold 15s, got %v and %v", k.interval, k.threshold)
+	}
+
+	// A connection reused after 20s raises the threshold
+	k.observe(at(30*time.Second), true, true)
+	if k.threshold != 20*time.Second {
+		t.Errorf("Expected threshold raised to 20s, got %v", k.threshold)
+	}
+	if !k.keepAlive(15*time.Second, time.Minute) {
+		t.Errorf("Expected keep-alive from the observed interval (%v), not the crawl delay", k.interval)
+	}
+
+	// The host closing the connection within 12s lowers it
+	k.observe(at(42*time.Second), true, false)
+	if k.threshold != 9*time.Second {
+		t.Errorf("Expected threshold lowered to 9s, got %v", k.threshold)
+	}
+	if k.keepAlive(15*time.Second, time.Second) {
+		t.Errorf("Expected no keep-alive once the interval (%v) is over the threshold", k.interval)
+	}
+
+	// Without keep-alive there is no connection to reuse, so the threshold
+	// stays put
+	k.observe(at(50*time.Second), false, false)
+	k.observe(at(51*time.Second), true, false)
+	if k.threshold != 9*time.Second {
+		t.Errorf("Expected threshold to stay 9s, got %v", k.threshold)
+	}
+}
+
+func TestConnStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	before := CurrentConnStats()
+	var tracers []*fetchTracer
+	for i := 0; i < 2; i++ {
+		tracer := &fetchTracer{timing: &FetchTiming{}}
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), tracer.clientTrace()))
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		tracers = append(tracers, tracer)
+	}
+
+	if tracers[0].reused || !tracers[1].reused {
+		t.Errorf("Expected the second request to reuse the first's connection, got reused %v and %v",
+			tracers[0].reused, tracers[1].reused)
+	}
+	after := CurrentConnStats()
+	if after.Established-before.Established != 1 || after.Reused-before.Reused != 1 {
+		t.Errorf("Expected 1 established and 1 reused connection, got %v", after)
+	}
+}
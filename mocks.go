@@ -2,6 +2,7 @@ package walker
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -11,26 +12,29 @@ import (
 )
 
 // MockDatastore implements walker's Datastore interface for testing.
+//
+// Context arguments are not passed on to Called, so expectations only need to
+// list the remaining arguments.
 type MockDatastore struct {
 	mock.Mock
 }
 
-func (ds *MockDatastore) StoreParsedURL(u *URL, fr *FetchResults) {
+func (ds *MockDatastore) StoreParsedURL(ctx context.Context, u *URL, fr *FetchResults) {
 	ds.Mock.Called(u, fr)
 }
 
-func (ds *MockDatastore) StoreURLFetchResults(fr *FetchResults) {
+func (ds *MockDatastore) StoreURLFetchResults(ctx context.Context, fr *FetchResults) {
 	ds.Mock.Called(fr)
 }
 
 // ClaimNewHost implements walker.Datastore interface
-func (ds *MockDatastore) ClaimNewHost() string {
+func (ds *MockDatastore) ClaimNewHost(ctx context.Context) string {
 	args := ds.Mock.Called()
 	return args.String(0)
 }
 
 // UnclaimHost implements walker.Datastore interface
-func (ds *MockDatastore) UnclaimHost(host string) {
+func (ds *MockDatastore) UnclaimHost(ctx context.Context, host string) {
 	ds.Mock.Called(host)
 }
 
@@ -40,7 +44,7 @@ func (ds *MockDatastore) UnclaimAll() error {
 	return args.Error(0)
 }
 
-func (ds *MockDatastore) LinksForHost(domain string) <-chan *URL {
+func (ds *MockDatastore) LinksForHost(ctx context.Context, domain string) <-chan *URL {
 	args := ds.Mock.Called(domain)
 	urls := args.Get(0).([]*URL)
 	ch := make(chan *URL, len(urls))
@@ -52,21 +56,33 @@ func (ds *MockDatastore) LinksForHost(domain string) <-chan *URL {
 }
 
 // KeepAlive implements walker.Datastore interface
-func (ds *MockDatastore) KeepAlive() error {
+func (ds *MockDatastore) KeepAlive(ctx context.Context) error {
 	ds.Mock.Called()
 	return nil
 }
 
+// Retire implements walker.RetiringDatastore interface
+func (ds *MockDatastore) Retire(ctx context.Context) error {
+	args := ds.Mock.Called()
+	return args.Error(0)
+}
+
+// StoreDomainAssets implements walker.AssetDatastore interface
+func (ds *MockDatastore) StoreDomainAssets(ctx context.Context, host string, assets *DomainAssets) {
+	ds.Mock.Called(host, assets)
+}
+
 func (ds *MockDatastore) Close() {
 	ds.Mock.Called()
 }
 
-// MockHandler implements the walker.Handler interface
+// MockHandler implements the walker.Handler interface. As with MockDatastore,
+// the context argument is not passed on to Called.
 type MockHandler struct {
 	mock.Mock
 }
 
-func (h *MockHandler) HandleResponse(fr *FetchResults) {
+func (h *MockHandler) HandleResponse(ctx context.Context, fr *FetchResults) {
 	// Copy response body so that the fetcher code can reuse readBuffer
 	var buffer bytes.Buffer
 	_, err := buffer.ReadFrom(fr.Response.Body)
@@ -220,6 +236,11 @@ func (s *MockHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		res.ContentType = "text/html"
 	}
 
+	for key, values := range res.Headers {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
 	w.Header().Set("Content-Type", res.ContentType)
 	if res.ContentLength != 0 {
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", res.ContentLength))
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"code.google.com/p/go.net/html"
@@ -20,6 +21,10 @@ import (
 // intended to have Parse() called on it, which will populate it's member
 // variables for reading.
 type HTMLParser struct {
+	// If true, Links are kept as written in the page instead of normalized,
+	// ex. to see what normalization changes. Parse does not reset it.
+	KeepRawLinks bool
+
 	// A concatenation of all text, excluding content from script/style tags
 	Text []byte
 	// A list of links found on the parsed page
@@ -28,6 +33,121 @@ type HTMLParser struct {
 	HasMetaNoIndex bool
 	// true if <meta name="ROBOTS" content="nofollow"> was found
 	HasMetaNoFollow bool
+	// true if <meta name="ROBOTS" content="noarchive"> was found
+	HasMetaNoArchive bool
+	// true if <meta name="ROBOTS" content="nosnippet"> was found
+	HasMetaNoSnippet bool
+	// The target of the first <meta http-equiv="refresh"> tag found, or nil
+	MetaRefresh *URL
+	// The delay, in seconds, of the MetaRefresh
+	MetaRefreshDelay int
+	// The text of the page's first <title> tag, if any
+	Title string
+	// The content of the <meta name="description"> tag, if any
+	Description string
+	// The lang attribute of the <html> tag (ex. "en-US"), if any
+	Lang string
+	// The targets of <link rel="icon"> (or "shortcut icon",
+	// "apple-touch-icon") tags found
+	Icons []*URL
+	// Why parsing was stopped before the end of the page, if it was: the
+	// page had more than fetcher.max_html_nodes tags, or nested them deeper
+	// than fetcher.max_html_depth. Empty if the whole page was parsed.
+	Aborted string
+}
+
+// voidElements are the HTML elements that have no end tag, so they do not
+// count towards the nesting depth of the tags following them
+var voidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"keygen": true,
+	"link":   true,
+	"meta":   true,
+	"param":  true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
+
+// impliedEnd says which open elements a start tag closes, for the elements
+// whose end tag HTML lets pages leave out (ex. a <li> closes the previous
+// <li> of its list). The closest open element in closes is closed, along with
+// those opened after it, unless an element in scope is open in between.
+type impliedEnd struct {
+	closes map[string]bool
+	scope  map[string]bool
+}
+
+func tagSet(tags ...string) map[string]bool {
+	set := map[string]bool{}
+	for _, t := range tags {
+		set[t] = true
+	}
+	return set
+}
+
+var impliedEnds = func() map[string]impliedEnd {
+	ends := map[string]impliedEnd{
+		"body":     {tagSet("head"), tagSet("html")},
+		"li":       {tagSet("li"), tagSet("ul", "ol", "menu")},
+		"dt":       {tagSet("dt", "dd"), tagSet("dl")},
+		"dd":       {tagSet("dt", "dd"), tagSet("dl")},
+		"option":   {tagSet("option"), tagSet("select", "datalist", "optgroup")},
+		"optgroup": {tagSet("option", "optgroup"), tagSet("select")},
+		"tr":       {tagSet("tr"), tagSet("table", "thead", "tbody", "tfoot")},
+		"td":       {tagSet("td", "th"), tagSet("tr", "table")},
+		"th":       {tagSet("td", "th"), tagSet("tr", "table")},
+		"thead":    {tagSet("thead", "tbody", "tfoot"), tagSet("table")},
+		"tbody":    {tagSet("thead", "tbody", "tfoot"), tagSet("table")},
+		"tfoot":    {tagSet("thead", "tbody", "tfoot"), tagSet("table")},
+	}
+	// Block elements close an open <p>
+	pScope := tagSet("button", "table", "td", "th", "caption", "object", "template", "html")
+	for _, t := range []string{"address", "article", "aside", "blockquote", "details", "div", "dl",
+		"fieldset", "figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr",
+		"main", "nav", "ol", "p", "pre", "section", "table", "ul"} {
+		ends[t] = impliedEnd{tagSet("p"), pScope}
+	}
+	return ends
+}()
+
+// openElements is the stack of elements a page has open while it is parsed,
+// whose size is its nesting depth
+type openElements []string
+
+// start opens tag, first closing the elements it implies the end of
+func (s *openElements) start(tag string) {
+	if end, ok := impliedEnds[tag]; ok {
+		for i := len(*s) - 1; i >= 0; i-- {
+			if end.closes[(*s)[i]] {
+				*s = (*s)[:i]
+				break
+			} else if end.scope[(*s)[i]] {
+				break
+			}
+		}
+	}
+	if !voidElements[tag] {
+		*s = append(*s, tag)
+	}
+}
+
+// end closes the innermost open tag, along with the elements opened inside it
+// and left unclosed. An end tag that matches no open element is ignored.
+func (s *openElements) end(tag string) {
+	for i := len(*s) - 1; i >= 0; i-- {
+		if (*s)[i] == tag {
+			*s = (*s)[:i]
+			return
+		}
+	}
 }
 
 // Parse parses the given content body as HTML and populates instance variables
@@ -39,6 +159,15 @@ func (p *HTMLParser) Parse(body []byte) {
 	p.Links = []*URL{}
 	p.HasMetaNoIndex = false
 	p.HasMetaNoFollow = false
+	p.HasMetaNoArchive = false
+	p.HasMetaNoSnippet = false
+	p.MetaRefresh = nil
+	p.MetaRefreshDelay = 0
+	p.Title = ""
+	p.Description = ""
+	p.Lang = ""
+	p.Icons = nil
+	p.Aborted = ""
 
 	utf8Reader, err := charset.NewReader(bytes.NewReader(body), "text/html")
 	if err != nil {
@@ -51,6 +180,13 @@ func (p *HTMLParser) Parse(body []byte) {
 	parentTags := map[string]int{}
 	tags := getIncludedTags()
 
+	// Number of tags seen, and the elements open, for
+	// fetcher.max_html_nodes and fetcher.max_html_depth
+	nodes := 0
+	var open openElements
+	maxNodes := Config.Fetcher.MaxHTMLNodes
+	maxDepth := Config.Fetcher.MaxHTMLDepth
+
 	for {
 		tokenType := tokenizer.Next()
 		switch tokenType {
@@ -63,11 +199,20 @@ func (p *HTMLParser) Parse(body []byte) {
 			// Do not store text from inside script/style tags
 			_, inScriptTag := parentTags["script"]
 			_, inStyleTag := parentTags["style"]
+			if inScriptTag && Config.Fetcher.DetectJSRedirects {
+				p.parseScriptRedirects(tokenizer.Text())
+			}
 			if inScriptTag || inStyleTag {
 				continue
 			}
 
 			txt := bytes.TrimSpace(tokenizer.Text())
+			if _, inNoscriptTag := parentTags["noscript"]; inNoscriptTag && Config.Fetcher.DetectJSRedirects {
+				p.parseNoscriptRedirect(txt)
+			}
+			if _, inTitleTag := parentTags["title"]; inTitleTag && p.Title == "" {
+				p.Title = string(bytes.Join(bytes.Fields(txt), []byte(" ")))
+			}
 			if len(txt) > 0 {
 				if len(p.Text) > 0 {
 					p.Text = append(p.Text, []byte("\n\n")...)
@@ -78,6 +223,11 @@ func (p *HTMLParser) Parse(body []byte) {
 		case html.StartTagToken, html.SelfClosingTagToken:
 			tagNameB, hasAttrs := tokenizer.TagName()
 			tagName := string(tagNameB)
+			nodes++
+			if maxNodes > 0 && nodes > maxNodes {
+				p.Aborted = fmt.Sprintf("more than %d tags (see fetcher.max_html_nodes)", maxNodes)
+				return
+			}
 			if tokenType == html.StartTagToken {
 				num, ok := parentTags[tagName]
 				if ok {
@@ -85,6 +235,17 @@ func (p *HTMLParser) Parse(body []byte) {
 				} else {
 					parentTags[tagName] = 1
 				}
+				open.start(tagName)
+				if maxDepth > 0 && len(open) > maxDepth {
+					p.Aborted = fmt.Sprintf("tags nested more than %d deep (see fetcher.max_html_depth)", maxDepth)
+					return
+				}
+			}
+			if hasAttrs && tagName == "html" && p.Lang == "" {
+				p.parseHTMLAttrs(tokenizer)
+			}
+			if hasAttrs && tagName == "link" {
+				p.parseLinkAttrs(tokenizer)
 			}
 			if hasAttrs && tags[tagName] {
 				switch tagName {
@@ -125,6 +286,7 @@ func (p *HTMLParser) Parse(body []byte) {
 			} else {
 				delete(parentTags, tagName)
 			}
+			open.end(tagName)
 		}
 	}
 }
@@ -155,16 +317,80 @@ func getIncludedTags() map[string]bool {
 
 // A set of words used by the parse* routines below
 var contentWordBytes = []byte("content")
+var descriptionWordBytes = []byte("description")
 var dataWordBytes = []byte("data")
 var nameWordBytes = []byte("name")
 var noindexWordBytes = []byte("noindex")
 var nofollowWordBytes = []byte("nofollow")
+var noarchiveWordBytes = []byte("noarchive")
+var nosnippetWordBytes = []byte("nosnippet")
 var robotsWordBytes = []byte("robots")
 var srcWordBytes = []byte("src")
 var srcdocWordBytes = []byte("srcdoc")
 var httpEquivWordBytes = []byte("http-equiv")
+var langWordBytes = []byte("lang")
 var refreshWordBytes = []byte("refresh")
-var metaRefreshPattern = regexp.MustCompile(`^\s*\d+;\s*url=(.*)`)
+var relWordBytes = []byte("rel")
+var iconRelWordBytes = [][]byte{[]byte("icon"), []byte("apple-touch-icon")}
+var nofollowRelWordBytes = [][]byte{[]byte("nofollow"), []byte("ugc"), []byte("sponsored")}
+
+// metaRefreshPattern matches the content of a meta refresh tag, capturing
+// the delay and the URL. It is matched against the raw content so the URL
+// keeps its case.
+var metaRefreshPattern = regexp.MustCompile(`(?i)^\s*(\d+);\s*url=(.*)`)
+
+// jsRedirectPatterns match common JavaScript redirects, capturing the target:
+// assignments to (window.)location(.href) and calls to location.replace() or
+// location.assign()
+var jsRedirectPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\blocation(?:\.href)?\s*=\s*["']([^"']+)["']`),
+	regexp.MustCompile(`\blocation\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)`),
+}
+
+// parseLink parses a link found in the page, normalizing it unless
+// p.KeepRawLinks is set
+func (p *HTMLParser) parseLink(ref string) (*URL, error) {
+	if p.KeepRawLinks {
+		return ParseURL(ref)
+	}
+	return ParseAndNormalizeURL(ref)
+}
+
+// parseScriptRedirects adds the targets of the JavaScript redirects found in
+// script (the contents of a <script> tag) to p.Links
+func (p *HTMLParser) parseScriptRedirects(script []byte) {
+	for _, pat := range jsRedirectPatterns {
+		for _, match := range pat.FindAllSubmatch(script, -1) {
+			p.addJSRedirect(string(match[1]))
+		}
+	}
+}
+
+// parseNoscriptRedirect adds the target of a <meta http-equiv="refresh"> in
+// body (the contents of a <noscript> tag, which the tokenizer does not parse)
+// to p.Links. Pages redirecting with JavaScript often do this for browsers
+// without it.
+func (p *HTMLParser) parseNoscriptRedirect(body []byte) {
+	subParser := &HTMLParser{KeepRawLinks: p.KeepRawLinks}
+	subParser.Parse(body)
+	if subParser.MetaRefresh != nil {
+		p.addJSRedirect(subParser.MetaRefresh.String())
+	}
+}
+
+func (p *HTMLParser) addJSRedirect(target string) {
+	target = strings.TrimSpace(target)
+	if target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(strings.ToLower(target), "javascript:") {
+		return
+	}
+	u, err := p.parseLink(target)
+	if err != nil {
+		log4go.Fine("Failed to parse JavaScript redirect target %q: %v", target, err)
+		return
+	}
+	u.JSRedirect = true
+	p.Links = append(p.Links, u)
+}
 
 // parseIframe grabs links either from the iframe's src attribute or by parsing
 // the embedded srcdoc
@@ -173,7 +399,7 @@ func (p *HTMLParser) parseIframe(tokenizer *html.Tokenizer) {
 	if err != nil {
 		return
 	} else if docsrc {
-		subParser := &HTMLParser{}
+		subParser := &HTMLParser{KeepRawLinks: p.KeepRawLinks}
 		subParser.Parse([]byte(body))
 		if !Config.Fetcher.HonorMetaNofollow || !(subParser.HasMetaNoFollow || p.HasMetaNoFollow) {
 			p.Links = append(p.Links, subParser.Links...)
@@ -181,7 +407,7 @@ func (p *HTMLParser) parseIframe(tokenizer *html.Tokenizer) {
 	} else { //!docsrc
 		if !p.HasMetaNoFollow {
 			var u *URL
-			u, err = ParseAndNormalizeURL(body)
+			u, err = p.parseLink(body)
 			if err != nil {
 				log4go.Fine("parseEmbed failed to parse src: %v", err)
 				return
@@ -214,20 +440,36 @@ func (p *HTMLParser) parseIframeAttrs(tokenizer *html.Tokenizer) (bool, string,
 	return false, "", fmt.Errorf("Failed to find src or srcdoc attribute in iframe tag")
 }
 
+func (p *HTMLParser) parseHTMLAttrs(tokenizer *html.Tokenizer) {
+	for {
+		key, val, moreAttr := tokenizer.TagAttr()
+		if bytes.Compare(key, langWordBytes) == 0 {
+			p.Lang = strings.TrimSpace(string(val))
+		}
+		if !moreAttr {
+			break
+		}
+	}
+}
+
 func (p *HTMLParser) parseMetaAttrs(tokenizer *html.Tokenizer) {
-	var content, httpEquiv []byte
-	var isRobots, noIndex, noFollow bool
+	var content, rawContent, httpEquiv []byte
+	var isRobots, isDescription, noIndex, noFollow, noArchive, noSnippet bool
 	for {
 		key, val, moreAttr := tokenizer.TagAttr()
 		if bytes.Compare(key, nameWordBytes) == 0 {
 			name := bytes.ToLower(val)
 			isRobots = bytes.Compare(name, robotsWordBytes) == 0
+			isDescription = bytes.Compare(name, descriptionWordBytes) == 0
 		} else if bytes.Compare(key, contentWordBytes) == 0 {
+			rawContent = val
 			content = bytes.ToLower(val)
 			// This will match ill-formatted contents like "noindexnofollow",
 			// but I don't expect that to be a big deal.
 			noIndex = bytes.Contains(content, noindexWordBytes)
 			noFollow = bytes.Contains(content, nofollowWordBytes)
+			noArchive = bytes.Contains(content, noarchiveWordBytes)
+			noSnippet = bytes.Contains(content, nosnippetWordBytes)
 		} else if bytes.Compare(key, httpEquivWordBytes) == 0 {
 			httpEquiv = bytes.ToLower(val)
 		}
@@ -237,15 +479,19 @@ func (p *HTMLParser) parseMetaAttrs(tokenizer *html.Tokenizer) {
 	}
 
 	if bytes.Compare(httpEquiv, refreshWordBytes) == 0 && content != nil {
-		results := metaRefreshPattern.FindSubmatch(content)
+		results := metaRefreshPattern.FindSubmatch(rawContent)
 		if results != nil {
-			link := strings.TrimSpace(string(results[1]))
-			u, err := ParseAndNormalizeURL(link)
+			link := strings.TrimSpace(string(results[2]))
+			u, err := p.parseLink(link)
 			if err != nil {
 				log4go.Fine("parseMetaAttrs failed to parse url for %q: %v", link, err)
 
 			} else {
 				p.Links = append(p.Links, u)
+				if p.MetaRefresh == nil {
+					p.MetaRefresh = u
+					p.MetaRefreshDelay, _ = strconv.Atoi(string(results[1]))
+				}
 			}
 		}
 	}
@@ -253,6 +499,12 @@ func (p *HTMLParser) parseMetaAttrs(tokenizer *html.Tokenizer) {
 	if isRobots {
 		p.HasMetaNoIndex = p.HasMetaNoIndex || noIndex
 		p.HasMetaNoFollow = p.HasMetaNoFollow || noFollow
+		p.HasMetaNoArchive = p.HasMetaNoArchive || noArchive
+		p.HasMetaNoSnippet = p.HasMetaNoSnippet || noSnippet
+	}
+
+	if isDescription && p.Description == "" {
+		p.Description = strings.TrimSpace(string(rawContent))
 	}
 
 	return
@@ -263,7 +515,7 @@ func (p *HTMLParser) parseObjectAttrs(tokenizer *html.Tokenizer) {
 	for {
 		key, val, moreAttr := tokenizer.TagAttr()
 		if bytes.Compare(key, dataWordBytes) == 0 {
-			u, err := ParseAndNormalizeURL(strings.TrimSpace(string(val)))
+			u, err := p.parseLink(strings.TrimSpace(string(val)))
 			if err == nil {
 				p.Links = append(p.Links, u)
 			}
@@ -281,7 +533,7 @@ func (p *HTMLParser) parseEmbedAttrs(tokenizer *html.Tokenizer) {
 	for {
 		key, val, moreAttr := tokenizer.TagAttr()
 		if bytes.Compare(key, srcWordBytes) == 0 {
-			u, err := ParseAndNormalizeURL(strings.TrimSpace(string(val)))
+			u, err := p.parseLink(strings.TrimSpace(string(val)))
 			if err == nil {
 				p.Links = append(p.Links, u)
 			}
@@ -297,16 +549,76 @@ func (p *HTMLParser) parseEmbedAttrs(tokenizer *html.Tokenizer) {
 // parseAnchorAttrs iterates over all of the attributes in the current anchor
 // token. It adds links when found in the href attribute.
 func (p *HTMLParser) parseAnchorAttrs(tokenizer *html.Tokenizer) {
+	var u *URL
+	var nofollow bool
 	for {
 		key, val, moreAttr := tokenizer.TagAttr()
 		if bytes.Compare(key, []byte("href")) == 0 {
-			u, err := ParseAndNormalizeURL(strings.TrimSpace(string(val)))
-			if err == nil {
-				p.Links = append(p.Links, u)
+			var err error
+			u, err = p.parseLink(strings.TrimSpace(string(val)))
+			if err != nil {
+				u = nil
 			}
+		} else if bytes.Compare(key, relWordBytes) == 0 {
+			nofollow = isNofollowRel(val)
 		}
 		if !moreAttr {
-			return
+			break
+		}
+	}
+	if u != nil {
+		u.Nofollow = nofollow
+		p.Links = append(p.Links, u)
+	}
+}
+
+// parseLinkAttrs adds the href of a <link> tag to Icons if its rel attribute
+// marks it as an icon
+func (p *HTMLParser) parseLinkAttrs(tokenizer *html.Tokenizer) {
+	var href []byte
+	var icon bool
+	for {
+		key, val, moreAttr := tokenizer.TagAttr()
+		if bytes.Compare(key, []byte("href")) == 0 {
+			href = val
+		} else if bytes.Compare(key, relWordBytes) == 0 {
+			icon = isIconRel(val)
+		}
+		if !moreAttr {
+			break
+		}
+	}
+	if icon && len(href) > 0 {
+		u, err := ParseAndNormalizeURL(strings.TrimSpace(string(href)))
+		if err == nil {
+			p.Icons = append(p.Icons, u)
+		}
+	}
+}
+
+// isIconRel returns true if the rel attribute value marks a <link> as the
+// page's icon
+func isIconRel(rel []byte) bool {
+	for _, kw := range bytes.Fields(bytes.ToLower(rel)) {
+		for _, ic := range iconRelWordBytes {
+			if bytes.Compare(kw, ic) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNofollowRel returns true if the rel attribute value contains one of the
+// keywords asking that the link not be followed or given credit (nofollow,
+// ugc, sponsored)
+func isNofollowRel(rel []byte) bool {
+	for _, kw := range bytes.Fields(bytes.ToLower(rel)) {
+		for _, nf := range nofollowRelWordBytes {
+			if bytes.Compare(kw, nf) == 0 {
+				return true
+			}
 		}
 	}
+	return false
 }
@@ -0,0 +1,150 @@
+package walker
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReplaySource provides previously fetched responses so a crawl can be
+// replayed without the network. See FetchManager.Replay.
+type ReplaySource interface {
+	// StoredResponse returns the latest stored response for u. It should
+	// return ErrNotStored if it has none.
+	StoredResponse(u *URL) (*http.Response, error)
+}
+
+// ErrNotStored is returned by a ReplaySource that has no response for a URL
+var ErrNotStored = fmt.Errorf("no stored response")
+
+// replayTransport is an http.RoundTripper answering requests from a
+// ReplaySource
+type replayTransport struct {
+	src ReplaySource
+}
+
+// NewReplayTransport returns an http.RoundTripper that answers every request
+// from src instead of the network. Requests for URLs src has no response for
+// fail.
+func NewReplayTransport(src ReplaySource) http.RoundTripper {
+	return &replayTransport{src: src}
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := &URL{URL: req.URL, LastCrawled: NotYetCrawled}
+	res, err := rt.src.StoredResponse(u)
+	if err != nil {
+		return nil, fmt.Errorf("replaying %v: %v", req.URL, err)
+	}
+	res.Request = req
+	if res.Body == nil || req.Method == "HEAD" {
+		res.Body = ioutil.NopCloser(bytes.NewReader(nil))
+	}
+	return res, nil
+}
+
+// replayDatastore wraps the Datastore of a replaying FetchManager so it keeps
+// driving the fetchers, but replayed results and parsed links are not stored
+type replayDatastore struct {
+	Datastore
+}
+
+func (ds *replayDatastore) StoreURLFetchResults(ctx context.Context, fr *FetchResults) {}
+
+func (ds *replayDatastore) StoreParsedURL(ctx context.Context, u *URL, fr *FetchResults) {}
+
+// WARCSource is a ReplaySource serving the response records of a WARC file.
+// The whole file is read into memory by NewWARCSource; if it has several
+// responses for a URL the last one is used.
+type WARCSource struct {
+	// URL -> raw HTTP response
+	responses map[string][]byte
+}
+
+// NewWARCSource reads the WARC file at path, which may be gzipped if its name
+// ends in .gz
+func NewWARCSource(path string) (*WARCSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read gzipped WARC %v: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	ws := &WARCSource{responses: map[string][]byte{}}
+	if err := ws.read(r); err != nil {
+		return nil, fmt.Errorf("Failed to read WARC %v: %v", path, err)
+	}
+	return ws, nil
+}
+
+// read parses WARC records from r, keeping the HTTP responses
+func (ws *WARCSource) read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	tp := textproto.NewReader(br)
+	for {
+		line, err := tp.ReadLine()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// Records are separated by blank lines
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			return fmt.Errorf("expected a WARC record, got %q", line)
+		}
+
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return err
+		}
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad Content-Length for record %v: %v", header.Get("WARC-Record-ID"), err)
+		}
+		block := make([]byte, length)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return err
+		}
+
+		if header.Get("WARC-Type") != "response" ||
+			!strings.HasPrefix(header.Get("Content-Type"), "application/http") {
+			continue
+		}
+		u, err := ParseURL(strings.Trim(header.Get("WARC-Target-URI"), "<>"))
+		if err != nil {
+			continue
+		}
+		ws.responses[u.String()] = block
+	}
+}
+
+// StoredResponse implements the ReplaySource interface
+func (ws *WARCSource) StoredResponse(u *URL) (*http.Response, error) {
+	block, ok := ws.responses[u.String()]
+	if !ok {
+		return nil, ErrNotStored
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+}
@@ -0,0 +1,307 @@
+package walker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRobotsSize is how much of a robots.txt file is read; like Google, we
+// ignore anything past the first 500KiB
+const maxRobotsSize = 500 * 1024
+
+// Robots is a parsed robots.txt file. It follows Google's robots.txt
+// specification (RFC 9309): see FindGroup for how a user agent's group is
+// chosen, and RobotsGroup.Match for how rules are applied.
+type Robots struct {
+	// Sitemaps lists the Sitemap URLs given anywhere in the file; they do
+	// not belong to any group
+	Sitemaps []string
+
+	groups []robotsGroupLines
+}
+
+// robotsGroupLines is a group as it appears in the file: the user agents it
+// is for and the lines that follow them
+type robotsGroupLines struct {
+	agents        []string
+	rules         []RobotsRule
+	crawlDelay    time.Duration
+	hasCrawlDelay bool
+}
+
+// RobotsGroup holds the robots.txt rules that apply to one user agent.
+type RobotsGroup struct {
+	Rules []RobotsRule
+
+	// Agent is the User-agent line of the group the rules come from (ex.
+	// "googlebot"), "*" if the * group applied, or empty if no group applied
+	Agent string
+
+	// CrawlDelay is the Crawl-delay of the group; it is only meaningful if
+	// HasCrawlDelay is true
+	CrawlDelay    time.Duration
+	HasCrawlDelay bool
+
+	// RetryTime is set if the group stands in for a robots.txt that answered
+	// with a 5XX or 429 status: it disallows everything until then (see
+	// fetcher.robots_unavailable_period)
+	RetryTime time.Time
+}
+
+// RobotsRule is an Allow or Disallow line of a robots.txt file. Path may use
+// the * (any sequence of characters) and $ (end of the URL) wildcards.
+type RobotsRule struct {
+	Allow bool
+	Path  string
+}
+
+// String returns the rule as it would be written in robots.txt, ex.
+// "Disallow: /private/*"
+func (r RobotsRule) String() string {
+	if r.Allow {
+		return "Allow: " + r.Path
+	}
+	return "Disallow: " + r.Path
+}
+
+// ParseRobots parses the contents of a robots.txt file. Parsing is lenient,
+// like Google's: lines it does not understand are skipped.
+func ParseRobots(r io.Reader) (*Robots, error) {
+	robots := &Robots{}
+	var group *robotsGroupLines
+	// inAgents is true while reading the User-agent lines that start a group
+	inAgents := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	scanner.Buffer(make([]byte, 4096), maxRobotsSize)
+	first := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := splitRobotsLine(line)
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "user-agent", "useragent", "user agent":
+			if !inAgents {
+				robots.groups = append(robots.groups, robotsGroupLines{})
+				group = &robots.groups[len(robots.groups)-1]
+				inAgents = true
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+
+		case "allow", "disallow", "dissallow", "dissalow", "disalow", "diasllow", "disallaw":
+			inAgents = false
+			// Rules before any User-agent line, and empty rules (ex.
+			// "Disallow:", which allows everything), have no effect
+			if group == nil || value == "" {
+				continue
+			}
+			group.rules = append(group.rules, RobotsRule{Allow: key == "allow", Path: value})
+
+		case "crawl-delay", "crawldelay", "crawl delay":
+			inAgents = false
+			if group == nil {
+				continue
+			}
+			secs, err := strconv.ParseFloat(value, 64)
+			if err != nil || secs < 0 {
+				continue
+			}
+			group.crawlDelay = time.Duration(secs * float64(time.Second))
+			group.hasCrawlDelay = true
+
+		case "sitemap", "site-map":
+			robots.Sitemaps = append(robots.Sitemaps, value)
+
+		default:
+			// Unknown lines (ex. Host, Clean-param) don't end the list of
+			// user agents starting a group
+		}
+	}
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return nil, err
+	}
+	return robots, nil
+}
+
+// splitRobotsLine splits a "key: value" line, lower casing the key. The colon
+// may be missing if the key and value are separated by whitespace.
+func splitRobotsLine(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", "", false
+	}
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		i = strings.IndexAny(line, " \t")
+		if i < 0 {
+			return "", "", false
+		}
+	}
+	key = strings.ToLower(strings.TrimSpace(line[:i]))
+	value = strings.TrimSpace(line[i+1:])
+	return key, value, key != ""
+}
+
+// FindGroup returns the rules for the given User-Agent header. The group
+// whose user-agent is the longest prefix of the agent's product token (ex.
+// "walker" for "Walker/1.0 (http://...)") applies, falling back to the *
+// group. Groups for the same user-agent are combined. If no group applies
+// everything is allowed.
+func (r *Robots) FindGroup(userAgent string) *RobotsGroup {
+	token := strings.ToLower(productToken(userAgent))
+
+	best := -1
+	for _, g := range r.groups {
+		for _, agent := range g.agents {
+			if agentMatches(agent, token) && len(agent) > best {
+				best = len(agent)
+			}
+		}
+	}
+
+	grp := &RobotsGroup{}
+	for _, g := range r.groups {
+		matches := false
+		for _, agent := range g.agents {
+			if (best < 0 && agent == "*") || (best >= 0 && len(agent) == best && agentMatches(agent, token)) {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		if best < 0 {
+			grp.Agent = "*"
+		} else {
+			grp.Agent = token[:best]
+		}
+		grp.Rules = append(grp.Rules, g.rules...)
+		if g.hasCrawlDelay && !grp.HasCrawlDelay {
+			grp.CrawlDelay = g.crawlDelay
+			grp.HasCrawlDelay = true
+		}
+	}
+	return grp
+}
+
+// agentMatches returns true if the user-agent of a group names the crawler
+// with the given product token
+func agentMatches(agent, token string) bool {
+	return agent != "" && agent != "*" && strings.HasPrefix(token, agent)
+}
+
+// productToken returns the product name a user agent identifies itself with,
+// ex. "Walker" for "Walker/1.0 (http://github.com/iParadigms/walker)"
+func productToken(userAgent string) string {
+	userAgent = strings.TrimSpace(userAgent)
+	if i := strings.IndexAny(userAgent, "/ \t("); i >= 0 {
+		return userAgent[:i]
+	}
+	return userAgent
+}
+
+// Test returns true if the group allows crawling path, which should be the
+// request URI of the link (path and query).
+func (g *RobotsGroup) Test(path string) bool {
+	rule := g.Match(path)
+	return rule == nil || rule.Allow
+}
+
+// Match returns the rule that decides whether path may be crawled, or nil if
+// no rule matches it (so it may be). The rule with the longest matching path
+// wins; if an Allow and a Disallow rule are equally long, the Allow rule
+// wins. /robots.txt itself is always allowed.
+func (g *RobotsGroup) Match(path string) *RobotsRule {
+	if path == "/robots.txt" {
+		return nil
+	}
+	path = normalizeRobotsPath(path)
+
+	var best *RobotsRule
+	for i := range g.Rules {
+		r := &g.Rules[i]
+		if !robotsPatternMatch(normalizeRobotsPath(r.Path), path) {
+			continue
+		}
+		if best == nil || len(r.Path) > len(best.Path) ||
+			(len(r.Path) == len(best.Path) && r.Allow && !best.Allow) {
+			best = r
+		}
+	}
+	return best
+}
+
+// robotsPatternMatch returns true if pattern matches the start of path (or
+// all of it, if the pattern ends with $)
+func robotsPatternMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	// positions holds every index of path the pattern so far can end at
+	positions := []int{0}
+	for i := 0; i < len(pattern); i++ {
+		var next []int
+		if pattern[i] == '*' {
+			for p := positions[0]; p <= len(path); p++ {
+				next = append(next, p)
+			}
+		} else {
+			for _, p := range positions {
+				if p < len(path) && path[p] == pattern[i] {
+					next = append(next, p+1)
+				}
+			}
+		}
+		if len(next) == 0 {
+			return false
+		}
+		positions = next
+	}
+
+	if !anchored {
+		return true
+	}
+	return positions[len(positions)-1] == len(path)
+}
+
+// normalizeRobotsPath percent-encodes non-ASCII bytes and upper cases the hex
+// digits of percent-encodings, so rules and paths written either way match
+func normalizeRobotsPath(path string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c >= 0x80:
+			fmt.Fprintf(&buf, "%%%02X", c)
+		case c == '%' && i+2 < len(path) && isHex(path[i+1]) && isHex(path[i+2]):
+			buf.WriteByte('%')
+			buf.WriteString(strings.ToUpper(path[i+1 : i+3]))
+			i += 2
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
@@ -0,0 +1,152 @@
+package walker
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// robotsCorpus cases are mostly taken from the examples in Google's robots.txt
+// documentation and RFC 9309
+var robotsCorpus = []struct {
+	tag     string
+	robots  string
+	agent   string
+	path    string
+	allowed bool
+
+	// The deciding rule, if any
+	rule string
+}{
+	{"NoRules", "User-agent: *\n", "Walker", "/page", true, ""},
+	{"DisallowAll", "User-agent: *\nDisallow: /\n", "Walker", "/page", false, "Disallow: /"},
+	{"EmptyDisallow", "User-agent: *\nDisallow:\n", "Walker", "/page", true, ""},
+	{"Prefix", "User-agent: *\nDisallow: /fish\n", "Walker", "/fish.html", false, "Disallow: /fish"},
+	{"PrefixQuery", "User-agent: *\nDisallow: /fish\n", "Walker", "/fish?id=1", false, "Disallow: /fish"},
+	{"PrefixCase", "User-agent: *\nDisallow: /fish\n", "Walker", "/Fish.asp", true, ""},
+	{"PrefixNoMatch", "User-agent: *\nDisallow: /fish\n", "Walker", "/catfish", true, ""},
+	{"TrailingStarIgnored", "User-agent: *\nDisallow: /fish*\n", "Walker", "/fishheads/yummy.html", false, "Disallow: /fish*"},
+	{"Directory", "User-agent: *\nDisallow: /fish/\n", "Walker", "/fish", true, ""},
+	{"DirectoryMatch", "User-agent: *\nDisallow: /fish/\n", "Walker", "/fish/salmon.htm", false, "Disallow: /fish/"},
+	{"NoLeadingSlash", "User-agent: *\nDisallow: fish/\n", "Walker", "/fish/salmon.htm", true, ""},
+	{"Wildcard", "User-agent: *\nDisallow: /*.php\n", "Walker", "/folder/filename.php?params", false, "Disallow: /*.php"},
+	{"WildcardNoMatch", "User-agent: *\nDisallow: /*.php\n", "Walker", "/windows.PHP", true, ""},
+	{"EndAnchor", "User-agent: *\nDisallow: /*.php$\n", "Walker", "/folder/filename.php", false, "Disallow: /*.php$"},
+	{"EndAnchorQuery", "User-agent: *\nDisallow: /*.php$\n", "Walker", "/filename.php?parameters", true, ""},
+	{"EndAnchorSuffix", "User-agent: *\nDisallow: /*.php$\n", "Walker", "/filename.php5", true, ""},
+	{"MiddleWildcard", "User-agent: *\nDisallow: /fish*.php\n", "Walker", "/fishheads/catfish.php?parameters", false, "Disallow: /fish*.php"},
+	{"MiddleWildcardNoMatch", "User-agent: *\nDisallow: /fish*.php\n", "Walker", "/Fish.PHP", true, ""},
+	{"MultipleWildcards", "User-agent: *\nDisallow: /*/private/*.html$\n", "Walker", "/a/private/b/c.html", false, "Disallow: /*/private/*.html$"},
+
+	// Precedence: the longest rule wins, Allow wins ties
+	{"LongerAllow", "User-agent: *\nAllow: /p\nDisallow: /\n", "Walker", "/page", true, "Allow: /p"},
+	{"LongerAllowDirectory", "User-agent: *\nAllow: /folder\nDisallow: /folder\n", "Walker", "/folder/page", true, "Allow: /folder"},
+	{"LongerDisallow", "User-agent: *\nAllow: /page\nDisallow: /*.htm\n", "Walker", "/page.htm", false, "Disallow: /*.htm"},
+	{"AllowRoot", "User-agent: *\nAllow: /$\nDisallow: /\n", "Walker", "/", true, "Allow: /$"},
+	{"AllowRootOnly", "User-agent: *\nAllow: /$\nDisallow: /\n", "Walker", "/page.htm", false, "Disallow: /"},
+	{"OrderIrrelevant", "User-agent: *\nDisallow: /\nAllow: /public/\n", "Walker", "/public/a.html", true, "Allow: /public/"},
+	{"RobotsAlwaysAllowed", "User-agent: *\nDisallow: /\n", "Walker", "/robots.txt", true, ""},
+
+	// Percent-encoding
+	{"EncodedCase", "User-agent: *\nDisallow: /a%3cd\n", "Walker", "/a%3Cd.html", false, "Disallow: /a%3cd"},
+	{"NonASCII", "User-agent: *\nDisallow: /ä\n", "Walker", "/%C3%A4/x", false, "Disallow: /ä"},
+
+	// Groups
+	{"SpecificGroup", "User-agent: *\nDisallow: /\n\nUser-agent: walker\nDisallow: /private\n", "Walker/1.0", "/page", true, ""},
+	{"SpecificGroupRule", "User-agent: *\nDisallow: /\n\nUser-agent: walker\nDisallow: /private\n", "Walker/1.0", "/private", false, "Disallow: /private"},
+	{"AgentCase", "User-agent: WALKER\nDisallow: /\n", "walker (http://github.com/iParadigms/walker)", "/page", false, "Disallow: /"},
+	{"OtherAgent", "User-agent: otherbot\nDisallow: /\n", "Walker", "/page", true, ""},
+	{"LongestAgent", "User-agent: walker\nDisallow: /a\n\nUser-agent: walker-news\nDisallow: /b\n", "Walker-News/2.0", "/a", true, ""},
+	{"MergedGroups", "User-agent: walker\nDisallow: /a\n\nUser-agent: *\nDisallow: /\n\nUser-agent: walker\nDisallow: /b\n", "Walker", "/b", false, "Disallow: /b"},
+	{"SharedGroup", "User-agent: otherbot\nUser-agent: walker\nDisallow: /shared\n", "Walker", "/shared", false, "Disallow: /shared"},
+	{"RulesBeforeAgent", "Disallow: /\nUser-agent: *\nDisallow: /private\n", "Walker", "/page", true, ""},
+	{"UnknownLinesInGroup", "User-agent: walker\nHost: example.com\nUser-agent: otherbot\nDisallow: /\n", "Walker", "/page", false, "Disallow: /"},
+
+	// Syntax
+	{"Comments", "# robots\nUser-agent: * # everyone\nDisallow: /private # secret\n", "Walker", "/private", false, "Disallow: /private"},
+	{"NoColon", "User-agent *\nDisallow /private\n", "Walker", "/private", false, "Disallow: /private"},
+	{"Typo", "User-agent: *\nDissallow: /private\n", "Walker", "/private", false, "Disallow: /private"},
+	{"ByteOrderMark", "\ufeffUser-agent: *\nDisallow: /\n", "Walker", "/page", false, "Disallow: /"},
+	{"CRLF", "User-agent: *\r\nDisallow: /private\r\n", "Walker", "/private", false, "Disallow: /private"},
+}
+
+func TestRobotsCorpus(t *testing.T) {
+	for _, c := range robotsCorpus {
+		robots, err := ParseRobots(strings.NewReader(c.robots))
+		if err != nil {
+			t.Errorf("%v: failed to parse robots.txt: %v", c.tag, err)
+			continue
+		}
+		grp := robots.FindGroup(c.agent)
+		if allowed := grp.Test(c.path); allowed != c.allowed {
+			t.Errorf("%v: expected allowed=%v for %v, got %v", c.tag, c.allowed, c.path, allowed)
+		}
+		rule := ""
+		if r := grp.Match(c.path); r != nil {
+			rule = r.String()
+		}
+		if rule != c.rule {
+			t.Errorf("%v: expected %v to be decided by %q, got %q", c.tag, c.path, c.rule, rule)
+		}
+	}
+}
+
+func TestRobotsCrawlDelayAndSitemaps(t *testing.T) {
+	robots, err := ParseRobots(strings.NewReader(`Sitemap: http://test.com/a.xml
+User-agent: walker
+Crawl-delay: 2.5
+Disallow: /a
+
+User-agent: *
+Crawl-delay: 10
+Sitemap: http://test.com/b.xml
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse robots.txt: %v", err)
+	}
+
+	if grp := robots.FindGroup("Walker"); !grp.HasCrawlDelay || grp.CrawlDelay != 2500*time.Millisecond {
+		t.Errorf("Expected the walker group crawl delay of 2.5s, got %v", grp.CrawlDelay)
+	}
+	if grp := robots.FindGroup("otherbot"); !grp.HasCrawlDelay || grp.CrawlDelay != 10*time.Second {
+		t.Errorf("Expected the * group crawl delay of 10s, got %v", grp.CrawlDelay)
+	}
+	if len(robots.Sitemaps) != 2 || robots.Sitemaps[0] != "http://test.com/a.xml" ||
+		robots.Sitemaps[1] != "http://test.com/b.xml" {
+		t.Errorf("Expected both sitemaps, got %v", robots.Sitemaps)
+	}
+
+	robots, err = ParseRobots(strings.NewReader("User-agent: *\nCrawl-delay: 10\n\nUser-agent: walker\nDisallow: /a\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse robots.txt: %v", err)
+	}
+	if grp := robots.FindGroup("Walker"); grp.HasCrawlDelay {
+		t.Errorf("Expected the walker group to have no crawl delay, got %v", grp.CrawlDelay)
+	}
+}
+
+func TestRobotsGroupAgent(t *testing.T) {
+	robots, err := ParseRobots(strings.NewReader(
+		"User-agent: googlebot\nDisallow: /g\n\nUser-agent: walker\nDisallow: /w\n\nUser-agent: *\nDisallow: /\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse robots.txt: %v", err)
+	}
+	for agent, expected := range map[string]string{
+		"Walker/1.0":                  "walker",
+		"Googlebot/2.1":               "googlebot",
+		"Googlebot-Image/1.0":         "googlebot",
+		"otherbot (http://other.com)": "*",
+	} {
+		if got := robots.FindGroup(agent).Agent; got != expected {
+			t.Errorf("Expected %q to get the %q group, got %q", agent, expected, got)
+		}
+	}
+
+	robots, err = ParseRobots(strings.NewReader("User-agent: otherbot\nDisallow: /\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse robots.txt: %v", err)
+	}
+	if got := robots.FindGroup("Walker").Agent; got != "" {
+		t.Errorf("Expected no group to apply, got %q", got)
+	}
+}
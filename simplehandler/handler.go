@@ -4,6 +4,7 @@ Package simplehandler provides a basic walker handler implementation
 package simplehandler
 
 import (
+	"context"
 	"io"
 	"os"
 	"path/filepath"
@@ -25,12 +26,17 @@ type Handler struct{}
 // `$PWD/test.com/amazing` and write the page contents (no headers or HTTP
 // data) to `$PWD/test.com/amazing/stuff.html`
 //
-// It skips pages that do not have a 2XX HTTP code.
-func (h *Handler) HandleResponse(fr *walker.FetchResults) {
+// It skips pages that do not have a 2XX HTTP code, and pages marked noarchive
+// if fetcher.honor_noarchive is true.
+func (h *Handler) HandleResponse(ctx context.Context, fr *walker.FetchResults) {
 	if fr.ExcludedByRobots {
 		log4go.Debug("Excluded by robots.txt, ignoring url: %v", fr.URL)
 		return
 	}
+	if fr.NoArchive && walker.Config.Fetcher.HonorNoarchive {
+		log4go.Debug("Marked noarchive, ignoring url: %v", fr.URL)
+		return
+	}
 	if fr.Response.StatusCode < 200 || fr.Response.StatusCode >= 300 {
 		log4go.Debug("Returned %v ignoring url: %v", fr.Response.StatusCode, fr.URL)
 		return
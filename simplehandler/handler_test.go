@@ -2,6 +2,7 @@ package simplehandler
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -67,7 +68,7 @@ func TestSimpleWriterHandler(t *testing.T) {
 				},
 			},
 		}
-		h.HandleResponse(fr)
+		h.HandleResponse(context.Background(), fr)
 
 		if ht.ExpectedDir != "" {
 			_, err := os.Stat(ht.ExpectedDir)
@@ -119,7 +120,7 @@ func TestSimpleWriterHandlerIgnoresOnRobots(t *testing.T) {
 		},
 	}
 
-	h.HandleResponse(page2Fetch)
+	h.HandleResponse(context.Background(), page2Fetch)
 	file := "test.com-page2.html"
 	_, err := ioutil.ReadFile(file)
 	if err == nil {
@@ -155,7 +156,7 @@ func TestSimpleWriterHandlerIgnoresBadHTTPCode(t *testing.T) {
 		},
 	}
 
-	h.HandleResponse(page3Fetch)
+	h.HandleResponse(context.Background(), page3Fetch)
 	file := "test.com-page3.html"
 	_, err := ioutil.ReadFile(file)
 	if err == nil {
@@ -0,0 +1,343 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SimulationReport describes a crawl of a single domain made by Simulate
+type SimulationReport struct {
+	// The TLD+1 crawled, and the URL the crawl started from
+	Domain string
+	Seed   string
+
+	// The pages fetched (or excluded by robots.txt), in crawl order
+	Pages []*SimulatedPage
+
+	// Distinct links of Domain parsed out of the pages, which would be stored
+	// in the links table. Unvisited counts the ones left uncrawled when the
+	// page limit was reached.
+	Links     []string
+	Unvisited int
+
+	// Number of distinct links to each other domain parsed out of the pages
+	OtherDomains map[string]int
+
+	// Links parsed out of the pages that would not be stored, by reason
+	Excluded map[string][]string
+
+	// Links whose href was changed by normalization (see URL.Normalize)
+	Normalized []NormalizationChange
+}
+
+// SimulatedPage is a page fetched during a simulated crawl
+type SimulatedPage struct {
+	URL string
+
+	// The HTTP status, or the error if the fetch failed
+	Status int
+	Error  string
+
+	// The robots.txt rule that excluded the page, if any
+	RobotsRule string
+
+	// Where the page redirected to, if it did
+	RedirectedTo string
+
+	MimeType string
+
+	// Number of links parsed out of the page that would be stored
+	Links int
+
+	// Set if the page was marked noindex or nofollow with a robots <meta> tag
+	NoIndex  bool
+	NoFollow bool
+}
+
+// NormalizationChange is a link as written in a page and what walker
+// normalized it to
+type NormalizationChange struct {
+	From string
+	To   string
+}
+
+// Simulate crawls up to pages pages of domain (a TLD+1 like "x.com", or a URL
+// to start from) in-process with the real fetcher and the current config, but
+// with a throwaway in-memory datastore, and reports what it found. It is meant
+// for checking configuration against a domain before crawling it for real;
+// nothing is written to the datastore.
+func Simulate(domain string, pages int) (*SimulationReport, error) {
+	return simulate(domain, pages, nil)
+}
+
+// simulate is Simulate using the given transport (nil for the default)
+func simulate(domain string, pages int, transport http.RoundTripper) (*SimulationReport, error) {
+	if pages < 1 {
+		return nil, fmt.Errorf("At least 1 page must be simulated, got %d", pages)
+	}
+	ref := domain
+	if !strings.Contains(ref, "://") {
+		ref = "http://" + ref + "/"
+	}
+	seed, err := ParseAndNormalizeURL(ref)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %v: %v", domain, err)
+	}
+	tld1, err := seed.ToplevelDomainPlusOne()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to find the domain of %v: %v", seed, err)
+	}
+
+	ds := &simulationDatastore{
+		domain:  tld1,
+		budget:  pages,
+		pending: []*URL{seed},
+		seen:    map[string]bool{seed.String(): true},
+		report: &SimulationReport{
+			Domain:       tld1,
+			Seed:         seed.String(),
+			OtherDomains: map[string]int{},
+			Excluded:     map[string][]string{},
+		},
+	}
+	fm := &FetchManager{
+		Datastore: ds,
+		Handler:   ds,
+		Transport: transport,
+	}
+	ds.filter = newFetcher(fm)
+	fm.oneShotRun()
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.report.Unvisited = len(ds.pending)
+	return ds.report, nil
+}
+
+// simulationDatastore is the Datastore (and Handler) of a simulated crawl. It
+// hands out the links of its domain a round at a time: each claim gets all
+// the links found so far that have not been crawled, up to the page budget.
+type simulationDatastore struct {
+	mu      sync.Mutex
+	domain  string
+	budget  int
+	claimed bool
+	pending []*URL
+	seen    map[string]bool
+
+	// only used for its link filters (see parsedLinkExclusion)
+	filter *fetcher
+
+	normalized map[string]bool
+	excluded   map[string]bool
+	report     *SimulationReport
+}
+
+func (ds *simulationDatastore) ClaimNewHost(ctx context.Context) string {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.claimed || ds.budget <= 0 || len(ds.pending) == 0 {
+		return ""
+	}
+	ds.claimed = true
+	return ds.domain
+}
+
+func (ds *simulationDatastore) UnclaimHost(ctx context.Context, host string) {
+	ds.mu.Lock()
+	ds.claimed = false
+	ds.mu.Unlock()
+}
+
+func (ds *simulationDatastore) LinksForHost(ctx context.Context, host string) <-chan *URL {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	n := len(ds.pending)
+	if n > ds.budget {
+		n = ds.budget
+	}
+	links := make(chan *URL, n)
+	for _, u := range ds.pending[:n] {
+		links <- u
+	}
+	close(links)
+	ds.pending = ds.pending[n:]
+	ds.budget -= n
+	return links
+}
+
+func (ds *simulationDatastore) StoreURLFetchResults(ctx context.Context, fr *FetchResults) {
+	page := &SimulatedPage{
+		URL:        fr.URL.String(),
+		RobotsRule: fr.RobotsRule,
+		MimeType:   fr.MimeType,
+		NoIndex:    fr.MetaNoIndex,
+		NoFollow:   fr.MetaNoFollow,
+	}
+	if fr.Response != nil {
+		page.Status = fr.Response.StatusCode
+	}
+	if fr.FetchError != nil {
+		page.Error = fr.FetchError.Error()
+	}
+	if len(fr.RedirectedFrom) > 0 {
+		page.RedirectedTo = fr.RedirectedFrom[len(fr.RedirectedFrom)-1].String()
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	for _, p := range ds.report.Pages {
+		if p.URL == page.URL {
+			// Links are counted as they are stored, before the page is
+			page.Links = p.Links
+			*p = *page
+			return
+		}
+	}
+	ds.report.Pages = append(ds.report.Pages, page)
+}
+
+func (ds *simulationDatastore) StoreParsedURL(ctx context.Context, u *URL, fr *FetchResults) {
+	dom, err := u.ToplevelDomainPlusOne()
+	if err != nil {
+		return
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if fr != nil {
+		ds.page(fr.URL.String()).Links++
+	}
+	key := u.String()
+	if ds.seen[key] {
+		return
+	}
+	ds.seen[key] = true
+	if dom != ds.domain {
+		ds.report.OtherDomains[dom]++
+		return
+	}
+	ds.report.Links = append(ds.report.Links, key)
+	ds.pending = append(ds.pending, u)
+}
+
+// page returns the report's page for u, adding it if it is not there yet.
+// ds.mu must be held.
+func (ds *simulationDatastore) page(u string) *SimulatedPage {
+	for _, p := range ds.report.Pages {
+		if p.URL == u {
+			return p
+		}
+	}
+	p := &SimulatedPage{URL: u}
+	ds.report.Pages = append(ds.report.Pages, p)
+	return p
+}
+
+func (ds *simulationDatastore) KeepAlive(ctx context.Context) error {
+	return nil
+}
+
+func (ds *simulationDatastore) Close() {}
+
+// HandleResponse records the links in HTML pages that normalization changes
+// or that would not be stored
+func (ds *simulationDatastore) HandleResponse(ctx context.Context, fr *FetchResults) {
+	if !isHTML(fr.Response) || fr.Response.Body == nil {
+		return
+	}
+	body, err := ioutil.ReadAll(fr.Response.Body)
+	if err != nil {
+		return
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.normalized == nil {
+		ds.normalized = map[string]bool{}
+		ds.excluded = map[string]bool{}
+	}
+	p := &HTMLParser{KeepRawLinks: true}
+	p.Parse(body)
+	for _, raw := range p.Links {
+		raw.MakeAbsolute(fr.URL)
+		u := raw.Clone()
+		u.Normalize()
+		u.Nofollow = raw.Nofollow
+		u.JSRedirect = raw.JSRedirect
+		if from, to := raw.String(), u.String(); from != to && !ds.normalized[from] {
+			ds.normalized[from] = true
+			ds.report.Normalized = append(ds.report.Normalized, NormalizationChange{From: from, To: to})
+		}
+		if reason := ds.filter.parsedLinkExclusion(u); reason != "" && !ds.excluded[u.String()] {
+			ds.excluded[u.String()] = true
+			ds.report.Excluded[reason] = append(ds.report.Excluded[reason], u.String())
+		}
+	}
+}
+
+// Print writes r as a human readable report to w
+func (r *SimulationReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Simulated crawl of %v from %v\n\n", r.Domain, r.Seed)
+
+	fmt.Fprintf(w, "Pages (%d):\n", len(r.Pages))
+	robotsExcluded := 0
+	for _, p := range r.Pages {
+		switch {
+		case p.RobotsRule != "":
+			robotsExcluded++
+			fmt.Fprintf(w, "  %v  excluded by robots.txt (%v)\n", p.URL, p.RobotsRule)
+		case p.Error != "":
+			fmt.Fprintf(w, "  %v  error: %v\n", p.URL, p.Error)
+		default:
+			fmt.Fprintf(w, "  %v  %d %v, %d links", p.URL, p.Status, p.MimeType, p.Links)
+			if p.RedirectedTo != "" {
+				fmt.Fprintf(w, ", redirected to %v", p.RedirectedTo)
+			}
+			if p.NoIndex {
+				fmt.Fprintf(w, ", noindex")
+			}
+			if p.NoFollow {
+				fmt.Fprintf(w, ", nofollow")
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintf(w, "\nRobots.txt excluded %d of %d pages\n", robotsExcluded, len(r.Pages))
+
+	fmt.Fprintf(w, "\nLinks of %v discovered: %d (%d not crawled)\n", r.Domain, len(r.Links), r.Unvisited)
+
+	var others []string
+	for dom := range r.OtherDomains {
+		others = append(others, dom)
+	}
+	sort.Strings(others)
+	fmt.Fprintf(w, "\nLinks to other domains (%d domains):\n", len(others))
+	for _, dom := range others {
+		fmt.Fprintf(w, "  %v  %d\n", dom, r.OtherDomains[dom])
+	}
+
+	var reasons []string
+	for reason := range r.Excluded {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	fmt.Fprintf(w, "\nLinks that would not be stored:\n")
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "  %v (%d):\n", reason, len(r.Excluded[reason]))
+		for _, u := range r.Excluded[reason] {
+			fmt.Fprintf(w, "    %v\n", u)
+		}
+	}
+
+	fmt.Fprintf(w, "\nLinks changed by normalization (%d):\n", len(r.Normalized))
+	for _, c := range r.Normalized {
+		fmt.Fprintf(w, "  %v\n    -> %v\n", c.From, c.To)
+	}
+}
@@ -0,0 +1,127 @@
+package walker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// pageRoundTrip serves a fresh response with the given body for each mapped
+// link (the robots.txt of a host is fetched each time the host is claimed)
+type pageRoundTrip map[string]string
+
+func (prt pageRoundTrip) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := prt[req.URL.String()]
+	if !ok {
+		return response404(), nil
+	}
+	res := response200()
+	res.Body = ioutil.NopCloser(strings.NewReader(body))
+	if strings.HasSuffix(req.URL.Path, "robots.txt") {
+		res.Header.Set("Content-Type", "text/plain")
+	}
+	return res, nil
+}
+
+func TestSimulate(t *testing.T) {
+	origDelay := Config.Fetcher.DefaultCrawlDelay
+	origRelNofollow := Config.Fetcher.RelNofollow
+	origExclude := Config.Fetcher.ExcludeLinkPatterns
+	defer func() {
+		Config.Fetcher.DefaultCrawlDelay = origDelay
+		Config.Fetcher.RelNofollow = origRelNofollow
+		Config.Fetcher.ExcludeLinkPatterns = origExclude
+		PostConfigHooks()
+	}()
+	Config.Fetcher.DefaultCrawlDelay = "0s"
+	Config.Fetcher.RelNofollow = "skip"
+	Config.Fetcher.ExcludeLinkPatterns = []string{`\.pdf$`}
+	PostConfigHooks()
+
+	transport := pageRoundTrip{
+		"http://sim.com/robots.txt": "User-agent: *\nDisallow: /private/\n",
+		"http://sim.com/": `<html><body>
+<a href="/page1.html">1</a>
+<a href="/page2.html#top">2</a>
+<a href="/private/secret.html">secret</a>
+<a href="/doc.pdf">pdf</a>
+<a href="/ads.html" rel="sponsored">ad</a>
+<a href="http://other.com/">other</a>
+</body></html>`,
+		"http://sim.com/page1.html": `<html><body>
+<a href="/page3.html">3</a>
+</body></html>`,
+		"http://sim.com/page2.html": `<html><body>
+<a href="http://other.com/page.html">other</a>
+<a href="http://www.third.com/">third</a>
+</body></html>`,
+	}
+
+	report, err := simulate("sim.com", 4, transport)
+	if err != nil {
+		t.Fatalf("Failed to simulate: %v", err)
+	}
+
+	expectedPages := map[string]int{
+		"http://sim.com/":                    200,
+		"http://sim.com/page1.html":          200,
+		"http://sim.com/page2.html":          200,
+		"http://sim.com/private/secret.html": 0,
+	}
+	if len(report.Pages) != len(expectedPages) {
+		t.Fatalf("Expected %d pages, got %d: %+v", len(expectedPages), len(report.Pages), report.Pages)
+	}
+	for _, p := range report.Pages {
+		status, ok := expectedPages[p.URL]
+		if !ok {
+			t.Errorf("Unexpected page %v", p.URL)
+			continue
+		}
+		if p.Status != status {
+			t.Errorf("Expected %v to have status %d, got %d", p.URL, status, p.Status)
+		}
+		if strings.Contains(p.URL, "private") && p.RobotsRule == "" {
+			t.Errorf("Expected %v to be excluded by robots.txt", p.URL)
+		}
+		if p.URL == "http://sim.com/" && p.Links != 4 {
+			t.Errorf("Expected 4 stored links from %v, got %d", p.URL, p.Links)
+		}
+	}
+
+	if len(report.Links) != 4 || report.Unvisited != 1 {
+		t.Errorf("Expected 4 links with 1 unvisited, got %v with %d unvisited", report.Links, report.Unvisited)
+	}
+	if report.OtherDomains["other.com"] != 2 || report.OtherDomains["third.com"] != 1 {
+		t.Errorf("Unexpected other domains %v", report.OtherDomains)
+	}
+
+	if ex := report.Excluded["excluded path (fetcher.exclude_link_patterns)"]; len(ex) != 1 || ex[0] != "http://sim.com/doc.pdf" {
+		t.Errorf("Expected doc.pdf to be excluded by path, got %v", ex)
+	}
+	if ex := report.Excluded["rel=nofollow (fetcher.rel_nofollow)"]; len(ex) != 1 || ex[0] != "http://sim.com/ads.html" {
+		t.Errorf("Expected ads.html to be excluded as nofollow, got %v", ex)
+	}
+
+	expectedNormalized := NormalizationChange{
+		From: "http://sim.com/page2.html#top",
+		To:   "http://sim.com/page2.html",
+	}
+	if len(report.Normalized) != 1 || report.Normalized[0] != expectedNormalized {
+		t.Errorf("Expected normalization %v, got %v", expectedNormalized, report.Normalized)
+	}
+
+	var buf bytes.Buffer
+	report.Print(&buf)
+	for _, s := range []string{
+		"Simulated crawl of sim.com",
+		"Robots.txt excluded 1 of 4 pages",
+		"other.com  2",
+		"-> http://sim.com/page2.html",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Expected report to contain %q, got:\n%v", s, buf.String())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"code.google.com/p/log4go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes walker sets, so that spans for a URL or domain can be
+// searched for
+const (
+	AttrURL    = attribute.Key("walker.url")
+	AttrDomain = attribute.Key("walker.domain")
+)
+
+// Tracer returns the OpenTelemetry tracer walker's spans are made with. It
+// uses the global TracerProvider, so spans are dropped unless StartTracing
+// (or the program embedding walker) has set one.
+func Tracer() trace.Tracer {
+	return otel.Tracer("github.com/iParadigms/walker")
+}
+
+// StartTracing sets the global OpenTelemetry TracerProvider to export spans
+// as configured in the tracing section of walker.yaml. It does nothing if
+// tracing.exporter is empty. The returned function flushes any spans not yet
+// exported and stops the exporter; call it before exiting.
+func StartTracing(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(Config.Tracing.Exporter) {
+	case "":
+		return noop, nil
+	case "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(Config.Tracing.Endpoint)}
+		if Config.Tracing.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		// This shouldn't happen b/c it's checked when loading config
+		err = fmt.Errorf("unknown exporter %q", Config.Tracing.Exporter)
+	}
+	if err != nil {
+		return noop, fmt.Errorf("Failed to create tracing exporter: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(Config.Tracing.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", Config.Tracing.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	log4go.Info("Exporting traces with %v exporter", Config.Tracing.Exporter)
+	return provider.Shutdown, nil
+}
+
+// EndSpan records err (if not nil) as the outcome of span and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endFetchSpan records the outcome of fr on span and ends it
+func endFetchSpan(span trace.Span, fr *FetchResults) {
+	if fr.Response != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", fr.Response.StatusCode))
+	}
+	if fr.MimeType != "" {
+		span.SetAttributes(attribute.String("walker.mime_type", fr.MimeType))
+	}
+	if fr.ExcludedByRobots {
+		span.SetAttributes(attribute.String("walker.robots_rule", fr.RobotsRule))
+	}
+	if len(fr.RedirectedFrom) > 0 {
+		span.SetAttributes(attribute.Int("walker.redirects", len(fr.RedirectedFrom)))
+	}
+	EndSpan(span, fr.FetchError)
+}
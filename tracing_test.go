@@ -0,0 +1,82 @@
+package walker
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFetchSpans(t *testing.T) {
+	origDelay := Config.Fetcher.DefaultCrawlDelay
+	origProvider := otel.GetTracerProvider()
+	defer func() {
+		Config.Fetcher.DefaultCrawlDelay = origDelay
+		otel.SetTracerProvider(origProvider)
+	}()
+	Config.Fetcher.DefaultCrawlDelay = "0s"
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	_, err := simulate("sim.com", 1, pageRoundTrip{
+		"http://sim.com/": `<html><body><a href="/page1.html">1</a></body></html>`,
+	})
+	if err != nil {
+		t.Fatalf("Failed to simulate: %v", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	fetch, ok := spans["walker.Fetch"]
+	if !ok {
+		t.Fatalf("Expected a walker.Fetch span, got %v", spans)
+	}
+	attrs := map[string]string{}
+	for _, kv := range fetch.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	expected := map[string]string{
+		"walker.url":                "http://sim.com/",
+		"walker.domain":             "sim.com",
+		"http.response.status_code": "200",
+		"walker.mime_type":          "text/html",
+	}
+	for k, v := range expected {
+		if attrs[k] != v {
+			t.Errorf("Expected walker.Fetch attribute %v to be %q, got %q", k, v, attrs[k])
+		}
+	}
+
+	handle, ok := spans["walker.HandleResponse"]
+	if !ok {
+		t.Fatalf("Expected a walker.HandleResponse span, got %v", spans)
+	}
+	if handle.Parent().SpanID() != fetch.SpanContext().SpanID() {
+		t.Errorf("Expected walker.HandleResponse to be a child of walker.Fetch")
+	}
+}
+
+func TestStartTracingDisabled(t *testing.T) {
+	orig := Config.Tracing.Exporter
+	defer func() {
+		Config.Tracing.Exporter = orig
+	}()
+	Config.Tracing.Exporter = ""
+
+	origProvider := otel.GetTracerProvider()
+	stop, err := StartTracing(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start tracing: %v", err)
+	}
+	if otel.GetTracerProvider() != origProvider {
+		t.Errorf("Expected tracing to be left alone with no exporter")
+	}
+	if err := stop(context.Background()); err != nil {
+		t.Errorf("Failed to stop tracing: %v", err)
+	}
+}
@@ -15,12 +15,24 @@ import (
 // URL is the walker URL object, which embeds *url.URL but has extra data and
 // capabilities used by walker. Note that LastCrawled should not be set to its
 // zero value, it should be set to NotYetCrawled.
+//
+// PrimaryKey, Canonical and the Equal methods are how walker identifies and
+// compares links; datastores and handlers should use them rather than
+// assembling the pieces themselves.
 type URL struct {
 	*url.URL
 
 	// LastCrawled is the last time we crawled this URL, for example to use a
 	// Last-Modified header.
 	LastCrawled time.Time
+
+	// Nofollow is true if this URL was parsed from a link marked
+	// rel="nofollow", "ugc" or "sponsored"
+	Nofollow bool
+
+	// JSRedirect is true if this URL was parsed as the target of a JavaScript
+	// redirect (see fetcher.detect_js_redirects)
+	JSRedirect bool
 }
 
 // CreateURL creates a walker URL from values usually pulled out of the
@@ -46,10 +58,34 @@ func CreateURL(domain, subdomain, path, protocol string, lastcrawled time.Time)
 var parseURLPathStrip *regexp.Regexp
 var parseURLPurgeMap map[string]bool
 
+// DomainQueryParams configures the query parameters of a domain's links that
+// are always removed or always left alone. See fetcher.domain_query_params in
+// walker.yaml.
+type DomainQueryParams struct {
+	// Parameters removed during normalization (ex. tracking parameters)
+	Strip []string `yaml:"strip"`
+
+	// Parameters never removed, neither by purge_sid_list nor by the
+	// dispatcher's duplicate content filter
+	Keep []string `yaml:"keep"`
+}
+
+// queryParamRules is a DomainQueryParams with lowercased parameter names
+type queryParamRules struct {
+	strip map[string]bool
+	keep  map[string]bool
+}
+
+// parseURLDomainParams holds fetcher.domain_query_params keyed by lowercased
+// domain
+var parseURLDomainParams map[string]*queryParamRules
+
+// setupNormalizeURL sets up the normalization rules from the config. The
+// rules are built before any are replaced, but URLs may not be normalized
+// while it runs.
 func setupNormalizeURL() error {
-	if len(Config.Fetcher.PurgeSidList) == 0 {
-		parseURLPathStrip = nil
-	} else {
+	var pathStrip *regexp.Regexp
+	if len(Config.Fetcher.PurgeSidList) > 0 {
 		// Here we want to write a regexp that looks like
 		// \;jsessionid=.*$|\;other=.*$
 		var buffer bytes.Buffer
@@ -65,19 +101,60 @@ func setupNormalizeURL() error {
 			buffer.WriteString(`\=.*$`)
 		}
 		var err error
-		parseURLPathStrip, err = regexp.Compile(buffer.String())
+		pathStrip, err = regexp.Compile(buffer.String())
 		if err != nil {
 			return fmt.Errorf("Failed setupParseURL: %v", err)
 		}
 	}
 
-	parseURLPurgeMap = map[string]bool{}
+	purgeMap := map[string]bool{}
 	for _, p := range Config.Fetcher.PurgeSidList {
-		parseURLPurgeMap[strings.ToLower(p)] = true
+		purgeMap[strings.ToLower(p)] = true
 	}
+
+	domainParams := map[string]*queryParamRules{}
+	for dom, dp := range Config.Fetcher.DomainQueryParams {
+		rules := &queryParamRules{strip: map[string]bool{}, keep: map[string]bool{}}
+		for _, p := range dp.Strip {
+			rules.strip[strings.ToLower(p)] = true
+		}
+		for _, p := range dp.Keep {
+			rules.keep[strings.ToLower(p)] = true
+		}
+		domainParams[strings.ToLower(dom)] = rules
+	}
+
+	parseURLPathStrip = pathStrip
+	parseURLPurgeMap = purgeMap
+	parseURLDomainParams = domainParams
 	return nil
 }
 
+// queryParamRulesFor returns the fetcher.domain_query_params rules for host
+// (without its port) or the closest of its parent domains, or nil if there
+// are none
+func queryParamRulesFor(host string) *queryParamRules {
+	host = strings.ToLower(host)
+	for host != "" {
+		if rules, ok := parseURLDomainParams[host]; ok {
+			return rules
+		}
+		i := strings.Index(host, ".")
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return nil
+}
+
+// KeepsQueryParam returns true if fetcher.domain_query_params says param
+// should never be removed from links of this URL's host
+func (u *URL) KeepsQueryParam(param string) bool {
+	rules := queryParamRulesFor(u.Hostname())
+	return rules != nil && rules.keep[strings.ToLower(param)]
+}
+
 // ParseURL is the walker.URL equivalent of url.Parse. Note, all URL's should
 // be passed through this function so that we get consistency.
 func ParseURL(ref string) (*URL, error) {
@@ -108,18 +185,28 @@ func (u *URL) Normalize() {
 	// modify the url in place.
 	purell.NormalizeURL(rawURL, purell.FlagsSafe|purell.FlagRemoveFragment)
 
+	if rawURL.User != nil && strings.ToLower(Config.Fetcher.UserinfoURLs) == "strip" {
+		rawURL.User = nil
+	}
+
 	// Filter the path to catch embedded session ids
 	if parseURLPathStrip != nil {
 		// Remove SID from path
 		u.Path = parseURLPathStrip.ReplaceAllString(rawURL.Path, "")
 	}
 
-	//Rewrite the query string to canonical order, removing SID's as needed.
+	//Rewrite the query string to canonical order, removing SID's and the
+	//domain's stripped parameters as needed.
 	if rawURL.RawQuery != "" {
 		purge := parseURLPurgeMap
+		rules := queryParamRulesFor(rawURL.Hostname())
 		params := rawURL.Query()
 		for k := range params {
-			if purge[strings.ToLower(k)] {
+			lk := strings.ToLower(k)
+			if rules != nil && rules.keep[lk] {
+				continue
+			}
+			if purge[lk] || (rules != nil && rules.strip[lk]) {
 				delete(params, k)
 			}
 		}
@@ -146,14 +233,13 @@ func (u *URL) Clone() *URL {
 func (u *URL) NormalizedForm() *URL {
 	// We compare the fields of url.URL below. A few notes:
 	//   (a) We do not compare the Opaque field, as it doesn't appear links we'll be looking at will use that field.
-	//   (b) We do not consider the User field (of type Userinfo). You can see where the User field comes into play by
-	//       looking at this (from url.URL)
+	//   (b) The User field (of type Userinfo) is only changed by normalization if fetcher.userinfo_urls is "strip".
+	//       You can see where the User field comes into play by looking at this (from url.URL)
 	//           scheme://[userinfo@]host/path[?query][#fragment]
-	//    the userinfo information should never be changed by normalization, so it appears there is no need to compare
-	//    it.
 	c := u.Clone()
 	c.Normalize()
 	normal := c.URL.Scheme == u.URL.Scheme &&
+		c.URL.User.String() == u.URL.User.String() &&
 		c.URL.Host == u.URL.Host &&
 		c.URL.Path == u.URL.Path &&
 		c.URL.RawQuery == u.URL.RawQuery &&
@@ -207,24 +293,61 @@ func (u *URL) TLDPlusOneAndSubdomain() (string, string, error) {
 	return dom, subdom, nil
 }
 
-// PrimaryKey returns the 5 tuple that is the primary key for this url in the links table. The return values
-// are (with cassandra keys in parens)
-// (a) Domain (dom)
-// (b) Subdomain (subdom)
-// (c) Path part of url (path)
-// (d) Schema of url (proto)
-// (e) last update time of link (time)
-// (f) any errors that occurred
-func (u *URL) PrimaryKey() (dom string, subdom string, path string, proto string, time time.Time, err error) {
-	// Grab new and old variables
+// PrimaryKey returns the columns identifying this URL in the links and
+// segments tables (along with a crawl time): its TLD+1 domain (dom),
+// subdomain (subdom), request URI (path) and scheme (proto). The error is
+// from TLDPlusOneAndSubdomain.
+//
+// Note this is an incompatible change: PrimaryKey used to also return
+// LastCrawled as a time before the error. Callers that need it should read
+// u.LastCrawled directly.
+func (u *URL) PrimaryKey() (dom, subdom, path, proto string, err error) {
 	dom, subdom, err = u.TLDPlusOneAndSubdomain()
 	if err != nil {
 		return
 	}
-	path = u.RequestURI()
-	proto = u.Scheme
-	time = u.LastCrawled
-	return
+	return dom, subdom, u.RequestURI(), u.Scheme, nil
+}
+
+// Canonical returns the normalized string form of this URL (see
+// Normalize), leaving u unchanged. Two URLs with the same canonical form are
+// stored as the same link.
+func (u *URL) Canonical() string {
+	c := u.Clone()
+	c.Normalize()
+	return c.String()
+}
+
+// WithoutQueryParams returns a copy of this URL with the named query
+// parameters (matched case insensitively) removed. The remaining parameters
+// keep their order.
+func (u *URL) WithoutQueryParams(names ...string) *URL {
+	c := u.Clone()
+	if c.RawQuery == "" || len(names) == 0 {
+		return c
+	}
+	var kept []string
+	for _, param := range strings.Split(c.RawQuery, "&") {
+		key := param
+		if i := strings.Index(key, "="); i >= 0 {
+			key = key[:i]
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		remove := false
+		for _, name := range names {
+			if strings.EqualFold(key, name) {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			kept = append(kept, param)
+		}
+	}
+	c.RawQuery = strings.Join(kept, "&")
+	return c
 }
 
 // MakeAbsolute uses URL.ResolveReference to make this URL object an absolute
@@ -237,6 +360,22 @@ func (u *URL) MakeAbsolute(base *URL) {
 	u.URL = base.URL.ResolveReference(u.URL)
 }
 
+// HasNonstandardPort returns true if this URL names a port other than the
+// default one of its scheme (see fetcher.nonstandard_ports)
+func (u *URL) HasNonstandardPort() bool {
+	port := u.Port()
+	switch {
+	case port == "":
+		return false
+	case u.Scheme == "http":
+		return port != "80"
+	case u.Scheme == "https":
+		return port != "443"
+	default:
+		return true
+	}
+}
+
 // Equal returns true if this link is identical to `other`.
 func (u *URL) Equal(other *URL) bool {
 	return u.LastCrawled.Equal(other.LastCrawled) && u.EqualIgnoreLastCrawled(other)
@@ -247,3 +386,9 @@ func (u *URL) Equal(other *URL) bool {
 func (u *URL) EqualIgnoreLastCrawled(other *URL) bool {
 	return *u.URL == *other.URL
 }
+
+// EqualCanonical returns true if this link and `other` have the same
+// canonical form (see Canonical), ignoring LastCrawled.
+func (u *URL) EqualCanonical(other *URL) bool {
+	return u.Canonical() == other.Canonical()
+}
@@ -0,0 +1,132 @@
+package walker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"code.google.com/p/log4go"
+)
+
+// ReadURLList reads a list of URLs to fetch (see FetchManager.URLList) from
+// the file at path: one URL per line, ignoring blank lines and lines starting
+// with #.
+func ReadURLList(path string) ([]*URL, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	links, err := readURLList(f)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read URL list %v: %v", path, err)
+	}
+	return links, nil
+}
+
+func readURLList(r io.Reader) ([]*URL, error) {
+	var links []*URL
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		u, err := ParseAndNormalizeURL(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: could not parse %q: %v", line, text, err)
+		}
+		if _, err := u.ToplevelDomainPlusOne(); err != nil {
+			return nil, fmt.Errorf("line %d: no domain in %q: %v", line, text, err)
+		}
+		links = append(links, u)
+	}
+	return links, scanner.Err()
+}
+
+// urlListDatastore wraps the Datastore of a FetchManager given a URLList, so
+// fetchers claim the list's domains and crawl exactly its links instead of
+// the Datastore's segments. Fetch results and parsed links are still stored.
+// If the Datastore is a HostClaimingDatastore the domains are claimed in it
+// too, so they are not crawled by other crawlers at the same time.
+type urlListDatastore struct {
+	Datastore
+
+	mu sync.Mutex
+
+	// domains not yet claimed, in the order they first appear in the list
+	domains []string
+
+	// domain -> its links in the list
+	links map[string][]*URL
+}
+
+func newURLListDatastore(ds Datastore, list []*URL) *urlListDatastore {
+	lds := &urlListDatastore{Datastore: ds, links: map[string][]*URL{}}
+	for _, u := range list {
+		dom, err := u.ToplevelDomainPlusOne()
+		if err != nil {
+			log4go.Warn("Skipping %v in URL list: %v", u, err)
+			continue
+		}
+		if _, ok := lds.links[dom]; !ok {
+			lds.domains = append(lds.domains, dom)
+		}
+		lds.links[dom] = append(lds.links[dom], u)
+	}
+	return lds
+}
+
+// ClaimNewHost returns the next domain of the list that could be claimed in
+// the wrapped Datastore, or "" if there is none (see pending)
+func (ds *urlListDatastore) ClaimNewHost(ctx context.Context) string {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	cds, claims := ds.Datastore.(HostClaimingDatastore)
+	for i, dom := range ds.domains {
+		if claims && !cds.ClaimHost(ctx, dom) {
+			log4go.Fine("%v from the URL list is claimed by another crawler, trying it later", dom)
+			continue
+		}
+		ds.domains = append(ds.domains[:i:i], ds.domains[i+1:]...)
+		return dom
+	}
+	return ""
+}
+
+// pending returns true if some domains of the list have not been claimed yet,
+// ex. because they were claimed by other crawlers when last tried
+func (ds *urlListDatastore) pending() bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return len(ds.domains) > 0
+}
+
+// LinksForHost returns the links of the list in host
+func (ds *urlListDatastore) LinksForHost(ctx context.Context, host string) <-chan *URL {
+	ds.mu.Lock()
+	links := ds.links[host]
+	delete(ds.links, host)
+	ds.mu.Unlock()
+
+	c := make(chan *URL, len(links))
+	for _, u := range links {
+		c <- u
+	}
+	close(c)
+	return c
+}
+
+// UnclaimHost releases host in the wrapped Datastore if it is a
+// HostClaimingDatastore. It never calls the wrapped UnclaimHost, which would
+// drop the segment of links the Datastore may have for host.
+func (ds *urlListDatastore) UnclaimHost(ctx context.Context, host string) {
+	if cds, ok := ds.Datastore.(HostClaimingDatastore); ok {
+		cds.ReleaseHost(ctx, host)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var backupOut string
+var backupLinks bool
+
+func init() {
+	backupDomainsCommand.Flags().StringVarP(&backupOut, "out", "o", "", "file to write the backup to")
+	backupDomainsCommand.Flags().BoolVarP(&backupLinks, "links", "l", false, "also back up the links table")
+	UtilCommand.AddCommand(&backupDomainsCommand)
+}
+
+var backupDomainsCommand = cobra.Command{
+	Use:   "backup-domains --out <file>",
+	Short: "Write domain_info (and optionally links) to a gzipped JSON lines file",
+	Long: `Writes every row of domain_info, and of links if --links is given (without
+response bodies), to a gzip compressed file with one JSON object per line.
+Claims and dispatch state are not written. Restore it, ex. into another
+cluster, with restore-domains (CassandraDatastore only).
+`,
+	Run: backupDomainsFunc,
+}
+
+func backupDomainsFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if backupOut == "" {
+		panic("An output file is needed to execute; add with --out/-o")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	out, err := os.Create(backupOut)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create %v: %v", backupOut, err))
+	}
+	gz := gzip.NewWriter(out)
+	n, err := ds.BackupDomains(context.Background(), gz, backupLinks)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Backup failed after %d rows: %v", n, err))
+	}
+	fmt.Printf("Wrote %d rows to %v\n", n, backupOut)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var deadLinksOut string
+
+func init() {
+	deadLinksCommand.Flags().StringVarP(&deadLinksOut, "out", "o", "",
+		"file to write the CSV to (default stdout)")
+	UtilCommand.AddCommand(&deadLinksCommand)
+}
+
+var deadLinksCommand = cobra.Command{
+	Use:   "dead-links <domain>",
+	Short: "Export the dead links of a domain as CSV",
+	Long: `Writes every link of the given domain whose latest fetch got a 404, 410 or
+5XX status as CSV (url, status, crawl_time, first_referrer, last_referrer).
+Referrers are only known if cassandra.store_referrers was on when the links
+were found (CassandraDatastore only).
+`,
+	Run: deadLinksFunc,
+}
+
+func deadLinksFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if len(args) != 1 {
+		panic("A domain is needed to execute")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	var out io.Writer = os.Stdout
+	if deadLinksOut != "" {
+		f, err := os.Create(deadLinksOut)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to create %v: %v", deadLinksOut, err))
+		}
+		defer f.Close()
+		out = f
+	}
+
+	count, err := cassandra.WriteDeadLinksCSV(out, ds, args[0])
+	if err != nil {
+		panic(fmt.Sprintf("Failed after %d dead links: %v", count, err))
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d dead links of %v\n", count, args[0])
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var dispatchDryRun bool
+var dispatchDomain string
+
+func init() {
+	dispatchCommand.Flags().BoolVarP(&dispatchDryRun, "dry-run", "n", false,
+		"print the segment that would be generated instead of writing it")
+	dispatchCommand.Flags().StringVarP(&dispatchDomain, "domain", "d", "",
+		"the domain to generate a segment for")
+	UtilCommand.AddCommand(&dispatchCommand)
+}
+
+var dispatchCommand = cobra.Command{
+	Use:   "dispatch --dry-run --domain <domain>",
+	Short: "Preview the next segment the dispatcher would generate for a domain",
+	Long: `Runs the dispatcher's segment generation for the given domain and
+prints what the segment would contain: getnow, uncrawled and refresh links, the
+links rewritten or dropped by the duplicate content filter, or why no segment
+would be generated. Nothing is written (CassandraDatastore only). Only
+--dry-run is supported; use 'walker dispatch' to actually dispatch.
+`,
+	Run: dispatchFunc,
+}
+
+func dispatchFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if !dispatchDryRun {
+		panic("Only --dry-run is supported; use 'walker dispatch' to dispatch segments")
+	}
+	if dispatchDomain == "" {
+		panic("A domain is needed to execute, see --domain")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+	dinfo, err := ds.FindDomain(dispatchDomain)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to find domain %v: %v", dispatchDomain, err))
+	}
+	if dinfo == nil {
+		panic(fmt.Sprintf("Domain %v does not exist", dispatchDomain))
+	}
+
+	db, err := cassandra.GetConfig().CreateSession()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create cassandra session: %v", err))
+	}
+	defer db.Close()
+
+	generator := &cassandra.SegmentGenerator{DB: db}
+	p, err := generator.Preview(dispatchDomain)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if p.Skipped != "" {
+		fmt.Printf("No segment would be generated for %v: %v\n", p.Domain, p.Skipped)
+		return
+	}
+	fmt.Printf("Domain %v: %d links, %d uncrawled\n", p.Domain, p.TotalLinks, p.UncrawledLinks)
+	fmt.Printf("Eligible: %d getnow, %d uncrawled, %d refresh\n",
+		len(p.GetNow), p.EligibleUncrawled, p.EligibleRefresh)
+	if p.TrapExcludedLinks > 0 {
+		fmt.Printf("Left out by crawler trap patterns: %d uncrawled\n", p.TrapExcludedLinks)
+	}
+	if len(p.TrapPatterns) > 0 {
+		fmt.Printf("Looks like a crawler trap, suggested exclude patterns: %v\n", strings.Join(p.TrapPatterns, " "))
+	}
+	printLinks := func(title string, links []*cassandra.LinkInfo) {
+		fmt.Printf("\n%v (%d):\n", title, len(links))
+		for _, l := range links {
+			fmt.Printf("\t%v\n", l.URL)
+		}
+	}
+	printLinks("Getnow", p.GetNow)
+	printLinks("Uncrawled", p.Uncrawled)
+	printLinks("Refresh", p.Refresh)
+
+	fmt.Printf("\nRewritten by duplicate content filter (%d):\n", len(p.Rewritten))
+	for from, to := range p.Rewritten {
+		fmt.Printf("\t%v => %v\n", from, to)
+	}
+	printLinks("Dropped as duplicates", p.Duplicates)
+	printLinks("Left for a later segment", p.Deferred)
+	printLinks("Not due for a refresh", p.TooFresh)
+
+	fmt.Printf("\nTombstoned for permanently redirecting (%d):\n", len(p.PermanentRedirects))
+	for from, to := range p.PermanentRedirects {
+		fmt.Printf("\t%v => %v\n", from, to)
+	}
+}
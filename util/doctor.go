@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var doctorURL string
+var doctorConsole string
+var doctorTimeout time.Duration
+
+func init() {
+	doctorCommand.Flags().StringVarP(&doctorURL, "url", "u", "http://example.com/", "URL to resolve and fetch")
+	doctorCommand.Flags().StringVar(&doctorConsole, "console", "",
+		"URL of the console (default http://localhost:<console.port>/)")
+	doctorCommand.Flags().DurationVarP(&doctorTimeout, "timeout", "t", 10*time.Second,
+		"timeout of each network check")
+	UtilCommand.AddCommand(&doctorCommand)
+}
+
+var doctorCommand = cobra.Command{
+	Use:   "doctor",
+	Short: "Check that walker is set up to crawl",
+	Long: `Checks the setup end to end and prints what to fix for each check that
+fails: that the config loads (durations parse, patterns compile, etc.), that
+Cassandra is reachable and the keyspace has every table and column of the
+walker schema, that a row can be written and read back, that --url resolves
+and can be fetched (through the proxy in HTTP_PROXY/HTTPS_PROXY, if any), and
+that the console answers at --console. It exits non-zero if any check fails.
+`,
+	Run: doctorFunc,
+}
+
+func doctorFunc(cmd *cobra.Command, args []string) {
+	d := &doctor{out: os.Stdout, timeout: doctorTimeout}
+	if !d.checkConfig(ConfigPath) {
+		fmt.Println("The config failed to load, skipping the other checks")
+		os.Exit(1)
+	}
+	d.checkCassandra()
+	d.checkFetch(doctorURL)
+	if doctorConsole == "" {
+		doctorConsole = fmt.Sprintf("http://localhost:%d/", walker.Config.Console.Port)
+	}
+	d.checkConsole(doctorConsole)
+	if d.failed > 0 {
+		fmt.Printf("%d checks failed\n", d.failed)
+		os.Exit(1)
+	}
+	fmt.Println("All checks passed")
+}
+
+// doctor runs the checks of the util doctor command, printing the result of
+// each with a hint on how to fix it if it failed
+type doctor struct {
+	out     io.Writer
+	timeout time.Duration
+	failed  int
+}
+
+// report prints the result of one check
+func (d *doctor) report(check string, detail string, err error, hint string) {
+	if err == nil {
+		fmt.Fprintf(d.out, "[ ok ] %v: %v\n", check, detail)
+		return
+	}
+	d.failed++
+	fmt.Fprintf(d.out, "[FAIL] %v: %v\n", check, strings.TrimSpace(err.Error()))
+	if hint != "" {
+		fmt.Fprintf(d.out, "       %v\n", hint)
+	}
+}
+
+// checkConfig reads the config file at path, if not empty. It returns false
+// if the config is unusable, in which case the other checks should not run.
+func (d *doctor) checkConfig(path string) bool {
+	if path == "" {
+		d.report("config", fmt.Sprintf("using %v (or defaults if it does not exist)", walker.ConfigName), nil, "")
+		return true
+	}
+	err := walker.ReadConfigFile(path)
+	d.report("config", path, err, "Fix the settings listed above; walker.yaml documents each of them.")
+	return err == nil
+}
+
+// checkCassandra connects to cassandra, compares the keyspace with the walker
+// schema and writes and reads a probe row
+func (d *doctor) checkCassandra() {
+	cas := walker.Config.Cassandra
+	ds, err := cassandra.NewDatastore()
+	d.report("cassandra", fmt.Sprintf("connected to %v (keyspace %v)", strings.Join(cas.Hosts, ", "), cas.Keyspace), err,
+		fmt.Sprintf("Check that Cassandra is up and reachable at cassandra.hosts (%v) on cassandra.port (%d).",
+			strings.Join(cas.Hosts, ", "), cas.Port))
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	problems, err := ds.SchemaProblems()
+	if err == nil && len(problems) > 0 {
+		err = fmt.Errorf("keyspace %v does not match the walker schema: %v", cas.Keyspace, strings.Join(problems, ", "))
+	}
+	d.report("schema", "all walker tables and columns exist", err,
+		"Create the keyspace with `walker schema`, or add what is missing to it by hand (see cassandra/schema.go).")
+
+	d.report("probe row", "wrote and read back a row of walker_globals", ds.ProbeWrite(),
+		"Check that the walker user can write to the keyspace and cassandra.read_consistency can be met.")
+}
+
+// checkFetch resolves the host of link and fetches it the way the fetcher
+// would (through any proxy set in the environment)
+func (d *doctor) checkFetch(link string) {
+	u, err := url.Parse(link)
+	if err == nil && u.Hostname() == "" {
+		err = fmt.Errorf("%q has no host", link)
+	}
+	if err != nil {
+		d.report("dns", link, err, "Pass an absolute URL with --url.")
+		return
+	}
+
+	host := u.Hostname()
+	if ip, ok := walker.Config.Fetcher.DNSOverrides[host]; ok {
+		d.report("dns", fmt.Sprintf("%v is overridden to %v (fetcher.dns_overrides)", host, ip), nil, "")
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		cancel()
+		d.report("dns", fmt.Sprintf("%v resolves to %v", host, strings.Join(addrs, ", ")), err,
+			"Check /etc/resolv.conf and that this machine can reach its name servers.")
+		if err != nil {
+			return
+		}
+	}
+
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		d.report("http", link, err, "")
+		return
+	}
+	req.Header.Set("User-Agent", walker.Config.Fetcher.UserAgent)
+	via := "directly"
+	proxy, err := http.ProxyFromEnvironment(req)
+	if err != nil {
+		d.report("http", link, fmt.Errorf("Invalid proxy in the environment: %v", err),
+			"Fix HTTP_PROXY/HTTPS_PROXY, or unset them to fetch directly.")
+		return
+	}
+	if proxy != nil {
+		via = "through proxy " + proxy.Host
+	}
+	client := &http.Client{
+		Timeout:   d.timeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+	}
+	res, err := client.Do(req)
+	if err == nil {
+		res.Body.Close()
+	}
+	hint := "Check that this machine can make outbound HTTP requests."
+	if proxy != nil {
+		hint = fmt.Sprintf("Check that the proxy at %v is up, or unset HTTP_PROXY/HTTPS_PROXY.", proxy.Host)
+	}
+	detail := ""
+	if res != nil {
+		detail = fmt.Sprintf("fetched %v %v (%v)", link, via, res.Status)
+	}
+	d.report("http", detail, err, hint)
+}
+
+// checkConsole requests the console's home page at consoleURL
+func (d *doctor) checkConsole(consoleURL string) {
+	client := &http.Client{Timeout: d.timeout}
+	res, err := client.Get(consoleURL)
+	if err == nil {
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%v returned %v", consoleURL, res.Status)
+		}
+	}
+	d.report("console", fmt.Sprintf("%v is up", consoleURL), err,
+		fmt.Sprintf("Start it with `walker console`; it listens on console.port (%d).", walker.Config.Console.Port))
+}
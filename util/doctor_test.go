@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoctorNetworkChecks(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer up.Close()
+
+	tests := []struct {
+		tag    string
+		check  func(d *doctor)
+		failed int
+		output string
+	}{
+		{"fetch", func(d *doctor) { d.checkFetch(up.URL + "/") }, 0, "[ ok ] http: fetched " + up.URL + "/ directly (200 OK)"},
+		{"fetch without host", func(d *doctor) { d.checkFetch("/index.html") }, 1, "[FAIL] dns: \"/index.html\" has no host"},
+		{"console", func(d *doctor) { d.checkConsole(up.URL + "/") }, 0, "[ ok ] console: " + up.URL + "/ is up"},
+		{"console not found", func(d *doctor) { d.checkConsole(up.URL + "/nothere") }, 1,
+			"[FAIL] console: " + up.URL + "/nothere returned 404 Not Found"},
+	}
+	for _, tst := range tests {
+		var out bytes.Buffer
+		d := &doctor{out: &out, timeout: time.Second}
+		tst.check(d)
+		if d.failed != tst.failed {
+			t.Errorf("%v: expected %d failed checks, got %d:\n%v", tst.tag, tst.failed, d.failed, out.String())
+		}
+		if !strings.Contains(out.String(), tst.output) {
+			t.Errorf("%v: expected output to contain %q, got:\n%v", tst.tag, tst.output, out.String())
+		}
+	}
+}
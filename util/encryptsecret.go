@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/iParadigms/walker"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	UtilCommand.AddCommand(&encryptSecretCommand)
+}
+
+var encryptSecretCommand = cobra.Command{
+	Use:   "encrypt-secret [secret]",
+	Short: "Encrypt a password or token for fetcher.domain_credentials",
+	Long: `Encrypts the given secret (or, if none is given, the first line read from
+stdin, which keeps it out of shell history) with fetcher.credentials_key and
+prints the "encrypted:..." value to put in fetcher.domain_credentials.
+`,
+	Run: encryptSecretFunc,
+}
+
+func encryptSecretFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+
+	var secret string
+	if len(args) > 0 {
+		secret = args[0]
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			panic(fmt.Sprintf("Failed to read secret from stdin: %v", err))
+		}
+		secret = strings.TrimRight(line, "\r\n")
+	}
+
+	encrypted, err := walker.EncryptSecret(secret)
+	if err != nil {
+		panic(err.Error())
+	}
+	fmt.Println(encrypted)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var excludePattern string
+var excludeReason string
+
+func init() {
+	excludeDomainsCommand.Flags().StringVarP(&excludePattern, "pattern", "p", "",
+		"regular expression matching the domains to exclude")
+	excludeDomainsCommand.Flags().StringVarP(&excludeReason, "reason", "r", "Manual exclude",
+		"exclude reason to record for each domain")
+	UtilCommand.AddCommand(&excludeDomainsCommand)
+}
+
+var excludeDomainsCommand = cobra.Command{
+	Use:   "exclude-domains",
+	Short: "Exclude all domains matching a regular expression",
+	Long: `Marks every domain in domain_info whose name matches --pattern as
+excluded from the crawl, with --reason as the exclude reason. Useful for
+cutting a whole spam network out of a broad crawl (CassandraDatastore only).
+`,
+	Run: excludeDomainsFunc,
+}
+
+func excludeDomainsFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if excludePattern == "" {
+		panic("A domain pattern is needed to execute; add with --pattern/-p")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	count, err := ds.ExcludeDomainsMatching(excludePattern, excludeReason)
+	fmt.Printf("Excluded %d domains\n", count)
+	if err != nil {
+		panic(err.Error())
+	}
+}
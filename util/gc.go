@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	UtilCommand.AddCommand(&gcCommand)
+}
+
+var gcCommand = cobra.Command{
+	Use:   "gc",
+	Short: "Delete link history past the configured retention limits",
+	Long: `Makes one pass over the links table, deleting the crawls of each link beyond
+the newest cassandra.history_max_crawls or older than cassandra.history_max_age
+(with their bodies), at up to cassandra.history_gc_rate deletes per second, and
+reports how much it deleted. The latest crawl of each link is always kept. The
+dispatcher can also do this periodically; see dispatcher.history_gc_interval
+(CassandraDatastore only).
+`,
+	Run: gcFunc,
+}
+
+func gcFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	stats, err := ds.CollectHistory(ctx)
+	if err != nil {
+		panic(fmt.Sprintf("History collection failed (%v so far): %v", stats, err))
+	}
+	fmt.Printf("History collection %v\n", stats)
+}
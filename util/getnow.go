@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	UtilCommand.AddCommand(&getNowCommand)
+}
+
+var getNowCommand = cobra.Command{
+	Use:   "get-now <url> [url...]",
+	Short: "Fetch links in the next dispatch of their domains",
+	Long: `Sets the getnow flag on each given link, so the dispatcher puts it in the
+next segment it generates for the link's domain no matter when it was last
+crawled. Links walker does not know yet are added (CassandraDatastore only).
+`,
+	Run: getNowFunc,
+}
+
+func getNowFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if len(args) == 0 {
+		panic("At least one url is needed to execute")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	for _, link := range args {
+		u, err := walker.ParseAndNormalizeURL(link)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to parse %v: %v", link, err))
+		}
+		if err := ds.MarkGetNow(u); err != nil {
+			panic(err.Error())
+		}
+		fmt.Printf("Marked %v to get now\n", u)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var resumeDomains bool
+
+func init() {
+	pauseDomainCommand.Flags().BoolVarP(&resumeDomains, "resume", "r", false,
+		"resume the given domains instead of pausing them")
+	UtilCommand.AddCommand(&pauseDomainCommand)
+}
+
+var pauseDomainCommand = cobra.Command{
+	Use:   "pause-domain <domain> [domain...]",
+	Short: "Pause (or with --resume, resume) crawling of domains",
+	Long: `Pauses crawling of each given domain: the dispatcher will not generate
+segments for it and fetchers will not claim it. Unlike exclusion, the domain's
+links and history are untouched. Pass --resume to crawl the domains again
+(CassandraDatastore only).
+`,
+	Run: pauseDomainFunc,
+}
+
+func pauseDomainFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if len(args) == 0 {
+		panic("At least one domain is needed to execute")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	for _, domain := range args {
+		dinfo, err := ds.FindDomain(domain)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to find domain %v: %v", domain, err))
+		}
+		if dinfo == nil {
+			panic(fmt.Sprintf("Domain %v does not exist", domain))
+		}
+
+		if resumeDomains {
+			err = ds.ResumeDomain(domain)
+		} else {
+			err = ds.PauseDomain(domain)
+		}
+		if err != nil {
+			panic(err.Error())
+		}
+		if resumeDomains {
+			fmt.Printf("Resumed %v\n", domain)
+		} else {
+			fmt.Printf("Paused %v\n", domain)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var approvePending bool
+var rejectPending bool
+var rejectReason string
+var pendingLimit int
+
+func init() {
+	pendingDomainsCommand.Flags().BoolVarP(&approvePending, "approve", "a", false,
+		"approve the given domains, adding them to the crawl")
+	pendingDomainsCommand.Flags().BoolVarP(&rejectPending, "reject", "x", false,
+		"reject the given domains, adding them as excluded")
+	pendingDomainsCommand.Flags().StringVarP(&rejectReason, "reason", "r", "Rejected pending domain",
+		"exclude reason to record for rejected domains")
+	pendingDomainsCommand.Flags().IntVarP(&pendingLimit, "limit", "l", 0,
+		"list at most this many domains (0 lists all)")
+	UtilCommand.AddCommand(&pendingDomainsCommand)
+}
+
+var pendingDomainsCommand = cobra.Command{
+	Use:   "pending-domains [domain...]",
+	Short: "List, approve or reject pending domains",
+	Long: `With no flags, lists the domains waiting in pending_domains (see
+cassandra.track_pending_domains), most referenced first. Pass --approve to add
+the given domains to the crawl, or --reject to add them as excluded
+(CassandraDatastore only).
+`,
+	Run: pendingDomainsFunc,
+}
+
+func pendingDomainsFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if approvePending && rejectPending {
+		panic("Only one of --approve and --reject can be given")
+	}
+	if (approvePending || rejectPending) && len(args) == 0 {
+		panic("At least one domain is needed to approve or reject")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	if !approvePending && !rejectPending {
+		pending, err := ds.ListPendingDomains(pendingLimit)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, p := range pending {
+			fmt.Printf("%v\t%d\n", p.Domain, p.References)
+		}
+		return
+	}
+
+	for _, domain := range args {
+		if approvePending {
+			err = ds.ApprovePendingDomain(domain)
+		} else {
+			err = ds.RejectPendingDomain(domain, rejectReason)
+		}
+		if err != nil {
+			panic(err.Error())
+		}
+		if approvePending {
+			fmt.Printf("Approved %v\n", domain)
+		} else {
+			fmt.Printf("Rejected %v\n", domain)
+		}
+	}
+}
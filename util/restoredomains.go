@@ -0,0 +1,60 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var restoreIn string
+
+func init() {
+	restoreDomainsCommand.Flags().StringVarP(&restoreIn, "in", "i", "", "backup file to restore")
+	UtilCommand.AddCommand(&restoreDomainsCommand)
+}
+
+var restoreDomainsCommand = cobra.Command{
+	Use:   "restore-domains --in <file>",
+	Short: "Insert the rows of a backup-domains file",
+	Long: `Inserts the rows of a file written by backup-domains into the configured
+keyspace. Restored domains are unclaimed and undispatched, so the dispatcher
+generates new segments for them. Rows already in the keyspace are overwritten
+(CassandraDatastore only).
+`,
+	Run: restoreDomainsFunc,
+}
+
+func restoreDomainsFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if restoreIn == "" {
+		panic("An input file is needed to execute; add with --in/-i")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	in, err := os.Open(restoreIn)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open %v: %v", restoreIn, err))
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read %v: %v", restoreIn, err))
+	}
+	n, err := ds.RestoreDomains(context.Background(), gz)
+	if err != nil {
+		panic(fmt.Sprintf("Restore failed after %d rows: %v", n, err))
+	}
+	fmt.Printf("Restored %d rows from %v\n", n, restoreIn)
+}
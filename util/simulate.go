@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/iParadigms/walker"
+	"github.com/spf13/cobra"
+)
+
+var simulateDomain string
+var simulatePages int
+
+func init() {
+	simulateCommand.Flags().StringVarP(&simulateDomain, "domain", "d", "", "domain (or URL) to crawl")
+	simulateCommand.Flags().IntVarP(&simulatePages, "pages", "n", 100, "maximum number of pages to fetch")
+	UtilCommand.AddCommand(&simulateCommand)
+}
+
+var simulateCommand = cobra.Command{
+	Use:   "simulate --domain <domain>",
+	Short: "Crawl a few pages of one domain and report what walker would do",
+	Long: `Crawls up to --pages pages of one domain in-process, with the real fetcher
+and the current config but without touching the datastore, and prints the
+pages fetched, the links discovered, what robots.txt excluded, which links
+normalization changed and which links would not be stored (and why). --domain
+can also be a URL to start the crawl from.
+`,
+	Run: simulateFunc,
+}
+
+func simulateFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+	if simulateDomain == "" {
+		panic("A domain is needed to execute; add with --domain/-d")
+	}
+
+	report, err := walker.Simulate(simulateDomain, simulatePages)
+	if err != nil {
+		panic(fmt.Sprintf("Simulation failed: %v", err))
+	}
+	report.Print(os.Stdout)
+}
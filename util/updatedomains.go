@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iParadigms/walker"
+	"github.com/iParadigms/walker/cassandra"
+	"github.com/spf13/cobra"
+)
+
+var updateTag string
+var updateWorking bool
+var updateLimit int
+var updatePriority int
+var updateExclude string
+var updateUnexclude bool
+var updateIfUnchanged bool
+
+func init() {
+	updateDomainsCommand.Flags().StringVarP(&updateTag, "tag", "t", "",
+		"only update domains carrying this tag")
+	updateDomainsCommand.Flags().BoolVarP(&updateWorking, "working", "w", false,
+		"only update dispatched domains")
+	updateDomainsCommand.Flags().IntVarP(&updateLimit, "limit", "l", 0,
+		"update at most this many domains (0 for all)")
+	updateDomainsCommand.Flags().IntVarP(&updatePriority, "priority", "p", 0,
+		"priority to set")
+	updateDomainsCommand.Flags().StringVarP(&updateExclude, "exclude", "e", "",
+		"exclude the domains, recording this exclude reason")
+	updateDomainsCommand.Flags().BoolVarP(&updateUnexclude, "unexclude", "u", false,
+		"lift the exclusion of the domains")
+	updateDomainsCommand.Flags().BoolVarP(&updateIfUnchanged, "if-unchanged", "c", false,
+		"skip domains changed by someone else while updating (slower)")
+	UtilCommand.AddCommand(&updateDomainsCommand)
+}
+
+var updateDomainsCommand = cobra.Command{
+	Use:   "update-domains",
+	Short: "Set the priority or exclusion of many domains at once",
+	Long: `Sets the priority (--priority) and/or exclusion (--exclude or --unexclude)
+of every domain matching --tag and --working, or of every domain if neither is
+given. With --if-unchanged each domain is updated with a lightweight
+transaction, so a domain edited elsewhere meanwhile is skipped and reported
+rather than overwritten (CassandraDatastore only).
+`,
+	Run: updateDomainsFunc,
+}
+
+func updateDomainsFunc(cmd *cobra.Command, args []string) {
+	if ConfigPath != "" {
+		walker.MustReadConfigFile(ConfigPath)
+	}
+
+	info := &cassandra.DomainInfo{}
+	cfg := cassandra.DomainInfoUpdateConfig{IfUnchanged: updateIfUnchanged}
+	if cmd.Flags().Changed("priority") {
+		cfg.Priority = true
+		info.Priority = updatePriority
+	}
+	if updateExclude != "" && updateUnexclude {
+		panic("Only one of --exclude/-e and --unexclude/-u may be given")
+	}
+	if updateExclude != "" || updateUnexclude {
+		cfg.Exclude = true
+		info.Excluded = updateExclude != ""
+		info.ExcludeReason = updateExclude
+	}
+	if !cfg.Priority && !cfg.Exclude {
+		panic("Nothing to update; add --priority/-p, --exclude/-e or --unexclude/-u")
+	}
+
+	ds, err := cassandra.NewDatastore()
+	if err != nil {
+		panic(fmt.Sprintf("Failed creating Cassandra datastore: %v", err))
+	}
+	defer ds.Close()
+
+	query := cassandra.DQ{Tag: updateTag, Working: updateWorking, Limit: updateLimit}
+	result, err := ds.UpdateDomains(query, info, cfg)
+	if result != nil {
+		fmt.Printf("Updated %d of %d matching domains\n", result.Updated, result.Matched)
+		if len(result.Conflicts) > 0 {
+			fmt.Printf("Skipped %d domains changed meanwhile: %v\n", len(result.Conflicts),
+				strings.Join(result.Conflicts, ", "))
+		}
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}